@@ -7,11 +7,15 @@ import (
 	"os"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/victoria-metrics/matview"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert"
 	vminsertcommon "github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
 	vminsertrelabel "github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/relabel"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/graphite"
+	vmselectprometheus "github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/prometheus"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/quota"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/buildinfo"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
@@ -34,7 +38,7 @@ var (
 	minScrapeInterval = flag.Duration("dedup.minScrapeInterval", 0, "Leave only the last sample in every time series per each discrete interval "+
 		"equal to -dedup.minScrapeInterval > 0. See also -streamAggr.dedupInterval and https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#deduplication")
 	dryRun = flag.Bool("dryRun", false, "Whether to check config files without running VictoriaMetrics. The following config files are checked: "+
-		"-promscrape.config, -relabelConfig and -streamAggr.config. Unknown config entries aren't allowed in -promscrape.config by default. "+
+		"-promscrape.config, -relabelConfig, -streamAggr.config, -search.quotaConfig, -search.graphiteStorageAggregationConfig and -materializedViews.config. Unknown config entries aren't allowed in -promscrape.config by default. "+
 		"This can be changed with -promscrape.config.strictParse=false command-line flag")
 	inmemoryDataFlushInterval = flag.Duration("inmemoryDataFlushInterval", 5*time.Second, "The interval for guaranteed saving of in-memory data to disk. "+
 		"The saved data survives unclean shutdowns such as OOM crash, hardware reset, SIGKILL, etc. "+
@@ -78,6 +82,15 @@ func main() {
 		if err := vminsertcommon.CheckStreamAggrConfig(); err != nil {
 			logger.Fatalf("error when checking -streamAggr.config: %s", err)
 		}
+		if err := quota.CheckConfig(); err != nil {
+			logger.Fatalf("error when checking -search.quotaConfig: %s", err)
+		}
+		if err := graphite.CheckStorageAggregationConfig(); err != nil {
+			logger.Fatalf("error when checking -search.graphiteStorageAggregationConfig: %s", err)
+		}
+		if err := matview.CheckConfig(); err != nil {
+			logger.Fatalf("error when checking -materializedViews.config: %s", err)
+		}
 		logger.Infof("-promscrape.config is ok; exiting with 0 status code")
 		return
 	}
@@ -94,8 +107,11 @@ func main() {
 		logger.Fatalf("-dedup.finalDedupScheduleCheckInterval cannot be smaller than 1 hour; got %s", *finalDedupScheduleInterval)
 	}
 	storage.SetFinalDedupScheduleInterval(*finalDedupScheduleInterval)
-	vmstorage.Init(promql.ResetRollupResultCacheIfNeeded)
+	vmstorage.Init(promql.ResetRollupResultCacheIfNeeded, promql.ResetRollupResultCache)
 	vmselect.Init()
+	matview.Init()
+	vmselectprometheus.SetMaterializedViewsRewriter(matview.RewriteQuery)
+	vminsertcommon.SetMaterializedViewsHook(matview.Enabled, matview.Push)
 	vminsertcommon.StartIngestionRateLimiter(*maxIngestionRate)
 	vminsert.Init()
 
@@ -121,6 +137,7 @@ func main() {
 	logger.Infof("successfully shut down the webservice in %.3f seconds", time.Since(startTime).Seconds())
 	vminsert.Stop()
 	vminsertcommon.StopIngestionRateLimiter()
+	matview.Stop()
 
 	vmstorage.Stop()
 	vmselect.Stop()
@@ -146,6 +163,7 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 			{"metric-relabel-debug", "debug metric relabeling"},
 			{"expand-with-exprs", "WITH expressions' tutorial"},
 			{"api/v1/targets", "advanced information about discovered targets in JSON format"},
+			{"api/v1/targets/health", "availability and scrape duration stats for scrape jobs in JSON format"},
 			{"config", "-promscrape.config contents"},
 			{"metrics", "available service metrics"},
 			{"flags", "command-line flags"},
@@ -0,0 +1,470 @@
+package matview
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs/fscore"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/streamaggr"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var materializedViewsConfig = flag.String("materializedViews.config", "", "Optional path to a file with materialized views. "+
+	"Materialized views are calculated from the ingested samples and are used for queries matching the views. "+
+	"The path can point either to local file or to http url. "+
+	"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#materialized-views . The config is reloaded on SIGHUP signal")
+
+// Init must be called after vmstorage.Init and before accepting samples for ingestion.
+//
+// Stop must be called when materialized views are no longer needed.
+func Init() {
+	stopCh = make(chan struct{})
+	if len(*materializedViewsConfig) == 0 {
+		return
+	}
+
+	// Register SIGHUP handler for config re-read just before loadConfig call.
+	// This guarantees that the config will be re-read if the signal arrives during loadConfig call.
+	sighupCh := procutil.NewSighupChan()
+
+	configReloads = metrics.NewCounter(`vm_materialized_views_config_reloads_total`)
+	configReloadErrors = metrics.NewCounter(`vm_materialized_views_config_reloads_errors_total`)
+	configSuccess = metrics.NewGauge(`vm_materialized_views_config_last_reload_successful`, nil)
+	configTimestamp = metrics.NewCounter(`vm_materialized_views_config_last_reload_success_timestamp_seconds`)
+
+	statePath = filepath.Join(*vmstorage.DataPath, "materializedViews.json")
+	states = mustLoadStates(statePath)
+
+	vcs, err := loadConfig()
+	if err != nil {
+		logger.Fatalf("cannot load -materializedViews.config: %s", err)
+	}
+	vs, err := newViews(vcs)
+	if err != nil {
+		logger.Fatalf("cannot initialize materialized views from -materializedViews.config=%q: %s", *materializedViewsConfig, err)
+	}
+	vsGlobal.Store(vs)
+	mustSaveStates()
+	configSuccess.Set(1)
+	configTimestamp.Set(fasttime.UnixTimestamp())
+	logger.Infof("loaded %d materialized views from -materializedViews.config=%q", len(vs.views), *materializedViewsConfig)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-sighupCh:
+			case <-stopCh:
+				return
+			}
+			reloadConfig()
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(statesSaveInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+			case <-stopCh:
+				return
+			}
+			mustSaveStates()
+		}
+	}()
+}
+
+// statesSaveInterval is the interval for persisting the time ranges covered by views.
+const statesSaveInterval = 10 * time.Second
+
+// Stop stops materialized views.
+func Stop() {
+	close(stopCh)
+	wg.Wait()
+
+	vs := vsGlobal.Load()
+	if vs != nil {
+		vs.sas.MustStop()
+		mustSaveStates()
+		vsGlobal.Store(nil)
+	}
+}
+
+var (
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	configReloads      *metrics.Counter
+	configReloadErrors *metrics.Counter
+	configSuccess      *metrics.Gauge
+	configTimestamp    *metrics.Counter
+
+	samplesWritten = metrics.NewCounter(`vm_materialized_views_samples_written_total`)
+	queryRewrites  = metrics.NewCounter(`vm_materialized_views_query_rewrites_total`)
+
+	_ = metrics.NewGauge(`vm_materialized_views`, func() float64 {
+		vs := vsGlobal.Load()
+		if vs == nil {
+			return 0
+		}
+		return float64(len(vs.views))
+	})
+)
+
+func reloadConfig() {
+	configReloads.Inc()
+	logger.Infof("received SIGHUP; reloading -materializedViews.config=%q...", *materializedViewsConfig)
+	vcs, err := loadConfig()
+	if err != nil {
+		configReloadErrors.Inc()
+		configSuccess.Set(0)
+		logger.Errorf("cannot load the updated -materializedViews.config: %s; preserving the previous config", err)
+		return
+	}
+	vsNew, err := newViews(vcs)
+	if err != nil {
+		configReloadErrors.Inc()
+		configSuccess.Set(0)
+		logger.Errorf("cannot initialize materialized views from the updated -materializedViews.config=%q: %s; preserving the previous config", *materializedViewsConfig, err)
+		return
+	}
+	vs := vsGlobal.Load()
+	if vsNew.sas.Equal(vs.sas) {
+		vsNew.sas.MustStop()
+		logger.Infof("nothing changed in -materializedViews.config=%q", *materializedViewsConfig)
+	} else {
+		vsOld := vsGlobal.Swap(vsNew)
+		vsOld.sas.MustStop()
+		// The new aggregators start calculating the views from scratch, so the first interval may be incomplete.
+		resetStartedViews()
+		mustSaveStates()
+		logger.Infof("successfully reloaded -materializedViews.config=%q", *materializedViewsConfig)
+	}
+	configSuccess.Set(1)
+	configTimestamp.Set(fasttime.UnixTimestamp())
+}
+
+// CheckConfig checks config pointed by -materializedViews.config
+func CheckConfig() error {
+	vcs, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	for _, vc := range vcs {
+		if _, err := newView(vc); err != nil {
+			return fmt.Errorf("invalid materialized view %q: %w", vc.Name, err)
+		}
+	}
+	return nil
+}
+
+func loadConfig() ([]*ViewConfig, error) {
+	if len(*materializedViewsConfig) == 0 {
+		return nil, nil
+	}
+	data, err := fscore.ReadFileOrHTTP(*materializedViewsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read -materializedViews.config=%q: %w", *materializedViewsConfig, err)
+	}
+	vcs, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse -materializedViews.config=%q: %w", *materializedViewsConfig, err)
+	}
+	return vcs, nil
+}
+
+func parseConfig(data []byte) ([]*ViewConfig, error) {
+	var vcs []*ViewConfig
+	if err := yaml.UnmarshalStrict(data, &vcs); err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{}, len(vcs))
+	for i, vc := range vcs {
+		if vc == nil {
+			return nil, fmt.Errorf("missing materialized view #%d", i+1)
+		}
+		if _, ok := names[vc.Name]; ok {
+			return nil, fmt.Errorf("duplicate materialized view name %q", vc.Name)
+		}
+		names[vc.Name] = struct{}{}
+	}
+	return vcs, nil
+}
+
+// views contains materialized views for the loaded config.
+type views struct {
+	views []*view
+
+	// byName contains views by their names.
+	byName map[string]*view
+
+	// byExpr contains views by their normalized expressions.
+	byExpr map[string]*view
+
+	// sas calculates the views from the ingested samples.
+	sas *streamaggr.Aggregators
+}
+
+var vsGlobal atomic.Pointer[views]
+
+func newViews(vcs []*ViewConfig) (*views, error) {
+	vs := &views{
+		byName: make(map[string]*view, len(vcs)),
+		byExpr: make(map[string]*view, len(vcs)),
+	}
+	aggrConfigs := make([]*streamaggr.Config, 0, len(vcs))
+	for _, vc := range vcs {
+		v, err := newView(vc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid materialized view %q: %w", vc.Name, err)
+		}
+		if vPrev := vs.byExpr[v.expr]; vPrev != nil {
+			return nil, fmt.Errorf("materialized views %q and %q have the same expr=%q", vPrev.name, v.name, v.expr)
+		}
+		vs.views = append(vs.views, v)
+		vs.byName[v.name] = v
+		vs.byExpr[v.expr] = v
+		aggrConfigs = append(aggrConfigs, v.aggrConfig)
+	}
+	data, err := yaml.Marshal(aggrConfigs)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal stream aggregation configs for materialized views: %s", err)
+	}
+	sas, err := streamaggr.LoadFromData(data, pushViewSeries, nil, "materialized_views")
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize stream aggregation for materialized views: %w", err)
+	}
+	vs.sas = sas
+	return vs, nil
+}
+
+// Enabled returns true if materialized views are configured.
+func Enabled() bool {
+	return vsGlobal.Load() != nil
+}
+
+// Push pushes the ingested tss to materialized views.
+func Push(tss []prompbmarshal.TimeSeries) {
+	vs := vsGlobal.Load()
+	if vs == nil {
+		return
+	}
+	matchIdxs := matchIdxsPool.Get()
+	matchIdxs.B = vs.sas.Push(tss, matchIdxs.B)
+	matchIdxsPool.Put(matchIdxs)
+}
+
+var matchIdxsPool bytesutil.ByteBufferPool
+
+// pushViewSeries writes the calculated views to the storage.
+func pushViewSeries(tss []prompbmarshal.TimeSeries) {
+	mrs := make([]storage.MetricRow, 0, len(tss))
+	var buf []byte
+	flushTimestamps := make(map[string]int64)
+	for _, ts := range tss {
+		name := getMetricName(ts.Labels)
+		for _, s := range ts.Samples {
+			bufLen := len(buf)
+			buf = storage.MarshalMetricNameRaw(buf, ts.Labels)
+			mrs = append(mrs, storage.MetricRow{
+				MetricNameRaw: buf[bufLen:],
+				Timestamp:     s.Timestamp,
+				Value:         s.Value,
+			})
+			if s.Timestamp > flushTimestamps[name] {
+				flushTimestamps[name] = s.Timestamp
+			}
+		}
+	}
+	if err := vmstorage.AddRows(mrs); err != nil {
+		// The results for the current interval are lost, so the next interval starts a new time range for the view.
+		logger.Errorf("cannot store materialized views: %s", err)
+		return
+	}
+	samplesWritten.Add(len(mrs))
+	for name, timestamp := range flushTimestamps {
+		markViewFlushed(name, timestamp)
+	}
+}
+
+func getMetricName(labels []prompbmarshal.Label) string {
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			return label.Value
+		}
+	}
+	return ""
+}
+
+// viewState contains the persisted state for the materialized view.
+type viewState struct {
+	// Expr is the normalized expression for the view.
+	Expr string `json:"expr"`
+
+	// Ranges contains time ranges with complete results written by the view.
+	//
+	// The view is used only for queries with the time range covered by one of these ranges.
+	Ranges []timeRange `json:"ranges"`
+}
+
+// timeRange is a time range in milliseconds covered by the view results.
+//
+// The range is empty if End < Start.
+type timeRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// maxViewRanges is the maximum number of time ranges stored per view.
+//
+// A new range is started after each restart and after each missing flush, so old ranges are dropped.
+const maxViewRanges = 100
+
+var (
+	statesLock sync.Mutex
+	states     map[string]viewState
+	statePath  string
+
+	// startedViews contains views, which wrote results since the process start.
+	startedViews = make(map[string]bool)
+)
+
+func mustLoadStates(path string) map[string]viewState {
+	m := make(map[string]viewState)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Panicf("FATAL: cannot read materialized views state: %s", err)
+		}
+		return m
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		logger.Errorf("cannot parse materialized views state from %q: %s; materialized views will be used by queries only after the next aggregation interval", path, err)
+		return make(map[string]viewState)
+	}
+	return m
+}
+
+// mustSaveStates saves states for the currently configured views.
+//
+// States for views, which are missing in the current config, are removed.
+func mustSaveStates() {
+	vs := vsGlobal.Load()
+
+	statesLock.Lock()
+	defer statesLock.Unlock()
+
+	m := make(map[string]viewState, len(vs.views))
+	for _, v := range vs.views {
+		if st, ok := states[v.name]; ok && st.Expr == v.expr {
+			m[v.name] = st
+		} else {
+			delete(startedViews, v.name)
+		}
+	}
+	states = m
+	mustWriteStatesLocked()
+}
+
+func mustWriteStatesLocked() {
+	data, err := json.Marshal(states)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal materialized views state: %s", err)
+	}
+	fs.MustWriteAtomic(statePath, data, true)
+}
+
+// markViewFlushed registers the results written by the view with the given name at the given timestamp.
+//
+// The states are persisted only when a new time range is started. Updates for the end of the current range
+// are persisted by the caller periodically, since losing them on crash results only in not using the view for the last results.
+func markViewFlushed(name string, timestamp int64) {
+	vs := vsGlobal.Load()
+	if vs == nil {
+		return
+	}
+	v := vs.byName[name]
+	if v == nil {
+		return
+	}
+
+	statesLock.Lock()
+	defer statesLock.Unlock()
+
+	st, ok := states[name]
+	if !ok || st.Expr != v.expr {
+		st = viewState{
+			Expr: v.expr,
+		}
+	}
+	if startedViews[name] && len(st.Ranges) > 0 {
+		tr := &st.Ranges[len(st.Ranges)-1]
+		if timestamp <= tr.End {
+			// The results for the given interval are already registered.
+			return
+		}
+		if timestamp == tr.End+v.window {
+			tr.End = timestamp
+			states[name] = st
+			return
+		}
+		// Some intervals are missing. This may happen if the flush was delayed,
+		// so the results at the timestamp may contain samples for multiple intervals.
+	}
+	// The first flushed interval after the start or after the missing interval may be incomplete.
+	// So the view contains complete results only since the next interval.
+	st.Ranges = append(st.Ranges, timeRange{
+		Start: timestamp + v.window,
+		End:   timestamp,
+	})
+	if len(st.Ranges) > maxViewRanges {
+		st.Ranges = append(st.Ranges[:0], st.Ranges[len(st.Ranges)-maxViewRanges:]...)
+	}
+	states[name] = st
+	startedViews[name] = true
+	mustWriteStatesLocked()
+}
+
+// resetStartedViews makes the next results for every view to start a new time range.
+func resetStartedViews() {
+	statesLock.Lock()
+	clear(startedViews)
+	statesLock.Unlock()
+}
+
+// isViewCovering returns true if the view v contains complete results for the time range [start ... end].
+func isViewCovering(v *view, start, end int64) bool {
+	statesLock.Lock()
+	defer statesLock.Unlock()
+
+	st, ok := states[v.name]
+	if !ok || st.Expr != v.expr {
+		return false
+	}
+	for _, tr := range st.Ranges {
+		if tr.Start <= start && end <= tr.End {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package matview
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseConfigFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		vcs, err := parseConfig([]byte(s))
+		if err == nil {
+			_, err = newViews(vcs)
+		}
+		if err == nil {
+			t.Fatalf("expecting non-nil error for config\n%s", s)
+		}
+	}
+
+	// invalid yaml
+	f(`foo`)
+	f(`- name: foo
+  expr: sum(rate(bar[1m]))
+  unknown: baz`)
+
+	// missing name
+	f(`- expr: sum(rate(bar[1m]))`)
+
+	// invalid name
+	f(`- name: foo-bar
+  expr: sum(rate(bar[1m]))`)
+
+	// duplicate name
+	f(`- name: foo
+  expr: sum(rate(bar[1m]))
+- name: foo
+  expr: sum(rate(baz[1m]))`)
+
+	// duplicate expr
+	f(`- name: foo
+  expr: sum(rate(bar[1m])) by (job)
+- name: baz
+  expr: sum by (job) (rate(bar[1m]))`)
+
+	// missing expr
+	f(`- name: foo`)
+
+	// invalid expr
+	f(`- name: foo
+  expr: sum(rate(`)
+
+	// unsupported expressions
+	f(`- name: foo
+  expr: rate(bar[1m])`)
+	f(`- name: foo
+  expr: sum(bar)`)
+	f(`- name: foo
+  expr: sum(rate(bar))`)
+	f(`- name: foo
+  expr: quantile(0.5, rate(bar[1m]))`)
+	f(`- name: foo
+  expr: sum(rate(bar[1m])) limit 3`)
+	f(`- name: foo
+  expr: sum(deriv(bar[1m]))`)
+	f(`- name: foo
+  expr: max(rate(bar[1m]))`)
+	f(`- name: foo
+  expr: sum(rate(bar[1m:10s]))`)
+	f(`- name: foo
+  expr: sum(rate(bar[1m] offset 5m))`)
+	f(`- name: foo
+  expr: sum(rate({job="bar"}[1m]))`)
+	f(`- name: foo
+  expr: sum(rate({__name__=~"bar.*"}[1m]))`)
+	f(`- name: foo
+  expr: sum(rate({__name__="bar" or __name__="baz"}[1m]))`)
+	f(`- name: foo
+  expr: sum(rate(bar[1500ms]))`)
+	f(`- name: foo
+  expr: sum(rate(bar[1m])) by (__name__)`)
+	f(`- name: foo
+  expr: sum(rate(bar[1m])) keep_metric_names`)
+}
+
+func TestNewViewSuccess(t *testing.T) {
+	f := func(expr, exprExpected, outputExpected, intervalExpected string, byExpected, withoutExpected []string) {
+		t.Helper()
+		v, err := newView(&ViewConfig{
+			Name: "foo:bar",
+			Expr: expr,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if v.expr != exprExpected {
+			t.Fatalf("unexpected expr; got %q; want %q", v.expr, exprExpected)
+		}
+		cfg := v.aggrConfig
+		if !reflect.DeepEqual(cfg.Outputs, []string{outputExpected}) {
+			t.Fatalf("unexpected outputs; got %q; want %q", cfg.Outputs, outputExpected)
+		}
+		if cfg.Interval != intervalExpected {
+			t.Fatalf("unexpected interval; got %q; want %q", cfg.Interval, intervalExpected)
+		}
+		if !reflect.DeepEqual(cfg.By, byExpected) {
+			t.Fatalf("unexpected by; got %q; want %q", cfg.By, byExpected)
+		}
+		if !reflect.DeepEqual(cfg.Without, withoutExpected) {
+			t.Fatalf("unexpected without; got %q; want %q", cfg.Without, withoutExpected)
+		}
+	}
+
+	f(`sum(rate(http_requests_total[1m]))`, `sum(rate(http_requests_total[1m]))`, "rate_sum", "1m0s", []string{"__name__"}, nil)
+	f(`sum(rate(http_requests_total{path!="/metrics"}[5m])) by (job, instance)`,
+		`sum(rate(http_requests_total{path!="/metrics"}[5m])) by(job,instance)`, "rate_sum", "5m0s", []string{"job", "instance"}, nil)
+	f(`sum by () (rate(foo[30s]))`, `sum(rate(foo[30s])) by()`, "rate_sum", "30s", []string{"__name__"}, nil)
+	f(`avg(rate(foo[1m])) without (instance)`, `avg(rate(foo[1m])) without(instance)`, "rate_avg", "1m0s", nil, []string{"instance"})
+	f(`sum(increase(foo[1h])) without ()`, `sum(increase(foo[1h])) without()`, "increase", "1h0m0s", nil, []string{})
+	f(`sum(sum_over_time(foo[1m]))`, `sum(sum_over_time(foo[1m]))`, "sum_samples", "1m0s", []string{"__name__"}, nil)
+	f(`sum(count_over_time(foo[1m]))`, `sum(count_over_time(foo[1m]))`, "count_samples", "1m0s", []string{"__name__"}, nil)
+	f(`count(count_over_time(foo[1m]))`, `count(count_over_time(foo[1m]))`, "count_series", "1m0s", []string{"__name__"}, nil)
+	f(`max(max_over_time(foo[1m])) by (job)`, `max(max_over_time(foo[1m])) by(job)`, "max", "1m0s", []string{"job"}, nil)
+	f(`min(min_over_time(foo[1m])) by (job)`, `min(min_over_time(foo[1m])) by(job)`, "min", "1m0s", []string{"job"}, nil)
+}
+
+func TestRewriteQuery(t *testing.T) {
+	vcs, err := parseConfig([]byte(`
+- name: job:requests:rate1m
+  expr: sum(rate(requests_total[1m])) by (job)
+  rewrite_queries: true
+- name: requests:max5m
+  expr: max(max_over_time(requests_total[5m]))
+  rewrite_queries: true
+- name: requests:min1m
+  expr: min(min_over_time(requests_total[1m]))
+`))
+	if err != nil {
+		t.Fatalf("cannot parse config: %s", err)
+	}
+	vs, err := newViews(vcs)
+	if err != nil {
+		t.Fatalf("cannot create views: %s", err)
+	}
+	vsGlobal.Store(vs)
+	statePath = t.TempDir() + "/materializedViews.json"
+	states = map[string]viewState{}
+	defer func() {
+		vsGlobal.Store(nil)
+		vs.sas.MustStop()
+		states = nil
+		resetStartedViews()
+	}()
+
+	f := func(query string, start, end, step int64, forceRewrite bool, resultExpected string) {
+		t.Helper()
+		result, ok := RewriteQuery(query, start, end, step, forceRewrite)
+		if ok != (resultExpected != query) {
+			t.Fatalf("unexpected ok=%v for query %q", ok, query)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected rewritten query for %q\ngot\n%s\nwant\n%s", query, result, resultExpected)
+		}
+	}
+
+	// views don't contain data yet
+	f(`sum(rate(requests_total[1m])) by (job)`, 1080e3, 1080e3, 60e3, false, `sum(rate(requests_total[1m])) by (job)`)
+
+	for ts := int64(1020e3); ts <= 1500e3; ts += 60e3 {
+		markViewFlushed("job:requests:rate1m", ts)
+		markViewFlushed("requests:min1m", ts)
+	}
+	for ts := int64(2100e3); ts <= 3000e3; ts += 300e3 {
+		markViewFlushed("requests:max5m", ts)
+	}
+	markViewFlushed("missing", 0)
+
+	// the first interval is incomplete
+	f(`sum(rate(requests_total[1m])) by (job)`, 1020e3, 1020e3, 60e3, false, `sum(rate(requests_total[1m])) by (job)`)
+
+	// matching views
+	f(`sum(rate(requests_total[1m])) by (job)`, 1080e3, 1080e3, 60e3, false, `max(last_over_time(job:requests:rate1m[60000ms])) by(job)`)
+	f(`sum by (job) (rate(requests_total[1m]))`, 1080e3, 1500e3, 60e3, false, `max(last_over_time(job:requests:rate1m[60000ms])) by(job)`)
+	f(`topk(3, sum(rate(requests_total[1m])) by (job))`, 1080e3, 1500e3, 60e3, false, `topk(3, max(last_over_time(job:requests:rate1m[60000ms])) by(job))`)
+	f(`abs(sum(rate(requests_total[1m])) by (job))`, 1080e3, 1080e3, 60e3, false, `abs(max(last_over_time(job:requests:rate1m[60000ms])) by(job))`)
+	f(`max(max_over_time(requests_total[5m]))`, 2400e3, 3000e3, 300e3, false, `max(last_over_time(requests:max5m[300000ms]))`)
+
+	// the time range isn't covered by the view
+	f(`sum(rate(requests_total[1m])) by (job)`, 1080e3, 1560e3, 60e3, false, `sum(rate(requests_total[1m])) by (job)`)
+
+	// the step doesn't match the view window
+	f(`sum(rate(requests_total[1m])) by (job)`, 1080e3, 1500e3, 30e3, false, `sum(rate(requests_total[1m])) by (job)`)
+
+	// the time range isn't aligned to the view window
+	f(`sum(rate(requests_total[1m])) by (job)`, 1110e3, 1110e3, 60e3, false, `sum(rate(requests_total[1m])) by (job)`)
+
+	// only the view covering the time range is used
+	f(`sum(rate(requests_total[1m])) by (job) + max(max_over_time(requests_total[5m]))`, 1200e3, 1200e3, 60e3, false,
+		`max(last_over_time(job:requests:rate1m[60000ms])) by(job) + max(max_over_time(requests_total[5m]))`)
+
+	// the view without rewrite_queries is used only with forceRewrite
+	f(`min(min_over_time(requests_total[1m]))`, 1080e3, 1080e3, 60e3, false, `min(min_over_time(requests_total[1m]))`)
+	f(`min(min_over_time(requests_total[1m]))`, 1080e3, 1080e3, 60e3, true, `max(last_over_time(requests:min1m[60000ms]))`)
+
+	// non-matching queries
+	f(`sum(rate(requests_total[1m])) by (instance)`, 1080e3, 1080e3, 60e3, false, `sum(rate(requests_total[1m])) by (instance)`)
+	f(`sum(rate(requests_total{job="foo"}[1m])) by (job)`, 1080e3, 1080e3, 60e3, false, `sum(rate(requests_total{job="foo"}[1m])) by (job)`)
+	f(`sum(rate(requests_total[5m])) by (job)`, 1080e3, 1080e3, 60e3, false, `sum(rate(requests_total[5m])) by (job)`)
+	f(`sum(rate(requests_total[1m] offset 1h)) by (job)`, 1080e3, 1080e3, 60e3, false, `sum(rate(requests_total[1m] offset 1h)) by (job)`)
+
+	// subqueries aren't rewritten
+	f(`max_over_time(sum(rate(requests_total[1m])) by (job)[1h:1m])`, 1080e3, 1080e3, 60e3, false, `max_over_time(sum(rate(requests_total[1m])) by (job)[1h:1m])`)
+
+	// invalid query
+	f(`sum(rate(`, 1080e3, 1080e3, 60e3, false, `sum(rate(`)
+
+	// a missing interval starts a new time range
+	markViewFlushed("job:requests:rate1m", 1680e3)
+	f(`sum(rate(requests_total[1m])) by (job)`, 1680e3, 1680e3, 60e3, false, `sum(rate(requests_total[1m])) by (job)`)
+	markViewFlushed("job:requests:rate1m", 1740e3)
+	f(`sum(rate(requests_total[1m])) by (job)`, 1740e3, 1740e3, 60e3, false, `max(last_over_time(job:requests:rate1m[60000ms])) by(job)`)
+	f(`sum(rate(requests_total[1m])) by (job)`, 1500e3, 1740e3, 60e3, false, `sum(rate(requests_total[1m])) by (job)`)
+
+	// restart starts a new time range
+	resetStartedViews()
+	markViewFlushed("job:requests:rate1m", 1800e3)
+	f(`sum(rate(requests_total[1m])) by (job)`, 1800e3, 1800e3, 60e3, false, `sum(rate(requests_total[1m])) by (job)`)
+	f(`sum(rate(requests_total[1m])) by (job)`, 1080e3, 1500e3, 60e3, false, `max(last_over_time(job:requests:rate1m[60000ms])) by(job)`)
+}
@@ -0,0 +1,113 @@
+package matview
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+// RewriteQuery rewrites sub-expressions in the query executed on the time range [start ... end] with the given step in milliseconds,
+// so they use materialized views instead of raw samples.
+//
+// Only views with `rewrite_queries: true` are used unless forceRewrite is set.
+// The view is used only if it returns the same results as the original sub-expression. This means that:
+//
+//   - the step for range queries must be equal to the view window;
+//   - start and end must be aligned to the view window;
+//   - the view must contain complete results for the whole time range.
+//
+// The original query is returned together with false if there are no matching materialized views.
+func RewriteQuery(query string, start, end, step int64, forceRewrite bool) (string, bool) {
+	vs := vsGlobal.Load()
+	if vs == nil {
+		return query, false
+	}
+	e, err := metricsql.Parse(query)
+	if err != nil {
+		// The error is returned to the client during query execution.
+		return query, false
+	}
+	getView := func(ae *metricsql.AggrFuncExpr) *view {
+		v := vs.byExpr[string(ae.AppendString(nil))]
+		if v == nil {
+			return nil
+		}
+		if !v.rewriteQueries && !forceRewrite {
+			return nil
+		}
+		if start != end && step != v.window {
+			// The view contains a single result per window, so it cannot be used for other steps.
+			return nil
+		}
+		if start%v.window != 0 || end%v.window != 0 {
+			// The view results are calculated at timestamps aligned to the window.
+			return nil
+		}
+		if !isViewCovering(v, start, end) {
+			// The view doesn't contain complete data for the requested time range.
+			return nil
+		}
+		return v
+	}
+	e, ok := rewriteExpr(e, getView)
+	if !ok {
+		return query, false
+	}
+	queryRewrites.Inc()
+	return string(e.AppendString(nil)), true
+}
+
+// rewriteExpr replaces aggregate functions in e with the matching views returned by getView.
+//
+// Sub-expressions inside subqueries aren't rewritten, since they may be evaluated at the time range not covered by views.
+func rewriteExpr(e metricsql.Expr, getView func(ae *metricsql.AggrFuncExpr) *view) (metricsql.Expr, bool) {
+	switch t := e.(type) {
+	case *metricsql.AggrFuncExpr:
+		if v := getView(t); v != nil {
+			return newViewExpr(v), true
+		}
+		return e, rewriteArgs(t.Args, getView)
+	case *metricsql.FuncExpr:
+		if metricsql.IsRollupFunc(t.Name) {
+			// Non-selector args of rollup functions are evaluated as implicit subqueries.
+			return e, false
+		}
+		return e, rewriteArgs(t.Args, getView)
+	case *metricsql.BinaryOpExpr:
+		var okLeft, okRight bool
+		t.Left, okLeft = rewriteExpr(t.Left, getView)
+		t.Right, okRight = rewriteExpr(t.Right, getView)
+		return e, okLeft || okRight
+	default:
+		return e, false
+	}
+}
+
+func rewriteArgs(args []metricsql.Expr, getView func(ae *metricsql.AggrFuncExpr) *view) bool {
+	rewritten := false
+	for i, arg := range args {
+		var ok bool
+		args[i], ok = rewriteExpr(arg, getView)
+		if ok {
+			rewritten = true
+		}
+	}
+	return rewritten
+}
+
+// newViewExpr returns an expression, which selects the results of the view v.
+//
+// Every group from the view modifier contains a single series, so `max` returns its value as is,
+// while the modifier removes the view name from the result in the same way as the original aggregate function does.
+func newViewExpr(v *view) metricsql.Expr {
+	// The view result for the interval (t-window ... t] has the timestamp t, so the lookbehind window
+	// must be equal to the view window in order to select exactly this result at the aligned timestamp t.
+	s := fmt.Sprintf("max(last_over_time(%s[%dms]))", v.name, v.window)
+	e, err := metricsql.Parse(s)
+	if err != nil {
+		panic(fmt.Errorf("BUG: cannot parse expression for materialized view %q: %w", v.name, err))
+	}
+	ae := e.(*metricsql.AggrFuncExpr)
+	ae.Modifier = v.modifier
+	return ae
+}
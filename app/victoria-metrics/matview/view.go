@@ -0,0 +1,178 @@
+package matview
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/streamaggr"
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+// ViewConfig is a config for a single materialized view.
+type ViewConfig struct {
+	// Name is the name of the time series produced by the view.
+	Name string `yaml:"name"`
+
+	// Expr is MetricsQL expression for the view.
+	//
+	// It must have the form `aggr(rollup(selector[window])) [by|without (labels)]`.
+	Expr string `yaml:"expr"`
+
+	// RewriteQueries enables rewriting queries containing Expr, so they use the view results.
+	//
+	// Queries can be rewritten for views without this option by passing `matview=1` query arg.
+	RewriteQueries bool `yaml:"rewrite_queries,omitempty"`
+}
+
+// view is a compiled materialized view.
+type view struct {
+	name string
+
+	// expr is the normalized string representation of the view expression.
+	// It is used for matching the view against queries.
+	expr string
+
+	// window is the rollup window in milliseconds, which is used as aggregation interval.
+	window int64
+
+	// modifier is the `by` or `without` modifier from the view expression.
+	modifier metricsql.ModifierExpr
+
+	// rewriteQueries is set if queries must be rewritten with the view without `matview=1` query arg.
+	rewriteQueries bool
+
+	// aggrConfig is stream aggregation config for calculating the view.
+	aggrConfig *streamaggr.Config
+}
+
+// getOutput returns stream aggregation output, which calculates aggrFunc(rollupFunc(...)) over input samples.
+//
+// Empty string is returned if there is no matching stream aggregation output.
+func getOutput(aggrFunc, rollupFunc string) string {
+	switch aggrFunc + "(" + rollupFunc + ")" {
+	case "sum(rate)":
+		return "rate_sum"
+	case "avg(rate)":
+		return "rate_avg"
+	case "sum(increase)":
+		return "increase"
+	case "sum(sum_over_time)":
+		return "sum_samples"
+	case "sum(count_over_time)":
+		return "count_samples"
+	case "count(count_over_time)":
+		return "count_series"
+	case "max(max_over_time)":
+		return "max"
+	case "min(min_over_time)":
+		return "min"
+	default:
+		return ""
+	}
+}
+
+const supportedExprs = "sum(rate(...)), avg(rate(...)), sum(increase(...)), sum(sum_over_time(...)), sum(count_over_time(...)), " +
+	"count(count_over_time(...)), max(max_over_time(...)), min(min_over_time(...))"
+
+func newView(vc *ViewConfig) (*view, error) {
+	if vc.Name == "" {
+		return nil, fmt.Errorf("missing `name`")
+	}
+	if !isValidMetricName(vc.Name) {
+		return nil, fmt.Errorf("invalid `name: %q`; it must match %s", vc.Name, metricNameRegexp)
+	}
+	if vc.Expr == "" {
+		return nil, fmt.Errorf("missing `expr`")
+	}
+	e, err := metricsql.Parse(vc.Expr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `expr: %q`: %w", vc.Expr, err)
+	}
+	ae, ok := e.(*metricsql.AggrFuncExpr)
+	if !ok || len(ae.Args) != 1 || ae.Limit > 0 {
+		return nil, fmt.Errorf("unsupported `expr: %q`; it must have the form aggr(rollup(selector[window])) [by|without (labels)]", vc.Expr)
+	}
+	fe, ok := ae.Args[0].(*metricsql.FuncExpr)
+	if !ok || len(fe.Args) != 1 || fe.KeepMetricNames {
+		return nil, fmt.Errorf("unsupported `expr: %q`; it must have the form aggr(rollup(selector[window])) [by|without (labels)]", vc.Expr)
+	}
+	output := getOutput(ae.Name, fe.Name)
+	if output == "" {
+		return nil, fmt.Errorf("unsupported `expr: %q`; supported expressions: %s", vc.Expr, supportedExprs)
+	}
+	re, ok := fe.Args[0].(*metricsql.RollupExpr)
+	if !ok || re.Window == nil || re.ForSubquery() || re.Offset != nil || re.At != nil {
+		return nil, fmt.Errorf("unsupported `expr: %q`; the rollup function arg must be a series selector with the window in square brackets without offset and @ modifiers", vc.Expr)
+	}
+	me, ok := re.Expr.(*metricsql.MetricExpr)
+	if !ok || len(me.LabelFilterss) != 1 {
+		return nil, fmt.Errorf("unsupported `expr: %q`; the rollup function arg must be a series selector without `or` filters", vc.Expr)
+	}
+	if lfs := me.LabelFilterss[0]; len(lfs) == 0 || lfs[0].Label != "__name__" || lfs[0].IsRegexp || lfs[0].IsNegative {
+		return nil, fmt.Errorf("unsupported `expr: %q`; the series selector must contain metric name", vc.Expr)
+	}
+	window := re.Window.Duration(0)
+	if window < 1000 || window%1000 != 0 {
+		return nil, fmt.Errorf("unsupported window in `expr: %q`; it must be a multiple of a second", vc.Expr)
+	}
+	for _, label := range ae.Modifier.Args {
+		if label == "__name__" {
+			return nil, fmt.Errorf("unsupported `expr: %q`; `__name__` label cannot be used in `%s` modifier", vc.Expr, ae.Modifier.Op)
+		}
+	}
+
+	var match promrelabel.IfExpression
+	if err := match.Parse(string(me.AppendString(nil))); err != nil {
+		return nil, fmt.Errorf("cannot convert series selector from `expr: %q` to stream aggregation match: %w", vc.Expr, err)
+	}
+	keepMetricNames := true
+	// Aggregate samples by their timestamps instead of the time they are received,
+	// and drop samples for already flushed intervals, so they do not get into the wrong interval.
+	enableWindows := true
+	ignoreOldSamples := true
+	name := vc.Name
+	cfg := &streamaggr.Config{
+		Name:             vc.Name,
+		Match:            &match,
+		Interval:         (time.Duration(window) * time.Millisecond).String(),
+		Outputs:          []string{output},
+		KeepMetricNames:  &keepMetricNames,
+		EnableWindows:    &enableWindows,
+		IgnoreOldSamples: &ignoreOldSamples,
+		OutputRelabelConfigs: []promrelabel.RelabelConfig{{
+			TargetLabel: "__name__",
+			Replacement: &name,
+		}},
+	}
+	switch ae.Modifier.Op {
+	case "by":
+		cfg.By = append([]string{}, ae.Modifier.Args...)
+		if len(cfg.By) == 0 {
+			// Aggregate all the input series into a single output series.
+			cfg.By = []string{"__name__"}
+		}
+	case "without":
+		// Empty `without` list means that the outputs are calculated individually per each input series.
+		cfg.Without = append([]string{}, ae.Modifier.Args...)
+	default:
+		cfg.By = []string{"__name__"}
+	}
+
+	v := &view{
+		name:           vc.Name,
+		expr:           string(ae.AppendString(nil)),
+		window:         window,
+		modifier:       ae.Modifier,
+		rewriteQueries: vc.RewriteQueries,
+		aggrConfig:     cfg,
+	}
+	return v, nil
+}
+
+func isValidMetricName(s string) bool {
+	return metricNameRegexp.MatchString(s)
+}
+
+var metricNameRegexp = regexp.MustCompile("^[a-zA-Z_:][a-zA-Z0-9_:]*$")
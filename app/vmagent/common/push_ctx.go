@@ -11,7 +11,7 @@ import (
 type PushCtx struct {
 	// WriteRequest contains the WriteRequest, which must be pushed later to remote storage.
 	//
-	// The actual labels and samples for the time series are stored in Labels and Samples fields.
+	// The actual labels, samples and exemplars for the time series are stored in Labels, Samples and Exemplars fields.
 	WriteRequest prompbmarshal.WriteRequest
 
 	// Labels contains flat list of all the labels used in WriteRequest.
@@ -19,6 +19,9 @@ type PushCtx struct {
 
 	// Samples contains flat list of all the samples used in WriteRequest.
 	Samples []prompbmarshal.Sample
+
+	// Exemplars contains flat list of all the exemplars used in WriteRequest.
+	Exemplars []prompbmarshal.Exemplar
 }
 
 // Reset resets ctx.
@@ -29,6 +32,9 @@ func (ctx *PushCtx) Reset() {
 	ctx.Labels = ctx.Labels[:0]
 
 	ctx.Samples = ctx.Samples[:0]
+
+	clear(ctx.Exemplars)
+	ctx.Exemplars = ctx.Exemplars[:0]
 }
 
 // GetPushCtx returns PushCtx from pool.
@@ -251,6 +251,7 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 			{"service-discovery", "labels before and after relabeling for discovered targets"},
 			{"metric-relabel-debug", "debug metric relabeling"},
 			{"api/v1/targets", "advanced information about discovered targets in JSON format"},
+			{"api/v1/targets/health", "availability and scrape duration stats for scrape jobs in JSON format"},
 			{"config", "-promscrape.config contents"},
 			{"metrics", "available service metrics"},
 			{"flags", "command-line flags"},
@@ -288,7 +289,7 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		prometheusWriteRequests.Inc()
-		if err := promremotewrite.InsertHandler(nil, r); err != nil {
+		if err := promremotewrite.InsertHandler(nil, w, r); err != nil {
 			prometheusWriteErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
@@ -450,6 +451,12 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 		scrapePool := r.FormValue("scrapePool")
 		promscrape.WriteAPIV1Targets(w, state, scrapePool)
 		return true
+	case "/prometheus/api/v1/targets/health", "/api/v1/targets/health":
+		promscrapeAPIV1TargetsHealthRequests.Inc()
+		w.Header().Set("Content-Type", "application/json")
+		scrapePool := r.FormValue("scrapePool")
+		promscrape.WriteAPIV1TargetsHealth(w, scrapePool)
+		return true
 	case "/prometheus/target_response", "/target_response":
 		promscrapeTargetResponseRequests.Inc()
 		if err := promscrape.WriteTargetResponse(w, r); err != nil {
@@ -546,7 +553,7 @@ func processMultitenantRequest(w http.ResponseWriter, r *http.Request, path stri
 	switch p.Suffix {
 	case "prometheus/", "prometheus", "prometheus/api/v1/write", "prometheus/api/v1/push":
 		prometheusWriteRequests.Inc()
-		if err := promremotewrite.InsertHandler(at, r); err != nil {
+		if err := promremotewrite.InsertHandler(at, w, r); err != nil {
 			prometheusWriteErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
@@ -739,7 +746,8 @@ var (
 	promscrapeMetricRelabelDebugRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/metric-relabel-debug"}`)
 	promscrapeTargetRelabelDebugRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/target-relabel-debug"}`)
 
-	promscrapeAPIV1TargetsRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/api/v1/targets"}`)
+	promscrapeAPIV1TargetsRequests       = metrics.NewCounter(`vmagent_http_requests_total{path="/api/v1/targets"}`)
+	promscrapeAPIV1TargetsHealthRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/api/v1/targets/health"}`)
 
 	promscrapeTargetResponseRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/target_response"}`)
 	promscrapeTargetResponseErrors   = metrics.NewCounter(`vmagent_http_request_errors_total{path="/target_response"}`)
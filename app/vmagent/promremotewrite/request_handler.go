@@ -6,6 +6,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/remotewrite"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/promremotewrite/stream"
@@ -21,18 +22,36 @@ var (
 )
 
 // InsertHandler processes remote write for prometheus.
-func InsertHandler(at *auth.Token, req *http.Request) error {
+//
+// Both Prometheus remote write 1.0 and 2.0 protocols are supported. The protocol is detected via Content-Type request header.
+func InsertHandler(at *auth.Token, w http.ResponseWriter, req *http.Request) error {
 	extraLabels, err := protoparserutil.GetExtraLabels(req)
 	if err != nil {
 		return err
 	}
 	isVMRemoteWrite := req.Header.Get("Content-Encoding") == "zstd"
-	return stream.Parse(req.Body, isVMRemoteWrite, func(tss []prompb.TimeSeries) error {
-		return insertRows(at, tss, extraLabels)
+	isPromRemoteWriteV2, err := stream.IsPromRemoteWriteV2(req.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	var written prompb.WriteRequestStats
+	err = stream.Parse(req.Body, isVMRemoteWrite, isPromRemoteWriteV2, func(tss []prompb.TimeSeries, mms []prompb.MetricMetadata, ws *prompb.WriteRequestStats) error {
+		if err := insertRows(at, tss, mms, extraLabels); err != nil {
+			return err
+		}
+		written = *ws
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+	if isPromRemoteWriteV2 {
+		stream.SetWrittenHeaders(w.Header(), &written)
+	}
+	return nil
 }
 
-func insertRows(at *auth.Token, timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Label) error {
+func insertRows(at *auth.Token, timeseries []prompb.TimeSeries, mms []prompb.MetricMetadata, extraLabels []prompbmarshal.Label) error {
 	ctx := common.GetPushCtx()
 	defer common.PutPushCtx(ctx)
 
@@ -40,6 +59,7 @@ func insertRows(at *auth.Token, timeseries []prompb.TimeSeries, extraLabels []pr
 	tssDst := ctx.WriteRequest.Timeseries[:0]
 	labels := ctx.Labels[:0]
 	samples := ctx.Samples[:0]
+	exemplars := ctx.Exemplars[:0]
 	for i := range timeseries {
 		ts := &timeseries[i]
 		rowsTotal += len(ts.Samples)
@@ -60,14 +80,46 @@ func insertRows(at *auth.Token, timeseries []prompb.TimeSeries, extraLabels []pr
 				Timestamp: sample.Timestamp,
 			})
 		}
+		exemplarsLen := len(exemplars)
+		for i := range ts.Exemplars {
+			e := &ts.Exemplars[i]
+			exemplarLabelsLen := len(labels)
+			for j := range e.Labels {
+				label := &e.Labels[j]
+				labels = append(labels, prompbmarshal.Label{
+					Name:  label.Name,
+					Value: label.Value,
+				})
+			}
+			exemplars = append(exemplars, prompbmarshal.Exemplar{
+				Labels:    labels[exemplarLabelsLen:],
+				Value:     e.Value,
+				Timestamp: e.Timestamp,
+			})
+		}
 		tssDst = append(tssDst, prompbmarshal.TimeSeries{
-			Labels:  labels[labelsLen:],
-			Samples: samples[samplesLen:],
+			Labels:    labels[labelsLen : labelsLen+len(ts.Labels)+len(extraLabels)],
+			Samples:   samples[samplesLen:],
+			Exemplars: exemplars[exemplarsLen:],
 		})
 	}
+	mmsDst := ctx.WriteRequest.Metadata[:0]
+	if prommetadata.IsEnabled() {
+		for i := range mms {
+			mm := &mms[i]
+			mmsDst = append(mmsDst, prompbmarshal.MetricMetadata{
+				Type:             uint32(mm.Type),
+				MetricFamilyName: mm.MetricFamilyName,
+				Help:             mm.Help,
+				Unit:             mm.Unit,
+			})
+		}
+	}
 	ctx.WriteRequest.Timeseries = tssDst
+	ctx.WriteRequest.Metadata = mmsDst
 	ctx.Labels = labels
 	ctx.Samples = samples
+	ctx.Exemplars = exemplars
 	if !remotewrite.TryPush(at, &ctx.WriteRequest) {
 		return remotewrite.ErrQueueFullHTTPRetry
 	}
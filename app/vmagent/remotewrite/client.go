@@ -21,6 +21,8 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/persistentqueue"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/ratelimiter"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timerpool"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
@@ -33,6 +35,9 @@ var (
 		"to the corresponding -remoteWrite.url . See https://docs.victoriametrics.com/victoriametrics/vmagent/#victoriametrics-remote-write-protocol")
 	forceVMProto = flagutil.NewArrayBool("remoteWrite.forceVMProto", "Whether to force VictoriaMetrics remote write protocol for sending data "+
 		"to the corresponding -remoteWrite.url . See https://docs.victoriametrics.com/victoriametrics/vmagent/#victoriametrics-remote-write-protocol")
+	usePromProtoV2 = flagutil.NewArrayBool("remoteWrite.usePromProtoV2", "Whether to use Prometheus remote write 2.0 protocol for sending data "+
+		"to the corresponding -remoteWrite.url . vmagent falls back to Prometheus remote write 1.0 protocol if the remote storage responds with "+
+		"415 Unsupported Media Type status code. See https://docs.victoriametrics.com/victoriametrics/vmagent/#prometheus-remote-write-20")
 
 	rateLimit = flagutil.NewArrayInt("remoteWrite.rateLimit", 0, "Optional rate limit in bytes per second for data sent to the corresponding -remoteWrite.url. "+
 		"By default, the rate limit is disabled. It can be useful for limiting load on remote storage when big amounts of buffered data "+
@@ -93,6 +98,9 @@ type client struct {
 	useVMProto          atomic.Bool
 	canDowngradeVMProto atomic.Bool
 
+	// Whether to use Prometheus remote write 2.0 protocol for sending the data to remoteWriteURL
+	usePromProtoV2 atomic.Bool
+
 	fq *persistentqueue.FastQueue
 	hc *http.Client
 
@@ -169,6 +177,15 @@ func newHTTPClient(argIdx int, remoteWriteURL, sanitizedURL string, fq *persiste
 	if useVMProto && usePromProto {
 		logger.Fatalf("-remoteWrite.useVMProto and -remoteWrite.usePromProto cannot be set simultaneously for -remoteWrite.url=%s", sanitizedURL)
 	}
+	if usePromProtoV2.GetOptionalArg(argIdx) {
+		if useVMProto {
+			logger.Fatalf("-remoteWrite.forceVMProto and -remoteWrite.usePromProtoV2 cannot be set simultaneously for -remoteWrite.url=%s", sanitizedURL)
+		}
+		// Prometheus remote write 2.0 is used only by Prometheus-compatible remote storage systems, so do not try the VM protocol.
+		// The Prometheus remote write 2.0 protocol could be downgraded later at runtime if unsupported media type response status is received.
+		usePromProto = true
+		c.usePromProtoV2.Store(true)
+	}
 	if !useVMProto && !usePromProto {
 		// The VM protocol could be downgraded later at runtime if unsupported media type response status is received.
 		useVMProto = true
@@ -342,8 +359,8 @@ func (c *client) runWorker() {
 	}
 }
 
-func (c *client) doRequest(url string, body []byte) (*http.Response, error) {
-	req, err := c.newRequest(url, body)
+func (c *client) doRequest(url string, body []byte, isPromProtoV2 bool) (*http.Response, error) {
+	req, err := c.newRequest(url, body, isPromProtoV2)
 	if err != nil {
 		return nil, err
 	}
@@ -358,7 +375,7 @@ func (c *client) doRequest(url string, body []byte) (*http.Response, error) {
 	// Make another attempt in hope request will succeed.
 	// If not, the error should be handled by the caller as usual.
 	// This should help with https://github.com/VictoriaMetrics/VictoriaMetrics/issues/4139
-	req, err = c.newRequest(url, body)
+	req, err = c.newRequest(url, body, isPromProtoV2)
 	if err != nil {
 		return nil, fmt.Errorf("second attempt: %w", err)
 	}
@@ -369,7 +386,7 @@ func (c *client) doRequest(url string, body []byte) (*http.Response, error) {
 	return resp, nil
 }
 
-func (c *client) newRequest(url string, body []byte) (*http.Request, error) {
+func (c *client) newRequest(url string, body []byte, isPromProtoV2 bool) (*http.Request, error) {
 	reqBody := bytes.NewBuffer(body)
 	req, err := http.NewRequest(http.MethodPost, url, reqBody)
 	if err != nil {
@@ -381,11 +398,16 @@ func (c *client) newRequest(url string, body []byte) (*http.Request, error) {
 	}
 	h := req.Header
 	h.Set("User-Agent", "vmagent")
-	h.Set("Content-Type", "application/x-protobuf")
-	if encoding.IsZstd(body) {
+	if isPromProtoV2 {
+		h.Set("Content-Type", prompb.ContentTypeV2)
+		h.Set("Content-Encoding", "snappy")
+		h.Set("X-Prometheus-Remote-Write-Version", "2.0.0")
+	} else if encoding.IsZstd(body) {
+		h.Set("Content-Type", "application/x-protobuf")
 		h.Set("Content-Encoding", "zstd")
 		h.Set("X-VictoriaMetrics-Remote-Write-Version", "1")
 	} else {
+		h.Set("Content-Type", "application/x-protobuf")
 		h.Set("Content-Encoding", "snappy")
 		h.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
 	}
@@ -408,9 +430,21 @@ func (c *client) sendBlockHTTP(block []byte) bool {
 	retryDuration := timeutil.AddJitterToDuration(c.retryMinInterval)
 	retriesCount := 0
 
+	// Blocks are stored in Prometheus remote write 1.0 format in the queue,
+	// so they are re-packed to Prometheus remote write 2.0 format just before sending.
+	var blockV2 []byte
+
 again:
+	reqBody := block
+	isPromProtoV2 := c.usePromProtoV2.Load() && !encoding.IsZstd(block)
+	if isPromProtoV2 {
+		if blockV2 == nil {
+			blockV2 = mustRepackBlockToPromProtoV2(block)
+		}
+		reqBody = blockV2
+	}
 	startTime := time.Now()
-	resp, err := c.doRequest(c.remoteWriteURL, block)
+	resp, err := c.doRequest(c.remoteWriteURL, reqBody, isPromProtoV2)
 	c.requestDuration.UpdateDuration(startTime)
 	if err != nil {
 		c.errorsCount.Inc()
@@ -455,6 +489,14 @@ again:
 		// - Remote Write v2 specification explicitly specifies a `415 Unsupported Media Type` for unsupported encodings.
 		// - Real-world implementations of v1 use both 400 and 415 status codes.
 		// See more in research: https://github.com/VictoriaMetrics/VictoriaMetrics/pull/8462#issuecomment-2786918054
+	} else if statusCode == 415 && isPromProtoV2 {
+		if c.usePromProtoV2.Swap(false) {
+			logger.Infof("received unsupported media type from remote storage at %q. Downgrading protocol from Prometheus remote write 2.0 to Prometheus remote write 1.0 for all future requests. "+
+				"See https://docs.victoriametrics.com/victoriametrics/vmagent/#prometheus-remote-write-20", c.sanitizedURL)
+		}
+		c.retriesCount.Inc()
+		_ = resp.Body.Close()
+		goto again
 	} else if statusCode == 415 || statusCode == 400 {
 		if c.canDowngradeVMProto.Swap(false) {
 			logger.Infof("received unsupported media type or bad request from remote storage at %q. Downgrading protocol from VictoriaMetrics to Prometheus remote write for all future requests. "+
@@ -542,6 +584,67 @@ func mustRepackBlockFromZstdToSnappy(zstdBlock []byte) []byte {
 	return snappy.Encode(nil, plainBlock)
 }
 
+func mustRepackBlockToPromProtoV2(snappyBlock []byte) []byte {
+	plainBlock, err := snappy.Decode(nil, snappyBlock)
+	if err != nil {
+		logger.Panicf("FATAL: cannot re-pack block with size %d bytes to Prometheus remote write 2.0: %s", len(snappyBlock), err)
+	}
+	var wr prompb.WriteRequest
+	if err := wr.UnmarshalProtobuf(plainBlock); err != nil {
+		logger.Panicf("FATAL: cannot re-pack block with size %d bytes to Prometheus remote write 2.0: %s", len(snappyBlock), err)
+	}
+
+	var wrm prompbmarshal.WriteRequest
+	wrm.Timeseries = make([]prompbmarshal.TimeSeries, len(wr.Timeseries))
+	for i := range wr.Timeseries {
+		ts := &wr.Timeseries[i]
+		tsm := &wrm.Timeseries[i]
+		tsm.Labels = make([]prompbmarshal.Label, len(ts.Labels))
+		for j, label := range ts.Labels {
+			tsm.Labels[j] = prompbmarshal.Label{
+				Name:  label.Name,
+				Value: label.Value,
+			}
+		}
+		tsm.Samples = make([]prompbmarshal.Sample, len(ts.Samples))
+		for j, sample := range ts.Samples {
+			tsm.Samples[j] = prompbmarshal.Sample{
+				Value:     sample.Value,
+				Timestamp: sample.Timestamp,
+			}
+		}
+		tsm.Exemplars = make([]prompbmarshal.Exemplar, len(ts.Exemplars))
+		for j := range ts.Exemplars {
+			e := &ts.Exemplars[j]
+			labels := make([]prompbmarshal.Label, len(e.Labels))
+			for k, label := range e.Labels {
+				labels[k] = prompbmarshal.Label{
+					Name:  label.Name,
+					Value: label.Value,
+				}
+			}
+			tsm.Exemplars[j] = prompbmarshal.Exemplar{
+				Labels:    labels,
+				Value:     e.Value,
+				Timestamp: e.Timestamp,
+			}
+		}
+	}
+	wrm.Metadata = make([]prompbmarshal.MetricMetadata, len(wr.Metadata))
+	for i := range wr.Metadata {
+		mm := &wr.Metadata[i]
+		wrm.Metadata[i] = prompbmarshal.MetricMetadata{
+			Type:             uint32(mm.Type),
+			MetricFamilyName: mm.MetricFamilyName,
+			Help:             mm.Help,
+			Unit:             mm.Unit,
+		}
+	}
+	plainBlock = wrm.MarshalProtobufV2(plainBlock[:0])
+
+	return snappy.Encode(nil, plainBlock)
+}
+
 func logBlockRejected(block []byte, sanitizedURL string, resp *http.Response) {
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/golang/snappy"
 )
 
@@ -116,3 +118,83 @@ func TestRepackBlockFromZstdToSnappy(t *testing.T) {
 		t.Fatalf("unexpected plain block; got %q; want %q", actualPlainBlock, expectedPlainBlock)
 	}
 }
+
+func TestRepackBlockToPromProtoV2(t *testing.T) {
+	wrm := &prompbmarshal.WriteRequest{
+		Timeseries: []prompbmarshal.TimeSeries{
+			{
+				Labels: []prompbmarshal.Label{
+					{
+						Name:  "__name__",
+						Value: "foo",
+					},
+					{
+						Name:  "job",
+						Value: "bar",
+					},
+				},
+				Samples: []prompbmarshal.Sample{
+					{
+						Value:     1,
+						Timestamp: 1000,
+					},
+				},
+				Exemplars: []prompbmarshal.Exemplar{
+					{
+						Labels: []prompbmarshal.Label{
+							{
+								Name:  "trace_id",
+								Value: "abc",
+							},
+						},
+						Value:     0.5,
+						Timestamp: 900,
+					},
+				},
+			},
+		},
+		Metadata: []prompbmarshal.MetricMetadata{
+			{
+				Type:             uint32(prompb.MetricTypeGauge),
+				MetricFamilyName: "foo",
+				Help:             "foo help",
+				Unit:             "seconds",
+			},
+		},
+	}
+	snappyBlock := snappy.Encode(nil, wrm.MarshalProtobuf(nil))
+	snappyBlockV2 := mustRepackBlockToPromProtoV2(snappyBlock)
+
+	plainBlockV2, err := snappy.Decode(nil, snappyBlockV2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var wr prompb.WriteRequest
+	if err := wr.UnmarshalProtobufV2(plainBlockV2); err != nil {
+		t.Fatalf("cannot unmarshal Prometheus remote write 2.0 request: %s", err)
+	}
+	if len(wr.Timeseries) != 1 {
+		t.Fatalf("unexpected number of time series; got %d; want 1", len(wr.Timeseries))
+	}
+	ts := wr.Timeseries[0]
+	if len(ts.Labels) != 2 || ts.Labels[0].Name != "__name__" || ts.Labels[0].Value != "foo" || ts.Labels[1].Name != "job" || ts.Labels[1].Value != "bar" {
+		t.Fatalf("unexpected labels: %v", ts.Labels)
+	}
+	if len(ts.Samples) != 1 || ts.Samples[0].Value != 1 || ts.Samples[0].Timestamp != 1000 {
+		t.Fatalf("unexpected samples: %v", ts.Samples)
+	}
+	if len(ts.Exemplars) != 1 {
+		t.Fatalf("unexpected number of exemplars; got %d; want 1", len(ts.Exemplars))
+	}
+	e := ts.Exemplars[0]
+	if len(e.Labels) != 1 || e.Labels[0].Name != "trace_id" || e.Labels[0].Value != "abc" || e.Value != 0.5 || e.Timestamp != 900 {
+		t.Fatalf("unexpected exemplar: %v", e)
+	}
+	if len(wr.Metadata) != 1 {
+		t.Fatalf("unexpected number of metadata entries; got %d; want 1", len(wr.Metadata))
+	}
+	mm := wr.Metadata[0]
+	if mm.Type != prompb.MetricTypeGauge || mm.MetricFamilyName != "foo" || mm.Help != "foo help" || mm.Unit != "seconds" {
+		t.Fatalf("unexpected metadata: %v", mm)
+	}
+}
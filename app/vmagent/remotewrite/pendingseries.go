@@ -67,6 +67,13 @@ func (ps *pendingSeries) TryPush(tss []prompbmarshal.TimeSeries) bool {
 	return ok
 }
 
+func (ps *pendingSeries) TryPushMetadata(mms []prompbmarshal.MetricMetadata) bool {
+	ps.mu.Lock()
+	ok := ps.wr.tryPushMetadata(mms)
+	ps.mu.Unlock()
+	return ok
+}
+
 func (ps *pendingSeries) periodicFlusher() {
 	flushSeconds := int64(flushInterval.Seconds())
 	if flushSeconds <= 0 {
@@ -110,11 +117,13 @@ type writeRequest struct {
 
 	wr prompbmarshal.WriteRequest
 
-	tss     []prompbmarshal.TimeSeries
-	labels  []prompbmarshal.Label
-	samples []prompbmarshal.Sample
+	tss       []prompbmarshal.TimeSeries
+	labels    []prompbmarshal.Label
+	samples   []prompbmarshal.Sample
+	exemplars []prompbmarshal.Exemplar
+	mms       []prompbmarshal.MetricMetadata
 
-	// buf holds labels data
+	// buf holds labels and metadata data
 	buf []byte
 }
 
@@ -122,6 +131,7 @@ func (wr *writeRequest) reset() {
 	// Do not reset lastFlushTime, fq, isVMRemoteWrite, significantFigures and roundDigits, since they are reused.
 
 	wr.wr.Timeseries = nil
+	wr.wr.Metadata = nil
 
 	clear(wr.tss)
 	wr.tss = wr.tss[:0]
@@ -130,6 +140,13 @@ func (wr *writeRequest) reset() {
 	wr.labels = wr.labels[:0]
 
 	wr.samples = wr.samples[:0]
+
+	clear(wr.exemplars)
+	wr.exemplars = wr.exemplars[:0]
+
+	clear(wr.mms)
+	wr.mms = wr.mms[:0]
+
 	wr.buf = wr.buf[:0]
 }
 
@@ -138,6 +155,7 @@ func (wr *writeRequest) reset() {
 // This is needed in order to properly save in-memory data to persistent queue on graceful shutdown.
 func (wr *writeRequest) mustFlushOnStop() {
 	wr.wr.Timeseries = wr.tss
+	wr.wr.Metadata = wr.mms
 	if !tryPushWriteRequest(&wr.wr, wr.mustWriteBlock, wr.isVMRemoteWrite.Load()) {
 		logger.Panicf("BUG: final flush must always return true")
 	}
@@ -151,6 +169,7 @@ func (wr *writeRequest) mustWriteBlock(block []byte) bool {
 
 func (wr *writeRequest) tryFlush() bool {
 	wr.wr.Timeseries = wr.tss
+	wr.wr.Metadata = wr.mms
 	wr.lastFlushTime.Store(fasttime.UnixTimestamp())
 	if !tryPushWriteRequest(&wr.wr, wr.fq.TryWriteBlock, wr.isVMRemoteWrite.Load()) {
 		return false
@@ -197,9 +216,43 @@ func (wr *writeRequest) tryPush(src []prompbmarshal.TimeSeries) bool {
 	return true
 }
 
+func (wr *writeRequest) tryPushMetadata(src []prompbmarshal.MetricMetadata) bool {
+	maxMetadataPerBlock := *maxRowsPerBlock
+	for i := range src {
+		if len(wr.mms) >= maxMetadataPerBlock {
+			if !wr.tryFlush() {
+				return false
+			}
+		}
+		wr.mms = append(wr.mms, prompbmarshal.MetricMetadata{})
+		wr.copyMetadata(&wr.mms[len(wr.mms)-1], &src[i])
+	}
+	return true
+}
+
 func (wr *writeRequest) copyTimeSeries(dst, src *prompbmarshal.TimeSeries) {
-	labelsSrc := src.Labels
+	dst.Labels = wr.copyLabels(src.Labels)
 
+	// Copy samples
+	samplesLen := len(wr.samples)
+	wr.samples = append(wr.samples, src.Samples...)
+	dst.Samples = wr.samples[samplesLen:]
+
+	// Copy exemplars
+	exemplarsLen := len(wr.exemplars)
+	for i := range src.Exemplars {
+		e := &src.Exemplars[i]
+		wr.exemplars = append(wr.exemplars, prompbmarshal.Exemplar{
+			Labels:    wr.copyLabels(e.Labels),
+			Value:     e.Value,
+			Timestamp: e.Timestamp,
+		})
+	}
+	dst.Exemplars = wr.exemplars[exemplarsLen:]
+}
+
+// copyLabels copies labelsSrc to wr.labels and wr.buf and returns the copied labels.
+func (wr *writeRequest) copyLabels(labelsSrc []prompbmarshal.Label) []prompbmarshal.Label {
 	// Pre-allocate memory for labels.
 	labelsLen := len(wr.labels)
 	wr.labels = slicesutil.SetLength(wr.labels, labelsLen+len(labelsSrc))
@@ -229,19 +282,30 @@ func (wr *writeRequest) copyTimeSeries(dst, src *prompbmarshal.TimeSeries) {
 		dstLabel.Value = bytesutil.ToUnsafeString(buf[bufLen:])
 	}
 	wr.buf = buf
-	dst.Labels = labelsDst
+	return labelsDst
+}
 
-	// Copy samples
-	samplesLen := len(wr.samples)
-	wr.samples = append(wr.samples, src.Samples...)
-	dst.Samples = wr.samples[samplesLen:]
+func (wr *writeRequest) copyMetadata(dst, src *prompbmarshal.MetricMetadata) {
+	dst.Type = src.Type
+
+	bufLen := len(wr.buf)
+	wr.buf = append(wr.buf, src.MetricFamilyName...)
+	dst.MetricFamilyName = bytesutil.ToUnsafeString(wr.buf[bufLen:])
+
+	bufLen = len(wr.buf)
+	wr.buf = append(wr.buf, src.Help...)
+	dst.Help = bytesutil.ToUnsafeString(wr.buf[bufLen:])
+
+	bufLen = len(wr.buf)
+	wr.buf = append(wr.buf, src.Unit...)
+	dst.Unit = bytesutil.ToUnsafeString(wr.buf[bufLen:])
 }
 
 // marshalConcurrency limits the maximum number of concurrent workers, which marshal and compress WriteRequest.
 var marshalConcurrencyCh = make(chan struct{}, cgroup.AvailableCPUs())
 
 func tryPushWriteRequest(wr *prompbmarshal.WriteRequest, tryPushBlock func(block []byte) bool, isVMRemoteWrite bool) bool {
-	if len(wr.Timeseries) == 0 {
+	if len(wr.Timeseries) == 0 && len(wr.Metadata) == 0 {
 		// Nothing to push
 		return true
 	}
@@ -279,6 +343,9 @@ func tryPushWriteRequest(wr *prompbmarshal.WriteRequest, tryPushBlock func(block
 	}
 
 	// Too big block. Recursively split it into smaller parts if possible.
+	if len(wr.Metadata) > 0 {
+		return tryPushWriteRequestMetadataSeparately(wr, tryPushBlock, isVMRemoteWrite)
+	}
 	if len(wr.Timeseries) == 1 {
 		// A single time series left. Recursively split its samples into smaller parts if possible.
 		samples := wr.Timeseries[0].Samples
@@ -316,6 +383,39 @@ func tryPushWriteRequest(wr *prompbmarshal.WriteRequest, tryPushBlock func(block
 	return true
 }
 
+// tryPushWriteRequestMetadataSeparately pushes wr.Metadata and wr.Timeseries in distinct blocks.
+//
+// Metadata is split into smaller blocks if it doesn't fit a single block.
+func tryPushWriteRequestMetadataSeparately(wr *prompbmarshal.WriteRequest, tryPushBlock func(block []byte) bool, isVMRemoteWrite bool) bool {
+	timeseries := wr.Timeseries
+	mms := wr.Metadata
+	defer func() {
+		wr.Timeseries = timeseries
+		wr.Metadata = mms
+	}()
+
+	wr.Timeseries = nil
+	if len(timeseries) == 0 {
+		if len(mms) == 1 {
+			logger.Warnf("dropping metadata for metric family with too long help exceeding -remoteWrite.maxBlockSize=%d bytes", maxUnpackedBlockSize.N)
+			return true
+		}
+		n := len(mms) / 2
+		wr.Metadata = mms[:n]
+		if !tryPushWriteRequest(wr, tryPushBlock, isVMRemoteWrite) {
+			return false
+		}
+		wr.Metadata = mms[n:]
+		return tryPushWriteRequest(wr, tryPushBlock, isVMRemoteWrite)
+	}
+	if !tryPushWriteRequest(wr, tryPushBlock, isVMRemoteWrite) {
+		return false
+	}
+	wr.Timeseries = timeseries
+	wr.Metadata = nil
+	return tryPushWriteRequest(wr, tryPushBlock, isVMRemoteWrite)
+}
+
 var (
 	blockSizeBytes = metrics.NewHistogram(`vmagent_remotewrite_block_size_bytes`)
 	blockSizeRows  = metrics.NewHistogram(`vmagent_remotewrite_block_size_rows`)
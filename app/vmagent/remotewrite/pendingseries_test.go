@@ -5,7 +5,9 @@ import (
 	"math"
 	"testing"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/golang/snappy"
 )
 
 func TestPushWriteRequest(t *testing.T) {
@@ -49,6 +51,103 @@ func testPushWriteRequest(t *testing.T, rowsCount, expectedBlockLenProm, expecte
 	f(true, expectedBlockLenVM, 15)
 }
 
+func TestWriteRequestCopiesExemplarsAndMetadata(t *testing.T) {
+	src := []prompbmarshal.TimeSeries{
+		{
+			Labels: []prompbmarshal.Label{
+				{
+					Name:  "__name__",
+					Value: "foo",
+				},
+			},
+			Samples: []prompbmarshal.Sample{
+				{
+					Value:     1,
+					Timestamp: 1000,
+				},
+			},
+			Exemplars: []prompbmarshal.Exemplar{
+				{
+					Labels: []prompbmarshal.Label{
+						{
+							Name:  "trace_id",
+							Value: "abc",
+						},
+					},
+					Value:     0.5,
+					Timestamp: 900,
+				},
+			},
+		},
+	}
+	mms := []prompbmarshal.MetricMetadata{
+		{
+			Type:             uint32(prompb.MetricTypeCounter),
+			MetricFamilyName: "foo",
+			Help:             "foo help",
+		},
+	}
+
+	var wr writeRequest
+	if !wr.tryPush(src) {
+		t.Fatalf("cannot push time series")
+	}
+	if !wr.tryPushMetadata(mms) {
+		t.Fatalf("cannot push metadata")
+	}
+
+	// Modify the source data in order to make sure it isn't referenced by wr.
+	src[0].Exemplars[0].Labels[0].Value = "modified"
+	mms[0].Help = "modified"
+
+	wr.wr.Timeseries = wr.tss
+	wr.wr.Metadata = wr.mms
+	var block []byte
+	pushBlock := func(b []byte) bool {
+		block = append(block[:0], b...)
+		return true
+	}
+	if !tryPushWriteRequest(&wr.wr, pushBlock, false) {
+		t.Fatalf("cannot push data to remote storage")
+	}
+	data, err := snappy.Decode(nil, block)
+	if err != nil {
+		t.Fatalf("cannot decode block: %s", err)
+	}
+	var pwr prompb.WriteRequest
+	if err := pwr.UnmarshalProtobuf(data); err != nil {
+		t.Fatalf("cannot unmarshal block: %s", err)
+	}
+	if len(pwr.Timeseries) != 1 || len(pwr.Timeseries[0].Exemplars) != 1 {
+		t.Fatalf("unexpected time series: %v", pwr.Timeseries)
+	}
+	e := pwr.Timeseries[0].Exemplars[0]
+	if len(e.Labels) != 1 || e.Labels[0].Name != "trace_id" || e.Labels[0].Value != "abc" || e.Value != 0.5 || e.Timestamp != 900 {
+		t.Fatalf("unexpected exemplar: %v", e)
+	}
+	if len(pwr.Metadata) != 1 {
+		t.Fatalf("unexpected number of metadata entries; got %d; want 1", len(pwr.Metadata))
+	}
+	mm := pwr.Metadata[0]
+	if mm.Type != prompb.MetricTypeCounter || mm.MetricFamilyName != "foo" || mm.Help != "foo help" || mm.Unit != "" {
+		t.Fatalf("unexpected metadata: %v", mm)
+	}
+
+	// Metadata-only requests must be pushed too.
+	wr.reset()
+	if !wr.tryPushMetadata(mms) {
+		t.Fatalf("cannot push metadata")
+	}
+	wr.wr.Metadata = wr.mms
+	block = block[:0]
+	if !tryPushWriteRequest(&wr.wr, pushBlock, false) {
+		t.Fatalf("cannot push data to remote storage")
+	}
+	if len(block) == 0 {
+		t.Fatalf("metadata-only request wasn't pushed")
+	}
+}
+
 func newTestWriteRequest(seriesCount, labelsCount int) *prompbmarshal.WriteRequest {
 	var wr prompbmarshal.WriteRequest
 	for i := 0; i < seriesCount; i++ {
@@ -408,6 +408,20 @@ func tryPush(at *auth.Token, wr *prompbmarshal.WriteRequest, forceDropSamplesOnF
 		return true
 	}
 
+	if len(wr.Metadata) > 0 {
+		// Metadata is sent to every remote storage system, including -remoteWrite.shardByURL mode,
+		// since samples for the same metric family may be sent to distinct remote storage systems.
+		for _, rwctx := range rwctxs {
+			if rwctx.tryPushMetadata(wr.Metadata) {
+				continue
+			}
+			rwctx.pushFailures.Inc()
+			if !forceDropSamplesOnFailure {
+				return false
+			}
+		}
+	}
+
 	var rctx *relabelCtx
 	rcs := allRelabelConfigs.Load()
 	pcsGlobal := rcs.global
@@ -1020,6 +1034,13 @@ func (rwctx *remoteWriteCtx) tryPushInternal(tss []prompbmarshal.TimeSeries) boo
 	return pss[idx].TryPush(tss)
 }
 
+func (rwctx *remoteWriteCtx) tryPushMetadata(mms []prompbmarshal.MetricMetadata) bool {
+	pss := rwctx.pss
+	idx := rwctx.pssNextIdx.Add(1) % uint64(len(pss))
+
+	return pss[idx].TryPushMetadata(mms)
+}
+
 var tssPool = &sync.Pool{
 	New: func() any {
 		a := []prompbmarshal.TimeSeries{}
@@ -74,7 +74,7 @@ func UnitTest(files []string, disableGroupLabel bool, externalLabels []string, e
 				httpserver.Errorf(w, r, "%s", err)
 			}
 		case "/prometheus/api/v1/write", "/api/v1/write":
-			if err := promremotewrite.InsertHandler(r); err != nil {
+			if err := promremotewrite.InsertHandler(w, r); err != nil {
 				httpserver.Errorf(w, r, "%s", err)
 			}
 		default:
@@ -274,7 +274,7 @@ func processFlags() {
 }
 
 func setUp() {
-	vmstorage.Init(promql.ResetRollupResultCacheIfNeeded)
+	vmstorage.Init(promql.ResetRollupResultCacheIfNeeded, promql.ResetRollupResultCache)
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	readyCheckFunc := func() bool {
@@ -110,7 +110,7 @@ func TestPrometheusProcessorRun(t *testing.T) {
 	}
 
 	processFlags()
-	vmstorage.Init(promql.ResetRollupResultCacheIfNeeded)
+	vmstorage.Init(promql.ResetRollupResultCacheIfNeeded, promql.ResetRollupResultCache)
 	defer func() {
 		vmstorage.Stop()
 		if err := os.RemoveAll(storagePath); err != nil {
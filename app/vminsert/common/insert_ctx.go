@@ -10,6 +10,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/ratelimiter"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/slicesutil"
@@ -53,6 +54,13 @@ type InsertCtx struct {
 	mrs            []storage.MetricRow
 	metricNamesBuf []byte
 
+	mms []storage.MetricMetadata
+
+	ers              []storage.ExemplarRow
+	exemplarLabels   []storage.ExemplarLabel
+	exemplarLabelsN  int
+	exemplarNamesBuf []byte
+
 	relabelCtx    relabel.Ctx
 	streamAggrCtx streamAggrCtx
 
@@ -75,6 +83,17 @@ func (ctx *InsertCtx) Reset(rowsLen int) {
 	ctx.mrs = mrs[:0]
 
 	ctx.metricNamesBuf = ctx.metricNamesBuf[:0]
+
+	clear(ctx.mms)
+	ctx.mms = ctx.mms[:0]
+
+	clear(ctx.ers)
+	ctx.ers = ctx.ers[:0]
+	clear(ctx.exemplarLabels)
+	ctx.exemplarLabels = ctx.exemplarLabels[:0]
+	ctx.exemplarLabelsN = 0
+	ctx.exemplarNamesBuf = ctx.exemplarNamesBuf[:0]
+
 	ctx.relabelCtx.Reset()
 	ctx.streamAggrCtx.Reset()
 	ctx.skipStreamAggr = false
@@ -151,6 +170,64 @@ func (ctx *InsertCtx) addRow(metricNameRaw []byte, timestamp int64, value float6
 	return nil
 }
 
+// WriteMetadata writes metric metadata into ctx buffer.
+//
+// The metadata is ignored if -enableMetadata command-line flag isn't set.
+//
+// metricFamilyName, help and unit are copied by the metadata store, so they may be changed after FlushBufs call.
+func (ctx *InsertCtx) WriteMetadata(metricType uint32, metricFamilyName, help, unit string) {
+	if !prommetadata.IsEnabled() || metricFamilyName == "" {
+		return
+	}
+	ctx.mms = append(ctx.mms, storage.MetricMetadata{
+		MetricFamilyName: metricFamilyName,
+		Type:             metricType,
+		Help:             help,
+		Unit:             unit,
+	})
+}
+
+// AddExemplarLabel adds (name, value) label for the exemplar written by the next WriteExemplar call.
+//
+// name and value must exist until FlushBufs call.
+func (ctx *InsertCtx) AddExemplarLabel(name, value string) {
+	if !vmstorage.IsExemplarsEnabled() {
+		return
+	}
+	ctx.exemplarLabels = append(ctx.exemplarLabels, storage.ExemplarLabel{
+		Name:  name,
+		Value: value,
+	})
+}
+
+// WriteExemplar writes an exemplar with (timestamp, value) and labels added via AddExemplarLabel
+// for the series with the given metricNameRaw into ctx buffer.
+//
+// metricNameRaw must be obtained from WriteDataPointExt for the series.
+// The exemplar is ignored if -storage.maxExemplarsPerSeries command-line flag isn't set.
+func (ctx *InsertCtx) WriteExemplar(metricNameRaw []byte, timestamp int64, value float64) {
+	if !vmstorage.IsExemplarsEnabled() {
+		return
+	}
+	labels := ctx.exemplarLabels[ctx.exemplarLabelsN:]
+	ctx.exemplarLabelsN = len(ctx.exemplarLabels)
+	if len(metricNameRaw) == 0 {
+		return
+	}
+
+	// Copy metricNameRaw, since ctx.metricNamesBuf may be reset on automatic flush inside addRow.
+	start := len(ctx.exemplarNamesBuf)
+	ctx.exemplarNamesBuf = append(ctx.exemplarNamesBuf, metricNameRaw...)
+	ctx.ers = append(ctx.ers, storage.ExemplarRow{
+		MetricNameRaw: ctx.exemplarNamesBuf[start:len(ctx.exemplarNamesBuf):len(ctx.exemplarNamesBuf)],
+		Exemplar: storage.Exemplar{
+			Labels:    labels[:len(labels):len(labels)],
+			Value:     value,
+			Timestamp: timestamp,
+		},
+	})
+}
+
 // AddLabelBytes adds (name, value) label to ctx.Labels.
 //
 // name and value must exist until ctx.Labels is used.
@@ -195,10 +272,11 @@ func (ctx *InsertCtx) ApplyRelabeling() {
 // FlushBufs flushes buffered rows to the underlying storage.
 func (ctx *InsertCtx) FlushBufs() error {
 	sas := sasGlobal.Load()
-	if (sas.IsEnabled() || deduplicator != nil) && !ctx.skipStreamAggr {
+	isStreamAggrEnabled := sas.IsEnabled() || deduplicator != nil
+	if (isStreamAggrEnabled || isMaterializedViewsEnabled()) && !ctx.skipStreamAggr {
 		matchIdxs := matchIdxsPool.Get()
 		matchIdxs.B = ctx.streamAggrCtx.push(ctx.mrs, matchIdxs.B)
-		if !*streamAggrKeepInput {
+		if isStreamAggrEnabled && !*streamAggrKeepInput {
 			// Remove aggregated rows from ctx.mrs
 			ctx.dropAggregatedRows(matchIdxs.B)
 		}
@@ -210,7 +288,17 @@ func (ctx *InsertCtx) FlushBufs() error {
 	// since the number of concurrent FlushBufs() calls should be already limited via writeconcurrencylimiter
 	// used at every stream.Parse() call under lib/protoparser/*
 
+	if len(ctx.mms) > 0 {
+		vmstorage.AddMetricsMetadata(ctx.mms)
+		metadataRowsInserted.Add(len(ctx.mms))
+	}
+
 	err := vmstorage.AddRows(ctx.mrs)
+	if len(ctx.ers) > 0 {
+		// Exemplars must be added after the rows, since they are stored only for already registered series.
+		vmstorage.AddExemplars(ctx.ers)
+		exemplarsInserted.Add(len(ctx.ers))
+	}
 	ctx.Reset(0)
 	if err == nil {
 		return nil
@@ -240,3 +328,8 @@ func (ctx *InsertCtx) dropAggregatedRows(matchIdxs []byte) {
 }
 
 var matchIdxsPool bytesutil.ByteBufferPool
+
+var (
+	metadataRowsInserted = metrics.NewCounter(`vm_metadata_rows_inserted_total`)
+	exemplarsInserted    = metrics.NewCounter(`vm_exemplars_inserted_total`)
+)
@@ -52,8 +52,26 @@ var (
 
 	sasGlobal    atomic.Pointer[streamaggr.Aggregators]
 	deduplicator *streamaggr.Deduplicator
+
+	matviewEnabled func() bool
+	matviewPush    func(tss []prompbmarshal.TimeSeries)
 )
 
+// SetMaterializedViewsHook sets the hook for calculating materialized views from the ingested samples.
+//
+// isEnabled must return true if materialized views are configured, while push is called with the ingested samples
+// before stream aggregation. The hook must be set before InitStreamAggr call.
+//
+// See https://docs.victoriametrics.com/victoriametrics/#materialized-views
+func SetMaterializedViewsHook(isEnabled func() bool, push func(tss []prompbmarshal.TimeSeries)) {
+	matviewEnabled = isEnabled
+	matviewPush = push
+}
+
+func isMaterializedViewsEnabled() bool {
+	return matviewEnabled != nil && matviewEnabled()
+}
+
 // CheckStreamAggrConfig checks config pointed by -stramaggr.config
 func CheckStreamAggrConfig() error {
 	if *streamAggrConfig == "" {
@@ -244,6 +262,11 @@ func (ctx *streamAggrCtx) push(mrs []storage.MetricRow, matchIdxs []byte) []byte
 
 	tss = tss[tssLen:]
 
+	// Materialized views are calculated from all the input samples before stream aggregation.
+	if matviewPush != nil {
+		matviewPush(tss)
+	}
+
 	sas := sasGlobal.Load()
 	if sas.IsEnabled() {
 		matchIdxs = sas.Push(tss, matchIdxs)
@@ -169,7 +169,7 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		prometheusWriteRequests.Inc()
-		if err := promremotewrite.InsertHandler(r); err != nil {
+		if err := promremotewrite.InsertHandler(w, r); err != nil {
 			prometheusWriteErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
@@ -213,11 +213,6 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		}
 		w.WriteHeader(http.StatusNoContent)
 		return true
-	case "/influx/query", "/query":
-		influxQueryRequests.Inc()
-		addInfluxResponseHeaders(w)
-		influxutil.WriteDatabaseNames(w)
-		return true
 	case "/influx/health":
 		influxHealthRequests.Inc()
 		influxutil.WriteHealthCheckResponse(w)
@@ -325,6 +320,12 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		scrapePool := r.FormValue("scrapePool")
 		promscrape.WriteAPIV1Targets(w, state, scrapePool)
 		return true
+	case "/prometheus/api/v1/targets/health", "/api/v1/targets/health":
+		promscrapeAPIV1TargetsHealthRequests.Inc()
+		w.Header().Set("Content-Type", "application/json")
+		scrapePool := r.FormValue("scrapePool")
+		promscrape.WriteAPIV1TargetsHealth(w, scrapePool)
+		return true
 	case "/prometheus/target_response", "/target_response":
 		promscrapeTargetResponseRequests.Inc()
 		if err := promscrape.WriteTargetResponse(w, r); err != nil {
@@ -403,7 +404,6 @@ var (
 	influxWriteRequests = metrics.NewCounter(`vm_http_requests_total{path="/influx/write", protocol="influx"}`)
 	influxWriteErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/influx/write", protocol="influx"}`)
 
-	influxQueryRequests  = metrics.NewCounter(`vm_http_requests_total{path="/influx/query", protocol="influx"}`)
 	influxHealthRequests = metrics.NewCounter(`vm_http_requests_total{path="/influx/health", protocol="influx"}`)
 
 	datadogv1WriteRequests = metrics.NewCounter(`vm_http_requests_total{path="/datadog/api/v1/series", protocol="datadog"}`)
@@ -432,7 +432,8 @@ var (
 	promscrapeTargetsRequests          = metrics.NewCounter(`vm_http_requests_total{path="/targets"}`)
 	promscrapeServiceDiscoveryRequests = metrics.NewCounter(`vm_http_requests_total{path="/service-discovery"}`)
 
-	promscrapeAPIV1TargetsRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/targets"}`)
+	promscrapeAPIV1TargetsRequests       = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/targets"}`)
+	promscrapeAPIV1TargetsHealthRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/targets/health"}`)
 
 	promscrapeTargetResponseRequests = metrics.NewCounter(`vm_http_requests_total{path="/target_response"}`)
 	promscrapeTargetResponseErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/target_response"}`)
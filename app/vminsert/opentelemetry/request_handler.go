@@ -33,12 +33,12 @@ func InsertHandler(req *http.Request) error {
 			return fmt.Errorf("json encoding isn't supported for opentelemetry format. Use protobuf encoding")
 		}
 	}
-	return stream.ParseStream(req.Body, encoding, processBody, func(tss []prompbmarshal.TimeSeries) error {
-		return insertRows(tss, extraLabels)
+	return stream.ParseStream(req.Body, encoding, processBody, func(tss []prompbmarshal.TimeSeries, mms []prompbmarshal.MetricMetadata) error {
+		return insertRows(tss, mms, extraLabels)
 	})
 }
 
-func insertRows(tss []prompbmarshal.TimeSeries, extraLabels []prompbmarshal.Label) error {
+func insertRows(tss []prompbmarshal.TimeSeries, mms []prompbmarshal.MetricMetadata, extraLabels []prompbmarshal.Label) error {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
@@ -47,6 +47,10 @@ func insertRows(tss []prompbmarshal.TimeSeries, extraLabels []prompbmarshal.Labe
 		rowsLen += len(tss[i].Samples)
 	}
 	ctx.Reset(rowsLen)
+	for i := range mms {
+		mm := &mms[i]
+		ctx.WriteMetadata(mm.Type, mm.MetricFamilyName, mm.Help, mm.Unit)
+	}
 	rowsTotal := 0
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range tss {
@@ -72,6 +76,14 @@ func insertRows(tss []prompbmarshal.TimeSeries, extraLabels []prompbmarshal.Labe
 				return err
 			}
 		}
+		for i := range ts.Exemplars {
+			e := &ts.Exemplars[i]
+			for j := range e.Labels {
+				label := &e.Labels[j]
+				ctx.AddExemplarLabel(label.Name, label.Value)
+			}
+			ctx.WriteExemplar(metricNameRaw, e.Timestamp, e.Value)
+		}
 	}
 	rowsInserted.Add(rowsTotal)
 	rowsPerInsert.Update(float64(rowsTotal))
@@ -20,6 +20,10 @@ func Push(wr *prompbmarshal.WriteRequest) {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
+	if len(wr.Metadata) > 0 {
+		pushMetadata(ctx, wr.Metadata)
+	}
+
 	tss := wr.Timeseries
 	for len(tss) > 0 {
 		// Process big tss in smaller blocks in order to reduce maximum memory usage
@@ -43,6 +47,17 @@ func Push(wr *prompbmarshal.WriteRequest) {
 	}
 }
 
+func pushMetadata(ctx *common.InsertCtx, mms []prompbmarshal.MetricMetadata) {
+	ctx.Reset(0)
+	for i := range mms {
+		mm := &mms[i]
+		ctx.WriteMetadata(mm.Type, mm.MetricFamilyName, mm.Help, mm.Unit)
+	}
+	if err := ctx.FlushBufs(); err != nil {
+		logger.Errorf("cannot flush promscrape metadata to storage: %s", err)
+	}
+}
+
 func push(ctx *common.InsertCtx, tss []prompbmarshal.TimeSeries) {
 	rowsLen := 0
 	for i := range tss {
@@ -72,6 +87,14 @@ func push(ctx *common.InsertCtx, tss []prompbmarshal.TimeSeries) {
 				return
 			}
 		}
+		for i := range ts.Exemplars {
+			e := &ts.Exemplars[i]
+			for j := range e.Labels {
+				label := &e.Labels[j]
+				ctx.AddExemplarLabel(label.Name, label.Value)
+			}
+			ctx.WriteExemplar(metricNameRaw, e.Timestamp, e.Value)
+		}
 	}
 	rowsInserted.Add(rowsTotal)
 	rowsPerInsert.Update(float64(rowsTotal))
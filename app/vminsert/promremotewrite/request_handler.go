@@ -5,6 +5,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/relabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/promremotewrite/stream"
@@ -18,18 +19,39 @@ var (
 )
 
 // InsertHandler processes remote write for prometheus.
-func InsertHandler(req *http.Request) error {
+//
+// Both Prometheus remote write 1.0 and 2.0 protocols are supported. The protocol is detected via Content-Type request header.
+func InsertHandler(w http.ResponseWriter, req *http.Request) error {
 	extraLabels, err := protoparserutil.GetExtraLabels(req)
 	if err != nil {
 		return err
 	}
 	isVMRemoteWrite := req.Header.Get("Content-Encoding") == "zstd"
-	return stream.Parse(req.Body, isVMRemoteWrite, func(tss []prompb.TimeSeries) error {
-		return insertRows(tss, extraLabels)
+	isPromRemoteWriteV2, err := stream.IsPromRemoteWriteV2(req.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	var written prompb.WriteRequestStats
+	err = stream.Parse(req.Body, isVMRemoteWrite, isPromRemoteWriteV2, func(tss []prompb.TimeSeries, mms []prompb.MetricMetadata, ws *prompb.WriteRequestStats) error {
+		if err := insertRows(tss, mms, extraLabels); err != nil {
+			return err
+		}
+		written = *ws
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+	if isPromRemoteWriteV2 {
+		if !vmstorage.IsExemplarsEnabled() {
+			written.Exemplars = 0
+		}
+		stream.SetWrittenHeaders(w.Header(), &written)
+	}
+	return nil
 }
 
-func insertRows(timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Label) error {
+func insertRows(timeseries []prompb.TimeSeries, mms []prompb.MetricMetadata, extraLabels []prompbmarshal.Label) error {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
@@ -38,6 +60,10 @@ func insertRows(timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Labe
 		rowsLen += len(timeseries[i].Samples)
 	}
 	ctx.Reset(rowsLen)
+	for i := range mms {
+		mm := &mms[i]
+		ctx.WriteMetadata(uint32(mm.Type), mm.MetricFamilyName, mm.Help, mm.Unit)
+	}
 	rowsTotal := 0
 	hasRelabeling := relabel.HasRelabeling()
 	for i := range timeseries {
@@ -66,6 +92,14 @@ func insertRows(timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Labe
 				return err
 			}
 		}
+		for i := range ts.Exemplars {
+			e := &ts.Exemplars[i]
+			for j := range e.Labels {
+				label := &e.Labels[j]
+				ctx.AddExemplarLabel(label.Name, label.Value)
+			}
+			ctx.WriteExemplar(metricNameRaw, e.Timestamp, e.Value)
+		}
 	}
 	rowsInserted.Add(rowsTotal)
 	rowsPerInsert.Update(float64(rowsTotal))
@@ -7,6 +7,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/graphiteql"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/quota"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -34,6 +35,9 @@ type evalConfig struct {
 	// Enforced tag filters
 	etfs [][]storage.TagFilter
 
+	// tenant contains quotas for the tenant the query is executed for. It may be nil.
+	tenant *quota.Tenant
+
 	// originalQuery contains the original query - used for debug logging.
 	originalQuery string
 }
@@ -137,6 +141,8 @@ func evalExpr(ec *evalConfig, expr graphiteql.Expr) (nextSeriesFunc, error) {
 		return evalMetricExpr(ec, t)
 	case *graphiteql.FuncExpr:
 		return evalFuncExpr(ec, t)
+	case *seriesExpr:
+		return multiSeriesFunc([]*series{t.s}), nil
 	default:
 		return nil, fmt.Errorf("unexpected expression type %T; want graphiteql.MetricExpr or graphiteql.FuncExpr; expr: %q", t, t.AppendString(nil))
 	}
@@ -148,12 +154,12 @@ func evalMetricExpr(ec *evalConfig, me *graphiteql.MetricExpr) (nextSeriesFunc,
 		Value: []byte(me.Query),
 	}}
 	tfss := joinTagFilterss(tfs, ec.etfs)
-	sq := storage.NewSearchQuery(ec.startTime, ec.endTime, tfss, *maxGraphiteSeries)
+	sq := storage.NewSearchQuery(ec.startTime, ec.endTime, tfss, ec.tenant.MaxSeries(*maxGraphiteSeries))
 	return newNextSeriesForSearchQuery(ec, sq, me)
 }
 
 func newNextSeriesForSearchQuery(ec *evalConfig, sq *storage.SearchQuery, expr graphiteql.Expr) (nextSeriesFunc, error) {
-	rss, err := netstorage.ProcessSearchQuery(nil, sq, ec.deadline)
+	rss, err := netstorage.ProcessSearchQuery(nil, ec.tenant, sq, ec.deadline)
 	if err != nil {
 		return nil, fmt.Errorf("cannot fetch data for %q: %w", sq, err)
 	}
@@ -171,7 +177,14 @@ func newNextSeriesForSearchQuery(ec *evalConfig, sq *storage.SearchQuery, expr g
 				expr:           expr,
 				pathExpression: string(expr.AppendString(nil)),
 			}
-			s.summarize(aggrAvg, ec.startTime, ec.endTime, ec.storageStep, 0)
+			aggrFunc := aggrAvg
+			if sar := getStorageAggregationRule(nameWithTags); sar != nil {
+				// Consolidate datapoints in the same way as Graphite does for whisper files created with the matching storage-aggregation.conf rule.
+				aggrFunc = sar.aggrFunc
+				s.consolidateFunc = sar.aggrFunc
+				s.xFilesFactor = sar.xFilesFactor
+			}
+			s.summarize(aggrFunc, ec.startTime, ec.endTime, ec.storageStep, 0)
 			t := timerpool.Get(30 * time.Second)
 			select {
 			case seriesCh <- s:
@@ -822,6 +822,33 @@ func TestExecExprSuccess(t *testing.T) {
 			Tags:       map[string]string{"name": "averageSeries(bar,foo,xxx)", "aggregatedBy": "average"},
 		},
 	})
+	f(`cactiStyle(time('foo.bar',30))`, []*series{
+		{
+			Timestamps:     []int64{120000, 150000, 180000, 210000},
+			Values:         []float64{120, 150, 180, 210},
+			Name:           "foo.bar Current:210.00    Max:210.00    Min:120.00    ",
+			Tags:           map[string]string{"name": "foo.bar"},
+			pathExpression: "foo.bar",
+		},
+	})
+	f(`cactiStyle(scale(time('foo',30),1000),'si','b')`, []*series{
+		{
+			Timestamps:     []int64{120000, 150000, 180000, 210000},
+			Values:         []float64{120000, 150000, 180000, 210000},
+			Name:           "scale(foo,1000) Current:210.00 Kb    Max:210.00 Kb    Min:120.00 Kb    ",
+			Tags:           map[string]string{"name": "foo"},
+			pathExpression: "scale(foo,1000)",
+		},
+	})
+	f(`cactiStyle(scale(time('foo',30),1000),system='binary')`, []*series{
+		{
+			Timestamps:     []int64{120000, 150000, 180000, 210000},
+			Values:         []float64{120000, 150000, 180000, 210000},
+			Name:           "scale(foo,1000) Current:205.08Ki    Max:205.08Ki    Min:117.19Ki    ",
+			Tags:           map[string]string{"name": "foo"},
+			pathExpression: "scale(foo,1000)",
+		},
+	})
 	f(`changed(
 		group(
 			constantLine(123)|alias('foo'),
@@ -1559,6 +1586,24 @@ func TestExecExprSuccess(t *testing.T) {
 			pathExpression: ("keepLastValue(removeAboveValue(a,150))"),
 		},
 	})
+	f(`legendValue(time('foo',30),'avg','last','bogus')`, []*series{
+		{
+			Timestamps:     []int64{120000, 150000, 180000, 210000},
+			Values:         []float64{120, 150, 180, 210},
+			Name:           "foo                 avg  165.00    last 210.00    bogus(?)       ",
+			Tags:           map[string]string{"name": "foo"},
+			pathExpression: "foo",
+		},
+	})
+	f(`legendValue(scale(time('foo',30),1000),'max','si')`, []*series{
+		{
+			Timestamps:     []int64{120000, 150000, 180000, 210000},
+			Values:         []float64{120000, 150000, 180000, 210000},
+			Name:           "scale(foo,1000)     max  210.00K   ",
+			Tags:           map[string]string{"name": "foo"},
+			pathExpression: "scale(foo,1000)",
+		},
+	})
 	f(`limit(
 		group(
 			time('foo',25),
@@ -1690,6 +1735,33 @@ func TestExecExprSuccess(t *testing.T) {
 			Tags:       map[string]string{"name": "baz"},
 		},
 	})
+	f(`mapSeries(
+		group(
+			time('a.x',30),
+			time('b.y',30),
+			time('a.z',30),
+		),
+		0
+	)`, []*series{
+		{
+			Timestamps: []int64{120000, 150000, 180000, 210000},
+			Values:     []float64{120, 150, 180, 210},
+			Name:       "a.x",
+			Tags:       map[string]string{"name": "a.x"},
+		},
+		{
+			Timestamps: []int64{120000, 150000, 180000, 210000},
+			Values:     []float64{120, 150, 180, 210},
+			Name:       "a.z",
+			Tags:       map[string]string{"name": "a.z"},
+		},
+		{
+			Timestamps: []int64{120000, 150000, 180000, 210000},
+			Values:     []float64{120, 150, 180, 210},
+			Name:       "b.y",
+			Tags:       map[string]string{"name": "b.y"},
+		},
+	})
 	f(`maxSeries(
 		time('foo',30),
 		time('bar',30),
@@ -2084,6 +2156,34 @@ func TestExecExprSuccess(t *testing.T) {
 			Tags:       map[string]string{"name": "powSeries(a,b)", "aggregatedBy": "pow"},
 		},
 	})
+	f(`reduceSeries(
+		mapSeries(
+			group(
+				constantLine(50)|alias('srv1.used'),
+				constantLine(200)|alias('srv1.total'),
+				constantLine(10)|alias('srv2.used'),
+				constantLine(100)|alias('srv2.total'),
+				constantLine(1)|alias('srv3.used'),
+			),
+			0
+		),
+		'asPercent', 1, 'used', 'total'
+	)`, []*series{
+		{
+			Timestamps:     []int64{120000, 165000},
+			Values:         []float64{25, 25},
+			Name:           "srv1.reduce.asPercent",
+			Tags:           map[string]string{"name": "asPercent(srv1.used,srv1.total)"},
+			pathExpression: "asPercent(srv1.used,srv1.total)",
+		},
+		{
+			Timestamps:     []int64{120000, 165000},
+			Values:         []float64{10, 10},
+			Name:           "srv2.reduce.asPercent",
+			Tags:           map[string]string{"name": "asPercent(srv2.used,srv2.total)"},
+			pathExpression: "asPercent(srv2.used,srv2.total)",
+		},
+	})
 	f(`removeAbovePercentile(time('a',35), 50)`, []*series{
 		{
 			Timestamps:     []int64{120000, 155000, 190000},
@@ -2434,6 +2534,14 @@ func TestExecExprSuccess(t *testing.T) {
 			Tags:       map[string]string{"name": "foo.bar"},
 		},
 	})
+	f(`pct(time('foo',30),300)`, []*series{
+		{
+			Timestamps: []int64{120000, 150000, 180000, 210000},
+			Values:     []float64{40, 50, 60, 70},
+			Name:       "asPercent(foo,300)",
+			Tags:       map[string]string{"name": "foo"},
+		},
+	})
 	f(`perSecond(time('foo.bar;baz=1',25))`, []*series{
 		{
 			Timestamps: []int64{120000, 145000, 170000, 195000},
@@ -2488,6 +2596,42 @@ func TestExecExprSuccess(t *testing.T) {
 			Tags:       map[string]string{"name": "percentileOfSeries(a,90)"},
 		},
 	})
+	f(`toLowerCase(alias(time('Foo.BAR',30),'Foo.BAR'))`, []*series{
+		{
+			Timestamps:     []int64{120000, 150000, 180000, 210000},
+			Values:         []float64{120, 150, 180, 210},
+			Name:           "foo.bar",
+			Tags:           map[string]string{"name": "Foo.BAR"},
+			pathExpression: "Foo.BAR",
+		},
+	})
+	f(`lower(time('FOO.BAR',30),0,-1,100)`, []*series{
+		{
+			Timestamps:     []int64{120000, 150000, 180000, 210000},
+			Values:         []float64{120, 150, 180, 210},
+			Name:           "fOO.BAr",
+			Tags:           map[string]string{"name": "FOO.BAR"},
+			pathExpression: "FOO.BAR",
+		},
+	})
+	f(`toUpperCase(time('foo.bar',30))`, []*series{
+		{
+			Timestamps:     []int64{120000, 150000, 180000, 210000},
+			Values:         []float64{120, 150, 180, 210},
+			Name:           "FOO.BAR",
+			Tags:           map[string]string{"name": "foo.bar"},
+			pathExpression: "foo.bar",
+		},
+	})
+	f(`upper(time('foo.bar',30),-3)`, []*series{
+		{
+			Timestamps:     []int64{120000, 150000, 180000, 210000},
+			Values:         []float64{120, 150, 180, 210},
+			Name:           "foo.Bar",
+			Tags:           map[string]string{"name": "foo.bar"},
+			pathExpression: "foo.bar",
+		},
+	})
 	f(`transformNull(time('foo.bar',35),-1,time('foo.bar',30))`, []*series{
 		{
 			Timestamps:     []int64{120000, 150000, 180000},
@@ -3563,6 +3707,11 @@ func TestExecExprFailure(t *testing.T) {
 
 	f("avg(1)")
 
+	f("cactiStyle()")
+	f("cactiStyle(1)")
+	f("cactiStyle(time('a'),'foo')")
+	f("cactiStyle(time('a'),'si','b',1)")
+
 	f("changed()")
 	f("changed(1)")
 
@@ -3722,6 +3871,11 @@ func TestExecExprFailure(t *testing.T) {
 	f("keepLastValue()")
 	f("keepLastValue(1)")
 
+	f("legendValue()")
+	f("legendValue(time('a'))")
+	f("legendValue(1,'avg')")
+	f("legendValue(time('a'),1)")
+
 	f("limit()")
 	f("limit(1,2)")
 	f("limit(1,'foo')")
@@ -3751,6 +3905,10 @@ func TestExecExprFailure(t *testing.T) {
 	f("lowestCurrent(1,2)")
 	f("lowestCurrent(1,'foo')")
 
+	f("mapSeries()")
+	f("mapSeries(time('a'))")
+	f("mapSeries(1,0)")
+
 	f("maxSeries(1)")
 	f("maxSeries(time('a'),1)")
 
@@ -3845,6 +4003,12 @@ func TestExecExprFailure(t *testing.T) {
 	f("randomWalk(1)")
 	f("randomWalk('foo','bar')")
 
+	f("reduceSeries()")
+	f("reduceSeries(time('a'),'sumSeries',1)")
+	f("reduceSeries(time('a'),'foobar',1,'a')")
+	f("reduceSeries(time('a'),'sumSeries','foo','a')")
+	f("reduceSeries(time('a'),'sumSeries',1,2)")
+
 	f("removeAbovePercentile()")
 	f("removeAbovePercentile(1, 2)")
 	f("removeAbovePercentile(1, 'foo')")
@@ -3953,6 +4117,14 @@ func TestExecExprFailure(t *testing.T) {
 	f(`timeSlice(time('a'),'5min','bar')`)
 	f(`timeSlice(1,'5min','10min')`)
 
+	f(`toLowerCase()`)
+	f(`toLowerCase(1)`)
+	f(`toLowerCase(time('a'),'foo')`)
+
+	f(`toUpperCase()`)
+	f(`toUpperCase(1)`)
+	f(`toUpperCase(time('a'),'foo')`)
+
 	f(`timeStack()`)
 	f(`timeStack(time('a'),timeShiftUnit=123)`)
 	f(`timeStack(time('a'),'foo')`)
@@ -0,0 +1,17 @@
+package graphite
+
+import (
+	"testing"
+)
+
+func TestFuncsAreSupported(t *testing.T) {
+	// Functions returned from /functions API must be supported by the render API.
+	for funcName, fi := range funcs {
+		if fi.Name != funcName {
+			t.Fatalf("unexpected name for function %q; got %q", funcName, fi.Name)
+		}
+		if transformFuncs[funcName] == nil {
+			t.Fatalf("function %q is listed in functions.json, but isn't supported", funcName)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/quota"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bufferedwriter"
 	"github.com/VictoriaMetrics/metrics"
@@ -104,6 +105,7 @@ func RenderHandler(startTime time.Time, w http.ResponseWriter, r *http.Request)
 			currentTime:   startTime,
 			xFilesFactor:  xFilesFactor,
 			etfs:          etfs,
+			tenant:        quota.GetTenant(r),
 			originalQuery: target,
 		}
 		nextSeries, err := execExpr(ec, target)
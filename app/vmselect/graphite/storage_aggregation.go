@@ -0,0 +1,191 @@
+package graphite
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs/fscore"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var storageAggregationConfig = flag.String("search.graphiteStorageAggregationConfig", "", "Optional path to a file in Graphite storage-aggregation.conf format "+
+	"with per-path aggregation methods and xFilesFactor values, which are used by Graphite Render API when consolidating datapoints. "+
+	"The path can point either to local file or to http url. "+
+	"See https://docs.victoriametrics.com/victoriametrics/integrations/graphite/#storage-aggregation . The config is reloaded on SIGHUP signal")
+
+// storageAggregationRule is a single section from -search.graphiteStorageAggregationConfig.
+//
+// See https://graphite.readthedocs.io/en/latest/config-carbon.html#storage-aggregation-conf
+type storageAggregationRule struct {
+	name              string
+	pattern           *regexp.Regexp
+	xFilesFactor      float64
+	aggregationMethod string
+	aggrFunc          aggrFunc
+}
+
+var storageAggregationRules atomic.Pointer[[]*storageAggregationRule]
+
+// Init must be called after flag.Parse and before using the graphite package.
+func Init() {
+	// Register SIGHUP handler for config re-read just before loadStorageAggregationConfig call.
+	// This guarantees that the config will be re-read if the signal arrives during loadStorageAggregationConfig call.
+	sighupCh := procutil.NewSighupChan()
+
+	rules, err := loadStorageAggregationConfig()
+	if err != nil {
+		logger.Fatalf("cannot load -search.graphiteStorageAggregationConfig: %s", err)
+	}
+	if len(*storageAggregationConfig) == 0 {
+		return
+	}
+	storageAggregationRules.Store(&rules)
+
+	configReloads := metrics.NewCounter(`vm_graphite_storage_aggregation_config_reloads_total`)
+	configReloadErrors := metrics.NewCounter(`vm_graphite_storage_aggregation_config_reloads_errors_total`)
+	configSuccess := metrics.NewGauge(`vm_graphite_storage_aggregation_config_last_reload_successful`, nil)
+	configTimestamp := metrics.NewCounter(`vm_graphite_storage_aggregation_config_last_reload_success_timestamp_seconds`)
+	configSuccess.Set(1)
+	configTimestamp.Set(fasttime.UnixTimestamp())
+
+	go func() {
+		for range sighupCh {
+			configReloads.Inc()
+			logger.Infof("received SIGHUP; reloading -search.graphiteStorageAggregationConfig=%q...", *storageAggregationConfig)
+			rules, err := loadStorageAggregationConfig()
+			if err != nil {
+				configReloadErrors.Inc()
+				configSuccess.Set(0)
+				logger.Errorf("cannot load the updated -search.graphiteStorageAggregationConfig: %s; preserving the previous config", err)
+				continue
+			}
+			storageAggregationRules.Store(&rules)
+			configSuccess.Set(1)
+			configTimestamp.Set(fasttime.UnixTimestamp())
+			logger.Infof("successfully reloaded -search.graphiteStorageAggregationConfig=%q", *storageAggregationConfig)
+		}
+	}()
+}
+
+// CheckStorageAggregationConfig checks config pointed by -search.graphiteStorageAggregationConfig
+func CheckStorageAggregationConfig() error {
+	_, err := loadStorageAggregationConfig()
+	return err
+}
+
+func loadStorageAggregationConfig() ([]*storageAggregationRule, error) {
+	if len(*storageAggregationConfig) == 0 {
+		return nil, nil
+	}
+	data, err := fscore.ReadFileOrHTTP(*storageAggregationConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read -search.graphiteStorageAggregationConfig=%q: %w", *storageAggregationConfig, err)
+	}
+	rules, err := parseStorageAggregationConfig(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse -search.graphiteStorageAggregationConfig=%q: %w", *storageAggregationConfig, err)
+	}
+	return rules, nil
+}
+
+// parseStorageAggregationConfig parses rules from data in storage-aggregation.conf format.
+//
+// Rules are returned in the order of their sections in data, since the first matching rule must be applied.
+func parseStorageAggregationConfig(data string) ([]*storageAggregationRule, error) {
+	var rules []*storageAggregationRule
+	var r *storageAggregationRule
+	finishRule := func() error {
+		if r == nil {
+			return nil
+		}
+		if r.pattern == nil {
+			return fmt.Errorf("missing pattern in section [%s]", r.name)
+		}
+		if r.aggrFunc == nil {
+			r.aggregationMethod = "average"
+			r.aggrFunc = aggrAvg
+		}
+		rules = append(rules, r)
+		return nil
+	}
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: missing closing bracket in section name %q", i+1, line)
+			}
+			if err := finishRule(); err != nil {
+				return nil, err
+			}
+			r = &storageAggregationRule{
+				name: strings.TrimSpace(line[1 : len(line)-1]),
+			}
+			continue
+		}
+		if r == nil {
+			return nil, fmt.Errorf("line %d: %q must be put inside a section", i+1, line)
+		}
+		n := strings.IndexAny(line, "=:")
+		if n < 0 {
+			return nil, fmt.Errorf("line %d: missing `=` in %q", i+1, line)
+		}
+		key := strings.TrimSpace(line[:n])
+		value := strings.TrimSpace(line[n+1:])
+		switch key {
+		case "pattern":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: cannot parse pattern %q: %w", i+1, value, err)
+			}
+			r.pattern = re
+		case "xFilesFactor":
+			xff, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: cannot parse xFilesFactor=%q: %w", i+1, value, err)
+			}
+			if xff < 0 || xff > 1 {
+				return nil, fmt.Errorf("line %d: xFilesFactor must be in the range [0..1]; got %v", i+1, xff)
+			}
+			r.xFilesFactor = xff
+		case "aggregationMethod":
+			af := aggrFuncs[value]
+			if af == nil {
+				return nil, fmt.Errorf("line %d: unsupported aggregationMethod=%q; supported values: average, sum, min, max, last, avg_zero", i+1, value)
+			}
+			r.aggregationMethod = value
+			r.aggrFunc = af
+		default:
+			return nil, fmt.Errorf("line %d: unsupported key %q in section [%s]; supported keys: pattern, xFilesFactor, aggregationMethod", i+1, key, r.name)
+		}
+	}
+	if err := finishRule(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// getStorageAggregationRule returns the first rule from -search.graphiteStorageAggregationConfig matching the given path.
+//
+// nil is returned if there are no matching rules.
+func getStorageAggregationRule(path string) *storageAggregationRule {
+	p := storageAggregationRules.Load()
+	if p == nil {
+		return nil
+	}
+	for _, r := range *p {
+		if r.pattern.MatchString(path) {
+			return r
+		}
+	}
+	return nil
+}
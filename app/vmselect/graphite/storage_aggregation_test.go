@@ -0,0 +1,103 @@
+package graphite
+
+import (
+	"testing"
+)
+
+func TestParseStorageAggregationConfigFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		rules, err := parseStorageAggregationConfig(data)
+		if err == nil {
+			t.Fatalf("expecting non-nil error for config\n%s", data)
+		}
+		if rules != nil {
+			t.Fatalf("expecting nil rules; got %v", rules)
+		}
+	}
+
+	// key outside a section
+	f(`pattern = foo`)
+
+	// missing closing bracket
+	f(`[foo
+pattern = foo`)
+
+	// missing pattern
+	f(`[foo]
+aggregationMethod = sum`)
+
+	// invalid pattern
+	f(`[foo]
+pattern = foo(`)
+
+	// invalid xFilesFactor
+	f(`[foo]
+pattern = foo
+xFilesFactor = bar`)
+	f(`[foo]
+pattern = foo
+xFilesFactor = 1.5`)
+
+	// unsupported aggregationMethod
+	f(`[foo]
+pattern = foo
+aggregationMethod = bar`)
+
+	// unsupported key
+	f(`[foo]
+pattern = foo
+retentions = 10s:1d`)
+
+	// missing value
+	f(`[foo]
+pattern`)
+}
+
+func TestParseStorageAggregationConfigSuccess(t *testing.T) {
+	rules, err := parseStorageAggregationConfig(`
+# Aggregation methods for whisper files. Entries are scanned in order,
+# and first match wins.
+[min]
+pattern = \.min$
+xFilesFactor = 0.1
+aggregationMethod = min
+
+[count]
+pattern: \.count$
+xFilesFactor: 0
+aggregationMethod: sum
+
+; the default rule
+[default_average]
+pattern = .*
+xFilesFactor = 0.5
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	storageAggregationRules.Store(&rules)
+	defer storageAggregationRules.Store(nil)
+
+	f := func(path, nameExpected, aggregationMethodExpected string, xFilesFactorExpected float64) {
+		t.Helper()
+		r := getStorageAggregationRule(path)
+		if r == nil {
+			t.Fatalf("missing rule for path %q", path)
+		}
+		if r.name != nameExpected {
+			t.Fatalf("unexpected rule for path %q; got %q; want %q", path, r.name, nameExpected)
+		}
+		if r.aggregationMethod != aggregationMethodExpected {
+			t.Fatalf("unexpected aggregationMethod for path %q; got %q; want %q", path, r.aggregationMethod, aggregationMethodExpected)
+		}
+		if r.xFilesFactor != xFilesFactorExpected {
+			t.Fatalf("unexpected xFilesFactor for path %q; got %v; want %v", path, r.xFilesFactor, xFilesFactorExpected)
+		}
+	}
+
+	f("foo.bar.min", "min", "min", 0.1)
+	f("foo.requests.count", "count", "sum", 0)
+	f("foo.count.bar", "default_average", "average", 0.5)
+	f("foo.count;env=prod", "default_average", "average", 0.5)
+}
@@ -6,12 +6,15 @@ import (
 	"math"
 	"math/rand"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/graphiteql"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
@@ -54,7 +57,7 @@ func init() {
 		"averageSeries":               transformAverageSeries,
 		"averageSeriesWithWildcards":  transformAverageSeriesWithWildcards,
 		"avg":                         transformAverageSeries,
-		"cactiStyle":                  transformTODO,
+		"cactiStyle":                  transformCactiStyle,
 		"changed":                     transformChanged,
 		"color":                       transformColor,
 		"consolidateBy":               transformConsolidateBy,
@@ -98,18 +101,19 @@ func init() {
 		"invert":                      transformInvert,
 		"isNonNull":                   transformIsNonNull,
 		"keepLastValue":               transformKeepLastValue,
-		"legendValue":                 transformTODO,
+		"legendValue":                 transformLegendValue,
 		"limit":                       transformLimit,
 		"lineWidth":                   transformLineWidth,
 		"linearRegression":            transformLinearRegression,
 		"log":                         transformLogarithm,
 		"logarithm":                   transformLogarithm,
 		"logit":                       transformLogit,
+		"lower":                       transformToLowerCase,
 		"lowest":                      transformLowest,
 		"lowestAverage":               transformLowestAverage,
 		"lowestCurrent":               transformLowestCurrent,
-		"map":                         transformTODO,
-		"mapSeries":                   transformTODO,
+		"map":                         transformMapSeries,
+		"mapSeries":                   transformMapSeries,
 		"max":                         transformMaxSeries,
 		"maxSeries":                   transformMaxSeries,
 		"maximumAbove":                transformMaximumAbove,
@@ -133,19 +137,17 @@ func init() {
 		"nonNegativeDerivative":       transformNonNegativeDerivative,
 		"offset":                      transformOffset,
 		"offsetToZero":                transformOffsetToZero,
+		"pct":                         transformAsPercent,
 		"perSecond":                   transformPerSecond,
 		"percentileOfSeries":          transformPercentileOfSeries,
-		// It looks like pie* functions aren't needed for Graphite render API
-		//		"pieAverage":                  transformTODO,
-		//		"pieMaximum":                  transformTODO,
-		//		"pieMinimum":                  transformTODO,
+		// pie* functions aren't supported, since they return a single value per series instead of series.
 		"pow":                     transformPow,
 		"powSeries":               transformPowSeries,
 		"randomWalk":              transformRandomWalk,
 		"randomWalkFunction":      transformRandomWalk,
 		"rangeOfSeries":           transformRangeOfSeries,
-		"reduce":                  transformTODO,
-		"reduceSeries":            transformTODO,
+		"reduce":                  transformReduceSeries,
+		"reduceSeries":            transformReduceSeries,
 		"removeAbovePercentile":   transformRemoveAbovePercentile,
 		"removeAboveValue":        transformRemoveAboveValue,
 		"removeBelowPercentile":   transformRemoveBelowPercentile,
@@ -184,8 +186,11 @@ func init() {
 		"timeShift":               transformTimeShift,
 		"timeSlice":               transformTimeSlice,
 		"timeStack":               transformTimeStack,
+		"toLowerCase":             transformToLowerCase,
+		"toUpperCase":             transformToUpperCase,
 		"transformNull":           transformTransformNull,
 		"unique":                  transformUnique,
+		"upper":                   transformToUpperCase,
 		"useSeriesAbove":          transformUseSeriesAbove,
 		"verticalLine":            transformVerticalLine,
 		"weightedAverage":         transformWeightedAverage,
@@ -193,10 +198,6 @@ func init() {
 	}
 }
 
-func transformTODO(_ *evalConfig, _ *graphiteql.FuncExpr) (nextSeriesFunc, error) {
-	return nil, fmt.Errorf("TODO: implement this function")
-}
-
 // See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.absolute
 func transformAbsolute(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, error) {
 	args := fe.Args
@@ -998,6 +999,120 @@ func aggregateSeriesWithWildcardsGeneric(ec *evalConfig, fe *graphiteql.FuncExpr
 	return aggregateSeriesWithWildcards(ec, fe, nextSeries, funcName, positions)
 }
 
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.cactiStyle
+func transformCactiStyle(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, error) {
+	args := fe.Args
+	if len(args) < 1 || len(args) > 3 {
+		return nil, fmt.Errorf("unexpected number of args; got %d; want from 1 to 3", len(args))
+	}
+	system, err := getOptionalString(args, "system", 1, "")
+	if err != nil {
+		return nil, err
+	}
+	if system != "" && unitSystems[system] == nil {
+		return nil, fmt.Errorf("unsupported system=%q; supported values: si, binary", system)
+	}
+	units, err := getOptionalString(args, "units", 2, "")
+	if err != nil {
+		return nil, err
+	}
+	nextSeries, err := evalSeriesList(ec, args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	ss, err := fetchAllSeries(nextSeries)
+	if err != nil {
+		return nil, err
+	}
+	formatValue := func(v float64) string {
+		if system == "" {
+			if units != "" {
+				return fmt.Sprintf("%.2f %s", v, units)
+			}
+			return fmt.Sprintf("%.2f", v)
+		}
+		v, prefix := formatUnits(v, system, units)
+		if units != "" {
+			return fmt.Sprintf("%.2f %s", v, prefix)
+		}
+		return fmt.Sprintf("%.2f%s", v, prefix)
+	}
+	// Graphite calculates column widths from the integer parts of values, while zero and missing values are replaced with 3.
+	formatValueForWidth := func(v float64) string {
+		if math.IsNaN(v) || v == 0 {
+			v = 3
+		}
+		return formatValue(math.Trunc(v))
+	}
+	nameLen, lastLen, maxLen, minLen := 0, 0, 0, 0
+	for _, s := range ss {
+		nameLen = max(nameLen, utf8.RuneCountInString(s.Name))
+		lastLen = max(lastLen, len(formatValueForWidth(aggrLast(s.Values))))
+		maxLen = max(maxLen, len(formatValueForWidth(aggrMax(s.Values))))
+		minLen = max(minLen, len(formatValueForWidth(aggrMin(s.Values))))
+	}
+	lastLen += 3
+	maxLen += 3
+	minLen += 3
+	formatColumn := func(v float64) string {
+		if math.IsNaN(v) {
+			return "NaN"
+		}
+		return formatValue(v)
+	}
+	for _, s := range ss {
+		last := formatColumn(aggrLast(s.Values))
+		maximum := formatColumn(aggrMax(s.Values))
+		minimum := formatColumn(aggrMin(s.Values))
+		s.Name = fmt.Sprintf("%-*s Current:%-*s Max:%-*s Min:%-*s ", nameLen, s.Name, lastLen, last, maxLen, maximum, minLen, minimum)
+		s.expr = fe
+	}
+	return multiSeriesFunc(ss), nil
+}
+
+// unitSystems contains unit prefixes for the given unit system in the descending order of their sizes.
+//
+// See https://github.com/graphite-project/graphite-web/blob/master/webapp/graphite/render/glyph.py
+var unitSystems = map[string][]unitPrefix{
+	"binary": {
+		{"Pi", 1 << 50},
+		{"Ti", 1 << 40},
+		{"Gi", 1 << 30},
+		{"Mi", 1 << 20},
+		{"Ki", 1 << 10},
+	},
+	"si": {
+		{"P", 1e15},
+		{"T", 1e12},
+		{"G", 1e9},
+		{"M", 1e6},
+		{"K", 1e3},
+	},
+}
+
+type unitPrefix struct {
+	prefix string
+	size   float64
+}
+
+// formatUnits returns v scaled to the biggest matching unit prefix from the given system and the prefix with the appended units.
+//
+// The system must be registered in unitSystems.
+func formatUnits(v float64, system, units string) (float64, string) {
+	roundIfInteger := func(v float64) float64 {
+		if v-math.Floor(v) < 1e-11 && v > 1 {
+			return math.Floor(v)
+		}
+		return v
+	}
+	for _, up := range unitSystems[system] {
+		if math.Abs(v) >= up.size {
+			return roundIfInteger(v / up.size), up.prefix + units
+		}
+	}
+	return roundIfInteger(v), units
+}
+
 // See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.changed
 func transformChanged(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, error) {
 	args := fe.Args
@@ -2324,6 +2439,52 @@ func transformKeepLastValue(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeries
 	return f, nil
 }
 
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.legendValue
+func transformLegendValue(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, error) {
+	args := fe.Args
+	if len(args) < 2 {
+		return nil, fmt.Errorf("unexpected number of args; got %d; want at least 2", len(args))
+	}
+	var valueTypes []string
+	for i := 1; i < len(args); i++ {
+		valueType, err := getString(args, "valueTypes", i)
+		if err != nil {
+			return nil, err
+		}
+		valueTypes = append(valueTypes, valueType)
+	}
+	system := ""
+	if lastType := valueTypes[len(valueTypes)-1]; unitSystems[lastType] != nil {
+		system = lastType
+		valueTypes = valueTypes[:len(valueTypes)-1]
+	}
+	nextSeries, err := evalSeriesList(ec, args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	f := nextSeriesSerialWrapper(nextSeries, func(s *series) (*series, error) {
+		for _, valueType := range valueTypes {
+			formatted := "(?)"
+			if af, err := getAggrFunc(valueType); err == nil {
+				v := af(s.Values)
+				switch {
+				case math.IsNaN(v):
+					formatted = "None"
+				case system != "":
+					v, prefix := formatUnits(v, system, "")
+					formatted = fmt.Sprintf("%.2f%s", v, prefix)
+				default:
+					formatted = fmt.Sprintf("%.2f", v)
+				}
+			}
+			s.Name = fmt.Sprintf("%-20s%-5s%-10s", s.Name, valueType, formatted)
+		}
+		s.expr = fe
+		return s, nil
+	})
+	return f, nil
+}
+
 // See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.limit
 func transformLimit(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, error) {
 	args := fe.Args
@@ -2543,6 +2704,44 @@ func transformLowestCurrent(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeries
 	return lowestGeneric(fe, nextSeries, n, "current")
 }
 
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.mapSeries
+//
+// Graphite returns a list of series lists, while this function returns a flat series list ordered by groups.
+// This is enough for reduceSeries, which is the only consumer of mapSeries results in Graphite.
+func transformMapSeries(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, error) {
+	args := fe.Args
+	if len(args) < 2 {
+		return nil, fmt.Errorf("unexpected number of args; got %d; want at least 2", len(args))
+	}
+	nodes, err := getNodes(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	nextSeries, err := evalSeriesList(ec, args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	ss, err := fetchAllSeries(nextSeries)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	m := make(map[string][]*series)
+	for _, s := range ss {
+		key := getNameFromNodes(s.Name, s.Tags, nodes)
+		if _, ok := m[key]; !ok {
+			keys = append(keys, key)
+		}
+		s.expr = fe
+		m[key] = append(m[key], s)
+	}
+	ss = ss[:0]
+	for _, key := range keys {
+		ss = append(ss, m[key]...)
+	}
+	return multiSeriesFunc(ss), nil
+}
+
 // See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.maxSeries
 func transformMaxSeries(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, error) {
 	return aggregateSeriesGeneric(ec, fe, "max")
@@ -2958,6 +3157,110 @@ func transformRangeOfSeries(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeries
 	return aggregateSeriesGeneric(ec, fe, "rangeOf")
 }
 
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.reduceSeries
+func transformReduceSeries(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, error) {
+	args := fe.Args
+	if len(args) < 4 {
+		return nil, fmt.Errorf("unexpected number of args; got %d; want at least 4", len(args))
+	}
+	reduceFunction, err := getString(args, "reduceFunction", 1)
+	if err != nil {
+		return nil, err
+	}
+	if transformFuncs[reduceFunction] == nil {
+		return nil, fmt.Errorf("unknown reduceFunction=%q", reduceFunction)
+	}
+	rn, err := getNumber(args, "reduceNode", 2)
+	if err != nil {
+		return nil, err
+	}
+	reduceNode := int(rn)
+	var reduceMatchers []string
+	for i := 3; i < len(args); i++ {
+		matcher, err := getString(args, "reduceMatchers", i)
+		if err != nil {
+			return nil, err
+		}
+		reduceMatchers = append(reduceMatchers, matcher)
+	}
+	nextSeries, err := evalSeriesList(ec, args, "seriesLists", 0)
+	if err != nil {
+		return nil, err
+	}
+	ss, err := fetchAllSeries(nextSeries)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	m := make(map[string][]*series)
+	for _, s := range ss {
+		nodes := strings.Split(getPathFromName(s.Name), ".")
+		n := getAbsoluteNodeIndex(reduceNode, len(nodes))
+		if n < 0 {
+			continue
+		}
+		idx := slices.Index(reduceMatchers, nodes[n])
+		if idx < 0 {
+			continue
+		}
+		key := strings.Join(nodes[:n], ".") + ".reduce." + reduceFunction
+		group := m[key]
+		if group == nil {
+			group = make([]*series, len(reduceMatchers))
+			m[key] = group
+			keys = append(keys, key)
+		}
+		group[idx] = s
+	}
+	var ssReduced []*series
+	for _, key := range keys {
+		group := m[key]
+		if slices.Contains(group, nil) {
+			// Skip incomplete groups, since reduceFunction cannot be applied to them.
+			continue
+		}
+		reduceArgs := make([]*graphiteql.ArgExpr, len(group))
+		for i, s := range group {
+			reduceArgs[i] = &graphiteql.ArgExpr{
+				Expr: &seriesExpr{
+					s: s,
+				},
+			}
+		}
+		nextReduced, err := evalFuncExpr(ec, &graphiteql.FuncExpr{
+			FuncName: reduceFunction,
+			Args:     reduceArgs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		ssGroup, err := fetchAllSeries(nextReduced)
+		if err != nil {
+			return nil, err
+		}
+		if len(ssGroup) == 0 {
+			continue
+		}
+		s := ssGroup[0]
+		s.Name = key
+		s.expr = fe
+		ssReduced = append(ssReduced, s)
+	}
+	return multiSeriesFunc(ssReduced), nil
+}
+
+// seriesExpr is an expression, which evaluates to already calculated series.
+//
+// It is used for passing series to transform functions.
+type seriesExpr struct {
+	s *series
+}
+
+// AppendString appends the series name to dst and returns the result.
+func (se *seriesExpr) AppendString(dst []byte) []byte {
+	return append(dst, se.s.Name...)
+}
+
 // See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.removeAbovePercentile
 func transformRemoveAbovePercentile(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, error) {
 	args := fe.Args
@@ -4168,6 +4471,51 @@ func transformUnique(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, e
 	return nextSeriesGroup(uniqSeries, fe), nil
 }
 
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.toLowerCase
+func transformToLowerCase(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, error) {
+	return transformChangeCase(ec, fe, unicode.ToLower)
+}
+
+// See https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.toUpperCase
+func transformToUpperCase(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, error) {
+	return transformChangeCase(ec, fe, unicode.ToUpper)
+}
+
+func transformChangeCase(ec *evalConfig, fe *graphiteql.FuncExpr, changeCase func(r rune) rune) (nextSeriesFunc, error) {
+	args := fe.Args
+	if len(args) < 1 {
+		return nil, fmt.Errorf("unexpected number of args; got %d; want at least 1", len(args))
+	}
+	positions, err := getInts(args[1:], "pos")
+	if err != nil {
+		return nil, err
+	}
+	nextSeries, err := evalSeriesList(ec, args, "seriesList", 0)
+	if err != nil {
+		return nil, err
+	}
+	f := nextSeriesSerialWrapper(nextSeries, func(s *series) (*series, error) {
+		if len(positions) == 0 {
+			s.Name = strings.Map(changeCase, s.Name)
+		} else {
+			rs := []rune(s.Name)
+			for _, pos := range positions {
+				if pos < 0 {
+					// Negative positions are counted from the end of the name in the same way as Graphite does.
+					pos += len(rs)
+				}
+				if pos >= 0 && pos < len(rs) {
+					rs[pos] = changeCase(rs[pos])
+				}
+			}
+			s.Name = string(rs)
+		}
+		s.expr = fe
+		return s, nil
+	})
+	return f, nil
+}
+
 // https://graphite.readthedocs.io/en/stable/functions.html#graphite.render.functions.transformNull
 func transformTransformNull(ec *evalConfig, fe *graphiteql.FuncExpr) (nextSeriesFunc, error) {
 	args := fe.Args
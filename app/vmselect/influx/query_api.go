@@ -0,0 +1,787 @@
+package influx
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/metricsql"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/influxql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/prometheus"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/quota"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bufferedwriter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/influxutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+var (
+	measurementFieldSeparator = flag.String("search.influxMeasurementFieldSeparator", "_", "Separator between measurement and field names in metric names, "+
+		"which is used for translating InfluxQL queries at /influx/query. It must match -influxMeasurementFieldSeparator value used during data ingestion. "+
+		"See https://docs.victoriametrics.com/victoriametrics/integrations/influxdb/#querying-via-influxql")
+	dbLabel = flag.String("search.influxDBLabel", "", "Optional label name for filtering series by the database name passed via 'db' query arg "+
+		"or via FROM clause in InfluxQL queries at /influx/query. It must match -influxDBLabel value used during data ingestion. "+
+		"Database names are ignored if this flag is empty. See https://docs.victoriametrics.com/victoriametrics/integrations/influxdb/#querying-via-influxql")
+	maxPointsPerSeries = flag.Int("search.influxMaxPointsPerSeries", 30e3, "The maximum number of points per series, which can be returned "+
+		"from InfluxQL SELECT queries with GROUP BY time() at /influx/query")
+	maxInfluxTagKeys   = flag.Int("search.maxInfluxTagKeys", 100e3, "The maximum number of tag keys returned from InfluxQL SHOW TAG KEYS queries at /influx/query")
+	maxInfluxTagValues = flag.Int("search.maxInfluxTagValues", 100e3, "The maximum number of tag values, measurements or field keys returned "+
+		"from InfluxQL SHOW queries at /influx/query")
+)
+
+// QueryHandler implements /influx/query handler.
+//
+// See https://docs.influxdata.com/influxdb/v1/tools/api/#query-http-endpoint
+func QueryHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	q := r.FormValue("q")
+	if q == "" {
+		return fmt.Errorf("missing query arg `q`")
+	}
+	epoch := r.FormValue("epoch")
+	switch epoch {
+	case "", "ns", "u", "µ", "ms", "s", "m", "h":
+	default:
+		return fmt.Errorf("unsupported `epoch` query arg: %q; supported values: ns, u, µ, ms, s, m, h", epoch)
+	}
+	stmts, err := influxql.Parse(q)
+	if err != nil {
+		return err
+	}
+	etfs, err := searchutil.GetExtraTagFilters(r)
+	if err != nil {
+		return err
+	}
+	qc := &queryContext{
+		qt:       qt,
+		r:        r,
+		deadline: searchutil.GetDeadlineForQuery(r, startTime),
+		now:      startTime.UnixNano() / 1e6,
+		db:       r.FormValue("db"),
+		etfs:     etfs,
+		mayCache: !httputil.GetBool(r, "nocache"),
+		tenant:   quota.GetTenant(r),
+	}
+	results := make([]*statementResult, len(stmts))
+	for i, stmt := range stmts {
+		sr, err := qc.execStatement(stmt)
+		if err != nil {
+			sr = &statementResult{
+				Err: err.Error(),
+			}
+		}
+		sr.StatementID = i
+		results[i] = sr
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteQueryResponse(bw, results, epoch)
+	return bw.Flush()
+}
+
+type statementResult struct {
+	StatementID int
+	Series      []*series
+	Err         string
+}
+
+// series is a single series in the response for InfluxQL statement.
+//
+// Either Rows or StringRows must be set.
+type series struct {
+	Name    string
+	Tags    []tag
+	Columns []string
+
+	// Rows contains rows for SELECT statements.
+	Rows []row
+
+	// StringRows contains rows for SHOW statements.
+	StringRows [][]string
+}
+
+type tag struct {
+	Key   string
+	Value string
+}
+
+type row struct {
+	Timestamp int64
+	Values    []float64
+}
+
+type queryContext struct {
+	qt       *querytracer.Tracer
+	r        *http.Request
+	deadline searchutil.Deadline
+	now      int64
+	db       string
+	etfs     [][]storage.TagFilter
+	mayCache bool
+	tenant   *quota.Tenant
+}
+
+func (qc *queryContext) execStatement(stmt influxql.Statement) (*statementResult, error) {
+	qt := qc.qt.NewChild("execute %s", stmt.AppendString(nil))
+	defer qt.Done()
+	qcLocal := *qc
+	qcLocal.qt = qt
+	qc = &qcLocal
+
+	var ss []*series
+	var err error
+	switch stmt := stmt.(type) {
+	case *influxql.SelectStatement:
+		ss, err = qc.execSelect(stmt)
+	case *influxql.ShowDatabasesStatement:
+		ss, err = qc.execShowDatabases()
+	case *influxql.ShowRetentionPoliciesStatement:
+		ss = []*series{{
+			Columns:    []string{"name", "duration", "shardGroupDuration", "replicaN", "default"},
+			StringRows: [][]string{{"autogen", "0s", "168h0m0s", "1", "true"}},
+		}}
+	case *influxql.ShowMeasurementsStatement:
+		ss, err = qc.execShowMeasurements(stmt)
+	case *influxql.ShowTagKeysStatement:
+		ss, err = qc.execShowTagKeys(stmt)
+	case *influxql.ShowTagValuesStatement:
+		ss, err = qc.execShowTagValues(stmt)
+	case *influxql.ShowFieldKeysStatement:
+		ss, err = qc.execShowFieldKeys(stmt)
+	default:
+		err = fmt.Errorf("BUG: unexpected statement type %T", stmt)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &statementResult{
+		Series: ss,
+	}, nil
+}
+
+// dbFilters returns label filters for the given database name.
+func (qc *queryContext) dbFilters(db string) []metricsql.LabelFilter {
+	if db == "" {
+		db = qc.db
+	}
+	if *dbLabel == "" || db == "" {
+		return nil
+	}
+	return []metricsql.LabelFilter{{
+		Label: *dbLabel,
+		Value: db,
+	}}
+}
+
+func (qc *queryContext) execSelect(ss *influxql.SelectStatement) ([]*series, error) {
+	start, end, hasStart := ss.TimeConditions.TimeRange(qc.now)
+	if end < start {
+		return nil, nil
+	}
+	interval := ss.GroupByInterval
+	// shift is the difference between the timestamps returned from MetricsQL and the timestamps for InfluxQL buckets.
+	// MetricsQL returns the timestamp at the end of the lookbehind window, while InfluxQL returns the timestamp at the start of the bucket.
+	var evalStart, evalEnd, step, shift int64
+	if interval > 0 {
+		if !hasStart {
+			return nil, fmt.Errorf("GROUP BY time() requires a lower time bound in WHERE clause such as `WHERE time > now() - 1h`")
+		}
+		offset := ss.GroupByOffset
+		bucketStart := start - positiveMod(start-offset, interval)
+		bucketEnd := end - positiveMod(end-offset, interval)
+		if err := promql.ValidateMaxPointsPerSeries(bucketStart, bucketEnd, interval, *maxPointsPerSeries); err != nil {
+			return nil, fmt.Errorf("%w; reduce the time range or increase GROUP BY time() interval (see also -search.influxMaxPointsPerSeries command-line flag)", err)
+		}
+		shift = interval - 1
+		evalStart = bucketStart + shift
+		evalEnd = bucketEnd + shift
+		step = interval
+	} else {
+		// Aggregate all the samples on the selected time range into a single point.
+		interval = end - start + 1
+		shift = end - start
+		evalStart = end
+		evalEnd = end
+		step = interval
+	}
+
+	var result []*series
+	for _, m := range ss.Sources {
+		tr, err := newTranslator(ss, m.Name, *measurementFieldSeparator, qc.dbFilters(m.Database), interval)
+		if err != nil {
+			return nil, err
+		}
+		sm := newSeriesMerger(len(ss.Fields), evalStart, evalEnd, step)
+		for i, f := range ss.Fields {
+			q, err := tr.translateField(f)
+			if err != nil {
+				return nil, err
+			}
+			qc.qt.Printf("field %s is translated into %s", f.Name(), q)
+			ec := qc.newEvalConfig(evalStart, evalEnd, step)
+			ec.QueryStats = promql.NewQueryStats(q, nil, ec)
+			rs, err := promql.Exec(qc.qt, ec, q, false)
+			if err != nil {
+				return nil, fmt.Errorf("cannot execute %s: %w", q, err)
+			}
+			for j := range rs {
+				sm.add(i, &rs[j], ss)
+			}
+		}
+		result = append(result, sm.series(m.Name, ss, shift)...)
+	}
+	if ss.SOffset > 0 {
+		if ss.SOffset >= len(result) {
+			return nil, nil
+		}
+		result = result[ss.SOffset:]
+	}
+	if ss.SLimit > 0 && ss.SLimit < len(result) {
+		result = result[:ss.SLimit]
+	}
+	return result, nil
+}
+
+func (qc *queryContext) newEvalConfig(start, end, step int64) *promql.EvalConfig {
+	return &promql.EvalConfig{
+		Start:              start,
+		End:                end,
+		Step:               step,
+		MaxPointsPerSeries: *maxPointsPerSeries,
+		MaxSeries:          qc.tenant.MaxSeries(prometheus.GetMaxUniqueTimeSeries()),
+		QuotedRemoteAddr:   httpserver.GetQuotedRemoteAddr(qc.r),
+		Deadline:           qc.deadline,
+		MayCache:           qc.mayCache,
+		RoundDigits:        100,
+
+		EnforcedTagFilterss: qc.etfs,
+		GetRequestURI: func() string {
+			return httpserver.GetRequestURI(qc.r)
+		},
+		Tenant: qc.tenant,
+	}
+}
+
+func positiveMod(a, b int64) int64 {
+	n := a % b
+	if n < 0 {
+		n += b
+	}
+	return n
+}
+
+// seriesMerger merges results for distinct fields into series with the same group key.
+type seriesMerger struct {
+	fields int
+	start  int64
+	step   int64
+	points int
+
+	m    map[string]*mergedSeries
+	keys []string
+}
+
+type mergedSeries struct {
+	tags []tag
+
+	// values contains per-field values for all the points on the selected time range.
+	values [][]float64
+}
+
+func newSeriesMerger(fields int, start, end, step int64) *seriesMerger {
+	return &seriesMerger{
+		fields: fields,
+		start:  start,
+		step:   step,
+		points: int((end-start)/step) + 1,
+		m:      make(map[string]*mergedSeries),
+	}
+}
+
+func (sm *seriesMerger) add(fieldIdx int, r *netstorage.Result, ss *influxql.SelectStatement) {
+	tags := groupTags(&r.MetricName, ss)
+	var b []byte
+	for _, t := range tags {
+		b = append(b, t.Key...)
+		b = append(b, 0)
+		b = append(b, t.Value...)
+		b = append(b, 0)
+	}
+	key := string(b)
+	ms := sm.m[key]
+	if ms == nil {
+		values := make([][]float64, sm.fields)
+		for i := range values {
+			vs := make([]float64, sm.points)
+			for j := range vs {
+				vs[j] = math.NaN()
+			}
+			values[i] = vs
+		}
+		ms = &mergedSeries{
+			tags:   tags,
+			values: values,
+		}
+		sm.m[key] = ms
+		sm.keys = append(sm.keys, key)
+	}
+	vs := ms.values[fieldIdx]
+	for i, ts := range r.Timestamps {
+		n := (ts - sm.start) / sm.step
+		if n < 0 || n >= int64(len(vs)) {
+			continue
+		}
+		vs[n] = r.Values[i]
+	}
+}
+
+// groupTags returns tags for the series in InfluxQL response according to GROUP BY clause.
+func groupTags(mn *storage.MetricName, ss *influxql.SelectStatement) []tag {
+	var tags []tag
+	if ss.GroupByAllTags {
+		for _, t := range mn.Tags {
+			if string(t.Key) == *dbLabel {
+				continue
+			}
+			tags = append(tags, tag{
+				Key:   string(t.Key),
+				Value: string(t.Value),
+			})
+		}
+	} else {
+		for _, key := range ss.GroupByTags {
+			tags = append(tags, tag{
+				Key:   key,
+				Value: string(mn.GetTagValue(key)),
+			})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Key < tags[j].Key
+	})
+	return tags
+}
+
+func (sm *seriesMerger) series(name string, ss *influxql.SelectStatement, shift int64) []*series {
+	columns := selectColumns(ss.Fields)
+	isGrouped := ss.GroupByAllTags || len(ss.GroupByTags) > 0
+	sort.Strings(sm.keys)
+	result := make([]*series, 0, len(sm.keys))
+	for _, key := range sm.keys {
+		ms := sm.m[key]
+		for _, vs := range ms.values {
+			fillValues(vs, ss.Fill)
+		}
+		rows := make([]row, 0, sm.points)
+		for i := 0; i < sm.points; i++ {
+			values := make([]float64, len(ms.values))
+			hasValues := false
+			for j, vs := range ms.values {
+				values[j] = vs[i]
+				if !math.IsNaN(vs[i]) {
+					hasValues = true
+				}
+			}
+			if !hasValues && ss.Fill.Mode == influxql.FillNone {
+				continue
+			}
+			rows = append(rows, row{
+				Timestamp: sm.start + int64(i)*sm.step - shift,
+				Values:    values,
+			})
+		}
+		if ss.OrderDesc {
+			for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+				rows[i], rows[j] = rows[j], rows[i]
+			}
+		}
+		if ss.Offset > 0 {
+			if ss.Offset >= len(rows) {
+				continue
+			}
+			rows = rows[ss.Offset:]
+		}
+		if ss.Limit > 0 && ss.Limit < len(rows) {
+			rows = rows[:ss.Limit]
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		s := &series{
+			Name:    name,
+			Columns: columns,
+			Rows:    rows,
+		}
+		if isGrouped {
+			s.Tags = ms.tags
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// selectColumns returns column names for the given fields.
+//
+// Duplicate names get numeric suffixes in the same way as InfluxDB does, e.g. mean, mean_1.
+func selectColumns(fields []*influxql.Field) []string {
+	columns := []string{"time"}
+	seen := make(map[string]int)
+	for _, f := range fields {
+		name := f.Name()
+		n := seen[name]
+		seen[name] = n + 1
+		if n > 0 {
+			name = fmt.Sprintf("%s_%d", name, n)
+		}
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+// fillValues fills missing values in vs according to f.
+func fillValues(vs []float64, f influxql.Fill) {
+	switch f.Mode {
+	case influxql.FillValue:
+		for i, v := range vs {
+			if math.IsNaN(v) {
+				vs[i] = f.Value
+			}
+		}
+	case influxql.FillPrevious:
+		prev := math.NaN()
+		for i, v := range vs {
+			if math.IsNaN(v) {
+				vs[i] = prev
+			} else {
+				prev = v
+			}
+		}
+	case influxql.FillLinear:
+		prevIdx := -1
+		for i, v := range vs {
+			if math.IsNaN(v) {
+				continue
+			}
+			if prevIdx >= 0 && i-prevIdx > 1 {
+				prev := vs[prevIdx]
+				d := (v - prev) / float64(i-prevIdx)
+				for j := prevIdx + 1; j < i; j++ {
+					vs[j] = prev + d*float64(j-prevIdx)
+				}
+			}
+			prevIdx = i
+		}
+	}
+}
+
+func (qc *queryContext) execShowDatabases() ([]*series, error) {
+	var dbs []string
+	if *dbLabel != "" {
+		sq := storage.NewSearchQuery(0, qc.now, qc.etfs, 0)
+		values, err := netstorage.LabelValues(qc.qt, *dbLabel, sq, *maxInfluxTagValues, qc.deadline)
+		if err != nil {
+			return nil, err
+		}
+		dbs = values
+	} else {
+		dbs = influxutil.DatabaseNames()
+	}
+	return []*series{{
+		Name:       "databases",
+		Columns:    []string{"name"},
+		StringRows: toStringRows(dbs),
+	}}, nil
+}
+
+func (qc *queryContext) execShowMeasurements(sms *influxql.ShowMeasurementsStatement) ([]*series, error) {
+	measurements, err := qc.getMeasurements(sms.Database, sms.Condition, sms.TimeConditions)
+	if err != nil {
+		return nil, err
+	}
+	if sms.MeasurementFilter != nil {
+		filtered := measurements[:0]
+		for _, m := range measurements {
+			if sms.MeasurementFilter.Match(m) {
+				filtered = append(filtered, m)
+			}
+		}
+		measurements = filtered
+	}
+	measurements = applyLimitOffset(measurements, sms.Limit, sms.Offset)
+	if len(measurements) == 0 {
+		return nil, nil
+	}
+	return []*series{{
+		Name:       "measurements",
+		Columns:    []string{"name"},
+		StringRows: toStringRows(measurements),
+	}}, nil
+}
+
+// getMeasurements returns sorted measurement names for series matching the given db and cond.
+//
+// The measurement name is obtained from the metric name by cutting it at the first -search.influxMeasurementFieldSeparator,
+// since it is impossible to determine the measurement name for metric names with multiple separators.
+func (qc *queryContext) getMeasurements(db string, cond influxql.Expr, tcs influxql.TimeConditions) ([]string, error) {
+	sep := *measurementFieldSeparator
+	nameFilter := metricsql.LabelFilter{
+		Label:    "__name__",
+		Value:    ".+" + regexp.QuoteMeta(sep) + ".+",
+		IsRegexp: true,
+	}
+	names, err := qc.labelValues("__name__", db, nameFilter, cond, tcs)
+	if err != nil {
+		return nil, err
+	}
+	var measurements []string
+	for _, name := range names {
+		n := strings.Index(name, sep)
+		if n <= 0 {
+			continue
+		}
+		measurements = append(measurements, name[:n])
+	}
+	sort.Strings(measurements)
+	return uniqueStrings(measurements), nil
+}
+
+func (qc *queryContext) getSourceMeasurements(db string, sources []*influxql.Measurement, cond influxql.Expr, tcs influxql.TimeConditions) ([]*influxql.Measurement, error) {
+	if len(sources) > 0 {
+		return sources, nil
+	}
+	measurements, err := qc.getMeasurements(db, cond, tcs)
+	if err != nil {
+		return nil, err
+	}
+	sources = make([]*influxql.Measurement, len(measurements))
+	for i, m := range measurements {
+		sources[i] = &influxql.Measurement{
+			Database: db,
+			Name:     m,
+		}
+	}
+	return sources, nil
+}
+
+func (qc *queryContext) execShowTagKeys(stks *influxql.ShowTagKeysStatement) ([]*series, error) {
+	sources, err := qc.getSourceMeasurements(stks.Database, stks.Sources, stks.Condition, stks.TimeConditions)
+	if err != nil {
+		return nil, err
+	}
+	var result []*series
+	for _, m := range sources {
+		keys, err := qc.tagKeys(m, stks.Database, stks.Condition, stks.TimeConditions)
+		if err != nil {
+			return nil, err
+		}
+		keys = applyLimitOffset(keys, stks.Limit, stks.Offset)
+		if len(keys) == 0 {
+			continue
+		}
+		result = append(result, &series{
+			Name:       m.Name,
+			Columns:    []string{"tagKey"},
+			StringRows: toStringRows(keys),
+		})
+	}
+	return result, nil
+}
+
+func (qc *queryContext) execShowTagValues(stvs *influxql.ShowTagValuesStatement) ([]*series, error) {
+	sources, err := qc.getSourceMeasurements(stvs.Database, stvs.Sources, stvs.Condition, stvs.TimeConditions)
+	if err != nil {
+		return nil, err
+	}
+	var result []*series
+	for _, m := range sources {
+		keys, err := qc.tagKeys(m, stvs.Database, stvs.Condition, stvs.TimeConditions)
+		if err != nil {
+			return nil, err
+		}
+		db := m.Database
+		if db == "" {
+			db = stvs.Database
+		}
+		var rows [][]string
+		for _, key := range keys {
+			if !stvs.KeyFilter.Match(key) {
+				continue
+			}
+			values, err := qc.labelValues(key, db, metricNameFilter(m.Name+*measurementFieldSeparator), stvs.Condition, stvs.TimeConditions)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range values {
+				rows = append(rows, []string{key, v})
+			}
+		}
+		rows = applyLimitOffset(rows, stvs.Limit, stvs.Offset)
+		if len(rows) == 0 {
+			continue
+		}
+		result = append(result, &series{
+			Name:       m.Name,
+			Columns:    []string{"key", "value"},
+			StringRows: rows,
+		})
+	}
+	return result, nil
+}
+
+func (qc *queryContext) execShowFieldKeys(sfks *influxql.ShowFieldKeysStatement) ([]*series, error) {
+	sources, err := qc.getSourceMeasurements(sfks.Database, sfks.Sources, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var result []*series
+	for _, m := range sources {
+		db := m.Database
+		if db == "" {
+			db = sfks.Database
+		}
+		prefix := m.Name + *measurementFieldSeparator
+		names, err := qc.labelValues("__name__", db, metricNameFilter(prefix), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		names = applyLimitOffset(names, sfks.Limit, sfks.Offset)
+		if len(names) == 0 {
+			continue
+		}
+		rows := make([][]string, len(names))
+		for i, name := range names {
+			rows[i] = []string{strings.TrimPrefix(name, prefix), "float"}
+		}
+		result = append(result, &series{
+			Name:       m.Name,
+			Columns:    []string{"fieldKey", "fieldType"},
+			StringRows: rows,
+		})
+	}
+	return result, nil
+}
+
+// tagKeys returns sorted tag keys for the given measurement m.
+func (qc *queryContext) tagKeys(m *influxql.Measurement, db string, cond influxql.Expr, tcs influxql.TimeConditions) ([]string, error) {
+	if m.Database != "" {
+		db = m.Database
+	}
+	sq, err := qc.newSearchQuery(db, metricNameFilter(m.Name+*measurementFieldSeparator), cond, tcs)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := netstorage.LabelNames(qc.qt, sq, *maxInfluxTagKeys, qc.deadline)
+	if err != nil {
+		return nil, err
+	}
+	keys := labels[:0]
+	for _, label := range labels {
+		if label == "__name__" || label == *dbLabel {
+			continue
+		}
+		keys = append(keys, label)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (qc *queryContext) labelValues(labelName, db string, nameFilter metricsql.LabelFilter, cond influxql.Expr, tcs influxql.TimeConditions) ([]string, error) {
+	sq, err := qc.newSearchQuery(db, nameFilter, cond, tcs)
+	if err != nil {
+		return nil, err
+	}
+	return netstorage.LabelValues(qc.qt, labelName, sq, *maxInfluxTagValues, qc.deadline)
+}
+
+// newSearchQuery returns search query for series matching the given nameFilter, db and cond on the time range from tcs.
+//
+// The time range covers all the data if tcs is empty.
+func (qc *queryContext) newSearchQuery(db string, nameFilter metricsql.LabelFilter, cond influxql.Expr, tcs influxql.TimeConditions) (*storage.SearchQuery, error) {
+	lfss, err := conditionToLabelFilterss(cond)
+	if err != nil {
+		return nil, err
+	}
+	dbFilters := qc.dbFilters(db)
+	for i, lfs := range lfss {
+		lfsNew := make([]metricsql.LabelFilter, 0, len(lfs)+1+len(dbFilters))
+		lfsNew = append(lfsNew, nameFilter)
+		lfsNew = append(lfsNew, lfs...)
+		lfsNew = append(lfsNew, dbFilters...)
+		lfss[i] = lfsNew
+	}
+	tfss := searchutil.JoinTagFilterss(searchutil.ToTagFilterss(lfss), qc.etfs)
+	start, end, _ := tcs.TimeRange(qc.now)
+	return storage.NewSearchQuery(start, end, tfss, 0), nil
+}
+
+func applyLimitOffset[T any](a []T, limit, offset int) []T {
+	if offset > 0 {
+		if offset >= len(a) {
+			return nil
+		}
+		a = a[offset:]
+	}
+	if limit > 0 && limit < len(a) {
+		a = a[:limit]
+	}
+	return a
+}
+
+func toStringRows(a []string) [][]string {
+	rows := make([][]string, len(a))
+	for i, s := range a {
+		rows[i] = []string{s}
+	}
+	return rows
+}
+
+func uniqueStrings(a []string) []string {
+	if len(a) == 0 {
+		return a
+	}
+	result := a[:1]
+	for _, s := range a[1:] {
+		if s != result[len(result)-1] {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// epochTimestamp converts timestamp in milliseconds to the given epoch precision.
+func epochTimestamp(ts int64, epoch string) int64 {
+	switch epoch {
+	case "ns":
+		return ts * 1e6
+	case "u", "µ":
+		return ts * 1e3
+	case "s":
+		return ts / 1e3
+	case "m":
+		return ts / 60e3
+	case "h":
+		return ts / 3600e3
+	default:
+		return ts
+	}
+}
+
+func formatTime(ts int64) string {
+	return time.UnixMilli(ts).UTC().Format(time.RFC3339Nano)
+}
+
+func isNullValue(v float64) bool {
+	return math.IsNaN(v) || math.IsInf(v, 0)
+}
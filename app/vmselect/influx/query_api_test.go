@@ -0,0 +1,65 @@
+package influx
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/influxql"
+)
+
+func TestFillValues(t *testing.T) {
+	nan := math.NaN()
+	f := func(vs []float64, fill influxql.Fill, resultExpected []float64) {
+		t.Helper()
+		fillValues(vs, fill)
+		for i := range vs {
+			v, vExpected := vs[i], resultExpected[i]
+			if math.IsNaN(v) && math.IsNaN(vExpected) {
+				continue
+			}
+			if v != vExpected {
+				t.Fatalf("unexpected result;\ngot\n%v\nwant\n%v", vs, resultExpected)
+			}
+		}
+	}
+	f([]float64{nan, 1, nan, 3}, influxql.Fill{Mode: influxql.FillNull}, []float64{nan, 1, nan, 3})
+	f([]float64{nan, 1, nan, 3}, influxql.Fill{Mode: influxql.FillNone}, []float64{nan, 1, nan, 3})
+	f([]float64{nan, 1, nan, 3}, influxql.Fill{Mode: influxql.FillValue, Value: -1}, []float64{-1, 1, -1, 3})
+	f([]float64{nan, 1, nan, nan, 3, nan}, influxql.Fill{Mode: influxql.FillPrevious}, []float64{nan, 1, 1, 1, 3, 3})
+	f([]float64{nan, 1, nan, nan, 4, nan}, influxql.Fill{Mode: influxql.FillLinear}, []float64{nan, 1, 2, 3, 4, nan})
+}
+
+func TestSelectColumns(t *testing.T) {
+	f := func(s string, columnsExpected []string) {
+		t.Helper()
+		stmts, err := influxql.Parse(s)
+		if err != nil {
+			t.Fatalf("cannot parse %s: %s", s, err)
+		}
+		columns := selectColumns(stmts[0].(*influxql.SelectStatement).Fields)
+		if !reflect.DeepEqual(columns, columnsExpected) {
+			t.Fatalf("unexpected columns for %s; got %q; want %q", s, columns, columnsExpected)
+		}
+	}
+	f(`SELECT mean(value) FROM cpu`, []string{"time", "mean"})
+	f(`SELECT mean(a), mean(b) AS b, mean(c) FROM cpu`, []string{"time", "mean", "b", "mean_1"})
+	f(`SELECT mean(a) / mean(b) FROM cpu`, []string{"time", "mean_mean"})
+}
+
+func TestEpochTimestamp(t *testing.T) {
+	f := func(epoch string, resultExpected int64) {
+		t.Helper()
+		result := epochTimestamp(7_200_123, epoch)
+		if result != resultExpected {
+			t.Fatalf("unexpected timestamp for epoch=%q; got %d; want %d", epoch, result, resultExpected)
+		}
+	}
+	f("ns", 7_200_123_000_000)
+	f("u", 7_200_123_000)
+	f("µ", 7_200_123_000)
+	f("ms", 7_200_123)
+	f("s", 7_200)
+	f("m", 120)
+	f("h", 2)
+}
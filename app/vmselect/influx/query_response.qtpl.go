@@ -0,0 +1,287 @@
+// Code generated by qtc from "query_response.qtpl". DO NOT EDIT.
+// See https://github.com/valyala/quicktemplate for details.
+
+// QueryResponse generates response for /influx/query handler.See https://docs.influxdata.com/influxdb/v1/tools/api/#query-http-endpoint
+
+//line app/vmselect/influx/query_response.qtpl:5
+package influx
+
+//line app/vmselect/influx/query_response.qtpl:5
+import (
+	qtio422016 "io"
+
+	qt422016 "github.com/valyala/quicktemplate"
+)
+
+//line app/vmselect/influx/query_response.qtpl:5
+var (
+	_ = qtio422016.Copy
+	_ = qt422016.AcquireByteBuffer
+)
+
+//line app/vmselect/influx/query_response.qtpl:5
+func StreamQueryResponse(qw422016 *qt422016.Writer, results []*statementResult, epoch string) {
+//line app/vmselect/influx/query_response.qtpl:5
+	qw422016.N().S(`{"results":[`)
+//line app/vmselect/influx/query_response.qtpl:8
+	for i, sr := range results {
+//line app/vmselect/influx/query_response.qtpl:9
+		streamstatementResultJSON(qw422016, sr, epoch)
+//line app/vmselect/influx/query_response.qtpl:10
+		if i+1 < len(results) {
+//line app/vmselect/influx/query_response.qtpl:10
+			qw422016.N().S(`,`)
+//line app/vmselect/influx/query_response.qtpl:10
+		}
+//line app/vmselect/influx/query_response.qtpl:11
+	}
+//line app/vmselect/influx/query_response.qtpl:11
+	qw422016.N().S(`]}`)
+//line app/vmselect/influx/query_response.qtpl:14
+}
+
+//line app/vmselect/influx/query_response.qtpl:14
+func WriteQueryResponse(qq422016 qtio422016.Writer, results []*statementResult, epoch string) {
+//line app/vmselect/influx/query_response.qtpl:14
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/influx/query_response.qtpl:14
+	StreamQueryResponse(qw422016, results, epoch)
+//line app/vmselect/influx/query_response.qtpl:14
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/influx/query_response.qtpl:14
+}
+
+//line app/vmselect/influx/query_response.qtpl:14
+func QueryResponse(results []*statementResult, epoch string) string {
+//line app/vmselect/influx/query_response.qtpl:14
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/influx/query_response.qtpl:14
+	WriteQueryResponse(qb422016, results, epoch)
+//line app/vmselect/influx/query_response.qtpl:14
+	qs422016 := string(qb422016.B)
+//line app/vmselect/influx/query_response.qtpl:14
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/influx/query_response.qtpl:14
+	return qs422016
+//line app/vmselect/influx/query_response.qtpl:14
+}
+
+//line app/vmselect/influx/query_response.qtpl:16
+func streamstatementResultJSON(qw422016 *qt422016.Writer, sr *statementResult, epoch string) {
+//line app/vmselect/influx/query_response.qtpl:16
+	qw422016.N().S(`{"statement_id":`)
+//line app/vmselect/influx/query_response.qtpl:18
+	qw422016.N().D(sr.StatementID)
+//line app/vmselect/influx/query_response.qtpl:19
+	if sr.Err != "" {
+//line app/vmselect/influx/query_response.qtpl:19
+		qw422016.N().S(`,"error":`)
+//line app/vmselect/influx/query_response.qtpl:20
+		qw422016.N().Q(sr.Err)
+//line app/vmselect/influx/query_response.qtpl:21
+	} else if len(sr.Series) > 0 {
+//line app/vmselect/influx/query_response.qtpl:21
+		qw422016.N().S(`,"series":[`)
+//line app/vmselect/influx/query_response.qtpl:23
+		for i, s := range sr.Series {
+//line app/vmselect/influx/query_response.qtpl:24
+			streamseriesJSON(qw422016, s, epoch)
+//line app/vmselect/influx/query_response.qtpl:25
+			if i+1 < len(sr.Series) {
+//line app/vmselect/influx/query_response.qtpl:25
+				qw422016.N().S(`,`)
+//line app/vmselect/influx/query_response.qtpl:25
+			}
+//line app/vmselect/influx/query_response.qtpl:26
+		}
+//line app/vmselect/influx/query_response.qtpl:26
+		qw422016.N().S(`]`)
+//line app/vmselect/influx/query_response.qtpl:28
+	}
+//line app/vmselect/influx/query_response.qtpl:28
+	qw422016.N().S(`}`)
+//line app/vmselect/influx/query_response.qtpl:30
+}
+
+//line app/vmselect/influx/query_response.qtpl:30
+func writestatementResultJSON(qq422016 qtio422016.Writer, sr *statementResult, epoch string) {
+//line app/vmselect/influx/query_response.qtpl:30
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/influx/query_response.qtpl:30
+	streamstatementResultJSON(qw422016, sr, epoch)
+//line app/vmselect/influx/query_response.qtpl:30
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/influx/query_response.qtpl:30
+}
+
+//line app/vmselect/influx/query_response.qtpl:30
+func statementResultJSON(sr *statementResult, epoch string) string {
+//line app/vmselect/influx/query_response.qtpl:30
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/influx/query_response.qtpl:30
+	writestatementResultJSON(qb422016, sr, epoch)
+//line app/vmselect/influx/query_response.qtpl:30
+	qs422016 := string(qb422016.B)
+//line app/vmselect/influx/query_response.qtpl:30
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/influx/query_response.qtpl:30
+	return qs422016
+//line app/vmselect/influx/query_response.qtpl:30
+}
+
+//line app/vmselect/influx/query_response.qtpl:32
+func streamseriesJSON(qw422016 *qt422016.Writer, s *series, epoch string) {
+//line app/vmselect/influx/query_response.qtpl:32
+	qw422016.N().S(`{`)
+//line app/vmselect/influx/query_response.qtpl:34
+	if s.Name != "" {
+//line app/vmselect/influx/query_response.qtpl:34
+		qw422016.N().S(`"name":`)
+//line app/vmselect/influx/query_response.qtpl:35
+		qw422016.N().Q(s.Name)
+//line app/vmselect/influx/query_response.qtpl:35
+		qw422016.N().S(`,`)
+//line app/vmselect/influx/query_response.qtpl:36
+	}
+//line app/vmselect/influx/query_response.qtpl:37
+	if len(s.Tags) > 0 {
+//line app/vmselect/influx/query_response.qtpl:37
+		qw422016.N().S(`"tags":{`)
+//line app/vmselect/influx/query_response.qtpl:39
+		for i, t := range s.Tags {
+//line app/vmselect/influx/query_response.qtpl:40
+			qw422016.N().Q(t.Key)
+//line app/vmselect/influx/query_response.qtpl:40
+			qw422016.N().S(`:`)
+//line app/vmselect/influx/query_response.qtpl:40
+			qw422016.N().Q(t.Value)
+//line app/vmselect/influx/query_response.qtpl:41
+			if i+1 < len(s.Tags) {
+//line app/vmselect/influx/query_response.qtpl:41
+				qw422016.N().S(`,`)
+//line app/vmselect/influx/query_response.qtpl:41
+			}
+//line app/vmselect/influx/query_response.qtpl:42
+		}
+//line app/vmselect/influx/query_response.qtpl:42
+		qw422016.N().S(`},`)
+//line app/vmselect/influx/query_response.qtpl:44
+	}
+//line app/vmselect/influx/query_response.qtpl:44
+	qw422016.N().S(`"columns":[`)
+//line app/vmselect/influx/query_response.qtpl:46
+	for i, c := range s.Columns {
+//line app/vmselect/influx/query_response.qtpl:47
+		qw422016.N().Q(c)
+//line app/vmselect/influx/query_response.qtpl:48
+		if i+1 < len(s.Columns) {
+//line app/vmselect/influx/query_response.qtpl:48
+			qw422016.N().S(`,`)
+//line app/vmselect/influx/query_response.qtpl:48
+		}
+//line app/vmselect/influx/query_response.qtpl:49
+	}
+//line app/vmselect/influx/query_response.qtpl:49
+	qw422016.N().S(`],"values":[`)
+//line app/vmselect/influx/query_response.qtpl:52
+	if len(s.Rows) > 0 {
+//line app/vmselect/influx/query_response.qtpl:53
+		for i, r := range s.Rows {
+//line app/vmselect/influx/query_response.qtpl:53
+			qw422016.N().S(`[`)
+//line app/vmselect/influx/query_response.qtpl:55
+			if epoch == "" {
+//line app/vmselect/influx/query_response.qtpl:56
+				qw422016.N().Q(formatTime(r.Timestamp))
+//line app/vmselect/influx/query_response.qtpl:57
+			} else {
+//line app/vmselect/influx/query_response.qtpl:58
+				qw422016.N().DL(epochTimestamp(r.Timestamp, epoch))
+//line app/vmselect/influx/query_response.qtpl:59
+			}
+//line app/vmselect/influx/query_response.qtpl:60
+			for _, v := range r.Values {
+//line app/vmselect/influx/query_response.qtpl:60
+				qw422016.N().S(`,`)
+//line app/vmselect/influx/query_response.qtpl:62
+				if isNullValue(v) {
+//line app/vmselect/influx/query_response.qtpl:62
+					qw422016.N().S(`null`)
+//line app/vmselect/influx/query_response.qtpl:64
+				} else {
+//line app/vmselect/influx/query_response.qtpl:65
+					qw422016.N().F(v)
+//line app/vmselect/influx/query_response.qtpl:66
+				}
+//line app/vmselect/influx/query_response.qtpl:67
+			}
+//line app/vmselect/influx/query_response.qtpl:67
+			qw422016.N().S(`]`)
+//line app/vmselect/influx/query_response.qtpl:69
+			if i+1 < len(s.Rows) {
+//line app/vmselect/influx/query_response.qtpl:69
+				qw422016.N().S(`,`)
+//line app/vmselect/influx/query_response.qtpl:69
+			}
+//line app/vmselect/influx/query_response.qtpl:70
+		}
+//line app/vmselect/influx/query_response.qtpl:71
+	} else {
+//line app/vmselect/influx/query_response.qtpl:72
+		for i, sr := range s.StringRows {
+//line app/vmselect/influx/query_response.qtpl:72
+			qw422016.N().S(`[`)
+//line app/vmselect/influx/query_response.qtpl:74
+			for j, v := range sr {
+//line app/vmselect/influx/query_response.qtpl:75
+				qw422016.N().Q(v)
+//line app/vmselect/influx/query_response.qtpl:76
+				if j+1 < len(sr) {
+//line app/vmselect/influx/query_response.qtpl:76
+					qw422016.N().S(`,`)
+//line app/vmselect/influx/query_response.qtpl:76
+				}
+//line app/vmselect/influx/query_response.qtpl:77
+			}
+//line app/vmselect/influx/query_response.qtpl:77
+			qw422016.N().S(`]`)
+//line app/vmselect/influx/query_response.qtpl:79
+			if i+1 < len(s.StringRows) {
+//line app/vmselect/influx/query_response.qtpl:79
+				qw422016.N().S(`,`)
+//line app/vmselect/influx/query_response.qtpl:79
+			}
+//line app/vmselect/influx/query_response.qtpl:80
+		}
+//line app/vmselect/influx/query_response.qtpl:81
+	}
+//line app/vmselect/influx/query_response.qtpl:81
+	qw422016.N().S(`]}`)
+//line app/vmselect/influx/query_response.qtpl:84
+}
+
+//line app/vmselect/influx/query_response.qtpl:84
+func writeseriesJSON(qq422016 qtio422016.Writer, s *series, epoch string) {
+//line app/vmselect/influx/query_response.qtpl:84
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/influx/query_response.qtpl:84
+	streamseriesJSON(qw422016, s, epoch)
+//line app/vmselect/influx/query_response.qtpl:84
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/influx/query_response.qtpl:84
+}
+
+//line app/vmselect/influx/query_response.qtpl:84
+func seriesJSON(s *series, epoch string) string {
+//line app/vmselect/influx/query_response.qtpl:84
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/influx/query_response.qtpl:84
+	writeseriesJSON(qb422016, s, epoch)
+//line app/vmselect/influx/query_response.qtpl:84
+	qs422016 := string(qb422016.B)
+//line app/vmselect/influx/query_response.qtpl:84
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/influx/query_response.qtpl:84
+	return qs422016
+//line app/vmselect/influx/query_response.qtpl:84
+}
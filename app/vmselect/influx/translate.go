@@ -0,0 +1,415 @@
+package influx
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/metricsql"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/influxql"
+)
+
+// maxConditionGroups is the maximum number of OR groups a WHERE condition may expand to.
+const maxConditionGroups = 100
+
+// maxMovingAverageWindow is the maximum number of points for moving_average().
+const maxMovingAverageWindow = 100
+
+// translator converts InfluxQL expressions for a single measurement into MetricsQL queries.
+type translator struct {
+	// measurement is the measurement name from FROM clause.
+	measurement string
+
+	// separator is the separator between measurement and field names in metric names.
+	separator string
+
+	// lfss contains label filters obtained from WHERE clause and the db filter.
+	lfss [][]metricsql.LabelFilter
+
+	// interval is the interval in milliseconds for rollup windows.
+	interval int64
+
+	// groupByAllTags is set to true if the results must be calculated per each series.
+	groupByAllTags bool
+
+	// groupByTags contains tags for grouping the results.
+	groupByTags []string
+}
+
+func newTranslator(ss *influxql.SelectStatement, measurement, separator string, extraFilters []metricsql.LabelFilter, interval int64) (*translator, error) {
+	lfss, err := conditionToLabelFilterss(ss.Condition)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lfss {
+		lfss[i] = append(lfss[i], extraFilters...)
+	}
+	return &translator{
+		measurement:    measurement,
+		separator:      separator,
+		lfss:           lfss,
+		interval:       interval,
+		groupByAllTags: ss.GroupByAllTags,
+		groupByTags:    ss.GroupByTags,
+	}, nil
+}
+
+// translateField returns MetricsQL query for the given field from SELECT clause.
+func (t *translator) translateField(f *influxql.Field) (string, error) {
+	q, err := t.translateExpr(f.Expr, 0)
+	if err != nil {
+		return "", fmt.Errorf("cannot translate %s: %w", f.AppendString(nil), err)
+	}
+	return q, nil
+}
+
+// translateExpr returns MetricsQL query for e, which is shifted back in time by offset milliseconds.
+func (t *translator) translateExpr(e influxql.Expr, offset int64) (string, error) {
+	switch e := e.(type) {
+	case *influxql.NumberExpr:
+		return string(e.AppendString(nil)), nil
+	case *influxql.BinaryExpr:
+		switch e.Op {
+		case "+", "-", "*", "/", "%":
+		default:
+			return "", fmt.Errorf("unsupported operator %q in SELECT clause", e.Op)
+		}
+		left, err := t.translateExpr(e.Left, offset)
+		if err != nil {
+			return "", err
+		}
+		right, err := t.translateExpr(e.Right, offset)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, e.Op, right), nil
+	case *influxql.CallExpr:
+		if isTransformFunc(e.Name) {
+			return t.translateTransformFunc(e, offset)
+		}
+		return t.translateAggrFunc(e, offset)
+	case *influxql.VarRef:
+		return "", fmt.Errorf("raw field %q must be wrapped into an aggregate function such as mean(%s); raw queries aren't supported", e.Name, e.Name)
+	case *influxql.Wildcard:
+		return "", fmt.Errorf("wildcards aren't supported in SELECT clause")
+	default:
+		return "", fmt.Errorf("unsupported expression in SELECT clause: %s", e.AppendString(nil))
+	}
+}
+
+func isTransformFunc(name string) bool {
+	switch name {
+	case "derivative", "non_negative_derivative", "difference", "non_negative_difference", "cumulative_sum", "moving_average":
+		return true
+	default:
+		return false
+	}
+}
+
+// translateTransformFunc translates InfluxQL transformation function such as derivative(mean(value), 1s).
+//
+// Transformations are applied to the results of aggregate functions at adjacent intervals.
+// The previous interval is obtained by shifting the aggregate function back in time by the interval.
+func (t *translator) translateTransformFunc(ce *influxql.CallExpr, offset int64) (string, error) {
+	if len(ce.Args) == 0 {
+		return "", fmt.Errorf("missing args for %s()", ce.Name)
+	}
+	if _, ok := ce.Args[0].(*influxql.CallExpr); !ok {
+		return "", fmt.Errorf("the first arg for %s() must be an aggregate function such as mean(value); got %s", ce.Name, ce.Args[0].AppendString(nil))
+	}
+	curr, err := t.translateExpr(ce.Args[0], offset)
+	if err != nil {
+		return "", err
+	}
+	switch ce.Name {
+	case "cumulative_sum":
+		if len(ce.Args) != 1 {
+			return "", fmt.Errorf("unexpected number of args for cumulative_sum(); got %d; want 1", len(ce.Args))
+		}
+		return fmt.Sprintf("running_sum(%s)", curr), nil
+	case "moving_average":
+		if len(ce.Args) != 2 {
+			return "", fmt.Errorf("unexpected number of args for moving_average(); got %d; want 2", len(ce.Args))
+		}
+		ne, ok := ce.Args[1].(*influxql.NumberExpr)
+		if !ok || ne.N < 1 || ne.N > maxMovingAverageWindow || ne.N != float64(int(ne.N)) {
+			return "", fmt.Errorf("the second arg for moving_average() must be an integer in the range [1 ... %d]; got %s", maxMovingAverageWindow, ce.Args[1].AppendString(nil))
+		}
+		n := int(ne.N)
+		items := []string{curr}
+		for i := 1; i < n; i++ {
+			prev, err := t.translateExpr(ce.Args[0], offset+int64(i)*t.interval)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, prev)
+		}
+		return fmt.Sprintf("((%s) / %d)", strings.Join(items, " + "), n), nil
+	}
+
+	prev, err := t.translateExpr(ce.Args[0], offset+t.interval)
+	if err != nil {
+		return "", err
+	}
+	diff := fmt.Sprintf("(%s - %s)", curr, prev)
+	switch ce.Name {
+	case "difference", "non_negative_difference":
+		if len(ce.Args) != 1 {
+			return "", fmt.Errorf("unexpected number of args for %s(); got %d; want 1", ce.Name, len(ce.Args))
+		}
+	default:
+		// derivative and non_negative_derivative
+		unit := int64(1000)
+		switch len(ce.Args) {
+		case 1:
+		case 2:
+			de, ok := ce.Args[1].(*influxql.DurationExpr)
+			if !ok || de.Msecs <= 0 {
+				return "", fmt.Errorf("the second arg for %s() must be a positive duration; got %s", ce.Name, ce.Args[1].AppendString(nil))
+			}
+			unit = de.Msecs
+		default:
+			return "", fmt.Errorf("unexpected number of args for %s(); got %d; want 1 or 2", ce.Name, len(ce.Args))
+		}
+		diff = fmt.Sprintf("(%s / %s)", diff, formatFloat(float64(t.interval)/float64(unit)))
+	}
+	if strings.HasPrefix(ce.Name, "non_negative_") {
+		return fmt.Sprintf("(%s >= 0)", diff), nil
+	}
+	return diff, nil
+}
+
+// translateAggrFunc translates InfluxQL aggregate function such as mean(value).
+//
+// count(), sum(), mean(), min(), max() and spread() are calculated over all the samples
+// for series in the group. Other functions are calculated per each series
+// and then the results are averaged across series in the group.
+func (t *translator) translateAggrFunc(ce *influxql.CallExpr, offset int64) (string, error) {
+	var arg string
+	switch ce.Name {
+	case "percentile":
+		if len(ce.Args) != 2 {
+			return "", fmt.Errorf("unexpected number of args for percentile(); got %d; want 2", len(ce.Args))
+		}
+		ne, ok := ce.Args[1].(*influxql.NumberExpr)
+		if !ok || ne.N < 0 || ne.N > 100 {
+			return "", fmt.Errorf("the second arg for percentile() must be a number in the range [0 ... 100]; got %s", ce.Args[1].AppendString(nil))
+		}
+		arg = formatFloat(ne.N / 100)
+	default:
+		if len(ce.Args) != 1 {
+			return "", fmt.Errorf("unexpected number of args for %s(); got %d; want 1", ce.Name, len(ce.Args))
+		}
+	}
+	vr, ok := ce.Args[0].(*influxql.VarRef)
+	if !ok {
+		return "", fmt.Errorf("the first arg for %s() must be a field name; got %s", ce.Name, ce.Args[0].AppendString(nil))
+	}
+	if vr.Type == "tag" {
+		return "", fmt.Errorf("cannot apply %s() to tag %q", ce.Name, vr.Name)
+	}
+	rollupArg := t.rollupArg(vr.Name, offset)
+
+	var perSeries string
+	switch ce.Name {
+	case "count", "sum", "min", "max", "median", "first", "last":
+		perSeries = fmt.Sprintf("%s_over_time(%s)", ce.Name, rollupArg)
+	case "mean":
+		perSeries = fmt.Sprintf("avg_over_time(%s)", rollupArg)
+	case "mode":
+		perSeries = fmt.Sprintf("mode_over_time(%s)", rollupArg)
+	case "spread":
+		perSeries = fmt.Sprintf("range_over_time(%s)", rollupArg)
+	case "stddev":
+		// InfluxDB returns sample standard deviation, while stddev_over_time returns population standard deviation.
+		perSeries = fmt.Sprintf("sqrt(stdvar_over_time(%s) * count_over_time(%s) / (count_over_time(%s) - 1))", rollupArg, rollupArg, rollupArg)
+	case "percentile":
+		perSeries = fmt.Sprintf("quantile_over_time(%s, %s)", arg, rollupArg)
+	default:
+		return "", fmt.Errorf("unsupported function %s(); supported functions: count, sum, mean, median, mode, min, max, first, last, spread, stddev, percentile, "+
+			"derivative, non_negative_derivative, difference, non_negative_difference, cumulative_sum, moving_average", ce.Name)
+	}
+	if t.groupByAllTags {
+		return perSeries, nil
+	}
+
+	modifier := t.groupModifier()
+	switch ce.Name {
+	case "count", "sum":
+		return fmt.Sprintf("sum(%s_over_time(%s)) %s", ce.Name, rollupArg, modifier), nil
+	case "mean":
+		return fmt.Sprintf("(sum(sum_over_time(%s)) %s / sum(count_over_time(%s)) %s)", rollupArg, modifier, rollupArg, modifier), nil
+	case "min", "max":
+		return fmt.Sprintf("%s(%s_over_time(%s)) %s", ce.Name, ce.Name, rollupArg, modifier), nil
+	case "spread":
+		return fmt.Sprintf("(max(max_over_time(%s)) %s - min(min_over_time(%s)) %s)", rollupArg, modifier, rollupArg, modifier), nil
+	default:
+		return fmt.Sprintf("avg(%s) %s", perSeries, modifier), nil
+	}
+}
+
+func (t *translator) groupModifier() string {
+	me := metricsql.ModifierExpr{
+		Op:   "by",
+		Args: t.groupByTags,
+	}
+	return string(me.AppendString(nil))
+}
+
+// rollupArg returns MetricsQL rollup arg for the given field such as `{__name__="cpu_usage"}[60000ms] offset 60000ms`.
+func (t *translator) rollupArg(field string, offset int64) string {
+	metricName := t.measurement + t.separator + field
+	lfss := make([][]metricsql.LabelFilter, 0, len(t.lfss))
+	for _, lfs := range t.lfss {
+		lfsNew := make([]metricsql.LabelFilter, 0, len(lfs)+1)
+		lfsNew = append(lfsNew, metricsql.LabelFilter{
+			Label: "__name__",
+			Value: metricName,
+		})
+		lfsNew = append(lfsNew, lfs...)
+		lfss = append(lfss, lfsNew)
+	}
+	me := &metricsql.MetricExpr{
+		LabelFilterss: lfss,
+	}
+	s := fmt.Sprintf("%s[%dms]", me.AppendString(nil), t.interval)
+	if offset > 0 {
+		s += fmt.Sprintf(" offset %dms", offset)
+	}
+	return s
+}
+
+// conditionToLabelFilterss converts WHERE condition on tags to MetricsQL label filters joined with `or`.
+func conditionToLabelFilterss(cond influxql.Expr) ([][]metricsql.LabelFilter, error) {
+	if cond == nil {
+		return [][]metricsql.LabelFilter{nil}, nil
+	}
+	be, ok := cond.(*influxql.BinaryExpr)
+	if !ok {
+		return nil, fmt.Errorf("unsupported condition: %s", cond.AppendString(nil))
+	}
+	switch be.Op {
+	case "AND":
+		left, err := conditionToLabelFilterss(be.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := conditionToLabelFilterss(be.Right)
+		if err != nil {
+			return nil, err
+		}
+		if len(left)*len(right) > maxConditionGroups {
+			return nil, fmt.Errorf("too many OR groups in WHERE clause; the limit is %d", maxConditionGroups)
+		}
+		var lfss [][]metricsql.LabelFilter
+		for _, a := range left {
+			for _, b := range right {
+				lfs := append([]metricsql.LabelFilter{}, a...)
+				lfs = append(lfs, b...)
+				lfss = append(lfss, lfs)
+			}
+		}
+		return lfss, nil
+	case "OR":
+		left, err := conditionToLabelFilterss(be.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := conditionToLabelFilterss(be.Right)
+		if err != nil {
+			return nil, err
+		}
+		if len(left)+len(right) > maxConditionGroups {
+			return nil, fmt.Errorf("too many OR groups in WHERE clause; the limit is %d", maxConditionGroups)
+		}
+		return append(left, right...), nil
+	}
+	lf, err := conditionToLabelFilter(be)
+	if err != nil {
+		return nil, err
+	}
+	return [][]metricsql.LabelFilter{{*lf}}, nil
+}
+
+func conditionToLabelFilter(be *influxql.BinaryExpr) (*metricsql.LabelFilter, error) {
+	vr, ok := be.Left.(*influxql.VarRef)
+	if !ok || vr.Type == "field" {
+		return nil, fmt.Errorf("unsupported condition %s; only conditions on tags such as `tag = 'value'` or `tag =~ /regexp/` are supported", be.AppendString(nil))
+	}
+	lf := &metricsql.LabelFilter{
+		Label: vr.Name,
+	}
+	switch be.Op {
+	case "=", "!=":
+		se, ok := be.Right.(*influxql.StringExpr)
+		if !ok {
+			return nil, fmt.Errorf("unsupported condition %s; filters on field values aren't supported; tag values must be enclosed into single quotes", be.AppendString(nil))
+		}
+		lf.Value = se.S
+		lf.IsNegative = be.Op == "!="
+	case "=~", "!~":
+		re := be.Right.(*influxql.RegexExpr)
+		lf.Value = toAnchoredRegex(re.Re)
+		lf.IsRegexp = true
+		lf.IsNegative = be.Op == "!~"
+	default:
+		return nil, fmt.Errorf("unsupported condition %s; filters on field values aren't supported", be.AppendString(nil))
+	}
+	return lf, nil
+}
+
+// toAnchoredRegex converts unanchored InfluxQL regexp to anchored regexp used in MetricsQL label filters.
+func toAnchoredRegex(re string) string {
+	if !hasTopLevelAlternation(re) {
+		// Drop explicit anchors, so MetricsQL could optimize the regexp. For example, /^foo.*$/ is converted to `foo.*`.
+		// This isn't safe for regexps with top-level alternations such as /^foo|bar$/.
+		if strings.HasPrefix(re, "^") && strings.HasSuffix(re, "$") && !strings.HasSuffix(re, `\$`) {
+			return re[1 : len(re)-1]
+		}
+		if strings.HasPrefix(re, "^") {
+			return re[1:] + ".*"
+		}
+	}
+	return ".*(?:" + re + ").*"
+}
+
+// hasTopLevelAlternation returns true if re contains `|` outside groups and character classes.
+func hasTopLevelAlternation(re string) bool {
+	depth := 0
+	for i := 0; i < len(re); i++ {
+		switch re[i] {
+		case '\\':
+			i++
+		case '[':
+			n := strings.IndexByte(re[i+1:], ']')
+			if n < 0 {
+				return false
+			}
+			i += n + 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '|':
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// metricNameFilter returns a filter on metric names, which start with the given prefix.
+func metricNameFilter(prefix string) metricsql.LabelFilter {
+	return metricsql.LabelFilter{
+		Label:    "__name__",
+		Value:    regexp.QuoteMeta(prefix) + ".+",
+		IsRegexp: true,
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,115 @@
+package influx
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/metricsql"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/influxql"
+)
+
+func TestTranslateFieldSuccess(t *testing.T) {
+	f := func(s, resultExpected string) {
+		t.Helper()
+		stmts, err := influxql.Parse(s)
+		if err != nil {
+			t.Fatalf("cannot parse %s: %s", s, err)
+		}
+		ss := stmts[0].(*influxql.SelectStatement)
+		tr, err := newTranslator(ss, ss.Sources[0].Name, "_", nil, 60000)
+		if err != nil {
+			t.Fatalf("cannot create translator for %s: %s", s, err)
+		}
+		result, err := tr.translateField(ss.Fields[0])
+		if err != nil {
+			t.Fatalf("cannot translate %s: %s", s, err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %s;\ngot\n%s\nwant\n%s", s, result, resultExpected)
+		}
+		if _, err := metricsql.Parse(result); err != nil {
+			t.Fatalf("cannot parse MetricsQL query %s obtained from %s: %s", result, s, err)
+		}
+	}
+
+	// aggregate functions over all the series
+	f(`SELECT mean(value) FROM cpu`, `(sum(sum_over_time(cpu_value[60000ms])) by() / sum(count_over_time(cpu_value[60000ms])) by())`)
+	f(`SELECT count(value) FROM cpu`, `sum(count_over_time(cpu_value[60000ms])) by()`)
+	f(`SELECT max(value) FROM cpu GROUP BY host, dc`, `max(max_over_time(cpu_value[60000ms])) by(host,dc)`)
+	f(`SELECT spread(value) FROM cpu`, `(max(max_over_time(cpu_value[60000ms])) by() - min(min_over_time(cpu_value[60000ms])) by())`)
+	f(`SELECT percentile(value, 95) FROM cpu`, `avg(quantile_over_time(0.95, cpu_value[60000ms])) by()`)
+	f(`SELECT last("usage idle") FROM "disk io"`, `avg(last_over_time(disk\ io_usage\ idle[60000ms])) by()`)
+
+	// aggregate functions per each series
+	f(`SELECT mean(value) FROM cpu GROUP BY *`, `avg_over_time(cpu_value[60000ms])`)
+	f(`SELECT mode(value) FROM cpu GROUP BY *`, `mode_over_time(cpu_value[60000ms])`)
+	f(`SELECT stddev(value) FROM cpu GROUP BY *`, `sqrt(stdvar_over_time(cpu_value[60000ms]) * count_over_time(cpu_value[60000ms]) / (count_over_time(cpu_value[60000ms]) - 1))`)
+
+	// math
+	f(`SELECT max(value) - min(value) FROM cpu GROUP BY *`, `(max_over_time(cpu_value[60000ms]) - min_over_time(cpu_value[60000ms]))`)
+	f(`SELECT sum(a) * 100 / sum(b) FROM cpu GROUP BY *`, `((sum_over_time(cpu_a[60000ms]) * 100) / sum_over_time(cpu_b[60000ms]))`)
+
+	// transformations
+	f(`SELECT derivative(max(value)) FROM cpu GROUP BY *`, `((max_over_time(cpu_value[60000ms]) - max_over_time(cpu_value[60000ms] offset 60000ms)) / 60)`)
+	f(`SELECT non_negative_derivative(max(value), 1m) FROM cpu GROUP BY *`, `(((max_over_time(cpu_value[60000ms]) - max_over_time(cpu_value[60000ms] offset 60000ms)) / 1) >= 0)`)
+	f(`SELECT difference(last(value)) FROM cpu GROUP BY *`, `(last_over_time(cpu_value[60000ms]) - last_over_time(cpu_value[60000ms] offset 60000ms))`)
+	f(`SELECT cumulative_sum(sum(value)) FROM cpu GROUP BY *`, `running_sum(sum_over_time(cpu_value[60000ms]))`)
+	f(`SELECT moving_average(last(value), 2) FROM cpu GROUP BY *`, `((last_over_time(cpu_value[60000ms]) + last_over_time(cpu_value[60000ms] offset 60000ms)) / 2)`)
+
+	// conditions
+	f(`SELECT mean(value) FROM cpu WHERE host = 'a' AND dc != 'b' GROUP BY *`, `avg_over_time(cpu_value{host="a",dc!="b"}[60000ms])`)
+	f(`SELECT mean(value) FROM cpu WHERE host =~ /^a/ OR host !~ /b/ GROUP BY *`, `avg_over_time(cpu_value{host=~"a.*" or host!~".*(?:b).*"}[60000ms])`)
+	f(`SELECT mean(value) FROM cpu WHERE (host = 'a' OR host = 'b') AND dc = 'x' GROUP BY *`, `avg_over_time(cpu_value{host="a",dc="x" or host="b",dc="x"}[60000ms])`)
+}
+
+func TestTranslateFieldFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		stmts, err := influxql.Parse(s)
+		if err != nil {
+			t.Fatalf("cannot parse %s: %s", s, err)
+		}
+		ss := stmts[0].(*influxql.SelectStatement)
+		tr, err := newTranslator(ss, ss.Sources[0].Name, "_", nil, 60000)
+		if err != nil {
+			return
+		}
+		if result, err := tr.translateField(ss.Fields[0]); err == nil {
+			t.Fatalf("expecting non-nil error when translating %s; got %s", s, result)
+		}
+	}
+	f(`SELECT value FROM cpu`)
+	f(`SELECT * FROM cpu`)
+	f(`SELECT mean(*) FROM cpu`)
+	f(`SELECT mean(host::tag) FROM cpu`)
+	f(`SELECT foobar(value) FROM cpu`)
+	f(`SELECT mean(value, 1) FROM cpu`)
+	f(`SELECT percentile(value) FROM cpu`)
+	f(`SELECT percentile(value, 101) FROM cpu`)
+	f(`SELECT derivative(value) FROM cpu`)
+	f(`SELECT derivative(mean(value), 5) FROM cpu`)
+	f(`SELECT moving_average(mean(value), 1000) FROM cpu`)
+	f(`SELECT mean(value) > 5 FROM cpu`)
+	f(`SELECT mean(value) FROM cpu WHERE value > 5`)
+	f(`SELECT mean(value) FROM cpu WHERE host = 5`)
+	f(`SELECT mean(value) FROM cpu WHERE host::field = 'a'`)
+}
+
+func TestToAnchoredRegex(t *testing.T) {
+	f := func(re, resultExpected string) {
+		t.Helper()
+		result := toAnchoredRegex(re)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q; got %q; want %q", re, result, resultExpected)
+		}
+	}
+	f(`foo`, `.*(?:foo).*`)
+	f(`^foo`, `foo.*`)
+	f(`^foo$`, `foo`)
+	f(`^foo\$`, `foo\$.*`)
+	f(`foo$`, `.*(?:foo$).*`)
+	f(`^(a|b)$`, `(a|b)`)
+	f(`^[a|b]$`, `[a|b]`)
+	f(`^a|b$`, `.*(?:^a|b$).*`)
+	f(`^a\|b$`, `a\|b`)
+}
@@ -0,0 +1,544 @@
+package influxql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expr is InfluxQL expression.
+type Expr interface {
+	// AppendString appends Expr contents to dst and returns the result.
+	AppendString(dst []byte) []byte
+}
+
+// NumberExpr is a numeric literal.
+type NumberExpr struct {
+	N float64
+}
+
+// AppendString appends string representation of ne to dst and returns the result.
+func (ne *NumberExpr) AppendString(dst []byte) []byte {
+	return strconv.AppendFloat(dst, ne.N, 'g', -1, 64)
+}
+
+// StringExpr is a string literal enclosed into single quotes.
+type StringExpr struct {
+	S string
+}
+
+// AppendString appends string representation of se to dst and returns the result.
+func (se *StringExpr) AppendString(dst []byte) []byte {
+	dst = append(dst, '\'')
+	for i := 0; i < len(se.S); i++ {
+		if se.S[i] == '\'' || se.S[i] == '\\' {
+			dst = append(dst, '\\')
+		}
+		dst = append(dst, se.S[i])
+	}
+	return append(dst, '\'')
+}
+
+// RegexExpr is a regexp literal enclosed into slashes.
+type RegexExpr struct {
+	Re string
+}
+
+// AppendString appends string representation of re to dst and returns the result.
+func (re *RegexExpr) AppendString(dst []byte) []byte {
+	return appendRegex(dst, re.Re)
+}
+
+// DurationExpr is a duration literal such as `5m`.
+type DurationExpr struct {
+	// Msecs is the duration in milliseconds.
+	Msecs int64
+}
+
+// AppendString appends string representation of de to dst and returns the result.
+func (de *DurationExpr) AppendString(dst []byte) []byte {
+	return append(dst, formatDuration(de.Msecs)...)
+}
+
+// VarRef is a reference to field or tag.
+type VarRef struct {
+	Name string
+
+	// Type is an optional type such as `field` or `tag` from `name::type` syntax.
+	Type string
+}
+
+// AppendString appends string representation of vr to dst and returns the result.
+func (vr *VarRef) AppendString(dst []byte) []byte {
+	dst = appendIdent(dst, vr.Name)
+	if vr.Type != "" {
+		dst = append(dst, "::"...)
+		dst = append(dst, vr.Type...)
+	}
+	return dst
+}
+
+// Wildcard is `*`.
+type Wildcard struct{}
+
+// AppendString appends string representation of w to dst and returns the result.
+func (w *Wildcard) AppendString(dst []byte) []byte {
+	return append(dst, '*')
+}
+
+// CallExpr is a function call.
+type CallExpr struct {
+	// Name is lowercase function name.
+	Name string
+
+	Args []Expr
+}
+
+// AppendString appends string representation of ce to dst and returns the result.
+func (ce *CallExpr) AppendString(dst []byte) []byte {
+	dst = append(dst, ce.Name...)
+	dst = append(dst, '(')
+	for i, arg := range ce.Args {
+		if i > 0 {
+			dst = append(dst, ", "...)
+		}
+		dst = arg.AppendString(dst)
+	}
+	return append(dst, ')')
+}
+
+// BinaryExpr is a binary operation such as `a + b`, `tag = 'value'` or `cond1 AND cond2`.
+type BinaryExpr struct {
+	// Op is the operator. AND and OR operators are uppercase.
+	Op string
+
+	Left  Expr
+	Right Expr
+}
+
+// AppendString appends string representation of be to dst and returns the result.
+func (be *BinaryExpr) AppendString(dst []byte) []byte {
+	prec := binaryOpPrecedence(be.Op)
+	dst = appendBinaryOperand(dst, be.Left, prec, false)
+	dst = append(dst, ' ')
+	dst = append(dst, be.Op...)
+	dst = append(dst, ' ')
+	return appendBinaryOperand(dst, be.Right, prec, true)
+}
+
+func appendBinaryOperand(dst []byte, e Expr, prec int, isRight bool) []byte {
+	be, ok := e.(*BinaryExpr)
+	if !ok {
+		return e.AppendString(dst)
+	}
+	opPrec := binaryOpPrecedence(be.Op)
+	if opPrec > prec || (opPrec == prec && !isRight) {
+		return be.AppendString(dst)
+	}
+	dst = append(dst, '(')
+	dst = be.AppendString(dst)
+	return append(dst, ')')
+}
+
+// binaryOpPrecedence returns precedence for the given binary operator op.
+//
+// It returns 0 if op isn't a binary operator.
+func binaryOpPrecedence(op string) int {
+	switch op {
+	case "OR":
+		return 1
+	case "AND":
+		return 2
+	case "=", "!=", "<", "<=", ">", ">=", "=~", "!~":
+		return 3
+	case "+", "-":
+		return 4
+	case "*", "/", "%":
+		return 5
+	default:
+		return 0
+	}
+}
+
+func normalizeBinaryOp(token string) string {
+	switch {
+	case token == "<>":
+		return "!="
+	case isKeyword(token, "and"):
+		return "AND"
+	case isKeyword(token, "or"):
+		return "OR"
+	default:
+		return token
+	}
+}
+
+func (p *parser) parseExpr() (Expr, error) {
+	return p.parseBinaryExpr(1)
+}
+
+func (p *parser) parseBinaryExpr(minPrec int) (Expr, error) {
+	left, err := p.parseUnaryExpr()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := normalizeBinaryOp(p.lex.Token)
+		prec := binaryOpPrecedence(op)
+		if prec == 0 || prec < minPrec {
+			return left, nil
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		var right Expr
+		if op == "=~" || op == "!~" {
+			right, err = p.parseRegex()
+		} else {
+			right, err = p.parseBinaryExpr(prec + 1)
+		}
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{
+			Op:    op,
+			Left:  left,
+			Right: right,
+		}
+	}
+}
+
+func (p *parser) parseUnaryExpr() (Expr, error) {
+	if p.lex.Token != "-" {
+		return p.parsePrimaryExpr()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	e, err := p.parsePrimaryExpr()
+	if err != nil {
+		return nil, err
+	}
+	switch t := e.(type) {
+	case *NumberExpr:
+		t.N = -t.N
+		return t, nil
+	case *DurationExpr:
+		t.Msecs = -t.Msecs
+		return t, nil
+	default:
+		be := &BinaryExpr{
+			Op:    "*",
+			Left:  &NumberExpr{N: -1},
+			Right: e,
+		}
+		return be, nil
+	}
+}
+
+func (p *parser) parsePrimaryExpr() (Expr, error) {
+	token := p.lex.Token
+	switch {
+	case token == "(":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectToken(")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case token == "*":
+		return &Wildcard{}, p.next()
+	case isNumberPrefix(token):
+		if isDurationToken(token) {
+			d, err := p.parseDuration()
+			if err != nil {
+				return nil, err
+			}
+			return &DurationExpr{
+				Msecs: d,
+			}, nil
+		}
+		return p.parseNumber()
+	case isStringPrefix(token):
+		se := &StringExpr{
+			S: unquote(token),
+		}
+		return se, p.next()
+	case isIdentPrefix(token):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.lex.Token == "(" {
+			return p.parseCall(token)
+		}
+		return p.parseVarRefTail(token)
+	case isQuotedIdentPrefix(token):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		return p.parseVarRefTail(identValue(token))
+	case token == "/":
+		return nil, fmt.Errorf("regexps are allowed only after =~ and !~ operators")
+	default:
+		return nil, fmt.Errorf("unexpected token when parsing expression: %q", token)
+	}
+}
+
+func (p *parser) parseNumber() (*NumberExpr, error) {
+	token := p.lex.Token
+	n, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse number %q: %w", token, err)
+	}
+	ne := &NumberExpr{
+		N: n,
+	}
+	return ne, p.next()
+}
+
+func (p *parser) parseCall(name string) (*CallExpr, error) {
+	if err := p.expectToken("("); err != nil {
+		return nil, err
+	}
+	ce := &CallExpr{
+		Name: strings.ToLower(name),
+	}
+	if p.lex.Token == ")" {
+		return ce, p.next()
+	}
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse arg #%d for %s(): %w", len(ce.Args)+1, ce.Name, err)
+		}
+		ce.Args = append(ce.Args, arg)
+		if p.lex.Token != "," {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectToken(")"); err != nil {
+		return nil, err
+	}
+	return ce, nil
+}
+
+func (p *parser) parseVarRefTail(name string) (*VarRef, error) {
+	vr := &VarRef{
+		Name: name,
+	}
+	if p.lex.Token != "::" {
+		return vr, nil
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if !isIdentPrefix(p.lex.Token) {
+		return nil, fmt.Errorf("expecting type after ::; got %q", p.lex.Token)
+	}
+	vr.Type = strings.ToLower(p.lex.Token)
+	return vr, p.next()
+}
+
+// TimeCondition is a condition on time from WHERE clause such as `time > now() - 1h`.
+type TimeCondition struct {
+	// Op is one of `=`, `<`, `<=`, `>` or `>=`.
+	Op string
+
+	// Value is an expression for the time.
+	Value Expr
+}
+
+// AppendString appends string representation of tc to dst and returns the result.
+func (tc *TimeCondition) AppendString(dst []byte) []byte {
+	dst = append(dst, "time "...)
+	dst = append(dst, tc.Op...)
+	dst = append(dst, ' ')
+	return tc.Value.AppendString(dst)
+}
+
+// TimeConditions contains conditions on time from WHERE clause.
+type TimeConditions []*TimeCondition
+
+// TimeRange returns the time range in milliseconds for tcs.
+//
+// now is the current time in milliseconds, which is used for evaluating now() calls.
+// The returned end equals to now if tcs has no upper bound. hasStart is set to false if tcs has no lower bound.
+func (tcs TimeConditions) TimeRange(now int64) (start, end int64, hasStart bool) {
+	hasEnd := false
+	for _, tc := range tcs {
+		// Errors are impossible there, since conditions are validated during parsing.
+		v, _ := evalTime(tc.Value, now)
+		switch tc.Op {
+		case ">", ">=":
+			if tc.Op == ">" {
+				v++
+			}
+			if !hasStart || v > start {
+				start = v
+			}
+			hasStart = true
+		case "<", "<=":
+			if tc.Op == "<" {
+				v--
+			}
+			if !hasEnd || v < end {
+				end = v
+			}
+			hasEnd = true
+		case "=":
+			start, end = v, v
+			hasStart, hasEnd = true, true
+		}
+	}
+	if !hasEnd {
+		end = now
+	}
+	return start, end, hasStart
+}
+
+// evalTime evaluates time expression e and returns the result in milliseconds.
+func evalTime(e Expr, now int64) (int64, error) {
+	switch t := e.(type) {
+	case *CallExpr:
+		if t.Name != "now" || len(t.Args) != 0 {
+			return 0, fmt.Errorf("unsupported function in time expression: %s", t.AppendString(nil))
+		}
+		return now, nil
+	case *StringExpr:
+		return parseTimeString(t.S)
+	case *NumberExpr:
+		// Numbers without units are nanoseconds.
+		return int64(t.N / 1e6), nil
+	case *DurationExpr:
+		return t.Msecs, nil
+	case *BinaryExpr:
+		if t.Op != "+" && t.Op != "-" {
+			return 0, fmt.Errorf("unsupported operator in time expression: %q", t.Op)
+		}
+		a, err := evalTime(t.Left, now)
+		if err != nil {
+			return 0, err
+		}
+		b, err := evalTime(t.Right, now)
+		if err != nil {
+			return 0, err
+		}
+		if t.Op == "-" {
+			return a - b, nil
+		}
+		return a + b, nil
+	default:
+		return 0, fmt.Errorf("unsupported time expression: %s", e.AppendString(nil))
+	}
+}
+
+func parseTimeString(s string) (int64, error) {
+	layouts := []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02",
+	}
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t.UnixMilli(), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot parse time %q; supported formats: RFC3339, `YYYY-MM-DD HH:MM:SS` and `YYYY-MM-DD`", s)
+}
+
+func isDurationToken(s string) bool {
+	if !isNumberPrefix(s) {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err != nil
+}
+
+var durationUnits = []struct {
+	unit  string
+	nsecs int64
+}{
+	// Longer units must go first, so `ms` isn't parsed as `m`.
+	{"ns", 1},
+	{"ms", 1e6},
+	{"µ", 1e3},
+	{"u", 1e3},
+	{"s", 1e9},
+	{"m", 60 * 1e9},
+	{"h", 3600 * 1e9},
+	{"d", 24 * 3600 * 1e9},
+	{"w", 7 * 24 * 3600 * 1e9},
+}
+
+// parseDuration parses InfluxQL duration s such as `5m` or `1h30m` and returns the result in milliseconds.
+func parseDuration(s string) (int64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("missing duration")
+	}
+	tail := s
+	var nsecs int64
+	for len(tail) > 0 {
+		n := 0
+		for n < len(tail) && isDecimalChar(tail[n]) {
+			n++
+		}
+		if n == 0 {
+			return 0, fmt.Errorf("cannot parse duration %q: missing number before %q", s, tail)
+		}
+		v, err := strconv.ParseInt(tail[:n], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse duration %q: %w", s, err)
+		}
+		tail = tail[n:]
+		unitFound := false
+		for _, du := range durationUnits {
+			if strings.HasPrefix(tail, du.unit) {
+				if v > (1<<63-1)/du.nsecs {
+					return 0, fmt.Errorf("too big duration %q", s)
+				}
+				nsecs += v * du.nsecs
+				tail = tail[len(du.unit):]
+				unitFound = true
+				break
+			}
+		}
+		if !unitFound {
+			return 0, fmt.Errorf("cannot parse duration %q: missing or unknown unit; supported units: ns, u, µ, ms, s, m, h, d, w", s)
+		}
+	}
+	return nsecs / 1e6, nil
+}
+
+// formatDuration returns InfluxQL representation for the duration in milliseconds.
+func formatDuration(msecs int64) string {
+	if msecs == 0 {
+		return "0s"
+	}
+	units := []struct {
+		unit  string
+		msecs int64
+	}{
+		{"w", 7 * 24 * 3600 * 1000},
+		{"d", 24 * 3600 * 1000},
+		{"h", 3600 * 1000},
+		{"m", 60 * 1000},
+		{"s", 1000},
+	}
+	for _, u := range units {
+		if msecs%u.msecs == 0 {
+			return fmt.Sprintf("%d%s", msecs/u.msecs, u.unit)
+		}
+	}
+	return fmt.Sprintf("%dms", msecs)
+}
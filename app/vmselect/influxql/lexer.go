@@ -0,0 +1,292 @@
+package influxql
+
+import (
+	"fmt"
+	"strings"
+)
+
+type lexer struct {
+	// Token contains the currently parsed token.
+	// An empty token means EOF.
+	Token string
+
+	sOrig string
+	sTail string
+
+	err error
+}
+
+func (lex *lexer) Context() string {
+	return fmt.Sprintf("%s%s", lex.Token, lex.sTail)
+}
+
+func (lex *lexer) Init(s string) {
+	lex.Token = ""
+
+	lex.sOrig = s
+	lex.sTail = s
+
+	lex.err = nil
+}
+
+func (lex *lexer) Next() error {
+	if lex.err != nil {
+		return lex.err
+	}
+	token, err := lex.next()
+	if err != nil {
+		lex.err = err
+		return err
+	}
+	lex.Token = token
+	return nil
+}
+
+// NextRegex scans regexp literal if the current token is `/`.
+//
+// InfluxQL regexps are enclosed into slashes, so they cannot be distinguished from division
+// without the parser context. That's why the parser must call NextRegex when it expects a regexp.
+func (lex *lexer) NextRegex() error {
+	if lex.err != nil {
+		return lex.err
+	}
+	if lex.Token != "/" {
+		return fmt.Errorf("expecting regexp enclosed into slashes; got %q", lex.Token)
+	}
+	s := lex.sTail
+	n := 0
+	for {
+		m := strings.IndexByte(s[n:], '/')
+		if m < 0 {
+			lex.err = fmt.Errorf("cannot find closing slash for regexp /%s", lex.sTail)
+			return lex.err
+		}
+		n += m
+		if n == 0 || s[n-1] != '\\' {
+			break
+		}
+		n++
+	}
+	lex.Token = "/" + s[:n+1]
+	lex.sTail = s[n+1:]
+	return nil
+}
+
+func (lex *lexer) next() (string, error) {
+	// Skip whitespace and comments
+	s := lex.sTail
+	for {
+		i := 0
+		for i < len(s) && isSpaceChar(s[i]) {
+			i++
+		}
+		s = s[i:]
+		if !strings.HasPrefix(s, "--") {
+			break
+		}
+		n := strings.IndexByte(s, '\n')
+		if n < 0 {
+			s = ""
+		} else {
+			s = s[n+1:]
+		}
+	}
+	lex.sTail = s
+
+	if len(s) == 0 {
+		return "", nil
+	}
+
+	var token string
+	var err error
+	switch s[0] {
+	case '(', ')', ',', ';', '+', '-', '*', '/', '%', '.':
+		token = s[:1]
+		goto tokenFoundLabel
+	case '=', '!', '<', '>', ':':
+		token, err = scanOperator(s)
+		if err != nil {
+			return "", err
+		}
+		goto tokenFoundLabel
+	}
+	if isStringPrefix(s) || isQuotedIdentPrefix(s) {
+		token, err = scanQuoted(s)
+		if err != nil {
+			return "", err
+		}
+		goto tokenFoundLabel
+	}
+	if isNumberPrefix(s) {
+		token = scanNumber(s)
+		goto tokenFoundLabel
+	}
+	if isIdentPrefix(s) {
+		token = scanIdent(s)
+		goto tokenFoundLabel
+	}
+	return "", fmt.Errorf("unexpected char %q", s[:1])
+
+tokenFoundLabel:
+	lex.sTail = s[len(token):]
+	return token, nil
+}
+
+func scanOperator(s string) (string, error) {
+	if len(s) >= 2 {
+		switch s[:2] {
+		case "=~", "!=", "!~", "<=", ">=", "<>", "::":
+			return s[:2], nil
+		}
+	}
+	switch s[0] {
+	case '=', '<', '>':
+		return s[:1], nil
+	}
+	return "", fmt.Errorf("unexpected operator %q", s[:1])
+}
+
+func scanQuoted(s string) (string, error) {
+	quote := s[0]
+	n := 1
+	for {
+		m := strings.IndexByte(s[n:], quote)
+		if m < 0 {
+			return "", fmt.Errorf("cannot find closing quote %c for %s", quote, s)
+		}
+		n += m
+		bs := 0
+		for bs < n && s[n-bs-1] == '\\' {
+			bs++
+		}
+		if bs%2 == 0 {
+			return s[:n+1], nil
+		}
+		n++
+	}
+}
+
+// unquote returns unquoted contents of the string or quoted identifier s.
+func unquote(s string) string {
+	s = s[1 : len(s)-1]
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case '\\', '\'', '"':
+				i++
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// scanNumber scans a number with optional duration suffix such as `10`, `1.5`, `1e3` or `5m`.
+func scanNumber(s string) string {
+	i := 0
+	for i < len(s) && isDecimalChar(s[i]) {
+		i++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && isDecimalChar(s[i]) {
+			i++
+		}
+	}
+	if i+1 < len(s) && (s[i] == 'e' || s[i] == 'E') && (isDecimalChar(s[i+1]) || (i+2 < len(s) && (s[i+1] == '-' || s[i+1] == '+') && isDecimalChar(s[i+2]))) {
+		i += 2
+		for i < len(s) && isDecimalChar(s[i]) {
+			i++
+		}
+	}
+	// Duration suffix
+	for i < len(s) {
+		if strings.HasPrefix(s[i:], "µ") {
+			i += len("µ")
+			continue
+		}
+		if !isIdentChar(s[i]) {
+			break
+		}
+		i++
+	}
+	return s[:i]
+}
+
+func scanIdent(s string) string {
+	i := 0
+	for i < len(s) && isIdentChar(s[i]) {
+		i++
+	}
+	return s[:i]
+}
+
+func isEOF(s string) bool {
+	return s == ""
+}
+
+func isStringPrefix(s string) bool {
+	return len(s) > 0 && s[0] == '\''
+}
+
+func isQuotedIdentPrefix(s string) bool {
+	return len(s) > 0 && s[0] == '"'
+}
+
+func isRegexToken(s string) bool {
+	return len(s) >= 2 && s[0] == '/' && s[len(s)-1] == '/'
+}
+
+func isNumberPrefix(s string) bool {
+	return len(s) > 0 && isDecimalChar(s[0])
+}
+
+func isIdentPrefix(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	ch := s[0]
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+// isIdentToken returns true if s is an identifier or a quoted identifier.
+func isIdentToken(s string) bool {
+	return isIdentPrefix(s) || isQuotedIdentPrefix(s)
+}
+
+// identValue returns the identifier value for the given identifier token s.
+func identValue(s string) string {
+	if isQuotedIdentPrefix(s) {
+		return unquote(s)
+	}
+	return s
+}
+
+func isDecimalChar(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentChar(ch byte) bool {
+	return ch == '_' || isDecimalChar(ch) || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isSpaceChar(ch byte) bool {
+	switch ch {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	default:
+		return false
+	}
+}
+
+func isKeyword(token, keyword string) bool {
+	return strings.EqualFold(token, keyword)
+}
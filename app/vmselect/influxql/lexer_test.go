@@ -0,0 +1,93 @@
+package influxql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanQuotedSuccess(t *testing.T) {
+	f := func(s, resultExpected string) {
+		t.Helper()
+		result, err := scanQuoted(s)
+		if err != nil {
+			t.Fatalf("unexpected error in scanQuoted(%s): %s", s, err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected string scanned from %s; got %s; want %s", s, result, resultExpected)
+		}
+	}
+	f(`''`, `''`)
+	f(`""tail`, `""`)
+	f(`'foo' AND`, `'foo'`)
+	f(`"foo bar".baz`, `"foo bar"`)
+	f(`'it\'s' x`, `'it\'s'`)
+	f(`'foo\\' x'`, `'foo\\'`)
+}
+
+func TestScanQuotedFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		result, err := scanQuoted(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error for scanQuoted(%s)", s)
+		}
+		if result != "" {
+			t.Fatalf("expecting empty result for scanQuoted(%s); got %s", s, result)
+		}
+	}
+	f(`"foo`)
+	f(`'bar\'`)
+}
+
+func TestLexerSuccess(t *testing.T) {
+	f := func(s string, tokensExpected []string) {
+		t.Helper()
+		var lex lexer
+		var tokens []string
+		lex.Init(s)
+		for {
+			if err := lex.Next(); err != nil {
+				t.Fatalf("unexpected error for %q: %s", s, err)
+			}
+			if isEOF(lex.Token) {
+				break
+			}
+			tokens = append(tokens, lex.Token)
+		}
+		if !reflect.DeepEqual(tokens, tokensExpected) {
+			t.Fatalf("unexpected tokens for %q;\ngot\n%q\nwant\n%q", s, tokens, tokensExpected)
+		}
+	}
+	f("", nil)
+	f("  -- comment only", nil)
+	f(`SELECT mean("value") FROM cpu`, []string{"SELECT", "mean", "(", `"value"`, ")", "FROM", "cpu"})
+	f(`time>=now()-1h30m`, []string{"time", ">=", "now", "(", ")", "-", "1h30m"})
+	f("a<>'b' -- comment\nAND c=~x", []string{"a", "<>", "'b'", "AND", "c", "=~", "x"})
+	f(`v::field != 1.5e-3`, []string{"v", "::", "field", "!=", "1.5e-3"})
+	f(`fill(-1) LIMIT 10;`, []string{"fill", "(", "-", "1", ")", "LIMIT", "10", ";"})
+	f(`db..m`, []string{"db", ".", ".", "m"})
+	f(`100µs`, []string{"100µs"})
+}
+
+func TestLexerNextRegex(t *testing.T) {
+	f := func(s, reExpected, tailExpected string) {
+		t.Helper()
+		var lex lexer
+		lex.Init(s)
+		if err := lex.Next(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := lex.NextRegex(); err != nil {
+			t.Fatalf("unexpected error in NextRegex for %q: %s", s, err)
+		}
+		if lex.Token != reExpected {
+			t.Fatalf("unexpected regexp for %q; got %q; want %q", s, lex.Token, reExpected)
+		}
+		if lex.sTail != tailExpected {
+			t.Fatalf("unexpected tail for %q; got %q; want %q", s, lex.sTail, tailExpected)
+		}
+	}
+	f(`/foo/`, `/foo/`, ``)
+	f(`/^a.+$/ AND`, `/^a.+$/`, ` AND`)
+	f(`/a\/b/ x`, `/a\/b/`, ` x`)
+}
@@ -0,0 +1,1237 @@
+package influxql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type parser struct {
+	lex lexer
+}
+
+// Statement is InfluxQL statement.
+type Statement interface {
+	// AppendString appends Statement contents to dst and returns the result.
+	AppendString(dst []byte) []byte
+}
+
+// Parse parses InfluxQL query s, which may contain multiple statements delimited by `;`.
+//
+// See https://docs.influxdata.com/influxdb/v1/query_language/spec/
+func Parse(s string) ([]Statement, error) {
+	var p parser
+	p.lex.Init(s)
+	if err := p.lex.Next(); err != nil {
+		return nil, fmt.Errorf("cannot parse query: %w; context: %q", err, p.lex.Context())
+	}
+	var stmts []Statement
+	for {
+		for p.lex.Token == ";" {
+			if err := p.lex.Next(); err != nil {
+				return nil, fmt.Errorf("cannot parse query: %w; context: %q", err, p.lex.Context())
+			}
+		}
+		if isEOF(p.lex.Token) {
+			break
+		}
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse query: %w; context: %q", err, p.lex.Context())
+		}
+		stmts = append(stmts, stmt)
+		if !isEOF(p.lex.Token) && p.lex.Token != ";" {
+			return nil, fmt.Errorf("unexpected tail left after parsing %q; context: %q", stmt.AppendString(nil), p.lex.Context())
+		}
+	}
+	if len(stmts) == 0 {
+		return nil, fmt.Errorf("missing statements in the query")
+	}
+	return stmts, nil
+}
+
+func (p *parser) next() error {
+	token := p.lex.Token
+	if err := p.lex.Next(); err != nil {
+		return fmt.Errorf("cannot find next token after %q: %w", token, err)
+	}
+	return nil
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if !isKeyword(p.lex.Token, keyword) {
+		return fmt.Errorf("expecting %s; got %q", keyword, p.lex.Token)
+	}
+	return p.next()
+}
+
+func (p *parser) expectToken(token string) error {
+	if p.lex.Token != token {
+		return fmt.Errorf("expecting %q; got %q", token, p.lex.Token)
+	}
+	return p.next()
+}
+
+func (p *parser) parseStatement() (Statement, error) {
+	switch {
+	case isKeyword(p.lex.Token, "select"):
+		return p.parseSelect()
+	case isKeyword(p.lex.Token, "show"):
+		return p.parseShow()
+	default:
+		return nil, fmt.Errorf("unsupported statement starting with %q; supported statements: SELECT, SHOW", p.lex.Token)
+	}
+}
+
+// SelectStatement is InfluxQL SELECT statement.
+type SelectStatement struct {
+	// Fields contains the selected fields.
+	Fields []*Field
+
+	// Sources contains measurements from FROM clause.
+	Sources []*Measurement
+
+	// Condition contains WHERE filters on tags. It is nil if there are no filters on tags.
+	Condition Expr
+
+	// TimeConditions contains WHERE filters on time.
+	TimeConditions TimeConditions
+
+	// GroupByInterval is the interval in milliseconds from `GROUP BY time(interval)`. It is zero if the interval is missing.
+	GroupByInterval int64
+
+	// GroupByOffset is the offset in milliseconds from `GROUP BY time(interval, offset)`.
+	GroupByOffset int64
+
+	// GroupByTags contains tags from GROUP BY clause.
+	GroupByTags []string
+
+	// GroupByAllTags is set to true for `GROUP BY *`.
+	GroupByAllTags bool
+
+	// Fill contains fill() option.
+	Fill Fill
+
+	// OrderDesc is set to true for `ORDER BY time DESC`.
+	OrderDesc bool
+
+	Limit   int
+	Offset  int
+	SLimit  int
+	SOffset int
+}
+
+// AppendString appends string representation of ss to dst and returns the result.
+func (ss *SelectStatement) AppendString(dst []byte) []byte {
+	dst = append(dst, "SELECT "...)
+	for i, f := range ss.Fields {
+		if i > 0 {
+			dst = append(dst, ", "...)
+		}
+		dst = f.AppendString(dst)
+	}
+	dst = appendSources(dst, ss.Sources)
+	dst = appendWhere(dst, ss.Condition, ss.TimeConditions)
+	if ss.GroupByInterval > 0 || len(ss.GroupByTags) > 0 || ss.GroupByAllTags {
+		dst = append(dst, " GROUP BY "...)
+		var groups []string
+		if ss.GroupByInterval > 0 {
+			s := "time(" + formatDuration(ss.GroupByInterval)
+			if ss.GroupByOffset != 0 {
+				s += ", " + formatDuration(ss.GroupByOffset)
+			}
+			groups = append(groups, s+")")
+		}
+		if ss.GroupByAllTags {
+			groups = append(groups, "*")
+		}
+		for _, tag := range ss.GroupByTags {
+			groups = append(groups, string(appendIdent(nil, tag)))
+		}
+		dst = append(dst, strings.Join(groups, ", ")...)
+	}
+	if ss.Fill.Mode != FillNull {
+		dst = append(dst, ' ')
+		dst = ss.Fill.AppendString(dst)
+	}
+	if ss.OrderDesc {
+		dst = append(dst, " ORDER BY time DESC"...)
+	}
+	dst = appendLimitOffset(dst, "LIMIT", ss.Limit)
+	dst = appendLimitOffset(dst, "OFFSET", ss.Offset)
+	dst = appendLimitOffset(dst, "SLIMIT", ss.SLimit)
+	dst = appendLimitOffset(dst, "SOFFSET", ss.SOffset)
+	return dst
+}
+
+func (p *parser) parseSelect() (*SelectStatement, error) {
+	if err := p.expectKeyword("select"); err != nil {
+		return nil, err
+	}
+	var ss SelectStatement
+	for {
+		f, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		ss.Fields = append(ss.Fields, f)
+		if p.lex.Token != "," {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if isKeyword(p.lex.Token, "into") {
+		return nil, fmt.Errorf("SELECT INTO isn't supported")
+	}
+	if err := p.expectKeyword("from"); err != nil {
+		return nil, err
+	}
+	sources, err := p.parseSources()
+	if err != nil {
+		return nil, err
+	}
+	ss.Sources = sources
+	if isKeyword(p.lex.Token, "where") {
+		cond, tcs, err := p.parseWhere()
+		if err != nil {
+			return nil, err
+		}
+		ss.Condition = cond
+		ss.TimeConditions = tcs
+	}
+	if isKeyword(p.lex.Token, "group") {
+		if err := p.parseGroupBy(&ss); err != nil {
+			return nil, err
+		}
+	}
+	if isKeyword(p.lex.Token, "fill") {
+		if err := p.parseFill(&ss.Fill); err != nil {
+			return nil, err
+		}
+	}
+	if isKeyword(p.lex.Token, "order") {
+		orderDesc, err := p.parseOrderBy()
+		if err != nil {
+			return nil, err
+		}
+		ss.OrderDesc = orderDesc
+	}
+	if ss.Limit, err = p.parseOptionalInt("limit"); err != nil {
+		return nil, err
+	}
+	if ss.Offset, err = p.parseOptionalInt("offset"); err != nil {
+		return nil, err
+	}
+	if ss.SLimit, err = p.parseOptionalInt("slimit"); err != nil {
+		return nil, err
+	}
+	if ss.SOffset, err = p.parseOptionalInt("soffset"); err != nil {
+		return nil, err
+	}
+	if isKeyword(p.lex.Token, "tz") {
+		return nil, fmt.Errorf("tz() clause isn't supported")
+	}
+	return &ss, nil
+}
+
+// Field is a field from SELECT clause.
+type Field struct {
+	Expr  Expr
+	Alias string
+}
+
+// AppendString appends string representation of f to dst and returns the result.
+func (f *Field) AppendString(dst []byte) []byte {
+	dst = f.Expr.AppendString(dst)
+	if f.Alias != "" {
+		dst = append(dst, " AS "...)
+		dst = appendIdent(dst, f.Alias)
+	}
+	return dst
+}
+
+// Name returns column name for f in the response.
+func (f *Field) Name() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return exprName(f.Expr)
+}
+
+func exprName(e Expr) string {
+	switch t := e.(type) {
+	case *CallExpr:
+		return t.Name
+	case *VarRef:
+		return t.Name
+	case *BinaryExpr:
+		a := exprName(t.Left)
+		b := exprName(t.Right)
+		switch {
+		case a == "":
+			return b
+		case b == "":
+			return a
+		default:
+			return a + "_" + b
+		}
+	default:
+		return ""
+	}
+}
+
+func (p *parser) parseField() (*Field, error) {
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	f := &Field{
+		Expr: e,
+	}
+	if isKeyword(p.lex.Token, "as") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if !isIdentToken(p.lex.Token) {
+			return nil, fmt.Errorf("expecting alias after AS; got %q", p.lex.Token)
+		}
+		f.Alias = identValue(p.lex.Token)
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Measurement is a measurement from FROM clause.
+type Measurement struct {
+	Database        string
+	RetentionPolicy string
+	Name            string
+}
+
+// AppendString appends string representation of m to dst and returns the result.
+func (m *Measurement) AppendString(dst []byte) []byte {
+	if m.Database != "" {
+		dst = appendIdent(dst, m.Database)
+		dst = append(dst, '.')
+		if m.RetentionPolicy != "" {
+			dst = appendIdent(dst, m.RetentionPolicy)
+		}
+		dst = append(dst, '.')
+	} else if m.RetentionPolicy != "" {
+		dst = appendIdent(dst, m.RetentionPolicy)
+		dst = append(dst, '.')
+	}
+	return appendIdent(dst, m.Name)
+}
+
+func appendSources(dst []byte, sources []*Measurement) []byte {
+	if len(sources) == 0 {
+		return dst
+	}
+	dst = append(dst, " FROM "...)
+	for i, m := range sources {
+		if i > 0 {
+			dst = append(dst, ", "...)
+		}
+		dst = m.AppendString(dst)
+	}
+	return dst
+}
+
+func (p *parser) parseSources() ([]*Measurement, error) {
+	var sources []*Measurement
+	for {
+		m, err := p.parseMeasurement()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, m)
+		if p.lex.Token != "," {
+			return sources, nil
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseMeasurement() (*Measurement, error) {
+	if p.lex.Token == "/" {
+		return nil, fmt.Errorf("regexps in FROM clause aren't supported")
+	}
+	var parts []string
+	for {
+		if !isIdentToken(p.lex.Token) {
+			return nil, fmt.Errorf("expecting measurement name; got %q", p.lex.Token)
+		}
+		parts = append(parts, identValue(p.lex.Token))
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.lex.Token != "." {
+			break
+		}
+		if len(parts) == 3 {
+			return nil, fmt.Errorf("too many dots in measurement name")
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.lex.Token == "." {
+			// Empty retention policy such as `db..measurement`
+			if len(parts) != 1 {
+				return nil, fmt.Errorf("unexpected empty retention policy")
+			}
+			parts = append(parts, "")
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	switch len(parts) {
+	case 1:
+		return &Measurement{
+			Name: parts[0],
+		}, nil
+	case 2:
+		return &Measurement{
+			RetentionPolicy: parts[0],
+			Name:            parts[1],
+		}, nil
+	default:
+		return &Measurement{
+			Database:        parts[0],
+			RetentionPolicy: parts[1],
+			Name:            parts[2],
+		}, nil
+	}
+}
+
+func appendWhere(dst []byte, cond Expr, tcs TimeConditions) []byte {
+	if cond == nil && len(tcs) == 0 {
+		return dst
+	}
+	dst = append(dst, " WHERE "...)
+	if cond != nil {
+		dst = cond.AppendString(dst)
+	}
+	for i, tc := range tcs {
+		if i > 0 || cond != nil {
+			dst = append(dst, " AND "...)
+		}
+		dst = tc.AppendString(dst)
+	}
+	return dst
+}
+
+// parseWhere parses WHERE clause and returns conditions on tags and conditions on time.
+func (p *parser) parseWhere() (Expr, TimeConditions, error) {
+	if err := p.expectKeyword("where"); err != nil {
+		return nil, nil, err
+	}
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, nil, err
+	}
+	return splitTimeConditions(e)
+}
+
+// splitTimeConditions splits e into conditions on tags and conditions on time.
+//
+// Conditions on time must be joined with other conditions with AND operator.
+func splitTimeConditions(e Expr) (Expr, TimeConditions, error) {
+	be, ok := e.(*BinaryExpr)
+	if !ok {
+		return e, nil, nil
+	}
+	if be.Op == "AND" {
+		left, leftTCs, err := splitTimeConditions(be.Left)
+		if err != nil {
+			return nil, nil, err
+		}
+		right, rightTCs, err := splitTimeConditions(be.Right)
+		if err != nil {
+			return nil, nil, err
+		}
+		tcs := append(leftTCs, rightTCs...)
+		switch {
+		case left == nil:
+			return right, tcs, nil
+		case right == nil:
+			return left, tcs, nil
+		default:
+			return &BinaryExpr{
+				Op:    "AND",
+				Left:  left,
+				Right: right,
+			}, tcs, nil
+		}
+	}
+	if isTimeRef(be.Left) {
+		switch be.Op {
+		case "=", "<", "<=", ">", ">=":
+		default:
+			return nil, nil, fmt.Errorf("unsupported operator %q for time condition %s", be.Op, be.AppendString(nil))
+		}
+		if _, err := evalTime(be.Right, 0); err != nil {
+			return nil, nil, fmt.Errorf("invalid time condition %s: %w", be.AppendString(nil), err)
+		}
+		tc := &TimeCondition{
+			Op:    be.Op,
+			Value: be.Right,
+		}
+		return nil, TimeConditions{tc}, nil
+	}
+	if hasTimeRef(be) {
+		return nil, nil, fmt.Errorf("time conditions must be joined with other conditions via AND operator; got %s", be.AppendString(nil))
+	}
+	return be, nil, nil
+}
+
+func isTimeRef(e Expr) bool {
+	vr, ok := e.(*VarRef)
+	return ok && strings.EqualFold(vr.Name, "time")
+}
+
+func hasTimeRef(e Expr) bool {
+	switch t := e.(type) {
+	case *VarRef:
+		return isTimeRef(t)
+	case *BinaryExpr:
+		return hasTimeRef(t.Left) || hasTimeRef(t.Right)
+	default:
+		return false
+	}
+}
+
+func (p *parser) parseGroupBy(ss *SelectStatement) error {
+	if err := p.expectKeyword("group"); err != nil {
+		return err
+	}
+	if err := p.expectKeyword("by"); err != nil {
+		return err
+	}
+	for {
+		switch {
+		case isKeyword(p.lex.Token, "time"):
+			if err := p.parseGroupByTime(ss); err != nil {
+				return err
+			}
+		case p.lex.Token == "*":
+			ss.GroupByAllTags = true
+			if err := p.next(); err != nil {
+				return err
+			}
+		case p.lex.Token == "/":
+			return fmt.Errorf("regexps in GROUP BY clause aren't supported")
+		case isIdentToken(p.lex.Token):
+			ss.GroupByTags = append(ss.GroupByTags, identValue(p.lex.Token))
+			if err := p.next(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected token in GROUP BY clause: %q", p.lex.Token)
+		}
+		if p.lex.Token != "," {
+			return nil
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) parseGroupByTime(ss *SelectStatement) error {
+	if ss.GroupByInterval > 0 {
+		return fmt.Errorf("duplicate time() in GROUP BY clause")
+	}
+	if err := p.expectKeyword("time"); err != nil {
+		return err
+	}
+	if err := p.expectToken("("); err != nil {
+		return err
+	}
+	d, err := p.parseDuration()
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("time() interval must be positive; got %s", formatDuration(d))
+	}
+	ss.GroupByInterval = d
+	if p.lex.Token == "," {
+		if err := p.next(); err != nil {
+			return err
+		}
+		isMinus := false
+		if p.lex.Token == "-" {
+			isMinus = true
+			if err := p.next(); err != nil {
+				return err
+			}
+		}
+		offset, err := p.parseDuration()
+		if err != nil {
+			return err
+		}
+		if isMinus {
+			offset = -offset
+		}
+		ss.GroupByOffset = offset
+	}
+	return p.expectToken(")")
+}
+
+func (p *parser) parseDuration() (int64, error) {
+	d, err := parseDuration(p.lex.Token)
+	if err != nil {
+		return 0, err
+	}
+	if err := p.next(); err != nil {
+		return 0, err
+	}
+	return d, nil
+}
+
+// FillMode is the mode for fill() option.
+type FillMode int
+
+const (
+	// FillNull fills missing values with nulls. This is the default mode.
+	FillNull FillMode = iota
+
+	// FillNone drops rows with missing values.
+	FillNone
+
+	// FillValue fills missing values with Fill.Value.
+	FillValue
+
+	// FillPrevious fills missing values with the previous value.
+	FillPrevious
+
+	// FillLinear fills missing values with linear interpolation between adjacent values.
+	FillLinear
+)
+
+// Fill is fill() option for SELECT statement.
+type Fill struct {
+	Mode  FillMode
+	Value float64
+}
+
+// AppendString appends string representation of f to dst and returns the result.
+func (f *Fill) AppendString(dst []byte) []byte {
+	dst = append(dst, "fill("...)
+	switch f.Mode {
+	case FillNone:
+		dst = append(dst, "none"...)
+	case FillValue:
+		dst = strconv.AppendFloat(dst, f.Value, 'g', -1, 64)
+	case FillPrevious:
+		dst = append(dst, "previous"...)
+	case FillLinear:
+		dst = append(dst, "linear"...)
+	default:
+		dst = append(dst, "null"...)
+	}
+	return append(dst, ')')
+}
+
+func (p *parser) parseFill(f *Fill) error {
+	if err := p.expectKeyword("fill"); err != nil {
+		return err
+	}
+	if err := p.expectToken("("); err != nil {
+		return err
+	}
+	token := p.lex.Token
+	switch {
+	case isKeyword(token, "null"):
+		f.Mode = FillNull
+	case isKeyword(token, "none"):
+		f.Mode = FillNone
+	case isKeyword(token, "previous"):
+		f.Mode = FillPrevious
+	case isKeyword(token, "linear"):
+		f.Mode = FillLinear
+	default:
+		e, err := p.parseUnaryExpr()
+		if err != nil {
+			return fmt.Errorf("cannot parse fill() option: %w", err)
+		}
+		ne, ok := e.(*NumberExpr)
+		if !ok {
+			return fmt.Errorf("unsupported fill() option: %s; supported options: null, none, previous, linear or a number", e.AppendString(nil))
+		}
+		f.Mode = FillValue
+		f.Value = ne.N
+		return p.expectToken(")")
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	return p.expectToken(")")
+}
+
+func (p *parser) parseOrderBy() (bool, error) {
+	if err := p.expectKeyword("order"); err != nil {
+		return false, err
+	}
+	if err := p.expectKeyword("by"); err != nil {
+		return false, err
+	}
+	if err := p.expectKeyword("time"); err != nil {
+		return false, fmt.Errorf("only ordering by time is supported: %w", err)
+	}
+	switch {
+	case isKeyword(p.lex.Token, "asc"):
+		return false, p.next()
+	case isKeyword(p.lex.Token, "desc"):
+		return true, p.next()
+	default:
+		return false, nil
+	}
+}
+
+func (p *parser) parseOptionalInt(keyword string) (int, error) {
+	if !isKeyword(p.lex.Token, keyword) {
+		return 0, nil
+	}
+	if err := p.next(); err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(p.lex.Token)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expecting non-negative integer after %s; got %q", strings.ToUpper(keyword), p.lex.Token)
+	}
+	if err := p.next(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func appendLimitOffset(dst []byte, keyword string, n int) []byte {
+	if n <= 0 {
+		return dst
+	}
+	dst = append(dst, ' ')
+	dst = append(dst, keyword...)
+	dst = append(dst, ' ')
+	return strconv.AppendInt(dst, int64(n), 10)
+}
+
+// ShowDatabasesStatement is `SHOW DATABASES` statement.
+type ShowDatabasesStatement struct{}
+
+// AppendString appends string representation of s to dst and returns the result.
+func (s *ShowDatabasesStatement) AppendString(dst []byte) []byte {
+	return append(dst, "SHOW DATABASES"...)
+}
+
+// ShowRetentionPoliciesStatement is `SHOW RETENTION POLICIES` statement.
+type ShowRetentionPoliciesStatement struct {
+	Database string
+}
+
+// AppendString appends string representation of s to dst and returns the result.
+func (s *ShowRetentionPoliciesStatement) AppendString(dst []byte) []byte {
+	dst = append(dst, "SHOW RETENTION POLICIES"...)
+	return appendOnDatabase(dst, s.Database)
+}
+
+// ShowMeasurementsStatement is `SHOW MEASUREMENTS` statement.
+type ShowMeasurementsStatement struct {
+	Database string
+
+	// MeasurementFilter contains filter from `WITH MEASUREMENT` clause. It is nil if the clause is missing.
+	MeasurementFilter *NameFilter
+
+	Condition      Expr
+	TimeConditions TimeConditions
+
+	Limit  int
+	Offset int
+}
+
+// AppendString appends string representation of s to dst and returns the result.
+func (s *ShowMeasurementsStatement) AppendString(dst []byte) []byte {
+	dst = append(dst, "SHOW MEASUREMENTS"...)
+	dst = appendOnDatabase(dst, s.Database)
+	if s.MeasurementFilter != nil {
+		dst = append(dst, " WITH MEASUREMENT "...)
+		dst = s.MeasurementFilter.AppendString(dst)
+	}
+	dst = appendWhere(dst, s.Condition, s.TimeConditions)
+	dst = appendLimitOffset(dst, "LIMIT", s.Limit)
+	dst = appendLimitOffset(dst, "OFFSET", s.Offset)
+	return dst
+}
+
+// ShowTagKeysStatement is `SHOW TAG KEYS` statement.
+type ShowTagKeysStatement struct {
+	Database       string
+	Sources        []*Measurement
+	Condition      Expr
+	TimeConditions TimeConditions
+
+	Limit  int
+	Offset int
+}
+
+// AppendString appends string representation of s to dst and returns the result.
+func (s *ShowTagKeysStatement) AppendString(dst []byte) []byte {
+	dst = append(dst, "SHOW TAG KEYS"...)
+	dst = appendOnDatabase(dst, s.Database)
+	dst = appendSources(dst, s.Sources)
+	dst = appendWhere(dst, s.Condition, s.TimeConditions)
+	dst = appendLimitOffset(dst, "LIMIT", s.Limit)
+	dst = appendLimitOffset(dst, "OFFSET", s.Offset)
+	return dst
+}
+
+// ShowTagValuesStatement is `SHOW TAG VALUES` statement.
+type ShowTagValuesStatement struct {
+	Database string
+	Sources  []*Measurement
+
+	// KeyFilter contains filter from `WITH KEY` clause.
+	KeyFilter *NameFilter
+
+	Condition      Expr
+	TimeConditions TimeConditions
+
+	Limit  int
+	Offset int
+}
+
+// AppendString appends string representation of s to dst and returns the result.
+func (s *ShowTagValuesStatement) AppendString(dst []byte) []byte {
+	dst = append(dst, "SHOW TAG VALUES"...)
+	dst = appendOnDatabase(dst, s.Database)
+	dst = appendSources(dst, s.Sources)
+	dst = append(dst, " WITH KEY "...)
+	dst = s.KeyFilter.AppendString(dst)
+	dst = appendWhere(dst, s.Condition, s.TimeConditions)
+	dst = appendLimitOffset(dst, "LIMIT", s.Limit)
+	dst = appendLimitOffset(dst, "OFFSET", s.Offset)
+	return dst
+}
+
+// ShowFieldKeysStatement is `SHOW FIELD KEYS` statement.
+type ShowFieldKeysStatement struct {
+	Database string
+	Sources  []*Measurement
+
+	Limit  int
+	Offset int
+}
+
+// AppendString appends string representation of s to dst and returns the result.
+func (s *ShowFieldKeysStatement) AppendString(dst []byte) []byte {
+	dst = append(dst, "SHOW FIELD KEYS"...)
+	dst = appendOnDatabase(dst, s.Database)
+	dst = appendSources(dst, s.Sources)
+	dst = appendLimitOffset(dst, "LIMIT", s.Limit)
+	dst = appendLimitOffset(dst, "OFFSET", s.Offset)
+	return dst
+}
+
+func appendOnDatabase(dst []byte, db string) []byte {
+	if db == "" {
+		return dst
+	}
+	dst = append(dst, " ON "...)
+	return appendIdent(dst, db)
+}
+
+func (p *parser) parseShow() (Statement, error) {
+	if err := p.expectKeyword("show"); err != nil {
+		return nil, err
+	}
+	switch {
+	case isKeyword(p.lex.Token, "databases"):
+		return &ShowDatabasesStatement{}, p.next()
+	case isKeyword(p.lex.Token, "retention"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("policies"); err != nil {
+			return nil, err
+		}
+		db, err := p.parseOnDatabase()
+		if err != nil {
+			return nil, err
+		}
+		return &ShowRetentionPoliciesStatement{
+			Database: db,
+		}, nil
+	case isKeyword(p.lex.Token, "measurements"):
+		return p.parseShowMeasurements()
+	case isKeyword(p.lex.Token, "tag"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		switch {
+		case isKeyword(p.lex.Token, "keys"):
+			return p.parseShowTagKeys()
+		case isKeyword(p.lex.Token, "values"):
+			return p.parseShowTagValues()
+		default:
+			return nil, fmt.Errorf("expecting KEYS or VALUES after SHOW TAG; got %q", p.lex.Token)
+		}
+	case isKeyword(p.lex.Token, "field"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		return p.parseShowFieldKeys()
+	default:
+		return nil, fmt.Errorf("unsupported SHOW statement: SHOW %s; supported statements: SHOW DATABASES, SHOW RETENTION POLICIES, "+
+			"SHOW MEASUREMENTS, SHOW TAG KEYS, SHOW TAG VALUES, SHOW FIELD KEYS", p.lex.Token)
+	}
+}
+
+func (p *parser) parseOnDatabase() (string, error) {
+	if !isKeyword(p.lex.Token, "on") {
+		return "", nil
+	}
+	if err := p.next(); err != nil {
+		return "", err
+	}
+	if !isIdentToken(p.lex.Token) {
+		return "", fmt.Errorf("expecting database name after ON; got %q", p.lex.Token)
+	}
+	db := identValue(p.lex.Token)
+	return db, p.next()
+}
+
+func (p *parser) parseShowMeasurements() (*ShowMeasurementsStatement, error) {
+	if err := p.expectKeyword("measurements"); err != nil {
+		return nil, err
+	}
+	var s ShowMeasurementsStatement
+	db, err := p.parseOnDatabase()
+	if err != nil {
+		return nil, err
+	}
+	s.Database = db
+	if isKeyword(p.lex.Token, "with") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("measurement"); err != nil {
+			return nil, err
+		}
+		nf, err := p.parseNameFilter(false)
+		if err != nil {
+			return nil, err
+		}
+		s.MeasurementFilter = nf
+	}
+	if isKeyword(p.lex.Token, "where") {
+		cond, tcs, err := p.parseWhere()
+		if err != nil {
+			return nil, err
+		}
+		s.Condition = cond
+		s.TimeConditions = tcs
+	}
+	if s.Limit, err = p.parseOptionalInt("limit"); err != nil {
+		return nil, err
+	}
+	if s.Offset, err = p.parseOptionalInt("offset"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (p *parser) parseShowTagKeys() (*ShowTagKeysStatement, error) {
+	if err := p.expectKeyword("keys"); err != nil {
+		return nil, err
+	}
+	var s ShowTagKeysStatement
+	db, err := p.parseOnDatabase()
+	if err != nil {
+		return nil, err
+	}
+	s.Database = db
+	if isKeyword(p.lex.Token, "from") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if s.Sources, err = p.parseSources(); err != nil {
+			return nil, err
+		}
+	}
+	if isKeyword(p.lex.Token, "where") {
+		cond, tcs, err := p.parseWhere()
+		if err != nil {
+			return nil, err
+		}
+		s.Condition = cond
+		s.TimeConditions = tcs
+	}
+	if s.Limit, err = p.parseOptionalInt("limit"); err != nil {
+		return nil, err
+	}
+	if s.Offset, err = p.parseOptionalInt("offset"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (p *parser) parseShowTagValues() (*ShowTagValuesStatement, error) {
+	if err := p.expectKeyword("values"); err != nil {
+		return nil, err
+	}
+	var s ShowTagValuesStatement
+	db, err := p.parseOnDatabase()
+	if err != nil {
+		return nil, err
+	}
+	s.Database = db
+	if isKeyword(p.lex.Token, "from") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if s.Sources, err = p.parseSources(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectKeyword("with"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("key"); err != nil {
+		return nil, err
+	}
+	nf, err := p.parseNameFilter(true)
+	if err != nil {
+		return nil, err
+	}
+	s.KeyFilter = nf
+	if isKeyword(p.lex.Token, "where") {
+		cond, tcs, err := p.parseWhere()
+		if err != nil {
+			return nil, err
+		}
+		s.Condition = cond
+		s.TimeConditions = tcs
+	}
+	if s.Limit, err = p.parseOptionalInt("limit"); err != nil {
+		return nil, err
+	}
+	if s.Offset, err = p.parseOptionalInt("offset"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (p *parser) parseShowFieldKeys() (*ShowFieldKeysStatement, error) {
+	if err := p.expectKeyword("keys"); err != nil {
+		return nil, err
+	}
+	var s ShowFieldKeysStatement
+	db, err := p.parseOnDatabase()
+	if err != nil {
+		return nil, err
+	}
+	s.Database = db
+	if isKeyword(p.lex.Token, "from") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if s.Sources, err = p.parseSources(); err != nil {
+			return nil, err
+		}
+	}
+	if s.Limit, err = p.parseOptionalInt("limit"); err != nil {
+		return nil, err
+	}
+	if s.Offset, err = p.parseOptionalInt("offset"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// NameFilter is a filter on names from `WITH MEASUREMENT` and `WITH KEY` clauses.
+type NameFilter struct {
+	// Op is one of `=`, `!=`, `=~`, `!~` or `IN`.
+	Op string
+
+	// Values contains names for `=`, `!=` and `IN` operators.
+	Values []string
+
+	// Re contains regexp for `=~` and `!~` operators.
+	Re *regexp.Regexp
+}
+
+// AppendString appends string representation of nf to dst and returns the result.
+func (nf *NameFilter) AppendString(dst []byte) []byte {
+	dst = append(dst, nf.Op...)
+	dst = append(dst, ' ')
+	switch nf.Op {
+	case "=~", "!~":
+		dst = appendRegex(dst, nf.Re.String())
+	case "IN":
+		dst = append(dst, '(')
+		for i, v := range nf.Values {
+			if i > 0 {
+				dst = append(dst, ", "...)
+			}
+			dst = appendIdent(dst, v)
+		}
+		dst = append(dst, ')')
+	default:
+		dst = appendIdent(dst, nf.Values[0])
+	}
+	return dst
+}
+
+// Match returns true if name matches nf.
+func (nf *NameFilter) Match(name string) bool {
+	switch nf.Op {
+	case "=~":
+		return nf.Re.MatchString(name)
+	case "!~":
+		return !nf.Re.MatchString(name)
+	case "!=":
+		return name != nf.Values[0]
+	default:
+		for _, v := range nf.Values {
+			if name == v {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (p *parser) parseNameFilter(allowIn bool) (*NameFilter, error) {
+	op := p.lex.Token
+	switch {
+	case op == "=", op == "!=", op == "<>":
+		if op == "<>" {
+			op = "!="
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if !isIdentToken(p.lex.Token) && !isStringPrefix(p.lex.Token) {
+			return nil, fmt.Errorf("expecting name after %q; got %q", op, p.lex.Token)
+		}
+		name := identValue(p.lex.Token)
+		if isStringPrefix(p.lex.Token) {
+			name = unquote(p.lex.Token)
+		}
+		return &NameFilter{
+			Op:     op,
+			Values: []string{name},
+		}, p.next()
+	case op == "=~" || op == "!~":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		re, err := p.parseRegex()
+		if err != nil {
+			return nil, err
+		}
+		reCompiled, err := regexp.Compile(re.Re)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse regexp %q: %w", re.Re, err)
+		}
+		return &NameFilter{
+			Op: op,
+			Re: reCompiled,
+		}, nil
+	case allowIn && isKeyword(op, "in"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.expectToken("("); err != nil {
+			return nil, err
+		}
+		var names []string
+		for {
+			if !isIdentToken(p.lex.Token) {
+				return nil, fmt.Errorf("expecting name inside IN (...); got %q", p.lex.Token)
+			}
+			names = append(names, identValue(p.lex.Token))
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.lex.Token != "," {
+				break
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expectToken(")"); err != nil {
+			return nil, err
+		}
+		return &NameFilter{
+			Op:     "IN",
+			Values: names,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unexpected operator %q", op)
+	}
+}
+
+func (p *parser) parseRegex() (*RegexExpr, error) {
+	if err := p.lex.NextRegex(); err != nil {
+		return nil, err
+	}
+	token := p.lex.Token
+	re := strings.ReplaceAll(token[1:len(token)-1], `\/`, `/`)
+	if _, err := regexp.Compile(re); err != nil {
+		return nil, fmt.Errorf("cannot parse regexp %s: %w", token, err)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return &RegexExpr{
+		Re: re,
+	}, nil
+}
+
+func appendIdent(dst []byte, s string) []byte {
+	if isPlainIdent(s) {
+		return append(dst, s...)
+	}
+	dst = append(dst, '"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			dst = append(dst, '\\')
+		}
+		dst = append(dst, s[i])
+	}
+	return append(dst, '"')
+}
+
+func isPlainIdent(s string) bool {
+	if !isIdentPrefix(s) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isIdentChar(s[i]) {
+			return false
+		}
+	}
+	switch strings.ToLower(s) {
+	case "select", "from", "where", "group", "by", "and", "or", "as", "show", "with", "key", "on", "limit", "offset",
+		"slimit", "soffset", "order", "fill", "in", "tag", "field", "measurement", "measurements":
+		return false
+	}
+	return true
+}
+
+func appendRegex(dst []byte, re string) []byte {
+	dst = append(dst, '/')
+	dst = append(dst, strings.ReplaceAll(re, "/", `\/`)...)
+	return append(dst, '/')
+}
@@ -0,0 +1,190 @@
+package influxql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSuccess(t *testing.T) {
+	another := func(s, resultExpected string) {
+		t.Helper()
+		stmts, err := Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %s: %s", s, err)
+		}
+		var results []string
+		for _, stmt := range stmts {
+			results = append(results, string(stmt.AppendString(nil)))
+		}
+		result := strings.Join(results, "; ")
+		if result != resultExpected {
+			t.Fatalf("unexpected result when marshaling %s;\ngot\n%s\nwant\n%s", s, result, resultExpected)
+		}
+	}
+	same := func(s string) {
+		t.Helper()
+		another(s, s)
+	}
+
+	// SELECT statements
+	same(`SELECT mean(value) FROM cpu`)
+	another(`select MEAN("value") from "cpu"`, `SELECT mean(value) FROM cpu`)
+	same(`SELECT mean(value) AS m, max(value) FROM cpu`)
+	same(`SELECT mean(value) FROM mydb.autogen.cpu`)
+	same(`SELECT mean(value) FROM mydb..cpu`)
+	same(`SELECT mean(value) FROM autogen.cpu`)
+	same(`SELECT mean("foo bar") FROM "disk io"`)
+	another(`SELECT mean("value"::field) FROM cpu`, `SELECT mean(value::field) FROM cpu`)
+	same(`SELECT percentile(value, 95) FROM cpu`)
+	same(`SELECT non_negative_derivative(mean(value), 1s) FROM cpu`)
+	same(`SELECT mean(a) * 100 / mean(b) FROM cpu`)
+	same(`SELECT (mean(a) + 1) * 2 FROM cpu`)
+	same(`SELECT mean(a) - (mean(b) - mean(c)) FROM cpu`)
+	same(`SELECT * FROM cpu`)
+	same(`SELECT mean(value) FROM cpu, mem`)
+
+	// WHERE
+	same(`SELECT mean(value) FROM cpu WHERE host = 'foo'`)
+	another(`SELECT mean(value) FROM cpu WHERE "host" <> 'foo'`, `SELECT mean(value) FROM cpu WHERE host != 'foo'`)
+	same(`SELECT mean(value) FROM cpu WHERE host =~ /^foo.+$/ AND dc !~ /a\/b/`)
+	same(`SELECT mean(value) FROM cpu WHERE host = 'a' OR host = 'b' AND dc = 'x'`)
+	same(`SELECT mean(value) FROM cpu WHERE (host = 'a' OR host = 'b') AND dc = 'x'`)
+	same(`SELECT mean(value) FROM cpu WHERE host = 'it\'s'`)
+	same(`SELECT mean(value) FROM cpu WHERE time > now() - 1h`)
+	another(`SELECT mean(value) FROM cpu WHERE time >= 1600000000000ms and time <= 1600003600000ms AND host = 'x'`,
+		`SELECT mean(value) FROM cpu WHERE host = 'x' AND time >= 1600000000s AND time <= 1600003600s`)
+	same(`SELECT mean(value) FROM cpu WHERE time > '2020-01-01T00:00:00Z'`)
+
+	// GROUP BY, fill, ORDER BY, LIMIT
+	same(`SELECT mean(value) FROM cpu WHERE time > now() - 1h GROUP BY time(1m)`)
+	another(`SELECT mean(value) FROM cpu GROUP BY time(60s, 15s), "host", dc`, `SELECT mean(value) FROM cpu GROUP BY time(1m, 15s), host, dc`)
+	same(`SELECT mean(value) FROM cpu GROUP BY time(1h, -15m)`)
+	another(`SELECT mean(value) FROM cpu GROUP BY time(1h30m), *`, `SELECT mean(value) FROM cpu GROUP BY time(90m), *`)
+	another(`SELECT mean(value) FROM cpu GROUP BY time(1m) fill(null)`, `SELECT mean(value) FROM cpu GROUP BY time(1m)`)
+	same(`SELECT mean(value) FROM cpu GROUP BY time(1m) fill(none)`)
+	same(`SELECT mean(value) FROM cpu GROUP BY time(1m) fill(-1.5)`)
+	same(`SELECT mean(value) FROM cpu GROUP BY time(1m) fill(previous)`)
+	same(`SELECT mean(value) FROM cpu GROUP BY time(1m) fill(linear)`)
+	same(`SELECT mean(value) FROM cpu GROUP BY time(1m) ORDER BY time DESC LIMIT 10 OFFSET 5 SLIMIT 3 SOFFSET 1`)
+	another(`SELECT mean(value) FROM cpu ORDER BY time ASC`, `SELECT mean(value) FROM cpu`)
+
+	// SHOW statements
+	same(`SHOW DATABASES`)
+	same(`SHOW RETENTION POLICIES`)
+	same(`SHOW RETENTION POLICIES ON telegraf`)
+	same(`SHOW MEASUREMENTS`)
+	same(`SHOW MEASUREMENTS ON telegraf WITH MEASUREMENT =~ /cpu.*/ WHERE host = 'foo' LIMIT 100`)
+	another(`SHOW MEASUREMENTS WITH MEASUREMENT = "cpu"`, `SHOW MEASUREMENTS WITH MEASUREMENT = cpu`)
+	same(`SHOW TAG KEYS`)
+	same(`SHOW TAG KEYS FROM cpu WHERE host = 'foo' LIMIT 5 OFFSET 2`)
+	same(`SHOW TAG VALUES WITH KEY = host`)
+	same(`SHOW TAG VALUES FROM cpu WITH KEY IN (host, dc) WHERE time > now() - 1d`)
+	same(`SHOW TAG VALUES FROM cpu WITH KEY =~ /ho.*/`)
+	same(`SHOW FIELD KEYS`)
+	same(`SHOW FIELD KEYS ON telegraf FROM cpu`)
+
+	// Multiple statements and comments
+	another("SHOW DATABASES; SHOW MEASUREMENTS -- comment\n;", `SHOW DATABASES; SHOW MEASUREMENTS`)
+}
+
+func TestParseFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		stmts, err := Parse(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when parsing %s; got %d statements", s, len(stmts))
+		}
+	}
+	f(``)
+	f(`;`)
+	f(`DROP MEASUREMENT cpu`)
+	f(`SELECT`)
+	f(`SELECT mean(value)`)
+	f(`SELECT mean(value) FROM`)
+	f(`SELECT mean(value) FROM /cpu/`)
+	f(`SELECT mean(value FROM cpu`)
+	f(`SELECT mean(value) INTO foo FROM cpu`)
+	f(`SELECT mean(value) FROM cpu WHERE host = 'foo`)
+	f(`SELECT mean(value) FROM cpu WHERE host =~ /foo`)
+	f(`SELECT mean(value) FROM cpu WHERE host =~ /[/`)
+	f(`SELECT mean(value) FROM cpu WHERE host = /foo/`)
+	f(`SELECT mean(value) FROM cpu WHERE time > now() - 1h OR host = 'foo'`)
+	f(`SELECT mean(value) FROM cpu WHERE time != now()`)
+	f(`SELECT mean(value) FROM cpu WHERE time > 'foobar'`)
+	f(`SELECT mean(value) FROM cpu WHERE time > foo()`)
+	f(`SELECT mean(value) FROM cpu GROUP BY time()`)
+	f(`SELECT mean(value) FROM cpu GROUP BY time(0s)`)
+	f(`SELECT mean(value) FROM cpu GROUP BY time(1x)`)
+	f(`SELECT mean(value) FROM cpu GROUP BY time(1m), time(2m)`)
+	f(`SELECT mean(value) FROM cpu GROUP BY /host/`)
+	f(`SELECT mean(value) FROM cpu GROUP BY time(1m) fill(foo)`)
+	f(`SELECT mean(value) FROM cpu ORDER BY host`)
+	f(`SELECT mean(value) FROM cpu LIMIT -1`)
+	f(`SELECT mean(value) FROM cpu LIMIT foo`)
+	f(`SELECT mean(value) FROM cpu tz('Europe/Berlin')`)
+	f(`SELECT mean(value) FROM cpu foo`)
+	f(`SHOW`)
+	f(`SHOW SERIES`)
+	f(`SHOW TAG foo`)
+	f(`SHOW TAG VALUES FROM cpu`)
+	f(`SHOW TAG VALUES WITH KEY`)
+	f(`SHOW TAG VALUES WITH KEY IN ()`)
+	f(`SHOW MEASUREMENTS WITH MEASUREMENT > cpu`)
+	f(`SHOW FIELD VALUES`)
+}
+
+func TestTimeConditionsTimeRange(t *testing.T) {
+	f := func(s string, now, startExpected, endExpected int64, hasStartExpected bool) {
+		t.Helper()
+		stmts, err := Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %s: %s", s, err)
+		}
+		ss := stmts[0].(*SelectStatement)
+		start, end, hasStart := ss.TimeConditions.TimeRange(now)
+		if start != startExpected || end != endExpected || hasStart != hasStartExpected {
+			t.Fatalf("unexpected time range for %s; got (%d, %d, %v); want (%d, %d, %v)", s, start, end, hasStart, startExpected, endExpected, hasStartExpected)
+		}
+	}
+	f(`SELECT mean(value) FROM cpu`, 1000, 0, 1000, false)
+	f(`SELECT mean(value) FROM cpu WHERE time > now() - 1s`, 5000, 4001, 5000, true)
+	f(`SELECT mean(value) FROM cpu WHERE time >= now() - 1s AND time < now()`, 5000, 4000, 4999, true)
+	f(`SELECT mean(value) FROM cpu WHERE time >= 1600000000000ms AND time <= 1600003600000ms`, 0, 1600000000000, 1600003600000, true)
+	f(`SELECT mean(value) FROM cpu WHERE time >= 1600000000000000000`, 1700000000000, 1600000000000, 1700000000000, true)
+	f(`SELECT mean(value) FROM cpu WHERE time >= '2020-09-13T12:26:40Z' AND time <= '2020-09-13 13:26:40'`, 0, 1600000000000, 1600003600000, true)
+	f(`SELECT mean(value) FROM cpu WHERE time = '2020-09-13'`, 0, 1599955200000, 1599955200000, true)
+	f(`SELECT mean(value) FROM cpu WHERE time > now() - 2h AND time > now() - 1h`, 3600*1000*5, 3600*1000*4+1, 3600*1000*5, true)
+}
+
+func TestParseDuration(t *testing.T) {
+	f := func(s string, msecsExpected int64) {
+		t.Helper()
+		msecs, err := parseDuration(s)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", s, err)
+		}
+		if msecs != msecsExpected {
+			t.Fatalf("unexpected duration for %q; got %d; want %d", s, msecs, msecsExpected)
+		}
+		if msecs > 0 {
+			s := formatDuration(msecs)
+			msecs, err := parseDuration(s)
+			if err != nil {
+				t.Fatalf("cannot parse formatted duration %q: %s", s, err)
+			}
+			if msecs != msecsExpected {
+				t.Fatalf("unexpected duration for formatted %q; got %d; want %d", s, msecs, msecsExpected)
+			}
+		}
+	}
+	f("1ns", 0)
+	f("1500000ns", 1)
+	f("2000u", 2)
+	f("3000µ", 3)
+	f("15ms", 15)
+	f("10s", 10000)
+	f("5m", 300000)
+	f("1h30m", 5400000)
+	f("2d", 2*24*3600*1000)
+	f("1w", 7*24*3600*1000)
+}
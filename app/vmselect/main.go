@@ -2,6 +2,7 @@ package vmselect
 
 import (
 	"embed"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
@@ -11,11 +12,15 @@ import (
 	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/graphite"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/influx"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/prometheus"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/querylog"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/quota"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/stats"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/tracestore"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
@@ -41,7 +46,7 @@ var (
 		"limit is reached; see also -search.maxQueryDuration")
 	resetCacheAuthKey    = flagutil.NewPassword("search.resetCacheAuthKey", "Optional authKey for resetting rollup cache via /internal/resetRollupResultCache call. It could be passed via authKey query arg. It overrides -httpAuth.*")
 	logSlowQueryDuration = flag.Duration("search.logSlowQueryDuration", 5*time.Second, "Log queries with execution time exceeding this value. Zero disables slow query logging. "+
-		"See also -search.logQueryMemoryUsage")
+		"See also -search.logQueryMemoryUsage and -search.queryLog.filePath")
 	vmalertProxyURL = flag.String("vmalert.proxyURL", "", "Optional URL for proxying requests to vmalert. For example, if -vmalert.proxyURL=http://vmalert:8880 , then alerting API requests such as /api/v1/rules from Grafana will be proxied to http://vmalert:8880/api/v1/rules")
 )
 
@@ -65,6 +70,10 @@ func Init() {
 	netstorage.InitTmpBlocksDir(tmpDirPath)
 	promql.InitRollupResultCache(*vmstorage.DataPath + "/cache/rollupResult")
 	prometheus.InitMaxUniqueTimeseries(*maxConcurrentRequests)
+	quota.Init()
+	graphite.Init()
+	querylog.Init(*logSlowQueryDuration)
+	tracestore.Init(*vmstorage.DataPath + "/queryTraces")
 
 	concurrencyLimitCh = make(chan struct{}, *maxConcurrentRequests)
 	initVMAlertProxy()
@@ -72,6 +81,7 @@ func Init() {
 
 // Stop stops vmselect
 func Stop() {
+	querylog.Stop()
 	promql.StopRollupResultCache()
 }
 
@@ -120,6 +130,34 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 	defer requestDuration.UpdateDuration(startTime)
 	tracerEnabled := httputil.GetBool(r, "trace")
 	qt := querytracer.New(tracerEnabled, "%s", r.URL.Path)
+	if qt == nil && tracestore.ShouldSample() {
+		// Trace the query without exposing the trace to the client, so it could be recorded if the query is slow.
+		qt = querytracer.NewHidden("%s", r.URL.Path)
+	}
+	if tracestore.Enabled() {
+		defer tracestore.RecordIfSlow(qt, r, startTime)
+	}
+
+	// Apply per-tenant quotas before the global concurrency limit,
+	// so queries waiting for the tenant quota do not occupy global concurrency slots.
+	tenant := quota.GetTenant(r)
+	if tenant != nil {
+		d := searchutil.GetMaxQueryDuration(r)
+		if d > *maxQueueDuration {
+			d = *maxQueueDuration
+		}
+		endQuery, err := tenant.BeginQuery(r, d)
+		if err != nil {
+			var esc *httpserver.ErrorWithStatusCode
+			if errors.As(err, &esc) && esc.StatusCode == http.StatusTooManyRequests {
+				w.Header().Add("Retry-After", "10")
+			}
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		defer endQuery()
+		qt.Printf("apply quotas for tenant %q", tenant.Name())
+	}
 
 	// Limit the number of concurrent queries.
 	select {
@@ -226,6 +264,15 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/api/v1/query/explain":
+		queryExplainRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.QueryExplainHandler(qt, startTime, w, r); err != nil {
+			queryExplainErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
 	case "/api/v1/series":
 		seriesRequests.Inc()
 		httpserver.EnableCORS(w, r)
@@ -253,6 +300,24 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/api/v1/metadata":
+		metadataRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.MetadataHandler(qt, startTime, w, r); err != nil {
+			metadataErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
+	case "/api/v1/query_exemplars":
+		queryExemplarsRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.QueryExemplarsHandler(qt, startTime, w, r); err != nil {
+			queryExemplarsErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
 	case "/api/v1/status/tsdb":
 		statusTSDBRequests.Inc()
 		httpserver.EnableCORS(w, r)
@@ -390,6 +455,16 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/influx/query", "/query":
+		influxQueryRequests.Inc()
+		// This is needed for some clients, which expect InfluxDB version header.
+		w.Header().Set("X-Influxdb-Version", "1.8.0")
+		if err := influx.QueryHandler(qt, startTime, w, r); err != nil {
+			influxQueryErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		return true
 	case "/api/v1/admin/tsdb/delete_series":
 		if !httpserver.CheckAuthFlag(w, r, deleteAuthKey) {
 			return true
@@ -512,11 +587,55 @@ func handleStaticAndSimpleRequests(w http.ResponseWriter, r *http.Request, path
 	}
 
 	switch path {
+	case "/internal/rollupResultCache/get":
+		// Handle cache requests from peers outside -search.maxConcurrentRequests limit,
+		// since they are cheap, while peers executing queries wait for them.
+		rollupResultCacheGetRequests.Inc()
+		if err := promql.RollupResultCacheGetHandler(w, r); err != nil {
+			rollupResultCacheGetErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		return true
+	case "/internal/rollupResultCache/put":
+		rollupResultCachePutRequests.Inc()
+		if err := promql.RollupResultCachePutHandler(w, r); err != nil {
+			rollupResultCachePutErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		return true
+	case "/internal/rollupResultCache/reset":
+		rollupResultCacheResetRequests.Inc()
+		if err := promql.RollupResultCacheResetHandler(w, r); err != nil {
+			rollupResultCacheResetErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		return true
 	case "/api/v1/status/active_queries":
 		statusActiveQueriesRequests.Inc()
 		httpserver.EnableCORS(w, r)
 		promql.ActiveQueriesHandler(w, r)
 		return true
+	case "/api/v1/status/traces":
+		statusTracesRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := tracestore.TracesHandler(w, r); err != nil {
+			statusTracesErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
+	case "/api/v1/status/traces/compare":
+		statusTracesCompareRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := tracestore.CompareTracesHandler(w, r); err != nil {
+			statusTracesCompareErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
 	case "/api/v1/status/top_queries":
 		topQueriesRequests.Inc()
 		httpserver.EnableCORS(w, r)
@@ -562,12 +681,6 @@ func handleStaticAndSimpleRequests(w http.ResponseWriter, r *http.Request, path
 		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprint(w, `{"status":"success","data":{"alerts":[]}}`)
 		return true
-	case "/api/v1/metadata":
-		// Return dumb placeholder for https://prometheus.io/docs/prometheus/latest/querying/api/#querying-metric-metadata
-		metadataRequests.Inc()
-		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprintf(w, "%s", `{"status":"success","data":{}}`)
-		return true
 	case "/api/v1/status/buildinfo":
 		buildInfoRequests.Inc()
 		w.Header().Set("Content-Type", "application/json")
@@ -576,12 +689,6 @@ func handleStaticAndSimpleRequests(w http.ResponseWriter, r *http.Request, path
 		// see this issue for more info: https://github.com/VictoriaMetrics/VictoriaMetrics/issues/5370
 		fmt.Fprintf(w, "%s", `{"status":"success","data":{"version":"2.24.0"}}`)
 		return true
-	case "/api/v1/query_exemplars":
-		// Return dumb placeholder for https://prometheus.io/docs/prometheus/latest/querying/api/#querying-exemplars
-		queryExemplarsRequests.Inc()
-		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprintf(w, "%s", `{"status":"success","data":[]}`)
-		return true
 	default:
 		return false
 	}
@@ -611,6 +718,9 @@ var (
 	queryRangeRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/query_range"}`)
 	queryRangeErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/query_range"}`)
 
+	queryExplainRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/query/explain"}`)
+	queryExplainErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/query/explain"}`)
+
 	seriesRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/series"}`)
 	seriesErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/series"}`)
 
@@ -625,6 +735,11 @@ var (
 
 	statusActiveQueriesRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/active_queries"}`)
 
+	statusTracesRequests        = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/traces"}`)
+	statusTracesErrors          = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/traces"}`)
+	statusTracesCompareRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/traces/compare"}`)
+	statusTracesCompareErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/traces/compare"}`)
+
 	topQueriesRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/top_queries"}`)
 	topQueriesErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/top_queries"}`)
 
@@ -679,6 +794,9 @@ var (
 	graphiteRenderRequests = metrics.NewCounter(`vm_http_requests_total{path="/render"}`)
 	graphiteRenderErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/render"}`)
 
+	influxQueryRequests = metrics.NewCounter(`vm_http_requests_total{path="/influx/query", protocol="influx"}`)
+	influxQueryErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/influx/query", protocol="influx"}`)
+
 	promscrapeMetricRelabelDebugRequests = metrics.NewCounter(`vm_http_requests_total{path="/metric-relabel-debug"}`)
 	promscrapeTargetRelabelDebugRequests = metrics.NewCounter(`vm_http_requests_total{path="/target-relabel-debug"}`)
 
@@ -696,14 +814,25 @@ var (
 	alertsRequests  = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/alerts"}`)
 
 	metadataRequests       = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/metadata"}`)
+	metadataErrors         = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/metadata"}`)
 	buildInfoRequests      = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/buildinfo"}`)
 	queryExemplarsRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/query_exemplars"}`)
+	queryExemplarsErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/query_exemplars"}`)
 
 	metricNamesStatsRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/metric_names_stats"}`)
 	metricNamesStatsErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/metric_names_stats"}`)
 
 	metricNamesStatsResetRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/admin/status/metric_names_stats/reset"}`)
 	metricNamesStatsResetErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/admin/status/metric_names_stats/reset"}`)
+
+	rollupResultCacheGetRequests = metrics.NewCounter(`vm_http_requests_total{path="/internal/rollupResultCache/get"}`)
+	rollupResultCacheGetErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/internal/rollupResultCache/get"}`)
+
+	rollupResultCachePutRequests = metrics.NewCounter(`vm_http_requests_total{path="/internal/rollupResultCache/put"}`)
+	rollupResultCachePutErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/internal/rollupResultCache/put"}`)
+
+	rollupResultCacheResetRequests = metrics.NewCounter(`vm_http_requests_total{path="/internal/rollupResultCache/reset"}`)
+	rollupResultCacheResetErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/internal/rollupResultCache/reset"}`)
 )
 
 func proxyVMAlertRequests(w http.ResponseWriter, r *http.Request) {
@@ -13,6 +13,7 @@ import (
 	"github.com/VictoriaMetrics/metrics"
 	"github.com/VictoriaMetrics/metricsql"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/quota"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
@@ -60,6 +61,9 @@ type Results struct {
 	tr       storage.TimeRange
 	deadline searchutil.Deadline
 
+	// samplesScanned is the number of raw samples in the data blocks selected for the search.
+	samplesScanned int
+
 	packedTimeseries []packedTimeseries
 	sr               *storage.Search
 	tbf              *tmpBlocksFile
@@ -70,6 +74,11 @@ func (rss *Results) Len() int {
 	return len(rss.packedTimeseries)
 }
 
+// SamplesScanned returns the number of raw samples in the data blocks selected for rss.
+func (rss *Results) SamplesScanned() int {
+	return rss.samplesScanned
+}
+
 // Cancel cancels rss work.
 func (rss *Results) Cancel() {
 	rss.mustClose()
@@ -687,7 +696,9 @@ func (sb *sortBlock) reset() {
 func (sb *sortBlock) unpackFrom(tmpBlock *storage.Block, tbf *tmpBlocksFile, br blockRef, tr storage.TimeRange) error {
 	tmpBlock.Reset()
 	brReal := tbf.MustReadBlockRefAt(br.partRef, br.addr)
-	brReal.MustReadBlock(tmpBlock)
+	if err := brReal.ReadBlock(tmpBlock); err != nil {
+		return err
+	}
 	if err := tmpBlock.UnmarshalData(); err != nil {
 		return fmt.Errorf("cannot unmarshal block: %w", err)
 	}
@@ -776,6 +787,20 @@ func DeleteSeries(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline sear
 	return vmstorage.DeleteSeries(qt, tfss, sq.MaxMetrics)
 }
 
+// DeleteSeriesOnTimeRange deletes samples on the time range from sq for series matching sq.
+//
+// The series remain available outside the time range.
+func DeleteSeriesOnTimeRange(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline searchutil.Deadline) (int, error) {
+	qt = qt.NewChild("delete samples: %s", sq)
+	defer qt.Done()
+	tr := sq.GetTimeRange()
+	tfss, err := setupTfss(qt, tr, sq.TagFilterss, sq.MaxMetrics, deadline)
+	if err != nil {
+		return 0, err
+	}
+	return vmstorage.DeleteSeriesOnTimeRange(qt, tfss, tr, sq.MaxMetrics)
+}
+
 // LabelNames returns label names matching the given sq until the given deadline.
 func LabelNames(qt *querytracer.Tracer, sq *storage.SearchQuery, maxLabelNames int, deadline searchutil.Deadline) ([]string, error) {
 	qt = qt.NewChild("get labels: %s", sq)
@@ -926,6 +951,9 @@ func TagValueSuffixes(qt *querytracer.Tracer, tr storage.TimeRange, tagKey, tagV
 // TSDBStatus returns tsdb status according to https://prometheus.io/docs/prometheus/latest/querying/api/#tsdb-stats
 //
 // It accepts arbitrary filters on time series in sq.
+//
+// If the time range in sq spans multiple days, then the status is calculated for unique series on these days
+// and it contains per-day series churn stats.
 func TSDBStatus(qt *querytracer.Tracer, sq *storage.SearchQuery, focusLabel string, topN int, deadline searchutil.Deadline) (*storage.TSDBStatus, error) {
 	qt = qt.NewChild("get tsdb stats: %s, focusLabel=%q, topN=%d", sq, focusLabel, topN)
 	defer qt.Done()
@@ -938,7 +966,13 @@ func TSDBStatus(qt *querytracer.Tracer, sq *storage.SearchQuery, focusLabel stri
 		return nil, err
 	}
 	date := uint64(tr.MinTimestamp) / (3600 * 24 * 1000)
-	status, err := vmstorage.GetTSDBStatus(qt, tfss, date, focusLabel, topN, sq.MaxMetrics, deadline.Deadline())
+	maxDate := uint64(tr.MaxTimestamp) / (3600 * 24 * 1000)
+	var status *storage.TSDBStatus
+	if maxDate > date {
+		status, err = vmstorage.GetTSDBStatusOnDateRange(qt, tfss, date, maxDate, focusLabel, topN, sq.MaxMetrics, deadline.Deadline())
+	} else {
+		status, err = vmstorage.GetTSDBStatus(qt, tfss, date, focusLabel, topN, sq.MaxMetrics, deadline.Deadline())
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error during tsdb status request: %w", err)
 	}
@@ -1046,7 +1080,15 @@ func ExportBlocks(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline sear
 			return fmt.Errorf("cannot unmarshal metricName for block #%d: %w", blocksRead, err)
 		}
 		br := sr.MetricBlockRef.BlockRef
-		br.MustReadBlock(&xw.b)
+		if err := br.ReadBlock(&xw.b); err != nil {
+			return fmt.Errorf("cannot read data block #%d: %w", blocksRead, err)
+		}
+		if xw.b.RowsCount() == 0 {
+			// All the samples in the block have been deleted.
+			xw.reset()
+			exportWorkPool.Put(xw)
+			continue
+		}
 		samples += br.RowsCount()
 		workCh <- xw
 	}
@@ -1115,10 +1157,77 @@ func SearchMetricNames(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline
 	return metricNames, nil
 }
 
-// ProcessSearchQuery performs sq until the given deadline.
+// SearchEstimate contains estimates for the search query obtained without reading data blocks.
+type SearchEstimate struct {
+	// Series is the number of series matching the search query according to indexdb.
+	Series int
+
+	// Blocks is the number of data blocks, which would be read by the search query.
+	Blocks int
+
+	// Samples is the number of samples in the data blocks, which would be read by the search query.
+	//
+	// This is an upper bound, since blocks at the edges of the time range may contain samples outside the time range.
+	// This is the same number, which is compared against -search.maxSamplesPerQuery during the query execution.
+	Samples int
+}
+
+// EstimateSearchQuery returns estimates for sq until the given deadline.
+//
+// The estimates are obtained from indexdb and from block headers, so data blocks aren't read.
+// The same limits as for ProcessSearchQuery are applied, so EstimateSearchQuery returns an error
+// if sq would fail because of -search.maxUniqueTimeseries or -search.maxSamplesPerQuery limits
+// or because of the quotas for the given tenant.
+func EstimateSearchQuery(qt *querytracer.Tracer, tenant *quota.Tenant, sq *storage.SearchQuery, deadline searchutil.Deadline) (*SearchEstimate, error) {
+	qt = qt.NewChild("estimate matching series: %s", sq)
+	defer qt.Done()
+	if deadline.Exceeded() {
+		return nil, fmt.Errorf("timeout exceeded before starting the query processing: %s", deadline.String())
+	}
+
+	tr := sq.GetTimeRange()
+	if err := vmstorage.CheckTimeRange(tr); err != nil {
+		return nil, err
+	}
+	tfss, err := setupTfss(qt, tr, sq.TagFilterss, sq.MaxMetrics, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	vmstorage.WG.Add(1)
+	defer vmstorage.WG.Done()
+
+	maxSamples := tenant.MaxSamplesPerQuery(*maxSamplesPerQuery)
+	sr := getStorageSearch()
+	defer putStorageSearch(sr)
+	var se SearchEstimate
+	se.Series = sr.Init(qt, vmstorage.Storage, tfss, tr, sq.MaxMetrics, deadline.Deadline())
+	for sr.NextMetricBlock() {
+		se.Blocks++
+		if deadline.Exceeded() {
+			return nil, fmt.Errorf("timeout exceeded while scanning block header #%d: %s", se.Blocks, deadline.String())
+		}
+		se.Samples += sr.MetricBlockRef.BlockRef.RowsCount()
+		if maxSamples > 0 && se.Samples > maxSamples {
+			return nil, newMaxSamplesPerQueryError(tenant, maxSamples)
+		}
+	}
+	if err := sr.Error(); err != nil {
+		if errors.Is(err, storage.ErrDeadlineExceeded) {
+			return nil, fmt.Errorf("timeout exceeded during the query: %s", deadline.String())
+		}
+		return nil, fmt.Errorf("search error after scanning %d block headers: %w", se.Blocks, err)
+	}
+	qt.Printf("found %d series with %d blocks and %d samples", se.Series, se.Blocks, se.Samples)
+	return &se, nil
+}
+
+// ProcessSearchQuery performs sq for the given tenant until the given deadline.
+//
+// The number of scanned samples is registered at the tenant. The tenant may be nil.
 //
 // Results.RunParallel or Results.Cancel must be called on the returned Results.
-func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline searchutil.Deadline) (*Results, error) {
+func ProcessSearchQuery(qt *querytracer.Tracer, tenant *quota.Tenant, sq *storage.SearchQuery, deadline searchutil.Deadline) (*Results, error) {
 	qt = qt.NewChild("fetch matching series: %s", sq)
 	defer qt.Done()
 	if deadline.Exceeded() {
@@ -1146,6 +1255,10 @@ func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadlin
 
 	blocksRead := 0
 	samples := 0
+	maxSamples := tenant.MaxSamplesPerQuery(*maxSamplesPerQuery)
+	defer func() {
+		tenant.AddSamplesScanned(samples)
+	}()
 	tbf := getTmpBlocksFile()
 	var buf []byte
 	var metricNamePrev []byte
@@ -1193,11 +1306,10 @@ func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadlin
 		// are left then because of the given time range.
 		// This allows effectively limiting CPU resources used per query.
 		samples += br.RowsCount()
-		if *maxSamplesPerQuery > 0 && samples > *maxSamplesPerQuery {
+		if maxSamples > 0 && samples > maxSamples {
 			putTmpBlocksFile(tbf)
 			putStorageSearch(sr)
-			return nil, fmt.Errorf("cannot select more than -search.maxSamplesPerQuery=%d samples; possible solutions: increase the -search.maxSamplesPerQuery; "+
-				"reduce time range for the query; use more specific label filters in order to select fewer series", *maxSamplesPerQuery)
+			return nil, newMaxSamplesPerQueryError(tenant, maxSamples)
 		}
 
 		buf = br.Marshal(buf[:0])
@@ -1278,6 +1390,7 @@ func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadlin
 	var rss Results
 	rss.tr = tr
 	rss.deadline = deadline
+	rss.samplesScanned = samples
 	pts := make([]packedTimeseries, len(orderedMetricNames))
 	for i, metricName := range orderedMetricNames {
 		pts[i] = packedTimeseries{
@@ -1291,6 +1404,15 @@ func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadlin
 	return &rss, nil
 }
 
+func newMaxSamplesPerQueryError(tenant *quota.Tenant, maxSamples int) error {
+	if maxSamples != *maxSamplesPerQuery {
+		return fmt.Errorf("cannot select more than max_samples_per_query=%d samples for tenant %q; possible solutions: increase max_samples_per_query for the tenant at -search.quotaConfig; "+
+			"reduce time range for the query; use more specific label filters in order to select fewer series", maxSamples, tenant.Name())
+	}
+	return fmt.Errorf("cannot select more than -search.maxSamplesPerQuery=%d samples; possible solutions: increase the -search.maxSamplesPerQuery; "+
+		"reduce time range for the query; use more specific label filters in order to select fewer series", maxSamples)
+}
+
 type blockRef struct {
 	partRef storage.PartRef
 	addr    tmpBlockAddr
@@ -1375,6 +1497,60 @@ func GetMetricNamesStats(qt *querytracer.Tracer, limit, le int, matchPattern str
 	return vmstorage.GetMetricNamesStats(qt, limit, le, matchPattern)
 }
 
+// GetMetricsMetadata returns metadata for the ingested metric families.
+//
+// If metricName is non-empty, then only metadata for the given metric family is returned.
+func GetMetricsMetadata(qt *querytracer.Tracer, metricName string, limit, limitPerMetric int) ([]storage.MetricMetadata, error) {
+	qt = qt.NewChild("get metrics metadata: metric=%q, limit=%d, limit_per_metric=%d", metricName, limit, limitPerMetric)
+	defer qt.Done()
+	return vmstorage.GetMetricsMetadata(qt, nil, metricName, limit, limitPerMetric), nil
+}
+
+// SeriesExemplars contains exemplars for a single series.
+type SeriesExemplars struct {
+	MetricName storage.MetricName
+	Exemplars  []storage.Exemplar
+}
+
+// SearchExemplars returns exemplars for series matching the given sq.
+//
+// The returned series are sorted by marshaled metric name.
+func SearchExemplars(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline searchutil.Deadline) ([]SeriesExemplars, error) {
+	qt = qt.NewChild("fetch exemplars: %s", sq)
+	defer qt.Done()
+	if deadline.Exceeded() {
+		return nil, fmt.Errorf("timeout exceeded before starting to search exemplars: %s", deadline.String())
+	}
+
+	// Setup search.
+	tr := sq.GetTimeRange()
+	if err := vmstorage.CheckTimeRange(tr); err != nil {
+		return nil, err
+	}
+	tfss, err := setupTfss(qt, tr, sq.TagFilterss, sq.MaxMetrics, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	ses, err := vmstorage.SearchExemplars(qt, tfss, tr, sq.MaxMetrics, deadline.Deadline())
+	if err != nil {
+		return nil, fmt.Errorf("cannot find exemplars: %w", err)
+	}
+	sort.Slice(ses, func(i, j int) bool {
+		return string(ses[i].MetricName) < string(ses[j].MetricName)
+	})
+	result := make([]SeriesExemplars, len(ses))
+	for i := range ses {
+		se := &ses[i]
+		if err := result[i].MetricName.Unmarshal(se.MetricName); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal metricName %q: %w", se.MetricName, err)
+		}
+		result[i].Exemplars = se.Exemplars
+	}
+	qt.Printf("found exemplars for %d series", len(result))
+	return result, nil
+}
+
 // ResetMetricNamesStats resets state of metric names usage
 func ResetMetricNamesStats(qt *querytracer.Tracer) error {
 	qt = qt.NewChild("reset metric names usage stats")
@@ -0,0 +1,113 @@
+// Code generated by qtc from "metadata_response.qtpl". DO NOT EDIT.
+// See https://github.com/valyala/quicktemplate for details.
+
+//line app/vmselect/prometheus/metadata_response.qtpl:3
+package prometheus
+
+//line app/vmselect/prometheus/metadata_response.qtpl:3
+import (
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// MetadataResponse generates response for /api/v1/metadata .See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-metric-metadata
+
+//line app/vmselect/prometheus/metadata_response.qtpl:11
+import (
+	qtio422016 "io"
+
+	qt422016 "github.com/valyala/quicktemplate"
+)
+
+//line app/vmselect/prometheus/metadata_response.qtpl:11
+var (
+	_ = qtio422016.Copy
+	_ = qt422016.AcquireByteBuffer
+)
+
+//line app/vmselect/prometheus/metadata_response.qtpl:11
+func StreamMetadataResponse(qw422016 *qt422016.Writer, groups [][]storage.MetricMetadata, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/metadata_response.qtpl:11
+	qw422016.N().S(`{"status":"success","data":{`)
+//line app/vmselect/prometheus/metadata_response.qtpl:15
+	for i, rows := range groups {
+//line app/vmselect/prometheus/metadata_response.qtpl:16
+		qw422016.N().Q(rows[0].MetricFamilyName)
+//line app/vmselect/prometheus/metadata_response.qtpl:16
+		qw422016.N().S(`:[`)
+//line app/vmselect/prometheus/metadata_response.qtpl:17
+		for j := range rows {
+//line app/vmselect/prometheus/metadata_response.qtpl:18
+			row := &rows[j]
+
+//line app/vmselect/prometheus/metadata_response.qtpl:18
+			qw422016.N().S(`{"type":`)
+//line app/vmselect/prometheus/metadata_response.qtpl:20
+			qw422016.N().Q(prompb.MetricType(row.Type).String())
+//line app/vmselect/prometheus/metadata_response.qtpl:20
+			qw422016.N().S(`,"help":`)
+//line app/vmselect/prometheus/metadata_response.qtpl:21
+			qw422016.N().Q(row.Help)
+//line app/vmselect/prometheus/metadata_response.qtpl:21
+			qw422016.N().S(`,"unit":`)
+//line app/vmselect/prometheus/metadata_response.qtpl:22
+			qw422016.N().Q(row.Unit)
+//line app/vmselect/prometheus/metadata_response.qtpl:22
+			qw422016.N().S(`}`)
+//line app/vmselect/prometheus/metadata_response.qtpl:24
+			if j+1 < len(rows) {
+//line app/vmselect/prometheus/metadata_response.qtpl:24
+				qw422016.N().S(`,`)
+//line app/vmselect/prometheus/metadata_response.qtpl:24
+			}
+//line app/vmselect/prometheus/metadata_response.qtpl:25
+		}
+//line app/vmselect/prometheus/metadata_response.qtpl:25
+		qw422016.N().S(`]`)
+//line app/vmselect/prometheus/metadata_response.qtpl:27
+		if i+1 < len(groups) {
+//line app/vmselect/prometheus/metadata_response.qtpl:27
+			qw422016.N().S(`,`)
+//line app/vmselect/prometheus/metadata_response.qtpl:27
+		}
+//line app/vmselect/prometheus/metadata_response.qtpl:28
+	}
+//line app/vmselect/prometheus/metadata_response.qtpl:28
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/metadata_response.qtpl:31
+	qt.Printf("generate response for %d metric families", len(groups))
+	qt.Done()
+
+//line app/vmselect/prometheus/metadata_response.qtpl:34
+	streamdumpQueryTrace(qw422016, qt)
+//line app/vmselect/prometheus/metadata_response.qtpl:34
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+}
+
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+func WriteMetadataResponse(qq422016 qtio422016.Writer, groups [][]storage.MetricMetadata, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+	StreamMetadataResponse(qw422016, groups, qt)
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+}
+
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+func MetadataResponse(groups [][]storage.MetricMetadata, qt *querytracer.Tracer) string {
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+	WriteMetadataResponse(qb422016, groups, qt)
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+	return qs422016
+//line app/vmselect/prometheus/metadata_response.qtpl:36
+}
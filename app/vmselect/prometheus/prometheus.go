@@ -18,7 +18,9 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/querylog"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/querystats"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/quota"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bufferedwriter"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
@@ -48,6 +50,10 @@ var (
 		"See also '-search.setLookbackToStep' flag")
 	setLookbackToStep = flag.Bool("search.setLookbackToStep", false, "Whether to fix lookback interval to 'step' query arg value. "+
 		"If set to true, the query model becomes closer to InfluxDB data model. If set to true, then -search.maxLookback and -search.maxStalenessInterval are ignored")
+	promqlCompat = flag.Bool("search.promqlCompat", false, "Whether to evaluate queries at /api/v1/query and /api/v1/query_range in Prometheus compatibility mode. "+
+		"In this mode only PromQL queries are accepted, while rollup functions are calculated in the same way as Prometheus does. "+
+		"It can be overridden on per-query basis via compat query arg. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#prometheus-compatibility-mode")
 	maxStepForPointsAdjustment = flag.Duration("search.maxStepForPointsAdjustment", time.Minute, "The maximum step when /api/v1/query_range handler adjusts "+
 		"points with timestamps closer than -search.latencyOffset to the current time. The adjustment is needed because such points may contain incomplete data")
 
@@ -126,8 +132,8 @@ func FederateHandler(startTime time.Time, w http.ResponseWriter, r *http.Request
 	if cp.IsDefaultTimeRange() {
 		cp.start = cp.end - lookbackDelta
 	}
-	sq := storage.NewSearchQuery(cp.start, cp.end, cp.filterss, *maxFederateSeries)
-	rss, err := netstorage.ProcessSearchQuery(nil, sq, cp.deadline)
+	sq := storage.NewSearchQuery(cp.start, cp.end, cp.filterss, cp.tenant.MaxSeries(*maxFederateSeries))
+	rss, err := netstorage.ProcessSearchQuery(nil, cp.tenant, sq, cp.deadline)
 	if err != nil {
 		return fmt.Errorf("cannot fetch data for %q: %w", sq, err)
 	}
@@ -171,7 +177,7 @@ func ExportCSVHandler(startTime time.Time, w http.ResponseWriter, r *http.Reques
 	fieldNames := strings.Split(format, ",")
 	reduceMemUsage := httputil.GetBool(r, "reduce_mem_usage")
 
-	sq := storage.NewSearchQuery(cp.start, cp.end, cp.filterss, *maxExportSeries)
+	sq := storage.NewSearchQuery(cp.start, cp.end, cp.filterss, cp.tenant.MaxSeries(*maxExportSeries))
 	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
 	bw := bufferedwriter.Get(w)
 	defer bufferedwriter.Put(bw)
@@ -186,7 +192,7 @@ func ExportCSVHandler(startTime time.Time, w http.ResponseWriter, r *http.Reques
 	}
 	doneCh := make(chan error, 1)
 	if !reduceMemUsage {
-		rss, err := netstorage.ProcessSearchQuery(nil, sq, cp.deadline)
+		rss, err := netstorage.ProcessSearchQuery(nil, cp.tenant, sq, cp.deadline)
 		if err != nil {
 			return fmt.Errorf("cannot fetch data for %q: %w", sq, err)
 		}
@@ -251,7 +257,7 @@ func ExportNativeHandler(startTime time.Time, w http.ResponseWriter, r *http.Req
 		return err
 	}
 
-	sq := storage.NewSearchQuery(cp.start, cp.end, cp.filterss, *maxExportSeries)
+	sq := storage.NewSearchQuery(cp.start, cp.end, cp.filterss, cp.tenant.MaxSeries(*maxExportSeries))
 	w.Header().Set("Content-Type", "VictoriaMetrics/native")
 	bw := bufferedwriter.Get(w)
 	defer bufferedwriter.Put(bw)
@@ -396,12 +402,12 @@ func exportHandler(qt *querytracer.Tracer, w http.ResponseWriter, cp *commonPara
 		}
 	}
 
-	sq := storage.NewSearchQuery(cp.start, cp.end, cp.filterss, *maxExportSeries)
+	sq := storage.NewSearchQuery(cp.start, cp.end, cp.filterss, cp.tenant.MaxSeries(*maxExportSeries))
 	w.Header().Set("Content-Type", contentType)
 
 	doneCh := make(chan error, 1)
 	if !reduceMemUsage {
-		rss, err := netstorage.ProcessSearchQuery(qt, sq, cp.deadline)
+		rss, err := netstorage.ProcessSearchQuery(qt, cp.tenant, sq, cp.deadline)
 		if err != nil {
 			return fmt.Errorf("cannot fetch data for %q: %w", sq, err)
 		}
@@ -497,11 +503,14 @@ func DeleteHandler(startTime time.Time, r *http.Request) error {
 	}
 	cp.deadline = searchutil.GetDeadlineForDelete(r, startTime)
 
-	if !cp.IsDefaultTimeRange() {
-		return fmt.Errorf("start=%d and end=%d args aren't supported. Remove these args from the query in order to delete all the matching metrics", cp.start, cp.end)
-	}
 	sq := storage.NewSearchQuery(cp.start, cp.end, cp.filterss, *maxDeleteSeries)
-	deletedCount, err := netstorage.DeleteSeries(nil, sq, cp.deadline)
+	var deletedCount int
+	if cp.IsDefaultTimeRange() {
+		deletedCount, err = netstorage.DeleteSeries(nil, sq, cp.deadline)
+	} else {
+		// Delete only samples on the given time range, so the matching series remain available outside it.
+		deletedCount, err = netstorage.DeleteSeriesOnTimeRange(nil, sq, cp.deadline)
+	}
 	if err != nil {
 		return fmt.Errorf("cannot delete time series: %w", err)
 	}
@@ -552,6 +561,9 @@ const secsPerDay = 3600 * 24
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#tsdb-stats
 //
 // It can accept `match[]` filters in order to narrow down the search.
+//
+// It can accept `startDate` and `endDate` args instead of `date` arg in order to obtain the status
+// for the given range of days together with per-day series churn stats.
 func TSDBStatusHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
 	defer tsdbStatusDuration.UpdateDuration(startTime)
 
@@ -567,11 +579,34 @@ func TSDBStatusHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 		if dateStr == "0" {
 			date = 0
 		} else {
-			t, err := time.Parse("2006-01-02", dateStr)
+			date, err = parseTSDBStatusDate(dateStr, "date")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	maxDate := date
+	startDateStr := r.FormValue("startDate")
+	endDateStr := r.FormValue("endDate")
+	if len(startDateStr) > 0 || len(endDateStr) > 0 {
+		if len(dateStr) > 0 {
+			return fmt.Errorf("`date` arg cannot be used together with `startDate` and `endDate` args")
+		}
+		if len(startDateStr) > 0 {
+			date, err = parseTSDBStatusDate(startDateStr, "startDate")
+			if err != nil {
+				return err
+			}
+		}
+		maxDate = fasttime.UnixDate()
+		if len(endDateStr) > 0 {
+			maxDate, err = parseTSDBStatusDate(endDateStr, "endDate")
 			if err != nil {
-				return fmt.Errorf("cannot parse `date` arg %q: %w", dateStr, err)
+				return err
 			}
-			date = uint64(t.Unix()) / secsPerDay
+		}
+		if date > maxDate {
+			return fmt.Errorf("`startDate` arg cannot exceed `endDate` arg; got %q vs %q", startDateStr, endDateStr)
 		}
 	}
 	focusLabel := r.FormValue("focusLabel")
@@ -591,7 +626,7 @@ func TSDBStatusHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 		topN = n
 	}
 	start := int64(date*secsPerDay) * 1000
-	end := int64((date+1)*secsPerDay)*1000 - 1
+	end := int64((maxDate+1)*secsPerDay)*1000 - 1
 	sq := storage.NewSearchQuery(start, end, cp.filterss, *maxTSDBStatusSeries)
 	status, err := netstorage.TSDBStatus(qt, sq, focusLabel, topN, cp.deadline)
 	if err != nil {
@@ -610,6 +645,14 @@ func TSDBStatusHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 
 var tsdbStatusDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/status/tsdb"}`)
 
+func parseTSDBStatusDate(s, argName string) (uint64, error) {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse `%s` arg %q: %w", argName, s, err)
+	}
+	return uint64(t.Unix()) / secsPerDay, nil
+}
+
 // LabelsHandler processes /api/v1/labels request.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#getting-label-names
@@ -642,6 +685,111 @@ func LabelsHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseW
 
 var labelsDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/labels"}`)
 
+// MetadataHandler processes /api/v1/metadata request.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-metric-metadata
+func MetadataHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer metadataDuration.UpdateDuration(startTime)
+
+	limit, err := httputil.GetInt(r, "limit")
+	if err != nil {
+		return err
+	}
+	limitPerMetric, err := httputil.GetInt(r, "limit_per_metric")
+	if err != nil {
+		return err
+	}
+	metricName := r.FormValue("metric")
+	rows, err := netstorage.GetMetricsMetadata(qt, metricName, limit, limitPerMetric)
+	if err != nil {
+		return fmt.Errorf("cannot obtain metrics metadata: %w", err)
+	}
+
+	// Group rows by metric family name. The rows are already sorted by metric family name.
+	var groups [][]storage.MetricMetadata
+	for len(rows) > 0 {
+		n := 1
+		for n < len(rows) && rows[n].MetricFamilyName == rows[0].MetricFamilyName {
+			n++
+		}
+		groups = append(groups, rows[:n])
+		rows = rows[n:]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteMetadataResponse(bw, groups, qt)
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot send metadata response to remote client: %w", err)
+	}
+	return nil
+}
+
+var metadataDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/metadata"}`)
+
+// QueryExemplarsHandler processes /api/v1/query_exemplars request.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-exemplars
+func QueryExemplarsHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer queryExemplarsDuration.UpdateDuration(startTime)
+
+	query := r.FormValue("query")
+	if len(query) == 0 {
+		return fmt.Errorf("missing `query` arg")
+	}
+	expr, err := metricsql.Parse(query)
+	if err != nil {
+		return fmt.Errorf("cannot parse query %q: %w", query, err)
+	}
+	// Exemplars are returned for all the series selectors in the query in the same way as Prometheus does.
+	var filterss [][]storage.TagFilter
+	metricsql.VisitAll(expr, func(e metricsql.Expr) {
+		if me, ok := e.(*metricsql.MetricExpr); ok && len(me.LabelFilterss) > 0 {
+			filterss = append(filterss, searchutil.ToTagFilterss(me.LabelFilterss)...)
+		}
+	})
+	if len(filterss) == 0 {
+		return fmt.Errorf("query %q must contain at least a single series selector", query)
+	}
+	etfs, err := searchutil.GetExtraTagFilters(r)
+	if err != nil {
+		return err
+	}
+	filterss = searchutil.JoinTagFilterss(filterss, etfs)
+
+	ct := startTime.UnixNano() / 1e6
+	end, err := httputil.GetTime(r, "end", ct)
+	if err != nil {
+		return err
+	}
+	start, err := httputil.GetTime(r, "start", end-defaultStep)
+	if err != nil {
+		return err
+	}
+	if end < start {
+		end = start
+	}
+
+	deadline := searchutil.GetDeadlineForQuery(r, startTime)
+	sq := storage.NewSearchQuery(start, end, filterss, *maxSeriesLimit)
+	ses, err := netstorage.SearchExemplars(qt, sq, deadline)
+	if err != nil {
+		return fmt.Errorf("cannot fetch exemplars for %q: %w", sq, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteQueryExemplarsResponse(bw, ses, qt)
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot send exemplars response to remote client: %w", err)
+	}
+	return nil
+}
+
+var queryExemplarsDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/query_exemplars"}`)
+
 // SeriesCountHandler processes /api/v1/series/count request.
 func SeriesCountHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) error {
 	defer seriesCountDuration.UpdateDuration(startTime)
@@ -703,6 +851,101 @@ func SeriesHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseW
 
 var seriesDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/series"}`)
 
+var queryExplainDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/query/explain"}`)
+
+// QueryExplainHandler processes /api/v1/query/explain request.
+//
+// It returns the query plan for the given query with the estimated number of series and samples per each series selector
+// without executing the query. The time range is set via start, end and step args in the same way as for /api/v1/query_range.
+// If start arg is missing, then the plan for instant query at the given time arg is returned.
+func QueryExplainHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer queryExplainDuration.UpdateDuration(startTime)
+
+	ct := startTime.UnixNano() / 1e6
+	query := r.FormValue("query")
+	if len(query) == 0 {
+		return fmt.Errorf("missing `query` arg")
+	}
+	if len(query) > maxQueryLen.IntN() {
+		return fmt.Errorf("too long query; got %d bytes; mustn't exceed `-search.maxQueryLen=%d` bytes", len(query), maxQueryLen.N)
+	}
+	lookbackDelta, err := getMaxLookback(r)
+	if err != nil {
+		return err
+	}
+	var start, end, step int64
+	if r.FormValue("start") != "" {
+		start, err = httputil.GetTime(r, "start", ct-defaultStep)
+		if err != nil {
+			return err
+		}
+		end, err = httputil.GetTime(r, "end", ct)
+		if err != nil {
+			return err
+		}
+		step, err = httputil.GetDuration(r, "step", defaultStep)
+		if err != nil {
+			return err
+		}
+		if start > end {
+			end = start + defaultStep
+		}
+		if err := promql.ValidateMaxPointsPerSeries(start, end, step, *maxPointsPerTimeseries); err != nil {
+			return fmt.Errorf("%w; (see -search.maxPointsPerTimeseries command-line flag)", err)
+		}
+	} else {
+		start, err = httputil.GetTime(r, "time", ct)
+		if err != nil {
+			return err
+		}
+		end = start
+		step, err = httputil.GetDuration(r, "step", lookbackDelta)
+		if err != nil {
+			return err
+		}
+	}
+	if step <= 0 {
+		step = defaultStep
+	}
+	mayCache := !httputil.GetBool(r, "nocache")
+	if mayCache {
+		start, end = promql.AdjustStartEnd(start, end, step)
+	}
+	etfs, err := searchutil.GetExtraTagFilters(r)
+	if err != nil {
+		return err
+	}
+
+	tenant := quota.GetTenant(r)
+	ec := &promql.EvalConfig{
+		Start:               start,
+		End:                 end,
+		Step:                step,
+		MaxPointsPerSeries:  *maxPointsPerTimeseries,
+		MaxSeries:           tenant.MaxSeries(GetMaxUniqueTimeSeries()),
+		QuotedRemoteAddr:    httpserver.GetQuotedRemoteAddr(r),
+		Deadline:            searchutil.GetDeadlineForQuery(r, startTime),
+		MayCache:            mayCache,
+		LookbackDelta:       lookbackDelta,
+		RoundDigits:         getRoundDigits(r),
+		EnforcedTagFilterss: etfs,
+		GetRequestURI: func() string {
+			return httpserver.GetRequestURI(r)
+		},
+		Tenant: tenant,
+	}
+	n, err := promql.Explain(qt, ec, query)
+	if err != nil {
+		return fmt.Errorf("cannot explain query=%q on the time range (start=%d, end=%d, step=%d): %w", query, start, end, step, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteQueryExplainResponse(bw, query, n, qt)
+	return bw.Flush()
+}
+
 // QueryHandler processes /api/v1/query request.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#instant-queries
@@ -793,11 +1036,15 @@ func QueryHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWr
 		return nil
 	}
 
+	compat, err := getPromQLCompat(r)
+	if err != nil {
+		return err
+	}
 	queryOffset, err := getLatencyOffsetMilliseconds(r)
 	if err != nil {
 		return err
 	}
-	if !httputil.GetBool(r, "nocache") && ct-start < queryOffset && start-ct < queryOffset {
+	if !compat && !httputil.GetBool(r, "nocache") && ct-start < queryOffset && start-ct < queryOffset {
 		// Adjust start time only if `nocache` arg isn't set.
 		// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/241
 		startPrev := start
@@ -806,12 +1053,13 @@ func QueryHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWr
 	} else {
 		queryOffset = 0
 	}
+	tenant := quota.GetTenant(r)
 	ec := &promql.EvalConfig{
 		Start:               start,
 		End:                 start,
 		Step:                step,
 		MaxPointsPerSeries:  *maxPointsPerTimeseries,
-		MaxSeries:           GetMaxUniqueTimeSeries(),
+		MaxSeries:           tenant.MaxSeries(GetMaxUniqueTimeSeries()),
 		QuotedRemoteAddr:    httpserver.GetQuotedRemoteAddr(r),
 		Deadline:            deadline,
 		MayCache:            mayCache,
@@ -821,11 +1069,15 @@ func QueryHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWr
 		GetRequestURI: func() string {
 			return httpserver.GetRequestURI(r)
 		},
+		Tenant:       tenant,
+		PromQLCompat: compat,
 	}
 	qs := promql.NewQueryStats(query, nil, ec)
 	ec.QueryStats = qs
 
-	result, err := promql.Exec(qt, ec, query, true)
+	execQuery := rewriteQueryWithMaterializedViews(qt, r, query, ec)
+	result, err := promql.Exec(qt, ec, execQuery, true)
+	logQuery(r, startTime, query, ec, err)
 	if err != nil {
 		return fmt.Errorf("error when executing query=%q for (time=%d, step=%d): %w", query, start, step, err)
 	}
@@ -859,6 +1111,47 @@ func QueryHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWr
 
 var queryDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/query"}`)
 
+// logQuery writes the record for the query executed with the given ec to the query log if the query is slow or failed.
+func logQuery(r *http.Request, startTime time.Time, query string, ec *promql.EvalConfig, err error) {
+	if !querylog.Enabled() {
+		return
+	}
+	d := time.Since(startTime)
+	slow := querylog.IsSlow(d)
+	if !slow && err == nil {
+		return
+	}
+	qs := ec.QueryStats
+	rec := &querylog.Record{
+		Time:                   time.Now(),
+		Path:                   r.URL.Path,
+		Query:                  query,
+		Start:                  ec.Start,
+		End:                    ec.End,
+		Step:                   ec.Step,
+		Duration:               d.Seconds(),
+		Slow:                   slow,
+		SeriesFetched:          qs.SeriesFetched.Load(),
+		SamplesScanned:         qs.SamplesScanned.Load(),
+		MemoryBytes:            qs.MemoryBytes.Load(),
+		RollupCacheFullHits:    qs.RollupCacheFullHits.Load(),
+		RollupCachePartialHits: qs.RollupCachePartialHits.Load(),
+		RollupCacheMisses:      qs.RollupCacheMisses.Load(),
+		RemoteAddr:             r.RemoteAddr,
+		Tenant:                 ec.Tenant.Name(),
+	}
+	if addr := r.Header.Get("X-Forwarded-For"); addr != "" {
+		rec.RemoteAddr += ", X-Forwarded-For: " + addr
+	}
+	if username, _, ok := r.BasicAuth(); ok {
+		rec.AuthUser = username
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	querylog.WriteRecord(rec)
+}
+
 // QueryRangeHandler processes /api/v1/query_range request.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#range-queries
@@ -892,6 +1185,42 @@ func QueryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 	return nil
 }
 
+// QueryRewriter rewrites the query executed on the time range [start ... end] with the given step in milliseconds.
+//
+// forceRewrite is set if the query contains `matview=1` query arg.
+// The original query is returned together with false if the query isn't rewritten.
+type QueryRewriter func(query string, start, end, step int64, forceRewrite bool) (string, bool)
+
+var materializedViewsRewriter QueryRewriter
+
+// SetMaterializedViewsRewriter sets the rewriter for queries, which may use materialized views.
+//
+// It must be called before serving the queries.
+//
+// See https://docs.victoriametrics.com/victoriametrics/#materialized-views
+func SetMaterializedViewsRewriter(qr QueryRewriter) {
+	materializedViewsRewriter = qr
+}
+
+// rewriteQueryWithMaterializedViews returns the query, which uses materialized views for the matching sub-expressions.
+//
+// The query isn't rewritten if it contains extra filters, since materialized views are calculated over all the matching series,
+// if the query is executed in Prometheus compatibility mode or if `nomatview=1` query arg is set.
+func rewriteQueryWithMaterializedViews(qt *querytracer.Tracer, r *http.Request, query string, ec *promql.EvalConfig) string {
+	if materializedViewsRewriter == nil {
+		return query
+	}
+	if len(ec.EnforcedTagFilterss) > 0 || ec.PromQLCompat || httputil.GetBool(r, "nomatview") {
+		return query
+	}
+	q, ok := materializedViewsRewriter(query, ec.Start, ec.End, ec.Step, httputil.GetBool(r, "matview"))
+	if !ok {
+		return query
+	}
+	qt.Printf("rewrite query with materialized views: %s", q)
+	return q
+}
+
 func queryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, query string,
 	start, end, step int64, r *http.Request, ct int64, etfs [][]storage.TagFilter) error {
 	deadline := searchutil.GetDeadlineForQuery(r, startTime)
@@ -911,16 +1240,21 @@ func queryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 	if err := promql.ValidateMaxPointsPerSeries(start, end, step, *maxPointsPerTimeseries); err != nil {
 		return fmt.Errorf("%w; (see -search.maxPointsPerTimeseries command-line flag)", err)
 	}
-	if mayCache {
+	compat, err := getPromQLCompat(r)
+	if err != nil {
+		return err
+	}
+	if mayCache && !compat {
 		start, end = promql.AdjustStartEnd(start, end, step)
 	}
 
+	tenant := quota.GetTenant(r)
 	ec := &promql.EvalConfig{
 		Start:               start,
 		End:                 end,
 		Step:                step,
 		MaxPointsPerSeries:  *maxPointsPerTimeseries,
-		MaxSeries:           GetMaxUniqueTimeSeries(),
+		MaxSeries:           tenant.MaxSeries(GetMaxUniqueTimeSeries()),
 		QuotedRemoteAddr:    httpserver.GetQuotedRemoteAddr(r),
 		Deadline:            deadline,
 		MayCache:            mayCache,
@@ -930,11 +1264,15 @@ func queryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 		GetRequestURI: func() string {
 			return httpserver.GetRequestURI(r)
 		},
+		Tenant:       tenant,
+		PromQLCompat: compat,
 	}
 	qs := promql.NewQueryStats(query, nil, ec)
 	ec.QueryStats = qs
 
-	result, err := promql.Exec(qt, ec, query, false)
+	execQuery := rewriteQueryWithMaterializedViews(qt, r, query, ec)
+	result, err := promql.Exec(qt, ec, execQuery, false)
+	logQuery(r, startTime, query, ec, err)
 	if err != nil {
 		return err
 	}
@@ -1073,6 +1411,22 @@ func getTagFilterssFromMatches(matches []string) ([][]storage.TagFilter, error)
 	return tfss, nil
 }
 
+// getPromQLCompat returns whether the query must be executed in Prometheus compatibility mode.
+//
+// The mode is enabled via -search.promqlCompat command-line flag and can be overridden via `compat` query arg.
+func getPromQLCompat(r *http.Request) (bool, error) {
+	switch s := r.FormValue("compat"); s {
+	case "":
+		return *promqlCompat, nil
+	case "prometheus":
+		return true, nil
+	case "metricsql":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported compat=%q query arg; supported values: prometheus, metricsql", s)
+	}
+}
+
 func getRoundDigits(r *http.Request) int {
 	s := r.FormValue("round_digits")
 	if len(s) == 0 {
@@ -1130,6 +1484,7 @@ type commonParams struct {
 	end              int64
 	currentTimestamp int64
 	filterss         [][]storage.TagFilter
+	tenant           *quota.Tenant
 }
 
 func (cp *commonParams) IsDefaultTimeRange() bool {
@@ -1227,6 +1582,7 @@ func getCommonParamsInternal(r *http.Request, startTime time.Time, requireNonEmp
 		end:              end,
 		currentTimestamp: ct,
 		filterss:         filterss,
+		tenant:           quota.GetTenant(r),
 	}
 	return cp, nil
 }
@@ -0,0 +1,134 @@
+// Code generated by qtc from "query_exemplars_response.qtpl". DO NOT EDIT.
+// See https://github.com/valyala/quicktemplate for details.
+
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:3
+package prometheus
+
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:3
+import (
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+)
+
+// QueryExemplarsResponse generates response for /api/v1/query_exemplars .See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-exemplars
+
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:10
+import (
+	qtio422016 "io"
+
+	qt422016 "github.com/valyala/quicktemplate"
+)
+
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:10
+var (
+	_ = qtio422016.Copy
+	_ = qt422016.AcquireByteBuffer
+)
+
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:10
+func StreamQueryExemplarsResponse(qw422016 *qt422016.Writer, ses []netstorage.SeriesExemplars, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:10
+	qw422016.N().S(`{"status":"success","data":[`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:14
+	for i := range ses {
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:15
+		se := &ses[i]
+
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:15
+		qw422016.N().S(`{"seriesLabels":`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:17
+		streammetricNameObject(qw422016, &se.MetricName)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:17
+		qw422016.N().S(`,"exemplars":[`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:19
+		for j := range se.Exemplars {
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:20
+			e := &se.Exemplars[j]
+
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:20
+			qw422016.N().S(`{"labels":{`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:23
+			for k := range e.Labels {
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:24
+				label := &e.Labels[k]
+
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:25
+				qw422016.N().Q(label.Name)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:25
+				qw422016.N().S(`:`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:25
+				qw422016.N().Q(label.Value)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:26
+				if k+1 < len(e.Labels) {
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:26
+					qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:26
+				}
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:27
+			}
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:27
+			qw422016.N().S(`},"value":"`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:29
+			qw422016.N().F(e.Value)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:29
+			qw422016.N().S(`","timestamp":`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:30
+			qw422016.N().F(float64(e.Timestamp) / 1e3)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:30
+			qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:32
+			if j+1 < len(se.Exemplars) {
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:32
+				qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:32
+			}
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:33
+		}
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:33
+		qw422016.N().S(`]}`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:36
+		if i+1 < len(ses) {
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:36
+			qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:36
+		}
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:37
+	}
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:37
+	qw422016.N().S(`]`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:40
+	qt.Printf("generate response for %d series", len(ses))
+	qt.Done()
+
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:43
+	streamdumpQueryTrace(qw422016, qt)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:43
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+}
+
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+func WriteQueryExemplarsResponse(qq422016 qtio422016.Writer, ses []netstorage.SeriesExemplars, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+	StreamQueryExemplarsResponse(qw422016, ses, qt)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+}
+
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+func QueryExemplarsResponse(ses []netstorage.SeriesExemplars, qt *querytracer.Tracer) string {
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+	WriteQueryExemplarsResponse(qb422016, ses, qt)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+	return qs422016
+//line app/vmselect/prometheus/query_exemplars_response.qtpl:45
+}
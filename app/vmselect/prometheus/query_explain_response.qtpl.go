@@ -0,0 +1,234 @@
+// Code generated by qtc from "query_explain_response.qtpl". DO NOT EDIT.
+// See https://github.com/valyala/quicktemplate for details.
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:1
+package prometheus
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:1
+import (
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+)
+
+// QueryExplainResponse generates response for /api/v1/query/explain.
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:8
+import (
+	qtio422016 "io"
+
+	qt422016 "github.com/valyala/quicktemplate"
+)
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:8
+var (
+	_ = qtio422016.Copy
+	_ = qt422016.AcquireByteBuffer
+)
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:8
+func StreamQueryExplainResponse(qw422016 *qt422016.Writer, query string, n *promql.ExplainNode, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/query_explain_response.qtpl:8
+	qw422016.N().S(`{`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:10
+	series, samples := n.Totals()
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:10
+	qw422016.N().S(`"status":"success","data":{"query":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:13
+	qw422016.N().Q(query)
+//line app/vmselect/prometheus/query_explain_response.qtpl:13
+	qw422016.N().S(`,"estimatedSeries":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:14
+	qw422016.N().D(series)
+//line app/vmselect/prometheus/query_explain_response.qtpl:14
+	qw422016.N().S(`,"estimatedSamples":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:15
+	qw422016.N().D(samples)
+//line app/vmselect/prometheus/query_explain_response.qtpl:15
+	qw422016.N().S(`,"plan":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:16
+	streamexplainNode(qw422016, n)
+//line app/vmselect/prometheus/query_explain_response.qtpl:16
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:18
+	qt.Done()
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:19
+	streamdumpQueryTrace(qw422016, qt)
+//line app/vmselect/prometheus/query_explain_response.qtpl:19
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+}
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+func WriteQueryExplainResponse(qq422016 qtio422016.Writer, query string, n *promql.ExplainNode, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+	StreamQueryExplainResponse(qw422016, query, n, qt)
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+}
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+func QueryExplainResponse(query string, n *promql.ExplainNode, qt *querytracer.Tracer) string {
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+	WriteQueryExplainResponse(qb422016, query, n, qt)
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+	return qs422016
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+}
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:23
+func streamexplainNode(qw422016 *qt422016.Writer, n *promql.ExplainNode) {
+//line app/vmselect/prometheus/query_explain_response.qtpl:23
+	qw422016.N().S(`{"type":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:25
+	qw422016.N().Q(n.Type)
+//line app/vmselect/prometheus/query_explain_response.qtpl:25
+	qw422016.N().S(`,"expr":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+	qw422016.N().Q(n.Expr)
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+	qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:27
+	if n.Func != "" {
+//line app/vmselect/prometheus/query_explain_response.qtpl:27
+		qw422016.N().S(`"func":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:28
+		qw422016.N().Q(n.Func)
+//line app/vmselect/prometheus/query_explain_response.qtpl:28
+		qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:29
+	}
+//line app/vmselect/prometheus/query_explain_response.qtpl:29
+	qw422016.N().S(`"start":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:30
+	qw422016.N().F(float64(n.Start) / 1e3)
+//line app/vmselect/prometheus/query_explain_response.qtpl:30
+	qw422016.N().S(`,"end":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:31
+	qw422016.N().F(float64(n.End) / 1e3)
+//line app/vmselect/prometheus/query_explain_response.qtpl:31
+	qw422016.N().S(`,"step":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:32
+	qw422016.N().F(float64(n.Step) / 1e3)
+//line app/vmselect/prometheus/query_explain_response.qtpl:33
+	if len(n.Notes) > 0 {
+//line app/vmselect/prometheus/query_explain_response.qtpl:33
+		qw422016.N().S(`,"notes":[`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:35
+		for i, note := range n.Notes {
+//line app/vmselect/prometheus/query_explain_response.qtpl:36
+			qw422016.N().Q(note)
+//line app/vmselect/prometheus/query_explain_response.qtpl:37
+			if i+1 < len(n.Notes) {
+//line app/vmselect/prometheus/query_explain_response.qtpl:37
+				qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:37
+			}
+//line app/vmselect/prometheus/query_explain_response.qtpl:38
+		}
+//line app/vmselect/prometheus/query_explain_response.qtpl:38
+		qw422016.N().S(`]`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:40
+	}
+//line app/vmselect/prometheus/query_explain_response.qtpl:41
+	if se := n.Selector; se != nil {
+//line app/vmselect/prometheus/query_explain_response.qtpl:41
+		qw422016.N().S(`,"selector":{"searchStart":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:43
+		qw422016.N().F(float64(se.MinTimestamp) / 1e3)
+//line app/vmselect/prometheus/query_explain_response.qtpl:43
+		qw422016.N().S(`,"searchEnd":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:44
+		qw422016.N().F(float64(se.MaxTimestamp) / 1e3)
+//line app/vmselect/prometheus/query_explain_response.qtpl:44
+		qw422016.N().S(`,"window":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:45
+		qw422016.N().F(float64(se.Window) / 1e3)
+//line app/vmselect/prometheus/query_explain_response.qtpl:45
+		qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:46
+		if se.Err != "" {
+//line app/vmselect/prometheus/query_explain_response.qtpl:46
+			qw422016.N().S(`"error":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:47
+			qw422016.N().Q(se.Err)
+//line app/vmselect/prometheus/query_explain_response.qtpl:48
+		} else {
+//line app/vmselect/prometheus/query_explain_response.qtpl:48
+			qw422016.N().S(`"series":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:49
+			qw422016.N().D(se.Series)
+//line app/vmselect/prometheus/query_explain_response.qtpl:49
+			qw422016.N().S(`,"blocks":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:50
+			qw422016.N().D(se.Blocks)
+//line app/vmselect/prometheus/query_explain_response.qtpl:50
+			qw422016.N().S(`,"samples":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:51
+			qw422016.N().D(se.Samples)
+//line app/vmselect/prometheus/query_explain_response.qtpl:52
+		}
+//line app/vmselect/prometheus/query_explain_response.qtpl:52
+		qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:54
+	}
+//line app/vmselect/prometheus/query_explain_response.qtpl:55
+	if len(n.Children) > 0 {
+//line app/vmselect/prometheus/query_explain_response.qtpl:55
+		qw422016.N().S(`,"children":[`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:57
+		for i, child := range n.Children {
+//line app/vmselect/prometheus/query_explain_response.qtpl:58
+			streamexplainNode(qw422016, child)
+//line app/vmselect/prometheus/query_explain_response.qtpl:59
+			if i+1 < len(n.Children) {
+//line app/vmselect/prometheus/query_explain_response.qtpl:59
+				qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:59
+			}
+//line app/vmselect/prometheus/query_explain_response.qtpl:60
+		}
+//line app/vmselect/prometheus/query_explain_response.qtpl:60
+		qw422016.N().S(`]`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:62
+	}
+//line app/vmselect/prometheus/query_explain_response.qtpl:62
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+}
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+func writeexplainNode(qq422016 qtio422016.Writer, n *promql.ExplainNode) {
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+	streamexplainNode(qw422016, n)
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+}
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+func explainNode(n *promql.ExplainNode) string {
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+	writeexplainNode(qb422016, n)
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+	return qs422016
+//line app/vmselect/prometheus/query_explain_response.qtpl:64
+}
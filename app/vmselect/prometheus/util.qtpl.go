@@ -220,41 +220,38 @@ func valuesWithTimestamps(values []float64, timestamps []int64) string {
 //line app/vmselect/prometheus/util.qtpl:49
 func streamdumpQueryTrace(qw422016 *qt422016.Writer, qt *querytracer.Tracer) {
 //line app/vmselect/prometheus/util.qtpl:50
-	traceJSON := qt.ToJSON()
-
-//line app/vmselect/prometheus/util.qtpl:51
-	if traceJSON != "" {
-//line app/vmselect/prometheus/util.qtpl:51
+	if qt.Exposed() {
+//line app/vmselect/prometheus/util.qtpl:50
 		qw422016.N().S(`,"trace":`)
 //line app/vmselect/prometheus/util.qtpl:51
-		qw422016.N().S(traceJSON)
-//line app/vmselect/prometheus/util.qtpl:51
-	}
+		qw422016.N().S(qt.ToJSON())
 //line app/vmselect/prometheus/util.qtpl:52
+	}
+//line app/vmselect/prometheus/util.qtpl:53
 }
 
-//line app/vmselect/prometheus/util.qtpl:52
+//line app/vmselect/prometheus/util.qtpl:53
 func writedumpQueryTrace(qq422016 qtio422016.Writer, qt *querytracer.Tracer) {
-//line app/vmselect/prometheus/util.qtpl:52
+//line app/vmselect/prometheus/util.qtpl:53
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/util.qtpl:52
+//line app/vmselect/prometheus/util.qtpl:53
 	streamdumpQueryTrace(qw422016, qt)
-//line app/vmselect/prometheus/util.qtpl:52
+//line app/vmselect/prometheus/util.qtpl:53
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/util.qtpl:52
+//line app/vmselect/prometheus/util.qtpl:53
 }
 
-//line app/vmselect/prometheus/util.qtpl:52
+//line app/vmselect/prometheus/util.qtpl:53
 func dumpQueryTrace(qt *querytracer.Tracer) string {
-//line app/vmselect/prometheus/util.qtpl:52
+//line app/vmselect/prometheus/util.qtpl:53
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/util.qtpl:52
+//line app/vmselect/prometheus/util.qtpl:53
 	writedumpQueryTrace(qb422016, qt)
-//line app/vmselect/prometheus/util.qtpl:52
+//line app/vmselect/prometheus/util.qtpl:53
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/util.qtpl:52
+//line app/vmselect/prometheus/util.qtpl:53
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/util.qtpl:52
+//line app/vmselect/prometheus/util.qtpl:53
 	return qs422016
-//line app/vmselect/prometheus/util.qtpl:52
+//line app/vmselect/prometheus/util.qtpl:53
 }
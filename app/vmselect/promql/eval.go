@@ -16,6 +16,7 @@ import (
 	"github.com/VictoriaMetrics/metricsql"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/quota"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
@@ -148,6 +149,16 @@ type EvalConfig struct {
 	// The caller must initialize QueryStats, otherwise it isn't collected.
 	QueryStats *QueryStats
 
+	// Tenant contains quotas for the tenant the query is executed for.
+	//
+	// It may be nil if the tenant has no quotas.
+	Tenant *quota.Tenant
+
+	// PromQLCompat enables Prometheus compatibility mode.
+	//
+	// In this mode the query must be valid PromQL, while rollup functions are calculated in the same way as Prometheus does.
+	PromQLCompat bool
+
 	timestamps     []int64
 	timestampsOnce sync.Once
 }
@@ -167,6 +178,8 @@ func copyEvalConfig(src *EvalConfig) *EvalConfig {
 	ec.EnforcedTagFilterss = src.EnforcedTagFilterss
 	ec.GetRequestURI = src.GetRequestURI
 	ec.QueryStats = src.QueryStats
+	ec.Tenant = src.Tenant
+	ec.PromQLCompat = src.PromQLCompat
 
 	// do not copy src.timestamps - they must be generated again.
 	return &ec
@@ -181,6 +194,18 @@ func (ec *EvalConfig) validate() {
 	}
 }
 
+// getRollupFunc returns the constructor for the rollup function with the given funcName.
+//
+// It returns Prometheus-compatible implementations if ec.PromQLCompat is set.
+func (ec *EvalConfig) getRollupFunc(funcName string) newRollupFunc {
+	if ec.PromQLCompat {
+		if nrf := rollupFuncsPromQLCompat[strings.ToLower(funcName)]; nrf != nil {
+			return nrf
+		}
+	}
+	return getRollupFunc(funcName)
+}
+
 func (ec *EvalConfig) mayCache() bool {
 	if *disableCache {
 		return false
@@ -188,6 +213,10 @@ func (ec *EvalConfig) mayCache() bool {
 	if !ec.MayCache {
 		return false
 	}
+	if ec.PromQLCompat {
+		// The rollup result cache doesn't distinguish results calculated in Prometheus compatibility mode.
+		return false
+	}
 	if ec.Start == ec.End {
 		// There is no need in aligning start and end to step for instant query
 		// in order to cache its results.
@@ -281,7 +310,7 @@ func evalExprInternal(qt *querytracer.Tracer, ec *EvalConfig, e metricsql.Expr)
 		return rv, nil
 	}
 	if fe, ok := e.(*metricsql.FuncExpr); ok {
-		nrf := getRollupFunc(fe.Name)
+		nrf := ec.getRollupFunc(fe.Name)
 		if nrf == nil {
 			qtChild := qt.NewChild("transform %s()", fe.Name)
 			rv, err := evalTransformFunc(qtChild, ec, fe)
@@ -360,19 +389,25 @@ func evalTransformFunc(qt *querytracer.Tracer, ec *EvalConfig, fe *metricsql.Fun
 			Err: fmt.Errorf(`cannot evaluate %q: %w`, fe.AppendString(nil), err),
 		}
 	}
+	if ec.PromQLCompat && transformFuncsKeepMetricName[strings.ToLower(fe.Name)] {
+		// Prometheus drops metric names for all the math functions.
+		for _, ts := range rv {
+			ts.MetricName.ResetMetricGroup()
+		}
+	}
 	return rv, nil
 }
 
 func evalAggrFunc(qt *querytracer.Tracer, ec *EvalConfig, ae *metricsql.AggrFuncExpr) ([]*timeseries, error) {
 	if callbacks := getIncrementalAggrFuncCallbacks(ae.Name); callbacks != nil {
-		fe, nrf := tryGetArgRollupFuncWithMetricExpr(ae)
-		if fe != nil {
+		if fe := tryGetArgRollupFuncWithMetricExpr(ae); fe != nil {
 			// There is an optimized path for calculating metricsql.AggrFuncExpr over rollupFunc over metricsql.MetricExpr.
 			// The optimized path saves RAM for aggregates over big number of time series.
 			args, re, err := evalRollupFuncArgs(qt, ec, fe)
 			if err != nil {
 				return nil, err
 			}
+			nrf := ec.getRollupFunc(fe.Name)
 			rf, err := nrf(args)
 			if err != nil {
 				return nil, fmt.Errorf("cannot evaluate args for aggregate func %q: %w", ae.AppendString(nil), err)
@@ -614,9 +649,9 @@ func joinRegexpValues(a []string) string {
 	return string(b)
 }
 
-func tryGetArgRollupFuncWithMetricExpr(ae *metricsql.AggrFuncExpr) (*metricsql.FuncExpr, newRollupFunc) {
+func tryGetArgRollupFuncWithMetricExpr(ae *metricsql.AggrFuncExpr) *metricsql.FuncExpr {
 	if len(ae.Args) != 1 {
-		return nil, nil
+		return nil
 	}
 	e := ae.Args[0]
 	// Make sure e contains one of the following:
@@ -628,56 +663,53 @@ func tryGetArgRollupFuncWithMetricExpr(ae *metricsql.AggrFuncExpr) (*metricsql.F
 	if me, ok := e.(*metricsql.MetricExpr); ok {
 		// e = metricExpr
 		if me.IsEmpty() {
-			return nil, nil
+			return nil
 		}
 		fe := &metricsql.FuncExpr{
 			Name: "default_rollup",
 			Args: []metricsql.Expr{me},
 		}
-		nrf := getRollupFunc(fe.Name)
-		return fe, nrf
+		return fe
 	}
 	if re, ok := e.(*metricsql.RollupExpr); ok {
 		if me, ok := re.Expr.(*metricsql.MetricExpr); !ok || me.IsEmpty() || re.ForSubquery() {
-			return nil, nil
+			return nil
 		}
 		// e = metricExpr[d]
 		fe := &metricsql.FuncExpr{
 			Name: "default_rollup",
 			Args: []metricsql.Expr{re},
 		}
-		nrf := getRollupFunc(fe.Name)
-		return fe, nrf
+		return fe
 	}
 	fe, ok := e.(*metricsql.FuncExpr)
 	if !ok {
-		return nil, nil
+		return nil
 	}
-	nrf := getRollupFunc(fe.Name)
-	if nrf == nil {
-		return nil, nil
+	if getRollupFunc(fe.Name) == nil {
+		return nil
 	}
 	rollupArgIdx := metricsql.GetRollupArgIdx(fe)
 	if rollupArgIdx >= len(fe.Args) {
 		// Incorrect number of args for rollup func.
-		return nil, nil
+		return nil
 	}
 	arg := fe.Args[rollupArgIdx]
 	if me, ok := arg.(*metricsql.MetricExpr); ok {
 		if me.IsEmpty() {
-			return nil, nil
+			return nil
 		}
 		// e = rollupFunc(metricExpr)
-		return fe, nrf
+		return fe
 	}
 	if re, ok := arg.(*metricsql.RollupExpr); ok {
 		if me, ok := re.Expr.(*metricsql.MetricExpr); !ok || me.IsEmpty() || re.ForSubquery() {
-			return nil, nil
+			return nil
 		}
 		// e = rollupFunc(metricExpr[d])
-		return fe, nrf
+		return fe
 	}
-	return nil, nil
+	return nil
 }
 
 func evalExprsSequentially(qt *querytracer.Tracer, ec *EvalConfig, es []metricsql.Expr) ([][]*timeseries, error) {
@@ -937,7 +969,7 @@ func evalRollupFuncWithSubquery(qt *querytracer.Tracer, ec *EvalConfig, funcName
 		return nil, nil
 	}
 	sharedTimestamps := getTimestamps(ec.Start, ec.End, ec.Step, ec.MaxPointsPerSeries)
-	preFunc, rcs, err := getRollupConfigs(funcName, rf, expr, ec.Start, ec.End, ec.Step, ec.MaxPointsPerSeries, window, ec.LookbackDelta, sharedTimestamps)
+	preFunc, rcs, err := getRollupConfigs(funcName, rf, expr, ec.Start, ec.End, ec.Step, ec.MaxPointsPerSeries, window, ec.LookbackDelta, ec.PromQLCompat, sharedTimestamps)
 	if err != nil {
 		return nil, err
 	}
@@ -1622,14 +1654,17 @@ func evalRollupFuncWithMetricExpr(qt *querytracer.Tracer, ec *EvalConfig, funcNa
 	if start > ec.End {
 		qt.Printf("the result is fully cached")
 		rollupResultCacheFullHits.Inc()
+		ec.QueryStats.addRollupCacheFullHit()
 		return tssCached, nil
 	}
 	if start > ec.Start {
 		qt.Printf("partial cache hit")
 		rollupResultCachePartialHits.Inc()
+		ec.QueryStats.addRollupCachePartialHit()
 	} else {
 		qt.Printf("cache miss")
 		rollupResultCacheMiss.Inc()
+		ec.QueryStats.addRollupCacheMiss()
 	}
 
 	// Fetch missing results, which aren't cached yet.
@@ -1661,6 +1696,29 @@ func evalRollupFuncWithMetricExpr(qt *querytracer.Tracer, ec *EvalConfig, funcNa
 	return rvs, nil
 }
 
+// getSearchMinTimestamp returns the minimum timestamp for fetching raw samples needed for calculating funcName with the given window.
+func getSearchMinTimestamp(ec *EvalConfig, funcName string, window int64) int64 {
+	minTimestamp := ec.Start
+	if needSilenceIntervalForRollupFunc[funcName] {
+		minTimestamp -= maxSilenceInterval()
+	}
+	if ec.PromQLCompat {
+		// Prometheus selects samples on the lookback delta for instant vector selectors,
+		// so it must be covered by the fetched time range even if it exceeds the window and the step.
+		lookbackDelta := ec.LookbackDelta
+		if lookbackDelta <= 0 {
+			lookbackDelta = promqlDefaultLookbackDelta
+		}
+		window = max(window, lookbackDelta)
+	}
+	if window > ec.Step {
+		minTimestamp -= window
+	} else {
+		minTimestamp -= ec.Step
+	}
+	return minTimestamp
+}
+
 // evalRollupFuncNoCache calculates the given rf with the given lookbehind window.
 //
 // pointsPerSeries is used only for estimating the needed memory for query processing
@@ -1675,7 +1733,7 @@ func evalRollupFuncNoCache(qt *querytracer.Tracer, ec *EvalConfig, funcName stri
 	}
 	// Obtain rollup configs before fetching data from db, so type errors could be caught earlier.
 	sharedTimestamps := getTimestamps(ec.Start, ec.End, ec.Step, ec.MaxPointsPerSeries)
-	preFunc, rcs, err := getRollupConfigs(funcName, rf, expr, ec.Start, ec.End, ec.Step, ec.MaxPointsPerSeries, window, ec.LookbackDelta, sharedTimestamps)
+	preFunc, rcs, err := getRollupConfigs(funcName, rf, expr, ec.Start, ec.End, ec.Step, ec.MaxPointsPerSeries, window, ec.LookbackDelta, ec.PromQLCompat, sharedTimestamps)
 	if err != nil {
 		return nil, err
 	}
@@ -1683,17 +1741,9 @@ func evalRollupFuncNoCache(qt *querytracer.Tracer, ec *EvalConfig, funcName stri
 	// Fetch the result.
 	tfss := searchutil.ToTagFilterss(me.LabelFilterss)
 	tfss = searchutil.JoinTagFilterss(tfss, ec.EnforcedTagFilterss)
-	minTimestamp := ec.Start
-	if needSilenceIntervalForRollupFunc[funcName] {
-		minTimestamp -= maxSilenceInterval()
-	}
-	if window > ec.Step {
-		minTimestamp -= window
-	} else {
-		minTimestamp -= ec.Step
-	}
+	minTimestamp := getSearchMinTimestamp(ec, funcName, window)
 	sq := storage.NewSearchQuery(minTimestamp, ec.End, tfss, ec.MaxSeries)
-	rss, err := netstorage.ProcessSearchQuery(qt, sq, ec.Deadline)
+	rss, err := netstorage.ProcessSearchQuery(qt, ec.Tenant, sq, ec.Deadline)
 	if err != nil {
 		return nil, err
 	}
@@ -1704,6 +1754,7 @@ func evalRollupFuncNoCache(qt *querytracer.Tracer, ec *EvalConfig, funcName stri
 		return nil, nil
 	}
 	qs.addSeriesFetched(rssLen)
+	qs.addSamplesScanned(rss.SamplesScanned())
 
 	// Verify timeseries fit available memory during rollup calculations.
 	timeseriesLen := rssLen
@@ -1755,6 +1806,7 @@ func evalRollupFuncNoCache(qt *querytracer.Tracer, ec *EvalConfig, funcName stri
 		return nil, err
 	}
 	defer rml.Put(uint64(rollupMemorySize))
+	qs.addMemoryBytes(rollupMemorySize)
 	qt.Printf("the rollup evaluation needs an estimated %d bytes of RAM for %d series and %d points per series (summary %d points)",
 		rollupMemorySize, timeseriesLen, pointsPerSeries, rollupPoints)
 
@@ -1872,7 +1924,7 @@ func doRollupForTimeseries(funcName string, keepMetricNames bool, rc *rollupConf
 	if len(rc.TagValue) > 0 {
 		tsDst.MetricName.AddTag("rollup", rc.TagValue)
 	}
-	if !keepMetricNames && !rollupFuncsKeepMetricName[funcName] {
+	if !keepMetricNames && !rc.keepsMetricName(funcName) {
 		tsDst.MetricName.ResetMetricGroup()
 	}
 	var samplesScanned uint64
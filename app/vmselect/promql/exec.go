@@ -49,6 +49,14 @@ func Exec(qt *querytracer.Tracer, ec *EvalConfig, q string, isFirstPointOnly boo
 		return nil, err
 	}
 
+	if ec.PromQLCompat {
+		if err := checkPromQLCompat(e); err != nil {
+			// we don't add query=%q to err message as it will be added by the caller
+			return nil, fmt.Errorf("query isn't supported in Prometheus compatibility mode: %w; "+
+				"see https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#prometheus-compatibility-mode", err)
+		}
+	}
+
 	if *disableImplicitConversion || *logImplicitConversion {
 		isInvalid := metricsql.IsLikelyInvalid(e)
 		if isInvalid && *disableImplicitConversion {
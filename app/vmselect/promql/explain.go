@@ -0,0 +1,337 @@
+package promql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/VictoriaMetrics/metricsql"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// ExplainNode is a node of the query plan returned by Explain.
+type ExplainNode struct {
+	// Type is the node type: rollup, subquery, aggregate, transform, binaryOp, number, string or duration.
+	Type string
+
+	// Expr is the expression evaluated at the node.
+	Expr string
+
+	// Func is the function name for rollup, subquery, aggregate and transform nodes and the operator for binaryOp nodes.
+	Func string
+
+	// Start, End and Step define the time range the node is evaluated on.
+	Start int64
+	End   int64
+	Step  int64
+
+	// Notes contains human-readable descriptions of optimizations and conversions applied to the node.
+	Notes []string
+
+	// Selector contains estimates for the series selector at rollup nodes.
+	Selector *SelectorEstimate
+
+	// Children contains nodes for the args of the node.
+	Children []*ExplainNode
+}
+
+// SelectorEstimate contains estimates for a series selector obtained without reading data blocks.
+type SelectorEstimate struct {
+	// MinTimestamp and MaxTimestamp define the time range for the series search.
+	MinTimestamp int64
+	MaxTimestamp int64
+
+	// Window is the lookbehind window in milliseconds.
+	//
+	// Zero window means the window is determined automatically from the interval between samples.
+	Window int64
+
+	// Series, Blocks and Samples contain estimates returned from netstorage.EstimateSearchQuery.
+	Series  int
+	Blocks  int
+	Samples int
+
+	// Err contains the error, which would occur when selecting the series during the query execution.
+	Err string
+}
+
+// Totals returns the total estimated number of series and samples selected by all the selectors in the plan rooted at n.
+func (n *ExplainNode) Totals() (series, samples int) {
+	if n.Selector != nil {
+		series += n.Selector.Series
+		samples += n.Selector.Samples
+	}
+	for _, child := range n.Children {
+		childSeries, childSamples := child.Totals()
+		series += childSeries
+		samples += childSamples
+	}
+	return series, samples
+}
+
+// Explain returns the query plan for q evaluated with the given ec.
+//
+// The query isn't executed. Series selectors in the plan are annotated with estimates
+// obtained from indexdb and block headers, so Explain doesn't read data blocks.
+func Explain(qt *querytracer.Tracer, ec *EvalConfig, q string) (*ExplainNode, error) {
+	ec.validate()
+
+	e, err := parsePromQLWithCache(q)
+	if err != nil {
+		return nil, err
+	}
+	isInvalid := metricsql.IsLikelyInvalid(e)
+	if isInvalid && *disableImplicitConversion {
+		return nil, fmt.Errorf("query requires implicit conversion and is rejected according to -search.disableImplicitConversion command-line flag. " +
+			"See https://docs.victoriametrics.com/victoriametrics/metricsql/#implicit-query-conversions for details")
+	}
+	n, err := explainExpr(qt, ec, e)
+	if err != nil {
+		return nil, err
+	}
+	if eOrig, err := metricsql.Parse(q); err == nil {
+		if s := string(e.AppendString(nil)); s != string(eOrig.AppendString(nil)) {
+			n.Notes = append(n.Notes, fmt.Sprintf("the query is optimized to %s", s))
+		}
+	}
+	if isInvalid {
+		n.Notes = append(n.Notes, "the query requires implicit conversion; "+
+			"see https://docs.victoriametrics.com/victoriametrics/metricsql/#implicit-query-conversions")
+	}
+	return n, nil
+}
+
+func explainExpr(qt *querytracer.Tracer, ec *EvalConfig, e metricsql.Expr) (*ExplainNode, error) {
+	n := &ExplainNode{
+		Expr:  string(e.AppendString(nil)),
+		Start: ec.Start,
+		End:   ec.End,
+		Step:  ec.Step,
+	}
+	switch t := e.(type) {
+	case *metricsql.MetricExpr:
+		re := &metricsql.RollupExpr{
+			Expr: t,
+		}
+		return explainRollup(qt, ec, n, "default_rollup", re)
+	case *metricsql.RollupExpr:
+		return explainRollup(qt, ec, n, "default_rollup", t)
+	case *metricsql.FuncExpr:
+		if getRollupFunc(t.Name) == nil {
+			if getTransformFunc(t.Name) == nil {
+				return nil, &httpserver.UserReadableError{
+					Err: fmt.Errorf(`unknown func %q`, t.Name),
+				}
+			}
+			n.Type = "transform"
+			n.Func = t.Name
+			if err := explainArgs(qt, ec, n, t.Args); err != nil {
+				return nil, err
+			}
+			return n, nil
+		}
+		rollupArgIdx := metricsql.GetRollupArgIdx(t)
+		if len(t.Args) <= rollupArgIdx {
+			return nil, fmt.Errorf("expecting at least %d args to %q; got %d args; expr: %q", rollupArgIdx+1, t.Name, len(t.Args), t.AppendString(nil))
+		}
+		for i, arg := range t.Args {
+			if i == rollupArgIdx {
+				continue
+			}
+			child, err := explainExpr(qt, ec, arg)
+			if err != nil {
+				return nil, fmt.Errorf("cannot explain arg #%d for %q: %w", i+1, t.AppendString(nil), err)
+			}
+			n.Children = append(n.Children, child)
+		}
+		re := getRollupExprArg(t.Args[rollupArgIdx])
+		return explainRollup(qt, ec, n, t.Name, re)
+	case *metricsql.AggrFuncExpr:
+		if getAggrFunc(t.Name) == nil {
+			return nil, &httpserver.UserReadableError{
+				Err: fmt.Errorf(`unknown func %q`, t.Name),
+			}
+		}
+		n.Type = "aggregate"
+		n.Func = t.Name
+		if getIncrementalAggrFuncCallbacks(t.Name) != nil {
+			if fe := tryGetArgRollupFuncWithMetricExpr(t); fe != nil {
+				n.Notes = append(n.Notes, "the aggregate is calculated incrementally over rollup results, "+
+					"so the selected series aren't held in memory at once")
+				child, err := explainExpr(qt, ec, fe)
+				if err != nil {
+					return nil, err
+				}
+				n.Children = append(n.Children, child)
+				return n, nil
+			}
+		}
+		if err := explainArgs(qt, ec, n, t.Args); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case *metricsql.BinaryOpExpr:
+		if getBinaryOpFunc(t.Op) == nil {
+			return nil, fmt.Errorf(`unknown binary op %q`, t.Op)
+		}
+		n.Type = "binaryOp"
+		n.Func = t.Op
+		if !canPushdownCommonFilters(t) {
+			n.Notes = append(n.Notes, "the left and the right sides are evaluated in parallel")
+		} else {
+			first, second := "left", "right"
+			switch strings.ToLower(t.Op) {
+			case "and", "if":
+				first, second = second, first
+			}
+			n.Notes = append(n.Notes, fmt.Sprintf("the %s side is evaluated at first; the common label filters from its results are pushed down to the %s side, "+
+				"so the estimates for the %s side are upper bounds", first, second, second))
+		}
+		if err := explainArgs(qt, ec, n, []metricsql.Expr{t.Left, t.Right}); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case *metricsql.NumberExpr:
+		n.Type = "number"
+		return n, nil
+	case *metricsql.StringExpr:
+		n.Type = "string"
+		return n, nil
+	case *metricsql.DurationExpr:
+		n.Type = "duration"
+		return n, nil
+	default:
+		return nil, fmt.Errorf("unexpected expression %q", e.AppendString(nil))
+	}
+}
+
+func explainArgs(qt *querytracer.Tracer, ec *EvalConfig, n *ExplainNode, args []metricsql.Expr) error {
+	for _, arg := range args {
+		child, err := explainExpr(qt, ec, arg)
+		if err != nil {
+			return err
+		}
+		n.Children = append(n.Children, child)
+	}
+	return nil
+}
+
+// explainRollup fills n with the plan for funcName over re.
+//
+// It mirrors the logic of evalRollupFunc.
+func explainRollup(qt *querytracer.Tracer, ec *EvalConfig, n *ExplainNode, funcName string, re *metricsql.RollupExpr) (*ExplainNode, error) {
+	funcName = strings.ToLower(funcName)
+	n.Type = "rollup"
+	n.Func = funcName
+
+	ecNew := ec
+	if re.At != nil {
+		child, err := explainExpr(qt, ec, re.At)
+		if err != nil {
+			return nil, fmt.Errorf("cannot explain `@` modifier: %w", err)
+		}
+		n.Children = append(n.Children, child)
+		if ne, ok := re.At.(*metricsql.NumberExpr); ok {
+			atTimestamp := int64(ne.N * 1000)
+			ecNew = copyEvalConfig(ecNew)
+			ecNew.Start = atTimestamp
+			ecNew.End = atTimestamp
+		} else {
+			n.Notes = append(n.Notes, "`@` modifier is calculated during the query execution, so the time range below doesn't take it into account")
+		}
+	}
+	if re.Offset != nil {
+		offset := re.Offset.Duration(ec.Step)
+		ecNew = copyEvalConfig(ecNew)
+		ecNew.Start -= offset
+		ecNew.End -= offset
+	}
+	if funcName == "rollup_candlestick" {
+		step := ecNew.Step
+		ecNew = copyEvalConfig(ecNew)
+		ecNew.Start += step
+		ecNew.End += step
+	}
+	n.Start = ecNew.Start
+	n.End = ecNew.End
+	n.Step = ecNew.Step
+
+	if me, ok := re.Expr.(*metricsql.MetricExpr); ok {
+		window, err := re.Window.NonNegativeDuration(ecNew.Step)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse lookbehind window in square brackets at %s: %w", n.Expr, err)
+		}
+		if me.IsEmpty() {
+			n.Notes = append(n.Notes, "empty series selector always returns NaN")
+			return n, nil
+		}
+		if ecNew.Start != ecNew.End && ecNew.mayCache() {
+			n.Notes = append(n.Notes, "the results may be partially served from the rollup result cache")
+		}
+		if len(ecNew.EnforcedTagFilterss) > 0 {
+			n.Notes = append(n.Notes, "the estimates take into account extra filters passed via extra_label or extra_filters[] query args")
+		}
+		n.Selector = explainSelector(qt, ecNew, funcName, me, window)
+		return n, nil
+	}
+
+	n.Type = "subquery"
+	if !re.ForSubquery() {
+		n.Notes = append(n.Notes, fmt.Sprintf("implicit subquery: the arg is evaluated with the step of the outer query; "+
+			"use %s[window:step] for explicit control; see https://docs.victoriametrics.com/victoriametrics/metricsql/#subqueries", re.Expr.AppendString(nil)))
+	}
+	step, err := re.Step.NonNegativeDuration(ecNew.Step)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse step in square brackets at %s: %w", n.Expr, err)
+	}
+	if step == 0 {
+		step = ecNew.Step
+	}
+	window, err := re.Window.NonNegativeDuration(ecNew.Step)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse lookbehind window in square brackets at %s: %w", n.Expr, err)
+	}
+	ecSQ := copyEvalConfig(ecNew)
+	ecSQ.Start -= window + step + maxSilenceInterval()
+	ecSQ.End += step
+	ecSQ.Step = step
+	ecSQ.MaxPointsPerSeries = *maxPointsSubqueryPerTimeseries
+	if err := ValidateMaxPointsPerSeries(ecSQ.Start, ecSQ.End, ecSQ.Step, ecSQ.MaxPointsPerSeries); err != nil {
+		return nil, fmt.Errorf("%w; (see -search.maxPointsSubqueryPerTimeseries command-line flag)", err)
+	}
+	ecSQ.Start, ecSQ.End = alignStartEnd(ecSQ.Start, ecSQ.End, ecSQ.Step)
+	child, err := explainExpr(qt, ecSQ, re.Expr)
+	if err != nil {
+		return nil, err
+	}
+	n.Children = append(n.Children, child)
+	return n, nil
+}
+
+// explainSelector returns estimates for me selected by funcName with the given window.
+//
+// The time range for the series search is calculated in the same way as evalRollupFuncNoCache does.
+func explainSelector(qt *querytracer.Tracer, ec *EvalConfig, funcName string, me *metricsql.MetricExpr, window int64) *SelectorEstimate {
+	tfss := searchutil.ToTagFilterss(me.LabelFilterss)
+	tfss = searchutil.JoinTagFilterss(tfss, ec.EnforcedTagFilterss)
+	minTimestamp := getSearchMinTimestamp(ec, funcName, window)
+	sq := storage.NewSearchQuery(minTimestamp, ec.End, tfss, ec.MaxSeries)
+	se := &SelectorEstimate{
+		MinTimestamp: sq.MinTimestamp,
+		MaxTimestamp: sq.MaxTimestamp,
+		Window:       window,
+	}
+	est, err := netstorage.EstimateSearchQuery(qt, ec.Tenant, sq, ec.Deadline)
+	if err != nil {
+		se.Err = err.Error()
+		return se
+	}
+	se.Series = est.Series
+	se.Blocks = est.Blocks
+	se.Samples = est.Samples
+	return se
+}
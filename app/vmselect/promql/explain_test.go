@@ -0,0 +1,119 @@
+package promql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutil"
+)
+
+func TestExplainSuccess(t *testing.T) {
+	f := func(q, resultExpected string) {
+		t.Helper()
+		ec := &EvalConfig{
+			Start:              1000e3,
+			End:                2000e3,
+			Step:               200e3,
+			MaxPointsPerSeries: 1e4,
+			MaxSeries:          1000,
+			Deadline:           searchutil.NewDeadline(time.Now(), time.Minute, ""),
+		}
+		n, err := Explain(nil, ec, q)
+		if err != nil {
+			t.Fatalf("unexpected error when explaining %q: %s", q, err)
+		}
+		result := marshalExplainNodeForTest(n)
+		if result != resultExpected {
+			t.Fatalf("unexpected plan for %q;\ngot\n%s\nwant\n%s", q, result, resultExpected)
+		}
+	}
+
+	f(`123`, `number(123[1000000..2000000:200000])`)
+	f(`"foo"`, `string("foo"[1000000..2000000:200000])`)
+	f(`abs(time())`, `transform:abs(abs(time())[1000000..2000000:200000]; transform:time(time()[1000000..2000000:200000]))`)
+	f(`time() or 1`, `binaryOp:or(time() or 1[1000000..2000000:200000] {the left and the right sides are evaluated in parallel}; `+
+		`transform:time(time()[1000000..2000000:200000]); number(1[1000000..2000000:200000]))`)
+	f(`time() + 1`, `binaryOp:+(time() + 1[1000000..2000000:200000] {the left side is evaluated at first; the common label filters from its results `+
+		`are pushed down to the right side, so the estimates for the right side are upper bounds}; `+
+		`transform:time(time()[1000000..2000000:200000]); number(1[1000000..2000000:200000]))`)
+	f(`sum(time())`, `aggregate:sum(sum(time())[1000000..2000000:200000]; transform:time(time()[1000000..2000000:200000]))`)
+	f(`max_over_time(time()[10m:100s] offset 100s)`, `subquery:max_over_time(max_over_time(time()[10m:100s] offset 100s)[900000..1900000:200000]; `+
+		`transform:time(time()[-100000..2000000:100000]))`)
+	f(`rate(time())`, `subquery:rate(rate(time())[1000000..2000000:200000] {implicit subquery: the arg is evaluated with the step of the outer query; `+
+		`use time()[window:step] for explicit control; see https://docs.victoriametrics.com/victoriametrics/metricsql/#subqueries; `+
+		`the query requires implicit conversion; see https://docs.victoriametrics.com/victoriametrics/metricsql/#implicit-query-conversions}; `+
+		`transform:time(time()[400000..2200000:200000]))`)
+}
+
+func TestExplainFailure(t *testing.T) {
+	f := func(q string) {
+		t.Helper()
+		ec := &EvalConfig{
+			Start:              1000e3,
+			End:                2000e3,
+			Step:               200e3,
+			MaxPointsPerSeries: 1e4,
+			MaxSeries:          1000,
+			Deadline:           searchutil.NewDeadline(time.Now(), time.Minute, ""),
+		}
+		n, err := Explain(nil, ec, q)
+		if err == nil {
+			t.Fatalf("expecting non-nil error when explaining %q; got %s", q, marshalExplainNodeForTest(n))
+		}
+	}
+
+	f(``)
+	f(`foo(`)
+	f(`unknown_func(time())`)
+	f(`sum(unknown_func(time()))`)
+	f(`max_over_time(time()[1h:1ms])`)
+}
+
+func TestExplainNodeTotals(t *testing.T) {
+	n := &ExplainNode{
+		Children: []*ExplainNode{
+			{
+				Selector: &SelectorEstimate{
+					Series:  3,
+					Samples: 100,
+				},
+			},
+			{
+				Children: []*ExplainNode{
+					{
+						Selector: &SelectorEstimate{
+							Series:  2,
+							Samples: 20,
+						},
+					},
+					{},
+				},
+			},
+		},
+	}
+	series, samples := n.Totals()
+	if series != 5 {
+		t.Fatalf("unexpected series; got %d; want %d", series, 5)
+	}
+	if samples != 120 {
+		t.Fatalf("unexpected samples; got %d; want %d", samples, 120)
+	}
+}
+
+func marshalExplainNodeForTest(n *ExplainNode) string {
+	s := fmt.Sprintf("%s[%d..%d:%d]", n.Expr, n.Start, n.End, n.Step)
+	if len(n.Notes) > 0 {
+		s += " {" + strings.Join(n.Notes, "; ") + "}"
+	}
+	if n.Func != "" {
+		s = n.Type + ":" + n.Func + "(" + s
+	} else {
+		s = n.Type + "(" + s
+	}
+	for _, child := range n.Children {
+		s += "; " + marshalExplainNodeForTest(child)
+	}
+	return s + ")"
+}
@@ -0,0 +1,354 @@
+package promql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+// promqlDefaultLookbackDelta is the default value for -query.lookback-delta in Prometheus.
+//
+// It is used for selecting the last sample for instant vectors in Prometheus compatibility mode
+// if EvalConfig.LookbackDelta isn't set.
+const promqlDefaultLookbackDelta = 5 * 60 * 1000
+
+// rollupFuncsPromQLCompat contains rollup functions, which are calculated differently in Prometheus compatibility mode.
+//
+// MetricsQL takes into account the last sample before the lookbehind window for these functions and doesn't extrapolate the results,
+// while Prometheus uses only the samples on the lookbehind window.
+// See https://docs.victoriametrics.com/victoriametrics/metricsql/#rate
+var rollupFuncsPromQLCompat = map[string]newRollupFunc{
+	"changes":  newRollupFuncOneArg(rollupChangesPrometheus),
+	"delta":    newRollupFuncOneArg(rollupDeltaPromQL),
+	"idelta":   newRollupFuncOneArg(rollupIdeltaPromQL),
+	"increase": newRollupFuncOneArg(rollupIncreasePromQL),
+	"irate":    newRollupFuncOneArg(rollupIratePromQL),
+	"rate":     newRollupFuncOneArg(rollupRatePromQL),
+}
+
+// rollupFuncsKeepMetricNamePromQLCompat contains rollup functions, which keep metric names in Prometheus compatibility mode.
+var rollupFuncsKeepMetricNamePromQLCompat = map[string]bool{
+	"default_rollup": true,
+	"last_over_time": true,
+}
+
+// promqlFuncs contains PromQL functions supported in Prometheus compatibility mode.
+//
+// Function names are case-sensitive in PromQL, so they must be matched as is.
+// See https://prometheus.io/docs/prometheus/latest/querying/functions/
+var promqlFuncs = map[string]bool{
+	"abs":                true,
+	"absent":             true,
+	"absent_over_time":   true,
+	"acos":               true,
+	"acosh":              true,
+	"asin":               true,
+	"asinh":              true,
+	"atan":               true,
+	"atanh":              true,
+	"avg_over_time":      true,
+	"ceil":               true,
+	"changes":            true,
+	"clamp":              true,
+	"clamp_max":          true,
+	"clamp_min":          true,
+	"cos":                true,
+	"cosh":               true,
+	"count_over_time":    true,
+	"day_of_month":       true,
+	"day_of_week":        true,
+	"day_of_year":        true,
+	"days_in_month":      true,
+	"deg":                true,
+	"delta":              true,
+	"deriv":              true,
+	"exp":                true,
+	"floor":              true,
+	"histogram_quantile": true,
+	"holt_winters":       true,
+	"hour":               true,
+	"idelta":             true,
+	"increase":           true,
+	"irate":              true,
+	"label_join":         true,
+	"label_replace":      true,
+	"last_over_time":     true,
+	"ln":                 true,
+	"log10":              true,
+	"log2":               true,
+	"max_over_time":      true,
+	"min_over_time":      true,
+	"minute":             true,
+	"month":              true,
+	"pi":                 true,
+	"predict_linear":     true,
+	"present_over_time":  true,
+	"quantile_over_time": true,
+	"rad":                true,
+	"rate":               true,
+	"resets":             true,
+	"round":              true,
+	"scalar":             true,
+	"sgn":                true,
+	"sin":                true,
+	"sinh":               true,
+	"sort":               true,
+	"sort_by_label":      true,
+	"sort_by_label_desc": true,
+	"sort_desc":          true,
+	"sqrt":               true,
+	"stddev_over_time":   true,
+	"stdvar_over_time":   true,
+	"sum_over_time":      true,
+	"tan":                true,
+	"tanh":               true,
+	"time":               true,
+	"timestamp":          true,
+	"vector":             true,
+	"year":               true,
+}
+
+// promqlInstantVectorRollupFuncs contains PromQL functions, which accept instant vectors,
+// while they are implemented as rollup functions in MetricsQL.
+var promqlInstantVectorRollupFuncs = map[string]bool{
+	"timestamp": true,
+}
+
+// promqlAggrFuncs contains PromQL aggregate functions supported in Prometheus compatibility mode.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/operators/#aggregation-operators
+var promqlAggrFuncs = map[string]bool{
+	"avg":          true,
+	"bottomk":      true,
+	"count":        true,
+	"count_values": true,
+	"group":        true,
+	"max":          true,
+	"min":          true,
+	"quantile":     true,
+	"stddev":       true,
+	"stdvar":       true,
+	"sum":          true,
+	"topk":         true,
+}
+
+// promqlBinaryOps contains PromQL binary operators.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/operators/#binary-operators
+var promqlBinaryOps = map[string]bool{
+	"+":      true,
+	"-":      true,
+	"*":      true,
+	"/":      true,
+	"%":      true,
+	"^":      true,
+	"atan2":  true,
+	"==":     true,
+	"!=":     true,
+	">":      true,
+	"<":      true,
+	">=":     true,
+	"<=":     true,
+	"and":    true,
+	"or":     true,
+	"unless": true,
+}
+
+// checkPromQLCompat returns an error if e contains MetricsQL extensions, which aren't supported by PromQL.
+func checkPromQLCompat(e metricsql.Expr) error {
+	switch t := e.(type) {
+	case *metricsql.MetricExpr:
+		if len(t.LabelFilterss) > 1 {
+			return fmt.Errorf("`or` filters aren't supported in PromQL: %s", t.AppendString(nil))
+		}
+		return nil
+	case *metricsql.RollupExpr:
+		if t.Window != nil && !t.ForSubquery() {
+			return fmt.Errorf("range vector %s can be passed only to functions accepting range vectors in PromQL", t.AppendString(nil))
+		}
+		return checkPromQLCompatRollupExpr(t)
+	case *metricsql.FuncExpr:
+		if !promqlFuncs[t.Name] {
+			return fmt.Errorf("function %q isn't supported in PromQL", t.Name)
+		}
+		if t.KeepMetricNames {
+			return fmt.Errorf("keep_metric_names modifier isn't supported in PromQL: %s", t.AppendString(nil))
+		}
+		rollupArgIdx := metricsql.GetRollupArgIdx(t)
+		for i, arg := range t.Args {
+			if i != rollupArgIdx || promqlInstantVectorRollupFuncs[t.Name] {
+				if err := checkPromQLCompat(arg); err != nil {
+					return err
+				}
+				continue
+			}
+			re, ok := arg.(*metricsql.RollupExpr)
+			if !ok || re.Window == nil {
+				return fmt.Errorf("function %q expects a range vector in PromQL; got %s; "+
+					"see https://docs.victoriametrics.com/victoriametrics/metricsql/#implicit-query-conversions", t.Name, arg.AppendString(nil))
+			}
+			if err := checkPromQLCompatRollupExpr(re); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *metricsql.AggrFuncExpr:
+		if !promqlAggrFuncs[strings.ToLower(t.Name)] {
+			return fmt.Errorf("aggregate function %q isn't supported in PromQL", t.Name)
+		}
+		if t.Limit > 0 {
+			return fmt.Errorf("limit modifier isn't supported in PromQL: %s", t.AppendString(nil))
+		}
+		return checkPromQLCompatArgs(t.Args)
+	case *metricsql.BinaryOpExpr:
+		if !promqlBinaryOps[t.Op] {
+			return fmt.Errorf("binary operator %q isn't supported in PromQL", t.Op)
+		}
+		if t.KeepMetricNames {
+			return fmt.Errorf("keep_metric_names modifier isn't supported in PromQL: %s", t.AppendString(nil))
+		}
+		if t.JoinModifierPrefix != nil {
+			return fmt.Errorf("prefix modifier isn't supported in PromQL: %s", t.AppendString(nil))
+		}
+		return checkPromQLCompatArgs([]metricsql.Expr{t.Left, t.Right})
+	case *metricsql.NumberExpr, *metricsql.StringExpr:
+		return nil
+	default:
+		return fmt.Errorf("unexpected expression in PromQL: %s", e.AppendString(nil))
+	}
+}
+
+func checkPromQLCompatArgs(args []metricsql.Expr) error {
+	for _, arg := range args {
+		if err := checkPromQLCompat(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkPromQLCompatRollupExpr(re *metricsql.RollupExpr) error {
+	for _, de := range []*metricsql.DurationExpr{re.Window, re.Step, re.Offset} {
+		if de == nil {
+			continue
+		}
+		// Durations relative to step such as [5i] return different values for different steps.
+		if de.Duration(1) != de.Duration(2) {
+			return fmt.Errorf("step-based duration %s isn't supported in PromQL: %s", de.AppendString(nil), re.AppendString(nil))
+		}
+	}
+	if re.ForSubquery() {
+		return checkPromQLCompat(re.Expr)
+	}
+	if _, ok := re.Expr.(*metricsql.MetricExpr); !ok {
+		return fmt.Errorf("subquery step is missing in %s; PromQL requires %s[window:step] syntax", re.AppendString(nil), re.Expr.AppendString(nil))
+	}
+	return checkPromQLCompat(re.Expr)
+}
+
+func rollupRatePromQL(rfa *rollupFuncArg) float64 {
+	return extrapolatedRatePromQL(rfa, true, true)
+}
+
+func rollupIncreasePromQL(rfa *rollupFuncArg) float64 {
+	return extrapolatedRatePromQL(rfa, true, false)
+}
+
+func rollupDeltaPromQL(rfa *rollupFuncArg) float64 {
+	return extrapolatedRatePromQL(rfa, false, false)
+}
+
+// extrapolatedRatePromQL calculates rate, increase or delta over samples on the lookbehind window in the same way as Prometheus does.
+//
+// The result is extrapolated to the window boundaries if the first and the last samples are close enough to them.
+// See https://github.com/prometheus/prometheus/blob/main/promql/functions.go
+func extrapolatedRatePromQL(rfa *rollupFuncArg, isCounter, isRate bool) float64 {
+	// There is no need in handling NaNs here, since they must be cleaned up
+	// before calling rollup funcs.
+	values := rfa.values
+	timestamps := rfa.timestamps
+	if len(values) < 2 {
+		return nan
+	}
+	firstValue := values[0]
+	result := values[len(values)-1] - firstValue
+	if isCounter {
+		prevValue := firstValue
+		for _, v := range values[1:] {
+			if v < prevValue {
+				result += prevValue
+			}
+			prevValue = v
+		}
+	}
+
+	rangeEnd := rfa.currTimestamp
+	rangeStart := rangeEnd - rfa.window
+	firstTimestamp := timestamps[0]
+	lastTimestamp := timestamps[len(timestamps)-1]
+	durationToStart := float64(firstTimestamp-rangeStart) / 1e3
+	durationToEnd := float64(rangeEnd-lastTimestamp) / 1e3
+	sampledInterval := float64(lastTimestamp-firstTimestamp) / 1e3
+	if sampledInterval <= 0 {
+		return nan
+	}
+	averageDurationBetweenSamples := sampledInterval / float64(len(values)-1)
+
+	// Extrapolate to the window boundary only if the sample is close enough to it,
+	// e.g. up to 10% more than the average duration between samples.
+	// Otherwise extrapolate by half of the average duration between samples.
+	extrapolationThreshold := averageDurationBetweenSamples * 1.1
+	if durationToStart >= extrapolationThreshold {
+		durationToStart = averageDurationBetweenSamples / 2
+	}
+	if isCounter && result > 0 && firstValue >= 0 {
+		// Counters cannot be negative, so do not extrapolate beyond the zero point of the counter.
+		durationToZero := sampledInterval * (firstValue / result)
+		if durationToZero < durationToStart {
+			durationToStart = durationToZero
+		}
+	}
+	if durationToEnd >= extrapolationThreshold {
+		durationToEnd = averageDurationBetweenSamples / 2
+	}
+	factor := (sampledInterval + durationToStart + durationToEnd) / sampledInterval
+	if isRate {
+		factor /= float64(rfa.window) / 1e3
+	}
+	return result * factor
+}
+
+func rollupIratePromQL(rfa *rollupFuncArg) float64 {
+	return instantValuePromQL(rfa, true)
+}
+
+func rollupIdeltaPromQL(rfa *rollupFuncArg) float64 {
+	return instantValuePromQL(rfa, false)
+}
+
+// instantValuePromQL calculates irate or idelta over the last two samples on the lookbehind window in the same way as Prometheus does.
+func instantValuePromQL(rfa *rollupFuncArg, isRate bool) float64 {
+	// There is no need in handling NaNs here, since they must be cleaned up
+	// before calling rollup funcs.
+	values := rfa.values
+	timestamps := rfa.timestamps
+	if len(values) < 2 {
+		return nan
+	}
+	lastValue := values[len(values)-1]
+	prevValue := values[len(values)-2]
+	sampledInterval := timestamps[len(timestamps)-1] - timestamps[len(timestamps)-2]
+	if sampledInterval == 0 {
+		return nan
+	}
+	if !isRate {
+		return lastValue - prevValue
+	}
+	result := lastValue - prevValue
+	if lastValue < prevValue {
+		// Counter reset.
+		result = lastValue
+	}
+	return result / (float64(sampledInterval) / 1e3)
+}
@@ -0,0 +1,286 @@
+package promql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metricsql"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestCheckPromQLCompatSuccess(t *testing.T) {
+	f := func(q string) {
+		t.Helper()
+		e, err := metricsql.Parse(q)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", q, err)
+		}
+		if err := checkPromQLCompat(e); err != nil {
+			t.Fatalf("unexpected error for %q: %s", q, err)
+		}
+	}
+
+	f(`foo`)
+	f(`foo{bar="baz",x=~"y.+"} offset 5m`)
+	f(`foo @ 1234`)
+	f(`rate(foo[5m])`)
+	f(`rate(foo[5m] offset 1h)`)
+	f(`sum(rate(foo[5m])) by (job)`)
+	f(`SUM(increase(foo[1h])) without (instance)`)
+	f(`histogram_quantile(0.99, sum(rate(foo_bucket[5m])) by (le))`)
+	f(`quantile_over_time(0.5, foo[10m])`)
+	f(`max_over_time(rate(foo[5m])[1h:1m])`)
+	f(`timestamp(foo)`)
+	f(`topk(3, foo)`)
+	f(`count_values("value", foo)`)
+	f(`label_replace(foo, "dst", "$1", "src", "(.+)")`)
+	f(`foo / on(job) group_left(instance) bar`)
+	f(`foo > bool 10`)
+	f(`foo and bar or baz unless qux`)
+	f(`clamp_max(foo, 10) + abs(bar)`)
+	f(`vector(1)`)
+	f(`time() - 10`)
+}
+
+func TestCheckPromQLCompatFailure(t *testing.T) {
+	f := func(q string) {
+		t.Helper()
+		e, err := metricsql.Parse(q)
+		if err != nil {
+			t.Fatalf("unexpected error when parsing %q: %s", q, err)
+		}
+		if err := checkPromQLCompat(e); err == nil {
+			t.Fatalf("expecting non-nil error for %q", q)
+		}
+	}
+
+	// MetricsQL functions
+	f(`range_median(foo)`)
+	f(`rollup_candlestick(foo[5m])`)
+	f(`median(foo)`)
+	f(`outliersk(3, foo)`)
+
+	// Function names are case-sensitive in PromQL
+	f(`RATE(foo[5m])`)
+
+	// Missing lookbehind window
+	f(`rate(foo)`)
+	f(`sum_over_time(foo offset 5m)`)
+
+	// Step-based lookbehind window
+	f(`rate(foo[5i])`)
+
+	// Range vector outside of function
+	f(`foo[5m]`)
+
+	// Subquery without step
+	f(`max_over_time(rate(foo[5m])[1h])`)
+
+	// MetricsQL modifiers
+	f(`rate(foo[5m]) keep_metric_names`)
+	f(`sum(foo) by (job) limit 10`)
+	f(`foo * on(job) group_left() prefix "bar_" bar`)
+
+	// MetricsQL binary operators
+	f(`foo default 0`)
+	f(`foo if bar`)
+
+	// `or` filters
+	f(`{job="foo" or job="bar"}`)
+}
+
+func TestRollupPromQLCompat(t *testing.T) {
+	// Counter samples scraped every 15 seconds with a counter reset at 60s.
+	values := []float64{10, 20, 30, 5, 15, 25}
+	timestamps := []int64{15e3, 30e3, 45e3, 60e3, 75e3, 90e3}
+
+	f := func(funcName string, window, ts int64, vExpected float64) {
+		t.Helper()
+		nrf := rollupFuncsPromQLCompat[funcName]
+		if nrf == nil {
+			nrf = getRollupFunc(funcName)
+		}
+		rf, err := nrf([]any{nil})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		rc := rollupConfig{
+			Func:               rf,
+			Start:              ts,
+			End:                ts,
+			Step:               1e3,
+			Window:             window,
+			MaxPointsPerSeries: 1e4,
+			promqlCompat:       true,
+		}
+		rc.Timestamps = rc.getTimestamps()
+		result, _ := rc.Do(nil, append([]float64{}, values...), timestamps)
+		testRowsEqual(t, result, rc.Timestamps, []float64{vExpected}, []int64{ts})
+	}
+
+	// The expected values are calculated in the same way as Prometheus does.
+
+	// The window (40s..100s] contains 30, 5, 15, 25.
+	// The result is extrapolated from 45s to 40s and from 90s to 100s.
+	f("increase", 60e3, 100e3, 25*60.0/45)
+	f("rate", 60e3, 100e3, 25*60.0/45/60)
+	f("delta", 60e3, 100e3, -5*60.0/45)
+	f("irate", 60e3, 100e3, 10.0/15)
+	f("idelta", 60e3, 100e3, 10)
+	f("changes", 60e3, 100e3, 3)
+	f("resets", 60e3, 100e3, 1)
+
+	// The window (-20s..100s] contains all the samples.
+	// The first sample is too far from the window start, so the result is extrapolated by half of the average interval between samples.
+	f("increase", 120e3, 100e3, 45*92.5/75)
+	f("rate", 120e3, 100e3, 45*92.5/75/120)
+
+	// The window (70s..100s] contains 15, 25.
+	f("increase", 30e3, 100e3, 10*(15+5+10)/15.0)
+
+	// The window (80s..100s] contains a single sample.
+	f("increase", 20e3, 100e3, nan)
+	f("rate", 20e3, 100e3, nan)
+	f("irate", 20e3, 100e3, nan)
+
+	// The last sample is selected on the default lookback delta if the window is missing.
+	f("default_rollup", 0, 100e3, 25)
+	f("default_rollup", 0, 389e3, 25)
+	f("default_rollup", 0, 390e3, nan)
+}
+
+func TestExecPromQLCompat(t *testing.T) {
+	netstorage.InitTmpBlocksDir(t.TempDir())
+	s := storage.MustOpenStorage(t.TempDir(), storage.OpenOptions{})
+	storageOrig := vmstorage.Storage
+	vmstorage.Storage = s
+	defer func() {
+		vmstorage.Storage = storageOrig
+		s.MustClose()
+	}()
+
+	// Align the base time to a minute, so subquery steps are aligned in the same way as in Prometheus.
+	base := (time.Now().UnixMilli()/60e3 - 60) * 60e3
+
+	var mrs []storage.MetricRow
+	addSamples := func(metricName string, values []float64, timestamps []int64) {
+		labels := []prompbmarshal.Label{
+			{
+				Name:  "__name__",
+				Value: metricName,
+			},
+			{
+				Name:  "job",
+				Value: "a",
+			},
+		}
+		metricNameRaw := storage.MarshalMetricNameRaw(nil, labels)
+		for i, v := range values {
+			mrs = append(mrs, storage.MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     base + timestamps[i],
+				Value:         v,
+			})
+		}
+	}
+	// Counter samples scraped every 15 seconds with a counter reset at 60s.
+	addSamples("http_requests_total", []float64{10, 20, 30, 5, 15, 25}, []int64{15e3, 30e3, 45e3, 60e3, 75e3, 90e3})
+	// Gauge with a single sample at 15s.
+	addSamples("temperature", []float64{7.4}, []int64{15e3})
+	s.AddRows(mrs, 64)
+	s.DebugFlush()
+
+	mnWithName := storage.MetricName{
+		MetricGroup: []byte("temperature"),
+		Tags: []storage.Tag{
+			{
+				Key:   []byte("job"),
+				Value: []byte("a"),
+			},
+		},
+	}
+	mnWithoutName := storage.MetricName{
+		Tags: []storage.Tag{
+			{
+				Key:   []byte("job"),
+				Value: []byte("a"),
+			},
+		},
+	}
+
+	// fWithLookbackDelta executes the instant query q at base+offset with the given lookbackDelta in Prometheus compatibility mode.
+	fWithLookbackDelta := func(q string, lookbackDelta, offset int64, mnExpected *storage.MetricName, vExpected float64) {
+		t.Helper()
+		ts := base + offset
+		ec := &EvalConfig{
+			Start:              ts,
+			End:                ts,
+			Step:               60e3,
+			MaxPointsPerSeries: 1e4,
+			MaxSeries:          1000,
+			Deadline:           searchutil.NewDeadline(time.Now(), time.Minute, ""),
+			RoundDigits:        100,
+			LookbackDelta:      lookbackDelta,
+			PromQLCompat:       true,
+		}
+		result, err := Exec(nil, ec, q, true)
+		if err != nil {
+			t.Fatalf("unexpected error when executing %q: %s", q, err)
+		}
+		if mnExpected == nil {
+			if len(result) != 0 {
+				t.Fatalf("expecting empty result for %q; got %d series", q, len(result))
+			}
+			return
+		}
+		resultExpected := []netstorage.Result{
+			{
+				MetricName: *mnExpected,
+				Values:     []float64{vExpected},
+				Timestamps: []int64{ts},
+			},
+		}
+		testResultsEqual(t, result, resultExpected)
+	}
+	f := func(q string, offset int64, mnExpected *storage.MetricName, vExpected float64) {
+		t.Helper()
+		fWithLookbackDelta(q, 0, offset, mnExpected, vExpected)
+	}
+
+	// The expected values are obtained from Prometheus for the same samples.
+
+	// rate and increase over the counter reset.
+	// The window (40s..100s] contains 30, 5, 15, 25, so the increase is 25 and it is extrapolated from 45s to 40s and from 90s to 100s.
+	f(`increase(http_requests_total[1m])`, 100e3, &mnWithoutName, 25*60.0/45)
+	f(`rate(http_requests_total[1m])`, 100e3, &mnWithoutName, 25*60.0/45/60)
+	f(`resets(http_requests_total[1m])`, 100e3, &mnWithoutName, 1)
+
+	// Instant vector selector returns the last sample on the 5 minutes lookback window,
+	// even if the lookback window exceeds the step.
+	f(`temperature`, 100e3, &mnWithName, 7.4)
+	f(`temperature`, 314e3, &mnWithName, 7.4)
+	f(`temperature`, 315e3, nil, 0)
+
+	// The lookback window exceeding the default staleness interval, e.g. -query.lookback-delta=10m in Prometheus.
+	fWithLookbackDelta(`temperature`, 10*60e3, 554e3, &mnWithName, 7.4)
+	fWithLookbackDelta(`temperature`, 10*60e3, 615e3, nil, 0)
+
+	// Metric names are dropped by math functions and by rollup functions except of last_over_time.
+	f(`ceil(temperature)`, 100e3, &mnWithoutName, 8)
+	f(`round(temperature)`, 100e3, &mnWithoutName, 7)
+	f(`clamp_max(temperature, 5)`, 100e3, &mnWithoutName, 5)
+	f(`abs(temperature)`, 100e3, &mnWithoutName, 7.4)
+	f(`sum_over_time(temperature[5m])`, 100e3, &mnWithoutName, 7.4)
+	f(`last_over_time(temperature[5m])`, 100e3, &mnWithName, 7.4)
+
+	// Subqueries are evaluated at steps aligned to the subquery step.
+	// rate(http_requests_total[30s]) equals to 2/3, 1/3, 1/2 and 2/3 at 45s, 60s, 75s and 90s.
+	f(`min_over_time(rate(http_requests_total[30s])[1m:15s])`, 100e3, &mnWithoutName, 1.0/3)
+	f(`max_over_time(rate(http_requests_total[30s])[1m:15s])`, 100e3, &mnWithoutName, 2.0/3)
+	f(`count_over_time(rate(http_requests_total[30s])[1m:15s])`, 100e3, &mnWithoutName, 4)
+}
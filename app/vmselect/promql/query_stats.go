@@ -13,6 +13,16 @@ type QueryStats struct {
 	ExecutionDuration atomic.Pointer[time.Duration]
 	// SeriesFetched contains the number of series fetched from storage or cache.
 	SeriesFetched atomic.Int64
+	// SamplesScanned contains the number of raw samples in the data blocks read from storage.
+	SamplesScanned atomic.Int64
+	// MemoryBytes contains the estimated memory in bytes needed for rollup evaluations.
+	MemoryBytes atomic.Int64
+	// RollupCacheFullHits contains the number of rollups fully served from the rollup result cache.
+	RollupCacheFullHits atomic.Int64
+	// RollupCachePartialHits contains the number of rollups partially served from the rollup result cache.
+	RollupCachePartialHits atomic.Int64
+	// RollupCacheMisses contains the number of rollups, which couldn't be served from the rollup result cache.
+	RollupCacheMisses atomic.Int64
 
 	at *auth.Token
 
@@ -46,6 +56,41 @@ func (qs *QueryStats) addSeriesFetched(n int) {
 	qs.SeriesFetched.Add(int64(n))
 }
 
+func (qs *QueryStats) addSamplesScanned(n int) {
+	if qs == nil {
+		return
+	}
+	qs.SamplesScanned.Add(int64(n))
+}
+
+func (qs *QueryStats) addMemoryBytes(n int64) {
+	if qs == nil {
+		return
+	}
+	qs.MemoryBytes.Add(n)
+}
+
+func (qs *QueryStats) addRollupCacheFullHit() {
+	if qs == nil {
+		return
+	}
+	qs.RollupCacheFullHits.Add(1)
+}
+
+func (qs *QueryStats) addRollupCachePartialHit() {
+	if qs == nil {
+		return
+	}
+	qs.RollupCachePartialHits.Add(1)
+}
+
+func (qs *QueryStats) addRollupCacheMiss() {
+	if qs == nil {
+		return
+	}
+	qs.RollupCacheMisses.Add(1)
+}
+
 func (qs *QueryStats) addExecutionTimeMsec(startTime time.Time) {
 	if qs == nil {
 		return
@@ -369,7 +369,7 @@ func getRollupTag(expr metricsql.Expr) (string, error) {
 }
 
 func getRollupConfigs(funcName string, rf rollupFunc, expr metricsql.Expr, start, end, step int64, maxPointsPerSeries int,
-	window, lookbackDelta int64, sharedTimestamps []int64) (
+	window, lookbackDelta int64, promqlCompat bool, sharedTimestamps []int64) (
 	func(values []float64, timestamps []int64), []*rollupConfig, error) {
 	preFunc := func(_ []float64, _ []int64) {}
 	funcName = strings.ToLower(funcName)
@@ -381,7 +381,8 @@ func getRollupConfigs(funcName string, rf rollupFunc, expr metricsql.Expr, start
 		stalenessInterval = window
 	}
 
-	if rollupFuncsRemoveCounterResets[funcName] {
+	if rollupFuncsRemoveCounterResets[funcName] && !promqlCompat {
+		// Prometheus-compatible rollup functions detect counter resets on their own.
 		preFunc = func(values []float64, timestamps []int64) {
 			removeCounterResets(values, timestamps, stalenessInterval)
 		}
@@ -403,6 +404,7 @@ func getRollupConfigs(funcName string, rf rollupFunc, expr metricsql.Expr, start
 			Timestamps:            sharedTimestamps,
 			isDefaultRollup:       funcName == "default_rollup",
 			samplesScannedPerCall: samplesScannedPerCall,
+			promqlCompat:          promqlCompat,
 		}
 	}
 
@@ -595,6 +597,19 @@ type rollupConfig struct {
 	//
 	// If zero, then it is considered that Func scans all the samples passed to it.
 	samplesScannedPerCall int
+
+	// Whether Prometheus compatibility mode is enabled.
+	//
+	// In this mode Func doesn't see samples outside the lookbehind window.
+	promqlCompat bool
+}
+
+// keepsMetricName returns true if the rollup function with the given funcName keeps metric name in the results.
+func (rc *rollupConfig) keepsMetricName(funcName string) bool {
+	if rc.promqlCompat {
+		return rollupFuncsKeepMetricNamePromQLCompat[funcName]
+	}
+	return rollupFuncsKeepMetricName[funcName]
 }
 
 func (rc *rollupConfig) getTimestamps() []int64 {
@@ -724,6 +739,13 @@ func (rc *rollupConfig) doInternal(dstValues []float64, tsm *timeseriesMap, valu
 		}
 	}
 	window := rc.Window
+	if window <= 0 && rc.promqlCompat {
+		// Prometheus selects the last sample on the lookback delta for instant vector selectors.
+		window = rc.LookbackDelta
+		if window <= 0 {
+			window = promqlDefaultLookbackDelta
+		}
+	}
 	if window <= 0 {
 		window = rc.Step
 		if rc.MayAdjustWindow && window < maxPrevInterval {
@@ -791,6 +813,11 @@ func (rc *rollupConfig) doInternal(dstValues []float64, tsm *timeseriesMap, valu
 		} else {
 			rfa.realNextValue = nan
 		}
+		if rc.promqlCompat {
+			rfa.prevValue = nan
+			rfa.realPrevValue = nan
+			rfa.realNextValue = nan
+		}
 		rfa.currTimestamp = tEnd
 		value := f(rfa)
 		rfa.idx++
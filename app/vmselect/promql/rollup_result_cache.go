@@ -179,10 +179,12 @@ func InitRollupResultCache(cachePath string) {
 	rollupResultCacheV = &rollupResultCache{
 		c: c,
 	}
+	initRollupResultCachePeers()
 }
 
 // StopRollupResultCache closes the rollupResult cache.
 func StopRollupResultCache() {
+	stopRollupResultCachePeers()
 	if len(rollupResultCachePath) == 0 {
 		rollupResultCacheV.c.Stop()
 		rollupResultCacheV.c = nil
@@ -210,7 +212,16 @@ type rollupResultCache struct {
 var rollupResultCacheResets = metrics.NewCounter(`vm_cache_resets_total{type="promql/rollupResult"}`)
 
 // ResetRollupResultCache resets rollup result cache.
+//
+// The cache is reset at -search.rollupResultCache.peers too.
 func ResetRollupResultCache() {
+	resetRollupResultCacheLocal()
+	if p := rrcPeers; p != nil {
+		p.scheduleReset()
+	}
+}
+
+func resetRollupResultCacheLocal() {
 	rollupResultCacheResets.Inc()
 	rollupResultCacheKeyPrefix.Add(1)
 	logger.Infof("rollupResult cache has been cleared")
@@ -292,30 +303,12 @@ func (rrc *rollupResultCache) GetSeries(qt *querytracer.Tracer, ec *EvalConfig,
 	defer bbPool.Put(bb)
 
 	bb.B = marshalRollupResultCacheKeyForSeries(bb.B[:0], expr, window, ec.Step, ec.EnforcedTagFilterss)
-	metainfoBuf := rrc.c.Get(nil, bb.B)
-	if len(metainfoBuf) == 0 {
-		qt.Printf("nothing found")
-		return nil, ec.Start
-	}
-	var mi rollupResultCacheMetainfo
-	if err := mi.Unmarshal(metainfoBuf); err != nil {
-		logger.Panicf("BUG: cannot unmarshal rollupResultCacheMetainfo: %s; it looks like it was improperly saved", err)
-	}
-	key := mi.GetBestKey(ec.Start, ec.End)
-	if key.prefix == 0 && key.suffix == 0 {
-		qt.Printf("nothing found on the timeRange")
-		return nil, ec.Start
-	}
-
-	var ok bool
-	bb.B = key.Marshal(bb.B[:0])
-	tss, ok = rrc.getSeriesFromCache(qt, bb.B)
+	tss, ok := rrc.getSeriesLocal(qt, bb.B, ec.Start, ec.End)
 	if !ok {
-		mi.RemoveKey(key)
-		metainfoBuf = mi.Marshal(metainfoBuf[:0])
-		bb.B = marshalRollupResultCacheKeyForSeries(bb.B[:0], expr, window, ec.Step, ec.EnforcedTagFilterss)
-		rrc.c.Set(bb.B, metainfoBuf)
-		return nil, ec.Start
+		tss, ok = rrc.getSeriesFromPeer(qt, bb.B, ec.Start, ec.End)
+		if !ok {
+			return nil, ec.Start
+		}
 	}
 
 	// Extract values for the matching timestamps
@@ -358,6 +351,37 @@ func (rrc *rollupResultCache) GetSeries(qt *querytracer.Tracer, ec *EvalConfig,
 	return tss, newStart
 }
 
+// getSeriesLocal returns series with the best coverage of the given time range from the local cache for the given metainfoKey.
+func (rrc *rollupResultCache) getSeriesLocal(qt *querytracer.Tracer, metainfoKey []byte, start, end int64) ([]*timeseries, bool) {
+	metainfoBuf := rrc.c.Get(nil, metainfoKey)
+	if len(metainfoBuf) == 0 {
+		qt.Printf("nothing found")
+		return nil, false
+	}
+	var mi rollupResultCacheMetainfo
+	if err := mi.Unmarshal(metainfoBuf); err != nil {
+		logger.Panicf("BUG: cannot unmarshal rollupResultCacheMetainfo: %s; it looks like it was improperly saved", err)
+	}
+	key := mi.GetBestKey(start, end)
+	if key.prefix == 0 && key.suffix == 0 {
+		qt.Printf("nothing found on the timeRange")
+		return nil, false
+	}
+
+	bb := bbPool.Get()
+	defer bbPool.Put(bb)
+
+	bb.B = key.Marshal(bb.B[:0])
+	tss, ok := rrc.getSeriesFromCache(qt, bb.B)
+	if !ok {
+		mi.RemoveKey(key)
+		metainfoBuf = mi.Marshal(metainfoBuf[:0])
+		rrc.c.Set(metainfoKey, metainfoBuf)
+		return nil, false
+	}
+	return tss, true
+}
+
 var resultBufPool bytesutil.ByteBufferPool
 
 func (rrc *rollupResultCache) PutSeries(qt *querytracer.Tracer, ec *EvalConfig, expr metricsql.Expr, window int64, tss []*timeseries) {
@@ -443,16 +467,17 @@ func (rrc *rollupResultCache) PutSeries(qt *querytracer.Tracer, ec *EvalConfig,
 	key.suffix = rollupResultCacheKeySuffix.Add(1)
 
 	bb := bbPool.Get()
+	defer bbPool.Put(bb)
 	bb.B = key.Marshal(bb.B[:0])
-	ok := rrc.putSeriesToCache(qt, bb.B, ec.Step, tss)
-	bbPool.Put(bb)
-	if !ok {
+	if !rrc.putSeriesToCache(qt, bb.B, ec.Step, tss) {
 		return
 	}
 
-	mi.AddKey(key, timestamps[0], timestamps[len(timestamps)-1])
+	mi.AddKey(key, start, end)
 	metainfoBuf.B = mi.Marshal(metainfoBuf.B[:0])
 	rrc.c.Set(metainfoKey.B, metainfoBuf.B)
+
+	rrc.pushSeriesToPeer(qt, metainfoKey.B, bb.B, start, end)
 }
 
 var (
@@ -0,0 +1,532 @@
+package promql
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/consistenthash"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/cespare/xxhash/v2"
+)
+
+var (
+	rollupResultCachePeerAddrs = flagutil.NewArrayString("search.rollupResultCache.peers", "Optional list of VictoriaMetrics instances for sharing rollup result cache, "+
+		"including the current instance. For example, http://vm1:8428,http://vm2:8428 . Every cached entry is owned by a single instance from the list, "+
+		"which is selected via consistent hashing. The same list must be passed to all the instances. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#shared-rollup-result-cache . See also -search.rollupResultCache.selfAddr")
+	rollupResultCacheSelfAddr = flag.String("search.rollupResultCache.selfAddr", "", "The address of the current instance in the -search.rollupResultCache.peers list. "+
+		"If empty, then the current instance only fetches cached entries from peers, while it doesn't own cached entries")
+	rollupResultCachePeerTimeout = flag.Duration("search.rollupResultCache.peerTimeout", time.Second, "Timeout for requests to -search.rollupResultCache.peers")
+	rollupResultCachePeerAuthKey = flagutil.NewPassword("search.rollupResultCache.peerAuthKey", "authKey for /internal/rollupResultCache/* endpoints, "+
+		"which are used for sharing rollup result cache between -search.rollupResultCache.peers. "+
+		"It must be set when -search.rollupResultCache.peers is set. The same authKey is sent to peers. "+
+		"/internal/rollupResultCache/* endpoints are disabled if this flag isn't set")
+)
+
+// maxPeerRequestBodySize is the maximum size of the request body for /internal/rollupResultCache/* endpoints.
+const maxPeerRequestBodySize = 64 * 1024 * 1024
+
+var rrcPeers *rollupResultCachePeers
+
+func initRollupResultCachePeers() {
+	if len(*rollupResultCachePeerAddrs) == 0 {
+		return
+	}
+	p, err := newRollupResultCachePeers(*rollupResultCachePeerAddrs, *rollupResultCacheSelfAddr, *rollupResultCachePeerTimeout, rollupResultCachePeerAuthKey.Get())
+	if err != nil {
+		logger.Fatalf("cannot initialize -search.rollupResultCache.peers: %s", err)
+	}
+	rrcPeers = p
+	logger.Infof("sharing rollupResult cache with %d peers: %s", len(p.addrs), p.addrs)
+}
+
+func stopRollupResultCachePeers() {
+	if rrcPeers == nil {
+		return
+	}
+	rrcPeers.stop()
+	rrcPeers = nil
+}
+
+// rollupResultCachePeers shares rollup result cache entries with other VictoriaMetrics instances.
+//
+// Every entry is owned by a single peer, which is selected via consistent hashing over the entry key without the node-specific prefix.
+// Instances, which don't own the entry, push it to the owner after calculating it and fetch it from the owner on local cache miss.
+type rollupResultCachePeers struct {
+	addrs   []string
+	selfIdx int
+	ch      *consistenthash.ConsistentHash
+
+	authKey string
+	c       *http.Client
+
+	pushCh chan *peerPushRequest
+
+	// resetPending contains per-peer flags for cache resets, which must be sent to peers.
+	resetPending []atomic.Bool
+	resetCh      chan struct{}
+	stopCh       chan struct{}
+
+	wg sync.WaitGroup
+}
+
+type peerPushRequest struct {
+	addr string
+	body []byte
+}
+
+const peerPushWorkers = 4
+
+// peerResetRetryInterval is the interval for retrying failed cache resets at peers.
+const peerResetRetryInterval = 5 * time.Second
+
+func newRollupResultCachePeers(addrs []string, selfAddr string, timeout time.Duration, authKey string) (*rollupResultCachePeers, error) {
+	if authKey == "" {
+		// Peers accept cache entries from each other, so the cache could be poisoned by anyone with access to the peers without authKey.
+		return nil, fmt.Errorf("-search.rollupResultCache.peerAuthKey must be set when -search.rollupResultCache.peers is set")
+	}
+	selfIdx := -1
+	for i, addr := range addrs {
+		if _, err := url.Parse(addr); err != nil {
+			return nil, fmt.Errorf("cannot parse peer address %q: %w", addr, err)
+		}
+		if addr == selfAddr {
+			selfIdx = i
+		}
+	}
+	if selfAddr != "" && selfIdx < 0 {
+		return nil, fmt.Errorf("-search.rollupResultCache.selfAddr=%q is missing in the list of peers %q", selfAddr, addrs)
+	}
+	p := &rollupResultCachePeers{
+		addrs:   append([]string{}, addrs...),
+		selfIdx: selfIdx,
+		ch:      consistenthash.NewConsistentHash(addrs, 0),
+		authKey: authKey,
+		c: &http.Client{
+			Transport: httputil.NewTransport(false, "vm_rollup_result_cache_peers"),
+			Timeout:   timeout,
+		},
+		pushCh: make(chan *peerPushRequest, 1024),
+
+		resetPending: make([]atomic.Bool, len(addrs)),
+		resetCh:      make(chan struct{}, 1),
+		stopCh:       make(chan struct{}),
+	}
+	for i := 0; i < peerPushWorkers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for pr := range p.pushCh {
+				p.push(pr)
+			}
+		}()
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.resetWorker()
+	}()
+	return p, nil
+}
+
+func (p *rollupResultCachePeers) stop() {
+	close(p.pushCh)
+	close(p.stopCh)
+	p.wg.Wait()
+}
+
+// scheduleReset schedules cache reset at all the peers except of the current instance.
+//
+// Peers must reset their caches together with the current instance, since otherwise they continue serving stale entries owned by them.
+func (p *rollupResultCachePeers) scheduleReset() {
+	for i := range p.resetPending {
+		if i != p.selfIdx {
+			p.resetPending[i].Store(true)
+		}
+	}
+	select {
+	case p.resetCh <- struct{}{}:
+	default:
+		// The reset is already scheduled.
+	}
+}
+
+func (p *rollupResultCachePeers) resetWorker() {
+	t := time.NewTicker(peerResetRetryInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-p.resetCh:
+		case <-t.C:
+		}
+		for i, addr := range p.addrs {
+			if !p.resetPending[i].Swap(false) {
+				continue
+			}
+			peerResets.Inc()
+			if _, err := p.doRequest(addr, "/internal/rollupResultCache/reset", nil, nil); err != nil {
+				// Retry the reset later, since the peer continues serving stale entries until it is reset.
+				p.resetPending[i].Store(true)
+				peerResetErrors.Inc()
+				peerLogger.Warnf("cannot reset rollupResult cache at peer; retrying in %s: %s", peerResetRetryInterval, err)
+			}
+		}
+	}
+}
+
+// getOwner returns the address of the peer, which owns the entry with the given shared key.
+//
+// Empty string is returned if the entry is owned by the current instance.
+func (p *rollupResultCachePeers) getOwner(sharedKey []byte) string {
+	h := xxhash.Sum64(sharedKey)
+	idx := p.ch.GetNodeIdx(h, nil)
+	if idx == p.selfIdx {
+		return ""
+	}
+	return p.addrs[idx]
+}
+
+// fetch returns compressed cache entry from the peer at addr for the given sharedKey and time range.
+//
+// nil is returned if the peer doesn't have the entry.
+func (p *rollupResultCachePeers) fetch(addr string, sharedKey []byte, start, end int64) ([]byte, error) {
+	args := url.Values{}
+	args.Set("start", strconv.FormatInt(start, 10))
+	args.Set("end", strconv.FormatInt(end, 10))
+	data, err := p.doRequest(addr, "/internal/rollupResultCache/get", args, sharedKey)
+	if err != nil {
+		peerFetchErrors.Inc()
+		return nil, err
+	}
+	return data, nil
+}
+
+// schedulePush schedules pushing the compressed cache entry for the given sharedKey and time range to the peer at addr.
+func (p *rollupResultCachePeers) schedulePush(addr string, sharedKey []byte, start, end int64, data []byte) {
+	body := encoding.MarshalInt64(nil, start)
+	body = encoding.MarshalInt64(body, end)
+	body = encoding.MarshalBytes(body, sharedKey)
+	body = append(body, data...)
+	pr := &peerPushRequest{
+		addr: addr,
+		body: body,
+	}
+	select {
+	case p.pushCh <- pr:
+	default:
+		// Do not block query execution if peers are slow.
+		peerPushesDropped.Inc()
+	}
+}
+
+func (p *rollupResultCachePeers) push(pr *peerPushRequest) {
+	peerPushes.Inc()
+	if _, err := p.doRequest(pr.addr, "/internal/rollupResultCache/put", nil, pr.body); err != nil {
+		peerPushErrors.Inc()
+		peerLogger.Warnf("cannot push rollupResult cache entry to peer: %s", err)
+	}
+}
+
+func (p *rollupResultCachePeers) doRequest(addr, path string, args url.Values, body []byte) ([]byte, error) {
+	if p.authKey != "" {
+		if args == nil {
+			args = url.Values{}
+		}
+		args.Set("authKey", p.authKey)
+	}
+	reqURL := addr + path
+	if len(args) > 0 {
+		reqURL += "?" + args.Encode()
+	}
+	resp, err := p.c.Post(reqURL, "application/octet-stream", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("cannot send request to %q: %w", addr+path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read response from %q: %w", addr+path, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return data, nil
+	case http.StatusNoContent:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected status code returned from %q: %d; want %d; response body: %q", addr+path, resp.StatusCode, http.StatusOK, data)
+	}
+}
+
+var (
+	peerFetches        = metrics.NewCounter(`vm_rollup_result_cache_peer_requests_total{type="get"}`)
+	peerFetchErrors    = metrics.NewCounter(`vm_rollup_result_cache_peer_request_errors_total{type="get"}`)
+	peerFetchHits      = metrics.NewCounter(`vm_rollup_result_cache_peer_hits_total`)
+	peerPushes         = metrics.NewCounter(`vm_rollup_result_cache_peer_requests_total{type="put"}`)
+	peerPushErrors     = metrics.NewCounter(`vm_rollup_result_cache_peer_request_errors_total{type="put"}`)
+	peerResets         = metrics.NewCounter(`vm_rollup_result_cache_peer_requests_total{type="reset"}`)
+	peerResetErrors    = metrics.NewCounter(`vm_rollup_result_cache_peer_request_errors_total{type="reset"}`)
+	peerPushesDropped  = metrics.NewCounter(`vm_rollup_result_cache_peer_pushes_dropped_total`)
+	peerInvalidEntries = metrics.NewCounter(`vm_rollup_result_cache_peer_invalid_entries_total`)
+)
+
+var peerLogger = logger.WithThrottler("rollupResultCachePeers", 5*time.Second)
+
+// getSeriesFromPeer returns series for the given local cache key and time range from the peer, which owns the key.
+func (rrc *rollupResultCache) getSeriesFromPeer(qt *querytracer.Tracer, key []byte, start, end int64) ([]*timeseries, bool) {
+	p := rrcPeers
+	if p == nil {
+		return nil, false
+	}
+	sharedKey := getSharedRollupResultCacheKey(nil, key)
+	addr := p.getOwner(sharedKey)
+	if addr == "" {
+		return nil, false
+	}
+	peerFetches.Inc()
+	data, err := p.fetch(addr, sharedKey, start, end)
+	if err != nil {
+		peerLogger.Warnf("cannot fetch rollupResult cache entry from peer: %s", err)
+		qt.Printf("cannot fetch cached series from peer %s: %s", addr, err)
+		return nil, false
+	}
+	if len(data) == 0 {
+		qt.Printf("nothing found at peer %s", addr)
+		return nil, false
+	}
+	tss, err := unmarshalCompressedRollupResultCacheEntry(data)
+	if err != nil {
+		peerInvalidEntries.Inc()
+		peerLogger.Warnf("cannot unmarshal rollupResult cache entry obtained from peer %q: %s", addr, err)
+		return nil, false
+	}
+	peerFetchHits.Inc()
+	qt.Printf("fetch %d series with compressed size %d bytes from peer %s", len(tss), len(data), addr)
+	return tss, true
+}
+
+// pushSeriesToPeer pushes the cache entry stored under the given entryKey for the given local metainfoKey
+// to the peer, which owns the metainfoKey.
+func (rrc *rollupResultCache) pushSeriesToPeer(qt *querytracer.Tracer, metainfoKey, entryKey []byte, start, end int64) {
+	p := rrcPeers
+	if p == nil {
+		return
+	}
+	sharedKey := getSharedRollupResultCacheKey(nil, metainfoKey)
+	addr := p.getOwner(sharedKey)
+	if addr == "" {
+		return
+	}
+	data := rrc.c.GetBig(nil, entryKey)
+	if len(data) == 0 {
+		return
+	}
+	p.schedulePush(addr, sharedKey, start, end, data)
+	qt.Printf("schedule pushing %d bytes to peer %s", len(data), addr)
+}
+
+// getCompressedEntry returns compressed entry with the best coverage of the given time range for the given local metainfoKey.
+//
+// nil is returned if there are no matching entries.
+func (rrc *rollupResultCache) getCompressedEntry(metainfoKey []byte, start, end int64) []byte {
+	metainfoBuf := rrc.c.Get(nil, metainfoKey)
+	if len(metainfoBuf) == 0 {
+		return nil
+	}
+	var mi rollupResultCacheMetainfo
+	if err := mi.Unmarshal(metainfoBuf); err != nil {
+		logger.Panicf("BUG: cannot unmarshal rollupResultCacheMetainfo: %s; it looks like it was improperly saved", err)
+	}
+	key := mi.GetBestKey(start, end)
+	if key.prefix == 0 && key.suffix == 0 {
+		return nil
+	}
+	return rrc.c.GetBig(nil, key.Marshal(nil))
+}
+
+// putCompressedEntry stores compressed entry with series on the given time range for the given local metainfoKey.
+func (rrc *rollupResultCache) putCompressedEntry(metainfoKey []byte, start, end int64, data []byte) {
+	metainfoBuf := rrc.c.Get(nil, metainfoKey)
+	var mi rollupResultCacheMetainfo
+	if len(metainfoBuf) > 0 {
+		if err := mi.Unmarshal(metainfoBuf); err != nil {
+			logger.Panicf("BUG: cannot unmarshal rollupResultCacheMetainfo: %s; it looks like it was improperly saved", err)
+		}
+	}
+	if mi.CoversTimeRange(start, end) {
+		return
+	}
+	var key rollupResultCacheKey
+	key.prefix = rollupResultCacheKeyPrefix.Load()
+	key.suffix = rollupResultCacheKeySuffix.Add(1)
+	rrc.c.SetBig(key.Marshal(nil), data)
+	mi.AddKey(key, start, end)
+	metainfoBuf = mi.Marshal(metainfoBuf[:0])
+	rrc.c.Set(metainfoKey, metainfoBuf)
+}
+
+// RollupResultCacheGetHandler processes /internal/rollupResultCache/get requests from -search.rollupResultCache.peers.
+func RollupResultCacheGetHandler(w http.ResponseWriter, r *http.Request) error {
+	return rollupResultCacheV.getHandler(w, r)
+}
+
+func (rrc *rollupResultCache) getHandler(w http.ResponseWriter, r *http.Request) error {
+	if !checkPeerAuth(w, r) {
+		return nil
+	}
+	start, err := getInt64Arg(r, "start")
+	if err != nil {
+		return err
+	}
+	end, err := getInt64Arg(r, "end")
+	if err != nil {
+		return err
+	}
+	sharedKey, err := readPeerRequestBody(r)
+	if err != nil {
+		return err
+	}
+	metainfoKey, err := getLocalRollupResultCacheKey(nil, sharedKey)
+	if err != nil {
+		return err
+	}
+	data := rrc.getCompressedEntry(metainfoKey, start, end)
+	if len(data) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, err = w.Write(data)
+	return err
+}
+
+// RollupResultCachePutHandler processes /internal/rollupResultCache/put requests from -search.rollupResultCache.peers.
+func RollupResultCachePutHandler(w http.ResponseWriter, r *http.Request) error {
+	return rollupResultCacheV.putHandler(w, r)
+}
+
+func (rrc *rollupResultCache) putHandler(w http.ResponseWriter, r *http.Request) error {
+	if !checkPeerAuth(w, r) {
+		return nil
+	}
+	body, err := readPeerRequestBody(r)
+	if err != nil {
+		return err
+	}
+	if len(body) < 16 {
+		return fmt.Errorf("too short request body; got %d bytes; want at least 16 bytes", len(body))
+	}
+	start := encoding.UnmarshalInt64(body)
+	end := encoding.UnmarshalInt64(body[8:])
+	body = body[16:]
+	sharedKey, n := encoding.UnmarshalBytes(body)
+	if n <= 0 {
+		return fmt.Errorf("cannot unmarshal cache key from request body")
+	}
+	data := body[n:]
+	metainfoKey, err := getLocalRollupResultCacheKey(nil, sharedKey)
+	if err != nil {
+		return err
+	}
+	// Verify the entry, since invalid entries result in panic when reading them from the cache.
+	if _, err := unmarshalCompressedRollupResultCacheEntry(data); err != nil {
+		peerInvalidEntries.Inc()
+		return fmt.Errorf("invalid cache entry: %w", err)
+	}
+	rrc.putCompressedEntry(metainfoKey, start, end, data)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// RollupResultCacheResetHandler processes /internal/rollupResultCache/reset requests from -search.rollupResultCache.peers.
+func RollupResultCacheResetHandler(w http.ResponseWriter, r *http.Request) error {
+	if !checkPeerAuth(w, r) {
+		return nil
+	}
+	// Do not propagate the reset to peers, since the peer, which sent the request, resets all the peers.
+	resetRollupResultCacheLocal()
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// checkPeerAuth verifies -search.rollupResultCache.peerAuthKey for requests to /internal/rollupResultCache/* endpoints.
+//
+// The endpoints are disabled if -search.rollupResultCache.peerAuthKey isn't set.
+func checkPeerAuth(w http.ResponseWriter, r *http.Request) bool {
+	if rollupResultCachePeerAuthKey.Get() == "" {
+		http.Error(w, "/internal/rollupResultCache/* endpoints are disabled, since -search.rollupResultCache.peerAuthKey isn't set", http.StatusForbidden)
+		return false
+	}
+	return httpserver.CheckAuthFlag(w, r, rollupResultCachePeerAuthKey)
+}
+
+func getInt64Arg(r *http.Request, argKey string) (int64, error) {
+	s := r.FormValue(argKey)
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %s=%q: %w", argKey, s, err)
+	}
+	return n, nil
+}
+
+func readPeerRequestBody(r *http.Request) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxPeerRequestBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read request body: %w", err)
+	}
+	if len(data) > maxPeerRequestBodySize {
+		return nil, fmt.Errorf("too big request body; mustn't exceed %d bytes", maxPeerRequestBodySize)
+	}
+	return data, nil
+}
+
+func unmarshalCompressedRollupResultCacheEntry(data []byte) ([]*timeseries, error) {
+	resultBuf, err := encoding.DecompressZSTD(nil, data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress entry: %w", err)
+	}
+	tss, err := unmarshalTimeseriesFast(resultBuf)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unmarshal series: %w", err)
+	}
+	return tss, nil
+}
+
+// getSharedRollupResultCacheKey appends the local key without node-specific prefix to dst and returns the result.
+//
+// See marshalRollupResultCacheKeyForSeries for the local key format.
+func getSharedRollupResultCacheKey(dst, key []byte) []byte {
+	dst = append(dst, key[0])
+	return append(dst, key[1+8:]...)
+}
+
+// getLocalRollupResultCacheKey appends local key for the given sharedKey to dst and returns the result.
+func getLocalRollupResultCacheKey(dst, sharedKey []byte) ([]byte, error) {
+	if len(sharedKey) < 2 {
+		return dst, fmt.Errorf("too short cache key; got %d bytes; want at least 2 bytes", len(sharedKey))
+	}
+	if sharedKey[0] != rollupResultCacheVersion {
+		return dst, fmt.Errorf("unsupported cache key version; got %d; want %d", sharedKey[0], rollupResultCacheVersion)
+	}
+	if sharedKey[1] != rollupResultCacheTypeSeries {
+		return dst, fmt.Errorf("unsupported cache key type; got %d; want %d", sharedKey[1], rollupResultCacheTypeSeries)
+	}
+	dst = append(dst, sharedKey[0])
+	dst = encoding.MarshalUint64(dst, rollupResultCacheKeyPrefix.Load())
+	return append(dst, sharedKey[1:]...), nil
+}
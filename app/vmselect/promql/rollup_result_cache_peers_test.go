@@ -0,0 +1,187 @@
+package promql
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/workingsetcache"
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+func TestRollupResultCachePeers(t *testing.T) {
+	const authKey = "secret"
+	if err := rollupResultCachePeerAuthKey.Set(authKey); err != nil {
+		t.Fatalf("cannot set -search.rollupResultCache.peerAuthKey: %s", err)
+	}
+	defer func() {
+		if err := rollupResultCachePeerAuthKey.Set(""); err != nil {
+			t.Fatalf("cannot reset -search.rollupResultCache.peerAuthKey: %s", err)
+		}
+	}()
+
+	InitRollupResultCache("")
+	defer StopRollupResultCache()
+
+	// The remote peer has its own cache.
+	rrcRemote := &rollupResultCache{
+		c: workingsetcache.New(1024 * 1024),
+	}
+	defer rrcRemote.c.Stop()
+	var remoteResets atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		switch r.URL.Path {
+		case "/internal/rollupResultCache/reset":
+			if r.FormValue("authKey") != authKey {
+				err = fmt.Errorf("unexpected authKey %q", r.FormValue("authKey"))
+				break
+			}
+			remoteResets.Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		case "/internal/rollupResultCache/get":
+			err = rrcRemote.getHandler(w, r)
+		case "/internal/rollupResultCache/put":
+			err = rrcRemote.putHandler(w, r)
+		default:
+			err = fmt.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := newRollupResultCachePeers([]string{"http://self", srv.URL}, "http://self", time.Second, authKey)
+	if err != nil {
+		t.Fatalf("cannot initialize peers: %s", err)
+	}
+	rrcPeers = p
+	defer stopRollupResultCachePeers()
+
+	if _, err := newRollupResultCachePeers([]string{"http://foo", "http://bar"}, "http://self", time.Second, authKey); err == nil {
+		t.Fatalf("expecting non-nil error for missing selfAddr")
+	}
+	if _, err := newRollupResultCachePeers([]string{"http://self", srv.URL}, "http://self", time.Second, ""); err == nil {
+		t.Fatalf("expecting non-nil error for missing authKey")
+	}
+
+	window := int64(456)
+	ec := &EvalConfig{
+		Start:              1000,
+		End:                2000,
+		Step:               200,
+		MaxPointsPerSeries: 1e4,
+
+		MayCache: true,
+	}
+
+	// Find expressions owned by the current node and by the remote peer.
+	var feLocal, feRemote *metricsql.FuncExpr
+	for i := 0; feLocal == nil || feRemote == nil; i++ {
+		fe := &metricsql.FuncExpr{
+			Name: "foo",
+			Args: []metricsql.Expr{
+				&metricsql.MetricExpr{
+					LabelFilterss: [][]metricsql.LabelFilter{{{
+						Label: "aaa",
+						Value: fmt.Sprintf("xxx_%d", i),
+					}}},
+				},
+			},
+		}
+		key := marshalRollupResultCacheKeyForSeries(nil, fe, window, ec.Step, nil)
+		if p.getOwner(getSharedRollupResultCacheKey(nil, key)) == "" {
+			feLocal = fe
+		} else {
+			feRemote = fe
+		}
+	}
+
+	tss := []*timeseries{
+		{
+			Timestamps: []int64{800, 1000, 1200},
+			Values:     []float64{0, 1, 2},
+		},
+	}
+	tssExpected := []*timeseries{
+		{
+			Timestamps: []int64{1000, 1200},
+			Values:     []float64{1, 2},
+		},
+	}
+	f := func(fe *metricsql.FuncExpr, mustPush bool) {
+		t.Helper()
+
+		rollupResultCacheV.PutSeries(nil, ec, fe, window, tss)
+
+		// Wait until the entry is pushed to the remote peer.
+		key := marshalRollupResultCacheKeyForSeries(nil, fe, window, ec.Step, nil)
+		deadline := time.Now().Add(5 * time.Second)
+		for mustPush {
+			if _, ok := rrcRemote.getSeriesLocal(nil, key, ec.Start, ec.End); ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("the entry hasn't been pushed to the remote peer")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		// Verify that the entry is fetched from the remote peer on local cache miss.
+		rrcLocal := &rollupResultCache{
+			c: workingsetcache.New(1024 * 1024),
+		}
+		defer rrcLocal.c.Stop()
+		tssResult, newStart := rrcLocal.GetSeries(nil, ec, fe, window)
+		if !mustPush {
+			if len(tssResult) != 0 || newStart != ec.Start {
+				t.Fatalf("unexpected series fetched for the locally owned entry: %v", tssResult)
+			}
+			return
+		}
+		if newStart != 1400 {
+			t.Fatalf("unexpected newStart; got %d; want %d", newStart, 1400)
+		}
+		testTimeseriesEqual(t, tssResult, tssExpected)
+	}
+
+	f(feRemote, true)
+	f(feLocal, false)
+	if _, ok := rrcRemote.getSeriesLocal(nil, marshalRollupResultCacheKeyForSeries(nil, feLocal, window, ec.Step, nil), ec.Start, ec.End); ok {
+		t.Fatalf("the locally owned entry mustn't be pushed to the remote peer")
+	}
+
+	// Verify that the cache reset is propagated to the remote peer.
+	ResetRollupResultCache()
+	deadline := time.Now().Add(5 * time.Second)
+	for remoteResets.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("the cache reset hasn't been propagated to the remote peer")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRollupResultCachePeerEndpointsWithoutAuthKey(t *testing.T) {
+	f := func(path string, h func(w http.ResponseWriter, r *http.Request) error) {
+		t.Helper()
+
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		w := httptest.NewRecorder()
+		if err := h(w, r); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("unexpected status code for %s; got %d; want %d", path, w.Code, http.StatusForbidden)
+		}
+	}
+
+	// The endpoints must be disabled if -search.rollupResultCache.peerAuthKey isn't set.
+	f("/internal/rollupResultCache/get", rollupResultCacheV.getHandler)
+	f("/internal/rollupResultCache/put", rollupResultCacheV.putHandler)
+	f("/internal/rollupResultCache/reset", RollupResultCacheResetHandler)
+}
@@ -0,0 +1,256 @@
+package querylog
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	filePath = flag.String("search.queryLog.filePath", "", "Optional path to a file for writing JSON records for slow and failed queries. "+
+		"Queries are considered slow if their execution time exceeds -search.logSlowQueryDuration. The file is rotated when its size exceeds -search.queryLog.maxFileSize. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#query-log")
+	maxFileSize = flagutil.NewBytes("search.queryLog.maxFileSize", 100*1024*1024, "The maximum size of the file at -search.queryLog.filePath before it is rotated")
+	maxFiles    = flag.Int("search.queryLog.maxFiles", 10, "The maximum number of rotated files to keep for -search.queryLog.filePath. Older files are deleted")
+	pushURL     = flag.String("search.queryLog.pushURL", "", "Optional URL for pushing JSON records for slow and failed queries. "+
+		"For example, http://victorialogs:9428/insert/jsonline?_msg_field=query&_time_field=time&_stream_fields=path for pushing the records to VictoriaLogs. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#query-log")
+	pushInterval = flag.Duration("search.queryLog.pushInterval", 5*time.Second, "The interval for pushing the collected query log records to -search.queryLog.pushURL")
+	maxPending   = flag.Int("search.queryLog.maxPendingRecords", 10000, "The maximum number of query log records waiting to be written. "+
+		"New records are dropped if the limit is reached")
+)
+
+// Record is a record for a slow or failed query.
+type Record struct {
+	// Time is the time when the query has finished.
+	Time time.Time `json:"time"`
+
+	// Path is the request path such as /api/v1/query_range.
+	Path string `json:"path"`
+
+	// Query is the query.
+	Query string `json:"query"`
+
+	// Start, End and Step are the query time range and step in milliseconds.
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Step  int64 `json:"step"`
+
+	// Duration is the query execution duration in seconds.
+	Duration float64 `json:"duration"`
+
+	// Slow is set if the query duration exceeds -search.logSlowQueryDuration.
+	Slow bool `json:"slow"`
+
+	// Error contains the error for failed queries.
+	Error string `json:"error,omitempty"`
+
+	// SeriesFetched is the number of series fetched from storage or cache.
+	SeriesFetched int64 `json:"series_fetched"`
+
+	// SamplesScanned is the number of raw samples read from storage.
+	SamplesScanned int64 `json:"samples_scanned"`
+
+	// MemoryBytes is the estimated memory needed for rollup evaluations.
+	MemoryBytes int64 `json:"memory_bytes"`
+
+	// RollupCacheFullHits, RollupCachePartialHits and RollupCacheMisses are stats for the rollup result cache.
+	RollupCacheFullHits    int64 `json:"rollup_cache_full_hits"`
+	RollupCachePartialHits int64 `json:"rollup_cache_partial_hits"`
+	RollupCacheMisses      int64 `json:"rollup_cache_misses"`
+
+	// RemoteAddr is the remote address of the client including X-Forwarded-For header.
+	RemoteAddr string `json:"remote_addr"`
+
+	// AuthUser is the username from Basic Auth header.
+	AuthUser string `json:"auth_user,omitempty"`
+
+	// Tenant is the tenant from -search.quotaTenantHeader header.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// Enabled returns true if query log is enabled.
+func Enabled() bool {
+	return *filePath != "" || *pushURL != ""
+}
+
+// Init initializes the query log.
+//
+// Queries with the duration exceeding slowQueryDuration are logged as slow. Zero slowQueryDuration disables logging of slow queries,
+// so only failed queries are logged.
+//
+// Stop must be called when the query log is no longer needed.
+func Init(slowQueryDuration time.Duration) {
+	if !Enabled() {
+		return
+	}
+	slowQueryDurationGlobal = slowQueryDuration
+	ql := &queryLog{
+		ch:     make(chan []byte, *maxPending),
+		stopCh: make(chan struct{}),
+	}
+	if *filePath != "" {
+		rf, err := newRotatingFile(*filePath, int64(maxFileSize.N), *maxFiles)
+		if err != nil {
+			logger.Fatalf("cannot open -search.queryLog.filePath: %s", err)
+		}
+		ql.rf = rf
+	}
+	if *pushURL != "" {
+		ql.client = &http.Client{
+			Transport: httputil.NewTransport(false, "vm_query_log"),
+			Timeout:   time.Minute,
+		}
+	}
+	ql.wg.Add(1)
+	go func() {
+		defer ql.wg.Done()
+		ql.run()
+	}()
+	qlGlobal = ql
+	logger.Infof("enabled query log for slow and failed queries; -search.queryLog.filePath=%q, pushing to -search.queryLog.pushURL: %v", *filePath, *pushURL != "")
+}
+
+// Stop stops the query log and writes all the pending records.
+func Stop() {
+	ql := qlGlobal
+	if ql == nil {
+		return
+	}
+	close(ql.stopCh)
+	ql.wg.Wait()
+	if ql.rf != nil {
+		ql.rf.mustClose()
+	}
+	qlGlobal = nil
+}
+
+var (
+	qlGlobal                *queryLog
+	slowQueryDurationGlobal time.Duration
+)
+
+// IsSlow returns true if the query with the given duration must be logged as slow.
+func IsSlow(d time.Duration) bool {
+	return slowQueryDurationGlobal > 0 && d >= slowQueryDurationGlobal
+}
+
+var (
+	recordsTotal        = metrics.NewCounter(`vm_query_log_records_total`)
+	recordsDroppedTotal = metrics.NewCounter(`vm_query_log_records_dropped_total`)
+	fileErrorsTotal     = metrics.NewCounter(`vm_query_log_errors_total{sink="file"}`)
+	pushErrorsTotal     = metrics.NewCounter(`vm_query_log_errors_total{sink="push"}`)
+)
+
+// WriteRecord writes r to the query log.
+//
+// The record is written asynchronously. It is dropped if there are more than -search.queryLog.maxPendingRecords pending records.
+func WriteRecord(r *Record) {
+	ql := qlGlobal
+	if ql == nil {
+		return
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal query log record: %s", err)
+	}
+	data = append(data, '\n')
+	select {
+	case ql.ch <- data:
+		recordsTotal.Inc()
+	default:
+		recordsDroppedTotal.Inc()
+	}
+}
+
+type queryLog struct {
+	ch     chan []byte
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	rf     *rotatingFile
+	client *http.Client
+
+	// pending contains records waiting to be pushed to -search.queryLog.pushURL
+	pending []byte
+}
+
+func (ql *queryLog) run() {
+	t := time.NewTicker(*pushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case data := <-ql.ch:
+			ql.writeRecord(data)
+		case <-t.C:
+			ql.push()
+		case <-ql.stopCh:
+			for {
+				select {
+				case data := <-ql.ch:
+					ql.writeRecord(data)
+				default:
+					ql.push()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (ql *queryLog) writeRecord(data []byte) {
+	if ql.rf != nil {
+		if err := ql.rf.write(data); err != nil {
+			fileErrorsTotal.Inc()
+			logger.Errorf("cannot write query log record to -search.queryLog.filePath: %s", err)
+		}
+	}
+	if ql.client != nil {
+		if len(ql.pending) > maxPendingBytes {
+			recordsDroppedTotal.Inc()
+			return
+		}
+		ql.pending = append(ql.pending, data...)
+	}
+}
+
+// maxPendingBytes limits the memory used by records, which couldn't be pushed to -search.queryLog.pushURL.
+const maxPendingBytes = 64 * 1024 * 1024
+
+func (ql *queryLog) push() {
+	if ql.client == nil || len(ql.pending) == 0 {
+		return
+	}
+	if err := ql.pushData(ql.pending); err != nil {
+		pushErrorsTotal.Inc()
+		logger.Errorf("cannot push query log records to -search.queryLog.pushURL: %s; retrying in %s", err, *pushInterval)
+		return
+	}
+	ql.pending = ql.pending[:0]
+}
+
+func (ql *queryLog) pushData(data []byte) error {
+	req, err := http.NewRequest(http.MethodPost, *pushURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("cannot create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/stream+json")
+	resp, err := ql.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package querylog
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "query.log")
+
+	rf, err := newRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("cannot create rotating file: %s", err)
+	}
+	for _, s := range []string{"foo\n", "bar\n", "baz\n", "qux\n", "abcdefghijklmnop\n", "x\n"} {
+		if err := rf.write([]byte(s)); err != nil {
+			t.Fatalf("cannot write %q: %s", s, err)
+		}
+		// Make sure rotated files have distinct names.
+		time.Sleep(time.Millisecond)
+	}
+	rf.mustClose()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read %q: %s", path, err)
+	}
+	if string(data) != "x\n" {
+		t.Fatalf("unexpected contents of the current file; got %q; want %q", data, "x\n")
+	}
+
+	paths, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("unexpected number of rotated files; got %d; want %d; files: %q", len(paths), 2, paths)
+	}
+	var rotated []string
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatalf("cannot read %q: %s", p, err)
+		}
+		rotated = append(rotated, string(data))
+	}
+	// The oldest rotated file with "foo\nbar\n" must be deleted.
+	if rotated[0] != "baz\nqux\n" || rotated[1] != "abcdefghijklmnop\n" {
+		t.Fatalf("unexpected contents of rotated files: %q", rotated)
+	}
+
+	// Re-opening the file must preserve its contents.
+	rf, err = newRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("cannot open rotating file: %s", err)
+	}
+	if rf.size != 2 {
+		t.Fatalf("unexpected size after re-opening; got %d; want %d", rf.size, 2)
+	}
+	rf.mustClose()
+}
+
+func TestRotatingFileGlobMetacharsInPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs[0-9]*")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("cannot create %q: %s", dir, err)
+	}
+	path := filepath.Join(dir, "query?.log")
+
+	rf, err := newRotatingFile(path, 4, 1)
+	if err != nil {
+		t.Fatalf("cannot create rotating file: %s", err)
+	}
+	for _, s := range []string{"foo\n", "bar\n", "baz\n"} {
+		if err := rf.write([]byte(s)); err != nil {
+			t.Fatalf("cannot write %q: %s", s, err)
+		}
+		// Make sure rotated files have distinct names.
+		time.Sleep(time.Millisecond)
+	}
+	rf.mustClose()
+
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("cannot read %q: %s", dir, err)
+	}
+	// The current file and a single rotated file must remain.
+	if len(des) != 2 {
+		t.Fatalf("unexpected number of files in %q; got %d; want 2", dir, len(des))
+	}
+}
+
+func TestRotatingFileRenameFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "query.log")
+
+	rf, err := newRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("cannot create rotating file: %s", err)
+	}
+	if err := rf.write([]byte("foo\n")); err != nil {
+		t.Fatalf("cannot write: %s", err)
+	}
+
+	// Remove the current file, so it cannot be renamed during rotation.
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+	if err := rf.write([]byte("abcdefghijk\n")); err == nil {
+		t.Fatalf("expecting non-nil error on unsuccessful rotation")
+	}
+
+	rf.mustClose()
+
+	// The file must be re-opened at the original path, so the data is written to it.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read %q: %s", path, err)
+	}
+	if string(data) != "abcdefghijk\n" {
+		t.Fatalf("unexpected contents of the current file; got %q; want %q", data, "abcdefghijk\n")
+	}
+}
+
+func TestQueryLogPush(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			fail = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("cannot read request body: %s", err)
+		}
+		bodies = append(bodies, string(data))
+	}))
+	defer srv.Close()
+
+	pushURLOrig := *pushURL
+	*pushURL = srv.URL
+	defer func() {
+		*pushURL = pushURLOrig
+	}()
+
+	ql := &queryLog{
+		client: srv.Client(),
+	}
+	rec := &Record{
+		Time:  time.Unix(1700000000, 0).UTC(),
+		Path:  "/api/v1/query",
+		Query: "sum(rate(foo[5m]))",
+		Error: "some error",
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatalf("cannot marshal record: %s", err)
+	}
+	data = append(data, '\n')
+	ql.writeRecord(data)
+
+	// The first push fails, so the record must be preserved for the next attempt.
+	ql.push()
+	if len(ql.pending) == 0 {
+		t.Fatalf("expecting non-empty pending records after failed push")
+	}
+	ql.writeRecord(data)
+	ql.push()
+	if len(ql.pending) != 0 {
+		t.Fatalf("expecting empty pending records after successful push; got %q", ql.pending)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 {
+		t.Fatalf("unexpected number of pushes; got %d; want %d", len(bodies), 1)
+	}
+	lines := strings.Split(strings.TrimSuffix(bodies[0], "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected number of pushed records; got %d; want %d", len(lines), 2)
+	}
+	for _, line := range lines {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("cannot unmarshal pushed record %q: %s", line, err)
+		}
+		if m["time"] != "2023-11-14T22:13:20Z" || m["query"] != rec.Query || m["error"] != rec.Error || m["path"] != rec.Path {
+			t.Fatalf("unexpected pushed record %q", line)
+		}
+	}
+}
+
+func TestIsSlow(t *testing.T) {
+	defer func() {
+		slowQueryDurationGlobal = 0
+	}()
+
+	slowQueryDurationGlobal = 0
+	if IsSlow(time.Hour) {
+		t.Fatalf("queries mustn't be slow when slow query logging is disabled")
+	}
+	slowQueryDurationGlobal = time.Second
+	if IsSlow(time.Millisecond) {
+		t.Fatalf("unexpected slow query for duration below the threshold")
+	}
+	if !IsSlow(time.Second) {
+		t.Fatalf("expecting slow query for duration at the threshold")
+	}
+}
@@ -0,0 +1,138 @@
+package querylog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// rotatingFile is a file, which is rotated when its size exceeds maxSize.
+//
+// Rotated files are renamed to <path>.<timestamp>. Only the last maxFiles rotated files are kept.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	// f is nil if the file couldn't be re-opened after rotation. It is re-opened on the next write then.
+	f    *os.File
+	size int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	rf := &rotatingFile{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open %q: %w", rf.path, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("cannot stat %q: %w", rf.path, err)
+	}
+	rf.f = f
+	rf.size = fi.Size()
+	return nil
+}
+
+func (rf *rotatingFile) write(data []byte) error {
+	if rf.f == nil {
+		if err := rf.open(); err != nil {
+			return err
+		}
+	}
+	var errRotate error
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(data)) > rf.maxSize {
+		errRotate = rf.rotate()
+		if rf.f == nil {
+			return errRotate
+		}
+		// The current file has been re-opened after unsuccessful rotation, so continue writing to it.
+	}
+	n, err := rf.f.Write(data)
+	rf.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("cannot write %d bytes to %q: %w", len(data), rf.path, err)
+	}
+	return errRotate
+}
+
+// rotate renames the current file to <path>.<timestamp> and opens a new file at path.
+//
+// The current file is re-opened at path if it cannot be renamed, so the writes continue to the current file.
+func (rf *rotatingFile) rotate() error {
+	f := rf.f
+	rf.f = nil
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot close %q: %w", rf.path, err)
+	}
+	// Use UTC timestamp with the fixed width, so rotated files are sorted by name in the creation order.
+	rotatedPath := rf.path + "." + time.Now().UTC().Format(rotatedFileTimestampFormat)
+	if err := os.Rename(rf.path, rotatedPath); err != nil {
+		err = fmt.Errorf("cannot rename %q to %q: %w", rf.path, rotatedPath, err)
+		if errOpen := rf.open(); errOpen != nil {
+			return fmt.Errorf("%w; %w", err, errOpen)
+		}
+		return err
+	}
+	if err := rf.open(); err != nil {
+		return err
+	}
+	rf.removeOldFiles()
+	return nil
+}
+
+const rotatedFileTimestampFormat = "20060102T150405.000000000"
+
+func (rf *rotatingFile) removeOldFiles() {
+	// Do not use filepath.Glob, since rf.path may contain glob metacharacters.
+	dir := filepath.Dir(rf.path)
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		logger.Errorf("cannot read directory with rotated query log files %q: %s", dir, err)
+		return
+	}
+	prefix := filepath.Base(rf.path) + "."
+	var rotatedPaths []string
+	for _, de := range des {
+		name := de.Name()
+		if !de.Type().IsRegular() || len(name) != len(prefix)+len(rotatedFileTimestampFormat) || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rotatedPaths = append(rotatedPaths, filepath.Join(dir, name))
+	}
+	if len(rotatedPaths) <= rf.maxFiles {
+		return
+	}
+	sort.Strings(rotatedPaths)
+	for _, path := range rotatedPaths[:len(rotatedPaths)-rf.maxFiles] {
+		if err := os.Remove(path); err != nil {
+			logger.Errorf("cannot remove rotated query log file %q: %s", path, err)
+		}
+	}
+}
+
+func (rf *rotatingFile) mustClose() {
+	if rf.f == nil {
+		return
+	}
+	if err := rf.f.Close(); err != nil {
+		logger.Errorf("cannot close %q: %s", rf.path, err)
+	}
+}
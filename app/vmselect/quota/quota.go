@@ -0,0 +1,468 @@
+package quota
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs/fscore"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timerpool"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	quotaConfig = flag.String("search.quotaConfig", "", "Optional path to a file with per-tenant query quotas. "+
+		"The path can point either to local file or to http url. The tenant is obtained from the request header set via -search.quotaTenantHeader. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#per-tenant-query-quotas . The config is reloaded on SIGHUP signal")
+	tenantHeader = flag.String("search.quotaTenantHeader", "X-Tenant-ID", "The name of the request header with the tenant name for applying quotas from -search.quotaConfig. "+
+		"The header is usually set by vmauth. The value in the form AccountID:ProjectID is normalized, so 42 and 42:0 refer to the same tenant")
+)
+
+// Limits contains query limits for a single tenant.
+//
+// Zero value for any limit means the limit isn't applied.
+type Limits struct {
+	// MaxSeries is the maximum number of unique time series a single query can select.
+	MaxSeries int `yaml:"max_series,omitempty"`
+
+	// MaxSamplesPerQuery is the maximum number of raw samples a single query can scan.
+	MaxSamplesPerQuery int `yaml:"max_samples_per_query,omitempty"`
+
+	// MaxQueryDuration is the maximum duration for query execution.
+	MaxQueryDuration *promutil.Duration `yaml:"max_query_duration,omitempty"`
+
+	// MaxConcurrentQueries is the maximum number of concurrently executed queries.
+	MaxConcurrentQueries int `yaml:"max_concurrent_queries,omitempty"`
+
+	// MaxDailySamples is the maximum number of raw samples all the queries can scan during a UTC day.
+	MaxDailySamples int64 `yaml:"max_daily_samples,omitempty"`
+}
+
+// Config is the config for -search.quotaConfig.
+type Config struct {
+	// Tenants maps tenant names to their limits.
+	Tenants map[string]*Limits `yaml:"tenants,omitempty"`
+
+	// Default contains limits for requests without tenant header and for tenants missing in Tenants.
+	//
+	// All these requests share the same limits, e.g. max_concurrent_queries and max_daily_samples are applied to all of them in total.
+	Default *Limits `yaml:"default,omitempty"`
+}
+
+// defaultTenantName is the name of the tenant for Config.Default limits.
+const defaultTenantName = "default"
+
+// Init must be called after flag.Parse and before using the quota package.
+func Init() {
+	// Register SIGHUP handler for config re-read just before loadConfig call.
+	// This guarantees that the config will be re-read if the signal arrives during loadConfig call.
+	sighupCh := procutil.NewSighupChan()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Fatalf("cannot load -search.quotaConfig: %s", err)
+	}
+	if len(*quotaConfig) == 0 {
+		return
+	}
+
+	configReloads = metrics.NewCounter(`vm_search_quota_config_reloads_total`)
+	configReloadErrors = metrics.NewCounter(`vm_search_quota_config_reloads_errors_total`)
+	configSuccess = metrics.NewGauge(`vm_search_quota_config_last_reload_successful`, nil)
+	configTimestamp = metrics.NewCounter(`vm_search_quota_config_last_reload_success_timestamp_seconds`)
+	metrics.RegisterMetricsWriter(writeTenantMetrics)
+
+	applyConfig(cfg)
+	configSuccess.Set(1)
+	configTimestamp.Set(fasttime.UnixTimestamp())
+
+	go func() {
+		for range sighupCh {
+			configReloads.Inc()
+			logger.Infof("received SIGHUP; reloading -search.quotaConfig=%q...", *quotaConfig)
+			cfg, err := loadConfig()
+			if err != nil {
+				configReloadErrors.Inc()
+				configSuccess.Set(0)
+				logger.Errorf("cannot load the updated -search.quotaConfig: %s; preserving the previous config", err)
+				continue
+			}
+			applyConfig(cfg)
+			configSuccess.Set(1)
+			configTimestamp.Set(fasttime.UnixTimestamp())
+			logger.Infof("successfully reloaded -search.quotaConfig=%q", *quotaConfig)
+		}
+	}()
+}
+
+var (
+	configReloads      *metrics.Counter
+	configReloadErrors *metrics.Counter
+	configSuccess      *metrics.Gauge
+	configTimestamp    *metrics.Counter
+)
+
+// CheckConfig checks config pointed by -search.quotaConfig
+func CheckConfig() error {
+	_, err := loadConfig()
+	return err
+}
+
+func loadConfig() (*Config, error) {
+	if len(*quotaConfig) == 0 {
+		return nil, nil
+	}
+	data, err := fscore.ReadFileOrHTTP(*quotaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read -search.quotaConfig=%q: %w", *quotaConfig, err)
+	}
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse -search.quotaConfig=%q: %w", *quotaConfig, err)
+	}
+	return cfg, nil
+}
+
+func parseConfig(data []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, err
+	}
+	tenants := make(map[string]*Limits, len(cfg.Tenants))
+	for name, limits := range cfg.Tenants {
+		if limits == nil {
+			return nil, fmt.Errorf("missing limits for tenant %q", name)
+		}
+		if err := limits.validate(); err != nil {
+			return nil, fmt.Errorf("invalid limits for tenant %q: %w", name, err)
+		}
+		normalizedName := normalizeTenantName(name)
+		if _, ok := tenants[normalizedName]; ok {
+			return nil, fmt.Errorf("duplicate limits for tenant %q", normalizedName)
+		}
+		tenants[normalizedName] = limits
+	}
+	if cfg.Default != nil {
+		if err := cfg.Default.validate(); err != nil {
+			return nil, fmt.Errorf("invalid limits at `default` section: %w", err)
+		}
+		if _, ok := tenants[defaultTenantName]; ok {
+			return nil, fmt.Errorf("tenant name %q cannot be used together with `default` section", defaultTenantName)
+		}
+	}
+	cfg.Tenants = tenants
+	return &cfg, nil
+}
+
+func (l *Limits) validate() error {
+	if l.MaxSeries < 0 {
+		return fmt.Errorf("max_series cannot be negative; got %d", l.MaxSeries)
+	}
+	if l.MaxSamplesPerQuery < 0 {
+		return fmt.Errorf("max_samples_per_query cannot be negative; got %d", l.MaxSamplesPerQuery)
+	}
+	if d := l.MaxQueryDuration.Duration(); d < 0 {
+		return fmt.Errorf("max_query_duration cannot be negative; got %s", d)
+	}
+	if l.MaxConcurrentQueries < 0 {
+		return fmt.Errorf("max_concurrent_queries cannot be negative; got %d", l.MaxConcurrentQueries)
+	}
+	if l.MaxDailySamples < 0 {
+		return fmt.Errorf("max_daily_samples cannot be negative; got %d", l.MaxDailySamples)
+	}
+	return nil
+}
+
+// normalizeTenantName returns the canonical name for tenant names in the form AccountID:ProjectID,
+// so 42 and 42:0 refer to the same tenant.
+func normalizeTenantName(name string) string {
+	name = strings.TrimSpace(name)
+	at, err := auth.NewToken(name)
+	if err != nil {
+		return name
+	}
+	return fmt.Sprintf("%d:%d", at.AccountID, at.ProjectID)
+}
+
+var (
+	tenantsLock   sync.Mutex
+	tenants       atomic.Pointer[map[string]*Tenant]
+	defaultTenant atomic.Pointer[Tenant]
+)
+
+// applyConfig applies cfg to tenants.
+//
+// Tenants, which are present in the previous config, preserve their stats.
+func applyConfig(cfg *Config) {
+	tenantsLock.Lock()
+	defer tenantsLock.Unlock()
+
+	var tmPrev map[string]*Tenant
+	if p := tenants.Load(); p != nil {
+		tmPrev = *p
+	}
+	tm := make(map[string]*Tenant, len(cfg.Tenants))
+	for name, limits := range cfg.Tenants {
+		t := tmPrev[name]
+		if t == nil {
+			t = &Tenant{
+				name: name,
+			}
+		}
+		t.setLimits(limits)
+		tm[name] = t
+	}
+	tenants.Store(&tm)
+
+	if cfg.Default == nil {
+		defaultTenant.Store(nil)
+		return
+	}
+	t := defaultTenant.Load()
+	if t == nil {
+		t = &Tenant{
+			name: defaultTenantName,
+		}
+	}
+	t.setLimits(cfg.Default)
+	defaultTenant.Store(t)
+}
+
+// GetTenant returns the tenant for the given r.
+//
+// The tenant for `default` section at -search.quotaConfig is returned if r has no tenant header
+// or if the tenant is missing in -search.quotaConfig.
+//
+// nil is returned if the tenant has no quotas in -search.quotaConfig.
+// All the Tenant methods can be called on nil Tenant.
+func GetTenant(r *http.Request) *Tenant {
+	p := tenants.Load()
+	if p == nil {
+		return nil
+	}
+	name := r.Header.Get(*tenantHeader)
+	if name == "" {
+		return defaultTenant.Load()
+	}
+	if t := (*p)[normalizeTenantName(name)]; t != nil {
+		return t
+	}
+	return defaultTenant.Load()
+}
+
+// Tenant tracks query quotas for a single tenant.
+type Tenant struct {
+	name string
+
+	limits        atomic.Pointer[Limits]
+	concurrencyCh atomic.Pointer[chan struct{}]
+
+	concurrentQueries atomic.Int64
+
+	queriesTotal             atomic.Uint64
+	concurrencyRejectedTotal atomic.Uint64
+	dailyRejectedTotal       atomic.Uint64
+	samplesScannedTotal      atomic.Uint64
+
+	// dailySamplesLock protects dailySamplesDay and dailySamples, so they are updated together on day rollover.
+	dailySamplesLock sync.Mutex
+
+	// dailySamplesDay is the UTC day in days since the Unix epoch, for which dailySamples is collected.
+	dailySamplesDay uint64
+	dailySamples    uint64
+}
+
+func (t *Tenant) setLimits(limits *Limits) {
+	t.limits.Store(limits)
+	n := limits.MaxConcurrentQueries
+	if chPtr := t.concurrencyCh.Load(); chPtr != nil && cap(*chPtr) == n {
+		return
+	}
+	if n <= 0 {
+		t.concurrencyCh.Store(nil)
+		return
+	}
+	ch := make(chan struct{}, n)
+	t.concurrencyCh.Store(&ch)
+}
+
+// Name returns the tenant name.
+func (t *Tenant) Name() string {
+	if t == nil {
+		return ""
+	}
+	return t.name
+}
+
+// MaxSeries returns the limit on the number of unique time series a single query can select given the global limit n.
+func (t *Tenant) MaxSeries(n int) int {
+	if t == nil {
+		return n
+	}
+	return minLimit(n, t.limits.Load().MaxSeries)
+}
+
+// MaxSamplesPerQuery returns the limit on the number of raw samples a single query can scan given the global limit n.
+func (t *Tenant) MaxSamplesPerQuery(n int) int {
+	if t == nil {
+		return n
+	}
+	return minLimit(n, t.limits.Load().MaxSamplesPerQuery)
+}
+
+// MaxQueryDuration returns the maximum query duration given the global limit d.
+func (t *Tenant) MaxQueryDuration(d time.Duration) time.Duration {
+	if t == nil {
+		return d
+	}
+	return time.Duration(minLimit(int(d), int(t.limits.Load().MaxQueryDuration.Duration())))
+}
+
+func minLimit(n, limit int) int {
+	if limit > 0 && (n <= 0 || limit < n) {
+		return limit
+	}
+	return n
+}
+
+// BeginQuery must be called before executing a query for t.
+//
+// It waits for up to maxWait until the number of concurrently executed queries for t drops below max_concurrent_queries.
+// The returned func must be called when the query is finished.
+func (t *Tenant) BeginQuery(r *http.Request, maxWait time.Duration) (func(), error) {
+	if t == nil {
+		return func() {}, nil
+	}
+	t.queriesTotal.Add(1)
+
+	limits := t.limits.Load()
+	if limits.MaxDailySamples > 0 {
+		if n := t.getDailySamples(currentDay()); n >= uint64(limits.MaxDailySamples) {
+			t.dailyRejectedTotal.Add(1)
+			return nil, &httpserver.ErrorWithStatusCode{
+				Err: fmt.Errorf("tenant %q has exceeded max_daily_samples=%d quota, since its queries have scanned %d samples today; "+
+					"the quota is reset at 00:00 UTC", t.name, limits.MaxDailySamples, n),
+				StatusCode: http.StatusTooManyRequests,
+			}
+		}
+	}
+
+	chPtr := t.concurrencyCh.Load()
+	if chPtr == nil {
+		t.concurrentQueries.Add(1)
+		return t.endQuery, nil
+	}
+	ch := *chPtr
+	release := func() {
+		<-ch
+		t.endQuery()
+	}
+	select {
+	case ch <- struct{}{}:
+		t.concurrentQueries.Add(1)
+		return release, nil
+	default:
+	}
+	tc := timerpool.Get(maxWait)
+	defer timerpool.Put(tc)
+	select {
+	case ch <- struct{}{}:
+		t.concurrentQueries.Add(1)
+		return release, nil
+	case <-r.Context().Done():
+		return nil, fmt.Errorf("client has canceled the request while waiting for max_concurrent_queries=%d quota for tenant %q", cap(ch), t.name)
+	case <-tc.C:
+		t.concurrencyRejectedTotal.Add(1)
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err: fmt.Errorf("couldn't start executing the request in %.3f seconds, since max_concurrent_queries=%d queries are executed for tenant %q. "+
+				"Possible solutions: to reduce query load for the tenant; to increase max_concurrent_queries for the tenant at -search.quotaConfig",
+				maxWait.Seconds(), cap(ch), t.name),
+			StatusCode: http.StatusTooManyRequests,
+		}
+	}
+}
+
+func (t *Tenant) endQuery() {
+	t.concurrentQueries.Add(-1)
+}
+
+// AddSamplesScanned registers n raw samples scanned by a query for t.
+func (t *Tenant) AddSamplesScanned(n int) {
+	if t == nil || n <= 0 {
+		return
+	}
+	t.samplesScannedTotal.Add(uint64(n))
+	t.addDailySamples(currentDay(), uint64(n))
+}
+
+func (t *Tenant) addDailySamples(day, n uint64) {
+	t.dailySamplesLock.Lock()
+	if t.dailySamplesDay != day {
+		t.dailySamplesDay = day
+		t.dailySamples = 0
+	}
+	t.dailySamples += n
+	t.dailySamplesLock.Unlock()
+}
+
+func (t *Tenant) getDailySamples(day uint64) uint64 {
+	t.dailySamplesLock.Lock()
+	defer t.dailySamplesLock.Unlock()
+
+	if t.dailySamplesDay != day {
+		return 0
+	}
+	return t.dailySamples
+}
+
+// currentDay returns the current UTC day in days since the Unix epoch.
+func currentDay() uint64 {
+	return fasttime.UnixTimestamp() / (24 * 3600)
+}
+
+func writeTenantMetrics(w io.Writer) {
+	p := tenants.Load()
+	if p == nil {
+		return
+	}
+	tm := *p
+	names := make([]string, 0, len(tm))
+	for name := range tm {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ts := make([]*Tenant, 0, len(names)+1)
+	for _, name := range names {
+		ts = append(ts, tm[name])
+	}
+	if t := defaultTenant.Load(); t != nil {
+		ts = append(ts, t)
+	}
+	for _, t := range ts {
+		name := t.name
+		limits := t.limits.Load()
+		label := fmt.Sprintf("tenant=%q", name)
+		metrics.WriteCounterUint64(w, fmt.Sprintf(`vm_tenant_queries_total{%s}`, label), t.queriesTotal.Load())
+		metrics.WriteCounterUint64(w, fmt.Sprintf(`vm_tenant_queries_rejected_total{%s,reason="max_concurrent_queries"}`, label), t.concurrencyRejectedTotal.Load())
+		metrics.WriteCounterUint64(w, fmt.Sprintf(`vm_tenant_queries_rejected_total{%s,reason="max_daily_samples"}`, label), t.dailyRejectedTotal.Load())
+		metrics.WriteGaugeUint64(w, fmt.Sprintf(`vm_tenant_concurrent_queries{%s}`, label), uint64(t.concurrentQueries.Load()))
+		metrics.WriteGaugeUint64(w, fmt.Sprintf(`vm_tenant_max_concurrent_queries{%s}`, label), uint64(limits.MaxConcurrentQueries))
+		metrics.WriteCounterUint64(w, fmt.Sprintf(`vm_tenant_samples_scanned_total{%s}`, label), t.samplesScannedTotal.Load())
+		metrics.WriteGaugeUint64(w, fmt.Sprintf(`vm_tenant_daily_samples_scanned{%s}`, label), t.getDailySamples(currentDay()))
+		metrics.WriteGaugeUint64(w, fmt.Sprintf(`vm_tenant_max_daily_samples{%s}`, label), uint64(limits.MaxDailySamples))
+	}
+}
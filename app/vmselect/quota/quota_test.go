@@ -0,0 +1,410 @@
+package quota
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestParseConfigSuccess(t *testing.T) {
+	f := func(data string, limitsExpected map[string]Limits) {
+		t.Helper()
+		cfg, err := parseConfig([]byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(cfg.Tenants) != len(limitsExpected) {
+			t.Fatalf("unexpected number of tenants; got %d; want %d", len(cfg.Tenants), len(limitsExpected))
+		}
+		for name, le := range limitsExpected {
+			l := cfg.Tenants[name]
+			if l == nil {
+				t.Fatalf("missing limits for tenant %q", name)
+			}
+			if l.MaxSeries != le.MaxSeries || l.MaxSamplesPerQuery != le.MaxSamplesPerQuery || l.MaxQueryDuration.Duration() != le.MaxQueryDuration.Duration() ||
+				l.MaxConcurrentQueries != le.MaxConcurrentQueries || l.MaxDailySamples != le.MaxDailySamples {
+				t.Fatalf("unexpected limits for tenant %q; got %+v; want %+v", name, l, le)
+			}
+		}
+	}
+
+	f(``, nil)
+	f(`
+tenants:
+  team-a:
+    max_series: 1000
+    max_samples_per_query: 100000
+    max_query_duration: 10s
+    max_concurrent_queries: 2
+    max_daily_samples: 1000000000
+  "42":
+    max_series: 5
+  "1:2":
+    max_concurrent_queries: 1
+`, map[string]Limits{
+		"team-a": {
+			MaxSeries:            1000,
+			MaxSamplesPerQuery:   100000,
+			MaxQueryDuration:     promutil.NewDuration(10 * time.Second),
+			MaxConcurrentQueries: 2,
+			MaxDailySamples:      1000000000,
+		},
+		"42:0": {
+			MaxSeries: 5,
+		},
+		"1:2": {
+			MaxConcurrentQueries: 1,
+		},
+	})
+}
+
+func TestParseConfigFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		if _, err := parseConfig([]byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// unknown field
+	f(`foo: bar`)
+	f(`
+tenants:
+  a:
+    max_foo: 1
+`)
+
+	// missing limits
+	f(`
+tenants:
+  a:
+`)
+
+	// negative limits
+	f(`
+tenants:
+  a:
+    max_series: -1
+`)
+	f(`
+tenants:
+  a:
+    max_concurrent_queries: -1
+`)
+	f(`
+tenants:
+  a:
+    max_daily_samples: -1
+`)
+
+	// duplicate tenants after normalization
+	f(`
+tenants:
+  "42":
+    max_series: 1
+  "42:0":
+    max_series: 2
+`)
+
+	// invalid default limits
+	f(`
+default:
+  max_series: -1
+`)
+
+	// tenant name clashes with default limits
+	f(`
+tenants:
+  default:
+    max_series: 1
+default:
+  max_series: 2
+`)
+}
+
+func TestNormalizeTenantName(t *testing.T) {
+	f := func(name, resultExpected string) {
+		t.Helper()
+		result := normalizeTenantName(name)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for normalizeTenantName(%q); got %q; want %q", name, result, resultExpected)
+		}
+	}
+	f("", "")
+	f("foo", "foo")
+	f("42", "42:0")
+	f(" 42:0 ", "42:0")
+	f("1:2", "1:2")
+	f("1:foo", "1:foo")
+}
+
+func TestTenantLimits(t *testing.T) {
+	var tNil *Tenant
+	if n := tNil.MaxSeries(100); n != 100 {
+		t.Fatalf("unexpected MaxSeries for nil tenant; got %d; want %d", n, 100)
+	}
+	if n := tNil.MaxSamplesPerQuery(0); n != 0 {
+		t.Fatalf("unexpected MaxSamplesPerQuery for nil tenant; got %d; want %d", n, 0)
+	}
+	if d := tNil.MaxQueryDuration(time.Second); d != time.Second {
+		t.Fatalf("unexpected MaxQueryDuration for nil tenant; got %s; want %s", d, time.Second)
+	}
+
+	tn := &Tenant{
+		name: "foo",
+	}
+	tn.setLimits(&Limits{
+		MaxSeries:          10,
+		MaxSamplesPerQuery: 1000,
+		MaxQueryDuration:   promutil.NewDuration(time.Minute),
+	})
+	if n := tn.MaxSeries(100); n != 10 {
+		t.Fatalf("unexpected MaxSeries; got %d; want %d", n, 10)
+	}
+	if n := tn.MaxSeries(5); n != 5 {
+		t.Fatalf("unexpected MaxSeries; got %d; want %d", n, 5)
+	}
+	if n := tn.MaxSamplesPerQuery(0); n != 1000 {
+		t.Fatalf("unexpected MaxSamplesPerQuery; got %d; want %d", n, 1000)
+	}
+	if d := tn.MaxQueryDuration(30 * time.Second); d != 30*time.Second {
+		t.Fatalf("unexpected MaxQueryDuration; got %s; want %s", d, 30*time.Second)
+	}
+	if d := tn.MaxQueryDuration(time.Hour); d != time.Minute {
+		t.Fatalf("unexpected MaxQueryDuration; got %s; want %s", d, time.Minute)
+	}
+}
+
+func TestTenantBeginQuery(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/api/v1/query", nil)
+	if err != nil {
+		t.Fatalf("cannot create request: %s", err)
+	}
+
+	tn := &Tenant{
+		name: "foo",
+	}
+	tn.setLimits(&Limits{
+		MaxConcurrentQueries: 1,
+		MaxDailySamples:      100,
+	})
+
+	endQuery, err := tn.BeginQuery(r, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := tn.BeginQuery(r, 10*time.Millisecond); err == nil {
+		t.Fatalf("expecting non-nil error when max_concurrent_queries is reached")
+	}
+	if n := tn.concurrencyRejectedTotal.Load(); n != 1 {
+		t.Fatalf("unexpected number of queries rejected because of concurrency; got %d; want %d", n, 1)
+	}
+	endQuery()
+	if n := tn.concurrentQueries.Load(); n != 0 {
+		t.Fatalf("unexpected number of concurrent queries; got %d; want %d", n, 0)
+	}
+
+	// The daily budget isn't exhausted yet.
+	tn.AddSamplesScanned(99)
+	endQuery, err = tn.BeginQuery(r, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tn.AddSamplesScanned(10)
+	endQuery()
+
+	// The daily budget is exhausted.
+	if _, err := tn.BeginQuery(r, time.Second); err == nil {
+		t.Fatalf("expecting non-nil error when max_daily_samples is reached")
+	}
+	if n := tn.dailyRejectedTotal.Load(); n != 1 {
+		t.Fatalf("unexpected number of queries rejected because of daily samples; got %d; want %d", n, 1)
+	}
+	if n := tn.samplesScannedTotal.Load(); n != 109 {
+		t.Fatalf("unexpected number of scanned samples; got %d; want %d", n, 109)
+	}
+
+	// The budget is reset on the next day.
+	tn.dailySamplesLock.Lock()
+	tn.dailySamplesDay--
+	tn.dailySamplesLock.Unlock()
+	endQuery, err = tn.BeginQuery(r, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	endQuery()
+	if n := tn.queriesTotal.Load(); n != 5 {
+		t.Fatalf("unexpected number of queries; got %d; want %d", n, 5)
+	}
+}
+
+func TestTenantAddDailySamplesConcurrent(t *testing.T) {
+	tn := &Tenant{
+		name: "foo",
+	}
+	tn.addDailySamples(1, 1000)
+
+	// Concurrent additions on the next day must reset the samples from the previous day exactly once
+	// without losing the samples added on the next day.
+	const workers = 8
+	const addsPerWorker = 1000
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < addsPerWorker; j++ {
+				tn.addDailySamples(2, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := tn.getDailySamples(1); n != 0 {
+		t.Fatalf("unexpected number of samples for the previous day; got %d; want %d", n, 0)
+	}
+	if n := tn.getDailySamples(2); n != workers*addsPerWorker {
+		t.Fatalf("unexpected number of samples for the current day; got %d; want %d", n, workers*addsPerWorker)
+	}
+}
+
+func TestApplyConfigPreservesStats(t *testing.T) {
+	defer tenants.Store(nil)
+	defer defaultTenant.Store(nil)
+
+	cfg, err := parseConfig([]byte(`
+tenants:
+  foo:
+    max_concurrent_queries: 1
+  bar:
+    max_series: 10
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	applyConfig(cfg)
+
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/api/v1/query", nil)
+	if err != nil {
+		t.Fatalf("cannot create request: %s", err)
+	}
+	if tn := GetTenant(r); tn != nil {
+		t.Fatalf("expecting nil tenant for request without %s header; got %q", *tenantHeader, tn.Name())
+	}
+	r.Header.Set(*tenantHeader, "foo")
+	tn := GetTenant(r)
+	if tn.Name() != "foo" {
+		t.Fatalf("unexpected tenant; got %q; want %q", tn.Name(), "foo")
+	}
+	tn.AddSamplesScanned(123)
+
+	cfg, err = parseConfig([]byte(`
+tenants:
+  foo:
+    max_concurrent_queries: 2
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	applyConfig(cfg)
+	tnNew := GetTenant(r)
+	if tnNew != tn {
+		t.Fatalf("expecting the same tenant after config reload")
+	}
+	if n := tnNew.samplesScannedTotal.Load(); n != 123 {
+		t.Fatalf("unexpected number of scanned samples after config reload; got %d; want %d", n, 123)
+	}
+	if n := cap(*tnNew.concurrencyCh.Load()); n != 2 {
+		t.Fatalf("unexpected max_concurrent_queries after config reload; got %d; want %d", n, 2)
+	}
+	r.Header.Set(*tenantHeader, "bar")
+	if tn := GetTenant(r); tn != nil {
+		t.Fatalf("expecting nil tenant for the removed tenant; got %q", tn.Name())
+	}
+}
+
+func TestGetTenantDefault(t *testing.T) {
+	defer tenants.Store(nil)
+	defer defaultTenant.Store(nil)
+
+	cfg, err := parseConfig([]byte(`
+tenants:
+  foo:
+    max_series: 10
+default:
+  max_series: 5
+  max_daily_samples: 100
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	applyConfig(cfg)
+
+	f := func(tenantName, nameExpected string, maxSeriesExpected int) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodGet, "http://localhost/api/v1/query", nil)
+		if err != nil {
+			t.Fatalf("cannot create request: %s", err)
+		}
+		if tenantName != "" {
+			r.Header.Set(*tenantHeader, tenantName)
+		}
+		tn := GetTenant(r)
+		if tn.Name() != nameExpected {
+			t.Fatalf("unexpected tenant for %q; got %q; want %q", tenantName, tn.Name(), nameExpected)
+		}
+		if n := tn.MaxSeries(0); n != maxSeriesExpected {
+			t.Fatalf("unexpected MaxSeries for %q; got %d; want %d", tenantName, n, maxSeriesExpected)
+		}
+	}
+
+	// known tenant
+	f("foo", "foo", 10)
+
+	// missing tenant header
+	f("", defaultTenantName, 5)
+
+	// unknown tenant
+	f("bar", defaultTenantName, 5)
+	f("42", defaultTenantName, 5)
+
+	// missing and unknown tenants share the daily budget
+	r, err := http.NewRequest(http.MethodGet, "http://localhost/api/v1/query", nil)
+	if err != nil {
+		t.Fatalf("cannot create request: %s", err)
+	}
+	r.Header.Set(*tenantHeader, "bar")
+	GetTenant(r).AddSamplesScanned(100)
+	r.Header.Del(*tenantHeader)
+	if _, err := GetTenant(r).BeginQuery(r, time.Second); err == nil {
+		t.Fatalf("expecting non-nil error when max_daily_samples is reached for default limits")
+	}
+
+	// the stats for default limits are preserved across config reloads
+	tn := GetTenant(r)
+	applyConfig(cfg)
+	if tnNew := GetTenant(r); tnNew != tn {
+		t.Fatalf("expecting the same default tenant after config reload")
+	}
+
+	// default limits are removed from the config
+	cfg, err = parseConfig([]byte(`
+tenants:
+  foo:
+    max_series: 10
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	applyConfig(cfg)
+	if tn := GetTenant(r); tn != nil {
+		t.Fatalf("expecting nil tenant for request without %s header; got %q", *tenantHeader, tn.Name())
+	}
+	r.Header.Set(*tenantHeader, "bar")
+	if tn := GetTenant(r); tn != nil {
+		t.Fatalf("expecting nil tenant for unknown tenant; got %q", tn.Name())
+	}
+}
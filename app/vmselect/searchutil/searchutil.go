@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/quota"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
@@ -29,14 +30,21 @@ func GetMaxQueryDuration(r *http.Request) time.Duration {
 		dms = 0
 	}
 	d := time.Duration(dms) * time.Millisecond
-	if d <= 0 || d > *maxQueryDuration {
-		d = *maxQueryDuration
+	dMax := quota.GetTenant(r).MaxQueryDuration(*maxQueryDuration)
+	if d <= 0 || d > dMax {
+		d = dMax
 	}
 	return d
 }
 
 // GetDeadlineForQuery returns deadline for the given query r.
+//
+// The deadline takes into account max_query_duration quota for the tenant from r.
 func GetDeadlineForQuery(r *http.Request, startTime time.Time) Deadline {
+	tenant := quota.GetTenant(r)
+	if d := tenant.MaxQueryDuration(*maxQueryDuration); d != *maxQueryDuration {
+		return getDeadlineWithMaxDuration(r, startTime, d.Milliseconds(), "-search.quotaConfig")
+	}
 	dMax := maxQueryDuration.Milliseconds()
 	return getDeadlineWithMaxDuration(r, startTime, dMax, "-search.maxQueryDuration")
 }
@@ -75,43 +75,40 @@ func StreamMetricNamesStatsResponse(qw422016 *qt422016.Writer, stats *storage.Me
 	qt.Done()
 
 //line app/vmselect/stats/metric_names_usage_response.qtpl:27
-	traceJSON := qt.ToJSON()
-
-//line app/vmselect/stats/metric_names_usage_response.qtpl:28
-	if traceJSON != "" {
-//line app/vmselect/stats/metric_names_usage_response.qtpl:28
+	if qt.Exposed() {
+//line app/vmselect/stats/metric_names_usage_response.qtpl:27
 		qw422016.N().S(`,"trace":`)
 //line app/vmselect/stats/metric_names_usage_response.qtpl:28
-		qw422016.N().S(traceJSON)
-//line app/vmselect/stats/metric_names_usage_response.qtpl:28
+		qw422016.N().S(qt.ToJSON())
+//line app/vmselect/stats/metric_names_usage_response.qtpl:29
 	}
-//line app/vmselect/stats/metric_names_usage_response.qtpl:28
+//line app/vmselect/stats/metric_names_usage_response.qtpl:29
 	qw422016.N().S(`}`)
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 }
 
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 func WriteMetricNamesStatsResponse(qq422016 qtio422016.Writer, stats *storage.MetricNamesStatsResponse, qt *querytracer.Tracer) {
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 	StreamMetricNamesStatsResponse(qw422016, stats, qt)
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 }
 
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 func MetricNamesStatsResponse(stats *storage.MetricNamesStatsResponse, qt *querytracer.Tracer) string {
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 	WriteMetricNamesStatsResponse(qb422016, stats, qt)
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 	qs422016 := string(qb422016.B)
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 	return qs422016
-//line app/vmselect/stats/metric_names_usage_response.qtpl:31
+//line app/vmselect/stats/metric_names_usage_response.qtpl:32
 }
@@ -0,0 +1,113 @@
+package tracestore
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// span is a single span of the recorded trace.
+//
+// It mirrors the JSON representation of querytracer.Tracer.
+type span struct {
+	DurationMsec float64 `json:"duration_msec"`
+	Message      string  `json:"message"`
+	Children     []*span `json:"children,omitempty"`
+}
+
+// spanDiff is the result of comparing spans from two traces.
+//
+// Either MessageA or MessageB is empty if the span is missing in the corresponding trace.
+type spanDiff struct {
+	MessageA      string      `json:"message_a,omitempty"`
+	MessageB      string      `json:"message_b,omitempty"`
+	DurationMsecA float64     `json:"duration_msec_a"`
+	DurationMsecB float64     `json:"duration_msec_b"`
+	DeltaMsec     float64     `json:"delta_msec"`
+	Children      []*spanDiff `json:"children,omitempty"`
+}
+
+// compareSpans returns the difference between a and b.
+//
+// Children spans are matched by their messages with numbers stripped,
+// since the same query usually produces spans with distinct numbers of series, samples, etc.
+func compareSpans(a, b *span) *spanDiff {
+	sd := &spanDiff{}
+	if a != nil {
+		sd.MessageA = a.Message
+		sd.DurationMsecA = a.DurationMsec
+	}
+	if b != nil {
+		sd.MessageB = b.Message
+		sd.DurationMsecB = b.DurationMsec
+	}
+	sd.DeltaMsec = sd.DurationMsecB - sd.DurationMsecA
+
+	var childrenA, childrenB []*span
+	if a != nil {
+		childrenA = a.Children
+	}
+	if b != nil {
+		childrenB = b.Children
+	}
+	j := 0
+	for _, childA := range childrenA {
+		k := findMatchingSpan(childrenB[j:], childA)
+		if k < 0 {
+			sd.Children = append(sd.Children, compareSpans(childA, nil))
+			continue
+		}
+		for _, childB := range childrenB[j : j+k] {
+			sd.Children = append(sd.Children, compareSpans(nil, childB))
+		}
+		sd.Children = append(sd.Children, compareSpans(childA, childrenB[j+k]))
+		j += k + 1
+	}
+	for _, childB := range childrenB[j:] {
+		sd.Children = append(sd.Children, compareSpans(nil, childB))
+	}
+	return sd
+}
+
+// maxSpanLookahead limits the number of spans to look through when searching for the matching span.
+//
+// This prevents from quadratic complexity when comparing traces with big number of distinct spans.
+const maxSpanLookahead = 64
+
+func findMatchingSpan(spans []*span, s *span) int {
+	key := spanKey(s.Message)
+	for i, sb := range spans {
+		if i >= maxSpanLookahead {
+			break
+		}
+		if spanKey(sb.Message) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func spanKey(message string) string {
+	return numberRe.ReplaceAllString(message, "N")
+}
+
+var numberRe = regexp.MustCompile(`[0-9]+(\.[0-9]+)?`)
+
+func (sd *spanDiff) writePlaintextWithIndent(w io.Writer, indent int) {
+	prefix := strings.Repeat("| ", indent) + "- "
+	msg := sd.MessageB
+	switch {
+	case sd.MessageA == "":
+		prefix += "[only in b] "
+	case sd.MessageB == "":
+		prefix += "[only in a] "
+		msg = sd.MessageA
+	case sd.MessageA != sd.MessageB:
+		msg = sd.MessageA + " => " + sd.MessageB
+	}
+	fmt.Fprintf(w, "%s%.03fms => %.03fms (%+.03fms): %s\n", prefix, sd.DurationMsecA, sd.DurationMsecB, sd.DeltaMsec, msg)
+	for _, child := range sd.Children {
+		child.writePlaintextWithIndent(w, indent+1)
+	}
+}
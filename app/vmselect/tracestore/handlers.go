@@ -0,0 +1,119 @@
+package tracestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputil"
+)
+
+// TracesHandler processes /api/v1/status/traces request.
+//
+// It returns the list of the most recently recorded traces. The trace with the given `id` query arg is returned if it is set.
+func TracesHandler(w http.ResponseWriter, r *http.Request) error {
+	ts := tsGlobal
+	if ts == nil {
+		return errDisabled
+	}
+	if id := r.FormValue("id"); id != "" {
+		rec, err := getRecord(ts, id)
+		if err != nil {
+			return err
+		}
+		return writeJSONResponse(w, rec)
+	}
+	limit, err := httputil.GetInt(r, "limit")
+	if err != nil {
+		return err
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	return writeJSONResponse(w, ts.list(limit))
+}
+
+// CompareTracesHandler processes /api/v1/status/traces/compare request.
+//
+// It compares the traces with ids from the `a` and `b` query args. The comparison is returned in plain text if `format=text` query arg is set.
+func CompareTracesHandler(w http.ResponseWriter, r *http.Request) error {
+	ts := tsGlobal
+	if ts == nil {
+		return errDisabled
+	}
+	recA, err := getRecord(ts, r.FormValue("a"))
+	if err != nil {
+		return fmt.Errorf("cannot obtain the trace from `a` arg: %w", err)
+	}
+	recB, err := getRecord(ts, r.FormValue("b"))
+	if err != nil {
+		return fmt.Errorf("cannot obtain the trace from `b` arg: %w", err)
+	}
+	var a, b span
+	if err := json.Unmarshal(recA.Trace, &a); err != nil {
+		return fmt.Errorf("cannot parse trace with id=%q: %w", recA.ID, err)
+	}
+	if err := json.Unmarshal(recB.Trace, &b); err != nil {
+		return fmt.Errorf("cannot parse trace with id=%q: %w", recB.ID, err)
+	}
+	sd := compareSpans(&a, &b)
+	if r.FormValue("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "a: id=%s, time=%s, duration=%.3fs, requestURI=%q\n", recA.ID, recA.Time.UTC().Format(timeFormat), recA.Duration, recA.RequestURI)
+		fmt.Fprintf(w, "b: id=%s, time=%s, duration=%.3fs, requestURI=%q\n", recB.ID, recB.Time.UTC().Format(timeFormat), recB.Duration, recB.RequestURI)
+		sd.writePlaintextWithIndent(w, 0)
+		return nil
+	}
+	recA.Trace = nil
+	recB.Trace = nil
+	return writeJSONResponse(w, map[string]any{
+		"a":    recA,
+		"b":    recB,
+		"diff": sd,
+	})
+}
+
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+var errDisabled = &httpserver.ErrorWithStatusCode{
+	Err:        fmt.Errorf("query trace recording is disabled; enable it via -search.traceRecording.minDuration command-line flag"),
+	StatusCode: http.StatusBadRequest,
+}
+
+func getRecord(ts *traceStore, id string) (*Record, error) {
+	if !isValidID(id) {
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("invalid trace id=%q; it must contain 16 uppercase hex chars", id),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	rec, err := ts.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("cannot find trace with id=%q; it may be already deleted because of -search.traceRecording.maxTraces or -search.traceRecording.maxDiskSize limits", id),
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	return rec, nil
+}
+
+func writeJSONResponse(w http.ResponseWriter, data any) error {
+	resp := struct {
+		Status string `json:"status"`
+		Data   any    `json:"data"`
+	}{
+		Status: "success",
+		Data:   data,
+	}
+	b, err := json.Marshal(&resp)
+	if err != nil {
+		return fmt.Errorf("BUG: cannot marshal response: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	return err
+}
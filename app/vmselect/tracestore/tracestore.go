@@ -0,0 +1,374 @@
+package tracestore
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/quota"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	minDuration = flag.Duration("search.traceRecording.minDuration", 0, "The minimum duration for queries, which traces must be recorded automatically. "+
+		"Recorded traces are available at /api/v1/status/traces . Zero value disables trace recording. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#query-trace-recording")
+	sampleRate = flag.Float64("search.traceRecording.sampleRate", 1, "The share of queries in the range (0..1], which are traced when -search.traceRecording.minDuration is set. "+
+		"Lower values reduce the overhead of query tracing at the cost of missing traces for some slow queries")
+	maxTraces   = flag.Int("search.traceRecording.maxTraces", 1000, "The maximum number of recorded query traces to keep on disk. Older traces are deleted")
+	maxDiskSize = flagutil.NewBytes("search.traceRecording.maxDiskSize", 256*1024*1024, "The maximum disk space, which can be occupied by recorded query traces. Older traces are deleted")
+)
+
+// Record is a recorded query trace.
+type Record struct {
+	// ID is a unique id of the recorded trace.
+	//
+	// It is a fixed-width hex string, so ids are sorted in the order of trace recording.
+	ID string `json:"id"`
+
+	// Time is the time when the query has been started.
+	Time time.Time `json:"time"`
+
+	// Path is the request path.
+	Path string `json:"path"`
+
+	// RequestURI is the request uri including query args.
+	RequestURI string `json:"request_uri"`
+
+	// Duration is the query duration in seconds.
+	Duration float64 `json:"duration_seconds"`
+
+	// RemoteAddr is the remote address of the client.
+	RemoteAddr string `json:"remote_addr"`
+
+	// Tenant is the tenant from -search.quotaTenantHeader header.
+	Tenant string `json:"tenant,omitempty"`
+
+	// Trace is the query trace in JSON.
+	Trace json.RawMessage `json:"trace,omitempty"`
+}
+
+// Enabled returns true if trace recording is enabled via -search.traceRecording.minDuration.
+func Enabled() bool {
+	return *minDuration > 0
+}
+
+// Init initializes trace recording at the given path.
+//
+// Previously recorded traces are loaded from the path.
+func Init(path string) {
+	if !Enabled() {
+		return
+	}
+	if *sampleRate <= 0 || *sampleRate > 1 {
+		logger.Fatalf("-search.traceRecording.sampleRate must be in the range (0..1]; got %v", *sampleRate)
+	}
+	ts := mustOpenTraceStore(path, *maxTraces, int64(maxDiskSize.N))
+	tsGlobal = ts
+	logger.Infof("loaded %d recorded query traces from %q", len(ts.records), path)
+}
+
+var tsGlobal *traceStore
+
+// ShouldSample returns true if the query without explicitly requested tracing must be traced for possible recording.
+func ShouldSample() bool {
+	if !Enabled() {
+		return false
+	}
+	return *sampleRate >= 1 || rand.Float64() < *sampleRate
+}
+
+// RecordIfSlow records the trace qt for the request r started at startTime if the request duration exceeds -search.traceRecording.minDuration.
+//
+// RecordIfSlow must be called after the request is processed.
+func RecordIfSlow(qt *querytracer.Tracer, r *http.Request, startTime time.Time) {
+	ts := tsGlobal
+	if ts == nil || !qt.Enabled() {
+		return
+	}
+	d := time.Since(startTime)
+	if d < *minDuration {
+		return
+	}
+	if !qt.IsDone() {
+		// The request handler didn't finish the trace, for example, because of the error.
+		qt.Donef("the request has been finished in %.3f seconds", d.Seconds())
+	}
+	rec := &Record{
+		Time:       startTime,
+		Path:       r.URL.Path,
+		RequestURI: httpserver.GetRequestURI(r),
+		Duration:   d.Seconds(),
+		RemoteAddr: r.RemoteAddr,
+		Tenant:     quota.GetTenant(r).Name(),
+		Trace:      json.RawMessage(qt.ToJSON()),
+	}
+	ts.add(rec)
+}
+
+var (
+	tracesRecordedTotal    = metrics.NewCounter(`vm_query_traces_recorded_total`)
+	traceRecordErrorsTotal = metrics.NewCounter(`vm_query_trace_record_errors_total`)
+
+	_ = metrics.NewGauge(`vm_query_traces_stored`, func() float64 {
+		ts := tsGlobal
+		if ts == nil {
+			return 0
+		}
+		n, _ := ts.stats()
+		return float64(n)
+	})
+	_ = metrics.NewGauge(`vm_query_traces_stored_bytes`, func() float64 {
+		ts := tsGlobal
+		if ts == nil {
+			return 0
+		}
+		_, size := ts.stats()
+		return float64(size)
+	})
+)
+
+// traceStore is a bounded on-disk ring of recorded traces.
+//
+// Every trace is stored in a separate file named by its id.
+type traceStore struct {
+	path     string
+	maxCount int
+	maxSize  int64
+
+	mu sync.Mutex
+
+	// records contains recorded traces without Trace field sorted by ID.
+	records []*Record
+
+	// sizes contains file sizes for records.
+	sizes []int64
+
+	totalSize int64
+	nextID    uint64
+}
+
+func mustOpenTraceStore(path string, maxCount int, maxSize int64) *traceStore {
+	fs.MustMkdirIfNotExist(path)
+	ts := &traceStore{
+		path:     path,
+		maxCount: maxCount,
+		maxSize:  maxSize,
+		nextID:   uint64(time.Now().UnixNano()),
+	}
+	des := fs.MustReadDir(path)
+	for _, de := range des {
+		fn := de.Name()
+		filePath := filepath.Join(path, fn)
+		if fs.IsTemporaryFileName(fn) {
+			// Remove the temporary file left after unclean shutdown.
+			mustRemoveFile(filePath)
+			continue
+		}
+		id, ok := strings.CutSuffix(fn, ".json")
+		if !ok || !isValidID(id) {
+			continue
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			logger.Panicf("FATAL: cannot read recorded query trace: %s", err)
+		}
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err != nil || rec.ID != id {
+			logger.Errorf("removing broken recorded query trace %q", filePath)
+			mustRemoveFile(filePath)
+			continue
+		}
+		rec.Trace = nil
+		ts.records = append(ts.records, &rec)
+		ts.sizes = append(ts.sizes, int64(len(data)))
+		ts.totalSize += int64(len(data))
+		if n, _ := strconv.ParseUint(id, 16, 64); n >= ts.nextID {
+			ts.nextID = n + 1
+		}
+	}
+	sort.Sort(recordsByID{ts})
+
+	ts.mu.Lock()
+	ts.removeOldRecordsLocked()
+	ts.mu.Unlock()
+
+	return ts
+}
+
+type recordsByID struct {
+	ts *traceStore
+}
+
+func (x recordsByID) Len() int           { return len(x.ts.records) }
+func (x recordsByID) Less(i, j int) bool { return x.ts.records[i].ID < x.ts.records[j].ID }
+func (x recordsByID) Swap(i, j int) {
+	ts := x.ts
+	ts.records[i], ts.records[j] = ts.records[j], ts.records[i]
+	ts.sizes[i], ts.sizes[j] = ts.sizes[j], ts.sizes[i]
+}
+
+// add records rec to ts.
+//
+// The trace is skipped if it cannot be written to disk, for example, because of out of disk space,
+// since recording failures mustn't affect query processing.
+func (ts *traceStore) add(rec *Record) {
+	ts.mu.Lock()
+	rec.ID = fmt.Sprintf("%016X", ts.nextID)
+	ts.nextID++
+	ts.mu.Unlock()
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal recorded query trace: %s", err)
+	}
+
+	// Write the file without holding ts.mu, since this may take a while on slow disks.
+	if err := writeFileAtomic(ts.filePath(rec.ID), data); err != nil {
+		traceRecordErrorsTotal.Inc()
+		logger.Errorf("skipping recorded query trace for %q: %s", rec.RequestURI, err)
+		return
+	}
+	tracesRecordedTotal.Inc()
+
+	recCopy := *rec
+	recCopy.Trace = nil
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	// Concurrently recorded traces may be written in arbitrary order, so keep records sorted by ID.
+	n := sort.Search(len(ts.records), func(i int) bool {
+		return ts.records[i].ID > recCopy.ID
+	})
+	ts.records = slices.Insert(ts.records, n, &recCopy)
+	ts.sizes = slices.Insert(ts.sizes, n, int64(len(data)))
+	ts.totalSize += int64(len(data))
+	ts.removeOldRecordsLocked()
+}
+
+// writeFileAtomic atomically writes data to the file at path.
+//
+// Unlike fs.MustWriteAtomic, it returns an error instead of panicking.
+// The temporary file is removed on errors. It is removed by mustOpenTraceStore if it is left after unclean shutdown.
+func writeFileAtomic(path string, data []byte) error {
+	// The path is unique, since it contains unique trace id, so there is no need in unique suffix for the temporary file.
+	tmpPath := path + ".tmp.0"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot create %q: %w", tmpPath, err)
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("cannot write %d bytes to %q: %w", len(data), tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("cannot move %q to %q: %w", tmpPath, path, err)
+	}
+	return nil
+}
+
+func (ts *traceStore) removeOldRecordsLocked() {
+	n := 0
+	totalSize := ts.totalSize
+	for n < len(ts.records) && (len(ts.records)-n > ts.maxCount || totalSize > ts.maxSize) {
+		mustRemoveFile(ts.filePath(ts.records[n].ID))
+		totalSize -= ts.sizes[n]
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	ts.records = append(ts.records[:0], ts.records[n:]...)
+	ts.sizes = append(ts.sizes[:0], ts.sizes[n:]...)
+	ts.totalSize = totalSize
+}
+
+func (ts *traceStore) stats() (int, int64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	return len(ts.records), ts.totalSize
+}
+
+// list returns up to limit the most recently recorded traces without Trace field.
+func (ts *traceStore) list(limit int) []*Record {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	result := []*Record{}
+	for i := len(ts.records) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, ts.records[i])
+	}
+	return result
+}
+
+// get returns the recorded trace with the given id.
+//
+// nil is returned if there is no trace with the given id.
+func (ts *traceStore) get(id string) (*Record, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	n := sort.Search(len(ts.records), func(i int) bool {
+		return ts.records[i].ID >= id
+	})
+	if n >= len(ts.records) || ts.records[n].ID != id {
+		return nil, nil
+	}
+	filePath := ts.filePath(id)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read recorded query trace: %w", err)
+	}
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("cannot parse recorded query trace %q: %w", filePath, err)
+	}
+	return &rec, nil
+}
+
+func (ts *traceStore) filePath(id string) string {
+	return filepath.Join(ts.path, id+".json")
+}
+
+func isValidID(id string) bool {
+	if len(id) != 16 {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func mustRemoveFile(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logger.Panicf("FATAL: cannot remove %q: %s", path, err)
+	}
+}
@@ -0,0 +1,169 @@
+package tracestore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTraceStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queryTraces")
+
+	ts := mustOpenTraceStore(path, 3, 1024*1024)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		rec := &Record{
+			Time:     time.Unix(int64(i), 0).UTC(),
+			Path:     "/api/v1/query",
+			Duration: float64(i),
+			Trace:    json.RawMessage(`{"duration_msec":1,"message":"foo"}`),
+		}
+		ts.add(rec)
+		ids = append(ids, rec.ID)
+	}
+
+	// Only the last 3 traces must be left.
+	f := func(ts *traceStore) {
+		t.Helper()
+		records := ts.list(10)
+		if len(records) != 3 {
+			t.Fatalf("unexpected number of records; got %d; want %d", len(records), 3)
+		}
+		for i, rec := range records {
+			idExpected := ids[len(ids)-1-i]
+			if rec.ID != idExpected {
+				t.Fatalf("unexpected id for record #%d; got %q; want %q", i, rec.ID, idExpected)
+			}
+			if rec.Trace != nil {
+				t.Fatalf("the list mustn't contain traces")
+			}
+		}
+		if records := ts.list(1); len(records) != 1 || records[0].ID != ids[4] {
+			t.Fatalf("unexpected list with limit=1: %+v", records)
+		}
+
+		rec, err := ts.get(ids[2])
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if rec == nil || rec.Duration != 2 || string(rec.Trace) != `{"duration_msec":1,"message":"foo"}` {
+			t.Fatalf("unexpected record: %+v", rec)
+		}
+		rec, err = ts.get(ids[1])
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if rec != nil {
+			t.Fatalf("expecting nil record for deleted trace; got %+v", rec)
+		}
+		des, err := os.ReadDir(path)
+		if err != nil {
+			t.Fatalf("cannot read dir: %s", err)
+		}
+		if len(des) != 3 {
+			t.Fatalf("unexpected number of files; got %d; want %d", len(des), 3)
+		}
+	}
+	f(ts)
+
+	// Re-open the store and verify that the traces are loaded.
+	ts = mustOpenTraceStore(path, 3, 1024*1024)
+	f(ts)
+
+	// New traces must have bigger ids after re-opening.
+	rec := &Record{
+		Trace: json.RawMessage(`{"duration_msec":1,"message":"bar"}`),
+	}
+	ts.add(rec)
+	if rec.ID <= ids[4] {
+		t.Fatalf("the id for the new trace must exceed %q; got %q", ids[4], rec.ID)
+	}
+
+	// Re-open the store with smaller disk size limit.
+	_, size := ts.stats()
+	ts = mustOpenTraceStore(path, 3, size/2)
+	if n, _ := ts.stats(); n != 1 {
+		t.Fatalf("unexpected number of traces after reducing disk size limit; got %d; want %d", n, 1)
+	}
+}
+
+func TestTraceStoreWriteFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queryTraces")
+
+	ts := mustOpenTraceStore(path, 3, 1024*1024)
+	newRecord := func() *Record {
+		return &Record{
+			Trace: json.RawMessage(`{"duration_msec":1,"message":"foo"}`),
+		}
+	}
+	ts.add(newRecord())
+
+	// Remove the directory with traces, so new traces cannot be written.
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatalf("cannot remove %q: %s", path, err)
+	}
+	errorsBefore := traceRecordErrorsTotal.Get()
+	ts.add(newRecord())
+	if n := traceRecordErrorsTotal.Get() - errorsBefore; n != 1 {
+		t.Fatalf("unexpected number of trace record errors; got %d; want 1", n)
+	}
+	if n, _ := ts.stats(); n != 1 {
+		t.Fatalf("the trace, which couldn't be written, mustn't be registered; got %d traces; want 1", n)
+	}
+
+	// Traces must be recorded again after the directory is restored.
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("cannot create %q: %s", path, err)
+	}
+	rec := newRecord()
+	ts.add(rec)
+	if n, _ := ts.stats(); n != 2 {
+		t.Fatalf("unexpected number of traces; got %d; want 2", n)
+	}
+	if rec, err := ts.get(rec.ID); err != nil || rec == nil {
+		t.Fatalf("cannot obtain the recorded trace; err=%v", err)
+	}
+}
+
+func TestCompareSpans(t *testing.T) {
+	f := func(a, b, resultExpected string) {
+		t.Helper()
+		var sa, sb span
+		if err := json.Unmarshal([]byte(a), &sa); err != nil {
+			t.Fatalf("cannot parse a: %s", err)
+		}
+		if err := json.Unmarshal([]byte(b), &sb); err != nil {
+			t.Fatalf("cannot parse b: %s", err)
+		}
+		sd := compareSpans(&sa, &sb)
+		data, err := json.Marshal(sd)
+		if err != nil {
+			t.Fatalf("cannot marshal diff: %s", err)
+		}
+		if string(data) != resultExpected {
+			t.Fatalf("unexpected diff\ngot\n%s\nwant\n%s", data, resultExpected)
+		}
+	}
+
+	// identical traces
+	f(`{"duration_msec":10,"message":"foo"}`, `{"duration_msec":10,"message":"foo"}`,
+		`{"message_a":"foo","message_b":"foo","duration_msec_a":10,"duration_msec_b":10,"delta_msec":0}`)
+
+	// spans with distinct numbers are matched
+	f(`{"duration_msec":10,"message":"root","children":[{"duration_msec":4,"message":"series=5"},{"duration_msec":6,"message":"done"}]}`,
+		`{"duration_msec":25,"message":"root","children":[{"duration_msec":20,"message":"series=50"},{"duration_msec":5,"message":"done"}]}`,
+		`{"message_a":"root","message_b":"root","duration_msec_a":10,"duration_msec_b":25,"delta_msec":15,"children":[`+
+			`{"message_a":"series=5","message_b":"series=50","duration_msec_a":4,"duration_msec_b":20,"delta_msec":16},`+
+			`{"message_a":"done","message_b":"done","duration_msec_a":6,"duration_msec_b":5,"delta_msec":-1}]}`)
+
+	// spans missing in one of traces
+	f(`{"duration_msec":10,"message":"root","children":[{"duration_msec":4,"message":"cache miss"},{"duration_msec":6,"message":"done"}]}`,
+		`{"duration_msec":5,"message":"root","children":[{"duration_msec":1,"message":"cache hit"},{"duration_msec":4,"message":"done"},{"duration_msec":0,"message":"extra"}]}`,
+		`{"message_a":"root","message_b":"root","duration_msec_a":10,"duration_msec_b":5,"delta_msec":-5,"children":[`+
+			`{"message_a":"cache miss","duration_msec_a":4,"duration_msec_b":0,"delta_msec":-4},`+
+			`{"message_b":"cache hit","duration_msec_a":0,"duration_msec_b":1,"delta_msec":1},`+
+			`{"message_a":"done","message_b":"done","duration_msec_a":6,"duration_msec_b":4,"delta_msec":-2},`+
+			`{"message_b":"extra","duration_msec_a":0,"duration_msec_b":0,"delta_msec":0}]}`)
+}
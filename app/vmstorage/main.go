@@ -1,6 +1,7 @@
 package vmstorage
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -12,6 +13,8 @@ import (
 
 	"github.com/VictoriaMetrics/metrics"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/actions"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
@@ -31,9 +34,18 @@ var (
 	snapshotAuthKey   = flagutil.NewPassword("snapshotAuthKey", "authKey, which must be passed in query string to /snapshot* pages. It overrides -httpAuth.*")
 	forceMergeAuthKey = flagutil.NewPassword("forceMergeAuthKey", "authKey, which must be passed in query string to /internal/force_merge pages. It overrides -httpAuth.*")
 	forceFlushAuthKey = flagutil.NewPassword("forceFlushAuthKey", "authKey, which must be passed in query string to /internal/force_flush pages. It overrides -httpAuth.*")
+	partitionAuthKey  = flagutil.NewPassword("partitionAuthKey", "authKey, which must be passed in query string to /internal/partition/* pages. It overrides -httpAuth.*")
 	snapshotsMaxAge   = flagutil.NewRetentionDuration("snapshotsMaxAge", "0", "Automatically delete snapshots older than -snapshotsMaxAge if it is set to non-zero duration. Make sure that backup process has enough time to finish the backup before the corresponding snapshot is automatically deleted")
 	_                 = flag.Duration("snapshotCreateTimeout", 0, "Deprecated: this flag does nothing")
 
+	retentionFilters = flagutil.NewArrayString("retentionFilter", "Retention filter in the format 'filter:retention'. For example, '{env=\"dev\"}:3d' configures the retention for time series with env=\"dev\" label to 3 days. "+
+		"The retention must be smaller or equal to -retentionPeriod. Time series not matching any filter use -retentionPeriod. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#retention-filters")
+	downsamplingPeriods = flagutil.NewArrayString("downsampling.period", "Comma-separated downsampling periods in the format 'offset:interval'. "+
+		"For example, '30d:5m' instructs leaving the last sample per each 5-minute interval for samples older than 30 days. "+
+		"Downsampling is applied during background merges. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#downsampling")
+
 	precisionBits = flag.Int("precisionBits", 64, "The number of precision bits to store per each value. Lower precision bits improves data compression at the cost of precision loss")
 
 	// DataPath is a path to storage data.
@@ -81,8 +93,35 @@ var (
 		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#track-ingested-metrics-usage")
 	cacheSizeMetricNamesStats = flagutil.NewBytes("storage.cacheSizeMetricNamesStats", 0, "Overrides max size for storage/metricNamesStatsTracker cache. "+
 		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#cache-tuning")
+	cacheSizeMetricsMetadata = flagutil.NewBytes("storage.cacheSizeMetricsMetadata", 0, "Overrides max size for storage/metricsMetadata store. "+
+		"The least recently seen metadata is evicted when the limit is reached. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#metrics-metadata")
+	metricsMetadataRetention = flag.Duration("storage.metricsMetadataRetention", 24*time.Hour, "Metrics metadata, which wasn't received during the given duration, is removed. "+
+		"Metadata is kept until -storage.cacheSizeMetricsMetadata limit is reached if this flag is set to 0. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#metrics-metadata")
+	maxExemplarsPerSeries = flag.Int("storage.maxExemplarsPerSeries", 0, "The maximum number of the most recent exemplars to store per each series. "+
+		"Exemplars aren't stored if this flag is set to 0. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#exemplars")
+	cacheSizeExemplars = flagutil.NewBytes("storage.cacheSizeExemplars", 0, "Overrides max size for storage/exemplars store. "+
+		"Exemplars for the least recently updated series are evicted when the limit is reached. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#exemplars")
+	exemplarsRetention = flag.Duration("storage.exemplarsRetention", 24*time.Hour, "Exemplars older than the given duration are removed. "+
+		"It is recommended to set it to the maximum time range used for querying exemplars, e.g. the maximum time range for Grafana dashboards with exemplars. "+
+		"Exemplars are kept until -storage.cacheSizeExemplars limit is reached if this flag is set to 0. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#exemplars")
+
+	offloadDst = flag.String("storage.offloadDst", "", "Optional remote storage for offloading data of partitions older than -storage.offloadAfter. "+
+		"For example, s3://bucket/path, gs://bucket/path, azblob://container/path or fs:///local/path . "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#tiered-storage")
+	offloadAfter = flagutil.NewRetentionDuration("storage.offloadAfter", "3", "Partitions with data older than the given duration are offloaded to -storage.offloadDst. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#tiered-storage")
+	offloadCacheSize = flagutil.NewBytes("storage.offloadCacheSize", 10*1024*1024*1024, "The maximum size of the local disk cache for data read from -storage.offloadDst. "+
+		"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#tiered-storage")
 )
 
+// offloadFS is the remote storage for offloaded data. It is nil if -storage.offloadDst isn't set.
+var offloadFS common.RemoteFS
+
 // CheckTimeRange returns true if the given tr is denied for querying.
 func CheckTimeRange(tr storage.TimeRange) error {
 	if !*denyQueriesOutsideRetention {
@@ -99,18 +138,27 @@ func CheckTimeRange(tr storage.TimeRange) error {
 }
 
 // Init initializes vmstorage.
-func Init(resetCacheIfNeeded func(mrs []storage.MetricRow)) {
+//
+// resetCacheIfNeeded is called before adding rows to the storage, while resetCache is called after the stored data is changed
+// by detaching or attaching partitions.
+func Init(resetCacheIfNeeded func(mrs []storage.MetricRow), resetCache func()) {
 	if err := encoding.CheckPrecisionBits(uint8(*precisionBits)); err != nil {
 		logger.Fatalf("invalid `-precisionBits`: %s", err)
 	}
 
 	resetResponseCacheIfNeeded = resetCacheIfNeeded
+	resetResponseCache = resetCache
 	storage.SetLogNewSeries(*logNewSeries)
 	storage.SetRetentionTimezoneOffset(*retentionTimezoneOffset)
 	storage.SetFreeDiskSpaceLimit(minFreeDiskSpaceBytes.N)
 	storage.SetTSIDCacheSize(cacheSizeStorageTSID.IntN())
 	storage.SetTagFiltersCacheSize(cacheSizeIndexDBTagFilters.IntN())
 	storage.SetMetricNamesStatsCacheSize(cacheSizeMetricNamesStats.IntN())
+	storage.SetMetricsMetadataCacheSize(cacheSizeMetricsMetadata.IntN())
+	storage.SetMetricsMetadataRetention(*metricsMetadataRetention)
+	storage.SetMaxExemplarsPerSeries(*maxExemplarsPerSeries)
+	storage.SetExemplarsCacheSize(cacheSizeExemplars.IntN())
+	storage.SetExemplarsRetention(*exemplarsRetention)
 	mergeset.SetIndexBlocksCacheSize(cacheSizeIndexDBIndexBlocks.IntN())
 	mergeset.SetDataBlocksCacheSize(cacheSizeIndexDBDataBlocks.IntN())
 	mergeset.SetDataBlocksSparseCacheSize(cacheSizeIndexDBDataBlocksSparse.IntN())
@@ -118,15 +166,40 @@ func Init(resetCacheIfNeeded func(mrs []storage.MetricRow)) {
 	if retentionPeriod.Duration() < 24*time.Hour {
 		logger.Fatalf("-retentionPeriod cannot be smaller than a day; got %s", retentionPeriod)
 	}
+	rfs, err := storage.ParseRetentionFilters(*retentionFilters)
+	if err != nil {
+		logger.Fatalf("invalid -retentionFilter: %s", err)
+	}
+	for _, rf := range rfs {
+		if rf.Retention > retentionPeriod.Duration() {
+			logger.Fatalf("-retentionFilter=%s cannot exceed -retentionPeriod=%s", rf, retentionPeriod)
+		}
+	}
+	dps, err := storage.ParseDownsamplingPeriods(*downsamplingPeriods)
+	if err != nil {
+		logger.Fatalf("invalid -downsampling.period: %s", err)
+	}
+	storage.SetDownsamplingPeriods(dps)
+	if *offloadDst != "" {
+		rfs, err := actions.NewRemoteFS(context.Background(), *offloadDst)
+		if err != nil {
+			logger.Fatalf("cannot initialize -storage.offloadDst=%q: %s", *offloadDst, err)
+		}
+		offloadFS = rfs
+	}
 	logger.Infof("opening storage at %q with -retentionPeriod=%s", *DataPath, retentionPeriod)
 	startTime := time.Now()
 	WG = syncwg.WaitGroup{}
 	opts := storage.OpenOptions{
 		Retention:             retentionPeriod.Duration(),
+		RetentionFilters:      rfs,
 		MaxHourlySeries:       *maxHourlySeries,
 		MaxDailySeries:        *maxDailySeries,
 		DisablePerDayIndex:    *disablePerDayIndex,
 		TrackMetricNamesStats: *trackMetricNamesStats,
+		OffloadFS:             offloadFS,
+		OffloadAfter:          offloadAfter.Duration(),
+		OffloadCacheSize:      uint64(offloadCacheSize.IntN()),
 	}
 	strg := storage.MustOpenStorage(*DataPath, opts)
 	Storage = strg
@@ -166,6 +239,9 @@ var WG syncwg.WaitGroup
 // resetResponseCacheIfNeeded is a callback for automatic resetting of response cache if needed.
 var resetResponseCacheIfNeeded func(mrs []storage.MetricRow)
 
+// resetResponseCache is a callback for resetting response cache after detaching or attaching partitions.
+var resetResponseCache func()
+
 // AddRows adds mrs to the storage.
 //
 // The caller should limit the number of concurrent calls to AddRows() in order to limit memory usage.
@@ -199,6 +275,16 @@ func DeleteSeries(qt *querytracer.Tracer, tfss []*storage.TagFilters, maxMetrics
 	return n, err
 }
 
+// DeleteSeriesOnTimeRange deletes samples on the given tr for series matching tfss.
+//
+// Returns the number of series with deleted samples.
+func DeleteSeriesOnTimeRange(qt *querytracer.Tracer, tfss []*storage.TagFilters, tr storage.TimeRange, maxMetrics int) (int, error) {
+	WG.Add(1)
+	n, err := Storage.DeleteSeriesOnTimeRange(qt, tfss, tr, maxMetrics)
+	WG.Done()
+	return n, err
+}
+
 // GetMetricNamesStats returns metric names usage stats with give limit and lte predicate
 func GetMetricNamesStats(qt *querytracer.Tracer, limit, le int, matchPattern string) (storage.MetricNamesStatsResponse, error) {
 	WG.Add(1)
@@ -214,6 +300,41 @@ func ResetMetricNamesStats(qt *querytracer.Tracer) {
 	WG.Done()
 }
 
+// AddMetricsMetadata adds mms to the metrics metadata store.
+func AddMetricsMetadata(mms []storage.MetricMetadata) {
+	WG.Add(1)
+	Storage.AddMetricsMetadata(mms)
+	WG.Done()
+}
+
+// IsExemplarsEnabled returns true if the storage stores exemplars.
+func IsExemplarsEnabled() bool {
+	return Storage.IsExemplarsEnabled()
+}
+
+// AddExemplars adds ers to the exemplars store.
+func AddExemplars(ers []storage.ExemplarRow) {
+	WG.Add(1)
+	Storage.AddExemplars(ers)
+	WG.Done()
+}
+
+// SearchExemplars returns exemplars for series matching the given tfss on the given tr.
+func SearchExemplars(qt *querytracer.Tracer, tfss []*storage.TagFilters, tr storage.TimeRange, maxMetrics int, deadline uint64) ([]storage.SeriesExemplars, error) {
+	WG.Add(1)
+	ses, err := Storage.SearchExemplars(qt, tfss, tr, maxMetrics, deadline)
+	WG.Done()
+	return ses, err
+}
+
+// GetMetricsMetadata appends metrics metadata for the given metricName to dst and returns the result.
+func GetMetricsMetadata(qt *querytracer.Tracer, dst []storage.MetricMetadata, metricName string, limit, limitPerMetric int) []storage.MetricMetadata {
+	WG.Add(1)
+	dst = Storage.GetMetricsMetadata(qt, dst, metricName, limit, limitPerMetric)
+	WG.Done()
+	return dst
+}
+
 // SearchMetricNames returns metric names for the given tfss on the given tr.
 func SearchMetricNames(qt *querytracer.Tracer, tfss []*storage.TagFilters, tr storage.TimeRange, maxMetrics int, deadline uint64) ([]string, error) {
 	WG.Add(1)
@@ -265,6 +386,14 @@ func GetTSDBStatus(qt *querytracer.Tracer, tfss []*storage.TagFilters, date uint
 	return status, err
 }
 
+// GetTSDBStatusOnDateRange returns TSDB status for given filters on the [minDate ... maxDate] date range.
+func GetTSDBStatusOnDateRange(qt *querytracer.Tracer, tfss []*storage.TagFilters, minDate, maxDate uint64, focusLabel string, topN, maxMetrics int, deadline uint64) (*storage.TSDBStatus, error) {
+	WG.Add(1)
+	status, err := Storage.GetTSDBStatusOnDateRange(qt, tfss, minDate, maxDate, focusLabel, topN, maxMetrics, deadline)
+	WG.Done()
+	return status, err
+}
+
 // GetSeriesCount returns the number of time series in the storage.
 func GetSeriesCount(deadline uint64) (uint64, error) {
 	WG.Add(1)
@@ -284,6 +413,10 @@ func Stop() {
 	WG.WaitAndBlock()
 	stopStaleSnapshotsRemover()
 	Storage.MustClose()
+	if offloadFS != nil {
+		offloadFS.MustStop()
+		offloadFS = nil
+	}
 	logger.Infof("successfully closed the storage in %.3f seconds", time.Since(startTime).Seconds())
 
 	logger.Infof("the storage has been stopped")
@@ -318,6 +451,42 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		Storage.DebugFlush()
 		return true
 	}
+	if path == "/internal/partition/detach" {
+		if !httpserver.CheckAuthFlag(w, r, partitionAuthKey) {
+			return true
+		}
+		partitionsDetachTotal.Inc()
+		w.Header().Set("Content-Type", "application/json")
+		name := r.FormValue("name")
+		if err := Storage.DetachPartition(name); err != nil {
+			err = fmt.Errorf("cannot detach partition %q: %w", name, err)
+			jsonResponseError(w, err)
+			partitionsDetachErrorsTotal.Inc()
+			return true
+		}
+		// Reset response cache, so queries do not return cached results for the detached partition.
+		resetResponseCache()
+		fmt.Fprintf(w, `{"status":"ok"}`)
+		return true
+	}
+	if path == "/internal/partition/attach" {
+		if !httpserver.CheckAuthFlag(w, r, partitionAuthKey) {
+			return true
+		}
+		partitionsAttachTotal.Inc()
+		w.Header().Set("Content-Type", "application/json")
+		name := r.FormValue("name")
+		if err := Storage.AttachPartition(name); err != nil {
+			err = fmt.Errorf("cannot attach partition %q: %w", name, err)
+			jsonResponseError(w, err)
+			partitionsAttachErrorsTotal.Inc()
+			return true
+		}
+		// Reset response cache, so queries do not return cached empty results for the attached partition.
+		resetResponseCache()
+		fmt.Fprintf(w, `{"status":"ok"}`)
+		return true
+	}
 	prometheusCompatibleResponse := false
 	if path == "/api/v1/admin/tsdb/snapshot" {
 		// Handle Prometheus API - https://prometheus.io/docs/prometheus/latest/querying/api/#snapshot .
@@ -442,6 +611,12 @@ var (
 
 	snapshotsDeleteAllTotal       = metrics.NewCounter(`vm_http_requests_total{path="/snapshot/delete_all"}`)
 	snapshotsDeleteAllErrorsTotal = metrics.NewCounter(`vm_http_request_errors_total{path="/snapshot/delete_all"}`)
+
+	partitionsDetachTotal       = metrics.NewCounter(`vm_http_requests_total{path="/internal/partition/detach"}`)
+	partitionsDetachErrorsTotal = metrics.NewCounter(`vm_http_request_errors_total{path="/internal/partition/detach"}`)
+
+	partitionsAttachTotal       = metrics.NewCounter(`vm_http_requests_total{path="/internal/partition/attach"}`)
+	partitionsAttachErrorsTotal = metrics.NewCounter(`vm_http_request_errors_total{path="/internal/partition/attach"}`)
 )
 
 func writeStorageMetrics(w io.Writer, strg *storage.Storage) {
@@ -655,8 +830,41 @@ func writeStorageMetrics(w io.Writer, strg *storage.Storage) {
 		metrics.WriteCounterUint64(w, `vm_cache_size_max_bytes{type="storage/metricNamesStatsTracker"}`, m.MetricNamesUsageTrackerSizeMaxBytes)
 	}
 
+	metrics.WriteGaugeUint64(w, `vm_cache_size_bytes{type="storage/metricsMetadata"}`, m.MetricsMetadataSizeBytes)
+	metrics.WriteGaugeUint64(w, `vm_cache_size{type="storage/metricsMetadata"}`, m.MetricsMetadataSize)
+	metrics.WriteGaugeUint64(w, `vm_cache_size_max_bytes{type="storage/metricsMetadata"}`, m.MetricsMetadataSizeMaxBytes)
+	metrics.WriteCounterUint64(w, `vm_metrics_metadata_dropped_rows_total`, m.MetricsMetadataDroppedRows)
+	metrics.WriteCounterUint64(w, `vm_metrics_metadata_evicted_rows_total{reason="size_limit"}`, m.MetricsMetadataEvictedRows)
+	metrics.WriteCounterUint64(w, `vm_metrics_metadata_evicted_rows_total{reason="retention"}`, m.MetricsMetadataExpiredRows)
+	metrics.WriteGaugeUint64(w, `vm_cache_size_bytes{type="storage/exemplars"}`, m.ExemplarsSizeBytes)
+	metrics.WriteGaugeUint64(w, `vm_cache_size{type="storage/exemplars"}`, m.ExemplarsSize)
+	metrics.WriteGaugeUint64(w, `vm_cache_size_max_bytes{type="storage/exemplars"}`, m.ExemplarsSizeMaxBytes)
+	metrics.WriteCounterUint64(w, `vm_exemplars_dropped_total{reason="size_limit"}`, m.ExemplarsDropped)
+	metrics.WriteCounterUint64(w, `vm_exemplars_evicted_total{reason="size_limit"}`, m.ExemplarsEvicted)
+	metrics.WriteCounterUint64(w, `vm_exemplars_evicted_total{reason="retention"}`, m.ExemplarsExpired)
+	metrics.WriteCounterUint64(w, `vm_exemplars_dropped_total{reason="unknown_series"}`, m.ExemplarsMissingTSIDsDropped)
+
+	metrics.WriteGaugeUint64(w, `vm_pending_tombstones`, m.PendingTombstones)
+
 	metrics.WriteGaugeUint64(w, `vm_downsampling_partitions_scheduled`, tm.ScheduledDownsamplingPartitions)
 	metrics.WriteGaugeUint64(w, `vm_downsampling_partitions_scheduled_size_bytes`, tm.ScheduledDownsamplingPartitionsSize)
+	metrics.WriteGaugeUint64(w, `vm_retention_filters_partitions_scheduled`, tm.ScheduledRetentionFiltersPartitions)
+	metrics.WriteGaugeUint64(w, `vm_retention_filters_partitions_scheduled_size_bytes`, tm.ScheduledRetentionFiltersPartitionsSize)
+
+	if offloadFS != nil {
+		metrics.WriteGaugeUint64(w, `vm_offloaded_parts`, tm.OffloadedPartsCount)
+		metrics.WriteGaugeUint64(w, `vm_offloaded_size_bytes`, tm.OffloadedSizeBytes)
+		metrics.WriteCounterUint64(w, `vm_offload_uploaded_bytes_total`, m.OffloadUploadedBytes)
+		metrics.WriteCounterUint64(w, `vm_offload_downloaded_bytes_total`, m.OffloadDownloadedBytes)
+		metrics.WriteCounterUint64(w, `vm_offload_download_errors_total`, m.OffloadDownloadErrors)
+		metrics.WriteCounterUint64(w, `vm_offload_download_failures_total`, m.OffloadDownloadFailures)
+		metrics.WriteCounterUint64(w, `vm_offload_deleted_chunks_total`, m.OffloadDeletedChunks)
+		metrics.WriteGaugeUint64(w, `vm_cache_entries{type="storage/offloadedChunks"}`, m.OffloadCacheSize)
+		metrics.WriteGaugeUint64(w, `vm_cache_size_bytes{type="storage/offloadedChunks"}`, m.OffloadCacheSizeBytes)
+		metrics.WriteGaugeUint64(w, `vm_cache_size_max_bytes{type="storage/offloadedChunks"}`, m.OffloadCacheSizeMaxBytes)
+		metrics.WriteCounterUint64(w, `vm_cache_requests_total{type="storage/offloadedChunks"}`, m.OffloadCacheRequests)
+		metrics.WriteCounterUint64(w, `vm_cache_misses_total{type="storage/offloadedChunks"}`, m.OffloadCacheMisses)
+	}
 }
 
 func jsonResponseError(w http.ResponseWriter, err error) {
@@ -11,16 +11,22 @@ import (
 var influxDatabaseNames = flagutil.NewArrayString("influx.databaseNames", "Comma-separated list of database names to return from /query and /influx/query API. "+
 	"This can be needed for accepting data from Telegraf plugins such as https://github.com/fangli/fluent-plugin-influxdb")
 
+// DatabaseNames returns database names, which must be returned from `SHOW DATABASES` query.
+func DatabaseNames() []string {
+	dbNames := *influxDatabaseNames
+	if len(dbNames) == 0 {
+		dbNames = []string{"_internal"}
+	}
+	return dbNames
+}
+
 // WriteDatabaseNames writes influxDatabaseNames to w.
 func WriteDatabaseNames(w http.ResponseWriter) {
 	// Emulate fake response for influx query.
 	// This is required for TSBS benchmark and some Telegraf plugins.
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1124
 	w.Header().Set("Content-Type", "application/json")
-	dbNames := *influxDatabaseNames
-	if len(dbNames) == 0 {
-		dbNames = []string{"_internal"}
-	}
+	dbNames := DatabaseNames()
 	dbs := make([]string, len(dbNames))
 	for i := range dbNames {
 		dbs[i] = fmt.Sprintf(`[%q]`, dbNames[i])
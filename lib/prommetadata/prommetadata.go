@@ -0,0 +1,21 @@
+package prommetadata
+
+import (
+	"flag"
+)
+
+var enableMetadata = flag.Bool("enableMetadata", false, "Whether to enable processing of metric metadata (TYPE, HELP and UNIT) for metrics scraped from targets, "+
+	"received via Prometheus remote write or via OpenTelemetry protocol. The metadata is exposed via /api/v1/metadata. "+
+	"See https://docs.victoriametrics.com/victoriametrics/single-server-victoriametrics/#metrics-metadata")
+
+// IsEnabled returns true if metric metadata processing is enabled via -enableMetadata command-line flag.
+func IsEnabled() bool {
+	return *enableMetadata
+}
+
+// SetEnabled overrides -enableMetadata command-line flag value.
+//
+// This function is intended for tests.
+func SetEnabled(v bool) {
+	*enableMetadata = v
+}
@@ -0,0 +1,384 @@
+package prompb
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// customBucketsSchema is the schema for native histograms with custom bucket bounds.
+//
+// See https://prometheus.io/docs/specs/native_histograms/#custom-buckets
+const customBucketsSchema = -53
+
+// Histogram is Prometheus native histogram sample.
+//
+// See https://prometheus.io/docs/specs/native_histograms/
+type Histogram struct {
+	// Count is the total number of observations.
+	Count float64
+
+	// Sum is the sum of observations.
+	Sum float64
+
+	// Schema defines bucket bounds for exponential histograms or equals to -53 for histograms with custom bucket bounds.
+	Schema int32
+
+	// ZeroThreshold is the width of the zero bucket.
+	ZeroThreshold float64
+
+	// ZeroCount is the number of observations in the zero bucket.
+	ZeroCount float64
+
+	// NegativeSpans contains spans for negative buckets.
+	NegativeSpans []BucketSpan
+
+	// NegativeDeltas contains delta-encoded counts for negative buckets of integer histograms.
+	NegativeDeltas []int64
+
+	// NegativeCounts contains absolute counts for negative buckets of float histograms.
+	NegativeCounts []float64
+
+	// PositiveSpans contains spans for positive buckets.
+	PositiveSpans []BucketSpan
+
+	// PositiveDeltas contains delta-encoded counts for positive buckets of integer histograms.
+	PositiveDeltas []int64
+
+	// PositiveCounts contains absolute counts for positive buckets of float histograms.
+	PositiveCounts []float64
+
+	// Timestamp is unix timestamp for the histogram in milliseconds.
+	Timestamp int64
+
+	// CustomValues contains upper bounds for buckets if Schema equals to -53.
+	CustomValues []float64
+}
+
+// BucketSpan defines a span of consecutive buckets in native histogram.
+type BucketSpan struct {
+	// Offset is the gap to the previous span or the starting bucket index for the first span.
+	Offset int32
+
+	// Length is the number of consecutive buckets in the span.
+	Length uint32
+}
+
+// Reset resets h for subsequent reuse.
+func (h *Histogram) Reset() {
+	h.Count = 0
+	h.Sum = 0
+	h.Schema = 0
+	h.ZeroThreshold = 0
+	h.ZeroCount = 0
+	h.NegativeSpans = h.NegativeSpans[:0]
+	h.NegativeDeltas = h.NegativeDeltas[:0]
+	h.NegativeCounts = h.NegativeCounts[:0]
+	h.PositiveSpans = h.PositiveSpans[:0]
+	h.PositiveDeltas = h.PositiveDeltas[:0]
+	h.PositiveCounts = h.PositiveCounts[:0]
+	h.Timestamp = 0
+	h.CustomValues = h.CustomValues[:0]
+}
+
+// IsStale returns true if h is a staleness marker.
+func (h *Histogram) IsStale() bool {
+	return decimal.IsStaleNaN(h.Sum)
+}
+
+// VisitBuckets calls f for every non-empty bucket in h.
+//
+// lower and upper are bucket bounds, while count is the number of observations in the bucket.
+// Bucket counts aren't cumulative, so they can be stored as `vmrange` buckets. See FormatVMRange.
+func (h *Histogram) VisitBuckets(f func(lower, upper, count float64)) error {
+	if h.ZeroCount > 0 {
+		f(0, h.ZeroThreshold, h.ZeroCount)
+	}
+	if h.Schema == customBucketsSchema {
+		if len(h.NegativeSpans) > 0 {
+			return fmt.Errorf("negative buckets aren't allowed for histograms with custom bucket bounds")
+		}
+		return visitBuckets(h.PositiveSpans, h.PositiveDeltas, h.PositiveCounts, func(idx int32, count float64) error {
+			if idx < 0 || int(idx) > len(h.CustomValues) {
+				return fmt.Errorf("bucket index %d is out of custom values range [0..%d]", idx, len(h.CustomValues))
+			}
+			lower := math.Inf(-1)
+			if idx > 0 {
+				lower = h.CustomValues[idx-1]
+			}
+			upper := math.Inf(1)
+			if int(idx) < len(h.CustomValues) {
+				upper = h.CustomValues[idx]
+			}
+			f(lower, upper, count)
+			return nil
+		})
+	}
+	if h.Schema < -4 || h.Schema > 8 {
+		return fmt.Errorf("unsupported schema %d; it must be in the range [-4..8]", h.Schema)
+	}
+
+	// Bucket with index i contains observations in the range (base^(i-1), base^i], where base=2^(2^-schema).
+	ratio := math.Pow(2, -float64(h.Schema))
+	base := math.Pow(2, ratio)
+	err := visitBuckets(h.PositiveSpans, h.PositiveDeltas, h.PositiveCounts, func(idx int32, count float64) error {
+		upper := math.Pow(2, float64(idx)*ratio)
+		f(upper/base, upper, count)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot visit positive buckets: %w", err)
+	}
+	err = visitBuckets(h.NegativeSpans, h.NegativeDeltas, h.NegativeCounts, func(idx int32, count float64) error {
+		upper := math.Pow(2, float64(idx)*ratio)
+		f(-upper, -upper/base, count)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot visit negative buckets: %w", err)
+	}
+	return nil
+}
+
+func visitBuckets(spans []BucketSpan, deltas []int64, counts []float64, f func(idx int32, count float64) error) error {
+	if len(deltas) > 0 && len(counts) > 0 {
+		return fmt.Errorf("integer and float bucket counts cannot be mixed")
+	}
+	bucketsLen := len(deltas) + len(counts)
+	n := 0
+	idx := int32(0)
+	count := int64(0)
+	for _, span := range spans {
+		idx += span.Offset
+		for j := uint32(0); j < span.Length; j++ {
+			if n >= bucketsLen {
+				return fmt.Errorf("spans refer to more than %d buckets", bucketsLen)
+			}
+			var v float64
+			if len(deltas) > 0 {
+				count += deltas[n]
+				v = float64(count)
+			} else {
+				v = counts[n]
+			}
+			if v > 0 {
+				if err := f(idx, v); err != nil {
+					return err
+				}
+			}
+			n++
+			idx++
+		}
+	}
+	if n != bucketsLen {
+		return fmt.Errorf("spans refer to %d buckets, while %d buckets are provided", n, bucketsLen)
+	}
+	return nil
+}
+
+// FormatVMRange returns `vmrange` label value for the bucket with the given bounds.
+//
+// See https://valyala.medium.com/improving-histogram-usability-for-prometheus-and-grafana-bc7e5df0e350
+func FormatVMRange(lower, upper float64) string {
+	return fmt.Sprintf("%.3e...%.3e", lower, upper)
+}
+
+func (h *Histogram) unmarshalProtobuf(src []byte) (err error) {
+	// message Histogram {
+	//   oneof count {
+	//     uint64 count_int   = 1;
+	//     double count_float = 2;
+	//   }
+	//   double sum            = 3;
+	//   sint32 schema         = 4;
+	//   double zero_threshold = 5;
+	//   oneof zero_count {
+	//     uint64 zero_count_int   = 6;
+	//     double zero_count_float = 7;
+	//   }
+	//   repeated BucketSpan negative_spans  = 8;
+	//   repeated sint64     negative_deltas = 9;
+	//   repeated double     negative_counts = 10;
+	//   repeated BucketSpan positive_spans  = 11;
+	//   repeated sint64     positive_deltas = 12;
+	//   repeated double     positive_counts = 13;
+	//   ResetHint           reset_hint      = 14;
+	//   int64               timestamp       = 15;
+	//   repeated double     custom_values   = 16;
+	// }
+	h.Reset()
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		var ok bool
+		switch fc.FieldNum {
+		case 1:
+			var n uint64
+			n, ok = fc.Uint64()
+			h.Count = float64(n)
+		case 2:
+			h.Count, ok = fc.Double()
+		case 3:
+			h.Sum, ok = fc.Double()
+		case 4:
+			h.Schema, ok = fc.Sint32()
+		case 5:
+			h.ZeroThreshold, ok = fc.Double()
+		case 6:
+			var n uint64
+			n, ok = fc.Uint64()
+			h.ZeroCount = float64(n)
+		case 7:
+			h.ZeroCount, ok = fc.Double()
+		case 8:
+			h.NegativeSpans, err = appendBucketSpan(h.NegativeSpans, &fc)
+			ok = err == nil
+		case 9:
+			h.NegativeDeltas, ok = fc.UnpackSint64s(h.NegativeDeltas)
+		case 10:
+			h.NegativeCounts, ok = fc.UnpackDoubles(h.NegativeCounts)
+		case 11:
+			h.PositiveSpans, err = appendBucketSpan(h.PositiveSpans, &fc)
+			ok = err == nil
+		case 12:
+			h.PositiveDeltas, ok = fc.UnpackSint64s(h.PositiveDeltas)
+		case 13:
+			h.PositiveCounts, ok = fc.UnpackDoubles(h.PositiveCounts)
+		case 15:
+			h.Timestamp, ok = fc.Int64()
+		case 16:
+			h.CustomValues, ok = fc.UnpackDoubles(h.CustomValues)
+		default:
+			ok = true
+		}
+		if !ok {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("cannot read field #%d", fc.FieldNum)
+		}
+	}
+	return nil
+}
+
+func appendBucketSpan(dst []BucketSpan, fc *easyproto.FieldContext) ([]BucketSpan, error) {
+	data, ok := fc.MessageData()
+	if !ok {
+		return dst, fmt.Errorf("cannot read bucket span data")
+	}
+	var bs BucketSpan
+	if err := bs.unmarshalProtobuf(data); err != nil {
+		return dst, fmt.Errorf("cannot unmarshal bucket span: %w", err)
+	}
+	return append(dst, bs), nil
+}
+
+func (bs *BucketSpan) unmarshalProtobuf(src []byte) (err error) {
+	// message BucketSpan {
+	//   sint32 offset = 1;
+	//   uint32 length = 2;
+	// }
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			offset, ok := fc.Sint32()
+			if !ok {
+				return fmt.Errorf("cannot read bucket span offset")
+			}
+			bs.Offset = offset
+		case 2:
+			length, ok := fc.Uint32()
+			if !ok {
+				return fmt.Errorf("cannot read bucket span length")
+			}
+			bs.Length = length
+		}
+	}
+	return nil
+}
+
+// convertLastTimeSeriesHistograms converts native histograms for the last time series in tss to ordinary time series.
+//
+// The last time series is dropped if it has no samples.
+func convertLastTimeSeriesHistograms(tss []TimeSeries, labelsPool []Label, samplesPool []Sample) ([]TimeSeries, []Label, []Sample, error) {
+	ts := &tss[len(tss)-1]
+	if len(ts.histograms) == 0 {
+		return tss, labelsPool, samplesPool, nil
+	}
+	labels, histograms := ts.Labels, ts.histograms
+	ts.histograms = nil
+	if len(ts.Samples) == 0 && len(ts.Exemplars) == 0 {
+		tss = tss[:len(tss)-1]
+	}
+	return appendHistogramTimeSeries(tss, labelsPool, samplesPool, labels, histograms)
+}
+
+// appendHistogramTimeSeries appends time series obtained from histograms with the given labels to tss.
+//
+// Every histogram is converted into `<name>_count`, `<name>_sum` and `<name>_bucket{vmrange="..."}` samples,
+// so they can be queried with histogram_quantile() and other histogram functions.
+func appendHistogramTimeSeries(tss []TimeSeries, labelsPool []Label, samplesPool []Sample, labels []Label, histograms []Histogram) ([]TimeSeries, []Label, []Sample, error) {
+	metricName := ""
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			metricName = label.Value
+			break
+		}
+	}
+	if metricName == "" {
+		return tss, labelsPool, samplesPool, fmt.Errorf("missing metric name for native histogram")
+	}
+
+	appendSample := func(suffix, extraLabelName, extraLabelValue string, timestamp int64, value float64) {
+		labelsPoolLen := len(labelsPool)
+		for _, label := range labels {
+			if label.Name == "__name__" {
+				label.Value = metricName + suffix
+			}
+			labelsPool = append(labelsPool, label)
+		}
+		if extraLabelName != "" {
+			labelsPool = append(labelsPool, Label{
+				Name:  extraLabelName,
+				Value: extraLabelValue,
+			})
+		}
+		samplesPoolLen := len(samplesPool)
+		samplesPool = append(samplesPool, Sample{
+			Value:     value,
+			Timestamp: timestamp,
+		})
+		tss = append(tss, TimeSeries{
+			Labels:  labelsPool[labelsPoolLen:],
+			Samples: samplesPool[samplesPoolLen:],
+		})
+	}
+
+	for i := range histograms {
+		h := &histograms[i]
+		if h.IsStale() {
+			appendSample("_count", "", "", h.Timestamp, decimal.StaleNaN)
+			appendSample("_sum", "", "", h.Timestamp, decimal.StaleNaN)
+			continue
+		}
+		appendSample("_count", "", "", h.Timestamp, h.Count)
+		appendSample("_sum", "", "", h.Timestamp, h.Sum)
+		err := h.VisitBuckets(func(lower, upper, count float64) {
+			appendSample("_bucket", "vmrange", FormatVMRange(lower, upper), h.Timestamp, count)
+		})
+		if err != nil {
+			return tss, labelsPool, samplesPool, fmt.Errorf("cannot convert native histogram %q: %w", metricName, err)
+		}
+	}
+	return tss, labelsPool, samplesPool, nil
+}
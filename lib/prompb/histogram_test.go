@@ -0,0 +1,158 @@
+package prompb
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+func TestHistogramVisitBucketsSuccess(t *testing.T) {
+	f := func(h *Histogram, resultExpected string) {
+		t.Helper()
+		var a []string
+		err := h.VisitBuckets(func(lower, upper, count float64) {
+			a = append(a, fmt.Sprintf("%s=%g", FormatVMRange(lower, upper), count))
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result := strings.Join(a, ",")
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	// empty histogram
+	f(&Histogram{}, "")
+
+	// integer histogram with schema=0
+	f(&Histogram{
+		Schema:         0,
+		ZeroThreshold:  1e-3,
+		ZeroCount:      2,
+		PositiveSpans:  []BucketSpan{{Offset: 0, Length: 2}, {Offset: 1, Length: 1}},
+		PositiveDeltas: []int64{1, 2, -3},
+		NegativeSpans:  []BucketSpan{{Offset: 1, Length: 1}},
+		NegativeDeltas: []int64{4},
+	}, "0.000e+00...1.000e-03=2,5.000e-01...1.000e+00=1,1.000e+00...2.000e+00=3,-2.000e+00...-1.000e+00=4")
+
+	// float histogram with schema=1
+	f(&Histogram{
+		Schema:         1,
+		PositiveSpans:  []BucketSpan{{Offset: 2, Length: 2}},
+		PositiveCounts: []float64{1.5, 0},
+	}, "1.414e+00...2.000e+00=1.5")
+
+	// custom buckets
+	f(&Histogram{
+		Schema:         customBucketsSchema,
+		PositiveSpans:  []BucketSpan{{Offset: 0, Length: 3}},
+		PositiveDeltas: []int64{1, 1, 1},
+		CustomValues:   []float64{0.5, 1},
+	}, "-Inf...5.000e-01=1,5.000e-01...1.000e+00=2,1.000e+00...+Inf=3")
+}
+
+func TestHistogramVisitBucketsFailure(t *testing.T) {
+	f := func(h *Histogram) {
+		t.Helper()
+		err := h.VisitBuckets(func(_, _, _ float64) {})
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// unsupported schema
+	f(&Histogram{
+		Schema: 9,
+	})
+
+	// spans refer to more buckets than provided
+	f(&Histogram{
+		PositiveSpans:  []BucketSpan{{Offset: 0, Length: 2}},
+		PositiveDeltas: []int64{1},
+	})
+
+	// spans refer to less buckets than provided
+	f(&Histogram{
+		PositiveSpans:  []BucketSpan{{Offset: 0, Length: 1}},
+		PositiveDeltas: []int64{1, 2},
+	})
+
+	// mixed integer and float counts
+	f(&Histogram{
+		PositiveSpans:  []BucketSpan{{Offset: 0, Length: 1}},
+		PositiveDeltas: []int64{1},
+		PositiveCounts: []float64{1},
+	})
+
+	// custom bucket index out of range
+	f(&Histogram{
+		Schema:         customBucketsSchema,
+		PositiveSpans:  []BucketSpan{{Offset: 0, Length: 2}},
+		PositiveDeltas: []int64{1, 1},
+	})
+}
+
+func TestWriteRequestUnmarshalProtobufHistograms(t *testing.T) {
+	var m easyproto.Marshaler
+	wr := m.MessageMarshaler()
+
+	ts := wr.AppendMessage(1)
+	label := ts.AppendMessage(1)
+	label.AppendString(1, "__name__")
+	label.AppendString(2, "foo")
+	label = ts.AppendMessage(1)
+	label.AppendString(1, "job")
+	label.AppendString(2, "bar")
+	h := ts.AppendMessage(4)
+	h.AppendUint64(1, 5)
+	h.AppendDouble(3, 12.5)
+	h.AppendSint32(4, 0)
+	span := h.AppendMessage(11)
+	span.AppendSint32(1, 1)
+	span.AppendUint32(2, 2)
+	h.AppendSint64s(12, []int64{2, 1})
+	h.AppendInt64(15, 1000)
+	h = ts.AppendMessage(4)
+	h.AppendDouble(3, decimal.StaleNaN)
+	h.AppendInt64(15, 2000)
+
+	ts = wr.AppendMessage(1)
+	label = ts.AppendMessage(1)
+	label.AppendString(1, "__name__")
+	label.AppendString(2, "baz")
+	sample := ts.AppendMessage(2)
+	sample.AppendDouble(1, 42)
+	sample.AppendInt64(2, 3000)
+
+	data := m.Marshal(nil)
+
+	var wrResult WriteRequest
+	if err := wrResult.UnmarshalProtobuf(data); err != nil {
+		t.Fatalf("cannot unmarshal protobuf: %s", err)
+	}
+	var a []string
+	for _, ts := range wrResult.Timeseries {
+		var labels []string
+		for _, label := range ts.Labels {
+			labels = append(labels, fmt.Sprintf("%s=%q", label.Name, label.Value))
+		}
+		for _, s := range ts.Samples {
+			a = append(a, fmt.Sprintf("{%s} %g %d", strings.Join(labels, ","), s.Value, s.Timestamp))
+		}
+	}
+	result := strings.Join(a, "\n")
+	resultExpected := `{__name__="foo_count",job="bar"} 5 1000
+{__name__="foo_sum",job="bar"} 12.5 1000
+{__name__="foo_bucket",job="bar",vmrange="1.000e+00...2.000e+00"} 2 1000
+{__name__="foo_bucket",job="bar",vmrange="2.000e+00...4.000e+00"} 3 1000
+{__name__="foo_count",job="bar"} NaN 2000
+{__name__="foo_sum",job="bar"} NaN 2000
+{__name__="baz"} 42 3000`
+	if result != resultExpected {
+		t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+	}
+}
@@ -11,8 +11,20 @@ type WriteRequest struct {
 	// Timeseries is a list of time series in the given WriteRequest
 	Timeseries []TimeSeries
 
-	labelsPool  []Label
-	samplesPool []Sample
+	// Metadata is a list of metric metadata in the given WriteRequest.
+	Metadata []MetricMetadata
+
+	// Stats contains the number of samples, native histogram samples and exemplars in the unmarshaled request.
+	Stats WriteRequestStats
+
+	labelsPool     []Label
+	samplesPool    []Sample
+	histogramsPool []Histogram
+	exemplarsPool  []Exemplar
+	symbolsPool    []string
+
+	labelRefsBuf         []uint32
+	exemplarLabelRefsBuf []uint32
 }
 
 // Reset resets wr for subsequent reuse.
@@ -25,6 +37,41 @@ func (wr *WriteRequest) Reset() {
 
 	clear(wr.samplesPool)
 	wr.samplesPool = wr.samplesPool[:0]
+
+	clear(wr.Metadata)
+	wr.Metadata = wr.Metadata[:0]
+
+	wr.Stats = WriteRequestStats{}
+
+	wr.histogramsPool = wr.histogramsPool[:0]
+
+	clear(wr.exemplarsPool)
+	wr.exemplarsPool = wr.exemplarsPool[:0]
+
+	clear(wr.symbolsPool)
+	wr.symbolsPool = wr.symbolsPool[:0]
+
+	wr.labelRefsBuf = wr.labelRefsBuf[:0]
+	wr.exemplarLabelRefsBuf = wr.exemplarLabelRefsBuf[:0]
+}
+
+// WriteRequestStats contains the number of samples, native histogram samples and exemplars in WriteRequest.
+//
+// The numbers are counted before converting native histograms to ordinary samples,
+// so they can be used for Prometheus remote write 2.0 response headers.
+type WriteRequestStats struct {
+	Samples    int
+	Histograms int
+	Exemplars  int
+}
+
+// add adds the number of samples, native histogram samples and exemplars in ts to ws.
+//
+// It must be called before converting native histograms in ts to ordinary samples.
+func (ws *WriteRequestStats) add(ts *TimeSeries) {
+	ws.Samples += len(ts.Samples)
+	ws.Histograms += len(ts.histograms)
+	ws.Exemplars += len(ts.Exemplars)
 }
 
 // TimeSeries is a timeseries.
@@ -34,6 +81,14 @@ type TimeSeries struct {
 
 	// Samples is a list of samples for the given TimeSeries
 	Samples []Sample
+
+	// Exemplars is a list of exemplars for the given TimeSeries.
+	Exemplars []Exemplar
+
+	// histograms is a list of native histogram samples for the given TimeSeries.
+	//
+	// They are converted to ordinary samples by WriteRequest.UnmarshalProtobuf.
+	histograms []Histogram
 }
 
 // Sample is a timeseries sample.
@@ -62,10 +117,14 @@ func (wr *WriteRequest) UnmarshalProtobuf(src []byte) (err error) {
 
 	// message WriteRequest {
 	//    repeated TimeSeries timeseries = 1;
+	//    repeated MetricMetadata metadata = 3;
 	// }
 	tss := wr.Timeseries
+	mms := wr.Metadata
 	labelsPool := wr.labelsPool
 	samplesPool := wr.samplesPool
+	histogramsPool := wr.histogramsPool
+	exemplarsPool := wr.exemplarsPool
 	var fc easyproto.FieldContext
 	for len(src) > 0 {
 		src, err = fc.NextField(src)
@@ -84,37 +143,60 @@ func (wr *WriteRequest) UnmarshalProtobuf(src []byte) (err error) {
 				tss = append(tss, TimeSeries{})
 			}
 			ts := &tss[len(tss)-1]
-			labelsPool, samplesPool, err = ts.unmarshalProtobuf(data, labelsPool, samplesPool)
+			labelsPool, samplesPool, histogramsPool, exemplarsPool, err = ts.unmarshalProtobuf(data, labelsPool, samplesPool, histogramsPool, exemplarsPool)
 			if err != nil {
 				return fmt.Errorf("cannot unmarshal timeseries: %w", err)
 			}
+			wr.Stats.add(ts)
+			tss, labelsPool, samplesPool, err = convertLastTimeSeriesHistograms(tss, labelsPool, samplesPool)
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal timeseries: %w", err)
+			}
+		case 3:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read metadata data")
+			}
+			mms = append(mms, MetricMetadata{})
+			mm := &mms[len(mms)-1]
+			if err := mm.unmarshalProtobuf(data); err != nil {
+				return fmt.Errorf("cannot unmarshal metadata: %w", err)
+			}
 		}
 	}
 	wr.Timeseries = tss
+	wr.Metadata = mms
 	wr.labelsPool = labelsPool
 	wr.samplesPool = samplesPool
+	wr.histogramsPool = histogramsPool
+	wr.exemplarsPool = exemplarsPool
 	return nil
 }
 
-func (ts *TimeSeries) unmarshalProtobuf(src []byte, labelsPool []Label, samplesPool []Sample) ([]Label, []Sample, error) {
+func (ts *TimeSeries) unmarshalProtobuf(src []byte, labelsPool []Label, samplesPool []Sample, histogramsPool []Histogram, exemplarsPool []Exemplar) ([]Label, []Sample, []Histogram, []Exemplar, error) {
 	// message TimeSeries {
-	//   repeated Label labels   = 1;
-	//   repeated Sample samples = 2;
+	//   repeated Label labels         = 1;
+	//   repeated Sample samples       = 2;
+	//   repeated Exemplar exemplars   = 3;
+	//   repeated Histogram histograms = 4;
 	// }
+	srcOrig := src
+	hasExemplars := false
 	labelsPoolLen := len(labelsPool)
 	samplesPoolLen := len(samplesPool)
+	histogramsPoolLen := len(histogramsPool)
 	var fc easyproto.FieldContext
 	for len(src) > 0 {
 		var err error
 		src, err = fc.NextField(src)
 		if err != nil {
-			return labelsPool, samplesPool, fmt.Errorf("cannot read the next field: %w", err)
+			return labelsPool, samplesPool, histogramsPool, exemplarsPool, fmt.Errorf("cannot read the next field: %w", err)
 		}
 		switch fc.FieldNum {
 		case 1:
 			data, ok := fc.MessageData()
 			if !ok {
-				return labelsPool, samplesPool, fmt.Errorf("cannot read label data")
+				return labelsPool, samplesPool, histogramsPool, exemplarsPool, fmt.Errorf("cannot read label data")
 			}
 			if len(labelsPool) < cap(labelsPool) {
 				labelsPool = labelsPool[:len(labelsPool)+1]
@@ -123,12 +205,12 @@ func (ts *TimeSeries) unmarshalProtobuf(src []byte, labelsPool []Label, samplesP
 			}
 			label := &labelsPool[len(labelsPool)-1]
 			if err := label.unmarshalProtobuf(data); err != nil {
-				return labelsPool, samplesPool, fmt.Errorf("cannot unmarshal label: %w", err)
+				return labelsPool, samplesPool, histogramsPool, exemplarsPool, fmt.Errorf("cannot unmarshal label: %w", err)
 			}
 		case 2:
 			data, ok := fc.MessageData()
 			if !ok {
-				return labelsPool, samplesPool, fmt.Errorf("cannot read the sample data")
+				return labelsPool, samplesPool, histogramsPool, exemplarsPool, fmt.Errorf("cannot read the sample data")
 			}
 			if len(samplesPool) < cap(samplesPool) {
 				samplesPool = samplesPool[:len(samplesPool)+1]
@@ -137,13 +219,102 @@ func (ts *TimeSeries) unmarshalProtobuf(src []byte, labelsPool []Label, samplesP
 			}
 			sample := &samplesPool[len(samplesPool)-1]
 			if err := sample.unmarshalProtobuf(data); err != nil {
-				return labelsPool, samplesPool, fmt.Errorf("cannot unmarshal sample: %w", err)
+				return labelsPool, samplesPool, histogramsPool, exemplarsPool, fmt.Errorf("cannot unmarshal sample: %w", err)
+			}
+		case 3:
+			// Exemplars are parsed after the series labels, since they share labelsPool.
+			hasExemplars = true
+		case 4:
+			data, ok := fc.MessageData()
+			if !ok {
+				return labelsPool, samplesPool, histogramsPool, exemplarsPool, fmt.Errorf("cannot read the histogram data")
+			}
+			if len(histogramsPool) < cap(histogramsPool) {
+				histogramsPool = histogramsPool[:len(histogramsPool)+1]
+			} else {
+				histogramsPool = append(histogramsPool, Histogram{})
+			}
+			h := &histogramsPool[len(histogramsPool)-1]
+			if err := h.unmarshalProtobuf(data); err != nil {
+				return labelsPool, samplesPool, histogramsPool, exemplarsPool, fmt.Errorf("cannot unmarshal histogram: %w", err)
 			}
 		}
 	}
 	ts.Labels = labelsPool[labelsPoolLen:]
 	ts.Samples = samplesPool[samplesPoolLen:]
-	return labelsPool, samplesPool, nil
+	ts.histograms = histogramsPool[histogramsPoolLen:]
+	ts.Exemplars = nil
+	if !hasExemplars {
+		return labelsPool, samplesPool, histogramsPool, exemplarsPool, nil
+	}
+
+	exemplarsPoolLen := len(exemplarsPool)
+	src = srcOrig
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return labelsPool, samplesPool, histogramsPool, exemplarsPool, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		if fc.FieldNum != 3 {
+			continue
+		}
+		data, ok := fc.MessageData()
+		if !ok {
+			return labelsPool, samplesPool, histogramsPool, exemplarsPool, fmt.Errorf("cannot read the exemplar data")
+		}
+		exemplarsPool = append(exemplarsPool, Exemplar{})
+		e := &exemplarsPool[len(exemplarsPool)-1]
+		labelsPool, err = e.unmarshalProtobuf(data, labelsPool)
+		if err != nil {
+			return labelsPool, samplesPool, histogramsPool, exemplarsPool, fmt.Errorf("cannot unmarshal exemplar: %w", err)
+		}
+	}
+	ts.Exemplars = exemplarsPool[exemplarsPoolLen:]
+	return labelsPool, samplesPool, histogramsPool, exemplarsPool, nil
+}
+
+func (e *Exemplar) unmarshalProtobuf(src []byte, labelsPool []Label) ([]Label, error) {
+	// message Exemplar {
+	//   repeated Label labels = 1;
+	//   double value          = 2;
+	//   int64 timestamp       = 3;
+	// }
+	labelsPoolLen := len(labelsPool)
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return labelsPool, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			data, ok := fc.MessageData()
+			if !ok {
+				return labelsPool, fmt.Errorf("cannot read label data")
+			}
+			labelsPool = append(labelsPool, Label{})
+			label := &labelsPool[len(labelsPool)-1]
+			if err := label.unmarshalProtobuf(data); err != nil {
+				return labelsPool, fmt.Errorf("cannot unmarshal label: %w", err)
+			}
+		case 2:
+			value, ok := fc.Double()
+			if !ok {
+				return labelsPool, fmt.Errorf("cannot read exemplar value")
+			}
+			e.Value = value
+		case 3:
+			timestamp, ok := fc.Int64()
+			if !ok {
+				return labelsPool, fmt.Errorf("cannot read exemplar timestamp")
+			}
+			e.Timestamp = timestamp
+		}
+	}
+	e.Labels = labelsPool[labelsPoolLen:]
+	return labelsPool, nil
 }
 
 func (lbl *Label) unmarshalProtobuf(src []byte) (err error) {
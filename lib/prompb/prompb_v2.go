@@ -0,0 +1,405 @@
+package prompb
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// ContentTypeV2 is the Content-Type header value for Prometheus remote write 2.0 requests.
+//
+// See https://prometheus.io/docs/specs/prw/remote_write_spec_2_0/#protocol
+const ContentTypeV2 = "application/x-protobuf;proto=io.prometheus.write.v2.Request"
+
+// Exemplar is an exemplar for a time series.
+type Exemplar struct {
+	// Labels is a list of exemplar labels.
+	Labels []Label
+
+	// Value is exemplar value.
+	Value float64
+
+	// Timestamp is unix timestamp for the exemplar in milliseconds.
+	Timestamp int64
+}
+
+// MetricType is the type of the metric.
+type MetricType uint32
+
+// Metric types.
+//
+// See https://github.com/OpenObservability/OpenMetrics/blob/main/specification/OpenMetrics.md#metric-types
+const (
+	MetricTypeUnknown        MetricType = 0
+	MetricTypeCounter        MetricType = 1
+	MetricTypeGauge          MetricType = 2
+	MetricTypeHistogram      MetricType = 3
+	MetricTypeGaugeHistogram MetricType = 4
+	MetricTypeSummary        MetricType = 5
+	MetricTypeInfo           MetricType = 6
+	MetricTypeStateset       MetricType = 7
+)
+
+// String returns string representation for mt as used in Prometheus text exposition format.
+func (mt MetricType) String() string {
+	switch mt {
+	case MetricTypeCounter:
+		return "counter"
+	case MetricTypeGauge:
+		return "gauge"
+	case MetricTypeHistogram:
+		return "histogram"
+	case MetricTypeGaugeHistogram:
+		return "gaugehistogram"
+	case MetricTypeSummary:
+		return "summary"
+	case MetricTypeInfo:
+		return "info"
+	case MetricTypeStateset:
+		return "stateset"
+	default:
+		return "unknown"
+	}
+}
+
+// MetricMetadata is metadata for the metric.
+type MetricMetadata struct {
+	// Type is the metric type.
+	Type MetricType
+
+	// MetricFamilyName is the name of the metric.
+	MetricFamilyName string
+
+	// Help is the help string for the metric.
+	Help string
+
+	// Unit is the unit of the metric.
+	Unit string
+}
+
+// UnmarshalProtobufV2 unmarshals Prometheus remote write 2.0 request from src into wr.
+//
+// Labels and exemplar labels are resolved via the symbols table, so wr has the same layout as after UnmarshalProtobuf call.
+// Per-series metadata is put into wr.Metadata.
+//
+// src mustn't change while wr is in use, since wr points to src.
+//
+// See https://prometheus.io/docs/specs/prw/remote_write_spec_2_0/
+func (wr *WriteRequest) UnmarshalProtobufV2(src []byte) (err error) {
+	wr.Reset()
+
+	// message Request {
+	//   repeated string symbols        = 4;
+	//   repeated TimeSeries timeseries = 5;
+	// }
+	//
+	// Symbols must be read before the timeseries, since the timeseries refer to them.
+	// The order of fields in protobuf messages isn't guaranteed, so read the symbols in a separate pass.
+	symbols := wr.symbolsPool
+	var fc easyproto.FieldContext
+	tail := src
+	for len(tail) > 0 {
+		tail, err = fc.NextField(tail)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		if fc.FieldNum == 4 {
+			symbol, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read symbol")
+			}
+			symbols = append(symbols, symbol)
+		}
+	}
+	wr.symbolsPool = symbols
+
+	tss := wr.Timeseries
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		if fc.FieldNum != 5 {
+			continue
+		}
+		data, ok := fc.MessageData()
+		if !ok {
+			return fmt.Errorf("cannot read timeseries data")
+		}
+		if len(tss) < cap(tss) {
+			tss = tss[:len(tss)+1]
+		} else {
+			tss = append(tss, TimeSeries{})
+		}
+		ts := &tss[len(tss)-1]
+		if err := wr.unmarshalTimeSeriesV2(ts, data, symbols); err != nil {
+			return fmt.Errorf("cannot unmarshal timeseries: %w", err)
+		}
+		wr.Stats.add(ts)
+		tss, wr.labelsPool, wr.samplesPool, err = convertLastTimeSeriesHistograms(tss, wr.labelsPool, wr.samplesPool)
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal timeseries: %w", err)
+		}
+	}
+	wr.Timeseries = tss
+	return nil
+}
+
+func (wr *WriteRequest) unmarshalTimeSeriesV2(ts *TimeSeries, src []byte, symbols []string) (err error) {
+	// message TimeSeries {
+	//   repeated uint32 labels_refs   = 1;
+	//   repeated Sample samples       = 2;
+	//   repeated Histogram histograms = 3;
+	//   repeated Exemplar exemplars   = 4;
+	//   Metadata metadata             = 5;
+	// }
+	//
+	// created_timestamp field is ignored, since VictoriaMetrics doesn't use it.
+	samplesPoolLen := len(wr.samplesPool)
+	histogramsPoolLen := len(wr.histogramsPool)
+	exemplarsPoolLen := len(wr.exemplarsPool)
+	var mm MetricMetadata
+	hasMetadata := false
+	refs := wr.labelRefsBuf[:0]
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			var ok bool
+			refs, ok = fc.UnpackUint32s(refs)
+			if !ok {
+				return fmt.Errorf("cannot read labels refs")
+			}
+		case 2:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read the sample data")
+			}
+			wr.samplesPool = append(wr.samplesPool, Sample{})
+			sample := &wr.samplesPool[len(wr.samplesPool)-1]
+			if err := sample.unmarshalProtobuf(data); err != nil {
+				return fmt.Errorf("cannot unmarshal sample: %w", err)
+			}
+		case 3:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read the histogram data")
+			}
+			if len(wr.histogramsPool) < cap(wr.histogramsPool) {
+				wr.histogramsPool = wr.histogramsPool[:len(wr.histogramsPool)+1]
+			} else {
+				wr.histogramsPool = append(wr.histogramsPool, Histogram{})
+			}
+			h := &wr.histogramsPool[len(wr.histogramsPool)-1]
+			if err := h.unmarshalProtobuf(data); err != nil {
+				return fmt.Errorf("cannot unmarshal histogram: %w", err)
+			}
+		case 4:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read the exemplar data")
+			}
+			wr.exemplarsPool = append(wr.exemplarsPool, Exemplar{})
+			e := &wr.exemplarsPool[len(wr.exemplarsPool)-1]
+			if err := wr.unmarshalExemplarV2(e, data, symbols); err != nil {
+				return fmt.Errorf("cannot unmarshal exemplar: %w", err)
+			}
+		case 5:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read the metadata")
+			}
+			if err := mm.unmarshalProtobufV2(data, symbols); err != nil {
+				return fmt.Errorf("cannot unmarshal metadata: %w", err)
+			}
+			hasMetadata = true
+		}
+	}
+	wr.labelRefsBuf = refs
+
+	// Exemplar labels may be already appended to wr.labelsPool, so series labels are appended after them.
+	labelsPoolLen := len(wr.labelsPool)
+	wr.labelsPool, err = appendLabelsFromRefs(wr.labelsPool, refs, symbols)
+	if err != nil {
+		return fmt.Errorf("cannot read labels: %w", err)
+	}
+	ts.Labels = wr.labelsPool[labelsPoolLen:]
+	ts.Samples = wr.samplesPool[samplesPoolLen:]
+	ts.histograms = wr.histogramsPool[histogramsPoolLen:]
+	ts.Exemplars = wr.exemplarsPool[exemplarsPoolLen:]
+
+	if hasMetadata && (mm.Type != MetricTypeUnknown || mm.Help != "" || mm.Unit != "") {
+		for _, label := range ts.Labels {
+			if label.Name == "__name__" {
+				mm.MetricFamilyName = label.Value
+				break
+			}
+		}
+		wr.Metadata = append(wr.Metadata, mm)
+	}
+	return nil
+}
+
+func (wr *WriteRequest) unmarshalExemplarV2(e *Exemplar, src []byte, symbols []string) (err error) {
+	// message Exemplar {
+	//   repeated uint32 label_refs = 1;
+	//   double value               = 2;
+	//   int64 timestamp            = 3;
+	// }
+	refs := wr.exemplarLabelRefsBuf[:0]
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			var ok bool
+			refs, ok = fc.UnpackUint32s(refs)
+			if !ok {
+				return fmt.Errorf("cannot read label refs")
+			}
+		case 2:
+			value, ok := fc.Double()
+			if !ok {
+				return fmt.Errorf("cannot read exemplar value")
+			}
+			e.Value = value
+		case 3:
+			timestamp, ok := fc.Int64()
+			if !ok {
+				return fmt.Errorf("cannot read exemplar timestamp")
+			}
+			e.Timestamp = timestamp
+		}
+	}
+	wr.exemplarLabelRefsBuf = refs
+
+	labelsPoolLen := len(wr.labelsPool)
+	wr.labelsPool, err = appendLabelsFromRefs(wr.labelsPool, refs, symbols)
+	if err != nil {
+		return fmt.Errorf("cannot read labels: %w", err)
+	}
+	e.Labels = wr.labelsPool[labelsPoolLen:]
+	return nil
+}
+
+func (mm *MetricMetadata) unmarshalProtobuf(src []byte) (err error) {
+	// message MetricMetadata {
+	//   MetricType type           = 1;
+	//   string metric_family_name = 2;
+	//   string help               = 4;
+	//   string unit               = 5;
+	// }
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			metricType, ok := fc.Uint32()
+			if !ok {
+				return fmt.Errorf("cannot read metric type")
+			}
+			mm.Type = MetricType(metricType)
+		case 2:
+			metricFamilyName, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read metric family name")
+			}
+			mm.MetricFamilyName = metricFamilyName
+		case 4:
+			help, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read help")
+			}
+			mm.Help = help
+		case 5:
+			unit, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read unit")
+			}
+			mm.Unit = unit
+		}
+	}
+	return nil
+}
+
+func (mm *MetricMetadata) unmarshalProtobufV2(src []byte, symbols []string) (err error) {
+	// message Metadata {
+	//   MetricType type = 1;
+	//   uint32 help_ref = 3;
+	//   uint32 unit_ref = 4;
+	// }
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			metricType, ok := fc.Uint32()
+			if !ok {
+				return fmt.Errorf("cannot read metric type")
+			}
+			mm.Type = MetricType(metricType)
+		case 3:
+			ref, ok := fc.Uint32()
+			if !ok {
+				return fmt.Errorf("cannot read help ref")
+			}
+			help, err := getSymbol(symbols, ref)
+			if err != nil {
+				return fmt.Errorf("cannot read help: %w", err)
+			}
+			mm.Help = help
+		case 4:
+			ref, ok := fc.Uint32()
+			if !ok {
+				return fmt.Errorf("cannot read unit ref")
+			}
+			unit, err := getSymbol(symbols, ref)
+			if err != nil {
+				return fmt.Errorf("cannot read unit: %w", err)
+			}
+			mm.Unit = unit
+		}
+	}
+	return nil
+}
+
+func appendLabelsFromRefs(dst []Label, refs []uint32, symbols []string) ([]Label, error) {
+	if len(refs)%2 != 0 {
+		return dst, fmt.Errorf("odd number of label refs: %d", len(refs))
+	}
+	for i := 0; i < len(refs); i += 2 {
+		name, err := getSymbol(symbols, refs[i])
+		if err != nil {
+			return dst, fmt.Errorf("cannot read label name: %w", err)
+		}
+		value, err := getSymbol(symbols, refs[i+1])
+		if err != nil {
+			return dst, fmt.Errorf("cannot read value for label %q: %w", name, err)
+		}
+		dst = append(dst, Label{
+			Name:  name,
+			Value: value,
+		})
+	}
+	return dst, nil
+}
+
+func getSymbol(symbols []string, ref uint32) (string, error) {
+	if uint64(ref) >= uint64(len(symbols)) {
+		return "", fmt.Errorf("symbol ref %d exceeds the symbols table size %d", ref, len(symbols))
+	}
+	return symbols[ref], nil
+}
@@ -0,0 +1,123 @@
+package prompb
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+func TestWriteRequestUnmarshalProtobufV2(t *testing.T) {
+	var m easyproto.Marshaler
+	mm := m.MessageMarshaler()
+
+	// Put timeseries before symbols in order to verify that the order of fields doesn't matter.
+	ts := mm.AppendMessage(5)
+	ts.AppendUint32s(1, []uint32{1, 2, 3, 4})
+	sample := ts.AppendMessage(2)
+	sample.AppendDouble(1, 10)
+	sample.AppendInt64(2, 1000)
+	sample = ts.AppendMessage(2)
+	sample.AppendDouble(1, 12)
+	sample.AppendInt64(2, 2000)
+	exemplar := ts.AppendMessage(4)
+	exemplar.AppendUint32s(1, []uint32{5, 6})
+	exemplar.AppendDouble(2, 0.5)
+	exemplar.AppendInt64(3, 1500)
+	metadata := ts.AppendMessage(5)
+	metadata.AppendUint32(1, uint32(MetricTypeCounter))
+	metadata.AppendUint32(3, 7)
+	metadata.AppendUint32(4, 8)
+	ts.AppendInt64(6, 500)
+
+	ts = mm.AppendMessage(5)
+	ts.AppendUint32s(1, []uint32{1, 9})
+	h := ts.AppendMessage(3)
+	h.AppendUint64(1, 3)
+	h.AppendDouble(3, 4.5)
+	span := h.AppendMessage(11)
+	span.AppendSint32(1, 1)
+	span.AppendUint32(2, 1)
+	h.AppendSint64s(12, []int64{3})
+	h.AppendInt64(15, 3000)
+
+	for _, symbol := range []string{"", "__name__", "foo_total", "job", "bar", "trace_id", "abc", "help text", "seconds", "hist"} {
+		mm.AppendString(4, symbol)
+	}
+	data := m.Marshal(nil)
+
+	var wr WriteRequest
+	if err := wr.UnmarshalProtobufV2(data); err != nil {
+		t.Fatalf("cannot unmarshal protobuf: %s", err)
+	}
+
+	var a []string
+	for _, ts := range wr.Timeseries {
+		labels := labelsString(ts.Labels)
+		for _, s := range ts.Samples {
+			a = append(a, fmt.Sprintf("%s %g %d", labels, s.Value, s.Timestamp))
+		}
+		for _, e := range ts.Exemplars {
+			a = append(a, fmt.Sprintf("exemplar %s %s %g %d", labels, labelsString(e.Labels), e.Value, e.Timestamp))
+		}
+	}
+	for _, mm := range wr.Metadata {
+		a = append(a, fmt.Sprintf("metadata %s %d %q %q", mm.MetricFamilyName, mm.Type, mm.Help, mm.Unit))
+	}
+	result := strings.Join(a, "\n")
+	resultExpected := `{__name__="foo_total",job="bar"} 10 1000
+{__name__="foo_total",job="bar"} 12 2000
+exemplar {__name__="foo_total",job="bar"} {trace_id="abc"} 0.5 1500
+{__name__="hist_count"} 3 3000
+{__name__="hist_sum"} 4.5 3000
+{__name__="hist_bucket",vmrange="1.000e+00...2.000e+00"} 3 3000
+metadata foo_total 1 "help text" "seconds"`
+	if result != resultExpected {
+		t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+	}
+
+	// Native histograms must be counted separately from samples before the conversion to ordinary samples.
+	statsExpected := WriteRequestStats{
+		Samples:    2,
+		Histograms: 1,
+		Exemplars:  1,
+	}
+	if wr.Stats != statsExpected {
+		t.Fatalf("unexpected stats; got %+v; want %+v", wr.Stats, statsExpected)
+	}
+}
+
+func TestWriteRequestUnmarshalProtobufV2Failure(t *testing.T) {
+	f := func(symbols []string, labelsRefs []uint32) {
+		t.Helper()
+
+		var m easyproto.Marshaler
+		mm := m.MessageMarshaler()
+		for _, symbol := range symbols {
+			mm.AppendString(4, symbol)
+		}
+		ts := mm.AppendMessage(5)
+		ts.AppendUint32s(1, labelsRefs)
+		data := m.Marshal(nil)
+
+		var wr WriteRequest
+		if err := wr.UnmarshalProtobufV2(data); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// odd number of label refs
+	f([]string{"", "foo", "bar"}, []uint32{1, 2, 1})
+
+	// label ref outside the symbols table
+	f([]string{"", "foo", "bar"}, []uint32{1, 3})
+}
+
+func labelsString(labels []Label) string {
+	a := make([]string, len(labels))
+	for i, label := range labels {
+		a[i] = fmt.Sprintf("%s=%q", label.Name, label.Value)
+	}
+	return "{" + strings.Join(a, ",") + "}"
+}
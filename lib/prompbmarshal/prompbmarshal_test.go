@@ -2,6 +2,7 @@ package prompbmarshal_test
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
@@ -36,6 +37,26 @@ func TestWriteRequestMarshalProtobuf(t *testing.T) {
 						Timestamp: 18939432423,
 					},
 				},
+				Exemplars: []prompbmarshal.Exemplar{
+					{
+						Labels: []prompbmarshal.Label{
+							{
+								Name:  "trace_id",
+								Value: "4bf92f3577b34da6a3ce929d0e0e4736",
+							},
+						},
+						Value:     0.25,
+						Timestamp: 18939432000,
+					},
+				},
+			},
+		},
+		Metadata: []prompbmarshal.MetricMetadata{
+			{
+				Type:             1,
+				MetricFamilyName: "process_cpu_seconds_total",
+				Help:             "Total user and system CPU time spent in seconds.",
+				Unit:             "seconds",
 			},
 		},
 	}
@@ -64,9 +85,33 @@ func TestWriteRequestMarshalProtobuf(t *testing.T) {
 				Timestamp: sample.Timestamp,
 			})
 		}
+		var exemplars []prompbmarshal.Exemplar
+		for _, e := range ts.Exemplars {
+			var exemplarLabels []prompbmarshal.Label
+			for _, label := range e.Labels {
+				exemplarLabels = append(exemplarLabels, prompbmarshal.Label{
+					Name:  label.Name,
+					Value: label.Value,
+				})
+			}
+			exemplars = append(exemplars, prompbmarshal.Exemplar{
+				Labels:    exemplarLabels,
+				Value:     e.Value,
+				Timestamp: e.Timestamp,
+			})
+		}
 		wrm.Timeseries = append(wrm.Timeseries, prompbmarshal.TimeSeries{
-			Labels:  labels,
-			Samples: samples,
+			Labels:    labels,
+			Samples:   samples,
+			Exemplars: exemplars,
+		})
+	}
+	for _, mm := range wr.Metadata {
+		wrm.Metadata = append(wrm.Metadata, prompbmarshal.MetricMetadata{
+			Type:             uint32(mm.Type),
+			MetricFamilyName: mm.MetricFamilyName,
+			Help:             mm.Help,
+			Unit:             mm.Unit,
 		})
 	}
 	dataResult := wrm.MarshalProtobuf(nil)
@@ -75,3 +120,131 @@ func TestWriteRequestMarshalProtobuf(t *testing.T) {
 		t.Fatalf("unexpected data obtained after marshaling\ngot\n%X\nwant\n%X", dataResult, data)
 	}
 }
+
+func TestWriteRequestMarshalProtobufV2(t *testing.T) {
+	wrm := &prompbmarshal.WriteRequest{
+		Timeseries: []prompbmarshal.TimeSeries{
+			{
+				Labels: []prompbmarshal.Label{
+					{
+						Name:  "__name__",
+						Value: "http_request_duration_seconds_bucket",
+					},
+					{
+						Name:  "le",
+						Value: "0.5",
+					},
+				},
+				Samples: []prompbmarshal.Sample{
+					{
+						Value:     12,
+						Timestamp: 8939432423,
+					},
+				},
+				Exemplars: []prompbmarshal.Exemplar{
+					{
+						Labels: []prompbmarshal.Label{
+							{
+								Name:  "trace_id",
+								Value: "4bf92f3577b34da6a3ce929d0e0e4736",
+							},
+						},
+						Value:     0.25,
+						Timestamp: 8939432000,
+					},
+				},
+			},
+			{
+				Labels: []prompbmarshal.Label{
+					{
+						Name:  "__name__",
+						Value: "up",
+					},
+				},
+				Samples: []prompbmarshal.Sample{
+					{
+						Value:     1,
+						Timestamp: 8939432423,
+					},
+				},
+			},
+		},
+		Metadata: []prompbmarshal.MetricMetadata{
+			{
+				Type:             uint32(prompb.MetricTypeHistogram),
+				MetricFamilyName: "http_request_duration_seconds",
+				Help:             "Request duration",
+				Unit:             "seconds",
+			},
+			{
+				// Metadata without time series must be dropped.
+				Type:             uint32(prompb.MetricTypeCounter),
+				MetricFamilyName: "missing_total",
+				Help:             "Missing counter",
+			},
+		},
+	}
+	data := wrm.MarshalProtobufV2(nil)
+
+	var wr prompb.WriteRequest
+	if err := wr.UnmarshalProtobufV2(data); err != nil {
+		t.Fatalf("cannot unmarshal protobuf: %s", err)
+	}
+	if len(wr.Timeseries) != len(wrm.Timeseries) {
+		t.Fatalf("unexpected number of time series; got %d; want %d", len(wr.Timeseries), len(wrm.Timeseries))
+	}
+	for i, ts := range wr.Timeseries {
+		tsm := &wrm.Timeseries[i]
+		var labels []prompbmarshal.Label
+		for _, label := range ts.Labels {
+			labels = append(labels, prompbmarshal.Label{
+				Name:  label.Name,
+				Value: label.Value,
+			})
+		}
+		if !reflect.DeepEqual(labels, tsm.Labels) {
+			t.Fatalf("unexpected labels for time series #%d\ngot\n%v\nwant\n%v", i, labels, tsm.Labels)
+		}
+		var samples []prompbmarshal.Sample
+		for _, sample := range ts.Samples {
+			samples = append(samples, prompbmarshal.Sample{
+				Value:     sample.Value,
+				Timestamp: sample.Timestamp,
+			})
+		}
+		if !reflect.DeepEqual(samples, tsm.Samples) {
+			t.Fatalf("unexpected samples for time series #%d\ngot\n%v\nwant\n%v", i, samples, tsm.Samples)
+		}
+		var exemplars []prompbmarshal.Exemplar
+		for _, e := range ts.Exemplars {
+			var exemplarLabels []prompbmarshal.Label
+			for _, label := range e.Labels {
+				exemplarLabels = append(exemplarLabels, prompbmarshal.Label{
+					Name:  label.Name,
+					Value: label.Value,
+				})
+			}
+			exemplars = append(exemplars, prompbmarshal.Exemplar{
+				Labels:    exemplarLabels,
+				Value:     e.Value,
+				Timestamp: e.Timestamp,
+			})
+		}
+		if !reflect.DeepEqual(exemplars, tsm.Exemplars) {
+			t.Fatalf("unexpected exemplars for time series #%d\ngot\n%v\nwant\n%v", i, exemplars, tsm.Exemplars)
+		}
+	}
+
+	// Remote write 2.0 carries metadata per time series, so the metric family name is taken from the series name.
+	metadataExpected := []prompb.MetricMetadata{
+		{
+			Type:             prompb.MetricTypeHistogram,
+			MetricFamilyName: "http_request_duration_seconds_bucket",
+			Help:             "Request duration",
+			Unit:             "seconds",
+		},
+	}
+	if !reflect.DeepEqual(wr.Metadata, metadataExpected) {
+		t.Fatalf("unexpected metadata\ngot\n%v\nwant\n%v", wr.Metadata, metadataExpected)
+	}
+}
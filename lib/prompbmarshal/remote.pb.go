@@ -9,10 +9,28 @@ import (
 
 type WriteRequest struct {
 	Timeseries []TimeSeries
+	Metadata   []MetricMetadata
+}
+
+type MetricMetadata struct {
+	Type             uint32
+	MetricFamilyName string
+	Help             string
+	Unit             string
 }
 
 func (m *WriteRequest) MarshalToSizedBuffer(dst []byte) (int, error) {
 	i := len(dst)
+	for j := len(m.Metadata) - 1; j >= 0; j-- {
+		size, err := m.Metadata[j].MarshalToSizedBuffer(dst[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarint(dst, i, uint64(size))
+		i--
+		dst[i] = 0x1a
+	}
 	for j := len(m.Timeseries) - 1; j >= 0; j-- {
 		size, err := m.Timeseries[j].MarshalToSizedBuffer(dst[:i])
 		if err != nil {
@@ -26,6 +44,37 @@ func (m *WriteRequest) MarshalToSizedBuffer(dst []byte) (int, error) {
 	return len(dst) - i, nil
 }
 
+func (m *MetricMetadata) MarshalToSizedBuffer(dst []byte) (int, error) {
+	i := len(dst)
+	if len(m.Unit) > 0 {
+		i -= len(m.Unit)
+		copy(dst[i:], m.Unit)
+		i = encodeVarint(dst, i, uint64(len(m.Unit)))
+		i--
+		dst[i] = 0x2a
+	}
+	if len(m.Help) > 0 {
+		i -= len(m.Help)
+		copy(dst[i:], m.Help)
+		i = encodeVarint(dst, i, uint64(len(m.Help)))
+		i--
+		dst[i] = 0x22
+	}
+	if len(m.MetricFamilyName) > 0 {
+		i -= len(m.MetricFamilyName)
+		copy(dst[i:], m.MetricFamilyName)
+		i = encodeVarint(dst, i, uint64(len(m.MetricFamilyName)))
+		i--
+		dst[i] = 0x12
+	}
+	if m.Type != 0 {
+		i = encodeVarint(dst, i, uint64(m.Type))
+		i--
+		dst[i] = 0x8
+	}
+	return len(dst) - i, nil
+}
+
 func encodeVarint(dst []byte, offset int, v uint64) int {
 	offset -= sov(v)
 	base := offset
@@ -45,6 +94,29 @@ func (m *WriteRequest) Size() (n int) {
 		l := e.Size()
 		n += 1 + l + sov(uint64(l))
 	}
+	for _, e := range m.Metadata {
+		l := e.Size()
+		n += 1 + l + sov(uint64(l))
+	}
+	return n
+}
+
+func (m *MetricMetadata) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	if m.Type != 0 {
+		n += 1 + sov(uint64(m.Type))
+	}
+	if l := len(m.MetricFamilyName); l > 0 {
+		n += 1 + l + sov(uint64(l))
+	}
+	if l := len(m.Help); l > 0 {
+		n += 1 + l + sov(uint64(l))
+	}
+	if l := len(m.Unit); l > 0 {
+		n += 1 + l + sov(uint64(l))
+	}
 	return n
 }
 
@@ -0,0 +1,182 @@
+package prompbmarshal
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// MarshalProtobufV2 marshals wr to dst in Prometheus remote write 2.0 format and returns the result.
+//
+// See https://prometheus.io/docs/specs/prw/remote_write_spec_2_0/
+//
+// Remote write 2.0 carries metadata per time series, so every entry in wr.Metadata is attached to the time series
+// from the corresponding metric family. Metadata without matching time series in wr isn't marshaled.
+func (wr *WriteRequest) MarshalProtobufV2(dst []byte) []byte {
+	ctx := getMarshalV2Ctx()
+	defer putMarshalV2Ctx(ctx)
+
+	for i := range wr.Metadata {
+		mm := &wr.Metadata[i]
+		ctx.metadata[mm.MetricFamilyName] = mm
+	}
+
+	// message Request {
+	//   repeated string symbols        = 4;
+	//   repeated TimeSeries timeseries = 5;
+	// }
+	//
+	// message TimeSeries {
+	//   repeated uint32 labels_refs  = 1;
+	//   repeated Sample samples      = 2;
+	//   repeated Exemplar exemplars  = 4;
+	//   Metadata metadata            = 5;
+	// }
+	//
+	// message Sample {
+	//   double value    = 1;
+	//   int64 timestamp = 2;
+	// }
+	//
+	// message Exemplar {
+	//   repeated uint32 label_refs = 1;
+	//   double value               = 2;
+	//   int64 timestamp            = 3;
+	// }
+	//
+	// message Metadata {
+	//   MetricType type = 1;
+	//   uint32 help_ref = 3;
+	//   uint32 unit_ref = 4;
+	// }
+	mm := ctx.m.MessageMarshaler()
+	for i := range wr.Timeseries {
+		ts := &wr.Timeseries[i]
+		ctx.refs = ctx.appendLabelRefs(ctx.refs[:0], ts.Labels)
+
+		tsm := mm.AppendMessage(5)
+		tsm.AppendUint32s(1, ctx.refs)
+		for _, s := range ts.Samples {
+			sm := tsm.AppendMessage(2)
+			sm.AppendDouble(1, s.Value)
+			sm.AppendInt64(2, s.Timestamp)
+		}
+		for j := range ts.Exemplars {
+			e := &ts.Exemplars[j]
+			ctx.refs = ctx.appendLabelRefs(ctx.refs[:0], e.Labels)
+
+			em := tsm.AppendMessage(4)
+			em.AppendUint32s(1, ctx.refs)
+			em.AppendDouble(2, e.Value)
+			em.AppendInt64(3, e.Timestamp)
+		}
+		if md := ctx.getMetadata(ts.Labels); md != nil {
+			mdm := tsm.AppendMessage(5)
+			mdm.AppendUint32(1, md.Type)
+			mdm.AppendUint32(3, ctx.getSymbolRef(md.Help))
+			mdm.AppendUint32(4, ctx.getSymbolRef(md.Unit))
+		}
+	}
+	for _, symbol := range ctx.symbols {
+		mm.AppendString(4, symbol)
+	}
+	return ctx.m.Marshal(dst)
+}
+
+type marshalV2Ctx struct {
+	m easyproto.Marshaler
+
+	symbols    []string
+	symbolRefs map[string]uint32
+	refs       []uint32
+
+	// metadata maps metric family names to their metadata.
+	metadata map[string]*MetricMetadata
+}
+
+func (ctx *marshalV2Ctx) reset() {
+	ctx.m.Reset()
+
+	clear(ctx.symbols)
+	ctx.symbols = ctx.symbols[:0]
+	clear(ctx.symbolRefs)
+	ctx.refs = ctx.refs[:0]
+	clear(ctx.metadata)
+}
+
+// appendLabelRefs appends symbol references for names and values of labels to dst and returns the result.
+func (ctx *marshalV2Ctx) appendLabelRefs(dst []uint32, labels []Label) []uint32 {
+	for _, label := range labels {
+		dst = append(dst, ctx.getSymbolRef(label.Name), ctx.getSymbolRef(label.Value))
+	}
+	return dst
+}
+
+// getMetadata returns metadata for the metric family of the time series with the given labels.
+//
+// It returns nil if there is no metadata for the time series.
+func (ctx *marshalV2Ctx) getMetadata(labels []Label) *MetricMetadata {
+	if len(ctx.metadata) == 0 {
+		return nil
+	}
+	metricName := ""
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			metricName = label.Value
+			break
+		}
+	}
+	if metricName == "" {
+		return nil
+	}
+	if md := ctx.metadata[metricName]; md != nil {
+		return md
+	}
+	// Time series for histograms, summaries, counters and info metrics have suffixes after the metric family name.
+	for _, suffix := range metricFamilySuffixes {
+		if familyName, ok := strings.CutSuffix(metricName, suffix); ok {
+			if md := ctx.metadata[familyName]; md != nil {
+				return md
+			}
+		}
+	}
+	return nil
+}
+
+var metricFamilySuffixes = []string{"_bucket", "_count", "_sum", "_total", "_created", "_info"}
+
+// getSymbolRef returns a reference to s in the symbols table.
+//
+// The first symbol in the table is always an empty string according to the spec.
+func (ctx *marshalV2Ctx) getSymbolRef(s string) uint32 {
+	if len(ctx.symbols) == 0 {
+		ctx.symbols = append(ctx.symbols, "")
+		ctx.symbolRefs[""] = 0
+	}
+	if ref, ok := ctx.symbolRefs[s]; ok {
+		return ref
+	}
+	ref := uint32(len(ctx.symbols))
+	ctx.symbols = append(ctx.symbols, s)
+	ctx.symbolRefs[s] = ref
+	return ref
+}
+
+func getMarshalV2Ctx() *marshalV2Ctx {
+	v := marshalV2CtxPool.Get()
+	if v == nil {
+		return &marshalV2Ctx{
+			symbolRefs: make(map[string]uint32),
+			metadata:   make(map[string]*MetricMetadata),
+		}
+	}
+	return v.(*marshalV2Ctx)
+}
+
+func putMarshalV2Ctx(ctx *marshalV2Ctx) {
+	ctx.reset()
+	marshalV2CtxPool.Put(ctx)
+}
+
+var marshalV2CtxPool sync.Pool
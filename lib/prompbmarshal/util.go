@@ -21,6 +21,9 @@ func (wr *WriteRequest) MarshalProtobuf(dst []byte) []byte {
 // Reset resets wr.
 func (wr *WriteRequest) Reset() {
 	wr.Timeseries = ResetTimeSeries(wr.Timeseries)
+
+	clear(wr.Metadata)
+	wr.Metadata = wr.Metadata[:0]
 }
 
 // ResetTimeSeries clears all the GC references from tss and returns an empty tss ready for further use.
@@ -7,15 +7,18 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/VictoriaMetrics/metrics"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/chunkedbuffer"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/netutil"
+	parser "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus"
 )
 
 var (
@@ -37,6 +40,7 @@ type client struct {
 	ctx                     context.Context
 	scrapeURL               string
 	scrapeTimeoutSecondsStr string
+	acceptHeader            string
 	setHeaders              func(req *http.Request) error
 	setProxyHeaders         func(req *http.Request) error
 	maxScrapeSize           int64
@@ -102,11 +106,17 @@ func newClient(ctx context.Context, sw *ScrapeWork) (*client, error) {
 		}
 	}
 
+	acceptHeader, err := getAcceptHeader(sw.ScrapeProtocols)
+	if err != nil {
+		return nil, err
+	}
+
 	c := &client{
 		c:                       hc,
 		ctx:                     ctx,
 		scrapeURL:               sw.ScrapeURL,
 		scrapeTimeoutSecondsStr: fmt.Sprintf("%.3f", sw.ScrapeTimeout.Seconds()),
+		acceptHeader:            acceptHeader,
 		setHeaders:              setHeaders,
 		setProxyHeaders:         setProxyHeaders,
 		maxScrapeSize:           sw.MaxScrapeSize,
@@ -124,12 +134,7 @@ func (c *client) ReadData(dst *chunkedbuffer.Buffer) (bool, error) {
 	if err != nil {
 		return false, fmt.Errorf("cannot create request for %q: %w", c.scrapeURL, err)
 	}
-	// The following `Accept` header has been copied from Prometheus sources.
-	// See https://github.com/prometheus/prometheus/blob/f9d21f10ecd2a343a381044f131ea4e46381ce09/scrape/scrape.go#L532 .
-	// This is needed as a workaround for scraping stupid Java-based servers such as Spring Boot.
-	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/608 for details.
-	// Do not bloat the `Accept` header with OpenMetrics shit, since it looks like dead standard now.
-	req.Header.Set("Accept", "text/plain;version=0.0.4;q=1,*/*;q=0.1")
+	req.Header.Set("Accept", c.acceptHeader)
 	// Set X-Prometheus-Scrape-Timeout-Seconds like Prometheus does, since it is used by some exporters such as PushProx.
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1179#issuecomment-813117162
 	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", c.scrapeTimeoutSecondsStr)
@@ -182,9 +187,90 @@ func (c *client) ReadData(dst *chunkedbuffer.Buffer) (bool, error) {
 	}
 
 	isGzipped := resp.Header.Get("Content-Encoding") == "gzip"
-	return isGzipped, nil
+	return convertToTextFormat(dst, isGzipped, resp.Header.Get("Content-Type"))
+}
+
+// defaultAcceptHeader is sent to scrape targets if `scrape_protocols` isn't set.
+//
+// The following `Accept` header has been copied from Prometheus sources.
+// See https://github.com/prometheus/prometheus/blob/f9d21f10ecd2a343a381044f131ea4e46381ce09/scrape/scrape.go#L532 .
+// This is needed as a workaround for scraping stupid Java-based servers such as Spring Boot.
+// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/608 for details.
+// Do not bloat the `Accept` header with OpenMetrics shit by default, since it looks like dead standard now.
+const defaultAcceptHeader = "text/plain;version=0.0.4;q=1,*/*;q=0.1"
+
+// scrapeProtocolHeaders contains `Accept` header values for the supported `scrape_protocols`.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#scrape_config
+var scrapeProtocolHeaders = map[string]string{
+	"PrometheusProto":      parser.ProtobufContentType,
+	"PrometheusText0.0.4":  "text/plain;version=0.0.4",
+	"PrometheusText1.0.0":  "text/plain;version=1.0.0",
+	"OpenMetricsText0.0.1": parser.OpenMetricsContentType + ";version=0.0.1",
+	"OpenMetricsText1.0.0": parser.OpenMetricsContentType + ";version=1.0.0",
 }
 
+// getAcceptHeader returns `Accept` header value for the given scrapeProtocols in the same way as Prometheus does.
+func getAcceptHeader(scrapeProtocols []string) (string, error) {
+	if len(scrapeProtocols) == 0 {
+		return defaultAcceptHeader, nil
+	}
+	weight := len(scrapeProtocolHeaders) + 1
+	var a []string
+	seen := make(map[string]bool, len(scrapeProtocols))
+	for _, sp := range scrapeProtocols {
+		h, ok := scrapeProtocolHeaders[sp]
+		if !ok {
+			return "", fmt.Errorf("unsupported scrape protocol %q; supported protocols: PrometheusProto, PrometheusText0.0.4, PrometheusText1.0.0, "+
+				"OpenMetricsText0.0.1, OpenMetricsText1.0.0", sp)
+		}
+		if seen[sp] {
+			return "", fmt.Errorf("duplicate scrape protocol %q", sp)
+		}
+		seen[sp] = true
+		a = append(a, h+";q=0."+strconv.Itoa(weight))
+		weight--
+	}
+	a = append(a, "*/*;q=0."+strconv.Itoa(weight))
+	return strings.Join(a, ","), nil
+}
+
+// convertToTextFormat converts the response in cb with the given contentType to Prometheus text exposition format.
+//
+// The response is left as is if it is already in Prometheus text exposition format.
+// The returned bool is set to true if the response in cb remains gzipped.
+func convertToTextFormat(cb *chunkedbuffer.Buffer, isGzipped bool, contentType string) (bool, error) {
+	mediaType, params, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	isProtobuf := mediaType == "application/vnd.google.protobuf" && strings.Contains(params, "io.prometheus.client.MetricFamily")
+	isOpenMetrics := mediaType == parser.OpenMetricsContentType
+	if !isProtobuf && !isOpenMetrics {
+		return isGzipped, nil
+	}
+
+	src := responseBufPool.Get()
+	defer responseBufPool.Put(src)
+	if err := readFromBuffer(src, cb, isGzipped); err != nil {
+		return false, err
+	}
+	dst := responseBufPool.Get()
+	defer responseBufPool.Put(dst)
+	if isProtobuf {
+		var err error
+		dst.B, err = parser.AppendTextFromProtobuf(dst.B[:0], src.B)
+		if err != nil {
+			return false, fmt.Errorf("cannot parse response in Prometheus protobuf format: %w", err)
+		}
+	} else {
+		dst.B = parser.AppendTextFromOpenMetrics(dst.B[:0], src.B)
+	}
+	cb.Reset()
+	cb.MustWrite(dst.B)
+	return false, nil
+}
+
+var responseBufPool bytesutil.ByteBufferPool
+
 var (
 	maxScrapeSizeExceeded = metrics.NewCounter(`vm_promscrape_max_scrape_size_exceeded_errors_total`)
 	scrapesTimedout       = metrics.NewCounter(`vm_promscrape_scrapes_timed_out_total`)
@@ -11,6 +11,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/VictoriaMetrics/easyproto"
+
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/chunkedbuffer"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
@@ -207,3 +209,74 @@ func TestClientProxyReadOk(t *testing.T) {
 	// backend tls and proxy auth
 	f(true, false, nil, &promauth.BasicAuthConfig{Username: "proxy-test", Password: promauth.NewSecret("1234")})
 }
+
+func TestGetAcceptHeaderSuccess(t *testing.T) {
+	f := func(scrapeProtocols []string, resultExpected string) {
+		t.Helper()
+		result, err := getAcceptHeader(scrapeProtocols)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f(nil, defaultAcceptHeader)
+	f([]string{"PrometheusText0.0.4"}, "text/plain;version=0.0.4;q=0.6,*/*;q=0.5")
+	f([]string{"PrometheusProto", "OpenMetricsText1.0.0", "PrometheusText0.0.4"}, "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.6,"+
+		"application/openmetrics-text;version=1.0.0;q=0.5,text/plain;version=0.0.4;q=0.4,*/*;q=0.3")
+}
+
+func TestGetAcceptHeaderFailure(t *testing.T) {
+	f := func(scrapeProtocols []string) {
+		t.Helper()
+		_, err := getAcceptHeader(scrapeProtocols)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// unknown protocol
+	f([]string{"foobar"})
+
+	// duplicate protocol
+	f([]string{"PrometheusProto", "PrometheusProto"})
+}
+
+func TestConvertToTextFormat(t *testing.T) {
+	f := func(contentType, data, resultExpected string) {
+		t.Helper()
+		var cb chunkedbuffer.Buffer
+		cb.MustWrite([]byte(data))
+		isGzipped, err := convertToTextFormat(&cb, false, contentType)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if isGzipped {
+			t.Fatalf("the response mustn't be gzipped")
+		}
+		result, err := io.ReadAll(cb.NewReader())
+		if err != nil {
+			t.Fatalf("cannot read result: %s", err)
+		}
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	// Prometheus text format
+	f("text/plain; version=0.0.4", "foo 1\n# EOF\n", "foo 1\n# EOF\n")
+	f("", "foo 1\n", "foo 1\n")
+
+	// OpenMetrics format
+	f("application/openmetrics-text; version=1.0.0; charset=utf-8", "# TYPE foo counter\nfoo_total 1\nfoo_created 2\n# EOF\n", "# TYPE foo counter\nfoo_total 1\n")
+
+	// Prometheus protobuf format
+	var m easyproto.Marshaler
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mm.AppendInt32(3, 1)
+	mm.AppendMessage(4).AppendMessage(2).AppendDouble(1, 42)
+	f("application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited", string(m.MarshalWithLen(nil)), "# TYPE foo gauge\nfoo 42\n")
+}
@@ -37,13 +37,20 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/gce"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/hetzner"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/http"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/ionos"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kubernetes"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kuma"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/lightsail"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/linode"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/marathon"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/nomad"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/openstack"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/ovhcloud"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/puppetdb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/scaleway"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/stackit"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/triton"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/uyuni"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/vultr"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/yandexcloud"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
@@ -116,8 +123,15 @@ type Config struct {
 	ScrapeConfigs     []*ScrapeConfig `yaml:"scrape_configs,omitempty"`
 	ScrapeConfigFiles []string        `yaml:"scrape_config_files,omitempty"`
 
+	KubernetesCRDSDConfigs []kubernetes.CRDSDConfig `yaml:"kubernetes_crd_sd_configs,omitempty"`
+
 	// This is set to the directory from where the config has been loaded.
 	baseDir string
+
+	// crdScrapeConfigsData contains scrape configs generated from KubernetesCRDSDConfigs.
+	//
+	// It is used for detecting changes in the discovered custom resources.
+	crdScrapeConfigsData []byte
 }
 
 func (cfg *Config) unmarshal(data []byte, isStrict bool) error {
@@ -270,6 +284,7 @@ type GlobalConfig struct {
 	ExternalLabels       *promutil.Labels            `yaml:"external_labels,omitempty"`
 	RelabelConfigs       []promrelabel.RelabelConfig `yaml:"relabel_configs,omitempty"`
 	MetricRelabelConfigs []promrelabel.RelabelConfig `yaml:"metric_relabel_configs,omitempty"`
+	ScrapeProtocols      []string                    `yaml:"scrape_protocols,omitempty"`
 }
 
 // ScrapeConfig represents essential parts for `scrape_config` section of Prometheus config.
@@ -296,6 +311,10 @@ type ScrapeConfig struct {
 	MetricRelabelConfigs []promrelabel.RelabelConfig `yaml:"metric_relabel_configs,omitempty"`
 	SampleLimit          int                         `yaml:"sample_limit,omitempty"`
 
+	// ScrapeProtocols contains protocols to negotiate with scrape targets in the order of preference.
+	// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#scrape_config
+	ScrapeProtocols []string `yaml:"scrape_protocols,omitempty"`
+
 	// This silly option is needed for compatibility with Prometheus.
 	// vmagent was supporting disable_compression option since the beginning, while Prometheus developers
 	// decided adding enable_compression option in https://github.com/prometheus/prometheus/pull/13166
@@ -315,14 +334,21 @@ type ScrapeConfig struct {
 	GCESDConfigs          []gce.SDConfig          `yaml:"gce_sd_configs,omitempty"`
 	HetznerSDConfigs      []hetzner.SDConfig      `yaml:"hetzner_sd_configs,omitempty"`
 	HTTPSDConfigs         []http.SDConfig         `yaml:"http_sd_configs,omitempty"`
+	IONOSSDConfigs        []ionos.SDConfig        `yaml:"ionos_sd_configs,omitempty"`
 	KubernetesSDConfigs   []kubernetes.SDConfig   `yaml:"kubernetes_sd_configs,omitempty"`
 	KumaSDConfigs         []kuma.SDConfig         `yaml:"kuma_sd_configs,omitempty"`
+	LightsailSDConfigs    []lightsail.SDConfig    `yaml:"lightsail_sd_configs,omitempty"`
+	LinodeSDConfigs       []linode.SDConfig       `yaml:"linode_sd_configs,omitempty"`
 	MarathonSDConfigs     []marathon.SDConfig     `yaml:"marathon_sd_configs,omitempty"`
 	NomadSDConfigs        []nomad.SDConfig        `yaml:"nomad_sd_configs,omitempty"`
 	OpenStackSDConfigs    []openstack.SDConfig    `yaml:"openstack_sd_configs,omitempty"`
 	OVHCloudSDConfigs     []ovhcloud.SDConfig     `yaml:"ovhcloud_sd_configs,omitempty"`
 	PuppetDBSDConfigs     []puppetdb.SDConfig     `yaml:"puppetdb_sd_configs,omitempty"`
+	ScalewaySDConfigs     []scaleway.SDConfig     `yaml:"scaleway_sd_configs,omitempty"`
+	StackitSDConfigs      []stackit.SDConfig      `yaml:"stackit_sd_configs,omitempty"`
 	StaticConfigs         []StaticConfig          `yaml:"static_configs,omitempty"`
+	TritonSDConfigs       []triton.SDConfig       `yaml:"triton_sd_configs,omitempty"`
+	UyuniSDConfigs        []uyuni.SDConfig        `yaml:"uyuni_sd_configs,omitempty"`
 	VultrSDConfigs        []vultr.SDConfig        `yaml:"vultr_configs,omitempty"`
 	YandexCloudSDConfigs  []yandexcloud.SDConfig  `yaml:"yandexcloud_sd_configs,omitempty"`
 
@@ -333,6 +359,9 @@ type ScrapeConfig struct {
 	ScrapeAlignInterval *promutil.Duration         `yaml:"scrape_align_interval,omitempty"`
 	ScrapeOffset        *promutil.Duration         `yaml:"scrape_offset,omitempty"`
 	SeriesLimit         *int                       `yaml:"series_limit,omitempty"`
+	SampleBudget        int                        `yaml:"sample_budget,omitempty"`
+	SeriesBudget        int                        `yaml:"series_budget,omitempty"`
+	MaxScrapeInterval   *promutil.Duration         `yaml:"max_scrape_interval,omitempty"`
 	NoStaleMarkers      *bool                      `yaml:"no_stale_markers,omitempty"`
 	ProxyClientConfig   promauth.ProxyClientConfig `yaml:",inline"`
 
@@ -392,12 +421,21 @@ func (sc *ScrapeConfig) mustStop() {
 	for i := range sc.HTTPSDConfigs {
 		sc.HTTPSDConfigs[i].MustStop()
 	}
+	for i := range sc.IONOSSDConfigs {
+		sc.IONOSSDConfigs[i].MustStop()
+	}
 	for i := range sc.KubernetesSDConfigs {
 		sc.KubernetesSDConfigs[i].MustStop()
 	}
 	for i := range sc.KumaSDConfigs {
 		sc.KumaSDConfigs[i].MustStop()
 	}
+	for i := range sc.LightsailSDConfigs {
+		sc.LightsailSDConfigs[i].MustStop()
+	}
+	for i := range sc.LinodeSDConfigs {
+		sc.LinodeSDConfigs[i].MustStop()
+	}
 	for i := range sc.NomadSDConfigs {
 		sc.NomadSDConfigs[i].MustStop()
 	}
@@ -410,6 +448,18 @@ func (sc *ScrapeConfig) mustStop() {
 	for i := range sc.PuppetDBSDConfigs {
 		sc.PuppetDBSDConfigs[i].MustStop()
 	}
+	for i := range sc.ScalewaySDConfigs {
+		sc.ScalewaySDConfigs[i].MustStop()
+	}
+	for i := range sc.StackitSDConfigs {
+		sc.StackitSDConfigs[i].MustStop()
+	}
+	for i := range sc.TritonSDConfigs {
+		sc.TritonSDConfigs[i].MustStop()
+	}
+	for i := range sc.UyuniSDConfigs {
+		sc.UyuniSDConfigs[i].MustStop()
+	}
 	for i := range sc.VultrSDConfigs {
 		sc.VultrSDConfigs[i].MustStop()
 	}
@@ -542,6 +592,19 @@ func (cfg *Config) parseData(data []byte, path string) error {
 		m[jobName] = struct{}{}
 	}
 
+	// Generate scrape configs from Kubernetes custom resources discovered via cfg.KubernetesCRDSDConfigs
+	crdScrapeConfigs := cfg.getKubernetesCRDScrapeConfigs()
+	cfg.crdScrapeConfigsData = marshalScrapeConfigsJSON(crdScrapeConfigs)
+	for _, sc := range crdScrapeConfigs {
+		jobName := sc.JobName
+		if _, ok := m[jobName]; ok {
+			logger.Errorf("skipping `scrape_config` generated from Kubernetes custom resource, since it has duplicate `job_name`: %q", jobName)
+			continue
+		}
+		m[jobName] = struct{}{}
+		cfg.ScrapeConfigs = append(cfg.ScrapeConfigs, sc)
+	}
+
 	// Initialize cfg.ScrapeConfigs
 	validScrapeConfigs := cfg.ScrapeConfigs[:0]
 	for _, sc := range cfg.ScrapeConfigs {
@@ -715,6 +778,16 @@ func (cfg *Config) getHTTPDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	return cfg.getScrapeWorkGeneric(visitConfigs, "http_sd_config", prev)
 }
 
+// getIONOSSDScrapeWork returns `ionos_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getIONOSSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.IONOSSDConfigs {
+			visitor(&sc.IONOSSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "ionos_sd_config", prev)
+}
+
 // getKubernetesSDScrapeWork returns `kubernetes_sd_configs` ScrapeWork from cfg.
 func (cfg *Config) getKubernetesSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	const discoveryType = "kubernetes_sd_config"
@@ -753,6 +826,26 @@ func (cfg *Config) getKumaSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	return cfg.getScrapeWorkGeneric(visitConfigs, "kuma_sd_config", prev)
 }
 
+// getLightsailSDScrapeWork returns `lightsail_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getLightsailSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.LightsailSDConfigs {
+			visitor(&sc.LightsailSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "lightsail_sd_config", prev)
+}
+
+// getLinodeSDScrapeWork returns `linode_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getLinodeSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.LinodeSDConfigs {
+			visitor(&sc.LinodeSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "linode_sd_config", prev)
+}
+
 // getMarathonSDScrapeWork returns `marathon_sd_configs` ScrapeWork from cfg.
 func (cfg *Config) getMarathonSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
@@ -803,6 +896,46 @@ func (cfg *Config) getPuppetDBSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	return cfg.getScrapeWorkGeneric(visitConfigs, "puppetdb_sd_config", prev)
 }
 
+// getScalewaySDScrapeWork returns `scaleway_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getScalewaySDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.ScalewaySDConfigs {
+			visitor(&sc.ScalewaySDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "scaleway_sd_config", prev)
+}
+
+// getStackitSDScrapeWork returns `stackit_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getStackitSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.StackitSDConfigs {
+			visitor(&sc.StackitSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "stackit_sd_config", prev)
+}
+
+// getTritonSDScrapeWork returns `triton_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getTritonSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.TritonSDConfigs {
+			visitor(&sc.TritonSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "triton_sd_config", prev)
+}
+
+// getUyuniSDScrapeWork returns `uyuni_sd_configs` ScrapeWork from cfg.
+func (cfg *Config) getUyuniSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
+	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
+		for i := range sc.UyuniSDConfigs {
+			visitor(&sc.UyuniSDConfigs[i])
+		}
+	}
+	return cfg.getScrapeWorkGeneric(visitConfigs, "uyuni_sd_config", prev)
+}
+
 // getVultrSDScrapeWork returns `vultr_sd_configs` ScrapeWork from cfg.
 func (cfg *Config) getVultrSDScrapeWork(prev []*ScrapeWork) []*ScrapeWork {
 	visitConfigs := func(sc *ScrapeConfig, visitor func(sdc targetLabelsGetter)) {
@@ -898,6 +1031,13 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 		// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1281#issuecomment-840538907
 		scrapeTimeout = scrapeInterval
 	}
+	maxScrapeInterval := sc.MaxScrapeInterval.Duration()
+	if maxScrapeInterval > 0 && maxScrapeInterval < scrapeInterval {
+		return nil, fmt.Errorf("`max_scrape_interval`=%s for `job_name` %q cannot be smaller than `scrape_interval`=%s", maxScrapeInterval, jobName, scrapeInterval)
+	}
+	if maxScrapeInterval <= 0 && (sc.SampleBudget > 0 || sc.SeriesBudget > 0) {
+		maxScrapeInterval = defaultMaxScrapeIntervalMultiplier * scrapeInterval
+	}
 	mss := maxScrapeSize.N
 	if sc.MaxScrapeSize != "" {
 		n, err := flagutil.ParseBytes(sc.MaxScrapeSize)
@@ -967,6 +1107,13 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 	if sc.EnableCompression != nil {
 		disableCompression = !*sc.EnableCompression
 	}
+	scrapeProtocols := sc.ScrapeProtocols
+	if len(scrapeProtocols) == 0 {
+		scrapeProtocols = globalCfg.ScrapeProtocols
+	}
+	if _, err := getAcceptHeader(scrapeProtocols); err != nil {
+		return nil, fmt.Errorf("cannot parse `scrape_protocols` for `job_name` %q: %w", jobName, err)
+	}
 	swc := &scrapeWorkConfig{
 		scrapeInterval:       scrapeInterval,
 		scrapeIntervalString: scrapeInterval.String(),
@@ -993,7 +1140,11 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 		scrapeAlignInterval:  sc.ScrapeAlignInterval.Duration(),
 		scrapeOffset:         sc.ScrapeOffset.Duration(),
 		seriesLimit:          seriesLimit,
+		sampleBudget:         sc.SampleBudget,
+		seriesBudget:         sc.SeriesBudget,
+		maxScrapeInterval:    maxScrapeInterval,
 		noStaleMarkers:       noStaleTracking,
+		scrapeProtocols:      scrapeProtocols,
 	}
 	return swc, nil
 }
@@ -1024,7 +1175,11 @@ type scrapeWorkConfig struct {
 	scrapeAlignInterval  time.Duration
 	scrapeOffset         time.Duration
 	seriesLimit          int
+	sampleBudget         int
+	seriesBudget         int
+	maxScrapeInterval    time.Duration
 	noStaleMarkers       bool
+	scrapeProtocols      []string
 }
 
 func appendScrapeWorkForTargetLabels(dst []*ScrapeWork, swc *scrapeWorkConfig, targetLabels []*promutil.Labels, discoveryType string) []*ScrapeWork {
@@ -1235,6 +1390,13 @@ func (swc *scrapeWorkConfig) getScrapeWork(target string, extraLabels, metaLabel
 		}
 		scrapeTimeout = d
 	}
+	// The scrape interval cannot be lengthened above max_scrape_interval.
+	// Make sure it isn't smaller than the scrape interval overridden via __scrape_interval__ label.
+	// See https://docs.victoriametrics.com/victoriametrics/vmagent/#adaptive-scrape-interval
+	maxScrapeInterval := swc.maxScrapeInterval
+	if maxScrapeInterval > 0 && maxScrapeInterval < scrapeInterval {
+		maxScrapeInterval = scrapeInterval
+	}
 	// Read series_limit option from __series_limit__ label.
 	// See https://docs.victoriametrics.com/victoriametrics/vmagent/#cardinality-limiter
 	seriesLimit := swc.seriesLimit
@@ -1307,7 +1469,11 @@ func (swc *scrapeWorkConfig) getScrapeWork(target string, extraLabels, metaLabel
 		ScrapeAlignInterval:  swc.scrapeAlignInterval,
 		ScrapeOffset:         swc.scrapeOffset,
 		SeriesLimit:          seriesLimit,
+		SampleBudget:         swc.sampleBudget,
+		SeriesBudget:         swc.seriesBudget,
+		MaxScrapeInterval:    maxScrapeInterval,
 		NoStaleMarkers:       swc.noStaleMarkers,
+		ScrapeProtocols:      swc.scrapeProtocols,
 		AuthToken:            at,
 
 		jobNameOriginal: swc.jobName,
@@ -1352,4 +1518,8 @@ func mergeLabels(dst *promutil.Labels, swc *scrapeWorkConfig, target string, ext
 const (
 	defaultScrapeInterval = time.Minute
 	defaultScrapeTimeout  = 10 * time.Second
+
+	// defaultMaxScrapeIntervalMultiplier is used for calculating max_scrape_interval
+	// when sample_budget or series_budget is set without max_scrape_interval.
+	defaultMaxScrapeIntervalMultiplier = 4
 )
@@ -0,0 +1,450 @@
+package promscrape
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/metricsql"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kubernetes"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// getKubernetesCRDScrapeConfigs returns scrape configs generated from ServiceMonitor, PodMonitor and Probe custom resources
+// discovered via `kubernetes_crd_sd_configs` at cfg.
+//
+// The conversion follows prometheus-operator rules, so the generated scrape configs produce the same targets and labels.
+func (cfg *Config) getKubernetesCRDScrapeConfigs() []*ScrapeConfig {
+	var scs []*ScrapeConfig
+	for i := range cfg.KubernetesCRDSDConfigs {
+		crdc := &cfg.KubernetesCRDSDConfigs[i]
+		cos, err := crdc.GetObjects(cfg.baseDir)
+		if err != nil {
+			logger.Errorf("skipping `kubernetes_crd_sd_config` #%d because of error: %s", i+1, err)
+			continue
+		}
+		scs = append(scs, getCRDScrapeConfigs(crdc, cos)...)
+	}
+	return scs
+}
+
+// needReloadKubernetesCRDs returns true if scrape configs generated from `kubernetes_crd_sd_configs` at cfg became outdated.
+func (cfg *Config) needReloadKubernetesCRDs() bool {
+	if len(cfg.KubernetesCRDSDConfigs) == 0 {
+		return false
+	}
+	scs := cfg.getKubernetesCRDScrapeConfigs()
+	return string(marshalScrapeConfigsJSON(scs)) != string(cfg.crdScrapeConfigsData)
+}
+
+func marshalScrapeConfigsJSON(scs []*ScrapeConfig) []byte {
+	var b []byte
+	for _, sc := range scs {
+		b = append(b, sc.marshalJSON()...)
+		b = append(b, '\n')
+	}
+	return b
+}
+
+func getCRDScrapeConfigs(crdc *kubernetes.CRDSDConfig, cos *kubernetes.CRDObjects) []*ScrapeConfig {
+	var scs []*ScrapeConfig
+	for _, sm := range cos.ServiceMonitors {
+		for i := range sm.Spec.Endpoints {
+			sc, err := newServiceMonitorScrapeConfig(crdc, sm, i)
+			if err != nil {
+				logger.Errorf("skipping endpoint #%d at ServiceMonitor %s/%s because of error: %s", i, sm.Metadata.Namespace, sm.Metadata.Name, err)
+				continue
+			}
+			checkCRDFileAccess(crdc, sc, fmt.Sprintf("endpoint #%d at ServiceMonitor %s/%s", i, sm.Metadata.Namespace, sm.Metadata.Name))
+			scs = append(scs, sc)
+		}
+	}
+	for _, pm := range cos.PodMonitors {
+		for i := range pm.Spec.PodMetricsEndpoints {
+			sc, err := newPodMonitorScrapeConfig(crdc, pm, i)
+			if err != nil {
+				logger.Errorf("skipping podMetricsEndpoint #%d at PodMonitor %s/%s because of error: %s", i, pm.Metadata.Namespace, pm.Metadata.Name, err)
+				continue
+			}
+			checkCRDFileAccess(crdc, sc, fmt.Sprintf("podMetricsEndpoint #%d at PodMonitor %s/%s", i, pm.Metadata.Namespace, pm.Metadata.Name))
+			scs = append(scs, sc)
+		}
+	}
+	for _, p := range cos.Probes {
+		sc, err := newProbeScrapeConfig(p)
+		if err != nil {
+			logger.Errorf("skipping Probe %s/%s because of error: %s", p.Metadata.Namespace, p.Metadata.Name, err)
+			continue
+		}
+		checkCRDFileAccess(crdc, sc, fmt.Sprintf("Probe %s/%s", p.Metadata.Namespace, p.Metadata.Name))
+		scs = append(scs, sc)
+	}
+	return scs
+}
+
+// checkCRDFileAccess removes references to local files from sc generated from the custom resource with the given description
+// unless crdc allows them.
+//
+// Custom resources may be created by anyone with access to the watched namespaces, so by default they mustn't be able
+// to make vmagent send local files such as its own service account token to targets, which may be controlled by the custom resource author.
+// This is the same as prometheus-operator does with `arbitraryFSAccessThroughSMs.deny`.
+func checkCRDFileAccess(crdc *kubernetes.CRDSDConfig, sc *ScrapeConfig, crDesc string) {
+	if crdc.AllowFileAccess {
+		return
+	}
+	var options []string
+	hc := &sc.HTTPClientConfig
+	if hc.BearerTokenFile != "" {
+		options = append(options, "bearerTokenFile")
+		hc.BearerTokenFile = ""
+	}
+	if tc := hc.TLSConfig; tc != nil {
+		if tc.CAFile != "" {
+			options = append(options, "tlsConfig.caFile")
+			tc.CAFile = ""
+		}
+		if tc.CertFile != "" {
+			options = append(options, "tlsConfig.certFile")
+			tc.CertFile = ""
+		}
+		if tc.KeyFile != "" {
+			options = append(options, "tlsConfig.keyFile")
+			tc.KeyFile = ""
+		}
+	}
+	if len(options) > 0 {
+		logger.Warnf("ignoring %s at %s, since access to local files is disabled; set `allow_file_access: true` at `kubernetes_crd_sd_configs` in order to allow it",
+			strings.Join(options, ", "), crDesc)
+	}
+}
+
+func newServiceMonitorScrapeConfig(crdc *kubernetes.CRDSDConfig, sm *kubernetes.ServiceMonitor, endpointIdx int) (*ScrapeConfig, error) {
+	ep := &sm.Spec.Endpoints[endpointIdx]
+	sc := &ScrapeConfig{
+		JobName:     fmt.Sprintf("serviceMonitor/%s/%s/%d", sm.Metadata.Namespace, sm.Metadata.Name, endpointIdx),
+		SampleLimit: sm.Spec.SampleLimit,
+	}
+	if err := applyMonitorEndpoint(sc, ep); err != nil {
+		return nil, err
+	}
+	namespaces := getCRDNamespaces(&sm.Spec.NamespaceSelector, sm.Metadata.Namespace)
+	sc.KubernetesSDConfigs = []kubernetes.SDConfig{
+		crdc.NewSDConfig("endpoints", namespaces),
+	}
+
+	var rcs []promrelabel.RelabelConfig
+	rcs = appendLabelSelectorRelabelConfigs(rcs, &sm.Spec.Selector, "__meta_kubernetes_service")
+	switch {
+	case ep.Port != "":
+		rcs = append(rcs, newKeepRelabelConfig("__meta_kubernetes_endpoint_port_name", regexp.QuoteMeta(ep.Port)))
+	case ep.TargetPort != nil && ep.TargetPort.IsInt:
+		rcs = append(rcs, newKeepRelabelConfig("__meta_kubernetes_pod_container_port_number", strconv.Itoa(ep.TargetPort.IntVal)))
+	case ep.TargetPort != nil:
+		rcs = append(rcs, newKeepRelabelConfig("__meta_kubernetes_pod_container_port_name", regexp.QuoteMeta(ep.TargetPort.StrVal)))
+	}
+	rcs = append(rcs,
+		newReplaceRelabelConfig("__meta_kubernetes_namespace", "namespace"),
+		newReplaceRelabelConfig("__meta_kubernetes_service_name", "service"),
+		newReplaceRelabelConfig("__meta_kubernetes_pod_name", "pod"),
+		newReplaceRelabelConfig("__meta_kubernetes_pod_container_name", "container"),
+	)
+	for _, name := range sm.Spec.TargetLabels {
+		rcs = append(rcs, newReplaceRelabelConfig("__meta_kubernetes_service_label_"+discoveryutil.SanitizeLabelName(name), discoveryutil.SanitizeLabelName(name)))
+	}
+	for _, name := range sm.Spec.PodTargetLabels {
+		rcs = append(rcs, newReplaceRelabelConfig("__meta_kubernetes_pod_label_"+discoveryutil.SanitizeLabelName(name), discoveryutil.SanitizeLabelName(name)))
+	}
+	rcs = append(rcs, newReplaceRelabelConfig("__meta_kubernetes_service_name", "job"))
+	if sm.Spec.JobLabel != "" {
+		rcs = append(rcs, newReplaceRelabelConfig("__meta_kubernetes_service_label_"+discoveryutil.SanitizeLabelName(sm.Spec.JobLabel), "job"))
+	}
+	rcs = appendEndpointLabelRelabelConfig(rcs, ep)
+	rcs = append(rcs, convertCRDRelabelConfigs(ep.Relabelings)...)
+	sc.RelabelConfigs = rcs
+	return sc, nil
+}
+
+func newPodMonitorScrapeConfig(crdc *kubernetes.CRDSDConfig, pm *kubernetes.PodMonitor, endpointIdx int) (*ScrapeConfig, error) {
+	ep := &pm.Spec.PodMetricsEndpoints[endpointIdx]
+	sc := &ScrapeConfig{
+		JobName:     fmt.Sprintf("podMonitor/%s/%s/%d", pm.Metadata.Namespace, pm.Metadata.Name, endpointIdx),
+		SampleLimit: pm.Spec.SampleLimit,
+	}
+	if err := applyMonitorEndpoint(sc, ep); err != nil {
+		return nil, err
+	}
+	namespaces := getCRDNamespaces(&pm.Spec.NamespaceSelector, pm.Metadata.Namespace)
+	sc.KubernetesSDConfigs = []kubernetes.SDConfig{
+		crdc.NewSDConfig("pod", namespaces),
+	}
+
+	var rcs []promrelabel.RelabelConfig
+	// Pods in terminal state cannot be scraped.
+	rcs = append(rcs, promrelabel.RelabelConfig{
+		Action:       "drop",
+		SourceLabels: []string{"__meta_kubernetes_pod_phase"},
+		Regex:        &promrelabel.MultiLineRegex{S: "(Failed|Succeeded)"},
+	})
+	rcs = appendLabelSelectorRelabelConfigs(rcs, &pm.Spec.Selector, "__meta_kubernetes_pod")
+	switch {
+	case ep.Port != "":
+		rcs = append(rcs, newKeepRelabelConfig("__meta_kubernetes_pod_container_port_name", regexp.QuoteMeta(ep.Port)))
+	case ep.PortNumber > 0:
+		rcs = append(rcs, newKeepRelabelConfig("__meta_kubernetes_pod_container_port_number", strconv.Itoa(ep.PortNumber)))
+	case ep.TargetPort != nil && ep.TargetPort.IsInt:
+		rcs = append(rcs, newKeepRelabelConfig("__meta_kubernetes_pod_container_port_number", strconv.Itoa(ep.TargetPort.IntVal)))
+	case ep.TargetPort != nil:
+		rcs = append(rcs, newKeepRelabelConfig("__meta_kubernetes_pod_container_port_name", regexp.QuoteMeta(ep.TargetPort.StrVal)))
+	}
+	rcs = append(rcs,
+		newReplaceRelabelConfig("__meta_kubernetes_namespace", "namespace"),
+		newReplaceRelabelConfig("__meta_kubernetes_pod_container_name", "container"),
+		newReplaceRelabelConfig("__meta_kubernetes_pod_name", "pod"),
+	)
+	for _, name := range pm.Spec.PodTargetLabels {
+		rcs = append(rcs, newReplaceRelabelConfig("__meta_kubernetes_pod_label_"+discoveryutil.SanitizeLabelName(name), discoveryutil.SanitizeLabelName(name)))
+	}
+	jobName := pm.Metadata.Namespace + "/" + pm.Metadata.Name
+	rcs = append(rcs, promrelabel.RelabelConfig{
+		TargetLabel: "job",
+		Replacement: &jobName,
+	})
+	if pm.Spec.JobLabel != "" {
+		rcs = append(rcs, newReplaceRelabelConfig("__meta_kubernetes_pod_label_"+discoveryutil.SanitizeLabelName(pm.Spec.JobLabel), "job"))
+	}
+	rcs = appendEndpointLabelRelabelConfig(rcs, ep)
+	rcs = append(rcs, convertCRDRelabelConfigs(ep.Relabelings)...)
+	sc.RelabelConfigs = rcs
+	return sc, nil
+}
+
+func newProbeScrapeConfig(p *kubernetes.Probe) (*ScrapeConfig, error) {
+	spec := &p.Spec
+	if spec.ProberSpec.URL == "" {
+		return nil, fmt.Errorf("missing `prober.url`")
+	}
+	stc := spec.Targets.StaticConfig
+	if stc == nil || len(stc.Targets) == 0 {
+		return nil, fmt.Errorf("missing `targets.staticConfig.static`; only static targets are supported")
+	}
+	sc := &ScrapeConfig{
+		JobName:     fmt.Sprintf("probe/%s/%s", p.Metadata.Namespace, p.Metadata.Name),
+		MetricsPath: spec.ProberSpec.Path,
+		Scheme:      spec.ProberSpec.Scheme,
+		SampleLimit: spec.SampleLimit,
+	}
+	if sc.MetricsPath == "" {
+		sc.MetricsPath = "/probe"
+	}
+	if spec.Module != "" {
+		sc.Params = map[string][]string{
+			"module": {spec.Module},
+		}
+	}
+	var err error
+	if sc.ScrapeInterval, err = parseCRDDuration(spec.Interval); err != nil {
+		return nil, fmt.Errorf("cannot parse `interval`: %w", err)
+	}
+	if sc.ScrapeTimeout, err = parseCRDDuration(spec.ScrapeTimeout); err != nil {
+		return nil, fmt.Errorf("cannot parse `scrapeTimeout`: %w", err)
+	}
+	sc.HTTPClientConfig.BearerTokenFile = spec.BearerTokenFile
+	sc.HTTPClientConfig.TLSConfig = convertCRDTLSConfig(spec.TLSConfig)
+
+	var labels *promutil.Labels
+	if len(stc.Labels) > 0 {
+		labels = promutil.NewLabelsFromMap(stc.Labels)
+		labels.Sort()
+	}
+	sc.StaticConfigs = []StaticConfig{
+		{
+			Targets: stc.Targets,
+			Labels:  labels,
+		},
+	}
+	proberURL := spec.ProberSpec.URL
+	rcs := []promrelabel.RelabelConfig{
+		newReplaceRelabelConfig("__address__", "__param_target"),
+		newReplaceRelabelConfig("__param_target", "instance"),
+		{
+			TargetLabel: "__address__",
+			Replacement: &proberURL,
+		},
+	}
+	if spec.JobName != "" {
+		jobName := spec.JobName
+		rcs = append(rcs, promrelabel.RelabelConfig{
+			TargetLabel: "job",
+			Replacement: &jobName,
+		})
+	}
+	rcs = append(rcs, convertCRDRelabelConfigs(stc.RelabelingConfigs)...)
+	sc.RelabelConfigs = rcs
+	sc.MetricRelabelConfigs = convertCRDRelabelConfigs(spec.MetricRelabelings)
+	return sc, nil
+}
+
+// applyMonitorEndpoint applies scrape options from ep to sc.
+func applyMonitorEndpoint(sc *ScrapeConfig, ep *kubernetes.MonitorEndpoint) error {
+	var err error
+	if sc.ScrapeInterval, err = parseCRDDuration(ep.Interval); err != nil {
+		return fmt.Errorf("cannot parse `interval`: %w", err)
+	}
+	if sc.ScrapeTimeout, err = parseCRDDuration(ep.ScrapeTimeout); err != nil {
+		return fmt.Errorf("cannot parse `scrapeTimeout`: %w", err)
+	}
+	sc.MetricsPath = ep.Path
+	sc.Scheme = ep.Scheme
+	sc.Params = ep.Params
+	sc.HonorLabels = ep.HonorLabels
+	if ep.HonorTimestamps != nil {
+		sc.HonorTimestamps = *ep.HonorTimestamps
+	}
+	sc.HTTPClientConfig.BearerTokenFile = ep.BearerTokenFile
+	sc.HTTPClientConfig.TLSConfig = convertCRDTLSConfig(ep.TLSConfig)
+	sc.HTTPClientConfig.FollowRedirects = ep.FollowRedirects
+	sc.MetricRelabelConfigs = convertCRDRelabelConfigs(ep.MetricRelabelings)
+	return nil
+}
+
+func parseCRDDuration(s string) (*promutil.Duration, error) {
+	if s == "" {
+		return nil, nil
+	}
+	ms, err := metricsql.DurationValue(s, 0)
+	if err != nil {
+		return nil, err
+	}
+	return promutil.NewDuration(time.Duration(ms) * time.Millisecond), nil
+}
+
+func convertCRDTLSConfig(tc *kubernetes.MonitorTLSConfig) *promauth.TLSConfig {
+	if tc == nil {
+		return nil
+	}
+	return &promauth.TLSConfig{
+		CAFile:             tc.CAFile,
+		CertFile:           tc.CertFile,
+		KeyFile:            tc.KeyFile,
+		ServerName:         tc.ServerName,
+		InsecureSkipVerify: tc.InsecureSkipVerify,
+	}
+}
+
+func convertCRDRelabelConfigs(mrcs []kubernetes.MonitorRelabelConfig) []promrelabel.RelabelConfig {
+	if len(mrcs) == 0 {
+		return nil
+	}
+	rcs := make([]promrelabel.RelabelConfig, len(mrcs))
+	for i, mrc := range mrcs {
+		rc := &rcs[i]
+		rc.Action = strings.ToLower(mrc.Action)
+		rc.SourceLabels = mrc.SourceLabels
+		rc.Separator = mrc.Separator
+		rc.TargetLabel = mrc.TargetLabel
+		if mrc.Regex != "" {
+			rc.Regex = &promrelabel.MultiLineRegex{S: mrc.Regex}
+		}
+		rc.Modulus = mrc.Modulus
+		rc.Replacement = mrc.Replacement
+	}
+	return rcs
+}
+
+// getCRDNamespaces returns namespaces to discover targets from according to nsSelector for the custom resource at the given namespace.
+func getCRDNamespaces(nsSelector *kubernetes.NamespaceSelector, namespace string) []string {
+	if nsSelector.Any {
+		return nil
+	}
+	if len(nsSelector.MatchNames) > 0 {
+		return nsSelector.MatchNames
+	}
+	return []string{namespace}
+}
+
+// appendLabelSelectorRelabelConfigs appends relabel configs for filtering targets by ls
+// applied to Kubernetes labels with the given metaPrefix to dst.
+func appendLabelSelectorRelabelConfigs(dst []promrelabel.RelabelConfig, ls *kubernetes.LabelSelector, metaPrefix string) []promrelabel.RelabelConfig {
+	keys := make([]string, 0, len(ls.MatchLabels))
+	for k := range ls.MatchLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		name := discoveryutil.SanitizeLabelName(k)
+		dst = append(dst, promrelabel.RelabelConfig{
+			Action:       "keep",
+			SourceLabels: []string{metaPrefix + "_label_" + name, metaPrefix + "_labelpresent_" + name},
+			Regex:        &promrelabel.MultiLineRegex{S: "(" + regexp.QuoteMeta(ls.MatchLabels[k]) + ");true"},
+		})
+	}
+	for _, e := range ls.MatchExpressions {
+		name := discoveryutil.SanitizeLabelName(e.Key)
+		values := make([]string, len(e.Values))
+		for i, v := range e.Values {
+			values[i] = regexp.QuoteMeta(v)
+		}
+		valuesRegex := "(" + strings.Join(values, "|") + ");true"
+		switch e.Operator {
+		case "In":
+			dst = append(dst, promrelabel.RelabelConfig{
+				Action:       "keep",
+				SourceLabels: []string{metaPrefix + "_label_" + name, metaPrefix + "_labelpresent_" + name},
+				Regex:        &promrelabel.MultiLineRegex{S: valuesRegex},
+			})
+		case "NotIn":
+			dst = append(dst, promrelabel.RelabelConfig{
+				Action:       "drop",
+				SourceLabels: []string{metaPrefix + "_label_" + name, metaPrefix + "_labelpresent_" + name},
+				Regex:        &promrelabel.MultiLineRegex{S: valuesRegex},
+			})
+		case "Exists":
+			dst = append(dst, newKeepRelabelConfig(metaPrefix+"_labelpresent_"+name, "true"))
+		case "DoesNotExist":
+			dst = append(dst, promrelabel.RelabelConfig{
+				Action:       "drop",
+				SourceLabels: []string{metaPrefix + "_labelpresent_" + name},
+				Regex:        &promrelabel.MultiLineRegex{S: "true"},
+			})
+		}
+	}
+	return dst
+}
+
+func appendEndpointLabelRelabelConfig(dst []promrelabel.RelabelConfig, ep *kubernetes.MonitorEndpoint) []promrelabel.RelabelConfig {
+	endpoint := ep.Port
+	if endpoint == "" && ep.TargetPort != nil {
+		endpoint = ep.TargetPort.String()
+	}
+	if endpoint == "" {
+		return dst
+	}
+	return append(dst, promrelabel.RelabelConfig{
+		TargetLabel: "endpoint",
+		Replacement: &endpoint,
+	})
+}
+
+func newKeepRelabelConfig(sourceLabel, regex string) promrelabel.RelabelConfig {
+	return promrelabel.RelabelConfig{
+		Action:       "keep",
+		SourceLabels: []string{sourceLabel},
+		Regex:        &promrelabel.MultiLineRegex{S: regex},
+	}
+}
+
+func newReplaceRelabelConfig(sourceLabel, targetLabel string) promrelabel.RelabelConfig {
+	return promrelabel.RelabelConfig{
+		SourceLabels: []string{sourceLabel},
+		TargetLabel:  targetLabel,
+		Regex:        &promrelabel.MultiLineRegex{S: "(.+)"},
+	}
+}
@@ -0,0 +1,304 @@
+package promscrape
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kubernetes"
+)
+
+func TestGetCRDScrapeConfigs(t *testing.T) {
+	f := func(objects string, resultExpected string) {
+		t.Helper()
+		var cos kubernetes.CRDObjects
+		if err := json.Unmarshal([]byte(objects), &cos); err != nil {
+			t.Fatalf("cannot unmarshal objects: %s", err)
+		}
+		crdc := &kubernetes.CRDSDConfig{
+			APIServer: "https://k8s:6443",
+		}
+		scs := getCRDScrapeConfigs(crdc, &cos)
+		data, err := yaml.Marshal(scs)
+		if err != nil {
+			t.Fatalf("cannot marshal scrape configs: %s", err)
+		}
+		if string(data) != resultExpected {
+			t.Fatalf("unexpected scrape configs\ngot\n%s\nwant\n%s", data, resultExpected)
+		}
+	}
+
+	// no objects
+	f(`{}`, "[]\n")
+
+	// ServiceMonitor
+	f(`{"serviceMonitors":[{
+  "metadata": {"name": "app", "namespace": "default"},
+  "spec": {
+    "jobLabel": "app.kubernetes.io/name",
+    "targetLabels": ["team"],
+    "selector": {
+      "matchLabels": {"app": "foo"},
+      "matchExpressions": [
+        {"key": "tier", "operator": "NotIn", "values": ["db"]},
+        {"key": "canary", "operator": "DoesNotExist"}
+      ]
+    },
+    "endpoints": [
+      {
+        "port": "http",
+        "interval": "15s",
+        "honorLabels": true,
+        "metricRelabelings": [{"sourceLabels": ["__name__"], "regex": "go_.+", "action": "Drop"}]
+      },
+      {"targetPort": 9090, "path": "/stats", "scheme": "https", "tlsConfig": {"insecureSkipVerify": true}}
+    ]
+  }
+}]}`, `- job_name: serviceMonitor/default/app/0
+  scrape_interval: 15s
+  honor_labels: true
+  relabel_configs:
+  - action: keep
+    source_labels: [__meta_kubernetes_service_label_app, __meta_kubernetes_service_labelpresent_app]
+    regex: (foo);true
+  - action: drop
+    source_labels: [__meta_kubernetes_service_label_tier, __meta_kubernetes_service_labelpresent_tier]
+    regex: (db);true
+  - action: drop
+    source_labels: [__meta_kubernetes_service_labelpresent_canary]
+    regex: "true"
+  - action: keep
+    source_labels: [__meta_kubernetes_endpoint_port_name]
+    regex: http
+  - source_labels: [__meta_kubernetes_namespace]
+    target_label: namespace
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_service_name]
+    target_label: service
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_pod_name]
+    target_label: pod
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_pod_container_name]
+    target_label: container
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_service_label_team]
+    target_label: team
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_service_name]
+    target_label: job
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_service_label_app_kubernetes_io_name]
+    target_label: job
+    regex: (.+)
+  - target_label: endpoint
+    replacement: http
+  metric_relabel_configs:
+  - action: drop
+    source_labels: [__name__]
+    regex: go_.+
+  kubernetes_sd_configs:
+  - api_server: https://k8s:6443
+    role: endpoints
+    namespaces:
+      own_namespace: false
+      names:
+      - default
+- job_name: serviceMonitor/default/app/1
+  metrics_path: /stats
+  scheme: https
+  tls_config:
+    insecure_skip_verify: true
+  relabel_configs:
+  - action: keep
+    source_labels: [__meta_kubernetes_service_label_app, __meta_kubernetes_service_labelpresent_app]
+    regex: (foo);true
+  - action: drop
+    source_labels: [__meta_kubernetes_service_label_tier, __meta_kubernetes_service_labelpresent_tier]
+    regex: (db);true
+  - action: drop
+    source_labels: [__meta_kubernetes_service_labelpresent_canary]
+    regex: "true"
+  - action: keep
+    source_labels: [__meta_kubernetes_pod_container_port_number]
+    regex: "9090"
+  - source_labels: [__meta_kubernetes_namespace]
+    target_label: namespace
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_service_name]
+    target_label: service
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_pod_name]
+    target_label: pod
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_pod_container_name]
+    target_label: container
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_service_label_team]
+    target_label: team
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_service_name]
+    target_label: job
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_service_label_app_kubernetes_io_name]
+    target_label: job
+    regex: (.+)
+  - target_label: endpoint
+    replacement: "9090"
+  kubernetes_sd_configs:
+  - api_server: https://k8s:6443
+    role: endpoints
+    namespaces:
+      own_namespace: false
+      names:
+      - default
+`)
+
+	// PodMonitor with namespaceSelector.any and an invalid endpoint, which must be skipped
+	f(`{"podMonitors":[{
+  "metadata": {"name": "pm", "namespace": "monitoring"},
+  "spec": {
+    "namespaceSelector": {"any": true},
+    "selector": {"matchExpressions": [{"key": "app", "operator": "In", "values": ["a.b", "c"]}]},
+    "podMetricsEndpoints": [
+      {"port": "metrics", "relabelings": [{"targetLabel": "env", "replacement": "prod"}]},
+      {"port": "web", "interval": "foobar"}
+    ]
+  }
+}]}`, `- job_name: podMonitor/monitoring/pm/0
+  relabel_configs:
+  - action: drop
+    source_labels: [__meta_kubernetes_pod_phase]
+    regex: (Failed|Succeeded)
+  - action: keep
+    source_labels: [__meta_kubernetes_pod_label_app, __meta_kubernetes_pod_labelpresent_app]
+    regex: (a\.b|c);true
+  - action: keep
+    source_labels: [__meta_kubernetes_pod_container_port_name]
+    regex: metrics
+  - source_labels: [__meta_kubernetes_namespace]
+    target_label: namespace
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_pod_container_name]
+    target_label: container
+    regex: (.+)
+  - source_labels: [__meta_kubernetes_pod_name]
+    target_label: pod
+    regex: (.+)
+  - target_label: job
+    replacement: monitoring/pm
+  - target_label: endpoint
+    replacement: metrics
+  - target_label: env
+    replacement: prod
+  kubernetes_sd_configs:
+  - api_server: https://k8s:6443
+    role: pod
+`)
+
+	// Probe
+	f(`{"probes":[{
+  "metadata": {"name": "blackbox", "namespace": "default"},
+  "spec": {
+    "jobName": "http-probe",
+    "prober": {"url": "blackbox-exporter:9115"},
+    "module": "http_2xx",
+    "interval": "1m",
+    "targets": {"staticConfig": {"static": ["https://example.com"], "labels": {"env": "prod"}}}
+  }
+}, {
+  "metadata": {"name": "no-prober", "namespace": "default"},
+  "spec": {
+    "targets": {"staticConfig": {"static": ["https://example.com"]}}
+  }
+}]}`, `- job_name: probe/default/blackbox
+  scrape_interval: 1m0s
+  metrics_path: /probe
+  params:
+    module:
+    - http_2xx
+  relabel_configs:
+  - source_labels: [__address__]
+    target_label: __param_target
+    regex: (.+)
+  - source_labels: [__param_target]
+    target_label: instance
+    regex: (.+)
+  - target_label: __address__
+    replacement: blackbox-exporter:9115
+  - target_label: job
+    replacement: http-probe
+  static_configs:
+  - targets:
+    - https://example.com
+    labels:
+      env: prod
+`)
+}
+
+func TestGetCRDScrapeConfigsFileAccess(t *testing.T) {
+	f := func(objects string, allowFileAccess bool, resultExpected string) {
+		t.Helper()
+		var cos kubernetes.CRDObjects
+		if err := json.Unmarshal([]byte(objects), &cos); err != nil {
+			t.Fatalf("cannot unmarshal objects: %s", err)
+		}
+		crdc := &kubernetes.CRDSDConfig{
+			AllowFileAccess: allowFileAccess,
+		}
+		scs := getCRDScrapeConfigs(crdc, &cos)
+		var hcs []any
+		for _, sc := range scs {
+			hcs = append(hcs, sc.HTTPClientConfig)
+		}
+		data, err := yaml.Marshal(hcs)
+		if err != nil {
+			t.Fatalf("cannot marshal http client configs: %s", err)
+		}
+		if string(data) != resultExpected {
+			t.Fatalf("unexpected http client configs\ngot\n%s\nwant\n%s", data, resultExpected)
+		}
+	}
+
+	objects := `{"serviceMonitors":[{
+  "metadata": {"name": "app", "namespace": "default"},
+  "spec": {
+    "endpoints": [{
+      "port": "http",
+      "bearerTokenFile": "/var/run/secrets/kubernetes.io/serviceaccount/token",
+      "tlsConfig": {"caFile": "/etc/ca.crt", "certFile": "/etc/cert.crt", "keyFile": "/etc/cert.key", "serverName": "foo"}
+    }]
+  }
+}], "podMonitors":[{
+  "metadata": {"name": "app", "namespace": "default"},
+  "spec": {
+    "podMetricsEndpoints": [{"port": "http", "bearerTokenFile": "/etc/passwd"}]
+  }
+}], "probes":[{
+  "metadata": {"name": "blackbox", "namespace": "default"},
+  "spec": {
+    "prober": {"url": "blackbox-exporter:9115"},
+    "bearerTokenFile": "/etc/passwd",
+    "targets": {"staticConfig": {"static": ["https://example.com"]}}
+  }
+}]}`
+
+	// file-based options are dropped by default
+	f(objects, false, `- tls_config:
+    server_name: foo
+- {}
+- {}
+`)
+
+	// file-based options are allowed explicitly
+	f(objects, true, `- bearer_token_file: /var/run/secrets/kubernetes.io/serviceaccount/token
+  tls_config:
+    ca_file: /etc/ca.crt
+    cert_file: /etc/cert.crt
+    key_file: /etc/cert.key
+    server_name: foo
+- bearer_token_file: /etc/passwd
+- bearer_token_file: /etc/passwd
+`)
+}
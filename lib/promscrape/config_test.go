@@ -542,6 +542,17 @@ scrape_configs:
   - targets: ["s"]
 `, []*ScrapeWork{})
 
+	// Scrape config with max_scrape_interval smaller than scrape_interval must be skipped
+	f(`
+scrape_configs:
+- job_name: aa
+  scrape_interval: 1m
+  max_scrape_interval: 30s
+  sample_budget: 100
+  static_configs:
+  - targets: ["s"]
+`, []*ScrapeWork{})
+
 	// Scrape config with missing source_labels for action=keep in relabel_configs must be skipped
 	f(`
 scrape_configs:
@@ -663,6 +674,25 @@ scrape_configs:
   - targets: ["foo"]
 `, []*ScrapeWork{})
 
+	// Scrape config with unsupported scrape_protocols must be skipped
+	f(`
+scrape_configs:
+- job_name: x
+  scrape_protocols: [foobar]
+  static_configs:
+  - targets: ["foo"]
+`, []*ScrapeWork{})
+
+	// Scrape config with duplicate scrape_protocols must be skipped
+	f(`
+global:
+  scrape_protocols: [PrometheusProto, PrometheusProto]
+scrape_configs:
+- job_name: x
+  static_configs:
+  - targets: ["foo"]
+`, []*ScrapeWork{})
+
 	// Scrape config with missing job_name must be skipped
 	f(`
 scrape_configs:
@@ -1260,6 +1290,60 @@ scrape_configs:
 		},
 	})
 	*seriesLimitPerTarget = defaultSeriesLimitPerTarget
+
+	// sample_budget and series_budget without max_scrape_interval
+	f(`
+scrape_configs:
+- job_name: foo
+  scrape_interval: 10s
+  sample_budget: 1000
+  series_budget: 100
+  static_configs:
+  - targets: ["foo.bar:1234"]
+`, []*ScrapeWork{
+		{
+			ScrapeURL:         "http://foo.bar:1234/metrics",
+			ScrapeInterval:    10 * time.Second,
+			ScrapeTimeout:     defaultScrapeTimeout,
+			MaxScrapeSize:     maxScrapeSize.N,
+			SampleBudget:      1000,
+			SeriesBudget:      100,
+			MaxScrapeInterval: 40 * time.Second,
+			jobNameOriginal:   "foo",
+			Labels: promutil.NewLabelsFromMap(map[string]string{
+				"instance": "foo.bar:1234",
+				"job":      "foo",
+			}),
+		},
+	})
+
+	// max_scrape_interval is adjusted to scrape interval overridden via __scrape_interval__
+	f(`
+scrape_configs:
+- job_name: foo
+  scrape_interval: 10s
+  max_scrape_interval: 1m
+  sample_budget: 1000
+  static_configs:
+  - targets: ["foo.bar:1234"]
+  relabel_configs:
+  - target_label: __scrape_interval__
+    replacement: 2m
+`, []*ScrapeWork{
+		{
+			ScrapeURL:         "http://foo.bar:1234/metrics",
+			ScrapeInterval:    2 * time.Minute,
+			ScrapeTimeout:     defaultScrapeTimeout,
+			MaxScrapeSize:     maxScrapeSize.N,
+			SampleBudget:      1000,
+			MaxScrapeInterval: 2 * time.Minute,
+			jobNameOriginal:   "foo",
+			Labels: promutil.NewLabelsFromMap(map[string]string{
+				"instance": "foo.bar:1234",
+				"job":      "foo",
+			}),
+		},
+	})
 }
 
 func equalStaticConfigForScrapeWorks(a, b []*ScrapeWork) bool {
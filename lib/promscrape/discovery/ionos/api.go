@@ -0,0 +1,59 @@
+package ionos
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+)
+
+var configMap = discoveryutil.NewConfigMap()
+
+type apiConfig struct {
+	client       *discoveryutil.Client
+	datacenterID string
+	port         int
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	if sdc.DatacenterID == "" {
+		return nil, fmt.Errorf("missing `datacenter_id` option")
+	}
+	hcc := sdc.HTTPClientConfig
+	if hcc.BasicAuth == nil && hcc.Authorization == nil && hcc.BearerToken == nil && hcc.BearerTokenFile == "" {
+		return nil, fmt.Errorf("missing `basic_auth` or `authorization` option")
+	}
+
+	// See https://api.ionos.com/docs/cloud/v6/
+	apiServer := "https://api.ionos.com/cloudapi/v6"
+
+	ac, err := hcc.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+	client, err := discoveryutil.NewClient(apiServer, ac, sdc.ProxyURL, proxyAC, &sdc.HTTPClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+	port := 80
+	if sdc.Port != nil {
+		port = *sdc.Port
+	}
+	cfg := &apiConfig{
+		client:       client,
+		datacenterID: sdc.DatacenterID,
+		port:         port,
+	}
+	return cfg, nil
+}
@@ -0,0 +1,48 @@
+package ionos
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
+)
+
+// SDCheckInterval defines interval for targets refresh.
+var SDCheckInterval = flag.Duration("promscrape.ionosSDCheckInterval", time.Minute, "Interval for checking for changes in IONOS Cloud. "+
+	"This works only if ionos_sd_configs is configured in '-promscrape.config' file. "+
+	"See https://docs.victoriametrics.com/victoriametrics/sd_configs/#ionos_sd_configs for details")
+
+// SDConfig represents service discovery config for IONOS Cloud.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#ionos_sd_config
+type SDConfig struct {
+	DatacenterID string `yaml:"datacenter_id"`
+	Port         *int   `yaml:"port,omitempty"`
+
+	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
+	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
+	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
+
+	// refresh_interval is obtained from `-promscrape.ionosSDCheckInterval` command-line option.
+}
+
+// GetLabels returns IONOS Cloud servers' labels according to sdc.
+func (sdc *SDConfig) GetLabels(baseDir string) ([]*promutil.Labels, error) {
+	cfg, err := getAPIConfig(sdc, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get API config: %w", err)
+	}
+	return getServersLabels(cfg)
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *SDConfig) MustStop() {
+	v := configMap.Delete(sdc)
+	if v != nil {
+		cfg := v.(*apiConfig)
+		cfg.client.Stop()
+	}
+}
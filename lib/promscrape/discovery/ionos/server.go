@@ -0,0 +1,161 @@
+package ionos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// Servers represents IONOS Cloud servers collection.
+//
+// See https://api.ionos.com/docs/cloud/v6/#tag/Servers/operation/datacentersServersGet
+type Servers struct {
+	ID    string   `json:"id"`
+	Items []Server `json:"items"`
+}
+
+// Server represents IONOS Cloud server.
+type Server struct {
+	ID         string           `json:"id"`
+	Metadata   ServerMetadata   `json:"metadata"`
+	Properties ServerProperties `json:"properties"`
+	Entities   ServerEntities   `json:"entities"`
+}
+
+// ServerMetadata represents IONOS Cloud server metadata.
+type ServerMetadata struct {
+	State string `json:"state"`
+}
+
+// ServerProperties represents IONOS Cloud server properties.
+type ServerProperties struct {
+	Name             string       `json:"name"`
+	AvailabilityZone string       `json:"availabilityZone"`
+	VMState          string       `json:"vmState"`
+	CPUFamily        string       `json:"cpuFamily"`
+	Type             string       `json:"type"`
+	BootCdrom        *ResourceRef `json:"bootCdrom"`
+	BootVolume       *ResourceRef `json:"bootVolume"`
+}
+
+// ResourceRef represents a reference to IONOS Cloud resource.
+type ResourceRef struct {
+	ID string `json:"id"`
+}
+
+// ServerEntities represents IONOS Cloud server entities.
+type ServerEntities struct {
+	Nics    *Collection[Nic]    `json:"nics"`
+	Volumes *Collection[Volume] `json:"volumes"`
+}
+
+// Collection represents IONOS Cloud resources collection.
+type Collection[T any] struct {
+	Items []T `json:"items"`
+}
+
+// Nic represents IONOS Cloud network interface.
+type Nic struct {
+	Properties NicProperties `json:"properties"`
+}
+
+// NicProperties represents IONOS Cloud network interface properties.
+type NicProperties struct {
+	Name string   `json:"name"`
+	IPs  []string `json:"ips"`
+}
+
+// Volume represents IONOS Cloud volume.
+type Volume struct {
+	Properties VolumeProperties `json:"properties"`
+}
+
+// VolumeProperties represents IONOS Cloud volume properties.
+type VolumeProperties struct {
+	Image string `json:"image"`
+}
+
+func getServersLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	servers, err := getServers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return getServerLabels(servers, cfg.port), nil
+}
+
+func getServers(cfg *apiConfig) (*Servers, error) {
+	// depth=3 is needed for obtaining nics and volumes properties.
+	path := fmt.Sprintf("/datacenters/%s/servers?depth=3", url.PathEscape(cfg.datacenterID))
+	data, err := cfg.client.GetAPIResponse(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain IONOS Cloud servers: %w", err)
+	}
+	var servers Servers
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal IONOS Cloud servers from %q: %w; response=%q", path, err, data)
+	}
+	return &servers, nil
+}
+
+func getServerLabels(servers *Servers, port int) []*promutil.Labels {
+	var ms []*promutil.Labels
+	for i := range servers.Items {
+		server := &servers.Items[i]
+
+		var ips []string
+		var nicNames []string
+		ipsByNICName := make(map[string][]string)
+		if server.Entities.Nics != nil {
+			for _, nic := range server.Entities.Nics.Items {
+				nicName := discoveryutil.SanitizeLabelName(nic.Properties.Name)
+				if _, ok := ipsByNICName[nicName]; !ok {
+					nicNames = append(nicNames, nicName)
+				}
+				ips = append(ips, nic.Properties.IPs...)
+				ipsByNICName[nicName] = append(ipsByNICName[nicName], nic.Properties.IPs...)
+			}
+		}
+		if len(ips) == 0 {
+			// The server has no IP addresses, so it cannot be scraped.
+			continue
+		}
+
+		m := promutil.NewLabels(16)
+		m.Add("__address__", discoveryutil.JoinHostPort(ips[0], port))
+		m.Add("__meta_ionos_server_availability_zone", server.Properties.AvailabilityZone)
+		m.Add("__meta_ionos_server_cpu_family", server.Properties.CPUFamily)
+		m.Add("__meta_ionos_server_servers_id", servers.ID)
+		m.Add("__meta_ionos_server_id", server.ID)
+		m.Add("__meta_ionos_server_ip", joinWithSeparator(ips))
+		m.Add("__meta_ionos_server_lifecycle", server.Metadata.State)
+		m.Add("__meta_ionos_server_name", server.Properties.Name)
+		m.Add("__meta_ionos_server_state", server.Properties.VMState)
+		m.Add("__meta_ionos_server_type", server.Properties.Type)
+		if server.Properties.BootCdrom != nil {
+			m.Add("__meta_ionos_server_boot_cdrom_id", server.Properties.BootCdrom.ID)
+		}
+		if server.Properties.BootVolume != nil {
+			m.Add("__meta_ionos_server_boot_volume_id", server.Properties.BootVolume.ID)
+		}
+		if server.Entities.Volumes != nil && len(server.Entities.Volumes.Items) > 0 {
+			if image := server.Entities.Volumes.Items[0].Properties.Image; image != "" {
+				m.Add("__meta_ionos_server_boot_image_id", image)
+			}
+		}
+		for _, nicName := range nicNames {
+			if nicIPs := ipsByNICName[nicName]; len(nicIPs) > 0 {
+				m.Add("__meta_ionos_server_nic_ip_"+nicName, joinWithSeparator(nicIPs))
+			}
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+func joinWithSeparator(a []string) string {
+	return "," + strings.Join(a, ",") + ","
+}
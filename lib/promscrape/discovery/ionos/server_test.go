@@ -0,0 +1,166 @@
+package ionos
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestNewAPIConfigFailure(t *testing.T) {
+	f := func(sdc *SDConfig) {
+		t.Helper()
+		_, err := newAPIConfig(sdc, ".")
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing datacenter_id
+	f(&SDConfig{
+		HTTPClientConfig: promauth.HTTPClientConfig{
+			BasicAuth: &promauth.BasicAuthConfig{
+				Username: "foo",
+				Password: promauth.NewSecret("bar"),
+			},
+		},
+	})
+
+	// missing auth
+	f(&SDConfig{
+		DatacenterID: "8feda53f-15f0-447f-badf-ebe32dad2fc0",
+	})
+}
+
+func TestGetServersLabels(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RequestURI() != "/datacenters/8feda53f-15f0-447f-badf-ebe32dad2fc0/servers?depth=3" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{
+  "id": "8feda53f-15f0-447f-badf-ebe32dad2fc0/servers",
+  "type": "collection",
+  "items": [
+    {
+      "id": "d6bf44ee-f7e8-4e19-8716-96fdd18cc697",
+      "type": "server",
+      "metadata": {"state": "AVAILABLE"},
+      "properties": {
+        "name": "prometheus-2",
+        "cores": 2,
+        "ram": 4096,
+        "availabilityZone": "AUTO",
+        "vmState": "RUNNING",
+        "bootCdrom": null,
+        "bootVolume": {"id": "8d7a8d6d-1d4c-4b89-a3a3-ec5d4d8a4dc3", "type": "volume"},
+        "cpuFamily": "INTEL_SKYLAKE",
+        "type": "ENTERPRISE"
+      },
+      "entities": {
+        "volumes": {
+          "items": [
+            {"id": "8d7a8d6d-1d4c-4b89-a3a3-ec5d4d8a4dc3", "properties": {"name": "root", "image": "0e4d57f9-cd78-11e9-b88c-525400f64d8d"}}
+          ]
+        },
+        "nics": {
+          "items": [
+            {"id": "nic-1", "properties": {"name": "metrics", "ips": ["85.215.243.177"]}},
+            {"id": "nic-2", "properties": {"name": "internal-net", "ips": ["10.7.224.11", "10.7.224.12"]}}
+          ]
+        }
+      }
+    },
+    {
+      "id": "b501942c-4e08-43e6-8ec1-00e59c64e0e4",
+      "type": "server",
+      "metadata": {"state": "BUSY"},
+      "properties": {
+        "name": "no-nics",
+        "availabilityZone": "ZONE_1",
+        "vmState": "SHUTOFF",
+        "cpuFamily": "AMD_OPTERON",
+        "type": "ENTERPRISE"
+      },
+      "entities": {
+        "nics": {"items": []}
+      }
+    },
+    {
+      "id": "523415e6-ff8c-4dc0-86d3-09c256039b30",
+      "type": "server",
+      "metadata": {"state": "AVAILABLE"},
+      "properties": {
+        "name": "prometheus-1",
+        "availabilityZone": "ZONE_1",
+        "vmState": "RUNNING",
+        "bootCdrom": {"id": "0e4d57f9-cd78-11e9-b88c-525400f64d8d", "type": "image"},
+        "bootVolume": null,
+        "cpuFamily": "INTEL_SKYLAKE",
+        "type": "ENTERPRISE"
+      },
+      "entities": {
+        "volumes": {"items": []},
+        "nics": {
+          "items": [
+            {"id": "nic-3", "properties": {"name": "", "ips": ["85.215.248.84"]}}
+          ]
+        }
+      }
+    }
+  ]
+}`))
+	}))
+	defer s.Close()
+
+	client, err := discoveryutil.NewClient(s.URL, nil, nil, nil, &promauth.HTTPClientConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error when creating http client: %s", err)
+	}
+	defer client.Stop()
+	cfg := &apiConfig{
+		client:       client,
+		datacenterID: "8feda53f-15f0-447f-badf-ebe32dad2fc0",
+		port:         80,
+	}
+	labelss, err := getServersLabels(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	labelssExpected := []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                             "85.215.243.177:80",
+			"__meta_ionos_server_availability_zone":   "AUTO",
+			"__meta_ionos_server_boot_image_id":       "0e4d57f9-cd78-11e9-b88c-525400f64d8d",
+			"__meta_ionos_server_boot_volume_id":      "8d7a8d6d-1d4c-4b89-a3a3-ec5d4d8a4dc3",
+			"__meta_ionos_server_cpu_family":          "INTEL_SKYLAKE",
+			"__meta_ionos_server_id":                  "d6bf44ee-f7e8-4e19-8716-96fdd18cc697",
+			"__meta_ionos_server_ip":                  ",85.215.243.177,10.7.224.11,10.7.224.12,",
+			"__meta_ionos_server_lifecycle":           "AVAILABLE",
+			"__meta_ionos_server_name":                "prometheus-2",
+			"__meta_ionos_server_nic_ip_internal_net": ",10.7.224.11,10.7.224.12,",
+			"__meta_ionos_server_nic_ip_metrics":      ",85.215.243.177,",
+			"__meta_ionos_server_servers_id":          "8feda53f-15f0-447f-badf-ebe32dad2fc0/servers",
+			"__meta_ionos_server_state":               "RUNNING",
+			"__meta_ionos_server_type":                "ENTERPRISE",
+		}),
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                           "85.215.248.84:80",
+			"__meta_ionos_server_availability_zone": "ZONE_1",
+			"__meta_ionos_server_boot_cdrom_id":     "0e4d57f9-cd78-11e9-b88c-525400f64d8d",
+			"__meta_ionos_server_cpu_family":        "INTEL_SKYLAKE",
+			"__meta_ionos_server_id":                "523415e6-ff8c-4dc0-86d3-09c256039b30",
+			"__meta_ionos_server_ip":                ",85.215.248.84,",
+			"__meta_ionos_server_lifecycle":         "AVAILABLE",
+			"__meta_ionos_server_name":              "prometheus-1",
+			"__meta_ionos_server_nic_ip_":           ",85.215.248.84,",
+			"__meta_ionos_server_servers_id":        "8feda53f-15f0-447f-badf-ebe32dad2fc0/servers",
+			"__meta_ionos_server_state":             "RUNNING",
+			"__meta_ionos_server_type":              "ENTERPRISE",
+		}),
+	}
+	discoveryutil.TestEqualLabelss(t, labelss, labelssExpected)
+}
@@ -16,6 +16,11 @@ func newAPIConfig(sdc *SDConfig, baseDir string, swcFunc ScrapeWorkConstructorFu
 	default:
 		return nil, fmt.Errorf("unexpected `role`: %q; must be one of `node`, `pod`, `service`, `endpoints`, `endpointslice` or `ingress`", role)
 	}
+	return newAPIConfigInternal(sdc, baseDir, swcFunc)
+}
+
+// newAPIConfigInternal creates apiConfig for sdc without checking sdc.Role.
+func newAPIConfigInternal(sdc *SDConfig, baseDir string, swcFunc ScrapeWorkConstructorFunc) (*apiConfig, error) {
 	cc := &sdc.HTTPClientConfig
 	ac, err := cc.NewConfig(baseDir)
 	if err != nil {
@@ -1027,6 +1027,9 @@ func getAPIPath(objectType, namespace, query string) string {
 	if objectType == "endpointslices" {
 		return "/apis/discovery.k8s.io/v1/" + suffix
 	}
+	if objectType == "servicemonitors" || objectType == "podmonitors" || objectType == "probes" {
+		return "/apis/monitoring.coreos.com/v1/" + suffix
+	}
 	return "/api/v1/" + suffix
 }
 
@@ -1067,6 +1070,12 @@ func getObjectTypeByRole(role string) string {
 		return "endpointslices"
 	case "ingress":
 		return "ingresses"
+	case "servicemonitor":
+		return "servicemonitors"
+	case "podmonitor":
+		return "podmonitors"
+	case "probe":
+		return "probes"
 	default:
 		logger.Panicf("BUG: unknown role=%q", role)
 		return ""
@@ -1087,6 +1096,12 @@ func getObjectParsersForRole(role string) (parseObjectFunc, parseObjectListFunc)
 		return parseEndpointSlice, parseEndpointSliceList
 	case "ingress":
 		return parseIngress, parseIngressList
+	case "servicemonitor":
+		return parseServiceMonitor, parseServiceMonitorList
+	case "podmonitor":
+		return parsePodMonitor, parsePodMonitorList
+	case "probe":
+		return parseProbe, parseProbeList
 	default:
 		logger.Panicf("BUG: unsupported role=%q", role)
 		return nil, nil
@@ -0,0 +1,516 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
+)
+
+// CRDSDConfig represents `kubernetes_crd_sd_configs` config.
+//
+// It discovers prometheus-operator ServiceMonitor, PodMonitor and Probe custom resources,
+// which can be converted to scrape configs without running prometheus-operator.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/
+type CRDSDConfig struct {
+	APIServer      string `yaml:"api_server,omitempty"`
+	KubeConfigFile string `yaml:"kubeconfig_file,omitempty"`
+
+	HTTPClientConfig promauth.HTTPClientConfig `yaml:",inline"`
+	ProxyURL         *proxy.URL                `yaml:"proxy_url,omitempty"`
+
+	// Namespaces limits namespaces to discover custom resources from.
+	Namespaces Namespaces `yaml:"namespaces,omitempty"`
+
+	// Selectors limits custom resources to discover.
+	// Supported roles: servicemonitor, podmonitor and probe.
+	Selectors []Selector `yaml:"selectors,omitempty"`
+
+	// Roles contains custom resource types to discover.
+	// Supported roles: servicemonitor, podmonitor and probe. All of them are discovered by default.
+	Roles []string `yaml:"roles,omitempty"`
+
+	// AllowFileAccess allows using file-based options such as `bearerTokenFile` and `tlsConfig.caFile` from the discovered custom resources.
+	//
+	// These options are ignored by default, since custom resources may be created by anyone with access to the watched namespaces,
+	// while these options allow sending arbitrary local files such as service account token to arbitrary targets.
+	AllowFileAccess bool `yaml:"allow_file_access,omitempty"`
+}
+
+var crdRoles = []string{"servicemonitor", "podmonitor", "probe"}
+
+func isCRDRole(role string) bool {
+	switch role {
+	case "servicemonitor", "podmonitor", "probe":
+		return true
+	default:
+		return false
+	}
+}
+
+func (cfg *CRDSDConfig) roles() ([]string, error) {
+	if len(cfg.Roles) == 0 {
+		return crdRoles, nil
+	}
+	for _, role := range cfg.Roles {
+		if !isCRDRole(role) {
+			return nil, fmt.Errorf("unexpected `role`: %q; must be one of `servicemonitor`, `podmonitor` or `probe`", role)
+		}
+	}
+	return cfg.Roles, nil
+}
+
+// NewSDConfig returns kubernetes_sd_config with the given role and namespaces, which uses the same Kubernetes API server connection settings as cfg.
+func (cfg *CRDSDConfig) NewSDConfig(role string, namespaces []string) SDConfig {
+	return SDConfig{
+		APIServer:        cfg.APIServer,
+		Role:             role,
+		KubeConfigFile:   cfg.KubeConfigFile,
+		HTTPClientConfig: cfg.HTTPClientConfig,
+		ProxyURL:         cfg.ProxyURL,
+		Namespaces: Namespaces{
+			Names: namespaces,
+		},
+	}
+}
+
+// CRDObjects contains custom resources discovered by CRDSDConfig.
+type CRDObjects struct {
+	ServiceMonitors []*ServiceMonitor
+	PodMonitors     []*PodMonitor
+	Probes          []*Probe
+}
+
+// GetObjects returns the latest state of custom resources discovered by cfg.
+//
+// Watchers for custom resources are started on the first call and are shared among calls with the same cfg and baseDir.
+// Watchers, which weren't used during the last 10 minutes, are stopped in background.
+func (cfg *CRDSDConfig) GetObjects(baseDir string) (*CRDObjects, error) {
+	cw, err := getCRDWatcher(cfg, baseDir)
+	if err != nil {
+		return nil, err
+	}
+	var cos CRDObjects
+	for _, aw := range cw.aws {
+		for _, o := range aw.getObjects() {
+			switch t := o.(type) {
+			case *ServiceMonitor:
+				cos.ServiceMonitors = append(cos.ServiceMonitors, t)
+			case *PodMonitor:
+				cos.PodMonitors = append(cos.PodMonitors, t)
+			case *Probe:
+				cos.Probes = append(cos.Probes, t)
+			}
+		}
+	}
+	sort.Slice(cos.ServiceMonitors, func(i, j int) bool {
+		return cos.ServiceMonitors[i].key() < cos.ServiceMonitors[j].key()
+	})
+	sort.Slice(cos.PodMonitors, func(i, j int) bool {
+		return cos.PodMonitors[i].key() < cos.PodMonitors[j].key()
+	})
+	sort.Slice(cos.Probes, func(i, j int) bool {
+		return cos.Probes[i].key() < cos.Probes[j].key()
+	})
+	return &cos, nil
+}
+
+// crdWatcher watches for custom resources for a single CRDSDConfig.
+type crdWatcher struct {
+	aws []*apiWatcher
+
+	// lastAccessTime is the last time the crdWatcher has been used.
+	//
+	// It is protected by crdWatchersLock.
+	lastAccessTime time.Time
+}
+
+var (
+	crdWatchersLock sync.Mutex
+	crdWatchers     = make(map[string]*crdWatcher)
+	crdWatchersOnce sync.Once
+)
+
+func getCRDWatcher(cfg *CRDSDConfig, baseDir string) (*crdWatcher, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal kubernetes_crd_sd_config: %w", err)
+	}
+	key := fmt.Sprintf("baseDir=%s, cfg=%s", baseDir, data)
+
+	crdWatchersOnce.Do(func() {
+		go crdWatchersCleaner()
+	})
+
+	crdWatchersLock.Lock()
+	defer crdWatchersLock.Unlock()
+
+	cw := crdWatchers[key]
+	if cw == nil {
+		roles, err := cfg.roles()
+		if err != nil {
+			return nil, err
+		}
+		cw = &crdWatcher{}
+		for _, role := range roles {
+			sdc := cfg.NewSDConfig(role, cfg.Namespaces.Names)
+			sdc.Namespaces.OwnNamespace = cfg.Namespaces.OwnNamespace
+			sdc.Selectors = cfg.Selectors
+			ac, err := newAPIConfigInternal(&sdc, baseDir, nil)
+			if err != nil {
+				cw.mustStop()
+				return nil, fmt.Errorf("cannot create API config for kubernetes custom resources: %w", err)
+			}
+			ac.aw.mustStart()
+			cw.aws = append(cw.aws, ac.aw)
+		}
+		crdWatchers[key] = cw
+	}
+	cw.lastAccessTime = time.Now()
+	return cw, nil
+}
+
+func (cw *crdWatcher) mustStop() {
+	for _, aw := range cw.aws {
+		aw.mustStop()
+	}
+}
+
+func crdWatchersCleaner() {
+	for {
+		time.Sleep(time.Minute)
+		crdWatchersLock.Lock()
+		for key, cw := range crdWatchers {
+			if time.Since(cw.lastAccessTime) > 10*time.Minute {
+				cw.mustStop()
+				delete(crdWatchers, key)
+			}
+		}
+		crdWatchersLock.Unlock()
+	}
+}
+
+// getObjects returns all the objects watched by aw.
+func (aw *apiWatcher) getObjects() []object {
+	aw.gw.registerPendingAPIWatchers()
+
+	var objs []object
+	gw := aw.gw
+	gw.mu.Lock()
+	for _, uw := range gw.m {
+		if _, ok := uw.aws[aw]; !ok {
+			continue
+		}
+		for _, o := range uw.objectsByKey {
+			objs = append(objs, o)
+		}
+	}
+	gw.mu.Unlock()
+	return objs
+}
+
+// ServiceMonitorList represents ServiceMonitor list in k8s.
+type ServiceMonitorList struct {
+	Metadata ListMeta
+	Items    []*ServiceMonitor
+}
+
+// ServiceMonitor represents prometheus-operator ServiceMonitor.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.ServiceMonitor
+type ServiceMonitor struct {
+	Metadata ObjectMeta
+	Spec     ServiceMonitorSpec
+}
+
+// ServiceMonitorSpec represents ServiceMonitor spec.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.ServiceMonitorSpec
+type ServiceMonitorSpec struct {
+	JobLabel          string
+	TargetLabels      []string
+	PodTargetLabels   []string
+	Endpoints         []MonitorEndpoint
+	Selector          LabelSelector
+	NamespaceSelector NamespaceSelector
+	SampleLimit       int
+}
+
+// PodMonitorList represents PodMonitor list in k8s.
+type PodMonitorList struct {
+	Metadata ListMeta
+	Items    []*PodMonitor
+}
+
+// PodMonitor represents prometheus-operator PodMonitor.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.PodMonitor
+type PodMonitor struct {
+	Metadata ObjectMeta
+	Spec     PodMonitorSpec
+}
+
+// PodMonitorSpec represents PodMonitor spec.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.PodMonitorSpec
+type PodMonitorSpec struct {
+	JobLabel            string
+	PodTargetLabels     []string
+	PodMetricsEndpoints []MonitorEndpoint
+	Selector            LabelSelector
+	NamespaceSelector   NamespaceSelector
+	SampleLimit         int
+}
+
+// MonitorEndpoint represents ServiceMonitor endpoint or PodMonitor podMetricsEndpoint.
+//
+// Only options, which do not refer to Kubernetes secrets, are supported.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.Endpoint
+// and https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.PodMetricsEndpoint
+type MonitorEndpoint struct {
+	Port              string
+	PortNumber        int
+	TargetPort        *IntOrString
+	Path              string
+	Scheme            string
+	Params            map[string][]string
+	Interval          string
+	ScrapeTimeout     string
+	HonorLabels       bool
+	HonorTimestamps   *bool
+	BearerTokenFile   string
+	TLSConfig         *MonitorTLSConfig
+	FollowRedirects   *bool
+	Relabelings       []MonitorRelabelConfig
+	MetricRelabelings []MonitorRelabelConfig
+}
+
+// MonitorTLSConfig represents TLS config for MonitorEndpoint.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.TLSConfig
+type MonitorTLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// MonitorRelabelConfig represents relabel config for custom resources.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.RelabelConfig
+type MonitorRelabelConfig struct {
+	SourceLabels []string
+	Separator    *string
+	TargetLabel  string
+	Regex        string
+	Modulus      uint64
+	Replacement  *string
+	Action       string
+}
+
+// ProbeList represents Probe list in k8s.
+type ProbeList struct {
+	Metadata ListMeta
+	Items    []*Probe
+}
+
+// Probe represents prometheus-operator Probe.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.Probe
+type Probe struct {
+	Metadata ObjectMeta
+	Spec     ProbeSpec
+}
+
+// ProbeSpec represents Probe spec.
+//
+// Only static targets are supported.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.ProbeSpec
+type ProbeSpec struct {
+	JobName           string
+	ProberSpec        ProberSpec `json:"prober"`
+	Module            string
+	Targets           ProbeTargets
+	Interval          string
+	ScrapeTimeout     string
+	BearerTokenFile   string
+	TLSConfig         *MonitorTLSConfig
+	MetricRelabelings []MonitorRelabelConfig
+	SampleLimit       int
+}
+
+// ProberSpec represents prober spec for Probe.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.ProberSpec
+type ProberSpec struct {
+	URL    string
+	Scheme string
+	Path   string
+}
+
+// ProbeTargets represents targets for Probe.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.ProbeTargets
+type ProbeTargets struct {
+	StaticConfig *ProbeTargetStaticConfig
+}
+
+// ProbeTargetStaticConfig represents static targets for Probe.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.ProbeTargetStaticConfig
+type ProbeTargetStaticConfig struct {
+	Targets           []string `json:"static"`
+	Labels            map[string]string
+	RelabelingConfigs []MonitorRelabelConfig
+}
+
+// LabelSelector represents label selector in k8s.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.28/#labelselector-v1-meta
+type LabelSelector struct {
+	MatchLabels      map[string]string
+	MatchExpressions []LabelSelectorRequirement
+}
+
+// LabelSelectorRequirement represents label selector requirement in k8s.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.28/#labelselectorrequirement-v1-meta
+type LabelSelectorRequirement struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+// NamespaceSelector represents namespace selector for custom resources.
+//
+// See https://prometheus-operator.dev/docs/api-reference/api/#monitoring.coreos.com/v1.NamespaceSelector
+type NamespaceSelector struct {
+	Any        bool
+	MatchNames []string
+}
+
+// IntOrString represents k8s value, which can hold either int or string.
+type IntOrString struct {
+	IntVal int
+	StrVal string
+	IsInt  bool
+}
+
+// UnmarshalJSON unmarshals ios from data.
+func (ios *IntOrString) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		ios.IsInt = false
+		return json.Unmarshal(data, &ios.StrVal)
+	}
+	ios.IsInt = true
+	return json.Unmarshal(data, &ios.IntVal)
+}
+
+// String returns string representation for ios.
+func (ios *IntOrString) String() string {
+	if ios.IsInt {
+		return strconv.Itoa(ios.IntVal)
+	}
+	return ios.StrVal
+}
+
+func (sm *ServiceMonitor) key() string {
+	return sm.Metadata.key()
+}
+
+func (sm *ServiceMonitor) getTargetLabels(_ *groupWatcher) []*promutil.Labels {
+	// ServiceMonitor objects are converted to scrape configs instead of targets.
+	return nil
+}
+
+func parseServiceMonitorList(r io.Reader) (map[string]object, ListMeta, error) {
+	var sml ServiceMonitorList
+	d := json.NewDecoder(r)
+	if err := d.Decode(&sml); err != nil {
+		return nil, sml.Metadata, fmt.Errorf("cannot unmarshal ServiceMonitorList: %w", err)
+	}
+	objectsByKey := make(map[string]object)
+	for _, sm := range sml.Items {
+		objectsByKey[sm.key()] = sm
+	}
+	return objectsByKey, sml.Metadata, nil
+}
+
+func parseServiceMonitor(data []byte) (object, error) {
+	var sm ServiceMonitor
+	if err := json.Unmarshal(data, &sm); err != nil {
+		return nil, err
+	}
+	return &sm, nil
+}
+
+func (pm *PodMonitor) key() string {
+	return pm.Metadata.key()
+}
+
+func (pm *PodMonitor) getTargetLabels(_ *groupWatcher) []*promutil.Labels {
+	// PodMonitor objects are converted to scrape configs instead of targets.
+	return nil
+}
+
+func parsePodMonitorList(r io.Reader) (map[string]object, ListMeta, error) {
+	var pml PodMonitorList
+	d := json.NewDecoder(r)
+	if err := d.Decode(&pml); err != nil {
+		return nil, pml.Metadata, fmt.Errorf("cannot unmarshal PodMonitorList: %w", err)
+	}
+	objectsByKey := make(map[string]object)
+	for _, pm := range pml.Items {
+		objectsByKey[pm.key()] = pm
+	}
+	return objectsByKey, pml.Metadata, nil
+}
+
+func parsePodMonitor(data []byte) (object, error) {
+	var pm PodMonitor
+	if err := json.Unmarshal(data, &pm); err != nil {
+		return nil, err
+	}
+	return &pm, nil
+}
+
+func (p *Probe) key() string {
+	return p.Metadata.key()
+}
+
+func (p *Probe) getTargetLabels(_ *groupWatcher) []*promutil.Labels {
+	// Probe objects are converted to scrape configs instead of targets.
+	return nil
+}
+
+func parseProbeList(r io.Reader) (map[string]object, ListMeta, error) {
+	var pl ProbeList
+	d := json.NewDecoder(r)
+	if err := d.Decode(&pl); err != nil {
+		return nil, pl.Metadata, fmt.Errorf("cannot unmarshal ProbeList: %w", err)
+	}
+	objectsByKey := make(map[string]object)
+	for _, p := range pl.Items {
+		objectsByKey[p.key()] = p
+	}
+	return objectsByKey, pl.Metadata, nil
+}
+
+func parseProbe(data []byte) (object, error) {
+	var p Probe
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
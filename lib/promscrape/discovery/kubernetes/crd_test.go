@@ -0,0 +1,259 @@
+package kubernetes
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCRDSDConfigRoles(t *testing.T) {
+	f := func(roles, rolesExpected []string, isErrorExpected bool) {
+		t.Helper()
+		cfg := &CRDSDConfig{
+			Roles: roles,
+		}
+		result, err := cfg.roles()
+		if isErrorExpected {
+			if err == nil {
+				t.Fatalf("expecting non-nil error")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(result, rolesExpected) {
+			t.Fatalf("unexpected roles; got %q; want %q", result, rolesExpected)
+		}
+	}
+	f(nil, []string{"servicemonitor", "podmonitor", "probe"}, false)
+	f([]string{"probe"}, []string{"probe"}, false)
+	f([]string{"podmonitor", "servicemonitor"}, []string{"podmonitor", "servicemonitor"}, false)
+	f([]string{"pod"}, nil, true)
+	f([]string{"probe", "foobar"}, nil, true)
+}
+
+func TestGetAPIPathsWithNamespacesCRD(t *testing.T) {
+	f := func(role string, namespaces []string, selectors []Selector, expectedPaths []string) {
+		t.Helper()
+		paths := getAPIPathsWithNamespaces(role, namespaces, selectors)
+		if !reflect.DeepEqual(paths, expectedPaths) {
+			t.Fatalf("unexpected paths; got\n%q\nwant\n%q", paths, expectedPaths)
+		}
+	}
+	f("servicemonitor", nil, nil, []string{"/apis/monitoring.coreos.com/v1/servicemonitors"})
+	f("podmonitor", []string{"foo", "bar"}, nil, []string{
+		"/apis/monitoring.coreos.com/v1/namespaces/foo/podmonitors",
+		"/apis/monitoring.coreos.com/v1/namespaces/bar/podmonitors",
+	})
+	f("probe", nil, []Selector{
+		{
+			Role:  "probe",
+			Label: "team=a",
+		},
+	}, []string{"/apis/monitoring.coreos.com/v1/probes?labelSelector=team%3Da"})
+}
+
+func TestParseServiceMonitorListFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		r := bytes.NewBufferString(s)
+		objectsByKey, _, err := parseServiceMonitorList(r)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if len(objectsByKey) != 0 {
+			t.Fatalf("unexpected non-empty ServiceMonitorList: %v", objectsByKey)
+		}
+	}
+	f(``)
+	f(`[1,23]`)
+	f(`{"items":[{"metadata":1}]}`)
+	f(`{"items":[{"spec":{"endpoints":{}}}]}`)
+}
+
+func TestParseServiceMonitorListSuccess(t *testing.T) {
+	data := `
+{
+  "kind": "ServiceMonitorList",
+  "apiVersion": "monitoring.coreos.com/v1",
+  "metadata": {
+    "resourceVersion": "1234"
+  },
+  "items": [
+    {
+      "metadata": {
+        "name": "app",
+        "namespace": "default"
+      },
+      "spec": {
+        "jobLabel": "app.kubernetes.io/name",
+        "targetLabels": ["team"],
+        "selector": {
+          "matchLabels": {"app": "foo"},
+          "matchExpressions": [{"key": "tier", "operator": "In", "values": ["web", "api"]}]
+        },
+        "namespaceSelector": {"matchNames": ["prod"]},
+        "sampleLimit": 1000,
+        "endpoints": [
+          {
+            "port": "http",
+            "path": "/metrics",
+            "interval": "15s",
+            "honorLabels": true,
+            "relabelings": [{"sourceLabels": ["__meta_kubernetes_pod_node_name"], "targetLabel": "node", "action": "replace"}]
+          },
+          {
+            "targetPort": 9090,
+            "scheme": "https",
+            "tlsConfig": {"caFile": "/etc/ca.crt", "insecureSkipVerify": true}
+          }
+        ]
+      }
+    }
+  ]
+}
+`
+	r := bytes.NewBufferString(data)
+	objectsByKey, meta, err := parseServiceMonitorList(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if meta.ResourceVersion != "1234" {
+		t.Fatalf("unexpected resource version; got %q; want %q", meta.ResourceVersion, "1234")
+	}
+	o := objectsByKey["default/app"]
+	if o == nil {
+		t.Fatalf("missing ServiceMonitor default/app in %v", objectsByKey)
+	}
+	sm := o.(*ServiceMonitor)
+	spec := &sm.Spec
+	if spec.JobLabel != "app.kubernetes.io/name" || !reflect.DeepEqual(spec.TargetLabels, []string{"team"}) || spec.SampleLimit != 1000 {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+	selectorExpected := LabelSelector{
+		MatchLabels: map[string]string{
+			"app": "foo",
+		},
+		MatchExpressions: []LabelSelectorRequirement{
+			{
+				Key:      "tier",
+				Operator: "In",
+				Values:   []string{"web", "api"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(spec.Selector, selectorExpected) {
+		t.Fatalf("unexpected selector\ngot\n%+v\nwant\n%+v", spec.Selector, selectorExpected)
+	}
+	if !reflect.DeepEqual(spec.NamespaceSelector.MatchNames, []string{"prod"}) || spec.NamespaceSelector.Any {
+		t.Fatalf("unexpected namespaceSelector: %+v", spec.NamespaceSelector)
+	}
+	if len(spec.Endpoints) != 2 {
+		t.Fatalf("unexpected number of endpoints; got %d; want 2", len(spec.Endpoints))
+	}
+	ep := &spec.Endpoints[0]
+	if ep.Port != "http" || ep.Path != "/metrics" || ep.Interval != "15s" || !ep.HonorLabels || ep.TargetPort != nil {
+		t.Fatalf("unexpected endpoint #0: %+v", ep)
+	}
+	if len(ep.Relabelings) != 1 || ep.Relabelings[0].TargetLabel != "node" || ep.Relabelings[0].Action != "replace" {
+		t.Fatalf("unexpected relabelings for endpoint #0: %+v", ep.Relabelings)
+	}
+	ep = &spec.Endpoints[1]
+	if ep.TargetPort == nil || !ep.TargetPort.IsInt || ep.TargetPort.String() != "9090" || ep.Scheme != "https" {
+		t.Fatalf("unexpected endpoint #1: %+v", ep)
+	}
+	if ep.TLSConfig == nil || ep.TLSConfig.CAFile != "/etc/ca.crt" || !ep.TLSConfig.InsecureSkipVerify {
+		t.Fatalf("unexpected tlsConfig for endpoint #1: %+v", ep.TLSConfig)
+	}
+	if labelss := sm.getTargetLabels(nil); len(labelss) != 0 {
+		t.Fatalf("unexpected target labels for ServiceMonitor: %v", labelss)
+	}
+}
+
+func TestParsePodMonitorSuccess(t *testing.T) {
+	data := `
+{
+  "metadata": {
+    "name": "pm",
+    "namespace": "monitoring"
+  },
+  "spec": {
+    "podTargetLabels": ["version"],
+    "namespaceSelector": {"any": true},
+    "podMetricsEndpoints": [
+      {"port": "metrics", "scrapeTimeout": "5s"},
+      {"targetPort": "web"}
+    ]
+  }
+}
+`
+	o, err := parsePodMonitor([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	pm := o.(*PodMonitor)
+	if pm.key() != "monitoring/pm" {
+		t.Fatalf("unexpected key; got %q; want %q", pm.key(), "monitoring/pm")
+	}
+	spec := &pm.Spec
+	if !spec.NamespaceSelector.Any || !reflect.DeepEqual(spec.PodTargetLabels, []string{"version"}) {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+	if len(spec.PodMetricsEndpoints) != 2 {
+		t.Fatalf("unexpected number of podMetricsEndpoints; got %d; want 2", len(spec.PodMetricsEndpoints))
+	}
+	ep := &spec.PodMetricsEndpoints[0]
+	if ep.Port != "metrics" || ep.ScrapeTimeout != "5s" {
+		t.Fatalf("unexpected podMetricsEndpoint #0: %+v", ep)
+	}
+	ep = &spec.PodMetricsEndpoints[1]
+	if ep.TargetPort == nil || ep.TargetPort.IsInt || ep.TargetPort.String() != "web" {
+		t.Fatalf("unexpected podMetricsEndpoint #1: %+v", ep)
+	}
+}
+
+func TestParseProbeSuccess(t *testing.T) {
+	data := `
+{
+  "metadata": {
+    "name": "blackbox",
+    "namespace": "default"
+  },
+  "spec": {
+    "jobName": "http-probe",
+    "prober": {"url": "blackbox-exporter:9115", "path": "/probe"},
+    "module": "http_2xx",
+    "targets": {
+      "staticConfig": {
+        "static": ["https://example.com", "https://example.org"],
+        "labels": {"env": "prod"}
+      }
+    }
+  }
+}
+`
+	o, err := parseProbe([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p := o.(*Probe)
+	spec := &p.Spec
+	if spec.JobName != "http-probe" || spec.Module != "http_2xx" {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+	proberExpected := ProberSpec{
+		URL:  "blackbox-exporter:9115",
+		Path: "/probe",
+	}
+	if !reflect.DeepEqual(spec.ProberSpec, proberExpected) {
+		t.Fatalf("unexpected prober\ngot\n%+v\nwant\n%+v", spec.ProberSpec, proberExpected)
+	}
+	stc := spec.Targets.StaticConfig
+	if stc == nil {
+		t.Fatalf("missing staticConfig")
+	}
+	if !reflect.DeepEqual(stc.Targets, []string{"https://example.com", "https://example.org"}) || stc.Labels["env"] != "prod" {
+		t.Fatalf("unexpected staticConfig: %+v", stc)
+	}
+}
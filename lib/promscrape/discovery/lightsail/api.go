@@ -0,0 +1,85 @@
+package lightsail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/awsapi"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+)
+
+var configMap = discoveryutil.NewConfigMap()
+
+type apiConfig struct {
+	client    *discoveryutil.Client
+	awsConfig *awsapi.Config
+	region    string
+	port      int
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	awsCfg, err := awsapi.NewConfig("", "", sdc.Region, sdc.RoleARN, sdc.AccessKey, sdc.SecretKey.String(), "lightsail")
+	if err != nil {
+		return nil, err
+	}
+	region := awsCfg.GetRegion()
+	apiServer := sdc.Endpoint
+	if apiServer == "" {
+		apiServer = fmt.Sprintf("https://lightsail.%s.amazonaws.com", region)
+	}
+	apiServer = strings.TrimSuffix(apiServer, "/")
+
+	ac, err := sdc.HTTPClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+	client, err := discoveryutil.NewClient(apiServer, ac, sdc.ProxyURL, proxyAC, &sdc.HTTPClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+	port := 80
+	if sdc.Port != nil {
+		port = *sdc.Port
+	}
+	cfg := &apiConfig{
+		client:    client,
+		awsConfig: awsCfg,
+		region:    region,
+		port:      port,
+	}
+	return cfg, nil
+}
+
+// getAPIResponse performs signed Lightsail API request for the given action with the given JSON body.
+//
+// See https://docs.aws.amazon.com/lightsail/2016-11-28/api-reference/Welcome.html
+func (cfg *apiConfig) getAPIResponse(action string, body []byte) ([]byte, error) {
+	var signErr error
+	data, err := cfg.client.GetAPIResponseWithReqParams("/", func(req *http.Request) {
+		req.Method = http.MethodPost
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "Lightsail_20161128."+action)
+		signErr = cfg.awsConfig.SignRequest(req, awsapi.HashHex(body))
+	})
+	if signErr != nil {
+		return nil, fmt.Errorf("cannot sign request for %s: %w", action, signErr)
+	}
+	return data, err
+}
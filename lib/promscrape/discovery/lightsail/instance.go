@@ -0,0 +1,123 @@
+package lightsail
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// InstancesResponse represents the response for GetInstances Lightsail API call.
+//
+// See https://docs.aws.amazon.com/lightsail/2016-11-28/api-reference/API_GetInstances.html
+type InstancesResponse struct {
+	Instances     []Instance `json:"instances"`
+	NextPageToken string     `json:"nextPageToken"`
+}
+
+// Instance represents Lightsail instance.
+//
+// See https://docs.aws.amazon.com/lightsail/2016-11-28/api-reference/API_Instance.html
+type Instance struct {
+	Name             string           `json:"name"`
+	SupportCode      string           `json:"supportCode"`
+	Location         InstanceLocation `json:"location"`
+	BlueprintID      string           `json:"blueprintId"`
+	BundleID         string           `json:"bundleId"`
+	State            InstanceState    `json:"state"`
+	PrivateIPAddress string           `json:"privateIpAddress"`
+	PublicIPAddress  string           `json:"publicIpAddress"`
+	IPv6Addresses    []string         `json:"ipv6Addresses"`
+	Tags             []Tag            `json:"tags"`
+}
+
+// InstanceLocation represents Lightsail instance location.
+type InstanceLocation struct {
+	AvailabilityZone string `json:"availabilityZone"`
+	RegionName       string `json:"regionName"`
+}
+
+// InstanceState represents Lightsail instance state.
+type InstanceState struct {
+	Name string `json:"name"`
+}
+
+// Tag represents Lightsail resource tag.
+type Tag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func getInstancesLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	instances, err := getInstances(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var ms []*promutil.Labels
+	for i := range instances {
+		ms = instances[i].appendTargetLabels(ms, cfg.region, cfg.port)
+	}
+	return ms, nil
+}
+
+func getInstances(cfg *apiConfig) ([]Instance, error) {
+	var instances []Instance
+	pageToken := ""
+	for {
+		req := make(map[string]string)
+		if pageToken != "" {
+			req["pageToken"] = pageToken
+		}
+		body, err := json.Marshal(req)
+		if err != nil {
+			return nil, fmt.Errorf("cannot marshal request body: %w", err)
+		}
+		data, err := cfg.getAPIResponse("GetInstances", body)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain Lightsail instances: %w", err)
+		}
+		var ir InstancesResponse
+		if err := json.Unmarshal(data, &ir); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal InstancesResponse: %w; response=%q", err, data)
+		}
+		instances = append(instances, ir.Instances...)
+		if ir.NextPageToken == "" {
+			return instances, nil
+		}
+		pageToken = ir.NextPageToken
+	}
+}
+
+func (inst *Instance) appendTargetLabels(ms []*promutil.Labels, region string, port int) []*promutil.Labels {
+	if inst.PrivateIPAddress == "" {
+		return ms
+	}
+	m := promutil.NewLabels(16)
+	m.Add("__address__", discoveryutil.JoinHostPort(inst.PrivateIPAddress, port))
+	m.Add("__meta_lightsail_availability_zone", inst.Location.AvailabilityZone)
+	m.Add("__meta_lightsail_blueprint_id", inst.BlueprintID)
+	m.Add("__meta_lightsail_bundle_id", inst.BundleID)
+	m.Add("__meta_lightsail_instance_name", inst.Name)
+	m.Add("__meta_lightsail_instance_state", inst.State.Name)
+	m.Add("__meta_lightsail_instance_support_code", inst.SupportCode)
+	m.Add("__meta_lightsail_private_ip", inst.PrivateIPAddress)
+	m.Add("__meta_lightsail_region", region)
+	if len(inst.IPv6Addresses) > 0 {
+		// Put separators at the beginning and the end of the list,
+		// so the addresses could be matched with `.*,addr,.*` regexps.
+		m.Add("__meta_lightsail_ipv6_addresses", ","+strings.Join(inst.IPv6Addresses, ",")+",")
+	}
+	if inst.PublicIPAddress != "" {
+		m.Add("__meta_lightsail_public_ip", inst.PublicIPAddress)
+	}
+	for _, t := range inst.Tags {
+		if t.Key == "" || t.Value == "" {
+			continue
+		}
+		m.Add(discoveryutil.SanitizeLabelName("__meta_lightsail_tag_"+t.Key), t.Value)
+	}
+	ms = append(ms, m)
+	return ms
+}
@@ -0,0 +1,122 @@
+package lightsail
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestGetInstancesLabels(t *testing.T) {
+	pages := map[string]string{
+		`{}`: `{
+  "instances": [
+    {
+      "name": "web-1",
+      "supportCode": "123456789012/i-0123456789abcdef0",
+      "location": {"availabilityZone": "eu-west-1a", "regionName": "eu-west-1"},
+      "blueprintId": "ubuntu_22_04",
+      "bundleId": "nano_3_0",
+      "state": {"code": 16, "name": "running"},
+      "privateIpAddress": "172.26.1.10",
+      "publicIpAddress": "34.245.10.11",
+      "ipv6Addresses": ["2a05:d018::1", "2a05:d018::2"],
+      "tags": [{"key": "env", "value": "prod"}, {"key": "team.name", "value": "infra"}, {"key": "empty"}]
+    }
+  ],
+  "nextPageToken": "page2"
+}`,
+		`{"pageToken":"page2"}`: `{
+  "instances": [
+    {
+      "name": "db-1",
+      "supportCode": "123456789012/i-0fedcba9876543210",
+      "location": {"availabilityZone": "eu-west-1b", "regionName": "eu-west-1"},
+      "blueprintId": "debian_12",
+      "bundleId": "small_3_0",
+      "state": {"code": 80, "name": "stopped"},
+      "privateIpAddress": "172.26.2.20"
+    },
+    {
+      "name": "pending",
+      "state": {"code": 0, "name": "pending"}
+    }
+  ]
+}`,
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("X-Amz-Target") != "Lightsail_20161128.GetInstances" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIAEXAMPLE/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp, ok := pages[string(body)]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(resp))
+	}))
+	defer s.Close()
+
+	port := 9100
+	sdc := &SDConfig{
+		Endpoint:  s.URL,
+		Region:    "eu-west-1",
+		AccessKey: "AKIAEXAMPLE",
+		SecretKey: promauth.NewSecret("secret"),
+		Port:      &port,
+	}
+	cfg, err := newAPIConfig(sdc, ".")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer cfg.client.Stop()
+
+	labelss, err := getInstancesLabels(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	labelssExpected := []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                            "172.26.1.10:9100",
+			"__meta_lightsail_availability_zone":     "eu-west-1a",
+			"__meta_lightsail_blueprint_id":          "ubuntu_22_04",
+			"__meta_lightsail_bundle_id":             "nano_3_0",
+			"__meta_lightsail_instance_name":         "web-1",
+			"__meta_lightsail_instance_state":        "running",
+			"__meta_lightsail_instance_support_code": "123456789012/i-0123456789abcdef0",
+			"__meta_lightsail_ipv6_addresses":        ",2a05:d018::1,2a05:d018::2,",
+			"__meta_lightsail_private_ip":            "172.26.1.10",
+			"__meta_lightsail_public_ip":             "34.245.10.11",
+			"__meta_lightsail_region":                "eu-west-1",
+			"__meta_lightsail_tag_env":               "prod",
+			"__meta_lightsail_tag_team_name":         "infra",
+		}),
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                            "172.26.2.20:9100",
+			"__meta_lightsail_availability_zone":     "eu-west-1b",
+			"__meta_lightsail_blueprint_id":          "debian_12",
+			"__meta_lightsail_bundle_id":             "small_3_0",
+			"__meta_lightsail_instance_name":         "db-1",
+			"__meta_lightsail_instance_state":        "stopped",
+			"__meta_lightsail_instance_support_code": "123456789012/i-0fedcba9876543210",
+			"__meta_lightsail_private_ip":            "172.26.2.20",
+			"__meta_lightsail_region":                "eu-west-1",
+		}),
+	}
+	discoveryutil.TestEqualLabelss(t, labelss, labelssExpected)
+}
@@ -0,0 +1,52 @@
+package lightsail
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
+)
+
+// SDCheckInterval defines interval for targets refresh.
+var SDCheckInterval = flag.Duration("promscrape.lightsailSDCheckInterval", time.Minute, "Interval for checking for changes in AWS Lightsail. "+
+	"This works only if lightsail_sd_configs is configured in '-promscrape.config' file. "+
+	"See https://docs.victoriametrics.com/victoriametrics/sd_configs/#lightsail_sd_configs for details")
+
+// SDConfig represents service discovery config for AWS Lightsail.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#lightsail_sd_config
+type SDConfig struct {
+	Endpoint  string           `yaml:"endpoint,omitempty"`
+	Region    string           `yaml:"region,omitempty"`
+	AccessKey string           `yaml:"access_key,omitempty"`
+	SecretKey *promauth.Secret `yaml:"secret_key,omitempty"`
+	RoleARN   string           `yaml:"role_arn,omitempty"`
+	Port      *int             `yaml:"port,omitempty"`
+
+	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
+	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
+	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
+
+	// refresh_interval is obtained from `-promscrape.lightsailSDCheckInterval` command-line option.
+}
+
+// GetLabels returns AWS Lightsail instances' labels according to sdc.
+func (sdc *SDConfig) GetLabels(baseDir string) ([]*promutil.Labels, error) {
+	cfg, err := getAPIConfig(sdc, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get API config: %w", err)
+	}
+	return getInstancesLabels(cfg)
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *SDConfig) MustStop() {
+	v := configMap.Delete(sdc)
+	if v != nil {
+		cfg := v.(*apiConfig)
+		cfg.client.Stop()
+	}
+}
@@ -0,0 +1,116 @@
+package linode
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+)
+
+var configMap = discoveryutil.NewConfigMap()
+
+type apiConfig struct {
+	client       *discoveryutil.Client
+	port         int
+	tagSeparator string
+
+	// filter contains optional value for X-Filter request header.
+	// See https://techdocs.akamai.com/linode-api/reference/filtering-and-sorting
+	filter string
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	hcc := sdc.HTTPClientConfig
+	if hcc.Authorization == nil && hcc.BearerToken == nil && hcc.BearerTokenFile == "" {
+		return nil, fmt.Errorf("missing `authorization` option with Linode API token")
+	}
+
+	// See https://techdocs.akamai.com/linode-api/reference/api
+	apiServer := "https://api.linode.com"
+
+	ac, err := hcc.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+	client, err := discoveryutil.NewClient(apiServer, ac, sdc.ProxyURL, proxyAC, &sdc.HTTPClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+
+	port := 80
+	if sdc.Port != nil {
+		port = *sdc.Port
+	}
+	tagSeparator := ","
+	if sdc.TagSeparator != "" {
+		tagSeparator = sdc.TagSeparator
+	}
+	var filter string
+	if sdc.Region != "" {
+		data, err := json.Marshal(map[string]string{
+			"region": sdc.Region,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("BUG: cannot marshal region filter: %w", err)
+		}
+		filter = string(data)
+	}
+	cfg := &apiConfig{
+		client:       client,
+		port:         port,
+		tagSeparator: tagSeparator,
+		filter:       filter,
+	}
+	return cfg, nil
+}
+
+// listResponse is a paginated response from Linode API.
+//
+// See https://techdocs.akamai.com/linode-api/reference/pagination
+type listResponse[T any] struct {
+	Data  []T `json:"data"`
+	Page  int `json:"page"`
+	Pages int `json:"pages"`
+}
+
+// getAllPages returns all the items from paginated Linode API at the given path.
+func getAllPages[T any](cfg *apiConfig, path string, useFilter bool) ([]T, error) {
+	var modifyRequest discoveryutil.RequestCallback
+	if useFilter && cfg.filter != "" {
+		modifyRequest = func(req *http.Request) {
+			req.Header.Set("X-Filter", cfg.filter)
+		}
+	}
+	var items []T
+	page := 1
+	for {
+		pagePath := path + "?page_size=500&page=" + strconv.Itoa(page)
+		data, err := cfg.client.GetAPIResponseWithReqParams(pagePath, modifyRequest)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain response from %q: %w", pagePath, err)
+		}
+		var resp listResponse[T]
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal response from %q: %w; response=%q", pagePath, err, data)
+		}
+		items = append(items, resp.Data...)
+		if resp.Page >= resp.Pages {
+			return items, nil
+		}
+		page++
+	}
+}
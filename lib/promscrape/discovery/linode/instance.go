@@ -0,0 +1,177 @@
+package linode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// Instance represents Linode instance.
+//
+// See https://techdocs.akamai.com/linode-api/reference/get-linode-instances
+type Instance struct {
+	ID         int            `json:"id"`
+	Label      string         `json:"label"`
+	Group      string         `json:"group"`
+	Status     string         `json:"status"`
+	Type       string         `json:"type"`
+	Region     string         `json:"region"`
+	Image      string         `json:"image"`
+	IPv4       []string       `json:"ipv4"`
+	IPv6       string         `json:"ipv6"`
+	Hypervisor string         `json:"hypervisor"`
+	Specs      InstanceSpecs  `json:"specs"`
+	Backups    InstanceBackup `json:"backups"`
+	Tags       []string       `json:"tags"`
+}
+
+// InstanceSpecs represents Linode instance specs.
+//
+// Disk, Memory and Transfer are in MB.
+type InstanceSpecs struct {
+	Disk     int64 `json:"disk"`
+	Memory   int64 `json:"memory"`
+	VCPUs    int   `json:"vcpus"`
+	Transfer int64 `json:"transfer"`
+	GPUs     int   `json:"gpus"`
+}
+
+// InstanceBackup represents Linode instance backups info.
+type InstanceBackup struct {
+	Enabled bool `json:"enabled"`
+}
+
+// IPAddress represents Linode IP address.
+//
+// See https://techdocs.akamai.com/linode-api/reference/get-ips
+type IPAddress struct {
+	Address string `json:"address"`
+	Public  bool   `json:"public"`
+	RDNS    string `json:"rdns"`
+	Type    string `json:"type"`
+}
+
+// IPv6Range represents Linode IPv6 range.
+//
+// See https://techdocs.akamai.com/linode-api/reference/get-ipv6-ranges
+type IPv6Range struct {
+	Range       string `json:"range"`
+	Prefix      int    `json:"prefix"`
+	RouteTarget string `json:"route_target"`
+}
+
+func getInstancesLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	instances, err := getAllPages[Instance](cfg, "/v4/linode/instances", true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain Linode instances: %w", err)
+	}
+	ips, err := getAllPages[IPAddress](cfg, "/v4/networking/ips", true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain Linode IP addresses: %w", err)
+	}
+	ipv6Ranges, err := getAllPages[IPv6Range](cfg, "/v4/networking/ipv6/ranges", false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain Linode IPv6 ranges: %w", err)
+	}
+	return getInstanceLabels(instances, ips, ipv6Ranges, cfg.port, cfg.tagSeparator), nil
+}
+
+func getInstanceLabels(instances []Instance, ips []IPAddress, ipv6Ranges []IPv6Range, port int, tagSeparator string) []*promutil.Labels {
+	ipsByAddress := make(map[string]*IPAddress, len(ips))
+	for i := range ips {
+		ip := &ips[i]
+		ipsByAddress[ip.Address] = ip
+	}
+
+	var ms []*promutil.Labels
+	for i := range instances {
+		instance := &instances[i]
+		if len(instance.IPv4) == 0 {
+			continue
+		}
+
+		var privateIPv4, publicIPv4, publicIPv6 string
+		var privateIPv4RDNS, publicIPv4RDNS, publicIPv6RDNS string
+		var extraIPs, instanceIPv6Ranges []string
+		for _, addr := range instance.IPv4 {
+			ip := ipsByAddress[addr]
+			if ip == nil {
+				continue
+			}
+			switch {
+			case ip.Public && publicIPv4 == "":
+				publicIPv4 = ip.Address
+				publicIPv4RDNS = getRDNS(ip)
+			case !ip.Public && privateIPv4 == "":
+				privateIPv4 = ip.Address
+				privateIPv4RDNS = getRDNS(ip)
+			default:
+				extraIPs = append(extraIPs, ip.Address)
+			}
+		}
+		if instance.IPv6 != "" {
+			addr, _, _ := strings.Cut(instance.IPv6, "/")
+			if ip := ipsByAddress[addr]; ip != nil {
+				publicIPv6 = ip.Address
+				publicIPv6RDNS = getRDNS(ip)
+			}
+			for _, r := range ipv6Ranges {
+				if r.RouteTarget == addr {
+					instanceIPv6Ranges = append(instanceIPv6Ranges, fmt.Sprintf("%s/%d", r.Range, r.Prefix))
+				}
+			}
+		}
+		backups := "disabled"
+		if instance.Backups.Enabled {
+			backups = "enabled"
+		}
+
+		m := promutil.NewLabels(24)
+		m.Add("__address__", discoveryutil.JoinHostPort(publicIPv4, port))
+		m.Add("__meta_linode_instance_id", strconv.Itoa(instance.ID))
+		m.Add("__meta_linode_instance_label", instance.Label)
+		m.Add("__meta_linode_image", instance.Image)
+		m.Add("__meta_linode_private_ipv4", privateIPv4)
+		m.Add("__meta_linode_public_ipv4", publicIPv4)
+		m.Add("__meta_linode_public_ipv6", publicIPv6)
+		m.Add("__meta_linode_private_ipv4_rdns", privateIPv4RDNS)
+		m.Add("__meta_linode_public_ipv4_rdns", publicIPv4RDNS)
+		m.Add("__meta_linode_public_ipv6_rdns", publicIPv6RDNS)
+		m.Add("__meta_linode_region", instance.Region)
+		m.Add("__meta_linode_type", instance.Type)
+		m.Add("__meta_linode_status", instance.Status)
+		m.Add("__meta_linode_group", instance.Group)
+		m.Add("__meta_linode_gpus", strconv.Itoa(instance.Specs.GPUs))
+		m.Add("__meta_linode_hypervisor", instance.Hypervisor)
+		m.Add("__meta_linode_backups", backups)
+		m.Add("__meta_linode_specs_disk_bytes", strconv.FormatInt(instance.Specs.Disk<<20, 10))
+		m.Add("__meta_linode_specs_memory_bytes", strconv.FormatInt(instance.Specs.Memory<<20, 10))
+		m.Add("__meta_linode_specs_vcpus", strconv.Itoa(instance.Specs.VCPUs))
+		m.Add("__meta_linode_specs_transfer_bytes", strconv.FormatInt(instance.Specs.Transfer<<20, 10))
+		if len(instance.Tags) > 0 {
+			m.Add("__meta_linode_tags", joinWithSeparator(instance.Tags, tagSeparator))
+		}
+		if len(extraIPs) > 0 {
+			m.Add("__meta_linode_extra_ips", joinWithSeparator(extraIPs, tagSeparator))
+		}
+		if len(instanceIPv6Ranges) > 0 {
+			m.Add("__meta_linode_ipv6_ranges", joinWithSeparator(instanceIPv6Ranges, tagSeparator))
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+func getRDNS(ip *IPAddress) string {
+	if ip.RDNS == "null" {
+		return ""
+	}
+	return ip.RDNS
+}
+
+func joinWithSeparator(a []string, separator string) string {
+	return separator + strings.Join(a, separator) + separator
+}
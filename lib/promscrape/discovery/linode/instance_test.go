@@ -0,0 +1,214 @@
+package linode
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestNewAPIConfig(t *testing.T) {
+	f := func(sdc *SDConfig, isErrorExpected bool) {
+		t.Helper()
+		_, err := newAPIConfig(sdc, ".")
+		if isErrorExpected && err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if !isErrorExpected && err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// missing token
+	f(&SDConfig{}, true)
+
+	// valid config
+	f(&SDConfig{
+		Region: "us-east",
+		HTTPClientConfig: promauth.HTTPClientConfig{
+			Authorization: &promauth.Authorization{
+				Credentials: promauth.NewSecret("foobar"),
+			},
+		},
+	}, false)
+}
+
+func TestGetInstancesLabels(t *testing.T) {
+	responses := map[string]string{
+		"/v4/linode/instances?page_size=500&page=1": `{
+  "data": [
+    {
+      "id": 40000001,
+      "label": "prometheus-linode-sd-exporter-1",
+      "group": "",
+      "status": "running",
+      "type": "g6-standard-2",
+      "region": "us-east",
+      "image": "linode/arch",
+      "ipv4": ["45.33.82.151", "96.126.108.37", "192.168.170.51", "192.168.201.25"],
+      "ipv6": "2600:3c03::f03c:92ff:fe1a:1382/128",
+      "hypervisor": "kvm",
+      "specs": {"disk": 81920, "memory": 4096, "vcpus": 2, "gpus": 0, "transfer": 4000},
+      "backups": {"enabled": false},
+      "tags": ["monitoring"]
+    },
+    {
+      "id": 40000002,
+      "label": "no-ips",
+      "status": "offline",
+      "ipv4": []
+    }
+  ],
+  "page": 1,
+  "pages": 2,
+  "results": 3
+}`,
+		"/v4/linode/instances?page_size=500&page=2": `{
+  "data": [
+    {
+      "id": 40000003,
+      "label": "prometheus-linode-sd-exporter-3",
+      "group": "test",
+      "status": "running",
+      "type": "g6-nanode-1",
+      "region": "ca-central",
+      "image": "linode/ubuntu22.04",
+      "ipv4": ["139.162.153.128"],
+      "ipv6": "2a01:7e00::f03c:93ff:fe11:a93c/128",
+      "hypervisor": "kvm",
+      "specs": {"disk": 25600, "memory": 1024, "vcpus": 1, "gpus": 0, "transfer": 1000},
+      "backups": {"enabled": true},
+      "tags": []
+    }
+  ],
+  "page": 2,
+  "pages": 2,
+  "results": 3
+}`,
+		"/v4/networking/ips?page_size=500&page=1": `{
+  "data": [
+    {"address": "45.33.82.151", "public": true, "rdns": "li1028-151.members.linode.com", "type": "ipv4", "linode_id": 40000001},
+    {"address": "96.126.108.37", "public": true, "rdns": "li176-37.members.linode.com", "type": "ipv4", "linode_id": 40000001},
+    {"address": "192.168.170.51", "public": false, "rdns": null, "type": "ipv4", "linode_id": 40000001},
+    {"address": "192.168.201.25", "public": false, "rdns": null, "type": "ipv4", "linode_id": 40000001},
+    {"address": "2600:3c03::f03c:92ff:fe1a:1382", "public": true, "rdns": "li1028-151.members.linode.com", "type": "ipv6/slaac", "linode_id": 40000001},
+    {"address": "139.162.153.128", "public": true, "rdns": "null", "type": "ipv4", "linode_id": 40000003},
+    {"address": "2a01:7e00::f03c:93ff:fe11:a93c", "public": true, "rdns": "", "type": "ipv6/slaac", "linode_id": 40000003}
+  ],
+  "page": 1,
+  "pages": 1,
+  "results": 7
+}`,
+		"/v4/networking/ipv6/ranges?page_size=500&page=1": `{
+  "data": [
+    {"range": "2600:3c03:e000:123::", "prefix": 64, "region": "us-east", "route_target": "2600:3c03::f03c:92ff:fe1a:1382"}
+  ],
+  "page": 1,
+  "pages": 1,
+  "results": 1
+}`,
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer foobar" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		resp, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(resp))
+	}))
+	defer s.Close()
+
+	hcc := &promauth.HTTPClientConfig{
+		Authorization: &promauth.Authorization{
+			Credentials: promauth.NewSecret("foobar"),
+		},
+	}
+	ac, err := hcc.NewConfig(".")
+	if err != nil {
+		t.Fatalf("unexpected error when creating auth config: %s", err)
+	}
+	client, err := discoveryutil.NewClient(s.URL, ac, nil, nil, hcc)
+	if err != nil {
+		t.Fatalf("unexpected error when creating http client: %s", err)
+	}
+	defer client.Stop()
+	cfg := &apiConfig{
+		client:       client,
+		port:         9100,
+		tagSeparator: ",",
+	}
+
+	labelss, err := getInstancesLabels(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	labelssExpected := []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                        "45.33.82.151:9100",
+			"__meta_linode_instance_id":          "40000001",
+			"__meta_linode_instance_label":       "prometheus-linode-sd-exporter-1",
+			"__meta_linode_image":                "linode/arch",
+			"__meta_linode_private_ipv4":         "192.168.170.51",
+			"__meta_linode_public_ipv4":          "45.33.82.151",
+			"__meta_linode_public_ipv6":          "2600:3c03::f03c:92ff:fe1a:1382",
+			"__meta_linode_private_ipv4_rdns":    "",
+			"__meta_linode_public_ipv4_rdns":     "li1028-151.members.linode.com",
+			"__meta_linode_public_ipv6_rdns":     "li1028-151.members.linode.com",
+			"__meta_linode_region":               "us-east",
+			"__meta_linode_type":                 "g6-standard-2",
+			"__meta_linode_status":               "running",
+			"__meta_linode_group":                "",
+			"__meta_linode_gpus":                 "0",
+			"__meta_linode_hypervisor":           "kvm",
+			"__meta_linode_backups":              "disabled",
+			"__meta_linode_specs_disk_bytes":     "85899345920",
+			"__meta_linode_specs_memory_bytes":   "4294967296",
+			"__meta_linode_specs_vcpus":          "2",
+			"__meta_linode_specs_transfer_bytes": "4194304000",
+			"__meta_linode_tags":                 ",monitoring,",
+			"__meta_linode_extra_ips":            ",96.126.108.37,192.168.201.25,",
+			"__meta_linode_ipv6_ranges":          ",2600:3c03:e000:123::/64,",
+		}),
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                        "139.162.153.128:9100",
+			"__meta_linode_instance_id":          "40000003",
+			"__meta_linode_instance_label":       "prometheus-linode-sd-exporter-3",
+			"__meta_linode_image":                "linode/ubuntu22.04",
+			"__meta_linode_private_ipv4":         "",
+			"__meta_linode_public_ipv4":          "139.162.153.128",
+			"__meta_linode_public_ipv6":          "2a01:7e00::f03c:93ff:fe11:a93c",
+			"__meta_linode_private_ipv4_rdns":    "",
+			"__meta_linode_public_ipv4_rdns":     "",
+			"__meta_linode_public_ipv6_rdns":     "",
+			"__meta_linode_region":               "ca-central",
+			"__meta_linode_type":                 "g6-nanode-1",
+			"__meta_linode_status":               "running",
+			"__meta_linode_group":                "test",
+			"__meta_linode_gpus":                 "0",
+			"__meta_linode_hypervisor":           "kvm",
+			"__meta_linode_backups":              "enabled",
+			"__meta_linode_specs_disk_bytes":     "26843545600",
+			"__meta_linode_specs_memory_bytes":   "1073741824",
+			"__meta_linode_specs_vcpus":          "1",
+			"__meta_linode_specs_transfer_bytes": "1048576000",
+		}),
+	}
+	discoveryutil.TestEqualLabelss(t, labelss, labelssExpected)
+
+	// API errors must be returned
+	cfg.client, err = discoveryutil.NewClient(s.URL, nil, nil, nil, &promauth.HTTPClientConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error when creating http client: %s", err)
+	}
+	defer cfg.client.Stop()
+	if _, err := getInstancesLabels(cfg); err == nil {
+		t.Fatalf("expecting non-nil error for unauthorized request")
+	}
+}
@@ -0,0 +1,129 @@
+package scaleway
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs/fscore"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+)
+
+var configMap = discoveryutil.NewConfigMap()
+
+type apiConfig struct {
+	client *discoveryutil.Client
+	role   string
+	zone   string
+	port   int
+
+	// listQueryArgs contains query args for filtering the listed servers.
+	listQueryArgs url.Values
+
+	secretKey     string
+	secretKeyFile string
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	switch sdc.Role {
+	case "instance", "baremetal":
+	default:
+		return nil, fmt.Errorf("unexpected `role`: %q; must be one of `instance` or `baremetal`", sdc.Role)
+	}
+	if sdc.ProjectID == "" {
+		return nil, fmt.Errorf("missing `project_id` option")
+	}
+	if sdc.AccessKey == "" {
+		return nil, fmt.Errorf("missing `access_key` option")
+	}
+	if sdc.SecretKey == nil && sdc.SecretKeyFile == "" {
+		return nil, fmt.Errorf("missing `secret_key` or `secret_key_file` option")
+	}
+	if sdc.SecretKey != nil && sdc.SecretKeyFile != "" {
+		return nil, fmt.Errorf("only one of `secret_key` or `secret_key_file` option must be set")
+	}
+
+	apiServer := sdc.APIURL
+	if apiServer == "" {
+		// See https://www.scaleway.com/en/developers/api/
+		apiServer = "https://api.scaleway.com"
+	}
+	apiServer = strings.TrimSuffix(apiServer, "/")
+	zone := sdc.Zone
+	if zone == "" {
+		zone = "fr-par-1"
+	}
+
+	ac, err := sdc.HTTPClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+	client, err := discoveryutil.NewClient(apiServer, ac, sdc.ProxyURL, proxyAC, &sdc.HTTPClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+
+	port := 80
+	if sdc.Port != nil {
+		port = *sdc.Port
+	}
+	qa := make(url.Values)
+	if sdc.NameFilter != "" {
+		qa.Set("name", sdc.NameFilter)
+	}
+	if len(sdc.TagsFilter) > 0 {
+		qa.Set("tags", strings.Join(sdc.TagsFilter, ","))
+	}
+	cfg := &apiConfig{
+		client:        client,
+		role:          sdc.Role,
+		zone:          zone,
+		port:          port,
+		listQueryArgs: qa,
+	}
+	if sdc.SecretKey != nil {
+		cfg.secretKey = sdc.SecretKey.String()
+	} else {
+		cfg.secretKeyFile = fscore.GetFilepath(baseDir, sdc.SecretKeyFile)
+	}
+	switch sdc.Role {
+	case "instance":
+		cfg.listQueryArgs.Set("project", sdc.ProjectID)
+	case "baremetal":
+		cfg.listQueryArgs.Set("project_id", sdc.ProjectID)
+	}
+	return cfg, nil
+}
+
+// getAPIResponse returns response for the given path from Scaleway API.
+func (cfg *apiConfig) getAPIResponse(path string) ([]byte, error) {
+	secretKey := cfg.secretKey
+	if cfg.secretKeyFile != "" {
+		// Re-read the secret key on every request in order to support secret key rotation.
+		s, err := fscore.ReadPasswordFromFileOrHTTP(cfg.secretKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read `secret_key_file`: %w", err)
+		}
+		secretKey = s
+	}
+	return cfg.client.GetAPIResponseWithReqParams(path, func(req *http.Request) {
+		req.Header.Set("X-Auth-Token", secretKey)
+	})
+}
+
+func joinWithSeparator(a []string) string {
+	return "," + strings.Join(a, ",") + ","
+}
@@ -0,0 +1,176 @@
+package scaleway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// BaremetalServerList represents the response for Scaleway Elastic Metal servers list API.
+//
+// See https://www.scaleway.com/en/developers/api/elastic-metal/#path-elastic-metal-servers-list-elastic-metal-servers-for-an-organization
+type BaremetalServerList struct {
+	Servers []BaremetalServer `json:"servers"`
+}
+
+// BaremetalServer represents Scaleway Elastic Metal server.
+type BaremetalServer struct {
+	ID        string                  `json:"id"`
+	Name      string                  `json:"name"`
+	ProjectID string                  `json:"project_id"`
+	Status    string                  `json:"status"`
+	OfferName string                  `json:"offer_name"`
+	Zone      string                  `json:"zone"`
+	Tags      []string                `json:"tags"`
+	IPs       []BaremetalIP           `json:"ips"`
+	Install   *BaremetalServerInstall `json:"install"`
+}
+
+// BaremetalIP represents Scaleway Elastic Metal server IP address.
+type BaremetalIP struct {
+	Address string `json:"address"`
+	Version string `json:"version"`
+}
+
+// BaremetalServerInstall represents Scaleway Elastic Metal server installation info.
+type BaremetalServerInstall struct {
+	OSID string `json:"os_id"`
+}
+
+// BaremetalOSList represents the response for Scaleway Elastic Metal OS list API.
+//
+// See https://www.scaleway.com/en/developers/api/elastic-metal/#path-operating-systems-list-available-operating-systems
+type BaremetalOSList struct {
+	OS []BaremetalOS `json:"os"`
+}
+
+// BaremetalOS represents Scaleway Elastic Metal OS.
+type BaremetalOS struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func getBaremetalServerLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	servers, err := getBaremetalServers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	oss, err := getBaremetalOSs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	osByID := make(map[string]*BaremetalOS, len(oss))
+	for i := range oss {
+		osByID[oss[i].ID] = &oss[i]
+	}
+	var ms []*promutil.Labels
+	for i := range servers {
+		ms = appendBaremetalServerLabels(ms, &servers[i], osByID, cfg.port)
+	}
+	return ms, nil
+}
+
+func getBaremetalServers(cfg *apiConfig) ([]BaremetalServer, error) {
+	const pageSize = 100
+	var servers []BaremetalServer
+	for page := 1; ; page++ {
+		qa := cloneQueryArgs(cfg.listQueryArgs)
+		qa.Set("page", strconv.Itoa(page))
+		qa.Set("page_size", strconv.Itoa(pageSize))
+		path := fmt.Sprintf("/baremetal/v1/zones/%s/servers?%s", cfg.zone, qa.Encode())
+		data, err := cfg.getAPIResponse(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain Scaleway baremetal servers: %w", err)
+		}
+		var sl BaremetalServerList
+		if err := json.Unmarshal(data, &sl); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal BaremetalServerList from %q: %w; response=%q", path, err, data)
+		}
+		servers = append(servers, sl.Servers...)
+		if len(sl.Servers) < pageSize {
+			return servers, nil
+		}
+	}
+}
+
+func getBaremetalOSs(cfg *apiConfig) ([]BaremetalOS, error) {
+	const pageSize = 100
+	var oss []BaremetalOS
+	for page := 1; ; page++ {
+		path := fmt.Sprintf("/baremetal/v1/zones/%s/os?page=%d&page_size=%d", cfg.zone, page, pageSize)
+		data, err := cfg.getAPIResponse(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain Scaleway baremetal operating systems: %w", err)
+		}
+		var ol BaremetalOSList
+		if err := json.Unmarshal(data, &ol); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal BaremetalOSList from %q: %w; response=%q", path, err, data)
+		}
+		oss = append(oss, ol.OS...)
+		if len(ol.OS) < pageSize {
+			return oss, nil
+		}
+	}
+}
+
+func appendBaremetalServerLabels(ms []*promutil.Labels, server *BaremetalServer, osByID map[string]*BaremetalOS, port int) []*promutil.Labels {
+	m := promutil.NewLabels(14)
+	var publicIPv4, publicIPv6 string
+	for _, ip := range server.IPs {
+		switch ip.Version {
+		case "IPv4":
+			if publicIPv4 == "" {
+				publicIPv4 = ip.Address
+			}
+		case "IPv6":
+			if publicIPv6 == "" {
+				publicIPv6 = ip.Address
+			}
+		}
+	}
+	addr := publicIPv4
+	if addr == "" {
+		addr = publicIPv6
+	}
+	if addr == "" {
+		// The server has no IP addresses, so it cannot be scraped.
+		return ms
+	}
+	m.Add("__address__", discoveryutil.JoinHostPort(addr, port))
+	if publicIPv4 != "" {
+		m.Add("__meta_scaleway_baremetal_public_ipv4", publicIPv4)
+	}
+	if publicIPv6 != "" {
+		m.Add("__meta_scaleway_baremetal_public_ipv6", publicIPv6)
+	}
+	m.Add("__meta_scaleway_baremetal_id", server.ID)
+	m.Add("__meta_scaleway_baremetal_name", server.Name)
+	m.Add("__meta_scaleway_baremetal_project_id", server.ProjectID)
+	m.Add("__meta_scaleway_baremetal_status", server.Status)
+	m.Add("__meta_scaleway_baremetal_type", server.OfferName)
+	m.Add("__meta_scaleway_baremetal_zone", server.Zone)
+	if server.Install != nil {
+		if bos := osByID[server.Install.OSID]; bos != nil {
+			m.Add("__meta_scaleway_baremetal_os_name", bos.Name)
+			m.Add("__meta_scaleway_baremetal_os_version", bos.Version)
+		}
+	}
+	if len(server.Tags) > 0 {
+		m.Add("__meta_scaleway_baremetal_tags", joinWithSeparator(server.Tags))
+	}
+	ms = append(ms, m)
+	return ms
+}
+
+func cloneQueryArgs(qa url.Values) url.Values {
+	dst := make(url.Values, len(qa)+2)
+	for k, vs := range qa {
+		dst[k] = append([]string{}, vs...)
+	}
+	return dst
+}
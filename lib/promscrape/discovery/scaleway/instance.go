@@ -0,0 +1,179 @@
+package scaleway
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// InstanceServerList represents the response for Scaleway instance servers list API.
+//
+// See https://www.scaleway.com/en/developers/api/instance/#path-instances-list-all-instances
+type InstanceServerList struct {
+	Servers []InstanceServer `json:"servers"`
+}
+
+// InstanceServer represents Scaleway instance server.
+type InstanceServer struct {
+	ID             string                 `json:"id"`
+	Name           string                 `json:"name"`
+	Organization   string                 `json:"organization"`
+	Project        string                 `json:"project"`
+	Hostname       string                 `json:"hostname"`
+	CommercialType string                 `json:"commercial_type"`
+	State          string                 `json:"state"`
+	BootType       string                 `json:"boot_type"`
+	Zone           string                 `json:"zone"`
+	Tags           []string               `json:"tags"`
+	Image          *InstanceImage         `json:"image"`
+	PrivateIP      *string                `json:"private_ip"`
+	PublicIP       *InstanceIP            `json:"public_ip"`
+	PublicIPs      []InstanceIP           `json:"public_ips"`
+	IPv6           *InstanceIP            `json:"ipv6"`
+	Location       *InstanceLocation      `json:"location"`
+	SecurityGroup  *InstanceSecurityGroup `json:"security_group"`
+}
+
+// InstanceImage represents Scaleway instance image.
+type InstanceImage struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Arch string `json:"arch"`
+}
+
+// InstanceIP represents Scaleway instance IP address.
+type InstanceIP struct {
+	Address string `json:"address"`
+	Family  string `json:"family"`
+}
+
+// InstanceLocation represents Scaleway instance location.
+type InstanceLocation struct {
+	ClusterID    string `json:"cluster_id"`
+	HypervisorID string `json:"hypervisor_id"`
+	NodeID       string `json:"node_id"`
+}
+
+// InstanceSecurityGroup represents Scaleway instance security group.
+type InstanceSecurityGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func getInstanceServerLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	servers, err := getInstanceServers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var ms []*promutil.Labels
+	for i := range servers {
+		ms = appendInstanceServerLabels(ms, &servers[i], cfg.port)
+	}
+	return ms, nil
+}
+
+func getInstanceServers(cfg *apiConfig) ([]InstanceServer, error) {
+	const perPage = 100
+	var servers []InstanceServer
+	for page := 1; ; page++ {
+		qa := cloneQueryArgs(cfg.listQueryArgs)
+		qa.Set("page", strconv.Itoa(page))
+		qa.Set("per_page", strconv.Itoa(perPage))
+		path := fmt.Sprintf("/instance/v1/zones/%s/servers?%s", cfg.zone, qa.Encode())
+		data, err := cfg.getAPIResponse(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain Scaleway instance servers: %w", err)
+		}
+		var sl InstanceServerList
+		if err := json.Unmarshal(data, &sl); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal InstanceServerList from %q: %w; response=%q", path, err, data)
+		}
+		servers = append(servers, sl.Servers...)
+		if len(sl.Servers) < perPage {
+			return servers, nil
+		}
+	}
+}
+
+func appendInstanceServerLabels(ms []*promutil.Labels, server *InstanceServer, port int) []*promutil.Labels {
+	m := promutil.NewLabels(28)
+	var addr string
+	if server.IPv6 != nil && server.IPv6.Address != "" {
+		m.Add("__meta_scaleway_instance_public_ipv6", server.IPv6.Address)
+		addr = server.IPv6.Address
+	}
+	if server.PublicIP != nil && server.PublicIP.Address != "" {
+		m.Add("__meta_scaleway_instance_public_ipv4", server.PublicIP.Address)
+		addr = server.PublicIP.Address
+	}
+	if server.PrivateIP != nil && *server.PrivateIP != "" {
+		m.Add("__meta_scaleway_instance_private_ipv4", *server.PrivateIP)
+		addr = *server.PrivateIP
+	}
+	if addr == "" {
+		// The server has no IP addresses, so it cannot be scraped.
+		return ms
+	}
+	m.Add("__address__", discoveryutil.JoinHostPort(addr, port))
+
+	var ipv4Addresses, ipv6Addresses []string
+	for _, ip := range server.PublicIPs {
+		switch ip.Family {
+		case "inet":
+			ipv4Addresses = append(ipv4Addresses, ip.Address)
+		case "inet6":
+			ipv6Addresses = append(ipv6Addresses, ip.Address)
+		}
+	}
+	if len(ipv4Addresses) > 0 {
+		m.Add("__meta_scaleway_instance_public_ipv4_addresses", strings.Join(ipv4Addresses, ","))
+	}
+	if len(ipv6Addresses) > 0 {
+		m.Add("__meta_scaleway_instance_public_ipv6_addresses", strings.Join(ipv6Addresses, ","))
+	}
+
+	m.Add("__meta_scaleway_instance_boot_type", server.BootType)
+	m.Add("__meta_scaleway_instance_hostname", server.Hostname)
+	m.Add("__meta_scaleway_instance_id", server.ID)
+	m.Add("__meta_scaleway_instance_name", server.Name)
+	m.Add("__meta_scaleway_instance_organization_id", server.Organization)
+	m.Add("__meta_scaleway_instance_project_id", server.Project)
+	m.Add("__meta_scaleway_instance_status", server.State)
+	m.Add("__meta_scaleway_instance_type", server.CommercialType)
+	m.Add("__meta_scaleway_instance_zone", server.Zone)
+	m.Add("__meta_scaleway_instance_region", getRegionFromZone(server.Zone))
+	if server.Image != nil {
+		m.Add("__meta_scaleway_instance_image_arch", server.Image.Arch)
+		m.Add("__meta_scaleway_instance_image_id", server.Image.ID)
+		m.Add("__meta_scaleway_instance_image_name", server.Image.Name)
+	}
+	if server.Location != nil {
+		m.Add("__meta_scaleway_instance_location_cluster_id", server.Location.ClusterID)
+		m.Add("__meta_scaleway_instance_location_hypervisor_id", server.Location.HypervisorID)
+		m.Add("__meta_scaleway_instance_location_node_id", server.Location.NodeID)
+	}
+	if server.SecurityGroup != nil {
+		m.Add("__meta_scaleway_instance_security_group_id", server.SecurityGroup.ID)
+		m.Add("__meta_scaleway_instance_security_group_name", server.SecurityGroup.Name)
+	}
+	if len(server.Tags) > 0 {
+		m.Add("__meta_scaleway_instance_tags", joinWithSeparator(server.Tags))
+	}
+	ms = append(ms, m)
+	return ms
+}
+
+// getRegionFromZone returns Scaleway region for the given zone.
+//
+// For example, `fr-par` region is returned for `fr-par-1` zone.
+func getRegionFromZone(zone string) string {
+	n := strings.LastIndexByte(zone, '-')
+	if n < 0 {
+		return zone
+	}
+	return zone[:n]
+}
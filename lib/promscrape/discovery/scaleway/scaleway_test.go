@@ -0,0 +1,230 @@
+package scaleway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestNewAPIConfigFailure(t *testing.T) {
+	f := func(sdc *SDConfig) {
+		t.Helper()
+		_, err := newAPIConfig(sdc, ".")
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing role
+	f(&SDConfig{
+		ProjectID: "p",
+		AccessKey: "a",
+		SecretKey: promauth.NewSecret("s"),
+	})
+
+	// unsupported role
+	f(&SDConfig{
+		Role:      "foobar",
+		ProjectID: "p",
+		AccessKey: "a",
+		SecretKey: promauth.NewSecret("s"),
+	})
+
+	// missing project_id
+	f(&SDConfig{
+		Role:      "instance",
+		AccessKey: "a",
+		SecretKey: promauth.NewSecret("s"),
+	})
+
+	// missing secret_key
+	f(&SDConfig{
+		Role:      "instance",
+		ProjectID: "p",
+		AccessKey: "a",
+	})
+
+	// both secret_key and secret_key_file
+	f(&SDConfig{
+		Role:          "baremetal",
+		ProjectID:     "p",
+		AccessKey:     "a",
+		SecretKey:     promauth.NewSecret("s"),
+		SecretKeyFile: "/path/to/file",
+	})
+}
+
+func newTestAPIConfig(t *testing.T, role string, responses map[string]string) *apiConfig {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		resp, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(resp))
+	}))
+	t.Cleanup(s.Close)
+
+	port := 9100
+	sdc := &SDConfig{
+		Role:       role,
+		ProjectID:  "proj-1",
+		AccessKey:  "access",
+		SecretKey:  promauth.NewSecret("secret"),
+		APIURL:     s.URL,
+		NameFilter: "web",
+		Port:       &port,
+	}
+	cfg, err := newAPIConfig(sdc, ".")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	t.Cleanup(cfg.client.Stop)
+	return cfg
+}
+
+func TestGetInstanceServerLabels(t *testing.T) {
+	cfg := newTestAPIConfig(t, "instance", map[string]string{
+		"/instance/v1/zones/fr-par-1/servers?name=web&page=1&per_page=100&project=proj-1": `{
+  "servers": [
+    {
+      "id": "93c18a61-b681-49d0-a1cc-62b43883ae89",
+      "name": "web-1",
+      "organization": "20b3d507-96ac-454c-a795-bc731b46b12f",
+      "project": "proj-1",
+      "hostname": "web-1",
+      "commercial_type": "DEV1-S",
+      "state": "running",
+      "boot_type": "local",
+      "zone": "fr-par-1",
+      "tags": ["prod", "web"],
+      "image": {"id": "71733b74-260f-4d0d-8f18-f37dcfa56d6f", "name": "Debian Buster", "arch": "x86_64"},
+      "private_ip": "10.70.60.57",
+      "public_ip": {"id": "ip-1", "address": "51.158.183.115", "family": "inet"},
+      "public_ips": [
+        {"id": "ip-1", "address": "51.158.183.115", "family": "inet"},
+        {"id": "ip-2", "address": "2001:bc8:630:1e44::1", "family": "inet6"}
+      ],
+      "ipv6": {"address": "2001:bc8:630:1e44::1", "gateway": "2001:bc8:630:1e44::", "netmask": "64"},
+      "location": {"cluster_id": "40", "hypervisor_id": "1601", "node_id": "29", "zone_id": "par1"},
+      "security_group": {"id": "984414da-9fc2-49c0-a925-fed6266fe092", "name": "Default security group"}
+    },
+    {
+      "id": "5b6198b4-c677-41b5-9c05-04557264ae1f",
+      "name": "web-2",
+      "organization": "20b3d507-96ac-454c-a795-bc731b46b12f",
+      "project": "proj-1",
+      "hostname": "web-2",
+      "commercial_type": "GP1-XS",
+      "state": "stopped",
+      "boot_type": "local",
+      "zone": "fr-par-1",
+      "tags": [],
+      "image": null,
+      "private_ip": null,
+      "public_ip": null,
+      "public_ips": [],
+      "ipv6": null,
+      "location": null,
+      "security_group": null
+    }
+  ]
+}`,
+	})
+	labelss, err := getInstanceServerLabels(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	labelssExpected := []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                                     "10.70.60.57:9100",
+			"__meta_scaleway_instance_boot_type":              "local",
+			"__meta_scaleway_instance_hostname":               "web-1",
+			"__meta_scaleway_instance_id":                     "93c18a61-b681-49d0-a1cc-62b43883ae89",
+			"__meta_scaleway_instance_image_arch":             "x86_64",
+			"__meta_scaleway_instance_image_id":               "71733b74-260f-4d0d-8f18-f37dcfa56d6f",
+			"__meta_scaleway_instance_image_name":             "Debian Buster",
+			"__meta_scaleway_instance_location_cluster_id":    "40",
+			"__meta_scaleway_instance_location_hypervisor_id": "1601",
+			"__meta_scaleway_instance_location_node_id":       "29",
+			"__meta_scaleway_instance_name":                   "web-1",
+			"__meta_scaleway_instance_organization_id":        "20b3d507-96ac-454c-a795-bc731b46b12f",
+			"__meta_scaleway_instance_private_ipv4":           "10.70.60.57",
+			"__meta_scaleway_instance_project_id":             "proj-1",
+			"__meta_scaleway_instance_public_ipv4":            "51.158.183.115",
+			"__meta_scaleway_instance_public_ipv6":            "2001:bc8:630:1e44::1",
+			"__meta_scaleway_instance_public_ipv4_addresses":  "51.158.183.115",
+			"__meta_scaleway_instance_public_ipv6_addresses":  "2001:bc8:630:1e44::1",
+			"__meta_scaleway_instance_region":                 "fr-par",
+			"__meta_scaleway_instance_security_group_id":      "984414da-9fc2-49c0-a925-fed6266fe092",
+			"__meta_scaleway_instance_security_group_name":    "Default security group",
+			"__meta_scaleway_instance_status":                 "running",
+			"__meta_scaleway_instance_tags":                   ",prod,web,",
+			"__meta_scaleway_instance_type":                   "DEV1-S",
+			"__meta_scaleway_instance_zone":                   "fr-par-1",
+		}),
+	}
+	discoveryutil.TestEqualLabelss(t, labelss, labelssExpected)
+}
+
+func TestGetBaremetalServerLabels(t *testing.T) {
+	cfg := newTestAPIConfig(t, "baremetal", map[string]string{
+		"/baremetal/v1/zones/fr-par-1/servers?name=web&page=1&page_size=100&project_id=proj-1": `{
+  "total_count": 1,
+  "servers": [
+    {
+      "id": "5ef5a3d1-3ec1-4a18-b66c-5a9c4b1b26d5",
+      "organization_id": "20b3d507-96ac-454c-a795-bc731b46b12f",
+      "project_id": "proj-1",
+      "name": "web-bm",
+      "status": "ready",
+      "offer_id": "a5065ba4-dde2-45f3-adec-1ebbb27b766b",
+      "offer_name": "EM-B112X-SSD",
+      "zone": "fr-par-1",
+      "tags": ["bm"],
+      "ips": [
+        {"id": "ip-1", "address": "51.159.74.150", "reverse": "", "version": "IPv4"},
+        {"id": "ip-2", "address": "2001:bc8:1201:1a::1", "reverse": "", "version": "IPv6"}
+      ],
+      "install": {"os_id": "7e865c16-1a63-4dc7-8181-b3c8a9c1c3f6", "hostname": "web-bm"}
+    }
+  ]
+}`,
+		"/baremetal/v1/zones/fr-par-1/os?page=1&page_size=100": `{
+  "total_count": 1,
+  "os": [
+    {"id": "7e865c16-1a63-4dc7-8181-b3c8a9c1c3f6", "name": "Ubuntu", "version": "22.04 LTS (Jammy Jellyfish)"}
+  ]
+}`,
+	})
+	labelss, err := getBaremetalServerLabels(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	labelssExpected := []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                           "51.159.74.150:9100",
+			"__meta_scaleway_baremetal_id":          "5ef5a3d1-3ec1-4a18-b66c-5a9c4b1b26d5",
+			"__meta_scaleway_baremetal_name":        "web-bm",
+			"__meta_scaleway_baremetal_os_name":     "Ubuntu",
+			"__meta_scaleway_baremetal_os_version":  "22.04 LTS (Jammy Jellyfish)",
+			"__meta_scaleway_baremetal_project_id":  "proj-1",
+			"__meta_scaleway_baremetal_public_ipv4": "51.159.74.150",
+			"__meta_scaleway_baremetal_public_ipv6": "2001:bc8:1201:1a::1",
+			"__meta_scaleway_baremetal_status":      "ready",
+			"__meta_scaleway_baremetal_tags":        ",bm,",
+			"__meta_scaleway_baremetal_type":        "EM-B112X-SSD",
+			"__meta_scaleway_baremetal_zone":        "fr-par-1",
+		}),
+	}
+	discoveryutil.TestEqualLabelss(t, labelss, labelssExpected)
+}
@@ -0,0 +1,68 @@
+package stackit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+)
+
+var configMap = discoveryutil.NewConfigMap()
+
+type apiConfig struct {
+	client  *discoveryutil.Client
+	project string
+	port    int
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	if sdc.Project == "" {
+		return nil, fmt.Errorf("missing `project` option")
+	}
+	hcc := sdc.HTTPClientConfig
+	if hcc.Authorization == nil && hcc.BearerToken == nil && hcc.BearerTokenFile == "" {
+		return nil, fmt.Errorf("missing `authorization` option with STACKIT service account access token")
+	}
+
+	region := sdc.Region
+	if region == "" {
+		region = "eu01"
+	}
+	apiServer := sdc.Endpoint
+	if apiServer == "" {
+		// See https://docs.api.stackit.cloud/documentation/iaas/version/v1
+		apiServer = fmt.Sprintf("https://iaas.api.%s.stackit.cloud", region)
+	}
+	apiServer = strings.TrimSuffix(apiServer, "/")
+
+	ac, err := hcc.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+	client, err := discoveryutil.NewClient(apiServer, ac, sdc.ProxyURL, proxyAC, &sdc.HTTPClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+	port := 80
+	if sdc.Port != nil {
+		port = *sdc.Port
+	}
+	cfg := &apiConfig{
+		client:  client,
+		project: sdc.Project,
+		port:    port,
+	}
+	return cfg, nil
+}
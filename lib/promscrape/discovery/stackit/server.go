@@ -0,0 +1,104 @@
+package stackit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// ServerList represents STACKIT servers list.
+//
+// See https://docs.api.stackit.cloud/documentation/iaas/version/v1#tag/Servers/operation/v1ListServersInProject
+type ServerList struct {
+	Items []Server `json:"items"`
+}
+
+// Server represents STACKIT server.
+type Server struct {
+	ID               string         `json:"id"`
+	Name             string         `json:"name"`
+	Status           string         `json:"status"`
+	PowerStatus      string         `json:"powerStatus"`
+	AvailabilityZone string         `json:"availabilityZone"`
+	MachineType      string         `json:"machineType"`
+	Labels           map[string]any `json:"labels"`
+	Nics             []ServerNic    `json:"nics"`
+}
+
+// ServerNic represents STACKIT server network interface.
+type ServerNic struct {
+	NetworkName string `json:"networkName"`
+	IPv4        string `json:"ipv4"`
+	PublicIP    string `json:"publicIp"`
+}
+
+func getServersLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	path := fmt.Sprintf("/v1/projects/%s/servers?details=true", url.PathEscape(cfg.project))
+	data, err := cfg.client.GetAPIResponse(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain STACKIT servers: %w", err)
+	}
+	var sl ServerList
+	if err := json.Unmarshal(data, &sl); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal ServerList from %q: %w; response=%q", path, err, data)
+	}
+	return getServerLabels(sl.Items, cfg.project, cfg.port), nil
+}
+
+func getServerLabels(servers []Server, project string, port int) []*promutil.Labels {
+	var ms []*promutil.Labels
+	for i := range servers {
+		server := &servers[i]
+
+		m := promutil.NewLabels(16)
+		var addr, publicIP string
+		for _, nic := range server.Nics {
+			if nic.PublicIP != "" && publicIP == "" {
+				publicIP = nic.PublicIP
+				addr = publicIP
+			}
+			if nic.IPv4 != "" {
+				m.Add(discoveryutil.SanitizeLabelName("__meta_stackit_private_ipv4_"+nic.NetworkName), nic.IPv4)
+				if addr == "" {
+					addr = nic.IPv4
+				}
+			}
+		}
+		if addr == "" {
+			// The server has no IP addresses, so it cannot be scraped.
+			continue
+		}
+		m.Add("__address__", discoveryutil.JoinHostPort(addr, port))
+		if publicIP != "" {
+			m.Add("__meta_stackit_public_ipv4", publicIP)
+		}
+		m.Add("__meta_stackit_project", project)
+		m.Add("__meta_stackit_id", server.ID)
+		m.Add("__meta_stackit_name", server.Name)
+		m.Add("__meta_stackit_status", server.Status)
+		m.Add("__meta_stackit_power_status", server.PowerStatus)
+		m.Add("__meta_stackit_availability_zone", server.AvailabilityZone)
+		m.Add("__meta_stackit_type", server.MachineType)
+
+		keys := make([]string, 0, len(server.Labels))
+		for k := range server.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			// Only string labels are exposed, since STACKIT API allows arbitrary JSON values in labels.
+			v, ok := server.Labels[k].(string)
+			if !ok {
+				continue
+			}
+			m.Add(discoveryutil.SanitizeLabelName("__meta_stackit_label_"+k), v)
+			m.Add(discoveryutil.SanitizeLabelName("__meta_stackit_labelpresent_"+k), "true")
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
@@ -0,0 +1,134 @@
+package stackit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestNewAPIConfigFailure(t *testing.T) {
+	f := func(sdc *SDConfig) {
+		t.Helper()
+		_, err := newAPIConfig(sdc, ".")
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing project
+	f(&SDConfig{
+		HTTPClientConfig: promauth.HTTPClientConfig{
+			BearerToken: promauth.NewSecret("foobar"),
+		},
+	})
+
+	// missing auth
+	f(&SDConfig{
+		Project: "e6f3a8a4-0d6d-4bdb-a7e4-3fd6d7c5b3b2",
+	})
+}
+
+func TestGetServersLabels(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer foobar" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.RequestURI() != "/v1/projects/e6f3a8a4-0d6d-4bdb-a7e4-3fd6d7c5b3b2/servers?details=true" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{
+  "items": [
+    {
+      "id": "b4176700-596a-4f80-9fc8-5f9c58a606e1",
+      "name": "metrics-1",
+      "status": "ACTIVE",
+      "powerStatus": "RUNNING",
+      "availabilityZone": "eu01-3",
+      "machineType": "c1.2",
+      "labels": {"env": "prod", "app.kubernetes.io/name": "node-exporter", "replicas": 3},
+      "nics": [
+        {"networkId": "net-1", "networkName": "backend-net", "ipv4": "10.0.0.12"},
+        {"networkId": "net-2", "networkName": "public", "ipv4": "10.1.0.5", "publicIp": "193.148.160.10"}
+      ]
+    },
+    {
+      "id": "0c47b8b6-6cd9-4b0e-8b7b-fd6bba0b38e3",
+      "name": "private-only",
+      "status": "ACTIVE",
+      "powerStatus": "RUNNING",
+      "availabilityZone": "eu01-1",
+      "machineType": "g1.1",
+      "nics": [
+        {"networkId": "net-1", "networkName": "backend-net", "ipv4": "10.0.0.13"}
+      ]
+    },
+    {
+      "id": "80e0ec79-1ed4-4a4a-9f1e-3a1e4be0b1a7",
+      "name": "no-nics",
+      "status": "INACTIVE",
+      "powerStatus": "STOPPED",
+      "availabilityZone": "eu01-1",
+      "machineType": "g1.1"
+    }
+  ]
+}`))
+	}))
+	defer s.Close()
+
+	port := 9100
+	sdc := &SDConfig{
+		Project:  "e6f3a8a4-0d6d-4bdb-a7e4-3fd6d7c5b3b2",
+		Endpoint: s.URL,
+		Port:     &port,
+		HTTPClientConfig: promauth.HTTPClientConfig{
+			BearerToken: promauth.NewSecret("foobar"),
+		},
+	}
+	cfg, err := newAPIConfig(sdc, ".")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer cfg.client.Stop()
+
+	labelss, err := getServersLabels(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	labelssExpected := []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                                        "193.148.160.10:9100",
+			"__meta_stackit_availability_zone":                   "eu01-3",
+			"__meta_stackit_id":                                  "b4176700-596a-4f80-9fc8-5f9c58a606e1",
+			"__meta_stackit_label_app_kubernetes_io_name":        "node-exporter",
+			"__meta_stackit_label_env":                           "prod",
+			"__meta_stackit_labelpresent_app_kubernetes_io_name": "true",
+			"__meta_stackit_labelpresent_env":                    "true",
+			"__meta_stackit_name":                                "metrics-1",
+			"__meta_stackit_power_status":                        "RUNNING",
+			"__meta_stackit_private_ipv4_backend_net":            "10.0.0.12",
+			"__meta_stackit_private_ipv4_public":                 "10.1.0.5",
+			"__meta_stackit_project":                             "e6f3a8a4-0d6d-4bdb-a7e4-3fd6d7c5b3b2",
+			"__meta_stackit_public_ipv4":                         "193.148.160.10",
+			"__meta_stackit_status":                              "ACTIVE",
+			"__meta_stackit_type":                                "c1.2",
+		}),
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                             "10.0.0.13:9100",
+			"__meta_stackit_availability_zone":        "eu01-1",
+			"__meta_stackit_id":                       "0c47b8b6-6cd9-4b0e-8b7b-fd6bba0b38e3",
+			"__meta_stackit_name":                     "private-only",
+			"__meta_stackit_power_status":             "RUNNING",
+			"__meta_stackit_private_ipv4_backend_net": "10.0.0.13",
+			"__meta_stackit_project":                  "e6f3a8a4-0d6d-4bdb-a7e4-3fd6d7c5b3b2",
+			"__meta_stackit_status":                   "ACTIVE",
+			"__meta_stackit_type":                     "g1.1",
+		}),
+	}
+	discoveryutil.TestEqualLabelss(t, labelss, labelssExpected)
+}
@@ -0,0 +1,52 @@
+package stackit
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
+)
+
+// SDCheckInterval defines interval for targets refresh.
+var SDCheckInterval = flag.Duration("promscrape.stackitSDCheckInterval", time.Minute, "Interval for checking for changes in STACKIT. "+
+	"This works only if stackit_sd_configs is configured in '-promscrape.config' file. "+
+	"See https://docs.victoriametrics.com/victoriametrics/sd_configs/#stackit_sd_configs for details")
+
+// SDConfig represents service discovery config for STACKIT.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#stackit_sd_config
+//
+// Only token-based authorization is supported at the moment, e.g. service account keys aren't supported.
+type SDConfig struct {
+	Project  string `yaml:"project"`
+	Region   string `yaml:"region,omitempty"`
+	Endpoint string `yaml:"endpoint,omitempty"`
+	Port     *int   `yaml:"port,omitempty"`
+
+	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
+	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
+	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
+
+	// refresh_interval is obtained from `-promscrape.stackitSDCheckInterval` command-line option.
+}
+
+// GetLabels returns STACKIT servers' labels according to sdc.
+func (sdc *SDConfig) GetLabels(baseDir string) ([]*promutil.Labels, error) {
+	cfg, err := getAPIConfig(sdc, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get API config: %w", err)
+	}
+	return getServersLabels(cfg)
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *SDConfig) MustStop() {
+	v := configMap.Delete(sdc)
+	if v != nil {
+		cfg := v.(*apiConfig)
+		cfg.client.Stop()
+	}
+}
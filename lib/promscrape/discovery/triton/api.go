@@ -0,0 +1,86 @@
+package triton
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+)
+
+var configMap = discoveryutil.NewConfigMap()
+
+type apiConfig struct {
+	client    *discoveryutil.Client
+	role      string
+	dnsSuffix string
+	port      int
+
+	// queryArgs contains optional `groups` filter for the discovery API.
+	queryArgs string
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	if sdc.Account == "" {
+		return nil, fmt.Errorf("missing `account` option")
+	}
+	if sdc.DNSSuffix == "" {
+		return nil, fmt.Errorf("missing `dns_suffix` option")
+	}
+	if sdc.Endpoint == "" {
+		return nil, fmt.Errorf("missing `endpoint` option")
+	}
+	role := sdc.Role
+	if role == "" {
+		role = "container"
+	}
+	if role != "container" && role != "cn" {
+		return nil, fmt.Errorf("unsupported `role`: %q; supported values: container, cn", role)
+	}
+	port := 9163
+	if sdc.Port != nil {
+		port = *sdc.Port
+	}
+	version := 1
+	if sdc.Version != nil {
+		version = *sdc.Version
+	}
+
+	// Triton CMON discovery API is always served over https.
+	// See https://github.com/TritonDataCenter/triton-cmon
+	apiServer := fmt.Sprintf("https://%s/v%d", discoveryutil.JoinHostPort(sdc.Endpoint, port), version)
+
+	ac, err := sdc.HTTPClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+	client, err := discoveryutil.NewClient(apiServer, ac, sdc.ProxyURL, proxyAC, &sdc.HTTPClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+
+	var queryArgs string
+	if len(sdc.Groups) > 0 {
+		queryArgs = "?groups=" + url.QueryEscape(strings.Join(sdc.Groups, ","))
+	}
+	cfg := &apiConfig{
+		client:    client,
+		role:      role,
+		dnsSuffix: sdc.DNSSuffix,
+		port:      port,
+		queryArgs: queryArgs,
+	}
+	return cfg, nil
+}
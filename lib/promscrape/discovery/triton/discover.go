@@ -0,0 +1,88 @@
+package triton
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// DiscoveryResponse represents the response from Triton CMON discovery API for `container` role.
+//
+// See https://github.com/TritonDataCenter/sdc-cmon/blob/master/docs/README.md
+type DiscoveryResponse struct {
+	Containers []Container `json:"containers"`
+}
+
+// Container represents Triton container.
+type Container struct {
+	Groups      []string `json:"groups"`
+	ServerUUID  string   `json:"server_uuid"`
+	VMAlias     string   `json:"vm_alias"`
+	VMBrand     string   `json:"vm_brand"`
+	VMImageUUID string   `json:"vm_image_uuid"`
+	VMUUID      string   `json:"vm_uuid"`
+}
+
+// ComputeNodeDiscoveryResponse represents the response from Triton CMON discovery API for `cn` role.
+type ComputeNodeDiscoveryResponse struct {
+	ComputeNodes []ComputeNode `json:"cns"`
+}
+
+// ComputeNode represents Triton compute node.
+type ComputeNode struct {
+	ServerHostname string `json:"server_hostname"`
+	ServerUUID     string `json:"server_uuid"`
+}
+
+func getContainersLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	path := "/discover" + cfg.queryArgs
+	data, err := cfg.client.GetAPIResponse(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain Triton containers: %w", err)
+	}
+	var dr DiscoveryResponse
+	if err := json.Unmarshal(data, &dr); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal DiscoveryResponse from %q: %w; response=%q", path, err, data)
+	}
+	var ms []*promutil.Labels
+	for _, c := range dr.Containers {
+		m := promutil.NewLabels(8)
+		m.Add("__address__", discoveryutil.JoinHostPort(c.VMUUID+"."+cfg.dnsSuffix, cfg.port))
+		if len(c.Groups) > 0 {
+			// Groups are wrapped with separators in the same way as Prometheus does,
+			// so they can be matched with `.*,group,.*` regexps.
+			m.Add("__meta_triton_groups", ","+strings.Join(c.Groups, ",")+",")
+		}
+		m.Add("__meta_triton_machine_alias", c.VMAlias)
+		m.Add("__meta_triton_machine_brand", c.VMBrand)
+		m.Add("__meta_triton_machine_id", c.VMUUID)
+		m.Add("__meta_triton_machine_image", c.VMImageUUID)
+		m.Add("__meta_triton_server_id", c.ServerUUID)
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+func getComputeNodesLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	path := "/gz/discover"
+	data, err := cfg.client.GetAPIResponse(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain Triton compute nodes: %w", err)
+	}
+	var dr ComputeNodeDiscoveryResponse
+	if err := json.Unmarshal(data, &dr); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal ComputeNodeDiscoveryResponse from %q: %w; response=%q", path, err, data)
+	}
+	var ms []*promutil.Labels
+	for _, cn := range dr.ComputeNodes {
+		m := promutil.NewLabels(4)
+		m.Add("__address__", discoveryutil.JoinHostPort(cn.ServerUUID+"."+cfg.dnsSuffix, cfg.port))
+		m.Add("__meta_triton_machine_alias", cn.ServerHostname)
+		m.Add("__meta_triton_machine_id", cn.ServerUUID)
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
@@ -0,0 +1,145 @@
+package triton
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestNewAPIConfigFailure(t *testing.T) {
+	f := func(sdc *SDConfig) {
+		t.Helper()
+		_, err := newAPIConfig(sdc, ".")
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing account
+	f(&SDConfig{
+		DNSSuffix: "triton.example.com",
+		Endpoint:  "cmon.triton.example.com",
+	})
+
+	// missing dns_suffix
+	f(&SDConfig{
+		Account:  "testAccount",
+		Endpoint: "cmon.triton.example.com",
+	})
+
+	// missing endpoint
+	f(&SDConfig{
+		Account:   "testAccount",
+		DNSSuffix: "triton.example.com",
+	})
+
+	// unsupported role
+	f(&SDConfig{
+		Account:   "testAccount",
+		Role:      "foobar",
+		DNSSuffix: "triton.example.com",
+		Endpoint:  "cmon.triton.example.com",
+	})
+}
+
+func TestGetLabels(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.RequestURI() {
+		case "/v1/discover?groups=web%2Cdb":
+			w.Write([]byte(`{"containers":[
+  {
+    "groups": ["web", "db"],
+    "server_uuid": "44454c4c-5000-104d-8037-b7c04f5a5131",
+    "vm_alias": "server01",
+    "vm_brand": "lx",
+    "vm_image_uuid": "7b27a514-89d7-11e6-bee6-3f96f367bee7",
+    "vm_uuid": "ad466fbf-46a2-4027-9b64-8d3cdb7e9072"
+  },
+  {
+    "server_uuid": "a5894692-bd32-4ca1-908a-e2dda3c3a5e6",
+    "vm_alias": "server02",
+    "vm_brand": "kvm",
+    "vm_image_uuid": "a5894692-bd32-4ca1-908a-e2dda3c3a5e6",
+    "vm_uuid": "7b27a514-89d7-11e6-bee6-3f96f367bee7"
+  }
+]}`))
+		case "/v2/gz/discover":
+			w.Write([]byte(`{"cns":[
+  {"server_uuid": "44454c4c-5000-104d-8037-b7c04f5a5131", "server_hostname": "server01"}
+]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	host, portStr, err := net.SplitHostPort(s.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("cannot parse server address: %s", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("cannot parse server port: %s", err)
+	}
+
+	f := func(sdc *SDConfig, labelssExpected []*promutil.Labels) {
+		t.Helper()
+		sdc.Account = "testAccount"
+		sdc.DNSSuffix = "triton.example.com"
+		sdc.Endpoint = host
+		sdc.Port = &port
+		sdc.HTTPClientConfig = promauth.HTTPClientConfig{
+			TLSConfig: &promauth.TLSConfig{
+				InsecureSkipVerify: true,
+			},
+		}
+		labelss, err := sdc.GetLabels(".")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		sdc.MustStop()
+		discoveryutil.TestEqualLabelss(t, labelss, labelssExpected)
+	}
+
+	// container role
+	f(&SDConfig{
+		Groups: []string{"web", "db"},
+	}, []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                 "ad466fbf-46a2-4027-9b64-8d3cdb7e9072.triton.example.com:" + portStr,
+			"__meta_triton_groups":        ",web,db,",
+			"__meta_triton_machine_alias": "server01",
+			"__meta_triton_machine_brand": "lx",
+			"__meta_triton_machine_id":    "ad466fbf-46a2-4027-9b64-8d3cdb7e9072",
+			"__meta_triton_machine_image": "7b27a514-89d7-11e6-bee6-3f96f367bee7",
+			"__meta_triton_server_id":     "44454c4c-5000-104d-8037-b7c04f5a5131",
+		}),
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                 "7b27a514-89d7-11e6-bee6-3f96f367bee7.triton.example.com:" + portStr,
+			"__meta_triton_machine_alias": "server02",
+			"__meta_triton_machine_brand": "kvm",
+			"__meta_triton_machine_id":    "7b27a514-89d7-11e6-bee6-3f96f367bee7",
+			"__meta_triton_machine_image": "a5894692-bd32-4ca1-908a-e2dda3c3a5e6",
+			"__meta_triton_server_id":     "a5894692-bd32-4ca1-908a-e2dda3c3a5e6",
+		}),
+	})
+
+	// cn role
+	version := 2
+	f(&SDConfig{
+		Role:    "cn",
+		Version: &version,
+	}, []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                 "44454c4c-5000-104d-8037-b7c04f5a5131.triton.example.com:" + portStr,
+			"__meta_triton_machine_alias": "server01",
+			"__meta_triton_machine_id":    "44454c4c-5000-104d-8037-b7c04f5a5131",
+		}),
+	})
+}
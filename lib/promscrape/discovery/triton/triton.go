@@ -0,0 +1,60 @@
+package triton
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
+)
+
+// SDCheckInterval defines interval for targets refresh.
+var SDCheckInterval = flag.Duration("promscrape.tritonSDCheckInterval", time.Minute, "Interval for checking for changes in Triton. "+
+	"This works only if triton_sd_configs is configured in '-promscrape.config' file. "+
+	"See https://docs.victoriametrics.com/victoriametrics/sd_configs/#triton_sd_configs for details")
+
+// SDConfig represents service discovery config for Triton.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#triton_sd_config
+type SDConfig struct {
+	Account   string   `yaml:"account"`
+	Role      string   `yaml:"role,omitempty"`
+	DNSSuffix string   `yaml:"dns_suffix"`
+	Endpoint  string   `yaml:"endpoint"`
+	Groups    []string `yaml:"groups,omitempty"`
+	Port      *int     `yaml:"port,omitempty"`
+	Version   *int     `yaml:"version,omitempty"`
+
+	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
+	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
+	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
+
+	// refresh_interval is obtained from `-promscrape.tritonSDCheckInterval` command-line option.
+}
+
+// GetLabels returns Triton labels according to sdc.
+func (sdc *SDConfig) GetLabels(baseDir string) ([]*promutil.Labels, error) {
+	cfg, err := getAPIConfig(sdc, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get API config: %w", err)
+	}
+	switch cfg.role {
+	case "container":
+		return getContainersLabels(cfg)
+	case "cn":
+		return getComputeNodesLabels(cfg)
+	default:
+		return nil, fmt.Errorf("skipping unexpected role=%q; must be one of `container` or `cn`", cfg.role)
+	}
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *SDConfig) MustStop() {
+	v := configMap.Delete(sdc)
+	if v != nil {
+		cfg := v.(*apiConfig)
+		cfg.client.Stop()
+	}
+}
@@ -0,0 +1,103 @@
+package uyuni
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+)
+
+var configMap = discoveryutil.NewConfigMap()
+
+type apiConfig struct {
+	client      *discoveryutil.Client
+	username    string
+	password    string
+	entitlement string
+	separator   string
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (any, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	if sdc.Server == "" {
+		return nil, fmt.Errorf("missing `server` option")
+	}
+	u, err := url.Parse(sdc.Server)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `server` option %q: %w", sdc.Server, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("`server` option must contain scheme and host; got %q", sdc.Server)
+	}
+	if sdc.Username == "" {
+		return nil, fmt.Errorf("missing `username` option")
+	}
+	if sdc.Password == nil || sdc.Password.String() == "" {
+		return nil, fmt.Errorf("missing `password` option")
+	}
+	entitlement := sdc.Entitlement
+	if entitlement == "" {
+		entitlement = "monitoring_entitled"
+	}
+	separator := sdc.Separator
+	if separator == "" {
+		separator = ","
+	}
+
+	apiServer := strings.TrimSuffix(sdc.Server, "/")
+	ac, err := sdc.HTTPClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+	client, err := discoveryutil.NewClient(apiServer, ac, sdc.ProxyURL, proxyAC, &sdc.HTTPClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+	cfg := &apiConfig{
+		client:      client,
+		username:    sdc.Username,
+		password:    sdc.Password.String(),
+		entitlement: entitlement,
+		separator:   separator,
+	}
+	return cfg, nil
+}
+
+// call performs XML-RPC call for the given method with the given params at Uyuni API.
+//
+// See https://www.uyuni-project.org/uyuni-docs-api/uyuni/index.html
+func (cfg *apiConfig) call(method string, params ...any) (any, error) {
+	body, err := marshalMethodCall(method, params)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal %s call: %w", method, err)
+	}
+	data, err := cfg.client.GetAPIResponseWithReqParams("/rpc/api", func(req *http.Request) {
+		req.Method = http.MethodPost
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Content-Type", "text/xml")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot perform %s call: %w", method, err)
+	}
+	v, err := unmarshalMethodResponse(data)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected response for %s call: %w", method, err)
+	}
+	return v, nil
+}
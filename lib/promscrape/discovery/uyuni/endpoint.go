@@ -0,0 +1,204 @@
+package uyuni
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+// systemGroups represents system with its groups returned by system.listSystemGroupsForSystemsWithEntitlement.
+type systemGroups struct {
+	SystemID int
+	Groups   []string
+}
+
+// endpointInfo represents exporter endpoint returned by system.monitoring.listEndpoints.
+type endpointInfo struct {
+	SystemID     int
+	EndpointName string
+	Port         int
+	Path         string
+	ExporterName string
+	Module       string
+	TLSEnabled   bool
+}
+
+// networkInfo represents network info returned by system.getNetworkForSystems.
+type networkInfo struct {
+	SystemID    int
+	Hostname    string
+	PrimaryFQDN string
+}
+
+func getEndpointsLabels(cfg *apiConfig) ([]*promutil.Labels, error) {
+	v, err := cfg.call("auth.login", cfg.username, cfg.password)
+	if err != nil {
+		return nil, err
+	}
+	token, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected auth.login response; got %T; want string", v)
+	}
+	defer func() {
+		if _, err := cfg.call("auth.logout", token); err != nil {
+			logger.Warnf("cannot log out from Uyuni server %q: %s", cfg.client.APIServer(), err)
+		}
+	}()
+
+	systems, err := getSystemGroups(cfg, token)
+	if err != nil {
+		return nil, err
+	}
+	if len(systems) == 0 {
+		return nil, nil
+	}
+	systemIDs := make([]int, 0, len(systems))
+	groupsBySystemID := make(map[int][]string, len(systems))
+	for _, s := range systems {
+		systemIDs = append(systemIDs, s.SystemID)
+		groupsBySystemID[s.SystemID] = s.Groups
+	}
+	endpoints, err := getEndpoints(cfg, token, systemIDs)
+	if err != nil {
+		return nil, err
+	}
+	networkInfos, err := getNetworkInfos(cfg, token, systemIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var ms []*promutil.Labels
+	for _, ep := range endpoints {
+		ni, ok := networkInfos[ep.SystemID]
+		if !ok {
+			continue
+		}
+		host := ni.PrimaryFQDN
+		if host == "" {
+			host = ni.Hostname
+		}
+		scheme := "http"
+		if ep.TLSEnabled {
+			scheme = "https"
+		}
+		m := promutil.NewLabels(12)
+		m.Add("__address__", discoveryutil.JoinHostPort(host, ep.Port))
+		m.Add("__meta_uyuni_endpoint_name", ep.EndpointName)
+		m.Add("__meta_uyuni_exporter", ep.ExporterName)
+		m.Add("__meta_uyuni_groups", strings.Join(groupsBySystemID[ep.SystemID], cfg.separator))
+		m.Add("__meta_uyuni_metrics_path", ep.Path)
+		m.Add("__meta_uyuni_minion_hostname", ni.Hostname)
+		m.Add("__meta_uyuni_primary_fqdn", ni.PrimaryFQDN)
+		m.Add("__meta_uyuni_proxy_module", ep.Module)
+		m.Add("__meta_uyuni_scheme", scheme)
+		m.Add("__meta_uyuni_system_id", strconv.Itoa(ep.SystemID))
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+func getSystemGroups(cfg *apiConfig, token string) ([]systemGroups, error) {
+	v, err := cfg.call("system.listSystemGroupsForSystemsWithEntitlement", token, cfg.entitlement)
+	if err != nil {
+		return nil, err
+	}
+	items, err := getStructs(v)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected system.listSystemGroupsForSystemsWithEntitlement response: %w", err)
+	}
+	systems := make([]systemGroups, 0, len(items))
+	for _, item := range items {
+		groups, err := getStructs(item["system_groups"])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected system_groups in system.listSystemGroupsForSystemsWithEntitlement response: %w", err)
+		}
+		var groupNames []string
+		for _, g := range groups {
+			groupNames = append(groupNames, getString(g, "name"))
+		}
+		systems = append(systems, systemGroups{
+			SystemID: getInt(item, "id"),
+			Groups:   groupNames,
+		})
+	}
+	return systems, nil
+}
+
+func getEndpoints(cfg *apiConfig, token string, systemIDs []int) ([]endpointInfo, error) {
+	v, err := cfg.call("system.monitoring.listEndpoints", token, systemIDs)
+	if err != nil {
+		return nil, err
+	}
+	items, err := getStructs(v)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected system.monitoring.listEndpoints response: %w", err)
+	}
+	endpoints := make([]endpointInfo, 0, len(items))
+	for _, item := range items {
+		tlsEnabled, _ := item["tls_enabled"].(bool)
+		endpoints = append(endpoints, endpointInfo{
+			SystemID:     getInt(item, "system_id"),
+			EndpointName: getString(item, "endpoint_name"),
+			Port:         getInt(item, "port"),
+			Path:         getString(item, "path"),
+			ExporterName: getString(item, "exporter_name"),
+			Module:       getString(item, "module"),
+			TLSEnabled:   tlsEnabled,
+		})
+	}
+	return endpoints, nil
+}
+
+func getNetworkInfos(cfg *apiConfig, token string, systemIDs []int) (map[int]networkInfo, error) {
+	v, err := cfg.call("system.getNetworkForSystems", token, systemIDs)
+	if err != nil {
+		return nil, err
+	}
+	items, err := getStructs(v)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected system.getNetworkForSystems response: %w", err)
+	}
+	m := make(map[int]networkInfo, len(items))
+	for _, item := range items {
+		ni := networkInfo{
+			SystemID:    getInt(item, "system_id"),
+			Hostname:    getString(item, "hostname"),
+			PrimaryFQDN: getString(item, "primary_fqdn"),
+		}
+		m[ni.SystemID] = ni
+	}
+	return m, nil
+}
+
+func getStructs(v any) ([]map[string]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	a, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("got %T; want array", v)
+	}
+	items := make([]map[string]any, 0, len(a))
+	for _, x := range a {
+		m, ok := x.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("got array item %T; want struct", x)
+		}
+		items = append(items, m)
+	}
+	return items, nil
+}
+
+func getString(m map[string]any, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func getInt(m map[string]any, key string) int {
+	n, _ := m[key].(int)
+	return n
+}
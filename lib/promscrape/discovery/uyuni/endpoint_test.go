@@ -0,0 +1,183 @@
+package uyuni
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discoveryutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestNewAPIConfigFailure(t *testing.T) {
+	f := func(sdc *SDConfig) {
+		t.Helper()
+		_, err := newAPIConfig(sdc, ".")
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing server
+	f(&SDConfig{
+		Username: "admin",
+		Password: promauth.NewSecret("secret"),
+	})
+
+	// server without scheme
+	f(&SDConfig{
+		Server:   "uyuni.example.com",
+		Username: "admin",
+		Password: promauth.NewSecret("secret"),
+	})
+
+	// missing username
+	f(&SDConfig{
+		Server:   "https://uyuni.example.com",
+		Password: promauth.NewSecret("secret"),
+	})
+
+	// missing password
+	f(&SDConfig{
+		Server:   "https://uyuni.example.com",
+		Username: "admin",
+	})
+}
+
+func TestGetEndpointsLabels(t *testing.T) {
+	responses := map[string]string{
+		"auth.login":  `<value><string>token-123</string></value>`,
+		"auth.logout": `<value><int>1</int></value>`,
+		"system.listSystemGroupsForSystemsWithEntitlement": `<value><array><data>
+  <value><struct>
+    <member><name>id</name><value><int>1000010000</int></value></member>
+    <member><name>system_groups</name><value><array><data>
+      <value><struct><member><name>id</name><value><int>1</int></value></member><member><name>name</name><value><string>web</string></value></member></struct></value>
+      <value><struct><member><name>id</name><value><int>2</int></value></member><member><name>name</name><value>prod</value></member></struct></value>
+    </data></array></value></member>
+  </struct></value>
+  <value><struct>
+    <member><name>id</name><value><int>1000010001</int></value></member>
+    <member><name>system_groups</name><value><array><data></data></array></value></member>
+  </struct></value>
+</data></array></value>`,
+		"system.monitoring.listEndpoints": `<value><array><data>
+  <value><struct>
+    <member><name>system_id</name><value><int>1000010000</int></value></member>
+    <member><name>endpoint_name</name><value><string>node_exporter</string></value></member>
+    <member><name>port</name><value><int>9100</int></value></member>
+    <member><name>path</name><value><string>/metrics</string></value></member>
+    <member><name>exporter_name</name><value><string>node_exporter</string></value></member>
+    <member><name>tls_enabled</name><value><boolean>0</boolean></value></member>
+  </struct></value>
+  <value><struct>
+    <member><name>system_id</name><value><int>1000010001</int></value></member>
+    <member><name>endpoint_name</name><value><string>apache</string></value></member>
+    <member><name>port</name><value><int>9117</int></value></member>
+    <member><name>path</name><value><string>/metrics</string></value></member>
+    <member><name>exporter_name</name><value><string>apache_exporter</string></value></member>
+    <member><name>module</name><value><string>apache</string></value></member>
+    <member><name>tls_enabled</name><value><boolean>1</boolean></value></member>
+  </struct></value>
+  <value><struct>
+    <member><name>system_id</name><value><int>1000010002</int></value></member>
+    <member><name>endpoint_name</name><value><string>unknown</string></value></member>
+    <member><name>port</name><value><int>9100</int></value></member>
+  </struct></value>
+</data></array></value>`,
+		"system.getNetworkForSystems": `<value><array><data>
+  <value><struct>
+    <member><name>system_id</name><value><int>1000010000</int></value></member>
+    <member><name>hostname</name><value><string>minion1</string></value></member>
+    <member><name>primary_fqdn</name><value><string>minion1.example.com</string></value></member>
+    <member><name>ip</name><value><string>10.0.0.1</string></value></member>
+  </struct></value>
+  <value><struct>
+    <member><name>system_id</name><value><int>1000010001</int></value></member>
+    <member><name>hostname</name><value><string>minion2</string></value></member>
+  </struct></value>
+</data></array></value>`,
+	}
+	var loggedOut bool
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/rpc/api" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var mc struct {
+			MethodName string `xml:"methodName"`
+		}
+		if err := xml.Unmarshal(body, &mc); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if mc.MethodName == "auth.logout" {
+			loggedOut = true
+		}
+		resp, ok := responses[mc.MethodName]
+		if !ok {
+			w.Write([]byte(`<?xml version="1.0"?><methodResponse><fault><value><struct>
+<member><name>faultCode</name><value><int>-1</int></value></member>
+<member><name>faultString</name><value><string>unknown method</string></value></member>
+</struct></value></fault></methodResponse>`))
+			return
+		}
+		w.Write([]byte(`<?xml version="1.0"?><methodResponse><params><param>` + resp + `</param></params></methodResponse>`))
+	}))
+	defer s.Close()
+
+	sdc := &SDConfig{
+		Server:    s.URL,
+		Username:  "admin",
+		Password:  promauth.NewSecret("secret"),
+		Separator: ";",
+	}
+	cfg, err := newAPIConfig(sdc, ".")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer cfg.client.Stop()
+
+	labelss, err := getEndpointsLabels(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !loggedOut {
+		t.Fatalf("expecting auth.logout call")
+	}
+	labelssExpected := []*promutil.Labels{
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                  "minion1.example.com:9100",
+			"__meta_uyuni_endpoint_name":   "node_exporter",
+			"__meta_uyuni_exporter":        "node_exporter",
+			"__meta_uyuni_groups":          "web;prod",
+			"__meta_uyuni_metrics_path":    "/metrics",
+			"__meta_uyuni_minion_hostname": "minion1",
+			"__meta_uyuni_primary_fqdn":    "minion1.example.com",
+			"__meta_uyuni_proxy_module":    "",
+			"__meta_uyuni_scheme":          "http",
+			"__meta_uyuni_system_id":       "1000010000",
+		}),
+		promutil.NewLabelsFromMap(map[string]string{
+			"__address__":                  "minion2:9117",
+			"__meta_uyuni_endpoint_name":   "apache",
+			"__meta_uyuni_exporter":        "apache_exporter",
+			"__meta_uyuni_groups":          "",
+			"__meta_uyuni_metrics_path":    "/metrics",
+			"__meta_uyuni_minion_hostname": "minion2",
+			"__meta_uyuni_primary_fqdn":    "",
+			"__meta_uyuni_proxy_module":    "apache",
+			"__meta_uyuni_scheme":          "https",
+			"__meta_uyuni_system_id":       "1000010001",
+		}),
+	}
+	discoveryutil.TestEqualLabelss(t, labelss, labelssExpected)
+}
@@ -0,0 +1,51 @@
+package uyuni
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/proxy"
+)
+
+// SDCheckInterval defines interval for targets refresh.
+var SDCheckInterval = flag.Duration("promscrape.uyuniSDCheckInterval", time.Minute, "Interval for checking for changes in Uyuni. "+
+	"This works only if uyuni_sd_configs is configured in '-promscrape.config' file. "+
+	"See https://docs.victoriametrics.com/victoriametrics/sd_configs/#uyuni_sd_configs for details")
+
+// SDConfig represents service discovery config for Uyuni.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#uyuni_sd_config
+type SDConfig struct {
+	Server      string           `yaml:"server"`
+	Username    string           `yaml:"username"`
+	Password    *promauth.Secret `yaml:"password"`
+	Entitlement string           `yaml:"entitlement,omitempty"`
+	Separator   string           `yaml:"separator,omitempty"`
+
+	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
+	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
+	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
+
+	// refresh_interval is obtained from `-promscrape.uyuniSDCheckInterval` command-line option.
+}
+
+// GetLabels returns Uyuni labels according to sdc.
+func (sdc *SDConfig) GetLabels(baseDir string) ([]*promutil.Labels, error) {
+	cfg, err := getAPIConfig(sdc, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get API config: %w", err)
+	}
+	return getEndpointsLabels(cfg)
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *SDConfig) MustStop() {
+	v := configMap.Delete(sdc)
+	if v != nil {
+		cfg := v.(*apiConfig)
+		cfg.client.Stop()
+	}
+}
@@ -0,0 +1,175 @@
+package uyuni
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// marshalMethodCall returns XML-RPC methodCall request body for the given method and params.
+//
+// Only string, int and []int params are supported, since they are enough for Uyuni API calls used by service discovery.
+//
+// See http://xmlrpc.com/spec.md
+func marshalMethodCall(method string, params []any) ([]byte, error) {
+	var bb bytes.Buffer
+	bb.WriteString(`<?xml version="1.0"?><methodCall><methodName>`)
+	if err := xml.EscapeText(&bb, []byte(method)); err != nil {
+		return nil, err
+	}
+	bb.WriteString(`</methodName><params>`)
+	for _, p := range params {
+		bb.WriteString(`<param>`)
+		if err := marshalValue(&bb, p); err != nil {
+			return nil, err
+		}
+		bb.WriteString(`</param>`)
+	}
+	bb.WriteString(`</params></methodCall>`)
+	return bb.Bytes(), nil
+}
+
+func marshalValue(bb *bytes.Buffer, v any) error {
+	switch t := v.(type) {
+	case string:
+		bb.WriteString(`<value><string>`)
+		if err := xml.EscapeText(bb, []byte(t)); err != nil {
+			return err
+		}
+		bb.WriteString(`</string></value>`)
+	case int:
+		fmt.Fprintf(bb, `<value><int>%d</int></value>`, t)
+	case []int:
+		bb.WriteString(`<value><array><data>`)
+		for _, n := range t {
+			fmt.Fprintf(bb, `<value><int>%d</int></value>`, n)
+		}
+		bb.WriteString(`</data></array></value>`)
+	default:
+		return fmt.Errorf("unsupported param type %T", v)
+	}
+	return nil
+}
+
+type xmlMethodResponse struct {
+	Params []xmlValue `xml:"params>param>value"`
+	Fault  *xmlValue  `xml:"fault>value"`
+}
+
+type xmlValue struct {
+	String   *string    `xml:"string"`
+	Int      *string    `xml:"int"`
+	I4       *string    `xml:"i4"`
+	I8       *string    `xml:"i8"`
+	Boolean  *string    `xml:"boolean"`
+	Double   *string    `xml:"double"`
+	DateTime *string    `xml:"dateTime.iso8601"`
+	Nil      *struct{}  `xml:"nil"`
+	Array    *xmlArray  `xml:"array"`
+	Struct   *xmlStruct `xml:"struct"`
+
+	// Text contains the value without explicit type, which must be treated as string.
+	Text string `xml:",chardata"`
+}
+
+type xmlArray struct {
+	Values []xmlValue `xml:"data>value"`
+}
+
+type xmlStruct struct {
+	Members []xmlMember `xml:"member"`
+}
+
+type xmlMember struct {
+	Name  string   `xml:"name"`
+	Value xmlValue `xml:"value"`
+}
+
+// unmarshalMethodResponse parses XML-RPC methodResponse from data.
+//
+// XML-RPC values are converted to string, int, bool, float64, nil, []any and map[string]any.
+func unmarshalMethodResponse(data []byte) (any, error) {
+	var mr xmlMethodResponse
+	if err := xml.Unmarshal(data, &mr); err != nil {
+		return nil, fmt.Errorf("cannot parse XML-RPC response: %w; response=%q", err, data)
+	}
+	if mr.Fault != nil {
+		v, err := mr.Fault.toAny()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse XML-RPC fault: %w", err)
+		}
+		m, _ := v.(map[string]any)
+		return nil, fmt.Errorf("XML-RPC fault: code=%v, message=%q", m["faultCode"], m["faultString"])
+	}
+	if len(mr.Params) != 1 {
+		return nil, fmt.Errorf("unexpected number of params in XML-RPC response; got %d; want 1", len(mr.Params))
+	}
+	return mr.Params[0].toAny()
+}
+
+func (xv *xmlValue) toAny() (any, error) {
+	switch {
+	case xv.String != nil:
+		return *xv.String, nil
+	case xv.Int != nil:
+		return parseInt(*xv.Int)
+	case xv.I4 != nil:
+		return parseInt(*xv.I4)
+	case xv.I8 != nil:
+		return parseInt(*xv.I8)
+	case xv.Boolean != nil:
+		switch strings.TrimSpace(*xv.Boolean) {
+		case "1":
+			return true, nil
+		case "0":
+			return false, nil
+		default:
+			return nil, fmt.Errorf("cannot parse boolean %q", *xv.Boolean)
+		}
+	case xv.Double != nil:
+		f, err := strconv.ParseFloat(strings.TrimSpace(*xv.Double), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse double %q: %w", *xv.Double, err)
+		}
+		return f, nil
+	case xv.DateTime != nil:
+		return *xv.DateTime, nil
+	case xv.Nil != nil:
+		return nil, nil
+	case xv.Array != nil:
+		values := xv.Array.Values
+		a := make([]any, 0, len(values))
+		for i := range values {
+			v, err := values[i].toAny()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	case xv.Struct != nil:
+		members := xv.Struct.Members
+		m := make(map[string]any, len(members))
+		for i := range members {
+			member := &members[i]
+			v, err := member.Value.toAny()
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse struct member %q: %w", member.Name, err)
+			}
+			m[member.Name] = v
+		}
+		return m, nil
+	default:
+		return xv.Text, nil
+	}
+}
+
+func parseInt(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse int %q: %w", s, err)
+	}
+	return n, nil
+}
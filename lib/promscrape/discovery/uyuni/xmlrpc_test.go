@@ -0,0 +1,67 @@
+package uyuni
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMarshalMethodCall(t *testing.T) {
+	data, err := marshalMethodCall("system.getNetworkForSystems", []any{"a<b", []int{1, 2}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resultExpected := `<?xml version="1.0"?><methodCall><methodName>system.getNetworkForSystems</methodName><params>` +
+		`<param><value><string>a&lt;b</string></value></param>` +
+		`<param><value><array><data><value><int>1</int></value><value><int>2</int></value></data></array></value></param>` +
+		`</params></methodCall>`
+	if string(data) != resultExpected {
+		t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", data, resultExpected)
+	}
+
+	if _, err := marshalMethodCall("foo", []any{1.5}); err == nil {
+		t.Fatalf("expecting non-nil error for unsupported param type")
+	}
+}
+
+func TestUnmarshalMethodResponseSuccess(t *testing.T) {
+	f := func(data string, resultExpected any) {
+		t.Helper()
+		result, err := unmarshalMethodResponse([]byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result\ngot\n%#v\nwant\n%#v", result, resultExpected)
+		}
+	}
+	f(`<methodResponse><params><param><value>foo bar</value></param></params></methodResponse>`, "foo bar")
+	f(`<methodResponse><params><param><value><i4> 42 </i4></value></param></params></methodResponse>`, 42)
+	f(`<methodResponse><params><param><value><double>1.5</double></value></param></params></methodResponse>`, 1.5)
+	f(`<methodResponse><params><param><value><nil/></value></param></params></methodResponse>`, nil)
+	f(`<methodResponse><params><param><value><array><data></data></array></value></param></params></methodResponse>`, []any{})
+	f(`<methodResponse><params><param><value><struct>
+  <member><name>ok</name><value><boolean>1</boolean></value></member>
+  <member><name>items</name><value><array><data><value><string>a</string></value><value><int>1</int></value></data></array></value></member>
+</struct></value></param></params></methodResponse>`, map[string]any{
+		"ok":    true,
+		"items": []any{"a", 1},
+	})
+}
+
+func TestUnmarshalMethodResponseFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		_, err := unmarshalMethodResponse([]byte(data))
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f(``)
+	f(`<methodResponse><params></params></methodResponse>`)
+	f(`<methodResponse><params><param><value><int>foo</int></value></param></params></methodResponse>`)
+	f(`<methodResponse><params><param><value><boolean>yes</boolean></value></param></params></methodResponse>`)
+	f(`<methodResponse><fault><value><struct>
+  <member><name>faultCode</name><value><int>2950</int></value></member>
+  <member><name>faultString</name><value><string>Either the password or username is incorrect.</string></value></member>
+</struct></value></fault></methodResponse>`)
+}
@@ -28,13 +28,20 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/gce"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/hetzner"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/http"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/ionos"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kubernetes"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/kuma"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/lightsail"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/linode"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/marathon"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/nomad"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/openstack"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/ovhcloud"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/puppetdb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/scaleway"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/stackit"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/triton"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/uyuni"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/vultr"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promscrape/discovery/yandexcloud"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
@@ -141,13 +148,20 @@ func runScraper(configFile string, pushData func(at *auth.Token, wr *prompbmarsh
 	scs.add("gce_sd_configs", *gce.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getGCESDScrapeWork(swsPrev) })
 	scs.add("hetzner_sd_configs", *hetzner.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getHetznerSDScrapeWork(swsPrev) })
 	scs.add("http_sd_configs", *http.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getHTTPDScrapeWork(swsPrev) })
+	scs.add("ionos_sd_configs", *ionos.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getIONOSSDScrapeWork(swsPrev) })
 	scs.add("kubernetes_sd_configs", *kubernetes.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getKubernetesSDScrapeWork(swsPrev) })
 	scs.add("kuma_sd_configs", *kuma.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getKumaSDScrapeWork(swsPrev) })
+	scs.add("lightsail_sd_configs", *lightsail.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getLightsailSDScrapeWork(swsPrev) })
+	scs.add("linode_sd_configs", *linode.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getLinodeSDScrapeWork(swsPrev) })
 	scs.add("marathon_sd_configs", *marathon.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getMarathonSDScrapeWork(swsPrev) })
 	scs.add("nomad_sd_configs", *nomad.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getNomadSDScrapeWork(swsPrev) })
 	scs.add("openstack_sd_configs", *openstack.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getOpenStackSDScrapeWork(swsPrev) })
 	scs.add("ovhcloud_sd_configs", *ovhcloud.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getOVHCloudSDScrapeWork(swsPrev) })
 	scs.add("puppetdb_sd_configs", *puppetdb.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getPuppetDBSDScrapeWork(swsPrev) })
+	scs.add("scaleway_sd_configs", *scaleway.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getScalewaySDScrapeWork(swsPrev) })
+	scs.add("stackit_sd_configs", *stackit.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getStackitSDScrapeWork(swsPrev) })
+	scs.add("triton_sd_configs", *triton.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getTritonSDScrapeWork(swsPrev) })
+	scs.add("uyuni_sd_configs", *uyuni.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getUyuniSDScrapeWork(swsPrev) })
 	scs.add("vultr_sd_configs", *vultr.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getVultrSDScrapeWork(swsPrev) })
 	scs.add("yandexcloud_sd_configs", *yandexcloud.SDCheckInterval, func(cfg *Config, swsPrev []*ScrapeWork) []*ScrapeWork { return cfg.getYandexCloudSDScrapeWork(swsPrev) })
 	scs.add("static_configs", 0, func(cfg *Config, _ []*ScrapeWork) []*ScrapeWork { return cfg.getStaticScrapeWork() })
@@ -158,6 +172,9 @@ func runScraper(configFile string, pushData func(at *auth.Token, wr *prompbmarsh
 		tickerCh = ticker.C
 		defer ticker.Stop()
 	}
+	// Periodically check for changes in Kubernetes custom resources discovered via kubernetes_crd_sd_configs.
+	crdTicker := time.NewTicker(*kubernetes.SDCheckInterval)
+	defer crdTicker.Stop()
 	for {
 		scs.updateConfig(cfg)
 	waitForChans:
@@ -198,6 +215,29 @@ func runScraper(configFile string, pushData func(at *auth.Token, wr *prompbmarsh
 			configData.Store(&marshaledData)
 			configReloads.Inc()
 			configTimestamp.Set(fasttime.UnixTimestamp())
+		case <-crdTicker.C:
+			if !cfg.needReloadKubernetesCRDs() {
+				goto waitForChans
+			}
+			logger.Infof("Kubernetes custom resources for `kubernetes_crd_sd_configs` have been changed; reloading Prometheus configs from %q", configFile)
+			cfgNew, err := loadConfig(configFile)
+			if err != nil {
+				configReloadErrors.Inc()
+				configSuccess.Set(0)
+				logger.Errorf("cannot read %q: %s; continuing with the previous config", configFile, err)
+				goto waitForChans
+			}
+			configSuccess.Set(1)
+			if !cfgNew.mustRestart(cfg) {
+				// Prevent from repeated reloads if the changed custom resources didn't result in scrape config changes.
+				cfg.crdScrapeConfigsData = cfgNew.crdScrapeConfigsData
+				goto waitForChans
+			}
+			cfg = cfgNew
+			marshaledData = cfg.marshal()
+			configData.Store(&marshaledData)
+			configReloads.Inc()
+			configTimestamp.Set(fasttime.UnixTimestamp())
 		case <-globalStopCh:
 			cfg.mustStop()
 			logger.Infof("stopping Prometheus scrapers")
@@ -24,6 +24,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/leveledbytebufferpool"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
@@ -145,10 +146,29 @@ type ScrapeWork struct {
 	// Optional limit on the number of unique series the scrape target can expose.
 	SeriesLimit int
 
+	// Optional budget on the number of samples per ScrapeInterval for all the targets of the job.
+	//
+	// The scrape interval is lengthened up to MaxScrapeInterval if the budget is exceeded.
+	// See https://docs.victoriametrics.com/victoriametrics/vmagent/#adaptive-scrape-interval
+	SampleBudget int
+
+	// Optional budget on the number of new series per ScrapeInterval for all the targets of the job.
+	//
+	// The scrape interval is lengthened up to MaxScrapeInterval if the budget is exceeded.
+	// See https://docs.victoriametrics.com/victoriametrics/vmagent/#adaptive-scrape-interval
+	SeriesBudget int
+
+	// The maximum scrape interval when SampleBudget or SeriesBudget is exceeded.
+	MaxScrapeInterval time.Duration
+
 	// Whether to process stale markers for the given target.
 	// See https://docs.victoriametrics.com/victoriametrics/vmagent/#prometheus-staleness-markers
 	NoStaleMarkers bool
 
+	// Optional protocols to negotiate with ScrapeURL in the order of preference.
+	// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#scrape_config
+	ScrapeProtocols []string
+
 	// The Tenant Info
 	AuthToken *auth.Token
 
@@ -162,6 +182,11 @@ func (sw *ScrapeWork) canSwitchToStreamParseMode() bool {
 	return sw.SampleLimit <= 0 && sw.SeriesLimit <= 0
 }
 
+// hasScrapeBudget returns true if sample_budget or series_budget is set for sw.
+func (sw *ScrapeWork) hasScrapeBudget() bool {
+	return (sw.SampleBudget > 0 || sw.SeriesBudget > 0) && sw.MaxScrapeInterval > sw.ScrapeInterval
+}
+
 // key returns unique identifier for the given sw.
 //
 // It can be used for comparing for equality for two ScrapeWork objects.
@@ -173,12 +198,14 @@ func (sw *ScrapeWork) key() string {
 		"HonorTimestamps=%v, DenyRedirects=%v, Labels=%s, ExternalLabels=%s, MaxScrapeSize=%d, "+
 		"ProxyURL=%s, ProxyAuthConfig=%s, AuthConfig=%s, MetricRelabelConfigs=%q, "+
 		"SampleLimit=%d, DisableCompression=%v, DisableKeepAlive=%v, StreamParse=%v, "+
-		"ScrapeAlignInterval=%s, ScrapeOffset=%s, SeriesLimit=%d, NoStaleMarkers=%v",
+		"ScrapeAlignInterval=%s, ScrapeOffset=%s, SeriesLimit=%d, SampleBudget=%d, SeriesBudget=%d, MaxScrapeInterval=%s, "+
+		"NoStaleMarkers=%v, ScrapeProtocols=%q",
 		sw.jobNameOriginal, sw.ScrapeURL, sw.ScrapeInterval, sw.ScrapeTimeout, sw.HonorLabels,
 		sw.HonorTimestamps, sw.DenyRedirects, sw.Labels.String(), sw.ExternalLabels.String(), sw.MaxScrapeSize,
 		sw.ProxyURL.String(), sw.ProxyAuthConfig.String(), sw.AuthConfig.String(), sw.MetricRelabelConfigs.String(),
 		sw.SampleLimit, sw.DisableCompression, sw.DisableKeepAlive, sw.StreamParse,
-		sw.ScrapeAlignInterval, sw.ScrapeOffset, sw.SeriesLimit, sw.NoStaleMarkers)
+		sw.ScrapeAlignInterval, sw.ScrapeOffset, sw.SeriesLimit, sw.SampleBudget, sw.SeriesBudget, sw.MaxScrapeInterval,
+		sw.NoStaleMarkers, sw.ScrapeProtocols)
 	return key
 }
 
@@ -206,6 +233,11 @@ type scrapeWork struct {
 	// This flag is set to true if series_limit is exceeded.
 	seriesLimitExceeded atomic.Bool
 
+	// jobBudget contains the usage of sample_budget and series_budget shared among all the targets of the job.
+	// It is nil if sample_budget and series_budget aren't set for the job.
+	// See https://docs.victoriametrics.com/victoriametrics/vmagent/#adaptive-scrape-interval
+	jobBudget *jobBudget
+
 	// Optional limiter on the number of unique series per scrape target.
 	seriesLimiter     *bloomfilter.Limiter
 	seriesLimiterOnce sync.Once
@@ -222,6 +254,10 @@ type scrapeWork struct {
 	// It is used as a hint in order to reduce memory usage when parsing scrape responses.
 	prevLabelsLen int
 
+	// prevMetadataHash contains the hash for metric metadata pushed during the previous scrape.
+	// It is used for avoiding pushing the same metadata on every scrape.
+	prevMetadataHash uint64
+
 	// lastScrapeCompressed holds the last response from scrape target in the compressed form.
 	// It is used for staleness tracking and for populating scrape_series_added metric.
 	// The lastScrapeCompressed isn't populated if -promscrape.noStaleMarkers is set. This reduces memory usage.
@@ -305,6 +341,7 @@ func (sw *scrapeWork) run(stopCh <-chan struct{}, globalStopCh <-chan struct{})
 	}
 	timer := timerpool.Get(time.Duration(randSleep))
 	var timestamp int64
+	ticksSkipped := 0
 	var ticker *time.Ticker
 	select {
 	case <-stopCh:
@@ -356,6 +393,14 @@ func (sw *scrapeWork) run(stopCh <-chan struct{}, globalStopCh <-chan struct{})
 				// Too big jitter. Adjust timestamp
 				timestamp = t
 			}
+			if ticksSkipped+1 < sw.getCurrentScrapeIntervalMultiplier() {
+				// The scrape interval has been lengthened because of sample_budget or series_budget.
+				// Skip the scrape, while keeping timestamp aligned to the original scrape_interval.
+				ticksSkipped++
+				scrapesSkippedByBudget.Inc()
+				continue
+			}
+			ticksSkipped = 0
 			sw.scrapeAndLogError(timestamp, t)
 		}
 	}
@@ -400,6 +445,7 @@ var (
 	scrapeResponseSize          = metrics.NewHistogram("vm_promscrape_scrape_response_size_bytes")
 	scrapedSamples              = metrics.NewHistogram("vm_promscrape_scraped_samples")
 	scrapesSkippedBySampleLimit = metrics.NewCounter("vm_promscrape_scrapes_skipped_by_sample_limit_total")
+	scrapesSkippedByBudget      = metrics.NewCounter("vm_promscrape_scrapes_skipped_by_budget_total")
 	scrapesFailed               = metrics.NewCounter("vm_promscrape_scrapes_failed_total")
 	pushDataDuration            = metrics.NewHistogram("vm_promscrape_push_data_duration_seconds")
 )
@@ -513,6 +559,7 @@ func (sw *scrapeWork) processDataOneShot(scrapeTimestamp, realTimestamp int64, b
 	areIdenticalSeries := areIdenticalSeries(cfg, lastScrapeStr, bodyString)
 
 	wc := writeRequestCtxPool.Get(sw.prevLabelsLen)
+	isFetched := err == nil
 	if err != nil {
 		up = 0
 		scrapesFailed.Inc()
@@ -539,6 +586,8 @@ func (sw *scrapeWork) processDataOneShot(scrapeTimestamp, realTimestamp int64, b
 	}
 	if up == 0 {
 		bodyString = ""
+	} else if prommetadata.IsEnabled() {
+		wc.addMetadata(sw, bodyString)
 	}
 	seriesAdded := 0
 	if !areIdenticalSeries {
@@ -547,6 +596,9 @@ func (sw *scrapeWork) processDataOneShot(scrapeTimestamp, realTimestamp int64, b
 		// This is a trade-off between performance and accuracy.
 		seriesAdded = getSeriesAdded(lastScrapeStr, bodyString)
 	}
+	if isFetched {
+		sw.updateScrapeInterval(samplesPostRelabeling, seriesAdded)
+	}
 	samplesDropped := 0
 	if sw.seriesLimitExceeded.Load() || !areIdenticalSeries {
 		samplesDropped = wc.applySeriesLimit(sw)
@@ -651,6 +703,9 @@ func (sw *scrapeWork) processDataInStreamMode(scrapeTimestamp, realTimestamp int
 		// This is a trade-off between performance and accuracy.
 		seriesAdded = getSeriesAdded(lastScrapeStr, bodyString)
 	}
+	if err == nil {
+		sw.updateScrapeInterval(int(samplesPostRelabeling.Load()), seriesAdded)
+	}
 	responseSize := len(bodyString)
 
 	am := &autoMetrics{
@@ -698,14 +753,103 @@ func (sw *scrapeWork) pushData(wr *prompbmarshal.WriteRequest) {
 }
 
 func areIdenticalSeries(cfg *ScrapeWork, prevData, currData string) bool {
-	if cfg.NoStaleMarkers && cfg.SeriesLimit <= 0 {
+	if cfg.NoStaleMarkers && cfg.SeriesLimit <= 0 && cfg.SeriesBudget <= 0 {
 		// Do not spend CPU time on tracking the changes in series if stale markers are disabled.
 		// The check for series_limit is needed for https://github.com/VictoriaMetrics/VictoriaMetrics/issues/3660
+		// The check for series_budget is needed for calculating the number of added series.
 		return true
 	}
 	return parser.AreIdenticalSeriesFast(prevData, currData)
 }
 
+// getEffectiveScrapeInterval returns the current scrape interval for sw.
+//
+// It may be bigger than sw.Config.ScrapeInterval if sample_budget or series_budget is exceeded by the job.
+func (sw *scrapeWork) getEffectiveScrapeInterval() time.Duration {
+	return time.Duration(sw.getCurrentScrapeIntervalMultiplier()) * sw.Config.ScrapeInterval
+}
+
+// getCurrentScrapeIntervalMultiplier returns the current multiplier for sw.Config.ScrapeInterval.
+func (sw *scrapeWork) getCurrentScrapeIntervalMultiplier() int {
+	if sw.jobBudget == nil {
+		return 1
+	}
+	return int(sw.jobBudget.multiplier.Load())
+}
+
+// updateScrapeInterval updates the scrape interval for the job of sw according to the given number of samples and added series
+// scraped from sw during the last scrape.
+//
+// See https://docs.victoriametrics.com/victoriametrics/vmagent/#adaptive-scrape-interval
+func (sw *scrapeWork) updateScrapeInterval(samples, seriesAdded int) {
+	if sw.jobBudget == nil {
+		return
+	}
+	tsmGlobal.updateJobBudget(sw, samples, seriesAdded)
+}
+
+// jobBudget contains the usage of sample_budget and series_budget by all the targets of a single job.
+//
+// It is protected by targetStatusMap.mu except of multiplier, which may be read without the lock.
+type jobBudget struct {
+	// samples is the number of samples after relabeling scraped during the last scrape of all the job targets.
+	samples int
+
+	// seriesAdded is the number of new series scraped during the last scrape of all the job targets.
+	seriesAdded int
+
+	// targets is the number of the job targets sharing the budget.
+	targets int
+
+	// multiplier is the current multiplier for scrape_interval of all the job targets.
+	multiplier atomic.Int64
+}
+
+func newJobBudget() *jobBudget {
+	var jb jobBudget
+	jb.multiplier.Store(1)
+	return &jb
+}
+
+// getScrapeIntervalMultiplier returns the multiplier for cfg.ScrapeInterval, which is needed for fitting
+// the given number of samples and added series into cfg.SampleBudget and cfg.SeriesBudget per cfg.ScrapeInterval.
+//
+// The returned multiplier is in the range [1 ... cfg.MaxScrapeInterval/cfg.ScrapeInterval].
+// The multiplier is decreased comparing to prevMultiplier only if samples and seriesAdded fit
+// into 90% of the budgets for the decreased multiplier. This prevents from frequent flapping of the scrape interval.
+func getScrapeIntervalMultiplier(cfg *ScrapeWork, prevMultiplier, samples, seriesAdded int) int {
+	maxMultiplier := 1
+	if cfg.ScrapeInterval > 0 {
+		maxMultiplier = int(cfg.MaxScrapeInterval / cfg.ScrapeInterval)
+	}
+	if maxMultiplier < 1 {
+		maxMultiplier = 1
+	}
+	multiplier := 1
+	if cfg.SampleBudget > 0 {
+		multiplier = max(multiplier, (samples+cfg.SampleBudget-1)/cfg.SampleBudget)
+	}
+	if cfg.SeriesBudget > 0 {
+		multiplier = max(multiplier, (seriesAdded+cfg.SeriesBudget-1)/cfg.SeriesBudget)
+	}
+	if multiplier < prevMultiplier {
+		// Decrease the multiplier only if the usage fits 90% of the budget for the decreased multiplier.
+		fitsBudget := func(m int) bool {
+			if cfg.SampleBudget > 0 && float64(samples) > 0.9*float64(m*cfg.SampleBudget) {
+				return false
+			}
+			if cfg.SeriesBudget > 0 && float64(seriesAdded) > 0.9*float64(m*cfg.SeriesBudget) {
+				return false
+			}
+			return true
+		}
+		for multiplier < prevMultiplier && !fitsBudget(multiplier) {
+			multiplier++
+		}
+	}
+	return min(multiplier, maxMultiplier)
+}
+
 // leveledWriteRequestCtxPool allows reducing memory usage when writeRequestCtx
 // structs contain mixed number of labels.
 //
@@ -752,15 +896,18 @@ func (lwp *leveledWriteRequestCtxPool) getPoolIDAndCapacity(size int) (int, int)
 }
 
 type writeRequestCtx struct {
-	rows parser.Rows
+	rows         parser.Rows
+	metadataRows parser.MetadataRows
 
 	writeRequest prompbmarshal.WriteRequest
 	labels       []prompbmarshal.Label
 	samples      []prompbmarshal.Sample
+	exemplars    []prompbmarshal.Exemplar
 }
 
 func (wc *writeRequestCtx) reset() {
 	wc.rows.Reset()
+	wc.metadataRows.Reset()
 
 	wc.writeRequest.Reset()
 
@@ -768,10 +915,52 @@ func (wc *writeRequestCtx) reset() {
 	wc.labels = wc.labels[:0]
 
 	wc.samples = wc.samples[:0]
+
+	clear(wc.exemplars)
+	wc.exemplars = wc.exemplars[:0]
 }
 
 var writeRequestCtxPool leveledWriteRequestCtxPool
 
+// addMetadata adds metric metadata from `# HELP`, `# TYPE` and `# UNIT` lines at body to wc.writeRequest.
+//
+// The metadata is added only if it differs from the metadata added during the previous scrape for sw.
+// The added metadata refers to body, so body mustn't be changed while wc.writeRequest is in use.
+func (wc *writeRequestCtx) addMetadata(sw *scrapeWork, body string) {
+	wc.metadataRows.Unmarshal(body)
+	rows := wc.metadataRows.Rows
+	d := xxhash.New()
+	for i := range rows {
+		r := &rows[i]
+		_, _ = d.WriteString(r.Metric)
+		_, _ = d.Write([]byte{0, byte(r.Type), 0})
+		_, _ = d.WriteString(r.Help)
+		_, _ = d.Write([]byte{0})
+		_, _ = d.WriteString(r.Unit)
+		_, _ = d.Write([]byte{0})
+	}
+	h := d.Sum64()
+	if h == sw.prevMetadataHash {
+		return
+	}
+	sw.prevMetadataHash = h
+	for i := range rows {
+		r := &rows[i]
+		wc.writeRequest.Metadata = append(wc.writeRequest.Metadata, prompbmarshal.MetricMetadata{
+			Type:             uint32(r.Type),
+			MetricFamilyName: r.Metric,
+			Help:             r.Help,
+			Unit:             r.Unit,
+		})
+	}
+	metadataRowsScraped.Add(len(rows))
+}
+
+var (
+	metadataRowsScraped = metrics.NewCounter(`vm_promscrape_metadata_rows_scraped_total`)
+	exemplarsScraped    = metrics.NewCounter(`vm_promscrape_exemplars_scraped_total`)
+)
+
 func getSeriesAdded(lastScrape, currScrape string) int {
 	if currScrape == "" {
 		return 0
@@ -873,7 +1062,10 @@ func (sw *scrapeWork) sendStaleSeries(lastScrape, currScrape string, timestamp i
 }
 
 func setStaleMarkersForRows(series []prompbmarshal.TimeSeries) {
-	for _, tss := range series {
+	for i := range series {
+		tss := &series[i]
+		// Exemplars have no sense for stale markers.
+		tss.Exemplars = nil
 		samples := tss.Samples
 		for i := range samples {
 			samples[i].Value = decimal.StaleNaN
@@ -1069,6 +1261,34 @@ func (wc *writeRequestCtx) addRow(cfg *ScrapeWork, r *parser.Row, timestamp int6
 		Labels:  wc.labels[labelsLen:],
 		Samples: wc.samples[len(wc.samples)-1:],
 	})
+	if !r.Exemplar.IsEmpty() {
+		wc.addExemplar(&wr.Timeseries[len(wr.Timeseries)-1], &r.Exemplar, sampleTimestamp)
+	}
+}
+
+// addExemplar adds e to ts.
+//
+// The sampleTimestamp is used as exemplar timestamp if e has no timestamp.
+func (wc *writeRequestCtx) addExemplar(ts *prompbmarshal.TimeSeries, e *parser.Exemplar, sampleTimestamp int64) {
+	labelsLen := len(wc.labels)
+	for i := range e.Tags {
+		tag := &e.Tags[i]
+		wc.labels = append(wc.labels, prompbmarshal.Label{
+			Name:  tag.Key,
+			Value: tag.Value,
+		})
+	}
+	timestamp := e.Timestamp
+	if timestamp == 0 {
+		timestamp = sampleTimestamp
+	}
+	wc.exemplars = append(wc.exemplars, prompbmarshal.Exemplar{
+		Labels:    wc.labels[labelsLen:],
+		Value:     e.Value,
+		Timestamp: timestamp,
+	})
+	ts.Exemplars = wc.exemplars[len(wc.exemplars)-1:]
+	exemplarsScraped.Inc()
 }
 
 var bbPool bytesutil.ByteBufferPool
@@ -2,6 +2,7 @@ package promscrape
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/chunkedbuffer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
@@ -41,6 +43,43 @@ func TestIsAutoMetric(t *testing.T) {
 	f("upx", false)
 }
 
+func TestGetScrapeIntervalMultiplier(t *testing.T) {
+	f := func(sampleBudget, seriesBudget int, maxScrapeInterval time.Duration, prevMultiplier, samples, seriesAdded, multiplierExpected int) {
+		t.Helper()
+		cfg := &ScrapeWork{
+			ScrapeInterval:    10 * time.Second,
+			SampleBudget:      sampleBudget,
+			SeriesBudget:      seriesBudget,
+			MaxScrapeInterval: maxScrapeInterval,
+		}
+		multiplier := getScrapeIntervalMultiplier(cfg, prevMultiplier, samples, seriesAdded)
+		if multiplier != multiplierExpected {
+			t.Fatalf("unexpected multiplier; got %d; want %d", multiplier, multiplierExpected)
+		}
+	}
+
+	// usage fits the budget
+	f(1000, 0, time.Minute, 1, 1000, 0, 1)
+	f(0, 100, time.Minute, 1, 1e6, 100, 1)
+
+	// sample_budget is exceeded
+	f(1000, 0, time.Minute, 1, 1001, 0, 2)
+	f(1000, 0, time.Minute, 1, 3500, 0, 4)
+
+	// series_budget is exceeded
+	f(1000, 100, time.Minute, 1, 10, 250, 3)
+
+	// the multiplier is limited by max_scrape_interval
+	f(1000, 0, time.Minute, 1, 1e6, 0, 6)
+	f(1000, 0, 15*time.Second, 1, 1e6, 0, 1)
+
+	// the multiplier is decreased only if usage fits 90% of the budget
+	f(1000, 0, time.Minute, 4, 1950, 0, 3)
+	f(1000, 0, time.Minute, 4, 1800, 0, 2)
+	f(1000, 0, time.Minute, 4, 100, 0, 1)
+	f(1000, 100, time.Minute, 4, 100, 95, 2)
+}
+
 func TestAppendExtraLabels(t *testing.T) {
 	f := func(sourceLabels, extraLabels string, honorLabels bool, resultExpected string) {
 		t.Helper()
@@ -977,3 +1016,123 @@ func mustParseRelabelConfigs(config string) *promrelabel.ParsedConfigs {
 	}
 	return pcs
 }
+
+func TestScrapeWorkScrapeInternalMetadata(t *testing.T) {
+	prommetadata.SetEnabled(true)
+	defer prommetadata.SetEnabled(false)
+
+	data := `# HELP foo_total The number of foos.
+# TYPE foo_total counter
+foo_total 1
+`
+	var sw scrapeWork
+	sw.Config = &ScrapeWork{
+		ScrapeTimeout: time.Second * 42,
+	}
+	sw.ReadData = func(dst *chunkedbuffer.Buffer) (bool, error) {
+		dst.MustWrite([]byte(data))
+		return false, nil
+	}
+	var metadata []prompbmarshal.MetricMetadata
+	sw.PushData = func(_ *auth.Token, wr *prompbmarshal.WriteRequest) {
+		for _, mm := range wr.Metadata {
+			mm.MetricFamilyName = strings.Clone(mm.MetricFamilyName)
+			mm.Help = strings.Clone(mm.Help)
+			metadata = append(metadata, mm)
+		}
+	}
+
+	tsmGlobal.Register(&sw)
+	defer tsmGlobal.Unregister(&sw)
+
+	// The metadata must be pushed only once for identical responses.
+	for i := 0; i < 3; i++ {
+		timestamp := int64(123000 + i*1000)
+		if err := sw.scrapeInternal(timestamp, timestamp); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	metadataExpected := []prompbmarshal.MetricMetadata{
+		{
+			Type:             1,
+			MetricFamilyName: "foo_total",
+			Help:             "The number of foos.",
+		},
+	}
+	if !reflect.DeepEqual(metadata, metadataExpected) {
+		t.Fatalf("unexpected metadata\ngot\n%+v\nwant\n%+v", metadata, metadataExpected)
+	}
+
+	// Changed metadata must be pushed again.
+	data = strings.Replace(data, "counter", "gauge", 1)
+	if err := sw.scrapeInternal(130000, 130000); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(metadata) != 2 || metadata[1].Type != 2 {
+		t.Fatalf("unexpected metadata after the change: %+v", metadata)
+	}
+}
+
+func TestScrapeWorkScrapeInternalExemplars(t *testing.T) {
+	data := `foo_bucket{le="0.5"} 3 # {trace_id="abc"} 0.3 1.5
+foo_bucket{le="+Inf"} 4 # {trace_id="def"} 0.7
+foo_count 4
+`
+	var sw scrapeWork
+	sw.Config = &ScrapeWork{
+		ScrapeTimeout: time.Second * 42,
+	}
+	sw.ReadData = func(dst *chunkedbuffer.Buffer) (bool, error) {
+		dst.MustWrite([]byte(data))
+		return false, nil
+	}
+	exemplars := make(map[string][]prompbmarshal.Exemplar)
+	sw.PushData = func(_ *auth.Token, wr *prompbmarshal.WriteRequest) {
+		for _, ts := range wr.Timeseries {
+			if len(ts.Exemplars) == 0 {
+				continue
+			}
+			key := prompbmarshal.LabelsToString(ts.Labels)
+			for _, e := range ts.Exemplars {
+				var labels []prompbmarshal.Label
+				for _, label := range e.Labels {
+					labels = append(labels, prompbmarshal.Label{
+						Name:  strings.Clone(label.Name),
+						Value: strings.Clone(label.Value),
+					})
+				}
+				exemplars[key] = append(exemplars[key], prompbmarshal.Exemplar{
+					Labels:    labels,
+					Value:     e.Value,
+					Timestamp: e.Timestamp,
+				})
+			}
+		}
+	}
+
+	tsmGlobal.Register(&sw)
+	defer tsmGlobal.Unregister(&sw)
+
+	if err := sw.scrapeInternal(123000, 123000); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	exemplarsExpected := map[string][]prompbmarshal.Exemplar{
+		`{__name__="foo_bucket",le="0.5"}`: {
+			{
+				Labels:    []prompbmarshal.Label{{Name: "trace_id", Value: "abc"}},
+				Value:     0.3,
+				Timestamp: 1500,
+			},
+		},
+		`{__name__="foo_bucket",le="+Inf"}`: {
+			{
+				Labels:    []prompbmarshal.Label{{Name: "trace_id", Value: "def"}},
+				Value:     0.7,
+				Timestamp: 123000,
+			},
+		},
+	}
+	if !reflect.DeepEqual(exemplars, exemplarsExpected) {
+		t.Fatalf("unexpected exemplars\ngot\n%+v\nwant\n%+v", exemplars, exemplarsExpected)
+	}
+}
@@ -0,0 +1,381 @@
+package promscrape
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/stringsutil"
+)
+
+const (
+	// healthBucketDuration is the duration in seconds of a single bucket used for tracking scrape job health.
+	healthBucketDuration = 5 * 60
+
+	// healthBucketsCount is the number of buckets needed for covering the longest health window.
+	healthBucketsCount = 24 * 3600 / healthBucketDuration
+
+	// scrapeDurationsCount is the number of the most recent scrape durations to track per scrape job for calculating percentiles.
+	scrapeDurationsCount = 512
+)
+
+// healthWindows contains rolling windows for scrape job health tracking.
+var healthWindows = []struct {
+	name     string
+	duration uint64
+}{
+	{"1h", 3600},
+	{"24h", 24 * 3600},
+}
+
+// healthQuantiles contains scrape duration percentiles calculated per each scrape job.
+var healthQuantiles = []float64{0.5, 0.9, 0.99}
+
+// jobHealth tracks health for a single scrape job over rolling time windows.
+//
+// It is protected by targetStatusMap.mu.
+type jobHealth struct {
+	buckets [healthBucketsCount]healthBucket
+
+	// durations is a ring buffer with the most recent scrape durations in seconds across all the targets for the job.
+	durations    [scrapeDurationsCount]float64
+	durationsLen int
+	durationsIdx int
+}
+
+type healthBucket struct {
+	// startTime is unix timestamp in seconds for the bucket start.
+	startTime uint64
+
+	scrapesTotal  uint64
+	scrapesFailed uint64
+
+	// maxTargetsUp is the maximum number of `up` targets observed during the bucket lifetime.
+	maxTargetsUp int
+}
+
+func (jh *jobHealth) getBucket(currentTime uint64) *healthBucket {
+	startTime := currentTime - currentTime%healthBucketDuration
+	b := &jh.buckets[(startTime/healthBucketDuration)%healthBucketsCount]
+	if b.startTime != startTime {
+		*b = healthBucket{
+			startTime: startTime,
+		}
+	}
+	return b
+}
+
+func (jh *jobHealth) update(currentTime uint64, up bool, scrapeDurationSeconds float64, targetsUp int) {
+	b := jh.getBucket(currentTime)
+	b.scrapesTotal++
+	if !up {
+		b.scrapesFailed++
+	}
+	if targetsUp > b.maxTargetsUp {
+		b.maxTargetsUp = targetsUp
+	}
+
+	jh.durations[jh.durationsIdx] = scrapeDurationSeconds
+	jh.durationsIdx = (jh.durationsIdx + 1) % len(jh.durations)
+	if jh.durationsLen < len(jh.durations) {
+		jh.durationsLen++
+	}
+}
+
+// visitBuckets calls f for buckets, which intersect with (currentTime-window ... currentTime].
+func (jh *jobHealth) visitBuckets(currentTime, window uint64, f func(b *healthBucket)) {
+	minStartTime := uint64(0)
+	if currentTime >= window {
+		minStartTime = currentTime - window
+	}
+	minStartTime -= minStartTime % healthBucketDuration
+	for i := range jh.buckets {
+		b := &jh.buckets[i]
+		if b.scrapesTotal > 0 && b.startTime >= minStartTime && b.startTime <= currentTime {
+			f(b)
+		}
+	}
+}
+
+// availability returns the share of successful scrapes for the given window ending at currentTime.
+//
+// NaN is returned if there were no scrapes during the window.
+func (jh *jobHealth) availability(currentTime, window uint64) float64 {
+	var scrapesTotal, scrapesFailed uint64
+	jh.visitBuckets(currentTime, window, func(b *healthBucket) {
+		scrapesTotal += b.scrapesTotal
+		scrapesFailed += b.scrapesFailed
+	})
+	if scrapesTotal == 0 {
+		return math.NaN()
+	}
+	return float64(scrapesTotal-scrapesFailed) / float64(scrapesTotal)
+}
+
+// maxTargetsUp returns the maximum number of `up` targets observed during the given window ending at currentTime.
+func (jh *jobHealth) maxTargetsUp(currentTime, window uint64) int {
+	n := 0
+	jh.visitBuckets(currentTime, window, func(b *healthBucket) {
+		if b.maxTargetsUp > n {
+			n = b.maxTargetsUp
+		}
+	})
+	return n
+}
+
+// scrapeDurationQuantiles returns phis quantiles for the most recent scrape durations.
+//
+// NaN values are returned if there were no scrapes yet.
+func (jh *jobHealth) scrapeDurationQuantiles(phis []float64) []float64 {
+	a := append([]float64{}, jh.durations[:jh.durationsLen]...)
+	sort.Float64s(a)
+	qs := make([]float64, len(phis))
+	for i, phi := range phis {
+		if len(a) == 0 {
+			qs[i] = math.NaN()
+			continue
+		}
+		qs[i] = a[int(phi*float64(len(a)-1)+0.5)]
+	}
+	return qs
+}
+
+// getLostTargetsRatio returns the share of `up` targets lost since maxTargetsUp.
+func getLostTargetsRatio(targetsUp, maxTargetsUp int) float64 {
+	if maxTargetsUp <= 0 || targetsUp >= maxTargetsUp {
+		return 0
+	}
+	return 1 - float64(targetsUp)/float64(maxTargetsUp)
+}
+
+// updateJobHealthLocked updates health stats for the given jobName after the scrape of one of its targets.
+//
+// tsm.mu must be locked when calling this function.
+func (tsm *targetStatusMap) updateJobHealthLocked(jobName string, currentTime uint64, up bool, scrapeDuration int64) {
+	jh := tsm.healthByJob[jobName]
+	if jh == nil {
+		jh = &jobHealth{}
+		tsm.healthByJob[jobName] = jh
+	}
+	scrapeDurationSeconds := (time.Millisecond * time.Duration(scrapeDuration)).Seconds()
+	jh.update(currentTime, up, scrapeDurationSeconds, tsm.upByJob[jobName])
+}
+
+// getJobHealthValue returns the value obtained via f for the given jobName.
+//
+// NaN is returned if there is no health stats for the given jobName yet.
+func (tsm *targetStatusMap) getJobHealthValue(jobName string, f func(jh *jobHealth, targetsUp int) float64) float64 {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	jh := tsm.healthByJob[jobName]
+	if jh == nil {
+		return math.NaN()
+	}
+	return f(jh, tsm.upByJob[jobName])
+}
+
+func getJobHealthMetricNames(jobName string) []string {
+	var names []string
+	for _, hw := range healthWindows {
+		names = append(names,
+			fmt.Sprintf(`vm_promscrape_scrape_pool_availability{scrape_job=%q, window=%q}`, jobName, hw.name),
+			fmt.Sprintf(`vm_promscrape_scrape_pool_targets_up_max{scrape_job=%q, window=%q}`, jobName, hw.name),
+			fmt.Sprintf(`vm_promscrape_scrape_pool_lost_targets_ratio{scrape_job=%q, window=%q}`, jobName, hw.name),
+		)
+	}
+	for _, phi := range healthQuantiles {
+		names = append(names, fmt.Sprintf(`vm_promscrape_scrape_pool_scrape_duration_seconds{scrape_job=%q, quantile="%g"}`, jobName, phi))
+	}
+	names = append(names, fmt.Sprintf(`vm_promscrape_scrape_pool_samples_scraped{scrape_job=%q}`, jobName))
+	return names
+}
+
+func unregisterJobHealthMetrics(jobName string) {
+	for _, name := range getJobHealthMetricNames(jobName) {
+		metrics.UnregisterMetric(name)
+	}
+}
+
+// registerJobHealthMetrics registers health metrics for the given jobName.
+//
+// The registered metrics must be in sync with getJobHealthMetricNames.
+func (tsm *targetStatusMap) registerJobHealthMetrics(jobName string) {
+	for _, hw := range healthWindows {
+		window := hw.duration
+		_ = metrics.NewGauge(fmt.Sprintf(`vm_promscrape_scrape_pool_availability{scrape_job=%q, window=%q}`, jobName, hw.name), func() float64 {
+			return tsm.getJobHealthValue(jobName, func(jh *jobHealth, _ int) float64 {
+				return jh.availability(fasttime.UnixTimestamp(), window)
+			})
+		})
+		_ = metrics.NewGauge(fmt.Sprintf(`vm_promscrape_scrape_pool_targets_up_max{scrape_job=%q, window=%q}`, jobName, hw.name), func() float64 {
+			return tsm.getJobHealthValue(jobName, func(jh *jobHealth, targetsUp int) float64 {
+				return float64(max(jh.maxTargetsUp(fasttime.UnixTimestamp(), window), targetsUp))
+			})
+		})
+		_ = metrics.NewGauge(fmt.Sprintf(`vm_promscrape_scrape_pool_lost_targets_ratio{scrape_job=%q, window=%q}`, jobName, hw.name), func() float64 {
+			return tsm.getJobHealthValue(jobName, func(jh *jobHealth, targetsUp int) float64 {
+				return getLostTargetsRatio(targetsUp, jh.maxTargetsUp(fasttime.UnixTimestamp(), window))
+			})
+		})
+	}
+	for _, phi := range healthQuantiles {
+		phis := []float64{phi}
+		_ = metrics.NewGauge(fmt.Sprintf(`vm_promscrape_scrape_pool_scrape_duration_seconds{scrape_job=%q, quantile="%g"}`, jobName, phi), func() float64 {
+			return tsm.getJobHealthValue(jobName, func(jh *jobHealth, _ int) float64 {
+				return jh.scrapeDurationQuantiles(phis)[0]
+			})
+		})
+	}
+	_ = metrics.NewGauge(fmt.Sprintf(`vm_promscrape_scrape_pool_samples_scraped{scrape_job=%q}`, jobName), func() float64 {
+		tsm.mu.Lock()
+		n := tsm.samplesByJob[jobName]
+		tsm.mu.Unlock()
+		return float64(n)
+	})
+}
+
+// WriteAPIV1TargetsHealth writes health stats for scrape jobs and their targets in JSON format to w.
+//
+// If scrapePool isn't empty, then only the job with the given name is written.
+func WriteAPIV1TargetsHealth(w io.Writer, scrapePool string) {
+	tsmGlobal.WriteTargetsHealthJSON(w, scrapePool, fasttime.UnixTimestamp())
+}
+
+type jobHealthStatus struct {
+	jobName        string
+	targetsUp      int
+	targetsDown    int
+	samplesScraped int
+	availability   []float64
+	maxTargetsUp   []int
+	durationQs     []float64
+	targetStatuses []targetStatus
+}
+
+// emptyJobHealth is used for jobs without scrapes yet. It mustn't be modified.
+var emptyJobHealth jobHealth
+
+func (tsm *targetStatusMap) getJobHealthStatuses(scrapePoolFilter string, currentTime uint64) []*jobHealthStatus {
+	tss := tsm.getActiveTargetStatuses()
+
+	tsm.mu.Lock()
+	// Jobs without targets are registered in tsm.jobNames only,
+	// while jobs of targets left after config reload may be missing in tsm.jobNames.
+	jobNames := append([]string{}, tsm.jobNames...)
+	for _, ts := range tss {
+		jobNames = append(jobNames, ts.sw.Config.jobNameOriginal)
+	}
+	m := make(map[string]*jobHealthStatus, len(tsm.jobNames))
+	for _, jobName := range jobNames {
+		if scrapePoolFilter != "" && jobName != scrapePoolFilter {
+			continue
+		}
+		if m[jobName] != nil {
+			continue
+		}
+		jhs := &jobHealthStatus{
+			jobName:        jobName,
+			targetsUp:      tsm.upByJob[jobName],
+			targetsDown:    tsm.downByJob[jobName],
+			samplesScraped: tsm.samplesByJob[jobName],
+		}
+		jh := tsm.healthByJob[jobName]
+		if jh == nil {
+			jh = &emptyJobHealth
+		}
+		for _, hw := range healthWindows {
+			jhs.availability = append(jhs.availability, jh.availability(currentTime, hw.duration))
+			jhs.maxTargetsUp = append(jhs.maxTargetsUp, max(jh.maxTargetsUp(currentTime, hw.duration), jhs.targetsUp))
+		}
+		jhs.durationQs = jh.scrapeDurationQuantiles(healthQuantiles)
+		m[jobName] = jhs
+	}
+	tsm.mu.Unlock()
+
+	for _, ts := range tss {
+		if jhs := m[ts.sw.Config.jobNameOriginal]; jhs != nil {
+			jhs.targetStatuses = append(jhs.targetStatuses, ts)
+		}
+	}
+	jhss := make([]*jobHealthStatus, 0, len(m))
+	for _, jhs := range m {
+		jhss = append(jhss, jhs)
+	}
+	sort.Slice(jhss, func(i, j int) bool {
+		return jhss[i].jobName < jhss[j].jobName
+	})
+	return jhss
+}
+
+// WriteTargetsHealthJSON writes health stats for scrape jobs and their targets to w.
+func (tsm *targetStatusMap) WriteTargetsHealthJSON(w io.Writer, scrapePoolFilter string, currentTime uint64) {
+	jhss := tsm.getJobHealthStatuses(scrapePoolFilter, currentTime)
+	fmt.Fprintf(w, `{"status":"success","data":[`)
+	for i, jhs := range jhss {
+		if i > 0 {
+			fmt.Fprintf(w, `,`)
+		}
+		fmt.Fprintf(w, `{"scrapePool":%s`, stringsutil.JSONString(jhs.jobName))
+		fmt.Fprintf(w, `,"targetsUp":%d,"targetsDown":%d`, jhs.targetsUp, jhs.targetsDown)
+		fmt.Fprintf(w, `,"samplesScraped":%d`, jhs.samplesScraped)
+		writeHealthWindowsJSON(w, "availability", func(i int) string {
+			return formatJSONFloat(jhs.availability[i])
+		})
+		writeHealthWindowsJSON(w, "maxTargetsUp", func(i int) string {
+			return fmt.Sprintf("%d", jhs.maxTargetsUp[i])
+		})
+		writeHealthWindowsJSON(w, "lostTargetsRatio", func(i int) string {
+			return formatJSONFloat(getLostTargetsRatio(jhs.targetsUp, jhs.maxTargetsUp[i]))
+		})
+		fmt.Fprintf(w, `,"scrapeDurationSeconds":{`)
+		for i, phi := range healthQuantiles {
+			if i > 0 {
+				fmt.Fprintf(w, `,`)
+			}
+			fmt.Fprintf(w, `"%g":%s`, phi, formatJSONFloat(jhs.durationQs[i]))
+		}
+		fmt.Fprintf(w, `},"targets":[`)
+		for i, ts := range jhs.targetStatuses {
+			if i > 0 {
+				fmt.Fprintf(w, `,`)
+			}
+			fmt.Fprintf(w, `{"labels":`)
+			writeLabelsJSON(w, ts.sw.Config.Labels)
+			fmt.Fprintf(w, `,"scrapeUrl":%s`, stringsutil.JSONString(ts.sw.Config.ScrapeURL))
+			state := "up"
+			if !ts.up {
+				state = "down"
+			}
+			fmt.Fprintf(w, `,"health":%s`, stringsutil.JSONString(state))
+			fmt.Fprintf(w, `,"scrapesTotal":%d,"scrapesFailed":%d`, ts.scrapesTotal, ts.scrapesFailed)
+			fmt.Fprintf(w, `,"lastScrapeDuration":%g`, (time.Millisecond * time.Duration(ts.scrapeDuration)).Seconds())
+			fmt.Fprintf(w, `,"lastSamplesScraped":%d,"samplesScrapedDelta":%d}`, ts.samplesScraped, ts.getSamplesScrapedDelta())
+		}
+		fmt.Fprintf(w, `]}`)
+	}
+	fmt.Fprintf(w, `]}`)
+}
+
+func writeHealthWindowsJSON(w io.Writer, name string, format func(i int) string) {
+	fmt.Fprintf(w, `,%q:{`, name)
+	for i, hw := range healthWindows {
+		if i > 0 {
+			fmt.Fprintf(w, `,`)
+		}
+		fmt.Fprintf(w, `%q:%s`, hw.name, format(i))
+	}
+	fmt.Fprintf(w, `}`)
+}
+
+// formatJSONFloat formats f as JSON value. NaN and Inf values are formatted as null, since they aren't supported by JSON.
+func formatJSONFloat(f float64) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "null"
+	}
+	return fmt.Sprintf("%g", f)
+}
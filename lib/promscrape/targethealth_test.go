@@ -0,0 +1,259 @@
+package promscrape
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
+)
+
+func TestJobHealthAvailability(t *testing.T) {
+	var jh jobHealth
+	currentTime := uint64(1700000000)
+
+	f := func(window uint64, availabilityExpected float64) {
+		t.Helper()
+		availability := jh.availability(currentTime, window)
+		if math.IsNaN(availabilityExpected) {
+			if !math.IsNaN(availability) {
+				t.Fatalf("unexpected availability; got %v; want NaN", availability)
+			}
+			return
+		}
+		if math.Abs(availability-availabilityExpected) > 1e-9 {
+			t.Fatalf("unexpected availability; got %v; want %v", availability, availabilityExpected)
+		}
+	}
+
+	// no scrapes
+	f(3600, math.NaN())
+
+	// failed scrapes two hours ago
+	for i := 0; i < 10; i++ {
+		jh.update(currentTime-2*3600+uint64(i), false, 0.1, 0)
+	}
+	f(3600, math.NaN())
+	f(24*3600, 0)
+
+	// successful scrapes during the last hour
+	for i := 0; i < 30; i++ {
+		jh.update(currentTime-uint64(i)*60, true, 0.1, 5)
+	}
+	f(3600, 1)
+	f(24*3600, 30.0/40)
+
+	// the failed scrapes go out of the 24h window
+	currentTime += 23 * 3600
+	f(3600, math.NaN())
+	f(24*3600, 1)
+
+	// the bucket for the current time must be reset when it is re-used after 24 hours
+	jh.update(currentTime+24*3600, false, 0.1, 0)
+	currentTime += 24 * 3600
+	f(3600, 0)
+	f(24*3600, 0)
+}
+
+func TestJobHealthMaxTargetsUp(t *testing.T) {
+	var jh jobHealth
+	currentTime := uint64(1700000000)
+
+	f := func(window uint64, nExpected int) {
+		t.Helper()
+		n := jh.maxTargetsUp(currentTime, window)
+		if n != nExpected {
+			t.Fatalf("unexpected maxTargetsUp for window=%d; got %d; want %d", window, n, nExpected)
+		}
+	}
+
+	f(3600, 0)
+
+	jh.update(currentTime-5*3600, true, 0.1, 10)
+	jh.update(currentTime-1800, true, 0.1, 7)
+	jh.update(currentTime-600, true, 0.1, 3)
+	f(3600, 7)
+	f(24*3600, 10)
+
+	if ratio := getLostTargetsRatio(3, 10); math.Abs(ratio-0.7) > 1e-9 {
+		t.Fatalf("unexpected lost targets ratio; got %v; want 0.7", ratio)
+	}
+	if ratio := getLostTargetsRatio(3, 0); ratio != 0 {
+		t.Fatalf("unexpected lost targets ratio; got %v; want 0", ratio)
+	}
+}
+
+func TestJobHealthScrapeDurationQuantiles(t *testing.T) {
+	var jh jobHealth
+
+	qs := jh.scrapeDurationQuantiles(healthQuantiles)
+	for _, q := range qs {
+		if !math.IsNaN(q) {
+			t.Fatalf("expecting NaN quantiles for empty jobHealth; got %v", qs)
+		}
+	}
+
+	// Only the most recent scrapeDurationsCount durations must be taken into account.
+	for i := 0; i < scrapeDurationsCount; i++ {
+		jh.update(1700000000, true, 100, 1)
+	}
+	for i := 1; i <= scrapeDurationsCount; i++ {
+		jh.update(1700000000, true, float64(i)/scrapeDurationsCount, 1)
+	}
+	qs = jh.scrapeDurationQuantiles([]float64{0, 0.5, 0.99, 1})
+	qsExpected := []float64{1.0 / scrapeDurationsCount, 257.0 / scrapeDurationsCount, 507.0 / scrapeDurationsCount, 1}
+	if !reflect.DeepEqual(qs, qsExpected) {
+		t.Fatalf("unexpected quantiles\ngot\n%v\nwant\n%v", qs, qsExpected)
+	}
+}
+
+func TestWriteTargetsHealthJSON(t *testing.T) {
+	tsm := newTargetStatusMap()
+	newScrapeWork := func(jobName, addr string) *scrapeWork {
+		return &scrapeWork{
+			Config: &ScrapeWork{
+				jobNameOriginal: jobName,
+				ScrapeURL:       "http://" + addr + "/metrics",
+				OriginalLabels: promutil.NewLabelsFromMap(map[string]string{
+					"__address__": addr,
+				}),
+				Labels: promutil.NewLabelsFromMap(map[string]string{
+					"instance": addr,
+					"job":      jobName,
+				}),
+			},
+		}
+	}
+	sw1 := newScrapeWork("foo", "host1:80")
+	sw2 := newScrapeWork("foo", "host2:80")
+	sw3 := newScrapeWork("bar", "host3:80")
+	tsm.Register(sw1)
+	tsm.Register(sw2)
+	tsm.Register(sw3)
+
+	tsm.Update(sw1, true, 1000, 100, 10, 10, nil)
+	tsm.Update(sw1, true, 2000, 300, 10, 15, nil)
+	tsm.Update(sw2, true, 1000, 200, 10, 20, nil)
+	tsm.Update(sw2, false, 2000, 400, 0, 0, fmt.Errorf("error"))
+
+	type healthTarget struct {
+		ScrapeURL           string  `json:"scrapeUrl"`
+		Health              string  `json:"health"`
+		ScrapesTotal        int     `json:"scrapesTotal"`
+		ScrapesFailed       int     `json:"scrapesFailed"`
+		LastScrapeDuration  float64 `json:"lastScrapeDuration"`
+		LastSamplesScraped  int     `json:"lastSamplesScraped"`
+		SamplesScrapedDelta int     `json:"samplesScrapedDelta"`
+	}
+	type healthJob struct {
+		ScrapePool            string              `json:"scrapePool"`
+		TargetsUp             int                 `json:"targetsUp"`
+		TargetsDown           int                 `json:"targetsDown"`
+		SamplesScraped        int                 `json:"samplesScraped"`
+		Availability          map[string]*float64 `json:"availability"`
+		MaxTargetsUp          map[string]int      `json:"maxTargetsUp"`
+		LostTargetsRatio      map[string]float64  `json:"lostTargetsRatio"`
+		ScrapeDurationSeconds map[string]*float64 `json:"scrapeDurationSeconds"`
+		Targets               []healthTarget      `json:"targets"`
+	}
+	type healthResponse struct {
+		Status string      `json:"status"`
+		Data   []healthJob `json:"data"`
+	}
+
+	f := func(scrapePoolFilter string) []healthJob {
+		t.Helper()
+		var bb bytes.Buffer
+		tsm.WriteTargetsHealthJSON(&bb, scrapePoolFilter, fasttime.UnixTimestamp())
+		var resp healthResponse
+		if err := json.Unmarshal(bb.Bytes(), &resp); err != nil {
+			t.Fatalf("cannot unmarshal response %q: %s", bb.String(), err)
+		}
+		if resp.Status != "success" {
+			t.Fatalf("unexpected status; got %q; want %q", resp.Status, "success")
+		}
+		return resp.Data
+	}
+
+	jobs := f("")
+	if len(jobs) != 2 || jobs[0].ScrapePool != "bar" || jobs[1].ScrapePool != "foo" {
+		t.Fatalf("unexpected jobs: %+v", jobs)
+	}
+
+	// The job without scrapes has no availability and scrape duration stats
+	bar := jobs[0]
+	if bar.Availability["1h"] != nil || bar.ScrapeDurationSeconds["0.5"] != nil {
+		t.Fatalf("unexpected stats for the job without scrapes: %+v", bar)
+	}
+	if bar.TargetsDown != 1 || len(bar.Targets) != 1 || bar.Targets[0].Health != "down" {
+		t.Fatalf("unexpected targets for the job without scrapes: %+v", bar)
+	}
+
+	foo := f("foo")
+	if len(foo) != 1 {
+		t.Fatalf("unexpected number of jobs; got %d; want 1", len(foo))
+	}
+	jobExpected := healthJob{
+		ScrapePool:     "foo",
+		TargetsUp:      1,
+		TargetsDown:    1,
+		SamplesScraped: 15,
+		Availability: map[string]*float64{
+			"1h":  ptrFloat64(0.75),
+			"24h": ptrFloat64(0.75),
+		},
+		MaxTargetsUp: map[string]int{
+			"1h":  2,
+			"24h": 2,
+		},
+		LostTargetsRatio: map[string]float64{
+			"1h":  0.5,
+			"24h": 0.5,
+		},
+		ScrapeDurationSeconds: map[string]*float64{
+			"0.5":  ptrFloat64(0.3),
+			"0.9":  ptrFloat64(0.4),
+			"0.99": ptrFloat64(0.4),
+		},
+		Targets: []healthTarget{
+			{
+				ScrapeURL:           "http://host1:80/metrics",
+				Health:              "up",
+				ScrapesTotal:        2,
+				LastScrapeDuration:  0.3,
+				LastSamplesScraped:  15,
+				SamplesScrapedDelta: 5,
+			},
+			{
+				ScrapeURL:           "http://host2:80/metrics",
+				Health:              "down",
+				ScrapesTotal:        2,
+				ScrapesFailed:       1,
+				LastScrapeDuration:  0.4,
+				SamplesScrapedDelta: -20,
+			},
+		},
+	}
+	if !reflect.DeepEqual(foo[0], jobExpected) {
+		t.Fatalf("unexpected job health\ngot\n%+v\nwant\n%+v", foo[0], jobExpected)
+	}
+
+	if jobs := f("unknown"); len(jobs) != 0 {
+		t.Fatalf("unexpected jobs for unknown scrapePool: %+v", jobs)
+	}
+
+	// Unregistered targets must be excluded from the stats
+	tsm.Unregister(sw1)
+	foo = f("foo")
+	if foo[0].TargetsUp != 0 || foo[0].SamplesScraped != 0 || len(foo[0].Targets) != 1 || foo[0].LostTargetsRatio["1h"] != 1 {
+		t.Fatalf("unexpected job health after unregistering the target: %+v", foo[0])
+	}
+}
+
+func ptrFloat64(f float64) *float64 {
+	return &f
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/VictoriaMetrics/metrics"
 	"github.com/cespare/xxhash/v2"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promrelabel"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
@@ -97,6 +98,15 @@ type targetStatusMap struct {
 
 	// the current number of `down` targets in the given jobName
 	downByJob map[string]int
+
+	// the number of samples scraped during the last scrape of targets in the given jobName
+	samplesByJob map[string]int
+
+	// health stats for the given jobName
+	healthByJob map[string]*jobHealth
+
+	// the usage of sample_budget and series_budget for the given jobName
+	budgetByJob map[string]*jobBudget
 }
 
 func newTargetStatusMap() *targetStatusMap {
@@ -104,6 +114,10 @@ func newTargetStatusMap() *targetStatusMap {
 		m:         make(map[*scrapeWork]*targetStatus),
 		upByJob:   make(map[string]int),
 		downByJob: make(map[string]int),
+
+		samplesByJob: make(map[string]int),
+		healthByJob:  make(map[string]*jobHealth),
+		budgetByJob:  make(map[string]*jobBudget),
 	}
 }
 
@@ -128,6 +142,8 @@ func (tsm *targetStatusMap) registerJobsMetricsLocked(prevJobNames, currentJobNa
 		if _, ok := currentNames[jobName]; !ok {
 			metrics.UnregisterMetric(fmt.Sprintf(`vm_promscrape_scrape_pool_targets{scrape_job=%q, status="up"}`, jobName))
 			metrics.UnregisterMetric(fmt.Sprintf(`vm_promscrape_scrape_pool_targets{scrape_job=%q, status="down"}`, jobName))
+			unregisterJobHealthMetrics(jobName)
+			delete(tsm.healthByJob, jobName)
 		}
 	}
 
@@ -148,6 +164,7 @@ func (tsm *targetStatusMap) registerJobsMetricsLocked(prevJobNames, currentJobNa
 			tsm.mu.Unlock()
 			return float64(n)
 		})
+		tsm.registerJobHealthMetrics(jobNameLocal)
 	}
 }
 
@@ -159,6 +176,15 @@ func (tsm *targetStatusMap) Register(sw *scrapeWork) {
 		sw: sw,
 	}
 	tsm.downByJob[jobName]++
+	if sw.Config.hasScrapeBudget() {
+		jb := tsm.budgetByJob[jobName]
+		if jb == nil {
+			jb = newJobBudget()
+			tsm.budgetByJob[jobName] = jb
+		}
+		jb.targets++
+		sw.jobBudget = jb
+	}
 	tsm.mu.Unlock()
 }
 
@@ -175,10 +201,60 @@ func (tsm *targetStatusMap) Unregister(sw *scrapeWork) {
 	} else {
 		tsm.downByJob[jobName]--
 	}
+	tsm.samplesByJob[jobName] -= ts.samplesScraped
+	if tsm.samplesByJob[jobName] == 0 {
+		delete(tsm.samplesByJob, jobName)
+	}
+	if jb := sw.jobBudget; jb != nil {
+		jb.samples -= ts.budgetSamples
+		jb.seriesAdded -= ts.budgetSeriesAdded
+		jb.targets--
+		if jb.targets == 0 && tsm.budgetByJob[jobName] == jb {
+			delete(tsm.budgetByJob, jobName)
+		}
+	}
 	delete(tsm.m, sw)
 	tsm.mu.Unlock()
 }
 
+// updateJobBudget updates the usage of sample_budget and series_budget for the job of sw
+// with the given number of samples and new series scraped from sw during the last scrape.
+//
+// The scrape interval for all the job targets is updated according to the usage by all the job targets.
+func (tsm *targetStatusMap) updateJobBudget(sw *scrapeWork, samples, seriesAdded int) {
+	cfg := sw.Config
+	jb := sw.jobBudget
+
+	tsm.mu.Lock()
+	ts, ok := tsm.m[sw]
+	if !ok {
+		logger.Panicf("BUG: missing Register() call for the target %q", cfg.jobNameOriginal)
+	}
+	jb.samples += samples - ts.budgetSamples
+	jb.seriesAdded += seriesAdded - ts.budgetSeriesAdded
+	ts.budgetSamples = samples
+	ts.budgetSeriesAdded = seriesAdded
+	jobSamples := jb.samples
+	jobSeriesAdded := jb.seriesAdded
+	prevMultiplier := int(jb.multiplier.Load())
+	multiplier := getScrapeIntervalMultiplier(cfg, prevMultiplier, jobSamples, jobSeriesAdded)
+	jb.multiplier.Store(int64(multiplier))
+	tsm.mu.Unlock()
+
+	if multiplier == prevMultiplier {
+		return
+	}
+	scrapeInterval := time.Duration(multiplier) * cfg.ScrapeInterval
+	if multiplier > prevMultiplier {
+		logger.Warnf("increasing scrape interval for job %q to %s, since its targets expose %d samples and %d new series, "+
+			"which exceeds sample_budget=%d or series_budget=%d per scrape_interval=%s",
+			cfg.jobNameOriginal, scrapeInterval, jobSamples, jobSeriesAdded, cfg.SampleBudget, cfg.SeriesBudget, cfg.ScrapeInterval)
+	} else {
+		logger.Infof("decreasing scrape interval for job %q to %s, since its targets expose %d samples and %d new series",
+			cfg.jobNameOriginal, scrapeInterval, jobSamples, jobSeriesAdded)
+	}
+}
+
 func (tsm *targetStatusMap) Update(sw *scrapeWork, up bool, scrapeTime, scrapeDuration int64, scrapeResponseSize, samplesScraped int, err error) {
 	jobName := sw.Config.jobNameOriginal
 
@@ -197,6 +273,8 @@ func (tsm *targetStatusMap) Update(sw *scrapeWork, up bool, scrapeTime, scrapeDu
 	ts.up = up
 	ts.scrapeTime = scrapeTime
 	ts.scrapeDuration = scrapeDuration
+	tsm.samplesByJob[jobName] += samplesScraped - ts.samplesScraped
+	ts.samplesScrapedPrev = ts.samplesScraped
 	ts.samplesScraped = samplesScraped
 	ts.scrapeResponseSize = scrapeResponseSize
 	ts.scrapesTotal++
@@ -204,6 +282,7 @@ func (tsm *targetStatusMap) Update(sw *scrapeWork, up bool, scrapeTime, scrapeDu
 		ts.scrapesFailed++
 	}
 	ts.err = err
+	tsm.updateJobHealthLocked(jobName, fasttime.UnixTimestamp(), up, scrapeDuration)
 	tsm.mu.Unlock()
 }
 
@@ -281,6 +360,7 @@ func (tsm *targetStatusMap) WriteActiveTargetsJSON(w io.Writer, scrapePoolFilter
 		fmt.Fprintf(w, `,"lastScrape":"%s"`, time.Unix(ts.scrapeTime/1000, (ts.scrapeTime%1000)*1e6).Format(time.RFC3339Nano))
 		fmt.Fprintf(w, `,"lastScrapeDuration":%g`, (time.Millisecond * time.Duration(ts.scrapeDuration)).Seconds())
 		fmt.Fprintf(w, `,"lastSamplesScraped":%d`, ts.samplesScraped)
+		fmt.Fprintf(w, `,"scrapeInterval":%s`, stringsutil.JSONString(ts.sw.getEffectiveScrapeInterval().String()))
 		state := "up"
 		if !ts.up {
 			state = "down"
@@ -310,6 +390,9 @@ type targetStatus struct {
 	scrapeDuration     int64
 	scrapeResponseSize int
 	samplesScraped     int
+	samplesScrapedPrev int
+	budgetSamples      int
+	budgetSeriesAdded  int
 	scrapesTotal       int
 	scrapesFailed      int
 	err                error
@@ -323,6 +406,14 @@ func (ts *targetStatus) getDurationFromLastScrape() string {
 	return fmt.Sprintf("%.3fs ago", d.Seconds())
 }
 
+// getSamplesScrapedDelta returns the difference between the number of samples scraped during the last two scrapes.
+func (ts *targetStatus) getSamplesScrapedDelta() int {
+	if ts.scrapesTotal < 2 {
+		return 0
+	}
+	return ts.samplesScraped - ts.samplesScrapedPrev
+}
+
 func (ts *targetStatus) getSizeFromLastScrape() string {
 	if ts.scrapeResponseSize <= 0 {
 		return "never scraped"
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutil"
 )
@@ -58,3 +59,62 @@ func TestWriteActiveTargetsJSON(t *testing.T) {
 	})
 	f("unknown", []activeTarget{})
 }
+
+func TestTargetStatusMapUpdateJobBudget(t *testing.T) {
+	tsm := newTargetStatusMap()
+	newScrapeWork := func(jobName string) *scrapeWork {
+		sw := &scrapeWork{
+			Config: &ScrapeWork{
+				jobNameOriginal:   jobName,
+				ScrapeInterval:    10 * time.Second,
+				MaxScrapeInterval: time.Minute,
+				SampleBudget:      1000,
+			},
+		}
+		tsm.Register(sw)
+		return sw
+	}
+	f := func(sw *scrapeWork, samples int, multiplierExpected int) {
+		t.Helper()
+		tsm.updateJobBudget(sw, samples, 0)
+		if multiplier := sw.getCurrentScrapeIntervalMultiplier(); multiplier != multiplierExpected {
+			t.Fatalf("unexpected multiplier; got %d; want %d", multiplier, multiplierExpected)
+		}
+		scrapeIntervalExpected := time.Duration(multiplierExpected) * sw.Config.ScrapeInterval
+		if scrapeInterval := sw.getEffectiveScrapeInterval(); scrapeInterval != scrapeIntervalExpected {
+			t.Fatalf("unexpected scrape interval; got %s; want %s", scrapeInterval, scrapeIntervalExpected)
+		}
+	}
+
+	sw1 := newScrapeWork("foo")
+	sw2 := newScrapeWork("foo")
+	sw3 := newScrapeWork("foo")
+	swOther := newScrapeWork("bar")
+
+	// every target fits sample_budget, while the job exceeds it
+	f(sw1, 600, 1)
+	f(sw2, 600, 2)
+	if multiplier := sw1.getCurrentScrapeIntervalMultiplier(); multiplier != 2 {
+		t.Fatalf("unexpected multiplier for the first target of the job; got %d; want 2", multiplier)
+	}
+	f(sw3, 900, 3)
+
+	// the budget of other jobs isn't affected
+	f(swOther, 900, 1)
+
+	// repeated scrapes of the same target do not accumulate
+	f(sw3, 900, 3)
+
+	// the multiplier is decreased when the job usage decreases
+	f(sw3, 0, 2)
+
+	// the multiplier is decreased when targets are unregistered
+	tsm.Unregister(sw2)
+	f(sw1, 600, 1)
+
+	tsm.Unregister(sw1)
+	tsm.Unregister(sw3)
+	if _, ok := tsm.budgetByJob["foo"]; ok {
+		t.Fatalf("the budget for the job must be removed after unregistering all its targets")
+	}
+}
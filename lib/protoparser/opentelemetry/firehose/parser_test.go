@@ -225,7 +225,7 @@ func TestProcessRequestBody(t *testing.T) {
 {__name__="amazonaws.com/AWS/EBS/VolumeReadOps",cloud.provider="aws",cloud.account.id="677435890598",cloud.region="us-east-1",aws.exporter.arn="arn:aws:cloudwatch:us-east-1:677435890598:metric-stream/custom_ebs_metric",quantile="1"} 0 1709217300000
 `
 	var callbackCalls atomic.Uint64
-	err := stream.ParseStream(bytes.NewReader(data), "", ProcessRequestBody, func(tss []prompbmarshal.TimeSeries) error {
+	err := stream.ParseStream(bytes.NewReader(data), "", ProcessRequestBody, func(tss []prompbmarshal.TimeSeries, _ []prompbmarshal.MetricMetadata) error {
 		callbackCalls.Add(1)
 		s := formatTimeseries(tss)
 		if s != sExpected {
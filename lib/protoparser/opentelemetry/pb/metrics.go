@@ -152,6 +152,7 @@ func (sm *ScopeMetrics) unmarshalProtobuf(src []byte) (err error) {
 // Metric represents the corresponding OTEL protobuf message
 type Metric struct {
 	Name                 string
+	Description          string
 	Unit                 string
 	Gauge                *Gauge
 	Sum                  *Sum
@@ -162,6 +163,7 @@ type Metric struct {
 
 func (m *Metric) marshalProtobuf(mm *easyproto.MessageMarshaler) {
 	mm.AppendString(1, m.Name)
+	mm.AppendString(2, m.Description)
 	mm.AppendString(3, m.Unit)
 	switch {
 	case m.Gauge != nil:
@@ -180,6 +182,7 @@ func (m *Metric) marshalProtobuf(mm *easyproto.MessageMarshaler) {
 func (m *Metric) unmarshalProtobuf(src []byte) (err error) {
 	// message Metric {
 	//   string name = 1;
+	//   string description = 2;
 	//   string unit = 3;
 	//   oneof data {
 	//     Gauge gauge = 5;
@@ -202,6 +205,12 @@ func (m *Metric) unmarshalProtobuf(src []byte) (err error) {
 				return fmt.Errorf("cannot read metric name")
 			}
 			m.Name = strings.Clone(name)
+		case 2:
+			description, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read metric description")
+			}
+			m.Description = strings.Clone(description)
 		case 3:
 			unit, ok := fc.String()
 			if !ok {
@@ -301,6 +310,7 @@ type NumberDataPoint struct {
 	TimeUnixNano uint64
 	DoubleValue  *float64
 	IntValue     *int64
+	Exemplars    []*Exemplar
 	Flags        uint32
 }
 
@@ -315,6 +325,9 @@ func (ndp *NumberDataPoint) marshalProtobuf(mm *easyproto.MessageMarshaler) {
 	case ndp.IntValue != nil:
 		mm.AppendSfixed64(6, *ndp.IntValue)
 	}
+	for _, e := range ndp.Exemplars {
+		e.marshalProtobuf(mm.AppendMessage(5))
+	}
 	mm.AppendUint32(8, ndp.Flags)
 }
 
@@ -326,6 +339,7 @@ func (ndp *NumberDataPoint) unmarshalProtobuf(src []byte) (err error) {
 	//     double as_double = 4;
 	//     sfixed64 as_int = 6;
 	//   }
+	//   repeated Exemplar exemplars = 5;
 	//   uint32 flags = 8;
 	// }
 	var fc easyproto.FieldContext
@@ -363,6 +377,16 @@ func (ndp *NumberDataPoint) unmarshalProtobuf(src []byte) (err error) {
 				return fmt.Errorf("cannot read IntValue")
 			}
 			ndp.IntValue = &intValue
+		case 5:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read Exemplar")
+			}
+			ndp.Exemplars = append(ndp.Exemplars, &Exemplar{})
+			e := ndp.Exemplars[len(ndp.Exemplars)-1]
+			if err := e.unmarshalProtobuf(data); err != nil {
+				return fmt.Errorf("cannot unmarshal Exemplar: %w", err)
+			}
 		case 8:
 			flags, ok := fc.Uint32()
 			if !ok {
@@ -577,6 +601,7 @@ type HistogramDataPoint struct {
 	Sum            *float64
 	BucketCounts   []uint64
 	ExplicitBounds []float64
+	Exemplars      []*Exemplar
 	Flags          uint32
 }
 
@@ -591,6 +616,9 @@ func (dp *HistogramDataPoint) marshalProtobuf(mm *easyproto.MessageMarshaler) {
 	}
 	mm.AppendFixed64s(6, dp.BucketCounts)
 	mm.AppendDoubles(7, dp.ExplicitBounds)
+	for _, e := range dp.Exemplars {
+		e.marshalProtobuf(mm.AppendMessage(8))
+	}
 	mm.AppendUint32(10, dp.Flags)
 }
 
@@ -602,6 +630,7 @@ func (dp *HistogramDataPoint) unmarshalProtobuf(src []byte) (err error) {
 	//   optional double sum = 5;
 	//   repeated fixed64 bucket_counts = 6;
 	//   repeated double explicit_bounds = 7;
+	//   repeated Exemplar exemplars = 8;
 	//   uint32 flags = 10;
 	// }
 	var fc easyproto.FieldContext
@@ -651,6 +680,16 @@ func (dp *HistogramDataPoint) unmarshalProtobuf(src []byte) (err error) {
 				return fmt.Errorf("cannot read ExplicitBounds")
 			}
 			dp.ExplicitBounds = explicitBounds
+		case 8:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read Exemplar")
+			}
+			dp.Exemplars = append(dp.Exemplars, &Exemplar{})
+			e := dp.Exemplars[len(dp.Exemplars)-1]
+			if err := e.unmarshalProtobuf(data); err != nil {
+				return fmt.Errorf("cannot unmarshal Exemplar: %w", err)
+			}
 		case 10:
 			flags, ok := fc.Uint32()
 			if !ok {
@@ -662,6 +701,94 @@ func (dp *HistogramDataPoint) unmarshalProtobuf(src []byte) (err error) {
 	return nil
 }
 
+// Exemplar represents the corresponding OTEL protobuf message
+type Exemplar struct {
+	FilteredAttributes []*KeyValue
+	TimeUnixNano       uint64
+	DoubleValue        *float64
+	IntValue           *int64
+	SpanID             []byte
+	TraceID            []byte
+}
+
+func (e *Exemplar) marshalProtobuf(mm *easyproto.MessageMarshaler) {
+	for _, a := range e.FilteredAttributes {
+		a.marshalProtobuf(mm.AppendMessage(7))
+	}
+	mm.AppendFixed64(2, e.TimeUnixNano)
+	switch {
+	case e.DoubleValue != nil:
+		mm.AppendDouble(3, *e.DoubleValue)
+	case e.IntValue != nil:
+		mm.AppendSfixed64(6, *e.IntValue)
+	}
+	mm.AppendBytes(4, e.SpanID)
+	mm.AppendBytes(5, e.TraceID)
+}
+
+func (e *Exemplar) unmarshalProtobuf(src []byte) (err error) {
+	// message Exemplar {
+	//   repeated KeyValue filtered_attributes = 7;
+	//   fixed64 time_unix_nano = 2;
+	//   oneof value {
+	//     double as_double = 3;
+	//     sfixed64 as_int = 6;
+	//   }
+	//   bytes span_id = 4;
+	//   bytes trace_id = 5;
+	// }
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read next field in Exemplar: %w", err)
+		}
+		switch fc.FieldNum {
+		case 7:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read FilteredAttribute")
+			}
+			e.FilteredAttributes = append(e.FilteredAttributes, &KeyValue{})
+			a := e.FilteredAttributes[len(e.FilteredAttributes)-1]
+			if err := a.unmarshalProtobuf(data); err != nil {
+				return fmt.Errorf("cannot unmarshal FilteredAttribute: %w", err)
+			}
+		case 2:
+			timeUnixNano, ok := fc.Fixed64()
+			if !ok {
+				return fmt.Errorf("cannot read TimeUnixNano")
+			}
+			e.TimeUnixNano = timeUnixNano
+		case 3:
+			doubleValue, ok := fc.Double()
+			if !ok {
+				return fmt.Errorf("cannot read DoubleValue")
+			}
+			e.DoubleValue = &doubleValue
+		case 6:
+			intValue, ok := fc.Sfixed64()
+			if !ok {
+				return fmt.Errorf("cannot read IntValue")
+			}
+			e.IntValue = &intValue
+		case 4:
+			spanID, ok := fc.Bytes()
+			if !ok {
+				return fmt.Errorf("cannot read SpanID")
+			}
+			e.SpanID = spanID
+		case 5:
+			traceID, ok := fc.Bytes()
+			if !ok {
+				return fmt.Errorf("cannot read TraceID")
+			}
+			e.TraceID = traceID
+		}
+	}
+	return nil
+}
+
 // ExponentialHistogramDataPoint represents the corresponding OTEL protobuf message
 type ExponentialHistogramDataPoint struct {
 	Attributes    []*KeyValue
@@ -1,9 +1,11 @@
 package stream
 
 import (
+	"encoding/hex"
 	"fmt"
 	"io"
 	"math"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -14,6 +16,8 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/opentelemetry/pb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/protoparserutil"
@@ -23,10 +27,12 @@ var maxRequestSize = flagutil.NewBytes("opentelemetry.maxRequestSize", 64*1024*1
 
 // ParseStream parses OpenTelemetry protobuf or json data from r and calls callback for the parsed rows.
 //
-// callback shouldn't hold tss items after returning.
+// mms passed to callback contains metric metadata if -enableMetadata command-line flag is set.
+//
+// callback shouldn't hold tss and mms items after returning.
 //
 // optional processBody can be used for pre-processing the read request body from r before parsing it in OpenTelemetry format.
-func ParseStream(r io.Reader, encoding string, processBody func(data []byte) ([]byte, error), callback func(tss []prompbmarshal.TimeSeries) error) error {
+func ParseStream(r io.Reader, encoding string, processBody func(data []byte) ([]byte, error), callback func(tss []prompbmarshal.TimeSeries, mms []prompbmarshal.MetricMetadata) error) error {
 	err := protoparserutil.ReadUncompressedData(r, encoding, maxRequestSize, func(data []byte) error {
 		if processBody != nil {
 			dataNew, err := processBody(data)
@@ -43,7 +49,7 @@ func ParseStream(r io.Reader, encoding string, processBody func(data []byte) ([]
 	return nil
 }
 
-func parseData(data []byte, callback func(tss []prompbmarshal.TimeSeries) error) error {
+func parseData(data []byte, callback func(tss []prompbmarshal.TimeSeries, mms []prompbmarshal.MetricMetadata) error) error {
 	var req pb.ExportMetricsServiceRequest
 	if err := req.UnmarshalProtobuf(data); err != nil {
 		return fmt.Errorf("cannot unmarshal request from %d bytes: %w", len(data), err)
@@ -54,7 +60,7 @@ func parseData(data []byte, callback func(tss []prompbmarshal.TimeSeries) error)
 
 	wr.parseRequestToTss(&req)
 
-	if err := callback(wr.tss); err != nil {
+	if err := callback(wr.tss, wr.mms); err != nil {
 		return fmt.Errorf("error when processing OpenTelemetry samples: %w", err)
 	}
 
@@ -70,6 +76,9 @@ func (wr *writeContext) appendSamplesFromScopeMetrics(sc *pb.ScopeMetrics) {
 			continue
 		}
 		metricName := sanitizeMetricName(m)
+		if prommetadata.IsEnabled() {
+			wr.appendMetadata(metricName, m)
+		}
 		switch {
 		case m.Gauge != nil:
 			for _, p := range m.Gauge.DataPoints {
@@ -113,6 +122,30 @@ func (wr *writeContext) appendSamplesFromScopeMetrics(sc *pb.ScopeMetrics) {
 	}
 }
 
+// appendMetadata appends metadata for m with the given metricName to wr.mms
+func (wr *writeContext) appendMetadata(metricName string, m *pb.Metric) {
+	metricType := prompb.MetricTypeUnknown
+	switch {
+	case m.Gauge != nil:
+		metricType = prompb.MetricTypeGauge
+	case m.Sum != nil:
+		metricType = prompb.MetricTypeGauge
+		if m.Sum.IsMonotonic {
+			metricType = prompb.MetricTypeCounter
+		}
+	case m.Summary != nil:
+		metricType = prompb.MetricTypeSummary
+	case m.Histogram != nil, m.ExponentialHistogram != nil:
+		metricType = prompb.MetricTypeHistogram
+	}
+	wr.mms = append(wr.mms, prompbmarshal.MetricMetadata{
+		Type:             uint32(metricType),
+		MetricFamilyName: metricName,
+		Help:             m.Description,
+		Unit:             m.Unit,
+	})
+}
+
 // appendSampleFromNumericPoint appends p to wr.tss
 func (wr *writeContext) appendSampleFromNumericPoint(metricName string, p *pb.NumberDataPoint) {
 	var v float64
@@ -128,6 +161,11 @@ func (wr *writeContext) appendSampleFromNumericPoint(metricName string, p *pb.Nu
 	wr.pointLabels = appendAttributesToPromLabels(wr.pointLabels[:0], p.Attributes)
 
 	wr.appendSample(metricName, t, v, isStale)
+	if !isStale {
+		for _, e := range p.Exemplars {
+			wr.appendExemplar(&wr.tss[len(wr.tss)-1], e)
+		}
+	}
 }
 
 // appendSamplesFromSummary appends summary p to wr.tss
@@ -167,6 +205,7 @@ func (wr *writeContext) appendSamplesFromHistogram(metricName string, p *pb.Hist
 	}
 
 	var cumulative uint64
+	bucketsStart := len(wr.tss)
 	for index, bound := range p.ExplicitBounds {
 		cumulative += p.BucketCounts[index]
 		boundLabelValue := strconv.FormatFloat(bound, 'f', -1, 64)
@@ -174,6 +213,64 @@ func (wr *writeContext) appendSamplesFromHistogram(metricName string, p *pb.Hist
 	}
 	cumulative += p.BucketCounts[len(p.BucketCounts)-1]
 	wr.appendSampleWithExtraLabel(metricName+"_bucket", "le", "+Inf", t, float64(cumulative), isStale)
+
+	if !isStale {
+		// Attach every exemplar to the `_bucket` series with the smallest `le` bound covering the exemplar value.
+		for _, e := range p.Exemplars {
+			idx := sort.SearchFloat64s(p.ExplicitBounds, getExemplarValue(e))
+			wr.appendExemplar(&wr.tss[bucketsStart+idx], e)
+		}
+	}
+}
+
+// appendExemplar converts e to prompbmarshal.Exemplar and appends it to ts.Exemplars.
+//
+// trace_id and span_id are converted to hex-encoded labels.
+func (wr *writeContext) appendExemplar(ts *prompbmarshal.TimeSeries, e *pb.Exemplar) {
+	labelsPool := wr.labelsPool
+	labelsLen := len(labelsPool)
+	labelsPool = appendAttributesToPromLabels(labelsPool, e.FilteredAttributes)
+	if len(e.TraceID) > 0 {
+		labelsPool = append(labelsPool, prompbmarshal.Label{
+			Name:  "trace_id",
+			Value: hex.EncodeToString(e.TraceID),
+		})
+	}
+	if len(e.SpanID) > 0 {
+		labelsPool = append(labelsPool, prompbmarshal.Label{
+			Name:  "span_id",
+			Value: hex.EncodeToString(e.SpanID),
+		})
+	}
+	wr.labelsPool = labelsPool
+	if len(labelsPool) == labelsLen {
+		// Skip exemplars without labels, since they cannot be used for linking to traces.
+		return
+	}
+
+	t := int64(e.TimeUnixNano / 1e6)
+	if t <= 0 {
+		t = ts.Samples[len(ts.Samples)-1].Timestamp
+	}
+	exemplarsLen := len(wr.exemplarsPool)
+	wr.exemplarsPool = append(wr.exemplarsPool, ts.Exemplars...)
+	wr.exemplarsPool = append(wr.exemplarsPool, prompbmarshal.Exemplar{
+		Labels:    labelsPool[labelsLen:],
+		Value:     getExemplarValue(e),
+		Timestamp: t,
+	})
+	ts.Exemplars = wr.exemplarsPool[exemplarsLen:]
+}
+
+func getExemplarValue(e *pb.Exemplar) float64 {
+	switch {
+	case e.IntValue != nil:
+		return float64(*e.IntValue)
+	case e.DoubleValue != nil:
+		return *e.DoubleValue
+	default:
+		return 0
+	}
 }
 
 // appendSamplesFromExponentialHistogram appends histogram p to wr.tss
@@ -284,6 +381,9 @@ type writeContext struct {
 	// tss holds parsed time series
 	tss []prompbmarshal.TimeSeries
 
+	// mms holds parsed metric metadata
+	mms []prompbmarshal.MetricMetadata
+
 	// baseLabels are labels, which must be added to all the ingested samples
 	baseLabels []prompbmarshal.Label
 
@@ -291,19 +391,26 @@ type writeContext struct {
 	pointLabels []prompbmarshal.Label
 
 	// pools are used for reducing memory allocations when parsing time series
-	labelsPool  []prompbmarshal.Label
-	samplesPool []prompbmarshal.Sample
+	labelsPool    []prompbmarshal.Label
+	samplesPool   []prompbmarshal.Sample
+	exemplarsPool []prompbmarshal.Exemplar
 }
 
 func (wr *writeContext) reset() {
 	clear(wr.tss)
 	wr.tss = wr.tss[:0]
 
+	clear(wr.mms)
+	wr.mms = wr.mms[:0]
+
 	wr.baseLabels = resetLabels(wr.baseLabels)
 	wr.pointLabels = resetLabels(wr.pointLabels)
 
 	wr.labelsPool = resetLabels(wr.labelsPool)
 	wr.samplesPool = wr.samplesPool[:0]
+
+	clear(wr.exemplarsPool)
+	wr.exemplarsPool = wr.exemplarsPool[:0]
 }
 
 func resetLabels(labels []prompbmarshal.Label) []prompbmarshal.Label {
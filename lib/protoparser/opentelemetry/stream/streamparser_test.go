@@ -13,6 +13,7 @@ import (
 	"github.com/klauspost/compress/zstd"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prommetadata"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/opentelemetry/pb"
 )
@@ -27,7 +28,7 @@ func TestParseStream(t *testing.T) {
 			*usePrometheusNaming = prevPromNaming
 		}()
 
-		checkSeries := func(tss []prompbmarshal.TimeSeries) error {
+		checkSeries := func(tss []prompbmarshal.TimeSeries, _ []prompbmarshal.MetricMetadata) error {
 			if len(tss) != len(tssExpected) {
 				return fmt.Errorf("not expected tss count, got: %d, want: %d", len(tss), len(tssExpected))
 			}
@@ -318,7 +319,108 @@ func TestParseStream(t *testing.T) {
 	)
 }
 
-func checkParseStream(data []byte, checkSeries func(tss []prompbmarshal.TimeSeries) error) error {
+func TestParseStreamMetadata(t *testing.T) {
+	prommetadata.SetEnabled(true)
+	defer prommetadata.SetEnabled(false)
+
+	gauge := generateGauge("my-gauge", "")
+	gauge.Description = "gauge description"
+	req := &pb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*pb.ResourceMetrics{
+			generateOTLPSamples([]*pb.Metric{
+				gauge,
+				generateSum("my-counter", "ms", true),
+				generateSum("my-updown", "", false),
+				generateHistogram("my-histogram", "", true),
+				generateSummary("my-summary", ""),
+			}),
+		},
+	}
+	mmsExpected := []prompbmarshal.MetricMetadata{
+		{Type: 2, MetricFamilyName: "my-gauge", Help: "gauge description"},
+		{Type: 1, MetricFamilyName: "my-counter", Unit: "ms"},
+		{Type: 2, MetricFamilyName: "my-updown"},
+		{Type: 3, MetricFamilyName: "my-histogram"},
+		{Type: 5, MetricFamilyName: "my-summary"},
+	}
+	err := ParseStream(bytes.NewReader(req.MarshalProtobuf(nil)), "", nil, func(_ []prompbmarshal.TimeSeries, mms []prompbmarshal.MetricMetadata) error {
+		if !reflect.DeepEqual(mms, mmsExpected) {
+			return fmt.Errorf("unexpected metadata\ngot\n%+v\nwant\n%+v", mms, mmsExpected)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestParseStreamExemplars(t *testing.T) {
+	gauge := generateGauge("my-gauge", "")
+	gauge.Gauge.DataPoints[0].Exemplars = []*pb.Exemplar{
+		{
+			FilteredAttributes: attributesFromKV("foo", "bar"),
+			TimeUnixNano:       uint64(14 * time.Second),
+			DoubleValue:        func() *float64 { v := 1.5; return &v }(),
+			TraceID:            []byte{0x01, 0x02},
+			SpanID:             []byte{0x0a},
+		},
+	}
+	histogram := generateHistogram("my-histogram", "", true)
+	histogram.Histogram.DataPoints[0].Exemplars = []*pb.Exemplar{
+		{
+			IntValue: func() *int64 { v := int64(3); return &v }(),
+			TraceID:  []byte{0xff},
+		},
+		{
+			// Exemplars without labels must be skipped
+			IntValue: func() *int64 { v := int64(3); return &v }(),
+		},
+	}
+	req := &pb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*pb.ResourceMetrics{
+			generateOTLPSamples([]*pb.Metric{gauge, histogram}),
+		},
+	}
+	exemplarsExpected := map[string][]prompbmarshal.Exemplar{
+		`{__name__="my-gauge",job="vm",label1="value1"}`: {
+			{
+				Labels: []prompbmarshal.Label{
+					{Name: "foo", Value: "bar"},
+					{Name: "trace_id", Value: "0102"},
+					{Name: "span_id", Value: "0a"},
+				},
+				Value:     1.5,
+				Timestamp: 14000,
+			},
+		},
+		`{__name__="my-histogram_bucket",job="vm",label2="value2",le="5"}`: {
+			{
+				Labels: []prompbmarshal.Label{
+					{Name: "trace_id", Value: "ff"},
+				},
+				Value:     3,
+				Timestamp: 30000,
+			},
+		},
+	}
+	err := ParseStream(bytes.NewReader(req.MarshalProtobuf(nil)), "", nil, func(tss []prompbmarshal.TimeSeries, _ []prompbmarshal.MetricMetadata) error {
+		exemplars := make(map[string][]prompbmarshal.Exemplar)
+		for _, ts := range tss {
+			if len(ts.Exemplars) > 0 {
+				exemplars[prompbmarshal.LabelsToString(ts.Labels)] = ts.Exemplars
+			}
+		}
+		if !reflect.DeepEqual(exemplars, exemplarsExpected) {
+			return fmt.Errorf("unexpected exemplars\ngot\n%+v\nwant\n%+v", exemplars, exemplarsExpected)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func checkParseStream(data []byte, checkSeries func(tss []prompbmarshal.TimeSeries, mms []prompbmarshal.MetricMetadata) error) error {
 	// Verify parsing without compression
 	if err := ParseStream(bytes.NewBuffer(data), "", nil, checkSeries); err != nil {
 		return fmt.Errorf("error when parsing data: %w", err)
@@ -0,0 +1,120 @@
+package prometheus
+
+import (
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+// MetadataRows contains parsed Prometheus metadata rows.
+type MetadataRows struct {
+	Rows []MetadataRow
+}
+
+// MetadataRow is metadata for a single metric family obtained from `# HELP`, `# TYPE` and `# UNIT` lines.
+type MetadataRow struct {
+	Metric string
+	Type   prompb.MetricType
+	Help   string
+	Unit   string
+}
+
+// Reset resets mrs.
+func (mrs *MetadataRows) Reset() {
+	clear(mrs.Rows)
+	mrs.Rows = mrs.Rows[:0]
+}
+
+// Unmarshal unmarshals metadata rows from `# HELP`, `# TYPE` and `# UNIT` lines in Prometheus exposition text s.
+//
+// Subsequent metadata lines for the same metric family are merged into a single row.
+//
+// See https://github.com/prometheus/docs/blob/master/content/docs/instrumenting/exposition_formats.md#comments-help-text-and-type-information
+//
+// s shouldn't be modified while mrs is in use.
+func (mrs *MetadataRows) Unmarshal(s string) {
+	mrs.Rows = unmarshalMetadataRows(mrs.Rows[:0], s)
+}
+
+func unmarshalMetadataRows(dst []MetadataRow, s string) []MetadataRow {
+	for len(s) > 0 {
+		n := strings.IndexByte(s, '\n')
+		if n < 0 {
+			// The last line.
+			return unmarshalMetadataRow(dst, s)
+		}
+		dst = unmarshalMetadataRow(dst, s[:n])
+		s = s[n+1:]
+	}
+	return dst
+}
+
+func unmarshalMetadataRow(dst []MetadataRow, s string) []MetadataRow {
+	if len(s) > 0 && s[len(s)-1] == '\r' {
+		s = s[:len(s)-1]
+	}
+	s = skipLeadingWhitespace(s)
+	if len(s) == 0 || s[0] != '#' {
+		return dst
+	}
+	s = skipLeadingWhitespace(s[1:])
+	n := nextWhitespace(s)
+	if n < 0 {
+		return dst
+	}
+	kind := s[:n]
+	if kind != "HELP" && kind != "TYPE" && kind != "UNIT" {
+		// Ordinary comment
+		return dst
+	}
+	s = skipLeadingWhitespace(s[n+1:])
+	metric := s
+	value := ""
+	if n := nextWhitespace(s); n >= 0 {
+		metric = s[:n]
+		value = skipLeadingWhitespace(s[n+1:])
+	}
+	if metric == "" {
+		return dst
+	}
+
+	var r *MetadataRow
+	if len(dst) > 0 && dst[len(dst)-1].Metric == metric {
+		r = &dst[len(dst)-1]
+	} else {
+		dst = append(dst, MetadataRow{
+			Metric: metric,
+		})
+		r = &dst[len(dst)-1]
+	}
+	switch kind {
+	case "HELP":
+		r.Help = unescapeValue(value)
+	case "TYPE":
+		r.Type = parseMetricType(skipTrailingWhitespace(value))
+	case "UNIT":
+		r.Unit = skipTrailingWhitespace(value)
+	}
+	return dst
+}
+
+func parseMetricType(s string) prompb.MetricType {
+	switch s {
+	case "counter":
+		return prompb.MetricTypeCounter
+	case "gauge":
+		return prompb.MetricTypeGauge
+	case "histogram":
+		return prompb.MetricTypeHistogram
+	case "gaugehistogram":
+		return prompb.MetricTypeGaugeHistogram
+	case "summary":
+		return prompb.MetricTypeSummary
+	case "info":
+		return prompb.MetricTypeInfo
+	case "stateset":
+		return prompb.MetricTypeStateset
+	default:
+		return prompb.MetricTypeUnknown
+	}
+}
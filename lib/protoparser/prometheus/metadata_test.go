@@ -0,0 +1,79 @@
+package prometheus
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+func TestMetadataRowsUnmarshal(t *testing.T) {
+	f := func(s string, rowsExpected []MetadataRow) {
+		t.Helper()
+		var mrs MetadataRows
+		mrs.Unmarshal(s)
+		if !reflect.DeepEqual(mrs.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows\ngot\n%+v\nwant\n%+v", mrs.Rows, rowsExpected)
+		}
+
+		// Try unmarshaling again
+		mrs.Unmarshal(s)
+		if !reflect.DeepEqual(mrs.Rows, rowsExpected) {
+			t.Fatalf("unexpected rows on the second unmarshal\ngot\n%+v\nwant\n%+v", mrs.Rows, rowsExpected)
+		}
+
+		mrs.Reset()
+		if len(mrs.Rows) != 0 {
+			t.Fatalf("non-empty rows after reset: %+v", mrs.Rows)
+		}
+	}
+
+	// Empty input
+	f("", nil)
+
+	// No metadata
+	f("foo 123\n# some comment\nbar{a=\"b\"} 3", nil)
+
+	// Incomplete metadata lines
+	f("# HELP\n# TYPE \n", nil)
+
+	// Metadata for multiple metric families
+	f(`# HELP foo_total The number of foos.
+# TYPE foo_total counter
+foo_total 1
+# TYPE bar gauge
+# UNIT bar seconds
+# HELP bar Escaped \\ help\nwith newline
+bar 2
+#	TYPE baz	untyped
+# HELP qwe
+`, []MetadataRow{
+		{
+			Metric: "foo_total",
+			Type:   prompb.MetricTypeCounter,
+			Help:   "The number of foos.",
+		},
+		{
+			Metric: "bar",
+			Type:   prompb.MetricTypeGauge,
+			Help:   "Escaped \\ help\nwith newline",
+			Unit:   "seconds",
+		},
+		{
+			Metric: "baz",
+			Type:   prompb.MetricTypeUnknown,
+		},
+		{
+			Metric: "qwe",
+		},
+	})
+
+	// All the known metric types
+	f("# TYPE a histogram\n# TYPE b gaugehistogram\n# TYPE c summary\n# TYPE d info\n# TYPE e stateset\r\n", []MetadataRow{
+		{Metric: "a", Type: prompb.MetricTypeHistogram},
+		{Metric: "b", Type: prompb.MetricTypeGaugeHistogram},
+		{Metric: "c", Type: prompb.MetricTypeSummary},
+		{Metric: "d", Type: prompb.MetricTypeInfo},
+		{Metric: "e", Type: prompb.MetricTypeStateset},
+	})
+}
@@ -0,0 +1,62 @@
+package prometheus
+
+import (
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+)
+
+// OpenMetricsContentType is the content type for OpenMetrics text exposition format.
+//
+// See https://github.com/prometheus/OpenMetrics/blob/main/specification/OpenMetrics.md
+const OpenMetricsContentType = "application/openmetrics-text"
+
+// AppendTextFromOpenMetrics appends metrics from src in OpenMetrics text format to dst in Prometheus text exposition format.
+//
+// The following OpenMetrics-specific handling is performed:
+//
+//   - Everything after the `# EOF` line is ignored.
+//   - `<name>_created` series for counter, histogram, gaugehistogram and summary families are dropped,
+//     since they contain creation timestamps instead of samples. This is consistent with Prometheus.
+//
+// Exemplars and timestamps in seconds are preserved as is, since they are supported by Rows.Unmarshal.
+func AppendTextFromOpenMetrics(dst, src []byte) []byte {
+	var createdNames map[string]struct{}
+	s := bytesutil.ToUnsafeString(src)
+	for len(s) > 0 {
+		line := s
+		n := strings.IndexByte(s, '\n')
+		if n >= 0 {
+			line = s[:n+1]
+			s = s[n+1:]
+		} else {
+			s = ""
+		}
+		trimmed := skipTrailingWhitespace(strings.TrimRight(line, "\r\n"))
+		if trimmed == "# EOF" {
+			break
+		}
+		if strings.HasPrefix(trimmed, "# TYPE ") {
+			tail := strings.TrimPrefix(trimmed, "# TYPE ")
+			if n := strings.IndexByte(tail, ' '); n > 0 {
+				switch skipLeadingWhitespace(tail[n+1:]) {
+				case "counter", "histogram", "gaugehistogram", "summary":
+					if createdNames == nil {
+						createdNames = make(map[string]struct{})
+					}
+					createdNames[tail[:n]+"_created"] = struct{}{}
+				}
+			}
+		} else if len(createdNames) > 0 && !strings.HasPrefix(trimmed, "#") {
+			metricName := trimmed
+			if n := strings.IndexAny(metricName, "{ \t"); n >= 0 {
+				metricName = metricName[:n]
+			}
+			if _, ok := createdNames[metricName]; ok {
+				continue
+			}
+		}
+		dst = append(dst, line...)
+	}
+	return dst
+}
@@ -0,0 +1,44 @@
+package prometheus
+
+import (
+	"testing"
+)
+
+func TestAppendTextFromOpenMetrics(t *testing.T) {
+	f := func(s, resultExpected string) {
+		t.Helper()
+		result := AppendTextFromOpenMetrics(nil, []byte(s))
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f("", "")
+	f("foo 1\n# EOF\n", "foo 1\n")
+	f("foo 1\n# EOF\nbar 2\n", "foo 1\n")
+	f("foo 1\r\n# EOF\r\n", "foo 1\r\n")
+
+	// _created series are dropped for counters, histograms and summaries
+	f(`# TYPE foo counter
+# HELP foo Foo.
+foo_total{a="b"} 17.0 1520879607.789 # {trace_id="KOO5S4vxi0o"} 0.67
+foo_created{a="b"} 1520430000.123
+# TYPE bar histogram
+bar_bucket{le="+Inf"} 17
+bar_count 17
+bar_sum 324789.3
+bar_created 1520430000.123
+# TYPE baz gauge
+baz_created 123
+# EOF
+`, `# TYPE foo counter
+# HELP foo Foo.
+foo_total{a="b"} 17.0 1520879607.789 # {trace_id="KOO5S4vxi0o"} 0.67
+# TYPE bar histogram
+bar_bucket{le="+Inf"} 17
+bar_count 17
+bar_sum 324789.3
+# TYPE baz gauge
+baz_created 123
+`)
+}
@@ -60,18 +60,42 @@ type Row struct {
 	Tags      []Tag
 	Value     float64
 	Timestamp int64
+
+	// Exemplar is an optional OpenMetrics exemplar for the row.
+	//
+	// It is empty if the row has no exemplar.
+	Exemplar Exemplar
+}
+
+// Exemplar is an OpenMetrics exemplar.
+//
+// See https://github.com/OpenObservability/OpenMetrics/blob/main/specification/OpenMetrics.md#exemplars
+type Exemplar struct {
+	// Tags contains exemplar labels such as trace_id.
+	Tags []Tag
+
+	Value float64
+
+	// Timestamp is the exemplar timestamp in milliseconds. It is 0 if the exemplar has no timestamp.
+	Timestamp int64
+}
+
+// IsEmpty returns true if e contains no exemplar.
+func (e *Exemplar) IsEmpty() bool {
+	return len(e.Tags) == 0
 }
 
 func (r *Row) reset() {
 	*r = Row{}
 }
 
-func skipTrailingComment(s string) string {
+// splitTrailingComment splits s into the row part and the trailing comment part after `#`.
+func splitTrailingComment(s string) (string, string) {
 	n := strings.IndexByte(s, '#')
 	if n < 0 {
-		return s
+		return s, ""
 	}
-	return s[:n]
+	return s[:n], s[n+1:]
 }
 
 func skipLeadingWhitespace(s string) string {
@@ -108,6 +132,10 @@ func (r *Row) unmarshal(s string, tagsPool []Tag, noEscapes bool) ([]Tag, error)
 	r.reset()
 	s = skipLeadingWhitespace(s)
 	n := strings.IndexByte(s, '{')
+	if n >= 0 && (strings.Contains(s[:n], " #") || strings.Contains(s[:n], "\t#")) {
+		// The '{' belongs to the trailing comment such as exemplar - `foo 123 # {trace_id="abc"} 1`
+		n = -1
+	}
 	if n >= 0 {
 		// Tags found. Parse them.
 		r.Metric = skipTrailingWhitespace(s[:n])
@@ -137,10 +165,13 @@ func (r *Row) unmarshal(s string, tagsPool []Tag, noEscapes bool) ([]Tag, error)
 		return tagsPool, fmt.Errorf("metric cannot be empty")
 	}
 	s = skipLeadingWhitespace(s)
-	s = skipTrailingComment(s)
+	s, comment := splitTrailingComment(s)
 	if len(s) == 0 {
 		return tagsPool, fmt.Errorf("value cannot be empty")
 	}
+	if len(comment) > 0 {
+		tagsPool = r.unmarshalExemplar(tagsPool, comment, noEscapes)
+	}
 	n = nextWhitespace(s)
 	if n < 0 {
 		// There is no timestamp.
@@ -179,6 +210,50 @@ func (r *Row) unmarshal(s string, tagsPool []Tag, noEscapes bool) ([]Tag, error)
 	return tagsPool, nil
 }
 
+// unmarshalExemplar unmarshals OpenMetrics exemplar from s in the form `{labels} value [timestamp]` into r.Exemplar.
+//
+// Invalid exemplars are silently ignored, since they are put into comments
+// and they mustn't break parsing of the row.
+func (r *Row) unmarshalExemplar(tagsPool []Tag, s string, noEscapes bool) []Tag {
+	s = skipLeadingWhitespace(s)
+	if len(s) == 0 || s[0] != '{' {
+		// Ordinary comment
+		return tagsPool
+	}
+	tagsStart := len(tagsPool)
+	var tmp Row
+	s, tagsPool, err := tmp.unmarshalTags(tagsPool, s[1:], noEscapes)
+	if err != nil || tmp.Metric != "" || len(tagsPool) == tagsStart {
+		return tagsPool[:tagsStart]
+	}
+	s = skipTrailingWhitespace(skipLeadingWhitespace(s))
+	valueStr := s
+	timestampStr := ""
+	if n := nextWhitespace(s); n >= 0 {
+		valueStr = s[:n]
+		timestampStr = skipLeadingWhitespace(s[n+1:])
+	}
+	v, err := fastfloat.Parse(valueStr)
+	if err != nil {
+		return tagsPool[:tagsStart]
+	}
+	var ts float64
+	if len(timestampStr) > 0 {
+		// Exemplar timestamps are always in Unix seconds according to OpenMetrics.
+		ts, err = fastfloat.Parse(timestampStr)
+		if err != nil {
+			return tagsPool[:tagsStart]
+		}
+	}
+	tags := tagsPool[tagsStart:]
+	r.Exemplar = Exemplar{
+		Tags:      tags[:len(tags):len(tags)],
+		Value:     v,
+		Timestamp: int64(ts * 1000),
+	}
+	return tagsPool
+}
+
 var rowsReadScrape = metrics.NewCounter(`vm_protoparser_rows_read_total{type="promscrape"}`)
 
 func unmarshalRows(dst []Row, s string, tagsPool []Tag, noEscapes bool, errLogger func(s string)) ([]Row, []Tag) {
@@ -395,6 +395,16 @@ cassandra_token_ownership_ratio 78.9`, &Rows{
 					},
 				},
 				Value: 17,
+				Exemplar: Exemplar{
+					Tags: []Tag{
+						{
+							Key:   "trace_id",
+							Value: "oHg5SJ#YRHA0",
+						},
+					},
+					Value:     9.8,
+					Timestamp: 1520879607789,
+				},
 			},
 			{
 				Metric:    "abc",
@@ -408,6 +418,49 @@ cassandra_token_ownership_ratio 78.9`, &Rows{
 		},
 	})
 
+	// Exemplars without timestamp and invalid exemplars
+	f(`foo_total 5 # {trace_id="abc",span_id="def"} 1
+	   bar 1 # {} 2
+	   baz 2 # {trace_id="x"} qwe
+	   qux 3 # {trace_id="x"
+	   quux 4 # {"metric"} 5`, &Rows{
+		Rows: []Row{
+			{
+				Metric: "foo_total",
+				Value:  5,
+				Exemplar: Exemplar{
+					Tags: []Tag{
+						{
+							Key:   "trace_id",
+							Value: "abc",
+						},
+						{
+							Key:   "span_id",
+							Value: "def",
+						},
+					},
+					Value: 1,
+				},
+			},
+			{
+				Metric: "bar",
+				Value:  1,
+			},
+			{
+				Metric: "baz",
+				Value:  2,
+			},
+			{
+				Metric: "qux",
+				Value:  3,
+			},
+			{
+				Metric: "quux",
+				Value:  4,
+			},
+		},
+	})
+
 	// "Infinity" word - this has been added in OpenMetrics.
 	// See https://github.com/OpenObservability/OpenMetrics/blob/master/OpenMetrics.md
 	// Checks for https://github.com/VictoriaMetrics/VictoriaMetrics/issues/924
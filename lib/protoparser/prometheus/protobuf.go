@@ -0,0 +1,764 @@
+package prometheus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/VictoriaMetrics/easyproto"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+// ProtobufContentType is the content type for Prometheus protobuf exposition format.
+//
+// See https://prometheus.io/docs/instrumenting/exposition_formats/#protobuf-format
+const ProtobufContentType = "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited"
+
+// AppendTextFromProtobuf appends metrics from src in Prometheus protobuf exposition format to dst in Prometheus text exposition format.
+//
+// src must contain length-delimited io.prometheus.client.MetricFamily messages.
+//
+// Native histograms are converted into `<name>_bucket{vmrange="..."}` buckets plus `<name>_count` and `<name>_sum`,
+// while exemplars are appended to the corresponding lines in OpenMetrics format.
+func AppendTextFromProtobuf(dst, src []byte) ([]byte, error) {
+	var mf metricFamily
+	for len(src) > 0 {
+		n, nSize := binary.Uvarint(src)
+		if nSize <= 0 {
+			return dst, fmt.Errorf("cannot read MetricFamily message length")
+		}
+		src = src[nSize:]
+		if uint64(len(src)) < n {
+			return dst, fmt.Errorf("too short MetricFamily message; got %d bytes; want %d bytes", len(src), n)
+		}
+		if err := mf.unmarshalProtobuf(src[:n]); err != nil {
+			return dst, fmt.Errorf("cannot unmarshal MetricFamily: %w", err)
+		}
+		src = src[n:]
+		var err error
+		dst, err = mf.appendText(dst)
+		if err != nil {
+			return dst, fmt.Errorf("cannot convert metric family %q: %w", mf.name, err)
+		}
+	}
+	return dst, nil
+}
+
+// metricType is io.prometheus.client.MetricType
+type metricType int32
+
+const (
+	metricTypeCounter        metricType = 0
+	metricTypeGauge          metricType = 1
+	metricTypeSummary        metricType = 2
+	metricTypeUntyped        metricType = 3
+	metricTypeHistogram      metricType = 4
+	metricTypeGaugeHistogram metricType = 5
+)
+
+func (mt metricType) String() string {
+	switch mt {
+	case metricTypeCounter:
+		return "counter"
+	case metricTypeGauge:
+		return "gauge"
+	case metricTypeSummary:
+		return "summary"
+	case metricTypeHistogram:
+		return "histogram"
+	case metricTypeGaugeHistogram:
+		return "gaugehistogram"
+	default:
+		return "untyped"
+	}
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	typ     metricType
+	metrics []protobufMetric
+}
+
+type protobufMetric struct {
+	labels    []Tag
+	value     float64
+	exemplar  Exemplar
+	timestamp int64
+
+	// summary and histogram fields
+	count     float64
+	sum       float64
+	quantiles []quantile
+	buckets   []bucket
+
+	// hasNativeHistogram is set to true if h contains native histogram.
+	hasNativeHistogram bool
+	h                  prompb.Histogram
+	exemplars          []Exemplar
+}
+
+type quantile struct {
+	quantile float64
+	value    float64
+}
+
+type bucket struct {
+	upperBound      float64
+	cumulativeCount float64
+	exemplar        Exemplar
+}
+
+func (mf *metricFamily) reset() {
+	mf.name = ""
+	mf.help = ""
+	mf.typ = metricTypeUntyped
+	clear(mf.metrics)
+	mf.metrics = mf.metrics[:0]
+}
+
+func (mf *metricFamily) unmarshalProtobuf(src []byte) (err error) {
+	// message MetricFamily {
+	//   string     name   = 1;
+	//   string     help   = 2;
+	//   MetricType type   = 3;
+	//   repeated   Metric metric = 4;
+	//   string     unit   = 5;
+	// }
+	mf.reset()
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		var ok bool
+		switch fc.FieldNum {
+		case 1:
+			mf.name, ok = fc.String()
+		case 2:
+			mf.help, ok = fc.String()
+		case 3:
+			var typ int32
+			typ, ok = fc.Int32()
+			mf.typ = metricType(typ)
+		case 4:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				mf.metrics = append(mf.metrics, protobufMetric{})
+				if err := mf.metrics[len(mf.metrics)-1].unmarshalProtobuf(data); err != nil {
+					return fmt.Errorf("cannot unmarshal Metric: %w", err)
+				}
+			}
+		default:
+			ok = true
+		}
+		if !ok {
+			return fmt.Errorf("cannot read field #%d", fc.FieldNum)
+		}
+	}
+	if mf.name == "" {
+		return fmt.Errorf("missing metric family name")
+	}
+	return nil
+}
+
+func (m *protobufMetric) unmarshalProtobuf(src []byte) (err error) {
+	// message Metric {
+	//   repeated LabelPair label        = 1;
+	//   Gauge              gauge        = 2;
+	//   Counter            counter      = 3;
+	//   Summary            summary      = 4;
+	//   Untyped            untyped      = 5;
+	//   Histogram          histogram    = 7;
+	//   int64              timestamp_ms = 6;
+	// }
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 6:
+			ts, ok := fc.Int64()
+			if !ok {
+				return fmt.Errorf("cannot read timestamp_ms")
+			}
+			m.timestamp = ts
+			continue
+		case 1, 2, 3, 4, 5, 7:
+		default:
+			continue
+		}
+		data, ok := fc.MessageData()
+		if !ok {
+			return fmt.Errorf("cannot read field #%d", fc.FieldNum)
+		}
+		switch fc.FieldNum {
+		case 1:
+			m.labels, err = appendLabelPair(m.labels, data)
+		case 2, 5:
+			// message Gauge {
+			//   double value = 1;
+			// }
+			// message Untyped {
+			//   double value = 1;
+			// }
+			m.value, err = unmarshalValue(data)
+		case 3:
+			err = m.unmarshalCounter(data)
+		case 4:
+			err = m.unmarshalSummary(data)
+		case 7:
+			err = m.unmarshalHistogram(data)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalValue(src []byte) (v float64, err error) {
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return v, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		if fc.FieldNum == 1 {
+			var ok bool
+			v, ok = fc.Double()
+			if !ok {
+				return v, fmt.Errorf("cannot read value")
+			}
+		}
+	}
+	return v, nil
+}
+
+func (m *protobufMetric) unmarshalCounter(src []byte) (err error) {
+	// message Counter {
+	//   double                    value             = 1;
+	//   Exemplar                  exemplar          = 2;
+	//   google.protobuf.Timestamp created_timestamp = 3;
+	// }
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			v, ok := fc.Double()
+			if !ok {
+				return fmt.Errorf("cannot read counter value")
+			}
+			m.value = v
+		case 2:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read counter exemplar")
+			}
+			if err := m.exemplar.unmarshalProtobuf(data); err != nil {
+				return fmt.Errorf("cannot unmarshal counter exemplar: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *protobufMetric) unmarshalSummary(src []byte) (err error) {
+	// message Summary {
+	//   uint64   sample_count = 1;
+	//   double   sample_sum   = 2;
+	//   repeated Quantile quantile = 3;
+	// }
+	//
+	// message Quantile {
+	//   double quantile = 1;
+	//   double value    = 2;
+	// }
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		var ok bool
+		switch fc.FieldNum {
+		case 1:
+			var n uint64
+			n, ok = fc.Uint64()
+			m.count = float64(n)
+		case 2:
+			m.sum, ok = fc.Double()
+		case 3:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				var q quantile
+				if err := q.unmarshalProtobuf(data); err != nil {
+					return fmt.Errorf("cannot unmarshal summary quantile: %w", err)
+				}
+				m.quantiles = append(m.quantiles, q)
+			}
+		default:
+			ok = true
+		}
+		if !ok {
+			return fmt.Errorf("cannot read summary field #%d", fc.FieldNum)
+		}
+	}
+	return nil
+}
+
+func (q *quantile) unmarshalProtobuf(src []byte) (err error) {
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		var ok bool
+		switch fc.FieldNum {
+		case 1:
+			q.quantile, ok = fc.Double()
+		case 2:
+			q.value, ok = fc.Double()
+		default:
+			ok = true
+		}
+		if !ok {
+			return fmt.Errorf("cannot read quantile field #%d", fc.FieldNum)
+		}
+	}
+	return nil
+}
+
+func (m *protobufMetric) unmarshalHistogram(src []byte) (err error) {
+	// message Histogram {
+	//   uint64   sample_count       = 1;
+	//   double   sample_count_float = 4;
+	//   double   sample_sum         = 2;
+	//   repeated Bucket bucket      = 3;
+	//
+	//   sint32   schema             = 5;
+	//   double   zero_threshold     = 6;
+	//   uint64   zero_count         = 7;
+	//   double   zero_count_float   = 8;
+	//   repeated BucketSpan negative_span  = 9;
+	//   repeated sint64     negative_delta = 10;
+	//   repeated double     negative_count = 11;
+	//   repeated BucketSpan positive_span  = 12;
+	//   repeated sint64     positive_delta = 13;
+	//   repeated double     positive_count = 14;
+	//   repeated Exemplar   exemplars      = 16;
+	// }
+	h := &m.h
+	h.Reset()
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		var ok bool
+		switch fc.FieldNum {
+		case 1:
+			var n uint64
+			n, ok = fc.Uint64()
+			m.count = float64(n)
+		case 4:
+			m.count, ok = fc.Double()
+		case 2:
+			m.sum, ok = fc.Double()
+		case 3:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				var b bucket
+				if err := b.unmarshalProtobuf(data); err != nil {
+					return fmt.Errorf("cannot unmarshal histogram bucket: %w", err)
+				}
+				m.buckets = append(m.buckets, b)
+			}
+		case 5:
+			h.Schema, ok = fc.Sint32()
+			m.hasNativeHistogram = true
+		case 6:
+			h.ZeroThreshold, ok = fc.Double()
+			m.hasNativeHistogram = true
+		case 7:
+			var n uint64
+			n, ok = fc.Uint64()
+			h.ZeroCount = float64(n)
+			m.hasNativeHistogram = true
+		case 8:
+			h.ZeroCount, ok = fc.Double()
+			m.hasNativeHistogram = true
+		case 9, 12:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				var bs prompb.BucketSpan
+				if err := unmarshalBucketSpan(&bs, data); err != nil {
+					return fmt.Errorf("cannot unmarshal histogram bucket span: %w", err)
+				}
+				if fc.FieldNum == 9 {
+					h.NegativeSpans = append(h.NegativeSpans, bs)
+				} else {
+					h.PositiveSpans = append(h.PositiveSpans, bs)
+				}
+			}
+			m.hasNativeHistogram = true
+		case 10:
+			h.NegativeDeltas, ok = fc.UnpackSint64s(h.NegativeDeltas)
+		case 11:
+			h.NegativeCounts, ok = fc.UnpackDoubles(h.NegativeCounts)
+		case 13:
+			h.PositiveDeltas, ok = fc.UnpackSint64s(h.PositiveDeltas)
+		case 14:
+			h.PositiveCounts, ok = fc.UnpackDoubles(h.PositiveCounts)
+		case 16:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				var e Exemplar
+				if err := e.unmarshalProtobuf(data); err != nil {
+					return fmt.Errorf("cannot unmarshal histogram exemplar: %w", err)
+				}
+				m.exemplars = append(m.exemplars, e)
+			}
+		default:
+			ok = true
+		}
+		if !ok {
+			return fmt.Errorf("cannot read histogram field #%d", fc.FieldNum)
+		}
+	}
+	h.Count = m.count
+	h.Sum = m.sum
+	return nil
+}
+
+func (b *bucket) unmarshalProtobuf(src []byte) (err error) {
+	// message Bucket {
+	//   uint64   cumulative_count       = 1;
+	//   double   cumulative_count_float = 4;
+	//   double   upper_bound            = 2;
+	//   Exemplar exemplar               = 3;
+	// }
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		var ok bool
+		switch fc.FieldNum {
+		case 1:
+			var n uint64
+			n, ok = fc.Uint64()
+			b.cumulativeCount = float64(n)
+		case 4:
+			b.cumulativeCount, ok = fc.Double()
+		case 2:
+			b.upperBound, ok = fc.Double()
+		case 3:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				if err := b.exemplar.unmarshalProtobuf(data); err != nil {
+					return fmt.Errorf("cannot unmarshal bucket exemplar: %w", err)
+				}
+			}
+		default:
+			ok = true
+		}
+		if !ok {
+			return fmt.Errorf("cannot read bucket field #%d", fc.FieldNum)
+		}
+	}
+	return nil
+}
+
+func unmarshalBucketSpan(bs *prompb.BucketSpan, src []byte) (err error) {
+	// message BucketSpan {
+	//   sint32 offset = 1;
+	//   uint32 length = 2;
+	// }
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		var ok bool
+		switch fc.FieldNum {
+		case 1:
+			bs.Offset, ok = fc.Sint32()
+		case 2:
+			bs.Length, ok = fc.Uint32()
+		default:
+			ok = true
+		}
+		if !ok {
+			return fmt.Errorf("cannot read bucket span field #%d", fc.FieldNum)
+		}
+	}
+	return nil
+}
+
+func (e *Exemplar) unmarshalProtobuf(src []byte) (err error) {
+	// message Exemplar {
+	//   repeated LabelPair        label     = 1;
+	//   double                    value     = 2;
+	//   google.protobuf.Timestamp timestamp = 3;
+	// }
+	//
+	// message Timestamp {
+	//   int64 seconds = 1;
+	//   int32 nanos   = 2;
+	// }
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		var ok bool
+		switch fc.FieldNum {
+		case 1:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				e.Tags, err = appendLabelPair(e.Tags, data)
+				if err != nil {
+					return err
+				}
+			}
+		case 2:
+			e.Value, ok = fc.Double()
+		case 3:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				e.Timestamp, err = unmarshalTimestamp(data)
+				if err != nil {
+					return err
+				}
+			}
+		default:
+			ok = true
+		}
+		if !ok {
+			return fmt.Errorf("cannot read exemplar field #%d", fc.FieldNum)
+		}
+	}
+	return nil
+}
+
+func unmarshalTimestamp(src []byte) (int64, error) {
+	var secs int64
+	var nsecs int32
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return 0, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		var ok bool
+		switch fc.FieldNum {
+		case 1:
+			secs, ok = fc.Int64()
+		case 2:
+			nsecs, ok = fc.Int32()
+		default:
+			ok = true
+		}
+		if !ok {
+			return 0, fmt.Errorf("cannot read timestamp field #%d", fc.FieldNum)
+		}
+	}
+	return secs*1000 + int64(nsecs)/1e6, nil
+}
+
+func appendLabelPair(dst []Tag, src []byte) ([]Tag, error) {
+	// message LabelPair {
+	//   string name  = 1;
+	//   string value = 2;
+	// }
+	var tag Tag
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return dst, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		var ok bool
+		switch fc.FieldNum {
+		case 1:
+			tag.Key, ok = fc.String()
+		case 2:
+			tag.Value, ok = fc.String()
+		default:
+			ok = true
+		}
+		if !ok {
+			return dst, fmt.Errorf("cannot read label field #%d", fc.FieldNum)
+		}
+	}
+	return append(dst, tag), nil
+}
+
+func (mf *metricFamily) appendText(dst []byte) ([]byte, error) {
+	if mf.help != "" {
+		dst = append(dst, "# HELP "...)
+		dst = append(dst, mf.name...)
+		dst = append(dst, ' ')
+		dst = appendEscapedHelp(dst, mf.help)
+		dst = append(dst, '\n')
+	}
+	dst = append(dst, "# TYPE "...)
+	dst = append(dst, mf.name...)
+	dst = append(dst, ' ')
+	dst = append(dst, mf.typ.String()...)
+	dst = append(dst, '\n')
+
+	for i := range mf.metrics {
+		m := &mf.metrics[i]
+		switch mf.typ {
+		case metricTypeSummary:
+			for _, q := range m.quantiles {
+				dst = appendLine(dst, mf.name, "", m.labels, "quantile", formatFloat(q.quantile), q.value, m.timestamp, nil)
+			}
+			dst = appendLine(dst, mf.name, "_sum", m.labels, "", "", m.sum, m.timestamp, nil)
+			dst = appendLine(dst, mf.name, "_count", m.labels, "", "", m.count, m.timestamp, nil)
+		case metricTypeHistogram, metricTypeGaugeHistogram:
+			var err error
+			dst, err = m.appendHistogramText(dst, mf.name)
+			if err != nil {
+				return dst, err
+			}
+		default:
+			dst = appendLine(dst, mf.name, "", m.labels, "", "", m.value, m.timestamp, &m.exemplar)
+		}
+	}
+	return dst, nil
+}
+
+func (m *protobufMetric) appendHistogramText(dst []byte, name string) ([]byte, error) {
+	dst = appendLine(dst, name, "_sum", m.labels, "", "", m.sum, m.timestamp, nil)
+	dst = appendLine(dst, name, "_count", m.labels, "", "", m.count, m.timestamp, nil)
+	if m.hasNativeHistogram {
+		// Native histograms are stored as `vmrange` buckets in the same way as native histograms received via Prometheus remote write.
+		var err error
+		visitErr := m.h.VisitBuckets(func(lower, upper, count float64) {
+			var e *Exemplar
+			for i := range m.exemplars {
+				if v := m.exemplars[i].Value; v > lower && v <= upper {
+					e = &m.exemplars[i]
+					break
+				}
+			}
+			dst = appendLine(dst, name, "_bucket", m.labels, "vmrange", prompb.FormatVMRange(lower, upper), count, m.timestamp, e)
+		})
+		if visitErr != nil {
+			err = fmt.Errorf("cannot convert native histogram: %w", visitErr)
+		}
+		return dst, err
+	}
+	hasInf := false
+	for i := range m.buckets {
+		b := &m.buckets[i]
+		if math.IsInf(b.upperBound, 1) {
+			hasInf = true
+		}
+		dst = appendLine(dst, name, "_bucket", m.labels, "le", formatFloat(b.upperBound), b.cumulativeCount, m.timestamp, &b.exemplar)
+	}
+	if !hasInf {
+		// The +Inf bucket is implicit in protobuf format.
+		dst = appendLine(dst, name, "_bucket", m.labels, "le", "+Inf", m.count, m.timestamp, nil)
+	}
+	return dst, nil
+}
+
+func appendLine(dst []byte, name, suffix string, labels []Tag, extraLabelName, extraLabelValue string, value float64, timestamp int64, e *Exemplar) []byte {
+	dst = append(dst, name...)
+	dst = append(dst, suffix...)
+	if len(labels) > 0 || extraLabelName != "" {
+		dst = append(dst, '{')
+		dst = appendTags(dst, labels)
+		if extraLabelName != "" {
+			if len(labels) > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendTag(dst, extraLabelName, extraLabelValue)
+		}
+		dst = append(dst, '}')
+	}
+	dst = append(dst, ' ')
+	dst = strconv.AppendFloat(dst, value, 'g', -1, 64)
+	if timestamp != 0 {
+		dst = append(dst, ' ')
+		dst = strconv.AppendInt(dst, timestamp, 10)
+	}
+	if e != nil && !e.IsEmpty() {
+		dst = append(dst, " # {"...)
+		dst = appendTags(dst, e.Tags)
+		dst = append(dst, "} "...)
+		dst = strconv.AppendFloat(dst, e.Value, 'g', -1, 64)
+		if e.Timestamp != 0 {
+			// Exemplar timestamps are in Unix seconds according to OpenMetrics.
+			dst = append(dst, ' ')
+			dst = strconv.AppendFloat(dst, float64(e.Timestamp)/1e3, 'f', -1, 64)
+		}
+	}
+	return append(dst, '\n')
+}
+
+func appendTags(dst []byte, tags []Tag) []byte {
+	for i, tag := range tags {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendTag(dst, tag.Key, tag.Value)
+	}
+	return dst
+}
+
+func appendTag(dst []byte, key, value string) []byte {
+	dst = append(dst, key...)
+	dst = append(dst, `="`...)
+	dst = appendEscapedValue(dst, value)
+	return append(dst, '"')
+}
+
+func appendEscapedHelp(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			dst = append(dst, `\\`...)
+		case '\n':
+			dst = append(dst, `\n`...)
+		default:
+			dst = append(dst, s[i])
+		}
+	}
+	return dst
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
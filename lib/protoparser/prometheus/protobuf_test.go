@@ -0,0 +1,175 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+func TestAppendTextFromProtobufSuccess(t *testing.T) {
+	var data []byte
+	appendMetricFamily := func(f func(mm *easyproto.MessageMarshaler)) {
+		var m easyproto.Marshaler
+		f(m.MessageMarshaler())
+		data = m.MarshalWithLen(data)
+	}
+	appendLabel := func(mm *easyproto.MessageMarshaler, fieldNum uint32, name, value string) {
+		lp := mm.AppendMessage(fieldNum)
+		lp.AppendString(1, name)
+		lp.AppendString(2, value)
+	}
+	appendExemplar := func(mm *easyproto.MessageMarshaler, fieldNum uint32, traceID string, value float64, secs int64, nsecs int32) {
+		e := mm.AppendMessage(fieldNum)
+		appendLabel(e, 1, "trace_id", traceID)
+		e.AppendDouble(2, value)
+		if secs > 0 {
+			ts := e.AppendMessage(3)
+			ts.AppendInt64(1, secs)
+			ts.AppendInt32(2, nsecs)
+		}
+	}
+
+	// counter with exemplar
+	appendMetricFamily(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "http_requests_total")
+		mm.AppendString(2, "Total requests.\nSecond line")
+		mm.AppendInt32(3, 0)
+		m := mm.AppendMessage(4)
+		appendLabel(m, 1, "code", "200")
+		appendLabel(m, 1, "path", `/foo"bar`)
+		c := m.AppendMessage(3)
+		c.AppendDouble(1, 10)
+		appendExemplar(c, 2, "abc", 1, 1700000000, 123000000)
+	})
+
+	// gauge with zero value and timestamp
+	appendMetricFamily(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "temperature")
+		mm.AppendInt32(3, 1)
+		m := mm.AppendMessage(4)
+		m.AppendMessage(2)
+		m.AppendInt64(6, 1700000000000)
+	})
+
+	// summary
+	appendMetricFamily(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "rpc_duration_seconds")
+		mm.AppendInt32(3, 2)
+		m := mm.AppendMessage(4)
+		s := m.AppendMessage(4)
+		s.AppendUint64(1, 5)
+		s.AppendDouble(2, 1.5)
+		q := s.AppendMessage(3)
+		q.AppendDouble(1, 0.5)
+		q.AppendDouble(2, 0.2)
+	})
+
+	// classic histogram
+	appendMetricFamily(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "request_duration_seconds")
+		mm.AppendInt32(3, 4)
+		m := mm.AppendMessage(4)
+		appendLabel(m, 1, "job", "foo")
+		h := m.AppendMessage(7)
+		h.AppendUint64(1, 3)
+		h.AppendDouble(2, 0.7)
+		b := h.AppendMessage(3)
+		b.AppendUint64(1, 1)
+		b.AppendDouble(2, 0.1)
+		b = h.AppendMessage(3)
+		b.AppendUint64(1, 3)
+		b.AppendDouble(2, 1)
+		appendExemplar(b, 3, "x", 0.5, 0, 0)
+	})
+
+	// native histogram
+	appendMetricFamily(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "latency_seconds")
+		mm.AppendInt32(3, 4)
+		m := mm.AppendMessage(4)
+		h := m.AppendMessage(7)
+		h.AppendUint64(1, 4)
+		h.AppendDouble(2, 3.5)
+		h.AppendSint32(5, 0)
+		h.AppendDouble(6, 0.001)
+		h.AppendUint64(7, 1)
+		span := h.AppendMessage(12)
+		span.AppendSint32(1, 0)
+		span.AppendUint32(2, 2)
+		h.AppendSint64s(13, []int64{2, -1})
+		appendExemplar(h, 16, "y", 1.5, 1700000000, 0)
+	})
+
+	result, err := AppendTextFromProtobuf(nil, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resultExpected := `# HELP http_requests_total Total requests.\nSecond line
+# TYPE http_requests_total counter
+http_requests_total{code="200",path="/foo\"bar"} 10 # {trace_id="abc"} 1 1700000000.123
+# TYPE temperature gauge
+temperature 0 1700000000000
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 0.2
+rpc_duration_seconds_sum 1.5
+rpc_duration_seconds_count 5
+# TYPE request_duration_seconds histogram
+request_duration_seconds_sum{job="foo"} 0.7
+request_duration_seconds_count{job="foo"} 3
+request_duration_seconds_bucket{job="foo",le="0.1"} 1
+request_duration_seconds_bucket{job="foo",le="1"} 3 # {trace_id="x"} 0.5
+request_duration_seconds_bucket{job="foo",le="+Inf"} 3
+# TYPE latency_seconds histogram
+latency_seconds_sum 3.5
+latency_seconds_count 4
+latency_seconds_bucket{vmrange="0.000e+00...1.000e-03"} 1
+latency_seconds_bucket{vmrange="5.000e-01...1.000e+00"} 2
+latency_seconds_bucket{vmrange="1.000e+00...2.000e+00"} 1 # {trace_id="y"} 1.5 1700000000
+`
+	if string(result) != resultExpected {
+		t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+	}
+
+	// Make sure the result can be parsed.
+	var rows Rows
+	rows.UnmarshalWithErrLogger(string(result), func(s string) {
+		t.Fatalf("unexpected error when parsing the result: %s", s)
+	})
+	if len(rows.Rows) != 15 {
+		t.Fatalf("unexpected number of rows; got %d; want 15", len(rows.Rows))
+	}
+	e := rows.Rows[0].Exemplar
+	if len(e.Tags) != 1 || e.Tags[0].Value != "abc" || e.Value != 1 || e.Timestamp != 1700000000123 {
+		t.Fatalf("unexpected exemplar: %+v", e)
+	}
+}
+
+func TestAppendTextFromProtobufFailure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+		_, err := AppendTextFromProtobuf(nil, data)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// invalid length
+	f([]byte{0xff})
+
+	// too short message
+	f([]byte{10, 1, 2})
+
+	// missing metric family name
+	var m easyproto.Marshaler
+	m.MessageMarshaler().AppendString(2, "help")
+	f(m.MarshalWithLen(nil))
+
+	// invalid native histogram schema
+	m.Reset()
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mm.AppendInt32(3, 4)
+	h := mm.AppendMessage(4).AppendMessage(7)
+	h.AppendSint32(5, 10)
+	f(m.MarshalWithLen(nil))
+}
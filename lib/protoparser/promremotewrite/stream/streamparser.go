@@ -4,12 +4,16 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"mime"
+	"net/http"
+	"strconv"
 	"sync"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding/zstd"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
 	"github.com/VictoriaMetrics/metrics"
@@ -18,10 +22,51 @@ import (
 
 var maxInsertRequestSize = flagutil.NewBytes("maxInsertRequestSize", 32*1024*1024, "The maximum size in bytes of a single Prometheus remote_write API request")
 
-// Parse parses Prometheus remote_write message from reader and calls callback for the parsed timeseries.
+// IsPromRemoteWriteV2 returns true if the given Content-Type header value corresponds to Prometheus remote write 2.0 request.
 //
-// callback shouldn't hold tss after returning.
-func Parse(r io.Reader, isVMRemoteWrite bool, callback func(tss []prompb.TimeSeries) error) error {
+// An error with http.StatusUnsupportedMediaType status code is returned for unsupported protobuf messages.
+//
+// See https://prometheus.io/docs/specs/prw/remote_write_spec_2_0/#content-type
+func IsPromRemoteWriteV2(contentType string) (bool, error) {
+	if contentType == "" {
+		return false, nil
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// Prometheus remote write 1.0 senders may send arbitrary Content-Type headers.
+		return false, nil
+	}
+	switch params["proto"] {
+	case "", "prometheus.WriteRequest":
+		return false, nil
+	case "io.prometheus.write.v2.Request":
+		return true, nil
+	default:
+		return false, &httpserver.ErrorWithStatusCode{
+			Err:        fmt.Errorf("unsupported protobuf message in Content-Type: %q; supported messages: prometheus.WriteRequest, io.prometheus.write.v2.Request", contentType),
+			StatusCode: http.StatusUnsupportedMediaType,
+		}
+	}
+}
+
+// SetWrittenHeaders sets Prometheus remote write 2.0 response headers with the number of written samples, native histogram samples and exemplars to h.
+//
+// See https://prometheus.io/docs/specs/prw/remote_write_spec_2_0/#required-written-response-headers
+func SetWrittenHeaders(h http.Header, ws *prompb.WriteRequestStats) {
+	h.Set("X-Prometheus-Remote-Write-Samples-Written", strconv.Itoa(ws.Samples))
+	h.Set("X-Prometheus-Remote-Write-Histograms-Written", strconv.Itoa(ws.Histograms))
+	h.Set("X-Prometheus-Remote-Write-Exemplars-Written", strconv.Itoa(ws.Exemplars))
+}
+
+// Parse parses Prometheus remote_write message from reader and calls callback for the parsed timeseries and metric metadata.
+//
+// If isPromRemoteWriteV2 is set, then the message is parsed as Prometheus remote write 2.0 request.
+//
+// ws passed to callback contains the number of samples, native histogram samples and exemplars in the parsed message.
+// Native histograms in tss are converted to ordinary samples.
+//
+// callback shouldn't hold tss, mms and ws after returning.
+func Parse(r io.Reader, isVMRemoteWrite, isPromRemoteWriteV2 bool, callback func(tss []prompb.TimeSeries, mms []prompb.MetricMetadata, ws *prompb.WriteRequestStats) error) error {
 	wcr := writeconcurrencylimiter.GetReader(r)
 	defer writeconcurrencylimiter.PutReader(wcr)
 	r = wcr
@@ -76,9 +121,16 @@ func Parse(r io.Reader, isVMRemoteWrite bool, callback func(tss []prompb.TimeSer
 	}
 	wr := getWriteRequest()
 	defer putWriteRequest(wr)
-	if err := wr.UnmarshalProtobuf(bb.B); err != nil {
-		unmarshalErrors.Inc()
-		return fmt.Errorf("cannot unmarshal prompb.WriteRequest with size %d bytes: %w", len(bb.B), err)
+	if isPromRemoteWriteV2 {
+		if err := wr.UnmarshalProtobufV2(bb.B); err != nil {
+			unmarshalErrors.Inc()
+			return fmt.Errorf("cannot unmarshal io.prometheus.write.v2.Request with size %d bytes: %w", len(bb.B), err)
+		}
+	} else {
+		if err := wr.UnmarshalProtobuf(bb.B); err != nil {
+			unmarshalErrors.Inc()
+			return fmt.Errorf("cannot unmarshal prompb.WriteRequest with size %d bytes: %w", len(bb.B), err)
+		}
 	}
 
 	rows := 0
@@ -88,7 +140,7 @@ func Parse(r io.Reader, isVMRemoteWrite bool, callback func(tss []prompb.TimeSer
 	}
 	rowsRead.Add(rows)
 
-	if err := callback(tss); err != nil {
+	if err := callback(tss, wr.Metadata, &wr.Stats); err != nil {
 		return fmt.Errorf("error when processing imported data: %w", err)
 	}
 	return nil
@@ -42,6 +42,9 @@ type Tracer struct {
 	// span contains span for the given Tracer. It is added via Tracer.AddJSON().
 	// If span is non-nil, then the remaining fields aren't used.
 	span *span
+
+	// hidden is set to true for tracers created via NewHidden.
+	hidden bool
 }
 
 // New creates a new instance of the tracer with the given fmt.Sprintf(format, args...) message.
@@ -61,11 +64,40 @@ func New(enabled bool, format string, args ...any) *Tracer {
 	}
 }
 
+// NewHidden creates a new instance of the tracer with the given fmt.Sprintf(format, args...) message,
+// which mustn't be exposed to clients in query responses.
+//
+// Hidden tracers are used for recording traces of queries, which didn't request tracing explicitly.
+//
+// Done or Donef must be called when the tracer should be finished.
+func NewHidden(format string, args ...any) *Tracer {
+	t := New(true, format, args...)
+	if t != nil {
+		t.hidden = true
+	}
+	return t
+}
+
 // Enabled returns true if the t is enabled.
 func (t *Tracer) Enabled() bool {
 	return t != nil
 }
 
+// Exposed returns true if t must be exposed to clients in query responses.
+//
+// It returns false for disabled tracers and for tracers created via NewHidden.
+func (t *Tracer) Exposed() bool {
+	return t != nil && !t.hidden
+}
+
+// IsDone returns true if Done or Donef has been called for t.
+func (t *Tracer) IsDone() bool {
+	if t == nil {
+		return false
+	}
+	return t.isDone.Load()
+}
+
 // NewChild adds a new child Tracer to t with the given fmt.Sprintf(format, args...) message.
 //
 // The returned child must be closed via Done or Donef calls.
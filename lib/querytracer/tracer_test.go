@@ -56,6 +56,40 @@ func TestTracerEnabled(t *testing.T) {
 	}
 }
 
+func TestTracerHidden(t *testing.T) {
+	qt := NewHidden("test")
+	if !qt.Enabled() {
+		t.Fatalf("hidden query tracer must be enabled")
+	}
+	if qt.Exposed() {
+		t.Fatalf("hidden query tracer mustn't be exposed")
+	}
+	if qt.IsDone() {
+		t.Fatalf("query tracer mustn't be done before Done call")
+	}
+	qt.Printf("foo %d", 123)
+	qt.Done()
+	if !qt.IsDone() {
+		t.Fatalf("query tracer must be done after Done call")
+	}
+	s := qt.String()
+	sExpected := `- 0ms: : test
+| - 0ms: foo 123
+`
+	if !areEqualTracesSkipDuration(s, sExpected) {
+		t.Fatalf("unexpected trace\ngot\n%s\nwant\n%s", s, sExpected)
+	}
+
+	qt = New(true, "test")
+	if !qt.Exposed() {
+		t.Fatalf("query tracer must be exposed")
+	}
+	qt = New(false, "test")
+	if qt.Exposed() || qt.IsDone() {
+		t.Fatalf("disabled query tracer mustn't be exposed or done")
+	}
+}
+
 func TestTracerMultiline(t *testing.T) {
 	qt := New(true, "line1\nline2")
 	qt.Printf("line3\nline4\n")
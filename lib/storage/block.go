@@ -149,8 +149,8 @@ func (b *Block) tooBig() bool {
 	return false
 }
 
-func (b *Block) deduplicateSamplesDuringMerge() {
-	if !isDedupEnabled() {
+func (b *Block) deduplicateSamplesDuringMerge(dedupInterval int64) {
+	if dedupInterval <= 0 {
 		// Deduplication is disabled
 		return
 	}
@@ -163,11 +163,6 @@ func (b *Block) deduplicateSamplesDuringMerge() {
 		// Nothing to dedup.
 		return
 	}
-	dedupInterval := GetDedupInterval()
-	if dedupInterval <= 0 {
-		// Deduplication is disabled.
-		return
-	}
 	srcValues := b.values[b.nextIdx:]
 	timestamps, values := deduplicateSamplesDuringMerge(srcTimestamps, srcValues, dedupInterval)
 	dedups := len(srcTimestamps) - len(timestamps)
@@ -261,6 +256,10 @@ func (b *Block) UnmarshalData() error {
 		return nil
 	}
 
+	if b.bh.RowsCount == 0 && len(b.timestampsData) == 0 && len(b.valuesData) == 0 {
+		// All the samples in the block have been deleted by tombstones. See BlockRef.MustReadBlock.
+		return nil
+	}
 	if b.bh.RowsCount <= 0 {
 		return fmt.Errorf("RowsCount must be greater than 0; got %d", b.bh.RowsCount)
 	}
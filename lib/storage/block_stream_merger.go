@@ -16,6 +16,11 @@ type blockStreamMerger struct {
 	// Blocks with smaller timestamps are removed because of retention.
 	retentionDeadline int64
 
+	// getRetentionDeadlineFunc is an optional function for obtaining per-series retention deadline.
+	//
+	// It is used instead of retentionDeadline if set.
+	getRetentionDeadlineFunc func(metricID uint64) int64
+
 	// Whether the call to NextBlock must be no-op.
 	nextBlockNoop bool
 
@@ -35,15 +40,17 @@ func (bsm *blockStreamMerger) reset() {
 	bsm.bsrHeap = bsm.bsrHeap[:0]
 
 	bsm.retentionDeadline = 0
+	bsm.getRetentionDeadlineFunc = nil
 	bsm.nextBlockNoop = false
 	bsm.err = nil
 	bsm.useSparseCache = false
 }
 
 // Init initializes bsm with the given bsrs.
-func (bsm *blockStreamMerger) Init(bsrs []*blockStreamReader, retentionDeadline int64, useSparseCache bool) {
+func (bsm *blockStreamMerger) Init(bsrs []*blockStreamReader, retentionDeadline int64, getRetentionDeadline func(metricID uint64) int64, useSparseCache bool) {
 	bsm.reset()
 	bsm.retentionDeadline = retentionDeadline
+	bsm.getRetentionDeadlineFunc = getRetentionDeadline
 	for _, bsr := range bsrs {
 		if bsr.NextBlock() {
 			bsm.bsrHeap = append(bsm.bsrHeap, bsr)
@@ -66,8 +73,16 @@ func (bsm *blockStreamMerger) Init(bsrs []*blockStreamReader, retentionDeadline
 	bsm.useSparseCache = useSparseCache
 }
 
-func (bsm *blockStreamMerger) getRetentionDeadline(_ *blockHeader) int64 {
-	return bsm.retentionDeadline
+func (bsm *blockStreamMerger) getRetentionDeadline(bh *blockHeader) int64 {
+	if bsm.getRetentionDeadlineFunc == nil {
+		return bsm.retentionDeadline
+	}
+	return bsm.getRetentionDeadlineFunc(bh.TSID.MetricID)
+}
+
+// getTombstoneID returns partHeader.TombstoneID for the part containing bsm.Block.
+func (bsm *blockStreamMerger) getTombstoneID() uint64 {
+	return bsm.bsrHeap[0].ph.TombstoneID
 }
 
 // NextBlock stores the next block in bsm.Block.
@@ -156,6 +156,39 @@ func (bsr *blockStreamReader) MustInitFromFilePart(path string) {
 	bsr.mrs = mrs
 }
 
+// MustInitFromOffloadedPart initializes bsr from the file-based part on the given path with data files offloaded via o.
+func (bsr *blockStreamReader) MustInitFromOffloadedPart(path string, opi *offloadedPartInfo, o *offloader) {
+	bsr.reset()
+
+	path = filepath.Clean(path)
+
+	bsr.ph.MustReadMetadata(path)
+
+	timestampsFile := &remoteStreamReader{
+		r: o.newReaderAt(opi.RemotePath, timestampsFilename, opi.TimestampsSize),
+	}
+	valuesFile := &remoteStreamReader{
+		r: o.newReaderAt(opi.RemotePath, valuesFilename, opi.ValuesSize),
+	}
+
+	indexPath := filepath.Join(path, indexFilename)
+	indexFile := filestream.MustOpen(indexPath, true)
+
+	metaindexPath := filepath.Join(path, metaindexFilename)
+	metaindexFile := filestream.MustOpen(metaindexPath, true)
+	mrs, err := unmarshalMetaindexRows(bsr.mrs[:0], metaindexFile)
+	metaindexFile.MustClose()
+	if err != nil {
+		logger.Panicf("FATAL: cannot unmarshal metaindex rows from file part %q: %s", metaindexPath, err)
+	}
+
+	bsr.path = path
+	bsr.timestampsReader = timestampsFile
+	bsr.valuesReader = valuesFile
+	bsr.indexReader = indexFile
+	bsr.mrs = mrs
+}
+
 // MustClose closes the bsr.
 //
 // It closes *Reader files passed to Init.
@@ -270,14 +303,18 @@ func (bsr *blockStreamReader) readBlock() error {
 		bsr.Block.timestampsData = append(bsr.Block.timestampsData[:0], bsr.prevTimestampsData...)
 	} else {
 		bsr.Block.timestampsData = bytesutil.ResizeNoCopyMayOverallocate(bsr.Block.timestampsData, int(bsr.Block.bh.TimestampsBlockSize))
-		fs.MustReadData(bsr.timestampsReader, bsr.Block.timestampsData)
+		if err := readPartData(bsr.timestampsReader, bsr.Block.timestampsData); err != nil {
+			return fmt.Errorf("cannot read timestamps data: %w", err)
+		}
 		bsr.prevTimestampsBlockOffset = bsr.timestampsBlockOffset
 		bsr.prevTimestampsData = append(bsr.prevTimestampsData[:0], bsr.Block.timestampsData...)
 	}
 
 	// Read values data.
 	bsr.Block.valuesData = bytesutil.ResizeNoCopyMayOverallocate(bsr.Block.valuesData, int(bsr.Block.bh.ValuesBlockSize))
-	fs.MustReadData(bsr.valuesReader, bsr.Block.valuesData)
+	if err := readPartData(bsr.valuesReader, bsr.Block.valuesData); err != nil {
+		return fmt.Errorf("cannot read values data: %w", err)
+	}
 
 	// Update offsets.
 	if !usePrevTimestamps {
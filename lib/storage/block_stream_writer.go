@@ -39,6 +39,12 @@ type blockStreamWriter struct {
 	// since such metrics have identical timestamps.
 	prevTimestampsData        []byte
 	prevTimestampsBlockOffset uint64
+
+	// currentTimestamp is the timestamp in milliseconds, which is used for determining the age of the written blocks.
+	//
+	// The age is used for selecting the downsampling interval for the written blocks.
+	// Downsampling isn't applied if currentTimestamp is zero.
+	currentTimestamp int64
 }
 
 // Init initializes bsw with the given writers.
@@ -64,6 +70,8 @@ func (bsw *blockStreamWriter) reset() {
 
 	bsw.prevTimestampsData = bsw.prevTimestampsData[:0]
 	bsw.prevTimestampsBlockOffset = 0
+
+	bsw.currentTimestamp = 0
 }
 
 // MustInitFromInmemoryPart initializes bsw from inmemory part.
@@ -133,7 +141,7 @@ func (bsw *blockStreamWriter) MustClose() {
 // WriteExternalBlock writes b to bsw and updates ph and rowsMerged.
 func (bsw *blockStreamWriter) WriteExternalBlock(b *Block, ph *partHeader, rowsMerged *atomic.Uint64) {
 	rowsMerged.Add(uint64(b.rowsCount()))
-	b.deduplicateSamplesDuringMerge()
+	b.deduplicateSamplesDuringMerge(bsw.getDedupInterval(b))
 	headerData, timestampsData, valuesData := b.MarshalData(bsw.timestampsBlockOffset, bsw.valuesBlockOffset)
 
 	usePrevTimestamps := len(bsw.prevTimestampsData) > 0 && bytes.Equal(timestampsData, bsw.prevTimestampsData)
@@ -162,6 +170,13 @@ func (bsw *blockStreamWriter) WriteExternalBlock(b *Block, ph *partHeader, rowsM
 	updatePartHeader(b, ph)
 }
 
+func (bsw *blockStreamWriter) getDedupInterval(b *Block) int64 {
+	if bsw.currentTimestamp <= 0 {
+		return GetDedupInterval()
+	}
+	return getDedupIntervalForAge(bsw.currentTimestamp - b.bh.MaxTimestamp)
+}
+
 var (
 	timestampsBlocksMerged atomic.Uint64
 	timestampsBytesSaved   atomic.Uint64
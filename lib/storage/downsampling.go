@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+)
+
+// DownsamplingPeriod defines downsampling for samples older than Offset.
+//
+// Only the last sample per each Interval is left for samples older than Offset.
+type DownsamplingPeriod struct {
+	// Offset is the age in milliseconds for samples to be downsampled.
+	Offset int64
+
+	// Interval is the downsampling interval in milliseconds.
+	Interval int64
+}
+
+// String returns string representation of dp.
+func (dp *DownsamplingPeriod) String() string {
+	return fmt.Sprintf("%dms:%dms", dp.Offset, dp.Interval)
+}
+
+// ParseDownsamplingPeriods parses downsampling periods from a.
+//
+// Every item in a must have the form `offset:interval`, for example `30d:5m`.
+// The returned periods are sorted by Offset.
+func ParseDownsamplingPeriods(a []string) ([]DownsamplingPeriod, error) {
+	var dps []DownsamplingPeriod
+	for _, s := range a {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		dp, err := parseDownsamplingPeriod(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse downsampling period %q: %w", s, err)
+		}
+		dps = append(dps, dp)
+	}
+	sort.Slice(dps, func(i, j int) bool {
+		return dps[i].Offset < dps[j].Offset
+	})
+	for i := 1; i < len(dps); i++ {
+		prev := &dps[i-1]
+		dp := &dps[i]
+		if dp.Offset == prev.Offset {
+			return nil, fmt.Errorf("duplicate downsampling offset %dms", dp.Offset)
+		}
+		if dp.Interval <= prev.Interval || dp.Interval%prev.Interval != 0 {
+			return nil, fmt.Errorf("downsampling interval %dms for offset %dms must be a multiple of the interval %dms for the smaller offset %dms",
+				dp.Interval, dp.Offset, prev.Interval, prev.Offset)
+		}
+	}
+	return dps, nil
+}
+
+func parseDownsamplingPeriod(s string) (DownsamplingPeriod, error) {
+	var dp DownsamplingPeriod
+	n := strings.IndexByte(s, ':')
+	if n < 0 {
+		return dp, fmt.Errorf("missing ':' delimiter between offset and interval")
+	}
+	offsetStr, intervalStr := s[:n], s[n+1:]
+	if strings.IndexByte(intervalStr, ':') >= 0 {
+		return dp, fmt.Errorf("series filters aren't supported in downsampling periods; use `offset:interval` syntax")
+	}
+	offset, err := timeutil.ParseDuration(offsetStr)
+	if err != nil {
+		return dp, fmt.Errorf("cannot parse offset %q: %w", offsetStr, err)
+	}
+	interval, err := timeutil.ParseDuration(intervalStr)
+	if err != nil {
+		return dp, fmt.Errorf("cannot parse interval %q: %w", intervalStr, err)
+	}
+	dp.Offset = offset.Milliseconds()
+	dp.Interval = interval.Milliseconds()
+	if dp.Offset < 0 {
+		return dp, fmt.Errorf("offset cannot be negative; got %s", offsetStr)
+	}
+	if dp.Interval <= 0 {
+		return dp, fmt.Errorf("interval must be positive; got %s", intervalStr)
+	}
+	if dp.Offset%dp.Interval != 0 {
+		return dp, fmt.Errorf("offset %s must be a multiple of interval %s", offsetStr, intervalStr)
+	}
+	return dp, nil
+}
+
+// SetDownsamplingPeriods sets the downsampling periods, which are applied to samples during background merges.
+//
+// Downsampling is disabled if dps is empty.
+//
+// This function must be called before initializing the storage.
+func SetDownsamplingPeriods(dps []DownsamplingPeriod) {
+	globalDownsamplingPeriods = append([]DownsamplingPeriod{}, dps...)
+}
+
+var globalDownsamplingPeriods []DownsamplingPeriod
+
+func isDownsamplingEnabled() bool {
+	return len(globalDownsamplingPeriods) > 0
+}
+
+// getDownsamplingInterval returns the downsampling interval in milliseconds for samples with the given age in milliseconds.
+//
+// 0 is returned if the downsampling mustn't be applied to samples with the given age.
+func getDownsamplingInterval(age int64) int64 {
+	dps := globalDownsamplingPeriods
+	for i := len(dps) - 1; i >= 0; i-- {
+		if age >= dps[i].Offset {
+			return dps[i].Interval
+		}
+	}
+	return 0
+}
+
+// getDedupIntervalForAge returns the dedup interval in milliseconds for samples with the given age in milliseconds.
+//
+// The returned interval takes into account both the deduplication and the downsampling.
+func getDedupIntervalForAge(age int64) int64 {
+	return max(GetDedupInterval(), getDownsamplingInterval(age))
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
+)
+
+func TestParseDownsamplingPeriodsSuccess(t *testing.T) {
+	f := func(a []string, dpsExpected []DownsamplingPeriod) {
+		t.Helper()
+		dps, err := ParseDownsamplingPeriods(a)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(dps, dpsExpected) {
+			t.Fatalf("unexpected downsampling periods\ngot\n%v\nwant\n%v", dps, dpsExpected)
+		}
+	}
+
+	f(nil, nil)
+	f([]string{""}, nil)
+	f([]string{"30d:5m"}, []DownsamplingPeriod{
+		{Offset: 30 * 24 * 3600 * 1000, Interval: 5 * 60 * 1000},
+	})
+	f([]string{"180d:1h", "30d:5m"}, []DownsamplingPeriod{
+		{Offset: 30 * 24 * 3600 * 1000, Interval: 5 * 60 * 1000},
+		{Offset: 180 * 24 * 3600 * 1000, Interval: 3600 * 1000},
+	})
+	f([]string{"0s:1m", " 1h:10m "}, []DownsamplingPeriod{
+		{Offset: 0, Interval: 60 * 1000},
+		{Offset: 3600 * 1000, Interval: 10 * 60 * 1000},
+	})
+}
+
+func TestParseDownsamplingPeriodsFailure(t *testing.T) {
+	f := func(a []string) {
+		t.Helper()
+		_, err := ParseDownsamplingPeriods(a)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing delimiter
+	f([]string{"30d"})
+
+	// invalid offset
+	f([]string{"foo:5m"})
+
+	// invalid interval
+	f([]string{"30d:bar"})
+
+	// zero interval
+	f([]string{"30d:0s"})
+
+	// offset isn't a multiple of interval
+	f([]string{"7m:5m"})
+
+	// series filters aren't supported
+	f([]string{`{env="dev"}:30d:5m`})
+
+	// duplicate offsets
+	f([]string{"30d:5m", "30d:1h"})
+
+	// intervals aren't multiples of each other
+	f([]string{"30d:5m", "180d:7m"})
+	f([]string{"30d:1h", "180d:5m"})
+}
+
+func TestGetDownsamplingInterval(t *testing.T) {
+	defer SetDownsamplingPeriods(nil)
+
+	f := func(age, intervalExpected int64) {
+		t.Helper()
+		interval := getDownsamplingInterval(age)
+		if interval != intervalExpected {
+			t.Fatalf("unexpected downsampling interval for age=%d; got %d; want %d", age, interval, intervalExpected)
+		}
+	}
+
+	// downsampling is disabled
+	SetDownsamplingPeriods(nil)
+	f(0, 0)
+	f(1e12, 0)
+
+	SetDownsamplingPeriods([]DownsamplingPeriod{
+		{Offset: 1000, Interval: 10},
+		{Offset: 10000, Interval: 100},
+	})
+	f(-1, 0)
+	f(0, 0)
+	f(999, 0)
+	f(1000, 10)
+	f(9999, 10)
+	f(10000, 100)
+	f(1e12, 100)
+}
+
+func TestMergeBlockStreamsWithDownsampling(t *testing.T) {
+	defer SetDownsamplingPeriods(nil)
+	SetDownsamplingPeriods([]DownsamplingPeriod{
+		{Offset: 1000, Interval: 100},
+	})
+
+	f := func(currentTimestamp int64, rowsExpected int) {
+		t.Helper()
+
+		var r rawRow
+		initTestTSID(&r.TSID)
+		r.PrecisionBits = defaultPrecisionBits
+		var rows1, rows2 []rawRow
+		for i := 0; i < 100; i++ {
+			r.Timestamp = int64(i * 20)
+			r.Value = float64(i)
+			if i%2 == 0 {
+				rows1 = append(rows1, r)
+			} else {
+				rows2 = append(rows2, r)
+			}
+		}
+		bsrs := []*blockStreamReader{
+			newTestBlockStreamReader(rows1),
+			newTestBlockStreamReader(rows2),
+		}
+
+		var mp inmemoryPart
+		var bsw blockStreamWriter
+		bsw.MustInitFromInmemoryPart(&mp, -5)
+		bsw.currentTimestamp = currentTimestamp
+
+		dmis := &uint64set.Set{}
+		var rowsMerged, rowsDeleted atomic.Uint64
+		if err := mergeBlockStreams(&mp.ph, &bsw, bsrs, nil, dmis, nil, 0, nil, &rowsMerged, &rowsDeleted, true); err != nil {
+			t.Fatalf("unexpected error in mergeBlockStreams: %s", err)
+		}
+		if n := rowsMerged.Load(); n != 100 {
+			t.Fatalf("unexpected rowsMerged; got %d; want %d", n, 100)
+		}
+		if mp.ph.RowsCount != uint64(rowsExpected) {
+			t.Fatalf("unexpected rows count in partHeader; got %d; want %d", mp.ph.RowsCount, rowsExpected)
+		}
+	}
+
+	// downsampling isn't applied to blocks younger than the offset
+	f(2000, 100)
+
+	// downsampling is applied to blocks older than the offset
+	f(3000, 21)
+
+	// downsampling is disabled for zero currentTimestamp
+	f(0, 100)
+}
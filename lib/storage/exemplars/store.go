@@ -0,0 +1,472 @@
+package exemplars
+
+import (
+	"compress/gzip"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// exemplarOverhead is the approximate in-memory size of a single Exemplar excluding its labels.
+const exemplarOverhead = 8 + 8 + 24
+
+// seriesOverhead is the approximate in-memory size of per-series entry excluding its exemplars.
+const seriesOverhead = 8 + 8 + 24 + 8 + 48
+
+// expireInterval is the interval for removing exemplars older than the retention.
+const expireInterval = time.Minute
+
+// Label is an exemplar label.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Exemplar is an exemplar for a time series.
+//
+// See https://github.com/OpenObservability/OpenMetrics/blob/main/specification/OpenMetrics.md#exemplars
+type Exemplar struct {
+	// Labels contains exemplar labels such as trace_id.
+	Labels []Label
+
+	// Value is the exemplar value.
+	Value float64
+
+	// Timestamp is the exemplar timestamp in milliseconds.
+	Timestamp int64
+}
+
+func (e *Exemplar) sizeBytes() uint64 {
+	n := uint64(exemplarOverhead)
+	for _, label := range e.Labels {
+		n += uint64(len(label.Name)+len(label.Value)) + 32
+	}
+	return n
+}
+
+func (e *Exemplar) equal(x *Exemplar) bool {
+	if e.Timestamp != x.Timestamp || e.Value != x.Value || len(e.Labels) != len(x.Labels) {
+		return false
+	}
+	for i := range e.Labels {
+		if e.Labels[i] != x.Labels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Exemplar) clone() Exemplar {
+	labels := make([]Label, len(e.Labels))
+	for i, label := range e.Labels {
+		labels[i] = Label{
+			Name:  strings.Clone(label.Name),
+			Value: strings.Clone(label.Value),
+		}
+	}
+	return Exemplar{
+		Labels:    labels,
+		Value:     e.Value,
+		Timestamp: e.Timestamp,
+	}
+}
+
+// ring holds the last exemplars for a single series.
+type ring struct {
+	// exemplars contains up to Store.maxExemplarsPerSeries exemplars.
+	exemplars []Exemplar
+
+	// next is the index of the next exemplar to overwrite when exemplars is full.
+	next int
+
+	// lruElem is the element for the series at Store.lru.
+	lruElem *list.Element
+}
+
+func (r *ring) sizeBytes() uint64 {
+	n := uint64(seriesOverhead)
+	for i := range r.exemplars {
+		n += r.exemplars[i].sizeBytes()
+	}
+	return n
+}
+
+// removeOlderThan removes exemplars with timestamps smaller than minTimestamp from r.
+//
+// It returns the number of removed exemplars and their size in bytes.
+func (r *ring) removeOlderThan(minTimestamp int64, maxExemplars int) (int, uint64) {
+	// Fast path - nothing to remove.
+	hasOld := false
+	for i := range r.exemplars {
+		if r.exemplars[i].Timestamp < minTimestamp {
+			hasOld = true
+			break
+		}
+	}
+	if !hasOld {
+		return 0, 0
+	}
+
+	// Slow path - re-create exemplars in the order they were added.
+	exemplars := make([]Exemplar, 0, len(r.exemplars))
+	var removedSize uint64
+	for _, a := range [][]Exemplar{r.exemplars[r.next:], r.exemplars[:r.next]} {
+		for i := range a {
+			e := &a[i]
+			if e.Timestamp < minTimestamp {
+				removedSize += e.sizeBytes()
+				continue
+			}
+			exemplars = append(exemplars, *e)
+		}
+	}
+	removed := len(r.exemplars) - len(exemplars)
+	r.exemplars = exemplars
+	r.next = len(exemplars) % maxExemplars
+	return removed, removedSize
+}
+
+func (r *ring) last() *Exemplar {
+	if len(r.exemplars) == 0 {
+		return nil
+	}
+	idx := r.next - 1
+	if idx < 0 {
+		idx = len(r.exemplars) - 1
+	}
+	return &r.exemplars[idx]
+}
+
+// Store holds the last exemplars per every series.
+//
+// The number of exemplars per series is limited by maxExemplarsPerSeries,
+// while the total size of the store is limited by maxSizeBytes.
+// Exemplars for the least recently updated series are evicted when the size limit is reached.
+// Exemplars older than the retention are removed in background.
+type Store struct {
+	maxExemplarsPerSeries int
+	maxSizeBytes          uint64
+	retentionMsecs        int64
+	path                  string
+
+	currentSizeBytes  atomic.Uint64
+	currentItemsCount atomic.Uint64
+	droppedExemplars  atomic.Uint64
+	evictedExemplars  atomic.Uint64
+	expiredExemplars  atomic.Uint64
+
+	// mu protects m and lru
+	mu sync.RWMutex
+
+	// m maps metricID to the last exemplars for it.
+	m map[uint64]*ring
+
+	// lru contains metricIDs ordered by the last update time. The most recently updated metricID is at the front.
+	lru *list.List
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// Metrics contains metrics for the Store.
+type Metrics struct {
+	ItemsCount       uint64
+	SizeBytes        uint64
+	MaxSizeBytes     uint64
+	DroppedExemplars uint64
+	EvictedExemplars uint64
+	ExpiredExemplars uint64
+}
+
+// MustLoadFrom loads the store from the given path.
+//
+// Exemplars older than the given retention are dropped. The retention isn't applied if it is zero.
+//
+// An empty store is returned if the path doesn't exist.
+func MustLoadFrom(path string, maxExemplarsPerSeries int, maxSizeBytes uint64, retention time.Duration) *Store {
+	s, err := loadFrom(path, maxExemplarsPerSeries, maxSizeBytes, retention)
+	if err != nil {
+		logger.Fatalf("cannot load exemplars from %q: %s", path, err)
+	}
+	if s.IsEnabled() && s.retentionMsecs > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.expireWorker()
+		}()
+	}
+	return s
+}
+
+func (s *Store) expireWorker() {
+	t := time.NewTicker(expireInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-t.C:
+			s.removeOlderThan(s.getMinTimestamp())
+		}
+	}
+}
+
+// getMinTimestamp returns the minimum timestamp for exemplars, which must be kept in s.
+func (s *Store) getMinTimestamp() int64 {
+	if s.retentionMsecs <= 0 {
+		return math.MinInt64
+	}
+	return int64(fasttime.UnixTimestamp())*1000 - s.retentionMsecs
+}
+
+// removeOlderThan removes exemplars with timestamps smaller than minTimestamp from s.
+//
+// Series without exemplars are removed from s.
+func (s *Store) removeOlderThan(minTimestamp int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for metricID, r := range s.m {
+		n, size := r.removeOlderThan(minTimestamp, s.maxExemplarsPerSeries)
+		if n == 0 {
+			continue
+		}
+		if len(r.exemplars) == 0 {
+			s.lru.Remove(r.lruElem)
+			delete(s.m, metricID)
+			size += seriesOverhead
+		}
+		s.currentSizeBytes.Add(^(size - 1))
+		s.currentItemsCount.Add(^uint64(n - 1))
+		s.expiredExemplars.Add(uint64(n))
+	}
+}
+
+// seriesExemplars is used for persisting exemplars for a single series.
+type seriesExemplars struct {
+	MetricID  uint64
+	Exemplars []Exemplar
+}
+
+func loadFrom(path string, maxExemplarsPerSeries int, maxSizeBytes uint64, retention time.Duration) (*Store, error) {
+	s := &Store{
+		maxExemplarsPerSeries: maxExemplarsPerSeries,
+		maxSizeBytes:          maxSizeBytes,
+		retentionMsecs:        retention.Milliseconds(),
+		path:                  path,
+		m:                     make(map[uint64]*ring),
+		lru:                   list.New(),
+		stopCh:                make(chan struct{}),
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("cannot open file: %w", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create gzip reader: %w", err)
+	}
+	d := json.NewDecoder(zr)
+	for {
+		var se seriesExemplars
+		if err := d.Decode(&se); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("cannot parse exemplars: %w", err)
+		}
+		for i := range se.Exemplars {
+			s.Add(se.MetricID, &se.Exemplars[i])
+		}
+	}
+	if err := zr.Close(); err != nil {
+		return nil, fmt.Errorf("cannot close gzip reader: %w", err)
+	}
+	return s, nil
+}
+
+// IsEnabled returns true if s stores exemplars.
+func (s *Store) IsEnabled() bool {
+	return s.maxExemplarsPerSeries > 0
+}
+
+// Add adds e for the series with the given metricID to s.
+//
+// The oldest exemplar for the series is dropped if the series already has maxExemplarsPerSeries exemplars.
+// Exemplars for the least recently updated series are evicted if the store size exceeds maxSizeBytes.
+// Exemplars older than the retention are skipped.
+func (s *Store) Add(metricID uint64, e *Exemplar) {
+	if !s.IsEnabled() || math.IsNaN(e.Value) || math.IsInf(e.Value, 0) {
+		// Skip exemplars with non-finite values, since they cannot be persisted in JSON.
+		return
+	}
+	if e.Timestamp < s.getMinTimestamp() {
+		// Skip exemplars outside the retention, since they would be removed soon.
+		return
+	}
+
+	// Fast path - the exemplar is already stored. This is the common case for scraped exemplars,
+	// since the same exemplar is usually exposed until the next observation.
+	s.mu.RLock()
+	r := s.m[metricID]
+	isDuplicate := r != nil && r.last().equal(e)
+	s.mu.RUnlock()
+	if isDuplicate {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r = s.m[metricID]
+	if r != nil && r.last().equal(e) {
+		return
+	}
+	size := e.sizeBytes()
+	if r == nil {
+		size += seriesOverhead
+	}
+	if r != nil {
+		// The series becomes the most recently updated one, so it mustn't be evicted.
+		s.lru.MoveToFront(r.lruElem)
+	}
+	if r == nil || len(r.exemplars) < s.maxExemplarsPerSeries {
+		if !s.ensureFreeSpaceLocked(metricID, size) {
+			s.droppedExemplars.Add(1)
+			return
+		}
+		if r == nil {
+			r = &ring{}
+			r.lruElem = s.lru.PushFront(metricID)
+			s.m[metricID] = r
+		}
+		r.exemplars = append(r.exemplars, e.clone())
+		r.next = len(r.exemplars) % s.maxExemplarsPerSeries
+		s.currentSizeBytes.Add(size)
+		s.currentItemsCount.Add(1)
+		return
+	}
+
+	// Overwrite the oldest exemplar.
+	prevSize := r.exemplars[r.next].sizeBytes()
+	if size > prevSize && !s.ensureFreeSpaceLocked(metricID, size-prevSize) {
+		s.droppedExemplars.Add(1)
+		return
+	}
+	r.exemplars[r.next] = e.clone()
+	r.next = (r.next + 1) % len(r.exemplars)
+	s.currentSizeBytes.Add(size - prevSize)
+}
+
+// ensureFreeSpaceLocked evicts exemplars for the least recently updated series until s has free space for size bytes.
+//
+// Exemplars for the series with the given metricID aren't evicted. It returns false if there is no free space for size bytes after the eviction.
+func (s *Store) ensureFreeSpaceLocked(metricID uint64, size uint64) bool {
+	for s.currentSizeBytes.Load()+size > s.maxSizeBytes {
+		elem := s.lru.Back()
+		if elem == nil {
+			return false
+		}
+		evictMetricID := elem.Value.(uint64)
+		if evictMetricID == metricID {
+			// Only the series with the given metricID is left, so there is nothing to evict.
+			return false
+		}
+		r := s.m[evictMetricID]
+		s.lru.Remove(elem)
+		delete(s.m, evictMetricID)
+		s.currentSizeBytes.Add(^(r.sizeBytes() - 1))
+		s.currentItemsCount.Add(^uint64(len(r.exemplars) - 1))
+		s.evictedExemplars.Add(uint64(len(r.exemplars)))
+	}
+	return true
+}
+
+// Get appends exemplars with timestamps in the range [minTimestamp ... maxTimestamp] for the given metricID to dst and returns the result.
+//
+// The appended exemplars are sorted by timestamp.
+func (s *Store) Get(dst []Exemplar, metricID uint64, minTimestamp, maxTimestamp int64) []Exemplar {
+	dstLen := len(dst)
+	s.mu.RLock()
+	if r := s.m[metricID]; r != nil {
+		for i := range r.exemplars {
+			e := &r.exemplars[i]
+			if e.Timestamp >= minTimestamp && e.Timestamp <= maxTimestamp {
+				dst = append(dst, *e)
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	result := dst[dstLen:]
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+	return dst
+}
+
+// UpdateMetrics updates m with metrics from s.
+func (s *Store) UpdateMetrics(m *Metrics) {
+	m.ItemsCount = s.currentItemsCount.Load()
+	m.SizeBytes = s.currentSizeBytes.Load()
+	m.MaxSizeBytes = s.maxSizeBytes
+	m.DroppedExemplars = s.droppedExemplars.Load()
+	m.EvictedExemplars = s.evictedExemplars.Load()
+	m.ExpiredExemplars = s.expiredExemplars.Load()
+}
+
+// MustClose saves s to the path it was loaded from.
+//
+// s mustn't be used after MustClose call.
+func (s *Store) MustClose() {
+	close(s.stopCh)
+	s.wg.Wait()
+
+	if !s.IsEnabled() {
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var bb strings.Builder
+	zw := gzip.NewWriter(&bb)
+	e := json.NewEncoder(zw)
+	// Persist series starting from the least recently updated one, so the eviction order is preserved after loading.
+	for elem := s.lru.Back(); elem != nil; elem = elem.Prev() {
+		metricID := elem.Value.(uint64)
+		r := s.m[metricID]
+		// Persist exemplars in the order they were added, so the order is preserved after loading.
+		exemplars := append([]Exemplar{}, r.exemplars[r.next:]...)
+		exemplars = append(exemplars, r.exemplars[:r.next]...)
+		se := seriesExemplars{
+			MetricID:  metricID,
+			Exemplars: exemplars,
+		}
+		if err := e.Encode(&se); err != nil {
+			logger.Panicf("BUG: cannot marshal exemplars: %s", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		logger.Panicf("BUG: cannot close gzip writer: %s", err)
+	}
+	fs.MustWriteAtomic(s.path, []byte(bb.String()), true)
+}
@@ -0,0 +1,179 @@
+package exemplars
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStoreAddGet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exemplars")
+	s := MustLoadFrom(path, 2, 1e6, 0)
+
+	newExemplar := func(traceID string, value float64, timestamp int64) *Exemplar {
+		return &Exemplar{
+			Labels: []Label{
+				{Name: "trace_id", Value: traceID},
+			},
+			Value:     value,
+			Timestamp: timestamp,
+		}
+	}
+
+	f := func(metricID uint64, minTimestamp, maxTimestamp int64, resultExpected []Exemplar) {
+		t.Helper()
+		result := s.Get(nil, metricID, minTimestamp, maxTimestamp)
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected exemplars\ngot\n%+v\nwant\n%+v", result, resultExpected)
+		}
+	}
+
+	s.Add(1, newExemplar("a", 1, 1000))
+	s.Add(1, newExemplar("a", 1, 1000))
+	s.Add(1, newExemplar("b", 2, 2000))
+	s.Add(2, newExemplar("c", 3, 1500))
+
+	f(1, 0, 3000, []Exemplar{*newExemplar("a", 1, 1000), *newExemplar("b", 2, 2000)})
+	f(1, 1500, 3000, []Exemplar{*newExemplar("b", 2, 2000)})
+	f(2, 0, 3000, []Exemplar{*newExemplar("c", 3, 1500)})
+	f(3, 0, 3000, nil)
+
+	// The oldest exemplar must be overwritten when the ring is full
+	s.Add(1, newExemplar("d", 4, 3000))
+	f(1, 0, 3000, []Exemplar{*newExemplar("b", 2, 2000), *newExemplar("d", 4, 3000)})
+
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.ItemsCount != 3 {
+		t.Fatalf("unexpected ItemsCount; got %d; want 3", m.ItemsCount)
+	}
+
+	// Verify the exemplars are restored after MustClose
+	s.MustClose()
+	s = MustLoadFrom(path, 2, 1e6, 0)
+	f(1, 0, 3000, []Exemplar{*newExemplar("b", 2, 2000), *newExemplar("d", 4, 3000)})
+	f(2, 0, 3000, []Exemplar{*newExemplar("c", 3, 1500)})
+	s.MustClose()
+}
+
+func TestStoreLimits(t *testing.T) {
+	e := &Exemplar{
+		Labels: []Label{
+			{Name: "trace_id", Value: "foo"},
+		},
+		Value:     1,
+		Timestamp: 1000,
+	}
+
+	// Disabled store
+	s := MustLoadFrom(filepath.Join(t.TempDir(), "exemplars"), 0, 1e6, 0)
+	s.Add(1, e)
+	if result := s.Get(nil, 1, 0, 2000); len(result) != 0 {
+		t.Fatalf("unexpected exemplars in disabled store: %+v", result)
+	}
+	s.MustClose()
+
+	// Size limit - the least recently updated series must be evicted
+	s = MustLoadFrom(filepath.Join(t.TempDir(), "exemplars"), 10, 3*(e.sizeBytes()+seriesOverhead), 0)
+	s.Add(1, e)
+	s.Add(2, e)
+	s.Add(3, e)
+	s.Add(1, &Exemplar{
+		Labels:    e.Labels,
+		Value:     2,
+		Timestamp: 1000,
+	})
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.ItemsCount != 3 {
+		t.Fatalf("unexpected ItemsCount; got %d; want 3", m.ItemsCount)
+	}
+	if m.EvictedExemplars != 1 {
+		t.Fatalf("unexpected EvictedExemplars; got %d; want 1", m.EvictedExemplars)
+	}
+	if m.DroppedExemplars != 0 {
+		t.Fatalf("unexpected DroppedExemplars; got %d; want 0", m.DroppedExemplars)
+	}
+	if result := s.Get(nil, 2, 0, 2000); len(result) != 0 {
+		t.Fatalf("unexpected exemplars for the evicted series: %+v", result)
+	}
+	if result := s.Get(nil, 1, 0, 2000); len(result) != 2 {
+		t.Fatalf("unexpected exemplars for the recently updated series: %+v", result)
+	}
+	if result := s.Get(nil, 3, 0, 2000); len(result) != 1 {
+		t.Fatalf("unexpected exemplars for the recently added series: %+v", result)
+	}
+	s.MustClose()
+
+	// Too big exemplar must be dropped
+	s = MustLoadFrom(filepath.Join(t.TempDir(), "exemplars"), 10, e.sizeBytes(), 0)
+	s.Add(1, e)
+	s.UpdateMetrics(&m)
+	if m.ItemsCount != 0 {
+		t.Fatalf("unexpected ItemsCount; got %d; want 0", m.ItemsCount)
+	}
+	if m.DroppedExemplars != 1 {
+		t.Fatalf("unexpected DroppedExemplars; got %d; want 1", m.DroppedExemplars)
+	}
+	s.MustClose()
+}
+
+func TestStoreRetention(t *testing.T) {
+	newExemplar := func(timestamp int64) *Exemplar {
+		return &Exemplar{
+			Labels: []Label{
+				{Name: "trace_id", Value: "foo"},
+			},
+			Value:     1,
+			Timestamp: timestamp,
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	s := MustLoadFrom(filepath.Join(t.TempDir(), "exemplars"), 3, 1e6, time.Hour)
+	defer s.MustClose()
+
+	// Exemplars outside the retention must be skipped
+	s.Add(1, newExemplar(now-2*3600*1000))
+	if result := s.Get(nil, 1, 0, now); len(result) != 0 {
+		t.Fatalf("unexpected exemplars outside the retention: %+v", result)
+	}
+
+	s.Add(1, newExemplar(now-1000))
+	s.Add(1, newExemplar(now-500))
+	s.Add(1, newExemplar(now))
+	s.Add(2, newExemplar(now-1000))
+
+	// Old exemplars must be removed, while series without exemplars must be dropped
+	s.removeOlderThan(now - 500)
+	result := s.Get(nil, 1, 0, now)
+	resultExpected := []Exemplar{*newExemplar(now - 500), *newExemplar(now)}
+	if !reflect.DeepEqual(result, resultExpected) {
+		t.Fatalf("unexpected exemplars\ngot\n%+v\nwant\n%+v", result, resultExpected)
+	}
+	if result := s.Get(nil, 2, 0, now); len(result) != 0 {
+		t.Fatalf("unexpected exemplars for the expired series: %+v", result)
+	}
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.ItemsCount != 2 {
+		t.Fatalf("unexpected ItemsCount; got %d; want 2", m.ItemsCount)
+	}
+	if m.ExpiredExemplars != 2 {
+		t.Fatalf("unexpected ExpiredExemplars; got %d; want 2", m.ExpiredExemplars)
+	}
+	sizeBytesExpected := seriesOverhead + 2*newExemplar(now).sizeBytes()
+	if m.SizeBytes != sizeBytesExpected {
+		t.Fatalf("unexpected SizeBytes; got %d; want %d", m.SizeBytes, sizeBytesExpected)
+	}
+
+	// New exemplars must be added after the expired ones in the ring
+	s.Add(1, newExemplar(now+1))
+	s.Add(1, newExemplar(now+2))
+	result = s.Get(nil, 1, 0, now+2)
+	resultExpected = []Exemplar{*newExemplar(now), *newExemplar(now + 1), *newExemplar(now + 2)}
+	if !reflect.DeepEqual(result, resultExpected) {
+		t.Fatalf("unexpected exemplars\ngot\n%+v\nwant\n%+v", result, resultExpected)
+	}
+}
@@ -10,6 +10,10 @@ const (
 
 	appliedRetentionFilename    = "appliedRetention.txt"
 	resetCacheOnStartupFilename = "reset_cache_on_startup"
+	metricsMetadataFilename     = "metrics_metadata.json.gz"
+	offloadedPartFilename       = "offloaded.json"
+	tombstonesFilename          = "tombstones.json"
+	detachedSeriesFilename      = "series.bin"
 )
 
 const (
@@ -21,4 +25,7 @@ const (
 	metadataDirname  = "metadata"
 	snapshotsDirname = "snapshots"
 	cacheDirname     = "cache"
+	detachedDirname  = "detached"
+
+	offloadedChunksDirname = "offloaded_chunks"
 )
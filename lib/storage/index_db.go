@@ -1318,6 +1318,137 @@ func (db *indexDB) GetTSDBStatus(qt *querytracer.Tracer, tfss []*TagFilters, dat
 	return status, nil
 }
 
+// GetTSDBStatusOnDateRange returns topN entries for tsdb status for the given tfss and focusLabel on the [minDate ... maxDate] date range.
+//
+// The returned status contains stats for unique series seen on the date range and per-day series churn stats.
+func (db *indexDB) GetTSDBStatusOnDateRange(qt *querytracer.Tracer, tfss []*TagFilters, minDate, maxDate uint64, focusLabel string, topN, maxMetrics int, deadline uint64) (*TSDBStatus, error) {
+	qtChild := qt.NewChild("collect series churn stats on the date range [%d..%d]", minDate, maxDate)
+	metricIDs, dateStats, err := db.getSeriesChurnStats(qtChild, tfss, minDate, maxDate, maxMetrics, deadline)
+	qtChild.Done()
+	if err != nil {
+		return nil, err
+	}
+	if metricIDs.Len() == 0 {
+		qt.Printf("no matching series for filter=%s", tfss)
+		return &TSDBStatus{
+			SeriesCountByDate: dateStats,
+		}, nil
+	}
+
+	// Collect stats for the series seen on the date range from the global index,
+	// so every series is counted only once.
+	qtChild = qt.NewChild("collect tsdb stats for %d series in the current indexdb", metricIDs.Len())
+	is := db.getIndexSearch(deadline)
+	status, err := is.getTSDBStatusWithFilter(metricIDs, globalIndexDate, focusLabel, topN)
+	db.putIndexSearch(is)
+	qtChild.Done()
+	if err != nil {
+		return nil, err
+	}
+	if !status.hasEntries() {
+		db.doExtDB(func(extDB *indexDB) {
+			qtChild := qt.NewChild("collect tsdb stats for %d series in the previous indexdb", metricIDs.Len())
+			is := extDB.getIndexSearch(deadline)
+			status, err = is.getTSDBStatusWithFilter(metricIDs, globalIndexDate, focusLabel, topN)
+			extDB.putIndexSearch(is)
+			qtChild.Done()
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error when obtaining TSDB status from extDB: %w", err)
+		}
+	}
+	status.SeriesCountByDate = dateStats
+	return status, nil
+}
+
+// getSeriesChurnStats returns metricIDs for series matching tfss on the [minDate ... maxDate] date range plus per-day churn stats for these series.
+func (db *indexDB) getSeriesChurnStats(qt *querytracer.Tracer, tfss []*TagFilters, minDate, maxDate uint64, maxMetrics int, deadline uint64) (*uint64set.Set, []TSDBDateStats, error) {
+	var prevMetricIDs *uint64set.Set
+	if minDate > 0 {
+		// Obtain series for the day before minDate, so the churn could be calculated for minDate.
+		m, err := db.getMetricIDsOnDate(qt, tfss, minDate-1, maxMetrics, deadline)
+		if err != nil {
+			return nil, nil, err
+		}
+		prevMetricIDs = m
+	}
+
+	allMetricIDs := &uint64set.Set{}
+	dateStats := make([]TSDBDateStats, 0, maxDate-minDate+1)
+	for date := minDate; date <= maxDate; date++ {
+		metricIDs, err := db.getMetricIDsOnDate(qt, tfss, date, maxMetrics, deadline)
+		if err != nil {
+			return nil, nil, err
+		}
+		ds := TSDBDateStats{
+			Date:        date,
+			TotalSeries: uint64(metricIDs.Len()),
+		}
+		if prevMetricIDs != nil {
+			newMetricIDs := metricIDs.Clone()
+			newMetricIDs.Subtract(prevMetricIDs)
+			ds.NewSeries = uint64(newMetricIDs.Len())
+
+			disappearedMetricIDs := prevMetricIDs.Clone()
+			disappearedMetricIDs.Subtract(metricIDs)
+			ds.DisappearedSeries = uint64(disappearedMetricIDs.Len())
+		} else {
+			ds.NewSeries = ds.TotalSeries
+		}
+		dateStats = append(dateStats, ds)
+
+		allMetricIDs.Union(metricIDs)
+		if allMetricIDs.Len() > maxMetrics {
+			return nil, nil, errTooManyTimeseries(maxMetrics)
+		}
+		prevMetricIDs = metricIDs
+	}
+	qt.Printf("found %d unique series on %d days", allMetricIDs.Len(), len(dateStats))
+	return allMetricIDs, dateStats, nil
+}
+
+// getMetricIDsOnDate returns metricIDs for series matching tfss on the given date.
+//
+// All the series registered at the given date are returned if tfss is empty.
+func (db *indexDB) getMetricIDsOnDate(qt *querytracer.Tracer, tfss []*TagFilters, date uint64, maxMetrics int, deadline uint64) (*uint64set.Set, error) {
+	is := db.getIndexSearch(deadline)
+	metricIDs, err := is.getMetricIDsOnDate(qt, tfss, date, maxMetrics)
+	db.putIndexSearch(is)
+	if err != nil {
+		return nil, err
+	}
+	db.doExtDB(func(extDB *indexDB) {
+		is := extDB.getIndexSearch(deadline)
+		var extMetricIDs *uint64set.Set
+		extMetricIDs, err = is.getMetricIDsOnDate(qt, tfss, date, maxMetrics)
+		extDB.putIndexSearch(is)
+		if err == nil {
+			metricIDs.UnionMayOwn(extMetricIDs)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error when searching for metricIDs in the previous indexdb: %w", err)
+	}
+	metricIDs.Subtract(db.s.getDeletedMetricIDs())
+	return metricIDs, nil
+}
+
+func (is *indexSearch) getMetricIDsOnDate(qt *querytracer.Tracer, tfss []*TagFilters, date uint64, maxMetrics int) (*uint64set.Set, error) {
+	if len(tfss) == 0 {
+		// getMetricIDsForDate stops the search after reaching maxMetrics,
+		// so request an additional metricID for detecting the limit overflow.
+		metricIDs, err := is.getMetricIDsForDate(date, maxMetrics+1)
+		if err != nil {
+			return nil, err
+		}
+		if metricIDs.Len() > maxMetrics {
+			return nil, errTooManyTimeseries(maxMetrics)
+		}
+		return metricIDs, nil
+	}
+	return is.searchMetricIDsWithFiltersOnDate(qt, tfss, date, maxMetrics)
+}
+
 // getTSDBStatus returns topN entries for tsdb status for the given tfss, date and focusLabel.
 func (is *indexSearch) getTSDBStatus(qt *querytracer.Tracer, tfss []*TagFilters, date uint64, focusLabel string, topN, maxMetrics int) (*TSDBStatus, error) {
 	filter, err := is.searchMetricIDsWithFiltersOnDate(qt, tfss, date, maxMetrics)
@@ -1328,6 +1459,13 @@ func (is *indexSearch) getTSDBStatus(qt *querytracer.Tracer, tfss []*TagFilters,
 		qt.Printf("no matching series for filter=%s", tfss)
 		return &TSDBStatus{}, nil
 	}
+	return is.getTSDBStatusWithFilter(filter, date, focusLabel, topN)
+}
+
+// getTSDBStatusWithFilter returns topN entries for tsdb status for series with the given filter metricIDs at the given date and focusLabel.
+//
+// All the series registered at the given date are taken into account if filter is nil.
+func (is *indexSearch) getTSDBStatusWithFilter(filter *uint64set.Set, date uint64, focusLabel string, topN int) (*TSDBStatus, error) {
 	ts := &is.ts
 	kb := &is.kb
 	mp := &is.mp
@@ -1462,6 +1600,26 @@ type TSDBStatus struct {
 	SeriesCountByLabelValuePair  []TopHeapEntry
 	LabelValueCountByLabelName   []TopHeapEntry
 	SeriesQueryStatsByMetricName []MetricNamesStatsRecord
+
+	// SeriesCountByDate contains per-day series churn stats.
+	//
+	// It is set only for the status obtained on a date range.
+	SeriesCountByDate []TSDBDateStats
+}
+
+// TSDBDateStats contains series churn stats for a single day.
+type TSDBDateStats struct {
+	// Date is the number of days since Unix epoch.
+	Date uint64
+
+	// TotalSeries is the number of series seen at Date.
+	TotalSeries uint64
+
+	// NewSeries is the number of series seen at Date, which weren't seen at the previous day.
+	NewSeries uint64
+
+	// DisappearedSeries is the number of series seen at the previous day, which weren't seen at Date.
+	DisappearedSeries uint64
 }
 
 func (status *TSDBStatus) hasEntries() bool {
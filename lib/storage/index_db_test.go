@@ -2086,6 +2086,60 @@ func TestSearchTSIDWithTimeRange(t *testing.T) {
 		t.Fatalf("unexpected TotalLabelValuePairs; got %d; want %d", status.TotalLabelValuePairs, expectedLabelValuePairs)
 	}
 
+	// Check GetTSDBStatusOnDateRange with nil filters on the last 3 days.
+	// Every day has its own set of series, so all the series are new and all the series from the previous day disappear.
+	status, err = db.GetTSDBStatusOnDateRange(nil, nil, baseDate-2, baseDate, "", 5, 1e6, noDeadline)
+	if err != nil {
+		t.Fatalf("error in GetTSDBStatusOnDateRange: %s", err)
+	}
+	if !status.hasEntries() {
+		t.Fatalf("expecting non-empty TSDB status")
+	}
+	expectedSeriesCountByMetricName = []TopHeapEntry{
+		{
+			Name:  "testMetric",
+			Count: 3 * metricsPerDay,
+		},
+	}
+	if !reflect.DeepEqual(status.SeriesCountByMetricName, expectedSeriesCountByMetricName) {
+		t.Fatalf("unexpected SeriesCountByMetricName;\ngot\n%v\nwant\n%v", status.SeriesCountByMetricName, expectedSeriesCountByMetricName)
+	}
+	expectedTotalSeries = 3 * metricsPerDay
+	if status.TotalSeries != expectedTotalSeries {
+		t.Fatalf("unexpected TotalSeries; got %d; want %d", status.TotalSeries, expectedTotalSeries)
+	}
+	expectedSeriesCountByDate := []TSDBDateStats{
+		{Date: baseDate - 2, TotalSeries: metricsPerDay, NewSeries: metricsPerDay, DisappearedSeries: metricsPerDay},
+		{Date: baseDate - 1, TotalSeries: metricsPerDay, NewSeries: metricsPerDay, DisappearedSeries: metricsPerDay},
+		{Date: baseDate, TotalSeries: metricsPerDay, NewSeries: metricsPerDay, DisappearedSeries: metricsPerDay},
+	}
+	if !reflect.DeepEqual(status.SeriesCountByDate, expectedSeriesCountByDate) {
+		t.Fatalf("unexpected SeriesCountByDate;\ngot\n%v\nwant\n%v", status.SeriesCountByDate, expectedSeriesCountByDate)
+	}
+
+	// Check GetTSDBStatusOnDateRange with non-nil filter, which matches only 3 series per day
+	status, err = db.GetTSDBStatusOnDateRange(nil, []*TagFilters{tfs}, baseDate-2, baseDate, "", 5, 1e6, noDeadline)
+	if err != nil {
+		t.Fatalf("error in GetTSDBStatusOnDateRange: %s", err)
+	}
+	expectedTotalSeries = 9
+	if status.TotalSeries != expectedTotalSeries {
+		t.Fatalf("unexpected TotalSeries; got %d; want %d", status.TotalSeries, expectedTotalSeries)
+	}
+	expectedSeriesCountByDate = []TSDBDateStats{
+		{Date: baseDate - 2, TotalSeries: 3, NewSeries: 3, DisappearedSeries: 3},
+		{Date: baseDate - 1, TotalSeries: 3, NewSeries: 3, DisappearedSeries: 3},
+		{Date: baseDate, TotalSeries: 3, NewSeries: 3, DisappearedSeries: 3},
+	}
+	if !reflect.DeepEqual(status.SeriesCountByDate, expectedSeriesCountByDate) {
+		t.Fatalf("unexpected SeriesCountByDate;\ngot\n%v\nwant\n%v", status.SeriesCountByDate, expectedSeriesCountByDate)
+	}
+
+	// Check GetTSDBStatusOnDateRange with too small maxMetrics
+	if _, err := db.GetTSDBStatusOnDateRange(nil, nil, baseDate-2, baseDate, "", 5, metricsPerDay, noDeadline); err == nil {
+		t.Fatalf("expecting non-nil error when the number of series exceeds maxMetrics")
+	}
+
 	putIndexDB()
 	s.MustClose()
 	fs.MustRemoveAll(path)
@@ -15,17 +15,23 @@ import (
 // mergeBlockStreams returns immediately if stopCh is closed.
 //
 // rowsMerged is atomically updated with the number of merged rows during the merge.
-func mergeBlockStreams(ph *partHeader, bsw *blockStreamWriter, bsrs []*blockStreamReader, stopCh <-chan struct{}, dmis *uint64set.Set, retentionDeadline int64,
-	rowsMerged, rowsDeleted *atomic.Uint64, useSparseCache bool) error {
+//
+// Samples with timestamps smaller than retentionDeadline are dropped. If getRetentionDeadline isn't nil,
+// then it is used for obtaining per-series retention deadline instead of retentionDeadline.
+//
+// Samples deleted by tss are dropped. ph.TombstoneID is set to the last tombstone id from tss.
+func mergeBlockStreams(ph *partHeader, bsw *blockStreamWriter, bsrs []*blockStreamReader, stopCh <-chan struct{}, dmis *uint64set.Set, tss *tombstones, retentionDeadline int64,
+	getRetentionDeadline func(metricID uint64) int64, rowsMerged, rowsDeleted *atomic.Uint64, useSparseCache bool) error {
 	ph.Reset()
 
 	bsm := bsmPool.Get().(*blockStreamMerger)
-	bsm.Init(bsrs, retentionDeadline, useSparseCache)
-	err := mergeBlockStreamsInternal(ph, bsw, bsm, stopCh, dmis, rowsMerged, rowsDeleted)
+	bsm.Init(bsrs, retentionDeadline, getRetentionDeadline, useSparseCache)
+	err := mergeBlockStreamsInternal(ph, bsw, bsm, stopCh, dmis, tss, rowsMerged, rowsDeleted)
 	bsm.reset()
 	bsmPool.Put(bsm)
 	bsw.MustClose()
 	if err == nil {
+		ph.TombstoneID = tss.lastID()
 		return nil
 	}
 	return fmt.Errorf("cannot merge %d streams: %s: %w", len(bsrs), bsrs, err)
@@ -39,12 +45,13 @@ var bsmPool = &sync.Pool{
 
 var errForciblyStopped = fmt.Errorf("forcibly stopped")
 
-func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *blockStreamMerger, stopCh <-chan struct{}, dmis *uint64set.Set, rowsMerged, rowsDeleted *atomic.Uint64) error {
+func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *blockStreamMerger, stopCh <-chan struct{}, dmis *uint64set.Set, tss *tombstones, rowsMerged, rowsDeleted *atomic.Uint64) error {
 	pendingBlockIsEmpty := true
 	pendingBlock := getBlock()
 	defer putBlock(pendingBlock)
 	tmpBlock := getBlock()
 	defer putBlock(tmpBlock)
+	var deletedTimeRanges []TimeRange
 	for bsm.NextBlock() {
 		select {
 		case <-stopCh:
@@ -63,6 +70,29 @@ func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *bloc
 			rowsDeleted.Add(uint64(b.bh.RowsCount))
 			continue
 		}
+		if b.bh.MinTimestamp < retentionDeadline && bsm.getRetentionDeadlineFunc != nil {
+			// Slow path - drop samples outside the per-series retention from the block,
+			// since the block may be never merged with other blocks.
+			if err := b.UnmarshalData(); err != nil {
+				return fmt.Errorf("cannot unmarshal block outside the retention: %w", err)
+			}
+			skipSamplesOutsideRetention(b, retentionDeadline, rowsDeleted)
+			b.fixupTimestamps()
+		}
+		deletedTimeRanges = tss.appendDeletedTimeRanges(deletedTimeRanges[:0], &b.bh, bsm.getTombstoneID())
+		if len(deletedTimeRanges) > 0 {
+			// Slow path - drop samples deleted by tombstones from the block.
+			if err := b.UnmarshalData(); err != nil {
+				return fmt.Errorf("cannot unmarshal block with deleted samples: %w", err)
+			}
+			n := deleteSamplesOnTimeRanges(b, deletedTimeRanges)
+			rowsDeleted.Add(uint64(n))
+			if b.nextIdx >= len(b.timestamps) {
+				// All the samples in the block are deleted.
+				continue
+			}
+			b.fixupTimestamps()
+		}
 		if pendingBlockIsEmpty {
 			// Load the next block if pendingBlock is empty.
 			pendingBlock.CopyFrom(b)
@@ -0,0 +1,435 @@
+package metricsmetadata
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+// maxRowsPerMetric is the maximum number of distinct metadata rows stored per metric family name.
+//
+// Metrics with the same name may be exposed by distinct targets with distinct metadata,
+// but the number of such variations is usually small.
+const maxRowsPerMetric = 10
+
+// rowOverhead is the approximate in-memory size of a single Row excluding its strings.
+const rowOverhead = 8 + 3*16 + 8 + 8
+
+// Row contains metadata for a single metric family.
+type Row struct {
+	// MetricFamilyName is the name of the metric family.
+	MetricFamilyName string
+
+	// Type is the metric type according to prompb.MetricType.
+	Type uint32
+
+	// Help is the help string for the metric family.
+	Help string
+
+	// Unit is the unit for the metric family.
+	Unit string
+}
+
+func (r *Row) sizeBytes() uint64 {
+	return uint64(len(r.MetricFamilyName)+len(r.Help)+len(r.Unit)) + rowOverhead
+}
+
+func (r *Row) equalMetadata(x *Row) bool {
+	return r.Type == x.Type && r.Help == x.Help && r.Unit == x.Unit
+}
+
+// storedRow is a metadata row stored in Store.
+type storedRow struct {
+	Row
+
+	// lastSeen is the last time in unix seconds when the row was added to the store.
+	lastSeen atomic.Uint64
+}
+
+// persistedRow is used for persisting metadata rows.
+type persistedRow struct {
+	Row
+
+	// LastSeen is the last time in unix seconds when the row was added to the store.
+	LastSeen uint64 `json:",omitempty"`
+}
+
+// Store holds metadata for metric families.
+//
+// The store size is limited by maxSizeBytes. The least recently seen metadata rows are evicted when the limit is reached.
+// Metadata rows, which weren't seen during the retention, are removed in background.
+type Store struct {
+	maxSizeBytes     uint64
+	retentionSeconds uint64
+	path             string
+
+	currentSizeBytes  atomic.Uint64
+	currentItemsCount atomic.Uint64
+	droppedRows       atomic.Uint64
+	evictedRows       atomic.Uint64
+	expiredRows       atomic.Uint64
+
+	// mu protects m
+	mu sync.RWMutex
+
+	// m maps metric family name to distinct metadata rows for it.
+	m map[string][]*storedRow
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// Metrics contains metrics for the Store.
+type Metrics struct {
+	ItemsCount   uint64
+	SizeBytes    uint64
+	MaxSizeBytes uint64
+	DroppedRows  uint64
+	EvictedRows  uint64
+	ExpiredRows  uint64
+}
+
+// expireInterval is the interval for removing metadata rows, which weren't seen during the retention.
+const expireInterval = time.Minute
+
+// evictFraction is the fraction of maxSizeBytes to free on eviction.
+//
+// Rows are evicted in batches in order to amortize the cost of finding the least recently seen rows.
+const evictFraction = 0.1
+
+// MustLoadFrom loads the store from the given path.
+//
+// Metadata rows, which weren't seen during the given retention, are removed. The retention isn't applied if it is zero.
+//
+// An empty store is returned if the path doesn't exist.
+func MustLoadFrom(path string, maxSizeBytes uint64, retention time.Duration) *Store {
+	s, err := loadFrom(path, maxSizeBytes, retention)
+	if err != nil {
+		logger.Fatalf("cannot load metrics metadata from %q: %s", path, err)
+	}
+	if s.retentionSeconds > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.expireWorker()
+		}()
+	}
+	return s
+}
+
+func loadFrom(path string, maxSizeBytes uint64, retention time.Duration) (*Store, error) {
+	s := &Store{
+		maxSizeBytes:     maxSizeBytes,
+		retentionSeconds: uint64(retention.Seconds()),
+		path:             path,
+		m:                make(map[string][]*storedRow),
+		stopCh:           make(chan struct{}),
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("cannot open file: %w", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create gzip reader: %w", err)
+	}
+	d := json.NewDecoder(zr)
+	currentTime := fasttime.UnixTimestamp()
+	s.mu.Lock()
+	for {
+		var pr persistedRow
+		if err := d.Decode(&pr); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			s.mu.Unlock()
+			return nil, fmt.Errorf("cannot parse metadata row: %w", err)
+		}
+		lastSeen := pr.LastSeen
+		if lastSeen == 0 || lastSeen > currentTime {
+			// The row has been saved by the previous version without LastSeen field or the clock has been changed.
+			lastSeen = currentTime
+		}
+		if s.retentionSeconds > 0 && lastSeen+s.retentionSeconds < currentTime {
+			continue
+		}
+		s.addRowLocked(&pr.Row, lastSeen)
+	}
+	s.mu.Unlock()
+	if err := zr.Close(); err != nil {
+		return nil, fmt.Errorf("cannot close gzip reader: %w", err)
+	}
+	return s, nil
+}
+
+func (s *Store) expireWorker() {
+	t := time.NewTicker(expireInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-t.C:
+			s.removeNotSeenSince(fasttime.UnixTimestamp() - s.retentionSeconds)
+		}
+	}
+}
+
+// removeNotSeenSince removes rows, which weren't seen since the given minLastSeen unix timestamp in seconds.
+func (s *Store) removeNotSeenSince(minLastSeen uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.removeRowsLocked(func(sr *storedRow) bool {
+		return sr.lastSeen.Load() < minLastSeen
+	})
+	s.expiredRows.Add(n)
+}
+
+// removeRowsLocked removes rows matching the given f from s and returns the number of removed rows.
+func (s *Store) removeRowsLocked(f func(sr *storedRow) bool) uint64 {
+	removed := uint64(0)
+	for name, rs := range s.m {
+		rsNew := rs[:0]
+		for _, sr := range rs {
+			if !f(sr) {
+				rsNew = append(rsNew, sr)
+				continue
+			}
+			s.currentSizeBytes.Add(^(sr.sizeBytes() - 1))
+			removed++
+		}
+		if len(rsNew) == len(rs) {
+			continue
+		}
+		clear(rs[len(rsNew):])
+		if len(rsNew) == 0 {
+			delete(s.m, name)
+		} else {
+			s.m[name] = rsNew
+		}
+	}
+	s.currentItemsCount.Add(^(removed - 1))
+	return removed
+}
+
+// evictLocked evicts the least recently seen rows from s until it has free space for size bytes plus evictFraction of maxSizeBytes.
+func (s *Store) evictLocked(size uint64) {
+	rs := make([]*storedRow, 0, s.currentItemsCount.Load())
+	for _, x := range s.m {
+		rs = append(rs, x...)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].lastSeen.Load() < rs[j].lastSeen.Load()
+	})
+
+	evictSize := size + uint64(float64(s.maxSizeBytes)*evictFraction)
+	targetSize := uint64(0)
+	if s.maxSizeBytes > evictSize {
+		targetSize = s.maxSizeBytes - evictSize
+	}
+	sizeBytes := s.currentSizeBytes.Load()
+	evict := make(map[*storedRow]struct{})
+	for _, sr := range rs {
+		if sizeBytes <= targetSize {
+			break
+		}
+		evict[sr] = struct{}{}
+		sizeBytes -= sr.sizeBytes()
+	}
+	n := s.removeRowsLocked(func(sr *storedRow) bool {
+		_, ok := evict[sr]
+		return ok
+	})
+	s.evictedRows.Add(n)
+}
+
+// Add adds rows to s.
+//
+// Rows with empty MetricFamilyName are skipped.
+func (s *Store) Add(rows []Row) {
+	if len(rows) == 0 {
+		return
+	}
+	currentTime := fasttime.UnixTimestamp()
+
+	// Fast path - all the rows already exist in s.
+	s.mu.RLock()
+	n := 0
+	for i := range rows {
+		if !s.markRowSeenLocked(&rows[i], currentTime) {
+			break
+		}
+		n++
+	}
+	s.mu.RUnlock()
+	if n == len(rows) {
+		return
+	}
+
+	// Slow path - add missing rows.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range rows[n:] {
+		s.addRowLocked(&rows[n+i], currentTime)
+	}
+}
+
+// markRowSeenLocked updates the last seen time for r in s and returns true if r exists in s.
+//
+// It returns true for rows with empty MetricFamilyName, since such rows must be skipped.
+func (s *Store) markRowSeenLocked(r *Row, currentTime uint64) bool {
+	if r.MetricFamilyName == "" {
+		return true
+	}
+	for _, sr := range s.m[r.MetricFamilyName] {
+		if sr.equalMetadata(r) {
+			if sr.lastSeen.Load() < currentTime {
+				sr.lastSeen.Store(currentTime)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) addRowLocked(r *Row, lastSeen uint64) {
+	if s.markRowSeenLocked(r, lastSeen) {
+		return
+	}
+	size := r.sizeBytes()
+	if s.currentSizeBytes.Load()+size > s.maxSizeBytes {
+		s.evictLocked(size)
+		if s.currentSizeBytes.Load()+size > s.maxSizeBytes {
+			s.droppedRows.Add(1)
+			return
+		}
+	}
+	rs := s.m[r.MetricFamilyName]
+	if len(rs) >= maxRowsPerMetric {
+		// Drop the oldest row for the given metric family.
+		s.currentSizeBytes.Add(^(rs[0].sizeBytes() - 1))
+		s.currentItemsCount.Add(^uint64(0))
+		rs = append(rs[:0], rs[1:]...)
+	}
+	sr := &storedRow{
+		Row: Row{
+			MetricFamilyName: strings.Clone(r.MetricFamilyName),
+			Type:             r.Type,
+			Help:             strings.Clone(r.Help),
+			Unit:             strings.Clone(r.Unit),
+		},
+	}
+	sr.lastSeen.Store(lastSeen)
+	rs = append(rs, sr)
+	s.m[sr.MetricFamilyName] = rs
+	s.currentSizeBytes.Add(size)
+	s.currentItemsCount.Add(1)
+}
+
+// Get appends metadata rows to dst and returns the result.
+//
+// If metricName isn't empty, then only rows for the given metric family name are returned.
+// If limit > 0, then rows for up to limit metric families are returned.
+// If limitPerMetric > 0, then up to limitPerMetric rows are returned per each metric family.
+//
+// The returned rows are sorted by MetricFamilyName.
+func (s *Store) Get(dst []Row, metricName string, limit, limitPerMetric int) []Row {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var names []string
+	if metricName != "" {
+		if _, ok := s.m[metricName]; ok {
+			names = append(names, metricName)
+		}
+	} else {
+		names = make([]string, 0, len(s.m))
+		for name := range s.m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if limit > 0 && len(names) > limit {
+		names = names[:limit]
+	}
+	for _, name := range names {
+		rs := s.m[name]
+		if limitPerMetric > 0 && len(rs) > limitPerMetric {
+			rs = rs[:limitPerMetric]
+		}
+		for _, sr := range rs {
+			dst = append(dst, sr.Row)
+		}
+	}
+	return dst
+}
+
+// UpdateMetrics updates m with metrics from s.
+func (s *Store) UpdateMetrics(m *Metrics) {
+	m.ItemsCount = s.currentItemsCount.Load()
+	m.SizeBytes = s.currentSizeBytes.Load()
+	m.MaxSizeBytes = s.maxSizeBytes
+	m.DroppedRows = s.droppedRows.Load()
+	m.EvictedRows = s.evictedRows.Load()
+	m.ExpiredRows = s.expiredRows.Load()
+}
+
+// MustSave saves s to the path it was loaded from.
+func (s *Store) MustSave() {
+	s.mu.RLock()
+	rows := make([]persistedRow, 0, s.currentItemsCount.Load())
+	for _, rs := range s.m {
+		for _, sr := range rs {
+			rows = append(rows, persistedRow{
+				Row:      sr.Row,
+				LastSeen: sr.lastSeen.Load(),
+			})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].MetricFamilyName < rows[j].MetricFamilyName
+	})
+
+	var bb strings.Builder
+	zw := gzip.NewWriter(&bb)
+	e := json.NewEncoder(zw)
+	for i := range rows {
+		if err := e.Encode(&rows[i]); err != nil {
+			logger.Panicf("BUG: cannot marshal metadata row: %s", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		logger.Panicf("BUG: cannot close gzip writer: %s", err)
+	}
+	fs.MustWriteAtomic(s.path, []byte(bb.String()), true)
+}
+
+// MustClose saves s to the path it was loaded from.
+//
+// s mustn't be used after MustClose call.
+func (s *Store) MustClose() {
+	close(s.stopCh)
+	s.wg.Wait()
+
+	s.MustSave()
+}
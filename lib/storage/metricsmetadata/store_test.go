@@ -0,0 +1,132 @@
+package metricsmetadata
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+)
+
+func TestStoreAddGet(t *testing.T) {
+	s := MustLoadFrom(filepath.Join(t.TempDir(), "metadata"), 1e6, 0)
+
+	s.Add([]Row{
+		{MetricFamilyName: "foo", Type: 1, Help: "foo help"},
+		{MetricFamilyName: "bar", Type: 2, Help: "bar help", Unit: "seconds"},
+		{MetricFamilyName: "foo", Type: 1, Help: "foo help"},
+		{MetricFamilyName: "foo", Type: 1, Help: "another foo help"},
+		{MetricFamilyName: "", Type: 1, Help: "must be skipped"},
+	})
+
+	f := func(metricName string, limit, limitPerMetric int, rowsExpected []Row) {
+		t.Helper()
+		rows := s.Get(nil, metricName, limit, limitPerMetric)
+		if !reflect.DeepEqual(rows, rowsExpected) {
+			t.Fatalf("unexpected rows\ngot\n%v\nwant\n%v", rows, rowsExpected)
+		}
+	}
+
+	allRows := []Row{
+		{MetricFamilyName: "bar", Type: 2, Help: "bar help", Unit: "seconds"},
+		{MetricFamilyName: "foo", Type: 1, Help: "foo help"},
+		{MetricFamilyName: "foo", Type: 1, Help: "another foo help"},
+	}
+	f("", 0, 0, allRows)
+	f("foo", 0, 0, allRows[1:])
+	f("foo", 0, 1, allRows[1:2])
+	f("", 1, 0, allRows[:1])
+	f("missing", 0, 0, nil)
+
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.ItemsCount != 3 {
+		t.Fatalf("unexpected ItemsCount; got %d; want 3", m.ItemsCount)
+	}
+
+	// Verify the store is restored after MustClose
+	s.MustClose()
+	s = MustLoadFrom(s.path, 1e6, 0)
+	f("", 0, 0, allRows)
+}
+
+func TestStoreMaxSize(t *testing.T) {
+	s := MustLoadFrom(filepath.Join(t.TempDir(), "metadata"), 100, 0)
+	defer s.MustClose()
+
+	// Too big row must be dropped
+	s.Add([]Row{
+		{MetricFamilyName: "foo", Type: 1, Help: strings.Repeat("x", 100)},
+	})
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.ItemsCount != 0 {
+		t.Fatalf("unexpected ItemsCount; got %d; want 0", m.ItemsCount)
+	}
+	if m.DroppedRows != 1 {
+		t.Fatalf("unexpected DroppedRows; got %d; want 1", m.DroppedRows)
+	}
+
+	// The least recently seen row must be evicted
+	s.Add([]Row{
+		{MetricFamilyName: "foo", Type: 1},
+	})
+	s.m["foo"][0].lastSeen.Store(1)
+	s.Add([]Row{
+		{MetricFamilyName: "bar", Type: 1},
+	})
+	s.UpdateMetrics(&m)
+	if m.ItemsCount != 1 {
+		t.Fatalf("unexpected ItemsCount; got %d; want 1", m.ItemsCount)
+	}
+	if m.EvictedRows != 1 {
+		t.Fatalf("unexpected EvictedRows; got %d; want 1", m.EvictedRows)
+	}
+	if m.SizeBytes > m.MaxSizeBytes {
+		t.Fatalf("SizeBytes=%d exceeds MaxSizeBytes=%d", m.SizeBytes, m.MaxSizeBytes)
+	}
+	rows := s.Get(nil, "", 0, 0)
+	rowsExpected := []Row{{MetricFamilyName: "bar", Type: 1}}
+	if !reflect.DeepEqual(rows, rowsExpected) {
+		t.Fatalf("unexpected rows\ngot\n%v\nwant\n%v", rows, rowsExpected)
+	}
+}
+
+func TestStoreRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata")
+	s := MustLoadFrom(path, 1e6, time.Hour)
+
+	s.Add([]Row{
+		{MetricFamilyName: "foo", Type: 1},
+		{MetricFamilyName: "bar", Type: 1},
+	})
+
+	// Rows, which weren't seen since the given time, must be removed
+	currentTime := fasttime.UnixTimestamp()
+	s.m["foo"][0].lastSeen.Store(currentTime - 7200)
+	s.removeNotSeenSince(currentTime - 3600)
+	rows := s.Get(nil, "", 0, 0)
+	rowsExpected := []Row{{MetricFamilyName: "bar", Type: 1}}
+	if !reflect.DeepEqual(rows, rowsExpected) {
+		t.Fatalf("unexpected rows\ngot\n%v\nwant\n%v", rows, rowsExpected)
+	}
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.ItemsCount != 1 {
+		t.Fatalf("unexpected ItemsCount; got %d; want 1", m.ItemsCount)
+	}
+	if m.ExpiredRows != 1 {
+		t.Fatalf("unexpected ExpiredRows; got %d; want 1", m.ExpiredRows)
+	}
+
+	// Rows outside the retention must be skipped when loading the store
+	s.m["bar"][0].lastSeen.Store(currentTime - 7200)
+	s.MustClose()
+	s = MustLoadFrom(path, 1e6, time.Hour)
+	if rows := s.Get(nil, "", 0, 0); len(rows) != 0 {
+		t.Fatalf("unexpected rows outside the retention: %v", rows)
+	}
+	s.MustClose()
+}
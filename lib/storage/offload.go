@@ -0,0 +1,607 @@
+package storage
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/filestream"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/cespare/xxhash/v2"
+)
+
+// remoteChunkSize is the size of chunks data files of offloaded parts are split into at remote storage.
+//
+// Every chunk is downloaded and cached at once, so the chunk size is a trade-off between the number
+// of objects at remote storage and the amount of data to download for reading a single block.
+const remoteChunkSize = 4 * 1024 * 1024
+
+// maxRemoteChunkDownloadAttempts is the maximum number of attempts to download a chunk from remote storage.
+const maxRemoteChunkDownloadAttempts = 5
+
+// remoteChunkDownloadRetryInterval is the base interval between attempts to download a chunk from remote storage.
+//
+// It is overridden in tests.
+var remoteChunkDownloadRetryInterval = time.Second
+
+// errRemoteChunkDownload is returned when a chunk cannot be downloaded from remote storage.
+//
+// The remote storage may be temporarily unavailable, so the operation can be retried later.
+var errRemoteChunkDownload = errors.New("cannot download offloaded data from remote storage")
+
+// offloadCheckInterval is the interval for checking whether there are parts to offload to remote storage.
+var offloadCheckInterval = 10 * time.Minute
+
+// offloadedPartInfo describes a part with data files offloaded to remote storage.
+//
+// It is stored in offloadedPartFilename inside the part directory.
+// The index.bin, metaindex.bin and metadata.json files of the offloaded part remain on local disk,
+// while timestamps.bin and values.bin are read from remote storage via local cache.
+type offloadedPartInfo struct {
+	// RemotePath is the path to the part directory at remote storage.
+	RemotePath string
+
+	// TimestampsSize is the size of timestamps.bin file in bytes.
+	TimestampsSize uint64
+
+	// ValuesSize is the size of values.bin file in bytes.
+	ValuesSize uint64
+}
+
+func mustReadOffloadedPartInfo(partPath string) *offloadedPartInfo {
+	path := filepath.Join(partPath, offloadedPartFilename)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.Panicf("FATAL: cannot read %q: %s", path, err)
+	}
+	var opi offloadedPartInfo
+	if err := json.Unmarshal(data, &opi); err != nil {
+		logger.Panicf("FATAL: cannot parse %q: %s", path, err)
+	}
+	return &opi
+}
+
+func mustWriteOffloadedPartInfo(partPath string, opi *offloadedPartInfo) {
+	data, err := json.Marshal(opi)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal offloaded part info: %s", err)
+	}
+	path := filepath.Join(partPath, offloadedPartFilename)
+	fs.MustWriteAtomic(path, data, true)
+}
+
+func isOffloadedPart(partPath string) bool {
+	return fs.IsPathExist(filepath.Join(partPath, offloadedPartFilename))
+}
+
+// mustRemoveOffloadedLocalFiles removes local copies of data files for the offloaded part at partPath.
+func mustRemoveOffloadedLocalFiles(partPath string) {
+	removed := false
+	for _, filename := range []string{timestampsFilename, valuesFilename} {
+		path := filepath.Join(partPath, filename)
+		if fs.IsPathExist(path) {
+			fs.MustRemoveAll(path)
+			removed = true
+		}
+	}
+	if removed {
+		fs.MustSyncPath(partPath)
+	}
+}
+
+// offloader offloads data files for old parts to remote storage and reads them back.
+type offloader struct {
+	// fs is the remote storage for offloaded data.
+	fs common.RemoteFS
+
+	// offloadAfter is the age of partitions, which must be offloaded to fs.
+	offloadAfter time.Duration
+
+	// tablePath is the path to the table with parts. Remote paths for parts are relative to tablePath.
+	tablePath string
+
+	// cache is local cache for chunks read from fs.
+	cache *remoteChunksCache
+
+	uploadedBytes    atomic.Uint64
+	downloadedBytes  atomic.Uint64
+	downloadErrors   atomic.Uint64
+	downloadFailures atomic.Uint64
+	deletedChunks    atomic.Uint64
+}
+
+func newOffloader(remoteFS common.RemoteFS, offloadAfter time.Duration, tablePath, cachePath string, cacheSizeBytes uint64) *offloader {
+	return &offloader{
+		fs:           remoteFS,
+		offloadAfter: offloadAfter,
+		tablePath:    tablePath,
+		cache:        mustOpenRemoteChunksCache(cachePath, cacheSizeBytes),
+	}
+}
+
+// offloaderMetrics contains metrics for the offloader.
+type offloaderMetrics struct {
+	OffloadUploadedBytes    uint64
+	OffloadDownloadedBytes  uint64
+	OffloadDownloadErrors   uint64
+	OffloadDownloadFailures uint64
+	OffloadDeletedChunks    uint64
+
+	OffloadCacheSize         uint64
+	OffloadCacheSizeBytes    uint64
+	OffloadCacheSizeMaxBytes uint64
+	OffloadCacheRequests     uint64
+	OffloadCacheMisses       uint64
+}
+
+func (o *offloader) UpdateMetrics(m *offloaderMetrics) {
+	m.OffloadUploadedBytes += o.uploadedBytes.Load()
+	m.OffloadDownloadedBytes += o.downloadedBytes.Load()
+	m.OffloadDownloadErrors += o.downloadErrors.Load()
+	m.OffloadDownloadFailures += o.downloadFailures.Load()
+	m.OffloadDeletedChunks += o.deletedChunks.Load()
+
+	c := o.cache
+	c.mu.Lock()
+	m.OffloadCacheSize += uint64(len(c.m))
+	m.OffloadCacheSizeBytes += c.sizeBytes
+	c.mu.Unlock()
+	m.OffloadCacheSizeMaxBytes += c.maxSizeBytes
+	m.OffloadCacheRequests += c.requests.Load()
+	m.OffloadCacheMisses += c.misses.Load()
+}
+
+// getRemotePartPath returns the path to the part at partPath on remote storage.
+func (o *offloader) getRemotePartPath(partPath string) string {
+	relPath, err := filepath.Rel(o.tablePath, partPath)
+	if err != nil {
+		logger.Panicf("BUG: cannot obtain relative path for %q from %q: %s", partPath, o.tablePath, err)
+	}
+	return common.ToCanonicalPath(relPath)
+}
+
+// uploadPart uploads data files for the part at partPath to remote storage.
+func (o *offloader) uploadPart(partPath string) (*offloadedPartInfo, error) {
+	opi := &offloadedPartInfo{
+		RemotePath: o.getRemotePartPath(partPath),
+	}
+	var err error
+	opi.TimestampsSize, err = o.uploadFile(partPath, opi.RemotePath, timestampsFilename)
+	if err != nil {
+		return nil, err
+	}
+	opi.ValuesSize, err = o.uploadFile(partPath, opi.RemotePath, valuesFilename)
+	if err != nil {
+		return nil, err
+	}
+	return opi, nil
+}
+
+func (o *offloader) uploadFile(partPath, remotePartPath, filename string) (uint64, error) {
+	localPath := filepath.Join(partPath, filename)
+	f, err := os.Open(localPath)
+	if err != nil {
+		return 0, fmt.Errorf("cannot open %q: %w", localPath, err)
+	}
+	defer f.Close()
+
+	fileSize := fs.MustFileSize(localPath)
+	remotePath := path.Join(remotePartPath, filename)
+	for offset := uint64(0); offset < fileSize; offset += remoteChunkSize {
+		p := getRemoteChunk(remotePath, fileSize, offset)
+		r := io.NewSectionReader(f, int64(p.Offset), int64(p.Size))
+		if err := o.fs.UploadPart(p, r); err != nil {
+			return 0, fmt.Errorf("cannot upload %s to %s: %w", &p, o.fs, err)
+		}
+		o.uploadedBytes.Add(p.Size)
+	}
+	return fileSize, nil
+}
+
+// downloadChunk downloads the chunk p from remote storage.
+//
+// It retries the download on errors. The returned error wraps errRemoteChunkDownload,
+// so the caller could fail the query or retry the merge later.
+func (o *offloader) downloadChunk(p *common.Part) ([]byte, error) {
+	var bb bytes.Buffer
+	bb.Grow(int(p.Size))
+	for attempt := 1; ; attempt++ {
+		bb.Reset()
+		err := o.fs.DownloadPart(*p, &bb)
+		if err == nil {
+			o.downloadedBytes.Add(p.Size)
+			return bb.Bytes(), nil
+		}
+		o.downloadErrors.Add(1)
+		if attempt >= maxRemoteChunkDownloadAttempts {
+			o.downloadFailures.Add(1)
+			return nil, fmt.Errorf("%w: cannot download %s from %s after %d attempts: %w", errRemoteChunkDownload, p, o.fs, attempt, err)
+		}
+		d := time.Duration(attempt) * remoteChunkDownloadRetryInterval
+		logger.Warnf("cannot download %s from %s: %s; retrying in %s", p, o.fs, err, d)
+		time.Sleep(d)
+	}
+}
+
+// removeOrphanedChunks removes chunks for parts, which are no longer offloaded, from remote storage.
+//
+// Such chunks appear after merging offloaded parts, after dropping partitions outside the retention
+// and after unclean shutdown during the upload. Chunks referenced by snapshots are preserved,
+// since snapshots contain only references to the offloaded data.
+func (o *offloader) removeOrphanedChunks() error {
+	ps, err := o.fs.ListParts()
+	if err != nil {
+		return fmt.Errorf("cannot list chunks at %s: %w", o.fs, err)
+	}
+	deleted := 0
+	for _, p := range ps {
+		if p.ActualSize == p.Size && o.isReferencedPart(path.Dir(p.Path)) {
+			continue
+		}
+		if err := o.fs.DeletePart(p); err != nil {
+			return fmt.Errorf("cannot delete %s from %s: %w", &p, o.fs, err)
+		}
+		o.deletedChunks.Add(1)
+		deleted++
+	}
+	if deleted == 0 {
+		return nil
+	}
+	if err := o.fs.RemoveEmptyDirs(); err != nil {
+		return fmt.Errorf("cannot remove empty directories at %s: %w", o.fs, err)
+	}
+	return nil
+}
+
+// isReferencedPart returns true if the offloaded part at remotePartPath is referenced by a local part.
+//
+// The part may belong either to an attached partition or to a detached partition.
+func (o *offloader) isReferencedPart(remotePartPath string) bool {
+	partPath := filepath.Join(o.tablePath, filepath.FromSlash(remotePartPath))
+	if isOffloadedPart(partPath) {
+		return true
+	}
+
+	// remotePartPath has the form `(small|big)/partitionName/partName`,
+	// while detached parts are located at `detached/partitionName/(small|big)/partName`.
+	a := strings.Split(remotePartPath, "/")
+	if len(a) != 3 {
+		return false
+	}
+	detachedPartPath := filepath.Join(o.tablePath, detachedDirname, a[1], a[0], a[2])
+	if isOffloadedPart(detachedPartPath) {
+		return true
+	}
+
+	// Snapshot parts are located at `(small|big)/snapshots/snapshotName/partitionName/partName`.
+	snapshotsPath := filepath.Join(o.tablePath, a[0], snapshotsDirname)
+	if !fs.IsPathExist(snapshotsPath) {
+		return false
+	}
+	for _, de := range fs.MustReadDir(snapshotsPath) {
+		if !fs.IsDirOrSymlink(de) {
+			continue
+		}
+		snapshotPartPath := filepath.Join(snapshotsPath, de.Name(), a[1], a[2])
+		if isOffloadedPart(snapshotPartPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *offloader) newReaderAt(remotePartPath, filename string, fileSize uint64) *remoteReaderAt {
+	return &remoteReaderAt{
+		o:        o,
+		path:     path.Join(remotePartPath, filename),
+		fileSize: fileSize,
+	}
+}
+
+// getRemoteChunk returns the chunk starting at the given offset for the file with the given remotePath and fileSize.
+func getRemoteChunk(remotePath string, fileSize, offset uint64) common.Part {
+	return common.Part{
+		Path:     remotePath,
+		FileSize: fileSize,
+		Offset:   offset,
+		Size:     min(remoteChunkSize, fileSize-offset),
+	}
+}
+
+// remoteReaderAt implements fs.MustReadAtCloser for data files of offloaded parts.
+type remoteReaderAt struct {
+	o *offloader
+
+	// path is the path to the file at remote storage.
+	path string
+
+	fileSize uint64
+}
+
+// Path returns the path to r at remote storage.
+func (r *remoteReaderAt) Path() string {
+	return fmt.Sprintf("%s at %s", r.path, r.o.fs)
+}
+
+// MustReadAt reads len(p) bytes at off from r.
+//
+// It panics if the data cannot be downloaded from remote storage. Use ReadAt for handling such errors.
+func (r *remoteReaderAt) MustReadAt(p []byte, off int64) {
+	if err := r.ReadAt(p, off); err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+}
+
+// ReadAt reads len(p) bytes at off from r.
+func (r *remoteReaderAt) ReadAt(p []byte, off int64) error {
+	if len(p) == 0 {
+		return nil
+	}
+	if off < 0 || uint64(off)+uint64(len(p)) > r.fileSize {
+		logger.Panicf("BUG: cannot read %d bytes at offset %d from %s with size %d", len(p), off, r.Path(), r.fileSize)
+	}
+	for len(p) > 0 {
+		chunkOffset := uint64(off) / remoteChunkSize * remoteChunkSize
+		rc := getRemoteChunk(r.path, r.fileSize, chunkOffset)
+		n, err := r.o.cache.readAt(p, uint64(off)-chunkOffset, &rc, r.o.downloadChunk)
+		if err != nil {
+			return err
+		}
+		p = p[n:]
+		off += int64(n)
+	}
+	return nil
+}
+
+// readPartDataAt reads len(dst) bytes at off from the data file r of a part.
+//
+// Errors are returned only for offloaded parts, since their data is read from remote storage.
+func readPartDataAt(r fs.MustReadAtCloser, dst []byte, off int64) error {
+	if rr, ok := r.(*remoteReaderAt); ok {
+		return rr.ReadAt(dst, off)
+	}
+	r.MustReadAt(dst, off)
+	return nil
+}
+
+// MustClose closes r.
+func (r *remoteReaderAt) MustClose() {
+	// Nothing to do
+}
+
+// remoteStreamReader implements filestream.ReadCloser for data files of offloaded parts.
+//
+// It reads chunks directly from remote storage without polluting the local cache,
+// since it is used for merging offloaded parts, which are deleted after the merge.
+type remoteStreamReader struct {
+	r *remoteReaderAt
+
+	// offset is the offset of the next chunk to read.
+	offset uint64
+
+	buf       []byte
+	bufOffset int
+}
+
+// Path returns the path to sr at remote storage.
+func (sr *remoteStreamReader) Path() string {
+	return sr.r.Path()
+}
+
+// Read reads up to len(p) bytes from sr to p.
+func (sr *remoteStreamReader) Read(p []byte) (int, error) {
+	if sr.bufOffset >= len(sr.buf) {
+		if sr.offset >= sr.r.fileSize {
+			return 0, io.EOF
+		}
+		rc := getRemoteChunk(sr.r.path, sr.r.fileSize, sr.offset)
+		buf, err := sr.r.o.downloadChunk(&rc)
+		if err != nil {
+			return 0, err
+		}
+		sr.buf = buf
+		sr.bufOffset = 0
+		sr.offset += rc.Size
+	}
+	n := copy(p, sr.buf[sr.bufOffset:])
+	sr.bufOffset += n
+	return n, nil
+}
+
+// MustClose closes sr.
+func (sr *remoteStreamReader) MustClose() {
+	sr.buf = nil
+}
+
+// readPartData reads len(dst) bytes from the data file r of a part.
+//
+// Errors are returned only for offloaded parts, since their data is read from remote storage.
+func readPartData(r filestream.ReadCloser, dst []byte) error {
+	if sr, ok := r.(*remoteStreamReader); ok {
+		if _, err := io.ReadFull(sr, dst); err != nil {
+			return fmt.Errorf("cannot read %d bytes from %s: %w", len(dst), sr.Path(), err)
+		}
+		return nil
+	}
+	fs.MustReadData(r, dst)
+	return nil
+}
+
+// remoteChunksCache is on-disk LRU cache for chunks downloaded from remote storage.
+type remoteChunksCache struct {
+	dir          string
+	maxSizeBytes uint64
+
+	requests atomic.Uint64
+	misses   atomic.Uint64
+
+	// mu protects m, lru and sizeBytes.
+	mu sync.Mutex
+
+	// m maps file names for cached chunks to lru entries.
+	m map[string]*list.Element
+
+	// lru contains *cachedChunk items ordered by the last access time. The front item is the most recently accessed one.
+	lru *list.List
+
+	sizeBytes uint64
+}
+
+type cachedChunk struct {
+	name string
+	size uint64
+}
+
+func mustOpenRemoteChunksCache(dir string, maxSizeBytes uint64) *remoteChunksCache {
+	fs.MustMkdirIfNotExist(dir)
+	c := &remoteChunksCache{
+		dir:          dir,
+		maxSizeBytes: maxSizeBytes,
+		m:            make(map[string]*list.Element),
+		lru:          list.New(),
+	}
+
+	// Register chunks left from the previous run, so they could be reused.
+	type chunkFile struct {
+		cachedChunk
+		modTime time.Time
+	}
+	var cfs []chunkFile
+	for _, de := range fs.MustReadDir(dir) {
+		name := de.Name()
+		if de.IsDir() || fs.IsTemporaryFileName(name) {
+			fs.MustRemoveAll(filepath.Join(dir, name))
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			logger.Panicf("FATAL: cannot stat %q: %s", filepath.Join(dir, name), err)
+		}
+		cfs = append(cfs, chunkFile{
+			cachedChunk: cachedChunk{
+				name: name,
+				size: uint64(fi.Size()),
+			},
+			modTime: fi.ModTime(),
+		})
+	}
+	sort.Slice(cfs, func(i, j int) bool {
+		return cfs[i].modTime.Before(cfs[j].modTime)
+	})
+	c.mu.Lock()
+	for i := range cfs {
+		cc := cfs[i].cachedChunk
+		c.m[cc.name] = c.lru.PushFront(&cc)
+		c.sizeBytes += cc.size
+	}
+	c.evictLocked()
+	c.mu.Unlock()
+
+	return c
+}
+
+// readAt reads up to len(dst) bytes at the offset off of the chunk rc to dst and returns the number of read bytes.
+//
+// The chunk is downloaded with the download func if it is missing in c.
+func (c *remoteChunksCache) readAt(dst []byte, off uint64, rc *common.Part, download func(p *common.Part) ([]byte, error)) (int, error) {
+	n := min(uint64(len(dst)), rc.Size-off)
+	dst = dst[:n]
+
+	c.requests.Add(1)
+	name := fmt.Sprintf("%016X", xxhash.Sum64String(rc.RemotePath("")))
+	chunkPath := filepath.Join(c.dir, name)
+
+	c.mu.Lock()
+	e := c.m[name]
+	if e != nil {
+		c.lru.MoveToFront(e)
+	}
+	c.mu.Unlock()
+
+	if e != nil && readFileAt(chunkPath, dst, off) {
+		return int(n), nil
+	}
+
+	// Slow path - download the chunk and store it in the cache.
+	c.misses.Add(1)
+	data, err := download(rc)
+	if err != nil {
+		return 0, err
+	}
+	copy(dst, data[off:])
+	c.put(name, chunkPath, data)
+	return int(n), nil
+}
+
+func (c *remoteChunksCache) put(name, chunkPath string, data []byte) {
+	size := uint64(len(data))
+	if size > c.maxSizeBytes {
+		// The cache is too small for storing the chunk.
+		return
+	}
+
+	// Write the chunk outside the lock, so concurrent readers aren't blocked during the write.
+	// Concurrent writes for the same chunk are safe, since MustWriteAtomic uses distinct temporary files.
+	fs.MustWriteAtomic(chunkPath, data, true)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.m[name] != nil {
+		// The chunk has been already cached by concurrent goroutine.
+		return
+	}
+	c.m[name] = c.lru.PushFront(&cachedChunk{
+		name: name,
+		size: size,
+	})
+	c.sizeBytes += size
+	c.evictLocked()
+}
+
+func (c *remoteChunksCache) evictLocked() {
+	for c.sizeBytes > c.maxSizeBytes {
+		e := c.lru.Back()
+		cc := c.lru.Remove(e).(*cachedChunk)
+		delete(c.m, cc.name)
+		c.sizeBytes -= cc.size
+		// The chunk file may be still read by concurrent goroutines. This is OK, since the opened file
+		// remains readable after the removal. Goroutines, which didn't open the file yet, will download it again.
+		if err := os.Remove(filepath.Join(c.dir, cc.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Errorf("cannot remove cached chunk: %s", err)
+		}
+	}
+}
+
+// readFileAt reads len(dst) bytes at the offset off from the file at path.
+//
+// It returns false if the file cannot be read, e.g. if it has been evicted from the cache.
+func readFileAt(path string, dst []byte, off uint64) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Errorf("cannot open cached chunk: %s", err)
+		}
+		return false
+	}
+	defer f.Close()
+
+	if _, err := f.ReadAt(dst, int64(off)); err != nil {
+		logger.Errorf("cannot read %d bytes at offset %d from cached chunk %q: %s", len(dst), off, path, err)
+		return false
+	}
+	return true
+}
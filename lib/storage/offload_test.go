@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/fsremote"
+)
+
+func TestStorageOffload(t *testing.T) {
+	defer testRemoveAll(t)
+
+	remoteFS := &fsremote.FS{
+		Dir: t.TempDir(),
+	}
+	opts := OpenOptions{
+		Retention:        365 * 24 * time.Hour,
+		OffloadFS:        remoteFS,
+		OffloadAfter:     31 * 24 * time.Hour,
+		OffloadCacheSize: 1024 * 1024,
+	}
+	s := MustOpenStorage(t.Name(), opts)
+
+	const rowsPerBatch = 100
+	now := time.Now().UnixMilli()
+	oldTimestamp := now - 90*24*3600*1000
+	var mrs []MetricRow
+	addRows := func(timestamp int64) {
+		var batch []MetricRow
+		for i := 0; i < rowsPerBatch; i++ {
+			mn := MetricName{
+				MetricGroup: []byte("metric"),
+				Tags: []Tag{
+					{
+						Key:   []byte("series"),
+						Value: []byte(fmt.Sprintf("%d", i%10)),
+					},
+				},
+			}
+			batch = append(batch, MetricRow{
+				MetricNameRaw: mn.marshalRaw(nil),
+				Timestamp:     timestamp + int64(i)*1000,
+				Value:         float64(i),
+			})
+		}
+		s.AddRows(batch, 64)
+		s.DebugFlush()
+		mrs = append(mrs, batch...)
+	}
+
+	// Create multiple parts in the old partition, so they could be merged later.
+	addRows(oldTimestamp)
+	addRows(oldTimestamp + 3600*1000)
+	addRows(now - 3600*1000)
+
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("metric"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	tr := TimeRange{
+		MinTimestamp: oldTimestamp,
+		MaxTimestamp: now,
+	}
+	assertSearch := func(s *Storage) {
+		t.Helper()
+		if err := testAssertSearchResult(s, tr, tfs, mrs); err != nil {
+			t.Fatalf("unexpected search result: %s", err)
+		}
+	}
+	assertOffloaded := func(s *Storage) {
+		t.Helper()
+
+		var m Metrics
+		s.UpdateMetrics(&m)
+		if m.TableMetrics.OffloadedPartsCount == 0 {
+			t.Fatalf("expecting non-zero offloaded parts")
+		}
+		if m.TableMetrics.OffloadedSizeBytes == 0 {
+			t.Fatalf("expecting non-zero offloaded size")
+		}
+
+		// Local data files for the offloaded partition must be removed.
+		oldPartitionName := timestampToPartitionName(oldTimestamp)
+		err := filepath.WalkDir(filepath.Join(t.Name(), dataDirname), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Name() != valuesFilename {
+				return nil
+			}
+			if filepath.Base(filepath.Dir(filepath.Dir(p))) == oldPartitionName {
+				return fmt.Errorf("unexpected local data file for the offloaded partition: %q", p)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// All the remote chunks must belong to the offloaded parts.
+		ps, err := remoteFS.ListParts()
+		if err != nil {
+			t.Fatalf("cannot list remote parts: %s", err)
+		}
+		if len(ps) == 0 {
+			t.Fatalf("expecting non-empty list of remote parts")
+		}
+		for _, p := range ps {
+			partPath := filepath.Join(t.Name(), dataDirname, filepath.FromSlash(path.Dir(p.Path)))
+			if !isOffloadedPart(partPath) {
+				t.Fatalf("unexpected orphaned remote part %s", &p)
+			}
+		}
+	}
+
+	s.tb.offloadPartitions()
+	assertOffloaded(s)
+	assertSearch(s)
+
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.OffloadUploadedBytes == 0 {
+		t.Fatalf("expecting non-zero uploaded bytes")
+	}
+	if m.OffloadCacheMisses == 0 || m.OffloadDownloadedBytes == 0 {
+		t.Fatalf("expecting data to be downloaded from remote storage; cache misses: %d, downloaded bytes: %d", m.OffloadCacheMisses, m.OffloadDownloadedBytes)
+	}
+	if m.OffloadCacheSizeBytes == 0 {
+		t.Fatalf("expecting non-empty cache for offloaded data")
+	}
+
+	// Verify that offloaded data is readable after the restart.
+	s.MustClose()
+	s = MustOpenStorage(t.Name(), opts)
+	assertSearch(s)
+
+	// Merge the offloaded parts and offload the resulting part.
+	// Chunks for the merged parts must be preserved while they are referenced by a snapshot.
+	snapshotName := s.MustCreateSnapshot()
+	remotePartsBeforeMerge, err := remoteFS.ListParts()
+	if err != nil {
+		t.Fatalf("cannot list remote parts: %s", err)
+	}
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+	assertSearch(s)
+	s.tb.offloadPartitions()
+	assertSearch(s)
+
+	s.UpdateMetrics(&m)
+	if m.OffloadDeletedChunks != 0 {
+		t.Fatalf("unexpected deleted chunks referenced by the snapshot: %d", m.OffloadDeletedChunks)
+	}
+	remotePartsAfterMerge, err := remoteFS.ListParts()
+	if err != nil {
+		t.Fatalf("cannot list remote parts: %s", err)
+	}
+	remoteChunks := make(map[string]bool, len(remotePartsAfterMerge))
+	for _, p := range remotePartsAfterMerge {
+		remoteChunks[p.String()] = true
+	}
+	for _, p := range remotePartsBeforeMerge {
+		if !remoteChunks[p.String()] {
+			t.Fatalf("missing remote chunk %s referenced by the snapshot", &p)
+		}
+	}
+
+	// Chunks for the merged parts must be removed from remote storage after the snapshot is deleted.
+	if err := s.DeleteSnapshot(snapshotName); err != nil {
+		t.Fatalf("cannot delete snapshot %q: %s", snapshotName, err)
+	}
+	s.tb.offloadPartitions()
+	assertOffloaded(s)
+	assertSearch(s)
+
+	s.UpdateMetrics(&m)
+	if m.OffloadDeletedChunks == 0 {
+		t.Fatalf("expecting non-zero deleted chunks after merging offloaded parts")
+	}
+	s.MustClose()
+}
+
+func TestOffloaderDownloadChunkFailure(t *testing.T) {
+	defer func(d time.Duration) {
+		remoteChunkDownloadRetryInterval = d
+	}(remoteChunkDownloadRetryInterval)
+	remoteChunkDownloadRetryInterval = 0
+
+	remoteFS := &fsremote.FS{
+		Dir: t.TempDir(),
+	}
+	o := newOffloader(remoteFS, time.Hour, t.TempDir(), t.TempDir(), 1024*1024)
+
+	// The chunk is missing at remote storage, so the download must fail without panic.
+	p := getRemoteChunk("small/2020_01/part/values.bin", 1024, 0)
+	_, err := o.downloadChunk(&p)
+	if !errors.Is(err, errRemoteChunkDownload) {
+		t.Fatalf("unexpected error; got %v; want %v", err, errRemoteChunkDownload)
+	}
+	if n := o.downloadFailures.Load(); n != 1 {
+		t.Fatalf("unexpected number of download failures; got %d; want 1", n)
+	}
+	if n := o.downloadErrors.Load(); n != maxRemoteChunkDownloadAttempts {
+		t.Fatalf("unexpected number of download errors; got %d; want %d", n, maxRemoteChunkDownloadAttempts)
+	}
+
+	r := &remoteReaderAt{
+		o:        o,
+		path:     p.Path,
+		fileSize: p.FileSize,
+	}
+	buf := make([]byte, 16)
+	if err := r.ReadAt(buf, 0); !errors.Is(err, errRemoteChunkDownload) {
+		t.Fatalf("unexpected error from ReadAt; got %v; want %v", err, errRemoteChunkDownload)
+	}
+}
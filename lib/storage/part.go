@@ -43,22 +43,41 @@ type part struct {
 	indexFile      fs.MustReadAtCloser
 
 	metaindex []metaindexRow
+
+	// opi is non-nil if data files for the part are offloaded to remote storage.
+	opi *offloadedPartInfo
 }
 
 // mustOpenFilePart opens file-based part from the given path.
-func mustOpenFilePart(path string) *part {
+//
+// o is used for reading data files if the part is offloaded to remote storage.
+func mustOpenFilePart(path string, o *offloader) *part {
 	path = filepath.Clean(path)
 
 	var ph partHeader
 	ph.MustReadMetadata(path)
 
-	timestampsPath := filepath.Join(path, timestampsFilename)
-	timestampsFile := fs.MustOpenReaderAt(timestampsPath)
-	timestampsSize := fs.MustFileSize(timestampsPath)
-
-	valuesPath := filepath.Join(path, valuesFilename)
-	valuesFile := fs.MustOpenReaderAt(valuesPath)
-	valuesSize := fs.MustFileSize(valuesPath)
+	var opi *offloadedPartInfo
+	var timestampsFile, valuesFile fs.MustReadAtCloser
+	var timestampsSize, valuesSize uint64
+	if isOffloadedPart(path) {
+		if o == nil {
+			logger.Panicf("FATAL: cannot open part %q, since it is offloaded to remote storage, while offloading isn't configured", path)
+		}
+		opi = mustReadOffloadedPartInfo(path)
+		timestampsFile = o.newReaderAt(opi.RemotePath, timestampsFilename, opi.TimestampsSize)
+		timestampsSize = opi.TimestampsSize
+		valuesFile = o.newReaderAt(opi.RemotePath, valuesFilename, opi.ValuesSize)
+		valuesSize = opi.ValuesSize
+	} else {
+		timestampsPath := filepath.Join(path, timestampsFilename)
+		timestampsFile = fs.MustOpenReaderAt(timestampsPath)
+		timestampsSize = fs.MustFileSize(timestampsPath)
+
+		valuesPath := filepath.Join(path, valuesFilename)
+		valuesFile = fs.MustOpenReaderAt(valuesPath)
+		valuesSize = fs.MustFileSize(valuesPath)
+	}
 
 	indexPath := filepath.Join(path, indexFilename)
 	indexFile := fs.MustOpenReaderAt(indexPath)
@@ -69,7 +88,9 @@ func mustOpenFilePart(path string) *part {
 	metaindexSize := fs.MustFileSize(metaindexPath)
 
 	size := timestampsSize + valuesSize + indexSize + metaindexSize
-	return newPart(&ph, path, size, metaindexFile, timestampsFile, valuesFile, indexFile)
+	p := newPart(&ph, path, size, metaindexFile, timestampsFile, valuesFile, indexFile)
+	p.opi = opi
+	return p
 }
 
 // newPart returns new part initialized with the given arguments.
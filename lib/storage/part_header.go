@@ -31,6 +31,16 @@ type partHeader struct {
 
 	// MinDedupInterval is minimal dedup interval in milliseconds across all the blocks in the part.
 	MinDedupInterval int64
+
+	// RetentionFiltersTimestamp is the timestamp in milliseconds when retention filters were applied to the part.
+	//
+	// It is zero if retention filters weren't applied to the part.
+	RetentionFiltersTimestamp int64 `json:",omitempty"`
+
+	// TombstoneID is the id of the last tombstone applied to the part.
+	//
+	// Tombstones with bigger ids must be applied to the part. See tombstone for details.
+	TombstoneID uint64 `json:",omitempty"`
 }
 
 // String returns string representation of ph.
@@ -45,6 +55,8 @@ func (ph *partHeader) Reset() {
 	ph.MinTimestamp = (1 << 63) - 1
 	ph.MaxTimestamp = -1 << 63
 	ph.MinDedupInterval = 0
+	ph.RetentionFiltersTimestamp = 0
+	ph.TombstoneID = 0
 }
 
 func (ph *partHeader) readMinDedupInterval(partPath string) error {
@@ -132,6 +144,12 @@ func (ph *partHeader) ParseFromPath(path string) error {
 }
 
 func (ph *partHeader) MustReadMetadata(partPath string) {
+	if err := ph.readMetadata(partPath); err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+}
+
+func (ph *partHeader) readMetadata(partPath string) error {
 	ph.Reset()
 
 	metadataPath := filepath.Join(partPath, metadataFilename)
@@ -139,31 +157,32 @@ func (ph *partHeader) MustReadMetadata(partPath string) {
 		// This is a part created before v1.90.0.
 		// Fall back to reading the metadata from the partPath itself.
 		if err := ph.ParseFromPath(partPath); err != nil {
-			logger.Panicf("FATAL: cannot parse metadata from %q: %s", partPath, err)
+			return fmt.Errorf("cannot parse metadata from %q: %w", partPath, err)
 		}
 	} else {
 		metadata, err := os.ReadFile(metadataPath)
 		if err != nil {
-			logger.Panicf("FATAL: cannot read %q: %s", metadataPath, err)
+			return fmt.Errorf("cannot read %q: %w", metadataPath, err)
 		}
 		if err := json.Unmarshal(metadata, ph); err != nil {
-			logger.Panicf("FATAL: cannot parse %q: %s", metadataPath, err)
+			return fmt.Errorf("cannot parse %q: %w", metadataPath, err)
 		}
 	}
 
 	// Perform various checks
 	if ph.MinTimestamp > ph.MaxTimestamp {
-		logger.Panicf("FATAL: minTimestamp cannot exceed maxTimestamp at %q; got %d vs %d", metadataPath, ph.MinTimestamp, ph.MaxTimestamp)
+		return fmt.Errorf("minTimestamp cannot exceed maxTimestamp at %q; got %d vs %d", metadataPath, ph.MinTimestamp, ph.MaxTimestamp)
 	}
 	if ph.RowsCount <= 0 {
-		logger.Panicf("FATAL: rowsCount must be greater than 0 at %q; got %d", metadataPath, ph.RowsCount)
+		return fmt.Errorf("rowsCount must be greater than 0 at %q; got %d", metadataPath, ph.RowsCount)
 	}
 	if ph.BlocksCount <= 0 {
-		logger.Panicf("FATAL: blocksCount must be greater than 0 at %q; got %d", metadataPath, ph.BlocksCount)
+		return fmt.Errorf("blocksCount must be greater than 0 at %q; got %d", metadataPath, ph.BlocksCount)
 	}
 	if ph.BlocksCount > ph.RowsCount {
-		logger.Panicf("FATAL: blocksCount cannot be bigger than rowsCount at %q; got blocksCount=%d, rowsCount=%d", metadataPath, ph.BlocksCount, ph.RowsCount)
+		return fmt.Errorf("blocksCount cannot be bigger than rowsCount at %q; got blocksCount=%d, rowsCount=%d", metadataPath, ph.BlocksCount, ph.RowsCount)
 	}
+	return nil
 }
 
 func (ph *partHeader) MustWriteMetadata(partPath string) {
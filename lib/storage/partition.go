@@ -90,6 +90,8 @@ type partition struct {
 
 	isDedupScheduled atomic.Bool
 
+	isRetentionFiltersScheduled atomic.Bool
+
 	mergeIdx atomic.Uint64
 
 	// the path to directory with smallParts.
@@ -149,6 +151,10 @@ type partWrapper struct {
 	// was removed from the list of active parts.
 	mustDrop atomic.Bool
 
+	// The flag, which is set when local data files of the part must be deleted after refCount reaches zero,
+	// since they have been offloaded to remote storage.
+	mustDropLocalData atomic.Bool
+
 	// The part itself.
 	p *part
 
@@ -179,6 +185,10 @@ func (pw *partWrapper) decRef() {
 	if pw.mp == nil && pw.mustDrop.Load() {
 		deletePath = pw.p.path
 	}
+	offloadedPath := ""
+	if pw.mustDropLocalData.Load() {
+		offloadedPath = pw.p.path
+	}
 	if pw.mp != nil {
 		putInmemoryPart(pw.mp)
 		pw.mp = nil
@@ -189,6 +199,9 @@ func (pw *partWrapper) decRef() {
 	if deletePath != "" {
 		fs.MustRemoveAll(deletePath)
 	}
+	if offloadedPath != "" {
+		mustRemoveOffloadedLocalFiles(offloadedPath)
+	}
 }
 
 // mustCreatePartition creates new partition for the given timestamp and the given paths
@@ -253,8 +266,8 @@ func mustOpenPartition(smallPartsPath, bigPartsPath string, s *Storage) *partiti
 	partsFile := filepath.Join(smallPartsPath, partsFilename)
 	partNamesSmall, partNamesBig := mustReadPartNames(partsFile, smallPartsPath, bigPartsPath)
 
-	smallParts := mustOpenParts(partsFile, smallPartsPath, partNamesSmall)
-	bigParts := mustOpenParts(partsFile, bigPartsPath, partNamesBig)
+	smallParts := mustOpenParts(partsFile, smallPartsPath, partNamesSmall, s.offloader)
+	bigParts := mustOpenParts(partsFile, bigPartsPath, partNamesBig, s.offloader)
 
 	if !fs.IsPathExist(partsFile) {
 		// Create parts.json file if it doesn't exist yet.
@@ -334,6 +347,12 @@ type partitionMetrics struct {
 
 	ScheduledDownsamplingPartitions     uint64
 	ScheduledDownsamplingPartitionsSize uint64
+
+	ScheduledRetentionFiltersPartitions     uint64
+	ScheduledRetentionFiltersPartitionsSize uint64
+
+	OffloadedPartsCount uint64
+	OffloadedSizeBytes  uint64
 }
 
 // TotalRowsCount returns total number of rows in tm.
@@ -351,6 +370,10 @@ func (pt *partition) UpdateMetrics(m *partitionMetrics) {
 	if isDedupScheduled {
 		m.ScheduledDownsamplingPartitions++
 	}
+	isRetentionFiltersScheduled := pt.isRetentionFiltersScheduled.Load()
+	if isRetentionFiltersScheduled {
+		m.ScheduledRetentionFiltersPartitions++
+	}
 
 	for _, pw := range pt.inmemoryParts {
 		p := pw.p
@@ -361,6 +384,9 @@ func (pt *partition) UpdateMetrics(m *partitionMetrics) {
 		if isDedupScheduled {
 			m.ScheduledDownsamplingPartitionsSize += p.size
 		}
+		if isRetentionFiltersScheduled {
+			m.ScheduledRetentionFiltersPartitionsSize += p.size
+		}
 	}
 	for _, pw := range pt.smallParts {
 		p := pw.p
@@ -368,9 +394,16 @@ func (pt *partition) UpdateMetrics(m *partitionMetrics) {
 		m.SmallBlocksCount += p.ph.BlocksCount
 		m.SmallSizeBytes += p.size
 		m.SmallPartsRefCount += uint64(pw.refCount.Load())
+		if p.opi != nil {
+			m.OffloadedPartsCount++
+			m.OffloadedSizeBytes += p.opi.TimestampsSize + p.opi.ValuesSize
+		}
 		if isDedupScheduled {
 			m.ScheduledDownsamplingPartitionsSize += p.size
 		}
+		if isRetentionFiltersScheduled {
+			m.ScheduledRetentionFiltersPartitionsSize += p.size
+		}
 	}
 	for _, pw := range pt.bigParts {
 		p := pw.p
@@ -378,9 +411,16 @@ func (pt *partition) UpdateMetrics(m *partitionMetrics) {
 		m.BigBlocksCount += p.ph.BlocksCount
 		m.BigSizeBytes += p.size
 		m.BigPartsRefCount += uint64(pw.refCount.Load())
+		if p.opi != nil {
+			m.OffloadedPartsCount++
+			m.OffloadedSizeBytes += p.opi.TimestampsSize + p.opi.ValuesSize
+		}
 		if isDedupScheduled {
 			m.ScheduledDownsamplingPartitionsSize += p.size
 		}
+		if isRetentionFiltersScheduled {
+			m.ScheduledRetentionFiltersPartitionsSize += p.size
+		}
 	}
 
 	m.InmemoryPartsCount += uint64(len(pt.inmemoryParts))
@@ -649,6 +689,12 @@ func (pt *partition) inmemoryPartsMerger() {
 			// Nothing to do - finish the merger.
 			return
 		}
+		if errors.Is(err, errRemoteChunkDownload) {
+			// The remote storage with offloaded data is temporarily unavailable.
+			// The merge is retried when the merger is started next time.
+			logger.Warnf("cannot merge inmemory parts in partition %q: %s; the merge will be retried later", pt.name, err)
+			return
+		}
 		// Unexpected error.
 		logger.Panicf("FATAL: unrecoverable error when merging inmemory parts in partition %q: %s", pt.name, err)
 	}
@@ -682,6 +728,12 @@ func (pt *partition) smallPartsMerger() {
 			// Nothing to do - finish the merger.
 			return
 		}
+		if errors.Is(err, errRemoteChunkDownload) {
+			// The remote storage with offloaded data is temporarily unavailable.
+			// The merge is retried when the merger is started next time.
+			logger.Warnf("cannot merge small parts at %q: %s; the merge will be retried later", pt.smallPartsPath, err)
+			return
+		}
 		// Unexpected error.
 		logger.Panicf("FATAL: unrecoverable error when merging small parts at %q: %s", pt.smallPartsPath, err)
 	}
@@ -715,6 +767,12 @@ func (pt *partition) bigPartsMerger() {
 			// Nothing to do - finish the merger.
 			return
 		}
+		if errors.Is(err, errRemoteChunkDownload) {
+			// The remote storage with offloaded data is temporarily unavailable.
+			// The merge is retried when the merger is started next time.
+			logger.Warnf("cannot merge big parts at %q: %s; the merge will be retried later", pt.bigPartsPath, err)
+			return
+		}
 		// Unexpected error.
 		logger.Panicf("FATAL: unrecoverable error when merging big parts at %q: %s", pt.bigPartsPath, err)
 	}
@@ -833,6 +891,9 @@ func (pt *partition) createInmemoryPart(rows []rawRow) *partWrapper {
 	mp := getInmemoryPart()
 	mp.InitFromRows(rows)
 
+	// Tombstones created before the part must not be applied to it, since they cannot delete the newly added samples.
+	mp.ph.TombstoneID = pt.s.getTombstones().lastID()
+
 	// Make sure the part may be added.
 	if mp.ph.MinTimestamp > mp.ph.MaxTimestamp {
 		logger.Panicf("BUG: the part %q cannot be added to partition %q because its MinTimestamp exceeds MaxTimestamp; %d vs %d",
@@ -1353,7 +1414,7 @@ func (pt *partition) runFinalDedup(stopCh <-chan struct{}) error {
 }
 
 func (pt *partition) isFinalDedupNeeded() bool {
-	dedupInterval := GetDedupInterval()
+	dedupInterval := pt.getDedupInterval(time.Now().UnixMilli())
 
 	pws := pt.GetParts(nil, false)
 	minDedupInterval := getMinDedupInterval(pws)
@@ -1362,6 +1423,111 @@ func (pt *partition) isFinalDedupNeeded() bool {
 	return dedupInterval > minDedupInterval
 }
 
+// getDedupInterval returns the dedup interval in milliseconds, which must be applied to all the samples in pt at currentTimestamp.
+//
+// The returned interval includes the downsampling interval for the pt age if downsampling is enabled.
+func (pt *partition) getDedupInterval(currentTimestamp int64) int64 {
+	return getDedupIntervalForAge(currentTimestamp - pt.tr.MaxTimestamp)
+}
+
+func (pt *partition) runRetentionFilters(stopCh <-chan struct{}) error {
+	t := time.Now()
+	logger.Infof("start applying retention filters to partition (%s, %s)", pt.bigPartsPath, pt.smallPartsPath)
+	if err := pt.ForceMergeAllParts(stopCh); err != nil {
+		return fmt.Errorf("cannot apply retention filters to partition (%s, %s): %w", pt.bigPartsPath, pt.smallPartsPath, err)
+	}
+	logger.Infof("retention filters have been applied to partition (%s, %s) in %.3f seconds", pt.bigPartsPath, pt.smallPartsPath, time.Since(t).Seconds())
+	return nil
+}
+
+// applyTombstones merges parts, which contain samples deleted by tss, in order to remove these samples.
+//
+// In-memory parts aren't merged, since they are converted to file parts soon.
+func (pt *partition) applyTombstones(tss *tombstones, stopCh <-chan struct{}) error {
+	pt.partsLock.Lock()
+	pws := appendPartsForTombstones(nil, pt.smallParts, tss)
+	pws = appendPartsForTombstones(pws, pt.bigParts, tss)
+	pt.partsLock.Unlock()
+	if len(pws) == 0 {
+		// Nothing to merge.
+		return nil
+	}
+
+	// Check whether there is enough disk space for merging pws.
+	newPartSize := getPartsSize(pws)
+	maxOutBytes := fs.MustGetFreeSpace(pt.bigPartsPath)
+	if newPartSize > maxOutBytes {
+		freeSpaceNeededBytes := newPartSize - maxOutBytes
+		forceMergeLogger.Warnf("cannot apply tombstones to the partition %s; additional space needed: %d bytes", pt.name, freeSpaceNeededBytes)
+		pt.releasePartsToMerge(pws)
+		return nil
+	}
+
+	t := time.Now()
+	if err := pt.mergePartsToFiles(pws, stopCh, bigPartsConcurrencyCh, true); err != nil {
+		return fmt.Errorf("cannot merge %d parts from partition %q: %w", len(pws), pt.name, err)
+	}
+	logger.Infof("tombstones have been applied to %d parts from partition (%s, %s) in %.3f seconds", len(pws), pt.bigPartsPath, pt.smallPartsPath, time.Since(t).Seconds())
+	return nil
+}
+
+func appendPartsForTombstones(dst, src []*partWrapper, tss *tombstones) []*partWrapper {
+	for _, pw := range src {
+		if pw.isInMerge || !tss.mustBeAppliedToPart(&pw.p.ph) {
+			continue
+		}
+		pw.isInMerge = true
+		dst = append(dst, pw)
+	}
+	return dst
+}
+
+// hasPartsForTombstone returns true if pt contains parts, which may contain samples deleted by ts.
+func (pt *partition) hasPartsForTombstone(ts *tombstone) bool {
+	if ts.MaxTimestamp < pt.tr.MinTimestamp || ts.MinTimestamp > pt.tr.MaxTimestamp {
+		return false
+	}
+
+	pws := pt.GetParts(nil, true)
+	defer pt.PutParts(pws)
+
+	for _, pw := range pws {
+		ph := &pw.p.ph
+		if ts.mustBeAppliedTo(ph.TombstoneID, ph.MinTimestamp, ph.MaxTimestamp) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRetentionFiltersNeeded returns true if retention filters must be applied to pt at currentTimestamp.
+//
+// Retention filters must be applied to the partition if all its samples become outside the retention
+// for some of the retention filters after the last time the filters were applied to partition parts.
+func (pt *partition) isRetentionFiltersNeeded(currentTimestamp int64) bool {
+	if !pt.s.hasRetentionFilters() {
+		return false
+	}
+
+	pws := pt.GetParts(nil, false)
+	defer pt.PutParts(pws)
+
+	for _, rf := range pt.s.retentionFilters {
+		retentionMsecs := rf.Retention.Milliseconds()
+		if currentTimestamp-retentionMsecs <= pt.tr.MaxTimestamp {
+			// The partition contains samples inside the retention for the remaining filters,
+			// since the filters are sorted by retention.
+			return false
+		}
+		for _, pw := range pws {
+			if pw.p.ph.RetentionFiltersTimestamp-retentionMsecs <= pt.tr.MaxTimestamp {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func getMinDedupInterval(pws []*partWrapper) int64 {
 	if len(pws) == 0 {
 		return 0
@@ -1403,7 +1569,7 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}, isFi
 	mergeIdx := pt.nextMergeIdx()
 	dstPartPath := pt.getDstPartPath(dstPartType, mergeIdx)
 
-	if !isDedupEnabled() && isFinal && len(pws) == 1 && pws[0].mp != nil {
+	if !isDedupEnabled() && !isDownsamplingEnabled() && !pt.s.hasRetentionFilters() && isFinal && len(pws) == 1 && pws[0].mp != nil {
 		// Fast path: flush a single in-memory part to disk.
 		mp := pws[0].mp
 		mp.MustStoreToDisk(dstPartPath)
@@ -1413,7 +1579,7 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}, isFi
 	}
 
 	// Prepare BlockStreamReaders for source parts.
-	bsrs := mustOpenBlockStreamReaders(pws)
+	bsrs := mustOpenBlockStreamReaders(pws, pt.s.offloader)
 
 	// Prepare BlockStreamWriter for destination part.
 	srcSize := uint64(0)
@@ -1446,6 +1612,10 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}, isFi
 		putBlockStreamReader(bsr)
 	}
 	if err != nil {
+		if dstPartPath != "" && errors.Is(err, errRemoteChunkDownload) {
+			// Remove the incomplete destination part, since the source parts remain in place until the merge is retried.
+			fs.MustRemoveAll(dstPartPath)
+		}
 		return err
 	}
 	if mpNew != nil {
@@ -1538,12 +1708,14 @@ func (pt *partition) getDstPartPath(dstPartType partType, mergeIdx uint64) strin
 	return dstPartPath
 }
 
-func mustOpenBlockStreamReaders(pws []*partWrapper) []*blockStreamReader {
+func mustOpenBlockStreamReaders(pws []*partWrapper, o *offloader) []*blockStreamReader {
 	bsrs := make([]*blockStreamReader, 0, len(pws))
 	for _, pw := range pws {
 		bsr := getBlockStreamReader()
 		if pw.mp != nil {
 			bsr.MustInitFromInmemoryPart(pw.mp)
+		} else if pw.p.opi != nil {
+			bsr.MustInitFromOffloadedPart(pw.p.path, pw.p.opi, o)
 		} else {
 			bsr.MustInitFromFilePart(pw.p.path)
 		}
@@ -1578,16 +1750,30 @@ func (pt *partition) mergePartsInternal(dstPartPath string, bsw *blockStreamWrit
 		logger.Panicf("BUG: unknown partType=%d", dstPartType)
 	}
 	retentionDeadline := currentTimestamp - pt.s.retentionMsecs
+	var getRetentionDeadline func(metricID uint64) int64
+	if pt.s.hasRetentionFilters() && currentTimestamp-pt.s.getMinRetentionFilterMsecs() > pt.tr.MinTimestamp {
+		// The partition may contain samples outside retention filters.
+		getRetentionDeadline = func(metricID uint64) int64 {
+			return currentTimestamp - pt.s.getRetentionMsecs(metricID)
+		}
+	}
 	activeMerges.Add(1)
 	dmis := pt.s.getDeletedMetricIDs()
-	err := mergeBlockStreams(&ph, bsw, bsrs, stopCh, dmis, retentionDeadline, rowsMerged, rowsDeleted, useSparseCache)
+	tss := pt.s.getTombstones()
+	if isDownsamplingEnabled() {
+		bsw.currentTimestamp = currentTimestamp
+	}
+	err := mergeBlockStreams(&ph, bsw, bsrs, stopCh, dmis, tss, retentionDeadline, getRetentionDeadline, rowsMerged, rowsDeleted, useSparseCache)
 	activeMerges.Add(-1)
 	mergesCount.Add(1)
 	if err != nil {
 		return nil, fmt.Errorf("cannot merge %d parts to %s: %w", len(bsrs), dstPartPath, err)
 	}
+	if pt.s.hasRetentionFilters() {
+		ph.RetentionFiltersTimestamp = currentTimestamp
+	}
 	if dstPartPath != "" {
-		ph.MinDedupInterval = GetDedupInterval()
+		ph.MinDedupInterval = pt.getDedupInterval(currentTimestamp)
 		ph.MustWriteMetadata(dstPartPath)
 	}
 	return &ph, nil
@@ -1609,7 +1795,7 @@ func (pt *partition) openCreatedPart(ph *partHeader, pws []*partWrapper, mpNew *
 		return pwNew
 	}
 	// Open the created part from disk.
-	pNew := mustOpenFilePart(dstPartPath)
+	pNew := mustOpenFilePart(dstPartPath, pt.s.offloader)
 	pwNew := &partWrapper{
 		p: pNew,
 	}
@@ -1760,13 +1946,95 @@ func (pt *partition) removeStaleParts() {
 	pt.swapSrcWithDstParts(pws, nil, partSmall)
 }
 
+// offloadParts offloads data files for file-based parts of pt to remote storage via o.
+//
+// Offloading is stopped if stopCh is closed.
+func (pt *partition) offloadParts(o *offloader, stopCh <-chan struct{}) error {
+	// Flush recently added samples to file-based parts, so they are offloaded too.
+	pt.flushInmemoryRowsToFiles()
+
+	// Mark the parts as in merge, so they aren't merged or removed while they are offloaded.
+	pt.partsLock.Lock()
+	pws := appendPartsToOffload(nil, pt.smallParts)
+	pws = appendPartsToOffload(pws, pt.bigParts)
+	pt.partsLock.Unlock()
+
+	for i, pw := range pws {
+		select {
+		case <-stopCh:
+			pt.releasePartsToMerge(pws[i:])
+			return errForciblyStopped
+		default:
+		}
+		if err := pt.offloadPart(o, pw); err != nil {
+			pt.releasePartsToMerge(pws[i:])
+			return err
+		}
+	}
+	return nil
+}
+
+func appendPartsToOffload(dst, src []*partWrapper) []*partWrapper {
+	for _, pw := range src {
+		if !pw.isInMerge && pw.p.opi == nil {
+			pw.isInMerge = true
+			dst = append(dst, pw)
+		}
+	}
+	return dst
+}
+
+func (pt *partition) offloadPart(o *offloader, pw *partWrapper) error {
+	startTime := time.Now()
+	partPath := pw.p.path
+	opi, err := o.uploadPart(partPath)
+	if err != nil {
+		return fmt.Errorf("cannot offload part %q: %w", partPath, err)
+	}
+	mustWriteOffloadedPartInfo(partPath, opi)
+
+	// Re-open the part, so it reads data files from remote storage, and replace the original part with it.
+	// The part name remains the same, so there is no need in updating parts.json.
+	pNew := mustOpenFilePart(partPath, o)
+	pwNew := &partWrapper{
+		p: pNew,
+	}
+	pwNew.incRef()
+
+	pt.partsLock.Lock()
+	ok := replacePart(pt.smallParts, pw, pwNew) || replacePart(pt.bigParts, pw, pwNew)
+	pt.partsLock.Unlock()
+	if !ok {
+		logger.Panicf("BUG: cannot find part %q in partition %q", partPath, pt.name)
+	}
+
+	// Local data files are deleted after all the pending searches over the original part are finished.
+	pw.mustDropLocalData.Store(true)
+	pw.decRef()
+
+	logger.Infof("offloaded part %q with %d bytes to %s in %.3f seconds", partPath, opi.TimestampsSize+opi.ValuesSize, o.fs, time.Since(startTime).Seconds())
+	return nil
+}
+
+func replacePart(pws []*partWrapper, pw, pwNew *partWrapper) bool {
+	for i := range pws {
+		if pws[i] == pw {
+			pws[i] = pwNew
+			return true
+		}
+	}
+	return false
+}
+
 // getPartsToMerge returns optimal parts to merge from pws.
 //
 // The summary size of the returned parts must be smaller than maxOutBytes.
 func getPartsToMerge(pws []*partWrapper, maxOutBytes uint64) []*partWrapper {
 	pwsRemaining := make([]*partWrapper, 0, len(pws))
 	for _, pw := range pws {
-		if !pw.isInMerge {
+		// Do not merge offloaded parts in background, since this requires downloading them from remote storage.
+		// They are merged only by explicit merges such as final deduplication and force merge.
+		if !pw.isInMerge && pw.p.opi == nil {
 			pwsRemaining = append(pwsRemaining, pw)
 		}
 	}
@@ -1918,7 +2186,7 @@ func getPartsSize(pws []*partWrapper) uint64 {
 	return n
 }
 
-func mustOpenParts(partsFile, path string, partNames []string) []*partWrapper {
+func mustOpenParts(partsFile, path string, partNames []string, o *offloader) []*partWrapper {
 	// The path can be missing after restoring from backup, so create it if needed.
 	fs.MustMkdirIfNotExist(path)
 	fs.MustRemoveTemporaryDirs(path)
@@ -1964,7 +2232,11 @@ func mustOpenParts(partsFile, path string, partNames []string) []*partWrapper {
 	var pws []*partWrapper
 	for _, partName := range partNames {
 		partPath := filepath.Join(path, partName)
-		p := mustOpenFilePart(partPath)
+		if isOffloadedPart(partPath) {
+			// Local data files may be left after unclean shutdown just after offloading the part.
+			mustRemoveOffloadedLocalFiles(partPath)
+		}
+		p := mustOpenFilePart(partPath, o)
 		pw := &partWrapper{
 			p: p,
 		}
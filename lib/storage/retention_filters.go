@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+// RetentionFilter contains retention for time series matching the given series filter.
+type RetentionFilter struct {
+	// Retention is the retention for time series matching the filter.
+	Retention time.Duration
+
+	// filter is the original series filter.
+	filter string
+
+	// tfss contains or-delimited tag filters obtained from the filter.
+	tfss []*TagFilters
+}
+
+// String returns string representation of rf.
+func (rf *RetentionFilter) String() string {
+	return fmt.Sprintf("%s:%s", rf.filter, rf.Retention)
+}
+
+// ParseRetentionFilters parses retention filters from a.
+//
+// Every item in a must have the form `filter:duration`, for example `{team="dev"}:7d`.
+// The returned filters are sorted by Retention.
+func ParseRetentionFilters(a []string) ([]*RetentionFilter, error) {
+	var rfs []*RetentionFilter
+	for _, s := range a {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		rf, err := parseRetentionFilter(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse retention filter %q: %w", s, err)
+		}
+		rfs = append(rfs, rf)
+	}
+	sort.SliceStable(rfs, func(i, j int) bool {
+		return rfs[i].Retention < rfs[j].Retention
+	})
+	return rfs, nil
+}
+
+func parseRetentionFilter(s string) (*RetentionFilter, error) {
+	n := strings.LastIndexByte(s, ':')
+	if n < 0 {
+		return nil, fmt.Errorf("missing ':' delimiter between filter and retention")
+	}
+	filter, retentionStr := strings.TrimSpace(s[:n]), s[n+1:]
+	retention, err := timeutil.ParseDuration(retentionStr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse retention %q: %w", retentionStr, err)
+	}
+	if retention < 24*time.Hour {
+		return nil, fmt.Errorf("retention cannot be smaller than a day; got %s", retentionStr)
+	}
+	expr, err := metricsql.Parse(filter)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse series filter %q: %w", filter, err)
+	}
+	me, ok := expr.(*metricsql.MetricExpr)
+	if !ok {
+		return nil, fmt.Errorf("expecting series filter; got %q", expr.AppendString(nil))
+	}
+	if len(me.LabelFilterss) == 0 {
+		return nil, fmt.Errorf("series filter cannot be empty")
+	}
+	tfss := make([]*TagFilters, 0, len(me.LabelFilterss))
+	for _, lfs := range me.LabelFilterss {
+		tfs := NewTagFilters()
+		for _, lf := range lfs {
+			key := []byte(lf.Label)
+			if lf.Label == "__name__" {
+				key = nil
+			}
+			if err := tfs.Add(key, []byte(lf.Value), lf.IsNegative, lf.IsRegexp); err != nil {
+				return nil, fmt.Errorf("cannot parse series filter %q: %w", filter, err)
+			}
+		}
+		tfss = append(tfss, tfs)
+	}
+	return &RetentionFilter{
+		Retention: retention,
+		filter:    filter,
+		tfss:      tfss,
+	}, nil
+}
+
+// matchMetricName returns true if rf matches mn.
+func (rf *RetentionFilter) matchMetricName(mn *MetricName) bool {
+	kb := kbPool.Get()
+	defer kbPool.Put(kb)
+
+	var tfs []*tagFilter
+	for _, tfsOr := range rf.tfss {
+		tfs = tfs[:0]
+		for i := range tfsOr.tfs {
+			tfs = append(tfs, &tfsOr.tfs[i])
+		}
+		ok, err := matchTagFilters(mn, tfs, kb)
+		if err != nil {
+			logger.Panicf("BUG: cannot match retention filter %s against %s: %s", rf, mn, err)
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Storage) hasRetentionFilters() bool {
+	return len(s.retentionFilters) > 0
+}
+
+// getMinRetentionFilterMsecs returns the minimum retention in milliseconds across retention filters.
+func (s *Storage) getMinRetentionFilterMsecs() int64 {
+	if !s.hasRetentionFilters() {
+		return s.retentionMsecs
+	}
+	return s.retentionFilters[0].Retention.Milliseconds()
+}
+
+// getRetentionMsecs returns the retention in milliseconds for time series with the given metricID.
+//
+// The smallest retention is returned if the time series matches multiple retention filters.
+// -retentionPeriod is returned if the time series doesn't match any retention filter.
+func (s *Storage) getRetentionMsecs(metricID uint64) int64 {
+	if !s.hasRetentionFilters() {
+		return s.retentionMsecs
+	}
+
+	key := (*[unsafe.Sizeof(metricID)]byte)(unsafe.Pointer(&metricID))
+	var buf [8]byte
+	v := s.retentionFiltersCache.Get(buf[:0], key[:])
+	if len(v) == 8 {
+		return int64(binary.BigEndian.Uint64(v))
+	}
+
+	retentionMsecs := s.retentionMsecs
+	idb, putIndexDB := s.getCurrIndexDB()
+	metricName, ok := idb.searchMetricName(nil, metricID, false)
+	putIndexDB()
+	if ok {
+		mn := GetMetricName()
+		if err := mn.Unmarshal(metricName); err != nil {
+			logger.Panicf("FATAL: cannot unmarshal metricName %q obtained by metricID=%d: %s", metricName, metricID, err)
+		}
+		// Retention filters are sorted by retention, so the first matching filter has the smallest retention.
+		for _, rf := range s.retentionFilters {
+			if rf.matchMetricName(mn) {
+				retentionMsecs = min(retentionMsecs, rf.Retention.Milliseconds())
+				break
+			}
+		}
+		PutMetricName(mn)
+	}
+
+	binary.BigEndian.PutUint64(buf[:], uint64(retentionMsecs))
+	s.retentionFiltersCache.Set(key[:], buf[:])
+	return retentionMsecs
+}
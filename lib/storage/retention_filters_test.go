@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetentionFiltersSuccess(t *testing.T) {
+	f := func(a []string, resultExpected string) {
+		t.Helper()
+		rfs, err := ParseRetentionFilters(a)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var result string
+		for i, rf := range rfs {
+			if i > 0 {
+				result += ","
+			}
+			result += rf.String()
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f(nil, "")
+	f([]string{""}, "")
+	f([]string{`{team="dev"}:7d`}, `{team="dev"}:168h0m0s`)
+	f([]string{`{env=~"dev|staging"}:30d`, `{team="juniors"}:3d`}, `{team="juniors"}:72h0m0s,{env=~"dev|staging"}:720h0m0s`)
+	f([]string{`up{env="dev" or env="staging"}:1w`}, `up{env="dev" or env="staging"}:168h0m0s`)
+}
+
+func TestParseRetentionFiltersFailure(t *testing.T) {
+	f := func(a []string) {
+		t.Helper()
+		_, err := ParseRetentionFilters(a)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing retention
+	f([]string{`{team="dev"}`})
+
+	// invalid retention
+	f([]string{`{team="dev"}:foo`})
+
+	// too small retention
+	f([]string{`{team="dev"}:1h`})
+
+	// invalid filter
+	f([]string{`{team="dev":7d`})
+
+	// non-filter expression
+	f([]string{`rate(foo[5m]):7d`})
+}
+
+func TestRetentionFilterMatchMetricName(t *testing.T) {
+	f := func(filter string, mn *MetricName, resultExpected bool) {
+		t.Helper()
+		rfs, err := ParseRetentionFilters([]string{filter + ":1d"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		result := rfs[0].matchMetricName(mn)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for filter %s and metric name %s; got %v; want %v", filter, mn, result, resultExpected)
+		}
+	}
+
+	mn := &MetricName{
+		MetricGroup: []byte("up"),
+		Tags: []Tag{
+			{
+				Key:   []byte("env"),
+				Value: []byte("dev"),
+			},
+			{
+				Key:   []byte("team"),
+				Value: []byte("juniors"),
+			},
+		},
+	}
+	f(`{env="dev"}`, mn, true)
+	f(`{env="prod"}`, mn, false)
+	f(`{env=~"dev|staging"}`, mn, true)
+	f(`{env!="dev"}`, mn, false)
+	f(`up`, mn, true)
+	f(`foo`, mn, false)
+	f(`up{team="juniors",env="dev"}`, mn, true)
+	f(`up{team="juniors",env="prod"}`, mn, false)
+	f(`{env="prod" or team="juniors"}`, mn, true)
+	f(`{missing="foo"}`, mn, false)
+	f(`{missing=""}`, mn, true)
+}
+
+func TestStorageRetentionFilters(t *testing.T) {
+	defer testRemoveAll(t)
+
+	rfs, err := ParseRetentionFilters([]string{`{env="dev"}:1d`})
+	if err != nil {
+		t.Fatalf("cannot parse retention filters: %s", err)
+	}
+	s := MustOpenStorage(t.Name(), OpenOptions{
+		Retention:        365 * 24 * time.Hour,
+		RetentionFilters: rfs,
+	})
+	defer s.MustClose()
+
+	newMetricRow := func(env string, timestamp int64) MetricRow {
+		mn := MetricName{
+			MetricGroup: []byte("metric"),
+			Tags: []Tag{
+				{
+					Key:   []byte("env"),
+					Value: []byte(env),
+				},
+			},
+		}
+		return MetricRow{
+			MetricNameRaw: mn.marshalRaw(nil),
+			Timestamp:     timestamp,
+			Value:         1,
+		}
+	}
+
+	const rowsPerSeries = 10
+	now := time.Now().UnixMilli()
+	var mrs []MetricRow
+	for i := 0; i < rowsPerSeries; i++ {
+		// Rows outside the retention filter.
+		ts := now - 3*24*3600*1000 + int64(i)*1000
+		mrs = append(mrs, newMetricRow("dev", ts), newMetricRow("prod", ts))
+
+		// Rows inside the retention filter.
+		ts = now - 3600*1000 + int64(i)*1000
+		mrs = append(mrs, newMetricRow("dev", ts), newMetricRow("prod", ts))
+	}
+	s.AddRows(mrs, defaultPrecisionBits)
+	s.DebugFlush()
+
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+
+	if n := s.getRetentionMsecs(0); n != s.retentionMsecs {
+		t.Fatalf("unexpected retention for unknown metricID; got %d; want %d", n, s.retentionMsecs)
+	}
+
+	var m Metrics
+	s.UpdateMetrics(&m)
+	rowsExpected := uint64(3 * rowsPerSeries)
+	if n := m.TableMetrics.TotalRowsCount(); n != rowsExpected {
+		t.Fatalf("unexpected number of rows after applying retention filters; got %d; want %d", n, rowsExpected)
+	}
+
+	// Verify that the retention filters must be applied to the partition only after all its samples
+	// become outside the retention filter.
+	ptws := s.tb.GetPartitions(nil)
+	defer s.tb.PutPartitions(ptws)
+	for _, ptw := range ptws {
+		if ptw.pt.isRetentionFiltersNeeded(now) {
+			t.Fatalf("unexpected retention filters scheduling for partition %s", ptw.pt.name)
+		}
+		if !ptw.pt.isRetentionFiltersNeeded(ptw.pt.tr.MaxTimestamp + 2*24*3600*1000) {
+			t.Fatalf("expecting retention filters scheduling for partition %s", ptw.pt.name)
+		}
+	}
+}
+
+func TestStorageRetentionFiltersSearch(t *testing.T) {
+	defer testRemoveAll(t)
+
+	newMetricRow := func(env string, timestamp int64) MetricRow {
+		mn := MetricName{
+			MetricGroup: []byte("metric"),
+			Tags: []Tag{
+				{
+					Key:   []byte("env"),
+					Value: []byte(env),
+				},
+			},
+		}
+		return MetricRow{
+			MetricNameRaw: mn.marshalRaw(nil),
+			Timestamp:     timestamp,
+			Value:         1,
+		}
+	}
+
+	// Add hourly samples for the last 9 days, so blocks contain samples both inside and outside the 7d retention filter.
+	now := time.Now().UnixMilli()
+	retentionDeadline := now - 7*24*3600*1000
+	var mrs, mrsExpected []MetricRow
+	for ts := now - 9*24*3600*1000 + 1800*1000; ts < now; ts += 3600 * 1000 {
+		mrDev := newMetricRow("dev", ts)
+		mrProd := newMetricRow("prod", ts)
+		mrs = append(mrs, mrDev, mrProd)
+		mrsExpected = append(mrsExpected, mrProd)
+		if ts >= retentionDeadline {
+			mrsExpected = append(mrsExpected, mrDev)
+		}
+	}
+
+	// Store the samples without retention filters, so they aren't applied to the stored parts.
+	s := MustOpenStorage(t.Name(), OpenOptions{
+		Retention: 365 * 24 * time.Hour,
+	})
+	s.AddRows(mrs, defaultPrecisionBits)
+	s.DebugFlush()
+	s.MustClose()
+
+	rfs, err := ParseRetentionFilters([]string{`{env="dev"}:7d`})
+	if err != nil {
+		t.Fatalf("cannot parse retention filters: %s", err)
+	}
+	s = MustOpenStorage(t.Name(), OpenOptions{
+		Retention:        365 * 24 * time.Hour,
+		RetentionFilters: rfs,
+	})
+	defer s.MustClose()
+
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("metric"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	tr := TimeRange{
+		MinTimestamp: now - 10*24*3600*1000,
+		MaxTimestamp: now,
+	}
+
+	// Samples outside the retention filter must be invisible for search before the filter is applied to the stored data.
+	if err := testAssertSearchResult(s, tr, tfs, mrsExpected); err != nil {
+		t.Fatalf("unexpected search result: %s", err)
+	}
+}
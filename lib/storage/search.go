@@ -21,21 +21,34 @@ import (
 type BlockRef struct {
 	p  *part
 	bh blockHeader
+
+	// tss contains tombstones, which must be applied to the block. It may be nil.
+	tss *tombstones
+
+	// retentionDeadline is the retention deadline for the series in the block according to -retentionFilter.
+	// Samples with smaller timestamps are dropped from the block. It is 0 if the series doesn't match retention filters.
+	retentionDeadline int64
 }
 
 func (br *BlockRef) reset() {
 	br.p = nil
 	br.bh = blockHeader{}
+	br.tss = nil
+	br.retentionDeadline = 0
 }
 
 func (br *BlockRef) init(p *part, bh *blockHeader) {
 	br.p = p
 	br.bh = *bh
+	br.tss = nil
+	br.retentionDeadline = 0
 }
 
 // Init initializes br from pr and data
 func (br *BlockRef) Init(pr PartRef, data []byte) error {
 	br.p = pr.p
+	br.tss = pr.tss
+	br.retentionDeadline = pr.retentionDeadline
 	tail, err := br.bh.Unmarshal(data)
 	if err != nil {
 		return err
@@ -59,25 +72,76 @@ func (br *BlockRef) RowsCount() int {
 // PartRef returns PartRef from br.
 func (br *BlockRef) PartRef() PartRef {
 	return PartRef{
-		p: br.p,
+		p:                 br.p,
+		tss:               br.tss,
+		retentionDeadline: br.retentionDeadline,
 	}
 }
 
 // PartRef is Part reference.
 type PartRef struct {
-	p *part
+	p                 *part
+	tss               *tombstones
+	retentionDeadline int64
 }
 
 // MustReadBlock reads block from br to dst.
+//
+// Samples deleted by tombstones and samples outside the retention for the series are dropped from dst.
+// dst may contain zero rows if all its samples are deleted.
+//
+// It panics if the block cannot be read. Use ReadBlock for handling errors.
 func (br *BlockRef) MustReadBlock(dst *Block) {
+	if err := br.ReadBlock(dst); err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+}
+
+// ReadBlock reads block from br to dst.
+//
+// Samples deleted by tombstones and samples outside the retention for the series are dropped from dst.
+// dst may contain zero rows if all its samples are deleted.
+//
+// An error is returned if the block belongs to the offloaded part and it cannot be read from remote storage.
+func (br *BlockRef) ReadBlock(dst *Block) error {
 	dst.Reset()
 	dst.bh = br.bh
 
 	dst.timestampsData = bytesutil.ResizeNoCopyMayOverallocate(dst.timestampsData, int(br.bh.TimestampsBlockSize))
-	br.p.timestampsFile.MustReadAt(dst.timestampsData, int64(br.bh.TimestampsBlockOffset))
+	if err := readPartDataAt(br.p.timestampsFile, dst.timestampsData, int64(br.bh.TimestampsBlockOffset)); err != nil {
+		return fmt.Errorf("cannot read timestamps for block from part %q: %w", br.p.path, err)
+	}
 
 	dst.valuesData = bytesutil.ResizeNoCopyMayOverallocate(dst.valuesData, int(br.bh.ValuesBlockSize))
-	br.p.valuesFile.MustReadAt(dst.valuesData, int64(br.bh.ValuesBlockOffset))
+	if err := readPartDataAt(br.p.valuesFile, dst.valuesData, int64(br.bh.ValuesBlockOffset)); err != nil {
+		return fmt.Errorf("cannot read values for block from part %q: %w", br.p.path, err)
+	}
+
+	var deletedTimeRanges []TimeRange
+	if br.tss != nil {
+		deletedTimeRanges = br.tss.appendDeletedTimeRanges(nil, &br.bh, br.p.ph.TombstoneID)
+	}
+	if br.retentionDeadline > 0 && br.bh.MinTimestamp < br.retentionDeadline {
+		deletedTimeRanges = append(deletedTimeRanges, TimeRange{
+			MinTimestamp: br.bh.MinTimestamp,
+			MaxTimestamp: br.retentionDeadline - 1,
+		})
+	}
+	if len(deletedTimeRanges) == 0 {
+		return nil
+	}
+
+	// Slow path - drop samples deleted by tombstones, which weren't applied to the part yet,
+	// and samples outside the retention filter, which wasn't applied to the part yet.
+	if err := dst.UnmarshalData(); err != nil {
+		logger.Panicf("FATAL: cannot unmarshal block from part %q: %s", br.p.path, err)
+	}
+	deleteSamplesOnTimeRanges(dst, deletedTimeRanges)
+	dst.bh.RowsCount = uint32(len(dst.timestamps))
+	if len(dst.timestamps) > 0 {
+		dst.fixupTimestamps()
+	}
+	return nil
 }
 
 // MetricBlockRef contains reference to time series block for a single metric.
@@ -85,7 +149,7 @@ type MetricBlockRef struct {
 	// The metric name
 	MetricName []byte
 
-	// The block reference. Call BlockRef.MustReadBlock in order to obtain the block.
+	// The block reference. Call BlockRef.ReadBlock in order to obtain the block.
 	BlockRef *BlockRef
 }
 
@@ -104,6 +168,13 @@ type Search struct {
 	// retentionDeadline is used for filtering out blocks outside the configured retention.
 	retentionDeadline int64
 
+	// seriesRetentionDeadline is used for filtering out samples outside the retention filter for the current series.
+	// It is 0 if the current series doesn't match retention filters.
+	seriesRetentionDeadline int64
+
+	// tss contains tombstones for samples, which must be skipped during the search. It may be nil.
+	tss *tombstones
+
 	ts tableSearch
 
 	// tr contains time range used in the search.
@@ -134,6 +205,8 @@ func (s *Search) reset() {
 	s.idb = nil
 	s.putIndexDB = nil
 	s.retentionDeadline = 0
+	s.seriesRetentionDeadline = 0
+	s.tss = nil
 	s.ts.reset()
 	s.tr = TimeRange{}
 	s.tfss = nil
@@ -181,6 +254,11 @@ func (s *Search) Init(qt *querytracer.Tracer, storage *Storage, tfss []*TagFilte
 	// It is ok to call Init on non-nil err.
 	// Init must be called before returning because it will fail
 	// on Search.MustClose otherwise.
+	if tss := storage.getTombstones(); len(tss.items) > 0 {
+		// Obtain tombstones before obtaining parts to search in,
+		// so the search doesn't miss tombstones dropped after being applied to the obtained parts.
+		s.tss = tss
+	}
 	s.ts.Init(storage.tb, tsids, dataTR)
 	qt.Printf("search for parts with data for %d series", len(tsids))
 	if err != nil {
@@ -221,7 +299,15 @@ func (s *Search) NextMetricBlock() bool {
 			}
 		}
 		s.loops++
-		tsid := &s.ts.BlockRef.bh.TSID
+		br := s.ts.BlockRef
+		if s.tss != nil {
+			if s.tss.isBlockDeleted(&br.bh, br.p.ph.TombstoneID) {
+				// Skip the block, since all its samples are deleted.
+				continue
+			}
+			br.tss = s.tss
+		}
+		tsid := &br.bh.TSID
 		if tsid.MetricID != s.prevMetricID {
 			if s.ts.BlockRef.bh.MaxTimestamp < s.retentionDeadline {
 				// Skip the block, since it contains only data outside the configured retention.
@@ -247,6 +333,16 @@ func (s *Search) NextMetricBlock() bool {
 				s.idb.s.metricsTracker.RegisterQueryRequest(0, 0, s.metricGroupBuf)
 			}
 			s.prevMetricID = tsid.MetricID
+			s.seriesRetentionDeadline = s.getSeriesRetentionDeadline(tsid.MetricID)
+		}
+		if s.seriesRetentionDeadline > 0 {
+			if br.bh.MaxTimestamp < s.seriesRetentionDeadline {
+				// Skip the block, since it contains only data outside the retention filter for the series.
+				continue
+			}
+			// Samples outside the retention filter may be left in the block until the filter is applied during background merge.
+			// They are dropped when reading the block.
+			br.retentionDeadline = s.seriesRetentionDeadline
 		}
 		s.MetricBlockRef.BlockRef = s.ts.BlockRef
 		return true
@@ -260,6 +356,21 @@ func (s *Search) NextMetricBlock() bool {
 	return false
 }
 
+// getSeriesRetentionDeadline returns the retention deadline for the series with the given metricID according to -retentionFilter.
+//
+// 0 is returned if the series doesn't match retention filters, so -retentionPeriod is applied to it.
+func (s *Search) getSeriesRetentionDeadline(metricID uint64) int64 {
+	storage := s.idb.s
+	if !storage.hasRetentionFilters() {
+		return 0
+	}
+	retentionMsecs := storage.getRetentionMsecs(metricID)
+	if retentionMsecs >= storage.retentionMsecs {
+		return 0
+	}
+	return s.retentionDeadline + storage.retentionMsecs - retentionMsecs
+}
+
 // SearchQuery is used for sending search queries from vmselect to vmstorage.
 type SearchQuery struct {
 	// The time range for searching time series
@@ -16,6 +16,7 @@ import (
 	"unsafe"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/backupnames"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/backup/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bloomfilter"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
@@ -26,7 +27,9 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/memory"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/snapshot/snapshotutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage/exemplars"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage/metricnamestats"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage/metricsmetadata"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/workingsetcache"
@@ -69,6 +72,12 @@ type Storage struct {
 	cachePath      string
 	retentionMsecs int64
 
+	// retentionFilters contains retention filters sorted by retention.
+	retentionFilters []*RetentionFilter
+
+	// retentionFiltersCache is MetricID -> retention cache for the retentionFilters.
+	retentionFiltersCache *workingsetcache.Cache
+
 	// lock file for exclusive access to the storage on the given path.
 	flockF *os.File
 
@@ -158,6 +167,12 @@ type Storage struct {
 	deletedMetricIDs           atomic.Pointer[uint64set.Set]
 	deletedMetricIDsUpdateLock sync.Mutex
 
+	// tombstones contains samples deleted on the given time ranges, which weren't removed by background merges yet.
+	//
+	// tombstonesLock serializes tombstones updates.
+	tombstones     atomic.Pointer[tombstones]
+	tombstonesLock sync.Mutex
+
 	// missingMetricIDs maps metricID to the deadline in unix timestamp seconds
 	// after which all the indexdb entries for the given metricID
 	// must be deleted if index entry isn't found by the given metricID.
@@ -172,15 +187,37 @@ type Storage struct {
 	isReadOnly atomic.Bool
 
 	metricsTracker *metricnamestats.Tracker
+
+	// metricsMetadata holds TYPE, HELP and UNIT metadata for the ingested metric families.
+	metricsMetadata *metricsmetadata.Store
+
+	// exemplars holds the last exemplars per every series.
+	exemplars *exemplars.Store
+
+	// missingTSIDExemplars is the number of exemplars dropped because of missing TSID for the series.
+	missingTSIDExemplars atomic.Uint64
+
+	// offloader offloads old partitions to remote storage. It is nil if offloading is disabled.
+	offloader *offloader
 }
 
 // OpenOptions optional args for MustOpenStorage
 type OpenOptions struct {
 	Retention             time.Duration
+	RetentionFilters      []*RetentionFilter
 	MaxHourlySeries       int
 	MaxDailySeries        int
 	DisablePerDayIndex    bool
 	TrackMetricNamesStats bool
+
+	// OffloadFS is an optional remote storage for offloading data of partitions older than OffloadAfter.
+	OffloadFS common.RemoteFS
+
+	// OffloadAfter is the age of partitions to offload to OffloadFS.
+	OffloadAfter time.Duration
+
+	// OffloadCacheSize is the maximum size in bytes of the local cache for data read from OffloadFS.
+	OffloadCacheSize uint64
 }
 
 // MustOpenStorage opens storage on the given path with the given retentionMsecs.
@@ -241,6 +278,10 @@ func MustOpenStorage(path string, opts OpenOptions) *Storage {
 	s.metricIDCache = s.mustLoadCache("metricID_tsid", mem/16)
 	s.metricNameCache = s.mustLoadCache("metricID_metricName", mem/10)
 	s.dateMetricIDCache = newDateMetricIDCache()
+	if len(opts.RetentionFilters) > 0 {
+		s.retentionFilters = opts.RetentionFilters
+		s.retentionFiltersCache = workingsetcache.New(mem / 64)
+	}
 
 	hour := fasttime.UnixHour()
 	hmCurr := s.mustLoadHourMetricIDs(hour, "curr_hour_metric_ids")
@@ -263,11 +304,15 @@ func MustOpenStorage(path string, opts OpenOptions) *Storage {
 		}
 	}
 
+	s.exemplars = exemplars.MustLoadFrom(filepath.Join(s.cachePath, "exemplars"), maxExemplarsPerSeries, uint64(getExemplarsCacheSize()), exemplarsRetention)
+
 	// Load metadata
 	metadataDir := filepath.Join(path, metadataDirname)
 	isEmptyDB := !fs.IsPathExist(filepath.Join(path, indexdbDirname))
 	fs.MustMkdirIfNotExist(metadataDir)
 	s.minTimestampForCompositeIndex = mustGetMinTimestampForCompositeIndex(metadataDir, isEmptyDB)
+	s.metricsMetadata = metricsmetadata.MustLoadFrom(filepath.Join(metadataDir, metricsMetadataFilename), uint64(getMetricsMetadataCacheSize()), metricsMetadataRetention)
+	s.tombstones.Store(mustLoadTombstones(filepath.Join(metadataDir, tombstonesFilename)))
 
 	s.disablePerDayIndex = opts.DisablePerDayIndex
 
@@ -313,6 +358,10 @@ func MustOpenStorage(path string, opts OpenOptions) *Storage {
 
 	// Load data
 	tablePath := filepath.Join(path, dataDirname)
+	if opts.OffloadFS != nil {
+		cachePath := filepath.Join(s.cachePath, offloadedChunksDirname)
+		s.offloader = newOffloader(opts.OffloadFS, opts.OffloadAfter, tablePath, cachePath, opts.OffloadCacheSize)
+	}
 	tb := mustOpenTable(tablePath, s)
 	s.tb = tb
 
@@ -351,6 +400,61 @@ func getMetricNamesStatsCacheSize() int {
 	return maxMetricNamesStatsCacheSize
 }
 
+var metricsMetadataRetention time.Duration
+
+// SetMetricsMetadataRetention sets the retention for metrics metadata.
+//
+// Metadata, which wasn't seen during the retention, is removed. The retention isn't applied if d <= 0.
+func SetMetricsMetadataRetention(d time.Duration) {
+	metricsMetadataRetention = d
+}
+
+var maxMetricsMetadataCacheSize int
+
+// SetMetricsMetadataCacheSize overrides the default size of storage/metricsMetadata
+func SetMetricsMetadataCacheSize(size int) {
+	maxMetricsMetadataCacheSize = size
+}
+
+func getMetricsMetadataCacheSize() int {
+	if maxMetricsMetadataCacheSize <= 0 {
+		return memory.Allowed() / 100
+	}
+	return maxMetricsMetadataCacheSize
+}
+
+var maxExemplarsPerSeries int
+
+// SetMaxExemplarsPerSeries sets the maximum number of exemplars to store per each series.
+//
+// Exemplars aren't stored if n <= 0.
+func SetMaxExemplarsPerSeries(n int) {
+	maxExemplarsPerSeries = n
+}
+
+var exemplarsRetention time.Duration
+
+// SetExemplarsRetention sets the retention for exemplars.
+//
+// Exemplars older than the retention are removed. The retention isn't applied if d <= 0.
+func SetExemplarsRetention(d time.Duration) {
+	exemplarsRetention = d
+}
+
+var maxExemplarsCacheSize int
+
+// SetExemplarsCacheSize overrides the default size of storage/exemplars
+func SetExemplarsCacheSize(size int) {
+	maxExemplarsCacheSize = size
+}
+
+func getExemplarsCacheSize() int {
+	if maxExemplarsCacheSize <= 0 {
+		return memory.Allowed() / 100
+	}
+	return maxExemplarsCacheSize
+}
+
 func (s *Storage) getDeletedMetricIDs() *uint64set.Set {
 	return s.deletedMetricIDs.Load()
 }
@@ -420,6 +524,8 @@ func (s *Storage) MustCreateSnapshot() string {
 
 	fs.MustSyncPath(dstDataDir)
 
+	// Persist the metrics metadata, so it gets into the snapshot.
+	s.metricsMetadata.MustSave()
 	srcMetadataDir := filepath.Join(srcDir, metadataDirname)
 	dstMetadataDir := filepath.Join(dstDir, metadataDirname)
 	fs.MustCopyDirectory(srcMetadataDir, dstMetadataDir)
@@ -620,6 +726,25 @@ type Metrics struct {
 	MetricNamesUsageTrackerSizeBytes    uint64
 	MetricNamesUsageTrackerSizeMaxBytes uint64
 
+	MetricsMetadataSize         uint64
+	MetricsMetadataSizeBytes    uint64
+	MetricsMetadataSizeMaxBytes uint64
+	MetricsMetadataDroppedRows  uint64
+	MetricsMetadataEvictedRows  uint64
+	MetricsMetadataExpiredRows  uint64
+
+	ExemplarsSize                uint64
+	ExemplarsSizeBytes           uint64
+	ExemplarsSizeMaxBytes        uint64
+	ExemplarsDropped             uint64
+	ExemplarsEvicted             uint64
+	ExemplarsExpired             uint64
+	ExemplarsMissingTSIDsDropped uint64
+
+	PendingTombstones uint64
+
+	offloaderMetrics
+
 	IndexDBMetrics IndexDBMetrics
 	TableMetrics   TableMetrics
 }
@@ -720,6 +845,31 @@ func (s *Storage) UpdateMetrics(m *Metrics) {
 	m.MetricNamesUsageTrackerSize = tm.CurrentItemsCount
 	m.MetricNamesUsageTrackerSizeMaxBytes = tm.MaxSizeBytes
 
+	var mm metricsmetadata.Metrics
+	s.metricsMetadata.UpdateMetrics(&mm)
+	m.MetricsMetadataSize = mm.ItemsCount
+	m.MetricsMetadataSizeBytes = mm.SizeBytes
+	m.MetricsMetadataSizeMaxBytes = mm.MaxSizeBytes
+	m.MetricsMetadataDroppedRows = mm.DroppedRows
+	m.MetricsMetadataEvictedRows = mm.EvictedRows
+	m.MetricsMetadataExpiredRows = mm.ExpiredRows
+
+	var em exemplars.Metrics
+	s.exemplars.UpdateMetrics(&em)
+	m.ExemplarsSize = em.ItemsCount
+	m.ExemplarsSizeBytes = em.SizeBytes
+	m.ExemplarsSizeMaxBytes = em.MaxSizeBytes
+	m.ExemplarsDropped = em.DroppedExemplars
+	m.ExemplarsEvicted = em.EvictedExemplars
+	m.ExemplarsExpired = em.ExpiredExemplars
+	m.ExemplarsMissingTSIDsDropped = s.missingTSIDExemplars.Load()
+
+	m.PendingTombstones = uint64(len(s.getTombstones().items))
+
+	if s.offloader != nil {
+		s.offloader.UpdateMetrics(&m.offloaderMetrics)
+	}
+
 	d := s.nextRetentionSeconds()
 	if d < 0 {
 		d = 0
@@ -970,6 +1120,9 @@ func (s *Storage) MustClose() {
 	s.metricIDCache.Stop()
 	s.mustSaveCache(s.metricNameCache, "metricID_metricName")
 	s.metricNameCache.Stop()
+	if s.retentionFiltersCache != nil {
+		s.retentionFiltersCache.Stop()
+	}
 
 	hmCurr := s.currHourMetricIDs.Load()
 	s.mustSaveHourMetricIDs(hmCurr, "curr_hour_metric_ids")
@@ -980,6 +1133,8 @@ func (s *Storage) MustClose() {
 	s.mustSaveNextDayMetricIDs(nextDayMetricIDs)
 
 	s.metricsTracker.MustClose()
+	s.metricsMetadata.MustClose()
+	s.exemplars.MustClose()
 	// Release lock file.
 	fs.MustClose(s.flockF)
 	s.flockF = nil
@@ -1711,6 +1866,36 @@ func (s *Storage) GetTSDBStatus(qt *querytracer.Tracer, tfss []*TagFilters, date
 	if err != nil {
 		return nil, err
 	}
+	s.addSeriesQueryStats(res)
+	return res, nil
+}
+
+// GetTSDBStatusOnDateRange returns TSDB status data for /api/v1/status/tsdb on the [minDate ... maxDate] date range.
+//
+// The status is calculated for unique series seen on the date range. It also contains per-day series churn stats,
+// which are calculated from the per-day index. That's why the status cannot be obtained if -disablePerDayIndex flag is set.
+func (s *Storage) GetTSDBStatusOnDateRange(qt *querytracer.Tracer, tfss []*TagFilters, minDate, maxDate uint64, focusLabel string, topN, maxMetrics int, deadline uint64) (*TSDBStatus, error) {
+	if s.disablePerDayIndex {
+		return nil, fmt.Errorf("tsdb status on a date range cannot be obtained when -disablePerDayIndex command-line flag is set")
+	}
+	if minDate > maxDate {
+		return nil, fmt.Errorf("the start date cannot exceed the end date; got %d vs %d", minDate, maxDate)
+	}
+	if days := maxDate - minDate + 1; days > maxDaysForPerDaySearch {
+		return nil, fmt.Errorf("the date range cannot exceed %d days; got %d days", maxDaysForPerDaySearch, days)
+	}
+
+	idb, putIndexDB := s.getCurrIndexDB()
+	defer putIndexDB()
+	res, err := idb.GetTSDBStatusOnDateRange(qt, tfss, minDate, maxDate, focusLabel, topN, maxMetrics, deadline)
+	if err != nil {
+		return nil, err
+	}
+	s.addSeriesQueryStats(res)
+	return res, nil
+}
+
+func (s *Storage) addSeriesQueryStats(res *TSDBStatus) {
 	if s.metricsTracker != nil && len(res.SeriesCountByMetricName) > 0 {
 		// for performance reason always check if metricsTracker is configured
 		names := make([]string, len(res.SeriesCountByMetricName))
@@ -1719,7 +1904,6 @@ func (s *Storage) GetTSDBStatus(qt *querytracer.Tracer, tfss []*TagFilters, date
 		}
 		res.SeriesQueryStatsByMetricName = s.metricsTracker.GetStatRecordsForNames(0, 0, names)
 	}
-	return res, nil
 }
 
 // MetricRow is a metric to insert into storage.
@@ -1792,6 +1976,19 @@ func (s *Storage) ForceMergePartitions(partitionNamePrefix string) error {
 	return s.tb.ForceMergePartitions(partitionNamePrefix)
 }
 
+// DetachPartition detaches the partition with the given name from s.
+//
+// The detached partition is moved to the `detached` directory inside the data directory,
+// where it can be either removed or attached back via AttachPartition.
+func (s *Storage) DetachPartition(name string) error {
+	return s.tb.DetachPartition(name)
+}
+
+// AttachPartition attaches the partition with the given name from the `detached` directory inside the data directory.
+func (s *Storage) AttachPartition(name string) error {
+	return s.tb.AttachPartition(name)
+}
+
 // AddRows adds the given mrs to s.
 //
 // The caller should limit the number of concurrent AddRows calls to the number
@@ -2983,3 +3180,118 @@ func (s *Storage) GetMetricNamesStats(_ *querytracer.Tracer, limit, le int, matc
 func (s *Storage) ResetMetricNamesStats(_ *querytracer.Tracer) {
 	s.metricsTracker.Reset(s.tsidCache.Reset)
 }
+
+// MetricMetadata contains TYPE, HELP and UNIT metadata for a metric family.
+type MetricMetadata = metricsmetadata.Row
+
+// AddMetricsMetadata adds the given mms to s.
+func (s *Storage) AddMetricsMetadata(mms []MetricMetadata) {
+	s.metricsMetadata.Add(mms)
+}
+
+// GetMetricsMetadata appends metadata for the ingested metric families to dst and returns the result.
+//
+// If metricName is non-empty, then only metadata for the given metric family is returned.
+// limit limits the number of returned metric families, while limitPerMetric limits the number of metadata entries per each metric family.
+// Zero limits mean no limit.
+func (s *Storage) GetMetricsMetadata(qt *querytracer.Tracer, dst []MetricMetadata, metricName string, limit, limitPerMetric int) []MetricMetadata {
+	qt = qt.NewChild("get metrics metadata for metric=%q, limit=%d, limitPerMetric=%d", metricName, limit, limitPerMetric)
+	dstLen := len(dst)
+	dst = s.metricsMetadata.Get(dst, metricName, limit, limitPerMetric)
+	qt.Donef("found %d entries", len(dst)-dstLen)
+	return dst
+}
+
+// Exemplar is an exemplar for a time series.
+type Exemplar = exemplars.Exemplar
+
+// ExemplarLabel is a label for Exemplar.
+type ExemplarLabel = exemplars.Label
+
+// ExemplarRow is an exemplar for the series with the given MetricNameRaw.
+type ExemplarRow struct {
+	// MetricNameRaw contains raw metric name, which must be decoded
+	// with MetricName.UnmarshalRaw.
+	MetricNameRaw []byte
+
+	Exemplar Exemplar
+}
+
+// IsExemplarsEnabled returns true if s stores exemplars.
+func (s *Storage) IsExemplarsEnabled() bool {
+	return s.exemplars.IsEnabled()
+}
+
+// AddExemplars adds the given ers to s.
+//
+// Exemplars are stored only for series, which were already registered via AddRows.
+// Exemplars for unknown series are dropped.
+func (s *Storage) AddExemplars(ers []ExemplarRow) {
+	if !s.exemplars.IsEnabled() {
+		return
+	}
+	var genTSID generationTSID
+	for i := range ers {
+		er := &ers[i]
+		if !s.getTSIDFromCache(&genTSID, er.MetricNameRaw) {
+			s.missingTSIDExemplars.Add(1)
+			continue
+		}
+		s.exemplars.Add(genTSID.TSID.MetricID, &er.Exemplar)
+	}
+}
+
+// SeriesExemplars contains exemplars for a single series.
+type SeriesExemplars struct {
+	// MetricName is marshaled MetricName for the series.
+	MetricName []byte
+
+	// Exemplars contains exemplars for the series sorted by timestamp.
+	Exemplars []Exemplar
+}
+
+// SearchExemplars returns exemplars on the given tr for series matching the given tfss.
+//
+// Series without exemplars on the given tr are skipped.
+func (s *Storage) SearchExemplars(qt *querytracer.Tracer, tfss []*TagFilters, tr TimeRange, maxMetrics int, deadline uint64) ([]SeriesExemplars, error) {
+	qt = qt.NewChild("search for exemplars: filters=%s, timeRange=%s", tfss, &tr)
+	defer qt.Done()
+
+	if !s.exemplars.IsEnabled() {
+		qt.Printf("exemplars storage is disabled")
+		return nil, nil
+	}
+	idb, putIndexDB := s.getCurrIndexDB()
+	defer putIndexDB()
+	metricIDs, err := idb.searchMetricIDs(qt, tfss, s.adjustTimeRange(tr), maxMetrics, deadline)
+	if err != nil {
+		return nil, err
+	}
+	var ses []SeriesExemplars
+	var es []Exemplar
+	var metricName []byte
+	for i, metricID := range metricIDs {
+		if i&paceLimiterSlowIterationsMask == 0 {
+			if err := checkSearchDeadlineAndPace(deadline); err != nil {
+				return nil, err
+			}
+		}
+		es = s.exemplars.Get(es[:0], metricID, tr.MinTimestamp, tr.MaxTimestamp)
+		if len(es) == 0 {
+			continue
+		}
+		var ok bool
+		metricName, ok = idb.searchMetricName(metricName[:0], metricID, false)
+		if !ok {
+			// Skip missing metricName for metricID.
+			// It should be automatically fixed. See indexDB.searchMetricNameWithCache for details.
+			continue
+		}
+		ses = append(ses, SeriesExemplars{
+			MetricName: append([]byte{}, metricName...),
+			Exemplars:  append([]Exemplar{}, es...),
+		})
+	}
+	qt.Printf("found exemplars for %d series out of %d matching series", len(ses), len(metricIDs))
+	return ses, nil
+}
@@ -527,6 +527,108 @@ func TestNextRetentionDeadlineSeconds(t *testing.T) {
 	f("2024-04-29T00:00:00Z", 451*24*time.Hour, 0, "2024-05-01T04:00:00Z")
 }
 
+func TestStorageMetricsMetadata(t *testing.T) {
+	path := t.Name()
+	mms := []MetricMetadata{
+		{MetricFamilyName: "foo", Type: 1, Help: "foo help"},
+		{MetricFamilyName: "bar", Type: 2, Unit: "seconds"},
+	}
+	s := MustOpenStorage(path, OpenOptions{})
+	s.AddMetricsMetadata(mms)
+	snapshotName := s.MustCreateSnapshot()
+	s.MustClose()
+
+	// Verify the metadata is persisted across restarts
+	mmsExpected := []MetricMetadata{mms[1], mms[0]}
+	s = MustOpenStorage(path, OpenOptions{})
+	result := s.GetMetricsMetadata(nil, nil, "", 0, 0)
+	if !reflect.DeepEqual(result, mmsExpected) {
+		t.Fatalf("unexpected metadata\ngot\n%+v\nwant\n%+v", result, mmsExpected)
+	}
+	result = s.GetMetricsMetadata(nil, nil, "foo", 0, 0)
+	if !reflect.DeepEqual(result, mmsExpected[1:]) {
+		t.Fatalf("unexpected metadata for foo\ngot\n%+v\nwant\n%+v", result, mmsExpected[1:])
+	}
+	s.MustClose()
+
+	// Verify the metadata is included into snapshot
+	snapshotMetadataPath := filepath.Join(path, snapshotsDirname, snapshotName, metadataDirname, metricsMetadataFilename)
+	if !vmfs.IsPathExist(snapshotMetadataPath) {
+		t.Fatalf("missing metrics metadata in the snapshot at %q", snapshotMetadataPath)
+	}
+
+	vmfs.MustRemoveAll(path)
+}
+
+func TestStorageExemplars(t *testing.T) {
+	defer testRemoveAll(t)
+
+	SetMaxExemplarsPerSeries(2)
+	defer SetMaxExemplarsPerSeries(0)
+
+	tr := TimeRange{
+		MinTimestamp: time.Now().Add(-time.Hour).UnixMilli(),
+		MaxTimestamp: time.Now().UnixMilli(),
+	}
+	mnFoo := MetricName{MetricGroup: []byte("foo")}
+	mnBar := MetricName{MetricGroup: []byte("bar")}
+	mrs := []MetricRow{
+		{MetricNameRaw: mnFoo.marshalRaw(nil), Timestamp: tr.MaxTimestamp, Value: 1},
+		{MetricNameRaw: mnBar.marshalRaw(nil), Timestamp: tr.MaxTimestamp, Value: 2},
+	}
+	e := Exemplar{
+		Labels: []ExemplarLabel{
+			{Name: "trace_id", Value: "abc"},
+		},
+		Value:     0.5,
+		Timestamp: tr.MaxTimestamp,
+	}
+	mnMissing := MetricName{MetricGroup: []byte("missing")}
+	ers := []ExemplarRow{
+		{MetricNameRaw: mrs[0].MetricNameRaw, Exemplar: e},
+		{MetricNameRaw: mnMissing.marshalRaw(nil), Exemplar: e},
+	}
+
+	s := MustOpenStorage(t.Name(), OpenOptions{})
+	if !s.IsExemplarsEnabled() {
+		t.Fatalf("exemplars must be enabled")
+	}
+	s.AddRows(mrs, defaultPrecisionBits)
+	s.AddExemplars(ers)
+	s.DebugFlush()
+
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.ExemplarsSize != 1 {
+		t.Fatalf("unexpected ExemplarsSize; got %d; want 1", m.ExemplarsSize)
+	}
+	if m.ExemplarsMissingTSIDsDropped != 1 {
+		t.Fatalf("unexpected ExemplarsMissingTSIDsDropped; got %d; want 1", m.ExemplarsMissingTSIDsDropped)
+	}
+	s.MustClose()
+
+	// Verify the exemplars are persisted across restarts
+	s = MustOpenStorage(t.Name(), OpenOptions{})
+	defer s.MustClose()
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("foo|bar"), false, true); err != nil {
+		t.Fatalf("unexpected error in TagFilters.Add: %v", err)
+	}
+	ses, err := s.SearchExemplars(nil, []*TagFilters{tfs}, tr, 1e3, noDeadline)
+	if err != nil {
+		t.Fatalf("unexpected error in SearchExemplars: %s", err)
+	}
+	sesExpected := []SeriesExemplars{
+		{
+			MetricName: mnFoo.Marshal(nil),
+			Exemplars:  []Exemplar{e},
+		},
+	}
+	if !reflect.DeepEqual(ses, sesExpected) {
+		t.Fatalf("unexpected exemplars\ngot\n%+v\nwant\n%+v", ses, sesExpected)
+	}
+}
+
 func TestStorageOpenClose(t *testing.T) {
 	path := "TestStorageOpenClose"
 	opts := OpenOptions{
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -34,11 +35,27 @@ type table struct {
 	ptws     []*partitionWrapper
 	ptwsLock sync.Mutex
 
+	// detachingPartitions contains names for partitions, which are being detached.
+	//
+	// It is protected by ptwsLock.
+	detachingPartitions map[string]struct{}
+
+	// attachingPartitions contains names for partitions, which are being attached.
+	//
+	// It is protected by ptwsLock.
+	attachingPartitions map[string]struct{}
+
+	// partitionsMoveLock prevents from moving partition directories while orphaned chunks are removed from remote storage.
+	partitionsMoveLock sync.Mutex
+
 	stopCh chan struct{}
 
-	retentionWatcherWG  sync.WaitGroup
-	finalDedupWatcherWG sync.WaitGroup
-	forceMergeWG        sync.WaitGroup
+	retentionWatcherWG        sync.WaitGroup
+	finalDedupWatcherWG       sync.WaitGroup
+	retentionFiltersWatcherWG sync.WaitGroup
+	tombstonesWatcherWG       sync.WaitGroup
+	offloadWatcherWG          sync.WaitGroup
+	forceMergeWG              sync.WaitGroup
 }
 
 // partitionWrapper provides refcounting mechanism for the partition.
@@ -49,6 +66,11 @@ type partitionWrapper struct {
 	// if mustDrop is true, then the partition must be dropped after refCount reaches zero.
 	mustDrop atomic.Bool
 
+	// closedCh is closed after the partition is closed if it isn't nil.
+	//
+	// It is used for waiting until the detached partition is closed.
+	closedCh chan struct{}
+
 	pt *partition
 }
 
@@ -68,6 +90,10 @@ func (ptw *partitionWrapper) decRef() {
 	// refCount is zero. Close the partition.
 	ptw.pt.MustClose()
 
+	if ptw.closedCh != nil {
+		close(ptw.closedCh)
+	}
+
 	if !ptw.mustDrop.Load() {
 		ptw.pt = nil
 		return
@@ -117,6 +143,9 @@ func mustOpenTable(path string, s *Storage) *table {
 		bigPartitionsPath:   bigPartitionsPath,
 		s:                   s,
 
+		detachingPartitions: make(map[string]struct{}),
+		attachingPartitions: make(map[string]struct{}),
+
 		stopCh: make(chan struct{}),
 	}
 	for _, pt := range pts {
@@ -124,6 +153,9 @@ func mustOpenTable(path string, s *Storage) *table {
 	}
 	tb.startRetentionWatcher()
 	tb.startFinalDedupWatcher()
+	tb.startRetentionFiltersWatcher()
+	tb.startTombstonesWatcher()
+	tb.startOffloadWatcher()
 	return tb
 }
 
@@ -181,6 +213,9 @@ func (tb *table) MustClose() {
 	close(tb.stopCh)
 	tb.retentionWatcherWG.Wait()
 	tb.finalDedupWatcherWG.Wait()
+	tb.retentionFiltersWatcherWG.Wait()
+	tb.tombstonesWatcherWG.Wait()
+	tb.offloadWatcherWG.Wait()
 	tb.forceMergeWG.Wait()
 
 	tb.ptwsLock.Lock()
@@ -355,6 +390,20 @@ func (tb *table) MustAddRows(rows []rawRow) {
 			// Silently skip row outside retention, since it should be deleted anyway.
 			continue
 		}
+		if len(tb.detachingPartitions) > 0 {
+			if _, ok := tb.detachingPartitions[timestampToPartitionName(r.Timestamp)]; ok {
+				// Silently skip row for the partition, which is being detached,
+				// since the partition directory cannot be created until the detach is complete.
+				continue
+			}
+		}
+		if len(tb.attachingPartitions) > 0 {
+			if _, ok := tb.attachingPartitions[timestampToPartitionName(r.Timestamp)]; ok {
+				// Silently skip row for the partition, which is being attached,
+				// since the partition directory is already occupied by the attached partition.
+				continue
+			}
+		}
 
 		// Make sure the partition for the r hasn't been added by another goroutines.
 		ptFound := false
@@ -447,8 +496,8 @@ func (tb *table) startFinalDedupWatcher() {
 }
 
 func (tb *table) finalDedupWatcher() {
-	if !isDedupEnabled() {
-		// Deduplication is disabled.
+	if !isDedupEnabled() && !isDownsamplingEnabled() {
+		// Deduplication and downsampling are disabled.
 		return
 	}
 	f := func() {
@@ -503,6 +552,168 @@ func (tb *table) finalDedupWatcher() {
 	}
 }
 
+func (tb *table) startRetentionFiltersWatcher() {
+	tb.retentionFiltersWatcherWG.Add(1)
+	go func() {
+		tb.retentionFiltersWatcher()
+		tb.retentionFiltersWatcherWG.Done()
+	}()
+}
+
+func (tb *table) retentionFiltersWatcher() {
+	if !tb.s.hasRetentionFilters() {
+		// Retention filters are disabled.
+		return
+	}
+	f := func() {
+		ptws := tb.GetPartitions(nil)
+		defer tb.PutPartitions(ptws)
+		currentTimestamp := int64(fasttime.UnixTimestamp() * 1000)
+		var ptwsToProcess []*partitionWrapper
+		for _, ptw := range ptws {
+			if !ptw.pt.isRetentionFiltersNeeded(currentTimestamp) {
+				continue
+			}
+			ptw.pt.isRetentionFiltersScheduled.Store(true)
+			ptwsToProcess = append(ptwsToProcess, ptw)
+		}
+		for _, ptw := range ptwsToProcess {
+			if err := ptw.pt.runRetentionFilters(tb.stopCh); err != nil {
+				logger.Errorf("cannot apply retention filters to partition %s: %s", ptw.pt.name, err)
+			}
+			ptw.pt.isRetentionFiltersScheduled.Store(false)
+		}
+	}
+
+	d := timeutil.AddJitterToDuration(time.Hour)
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-tb.stopCh:
+			return
+		case <-t.C:
+			f()
+		}
+	}
+}
+
+func (tb *table) startTombstonesWatcher() {
+	tb.tombstonesWatcherWG.Add(1)
+	go func() {
+		tb.tombstonesWatcher()
+		tb.tombstonesWatcherWG.Done()
+	}()
+}
+
+func (tb *table) tombstonesWatcher() {
+	d := timeutil.AddJitterToDuration(tombstonesCheckInterval)
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-tb.stopCh:
+			return
+		case <-t.C:
+			tb.applyTombstones()
+		}
+	}
+}
+
+// tombstonesCheckInterval is the interval for checking whether there are tombstones to apply to parts.
+var tombstonesCheckInterval = time.Minute
+
+// applyTombstones merges parts with samples deleted by tombstones and then drops tombstones applied to all the parts.
+func (tb *table) applyTombstones() {
+	tss := tb.s.getTombstones()
+	if len(tss.items) == 0 {
+		return
+	}
+
+	ptws := tb.GetPartitions(nil)
+	defer tb.PutPartitions(ptws)
+
+	for _, ptw := range ptws {
+		select {
+		case <-tb.stopCh:
+			return
+		default:
+		}
+		if err := ptw.pt.applyTombstones(tss, tb.stopCh); err != nil {
+			logger.Errorf("cannot apply tombstones to partition %s: %s", ptw.pt.name, err)
+		}
+	}
+
+	tb.s.dropAppliedTombstones(func(ts *tombstone) bool {
+		for _, ptw := range ptws {
+			if ptw.pt.hasPartsForTombstone(ts) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (tb *table) startOffloadWatcher() {
+	tb.offloadWatcherWG.Add(1)
+	go func() {
+		tb.offloadWatcher()
+		tb.offloadWatcherWG.Done()
+	}()
+}
+
+func (tb *table) offloadWatcher() {
+	if tb.s.offloader == nil {
+		// Offloading is disabled.
+		return
+	}
+
+	d := timeutil.AddJitterToDuration(offloadCheckInterval)
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-tb.stopCh:
+			return
+		case <-t.C:
+			tb.offloadPartitions()
+		}
+	}
+}
+
+// offloadPartitions offloads parts for partitions older than offloader.offloadAfter to remote storage
+// and removes chunks for parts, which are no longer offloaded, from remote storage.
+func (tb *table) offloadPartitions() {
+	o := tb.s.offloader
+
+	ptws := tb.GetPartitions(nil)
+	defer tb.PutPartitions(ptws)
+
+	deadline := timestampFromTime(time.Now()) - o.offloadAfter.Milliseconds()
+	for _, ptw := range ptws {
+		if ptw.pt.tr.MaxTimestamp >= deadline {
+			continue
+		}
+		if err := ptw.pt.offloadParts(o, tb.stopCh); err != nil {
+			if errors.Is(err, errForciblyStopped) {
+				return
+			}
+			logger.Errorf("cannot offload partition %s to %s: %s", ptw.pt.name, o.fs, err)
+		}
+	}
+
+	// Orphaned chunks are removed in the same goroutine as the upload,
+	// so chunks for the part being uploaded cannot be removed.
+	// Partitions cannot be detached or attached during the removal,
+	// since this may result in removal of chunks for the moved parts.
+	tb.partitionsMoveLock.Lock()
+	err := o.removeOrphanedChunks()
+	tb.partitionsMoveLock.Unlock()
+	if err != nil {
+		logger.Errorf("cannot remove orphaned chunks from %s: %s", o.fs, err)
+	}
+}
+
 // GetPartitions appends tb's partitions snapshot to dst and returns the result.
 //
 // The returned partitions must be passed to PutPartitions
@@ -0,0 +1,719 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
+)
+
+// DetachPartition detaches the partition with the given name from tb and moves it to the `detached` directory.
+//
+// The detached partition can be attached back via AttachPartition.
+func (tb *table) DetachPartition(name string) error {
+	var tr TimeRange
+	if err := tr.fromPartitionName(name); err != nil {
+		return fmt.Errorf("invalid partition name %q: %w", name, err)
+	}
+	dstPath := filepath.Join(tb.path, detachedDirname, name)
+	if fs.IsPathExist(dstPath) {
+		return fmt.Errorf("cannot detach partition %q, since the detached partition with the same name already exists at %q", name, dstPath)
+	}
+
+	tb.ptwsLock.Lock()
+	idx := slices.IndexFunc(tb.ptws, func(ptw *partitionWrapper) bool {
+		return ptw.pt.name == name
+	})
+	if idx < 0 {
+		tb.ptwsLock.Unlock()
+		return fmt.Errorf("partition %q doesn't exist", name)
+	}
+	ptw := tb.ptws[idx]
+	tb.ptws = slices.Delete(tb.ptws, idx, idx+1)
+	tb.detachingPartitions[name] = struct{}{}
+	closedCh := make(chan struct{})
+	ptw.closedCh = closedCh
+	tb.ptwsLock.Unlock()
+
+	logger.Infof("detaching partition %q to %q", name, dstPath)
+	startTime := time.Now()
+
+	// Remove the table reference from the partition and wait until it is closed
+	// after all the pending searches and background merges are done.
+	pt := ptw.pt
+	ptw.decRef()
+	<-closedCh
+
+	tb.partitionsMoveLock.Lock()
+	detachedPath := filepath.Join(tb.path, detachedDirname)
+	fs.MustMkdirIfNotExist(detachedPath)
+	fs.MustMkdirFailIfExist(dstPath)
+	mustMovePartitionDir(pt.smallPartsPath, filepath.Join(dstPath, smallDirname))
+	mustMovePartitionDir(pt.bigPartsPath, filepath.Join(dstPath, bigDirname))
+	fs.MustSyncPath(dstPath)
+	fs.MustSyncPath(detachedPath)
+	fs.MustSyncPath(tb.smallPartitionsPath)
+	fs.MustSyncPath(tb.bigPartitionsPath)
+	tb.partitionsMoveLock.Unlock()
+
+	// Write the series for the partition, so it could be attached to another storage.
+	if err := tb.writeDetachedSeries(dstPath, tr); err != nil {
+		logger.Errorf("cannot write series for the detached partition %q; the partition can be attached only to the storage with indexdb containing all its series: %s", name, err)
+	}
+
+	tb.ptwsLock.Lock()
+	delete(tb.detachingPartitions, name)
+	tb.ptwsLock.Unlock()
+
+	logger.Infof("partition %q has been detached to %q in %.3f seconds", name, dstPath, time.Since(startTime).Seconds())
+	return nil
+}
+
+// AttachPartition attaches the partition with the given name from the `detached` directory to tb.
+//
+// Parts of the attached partition are verified before attaching. The series from these parts, which are missing in indexdb,
+// are registered from the file written when detaching the partition, together with per-day index entries for the days with samples in the partition.
+func (tb *table) AttachPartition(name string) error {
+	var tr TimeRange
+	if err := tr.fromPartitionName(name); err != nil {
+		return fmt.Errorf("invalid partition name %q: %w", name, err)
+	}
+	srcPath := filepath.Join(tb.path, detachedDirname, name)
+	if !fs.IsPathExist(srcPath) {
+		return fmt.Errorf("cannot find detached partition %q at %q", name, srcPath)
+	}
+	srcSmallPath := filepath.Join(srcPath, smallDirname)
+	srcBigPath := filepath.Join(srcPath, bigDirname)
+
+	logger.Infof("verifying detached partition %q at %q", name, srcPath)
+	startTime := time.Now()
+	dpi, err := tb.verifyDetachedPartition(srcPath, tr)
+	if err != nil {
+		return fmt.Errorf("cannot attach partition %q from %q: %w", name, srcPath, err)
+	}
+
+	if err := tb.startAttachingPartition(name); err != nil {
+		return err
+	}
+	if !fs.IsPathExist(srcPath) {
+		tb.finishAttachingPartition(name, nil)
+		return fmt.Errorf("cannot find detached partition %q at %q; probably, it has been already attached", name, srcPath)
+	}
+
+	// Register the missing index entries before the partition becomes visible for queries.
+	idb, putIndexDB := tb.s.getCurrIndexDB()
+	dpi.register(idb)
+	putIndexDB()
+
+	smallPartsPath := filepath.Join(tb.smallPartitionsPath, name)
+	bigPartsPath := filepath.Join(tb.bigPartitionsPath, name)
+
+	tb.partitionsMoveLock.Lock()
+	mustMovePartitionDir(srcSmallPath, smallPartsPath)
+	mustMovePartitionDir(srcBigPath, bigPartsPath)
+	fs.MustSyncPath(tb.smallPartitionsPath)
+	fs.MustSyncPath(tb.bigPartitionsPath)
+	fs.MustRemoveDirAtomic(srcPath)
+	tb.partitionsMoveLock.Unlock()
+
+	// Open the partition without holding tb.ptwsLock, since this may take a while for big partitions,
+	// while tb.ptwsLock blocks data ingestion and querying.
+	pt := mustOpenPartition(smallPartsPath, bigPartsPath, tb.s)
+	tb.finishAttachingPartition(name, pt)
+
+	logger.Infof("partition %q has been attached from %q in %.3f seconds", name, srcPath, time.Since(startTime).Seconds())
+	return nil
+}
+
+// startAttachingPartition marks the partition with the given name as being attached.
+//
+// finishAttachingPartition must be called after the partition is attached.
+func (tb *table) startAttachingPartition(name string) error {
+	tb.ptwsLock.Lock()
+	defer tb.ptwsLock.Unlock()
+
+	for _, ptw := range tb.ptws {
+		if ptw.pt.name == name {
+			return fmt.Errorf("cannot attach partition %q, since it already exists; detach the existing partition before attaching the new one", name)
+		}
+	}
+	if _, ok := tb.detachingPartitions[name]; ok {
+		return fmt.Errorf("cannot attach partition %q, since it is being detached", name)
+	}
+	if _, ok := tb.attachingPartitions[name]; ok {
+		return fmt.Errorf("cannot attach partition %q, since it is already being attached", name)
+	}
+	tb.attachingPartitions[name] = struct{}{}
+	return nil
+}
+
+// finishAttachingPartition adds pt to tb if it isn't nil and removes the attaching mark for the partition with the given name.
+func (tb *table) finishAttachingPartition(name string, pt *partition) {
+	tb.ptwsLock.Lock()
+	if pt != nil {
+		tb.addPartitionNolock(pt)
+	}
+	delete(tb.attachingPartitions, name)
+	tb.ptwsLock.Unlock()
+}
+
+// mustMovePartitionDir moves srcPath directory to dstPath.
+//
+// An empty dstPath directory is created if srcPath doesn't exist.
+func mustMovePartitionDir(srcPath, dstPath string) {
+	if !fs.IsPathExist(srcPath) {
+		fs.MustMkdirFailIfExist(dstPath)
+		return
+	}
+	if fs.IsPathExist(dstPath) {
+		logger.Panicf("FATAL: cannot move %q to %q, since the destination already exists", srcPath, dstPath)
+	}
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		logger.Panicf("FATAL: cannot move %q to %q: %s", srcPath, dstPath, err)
+	}
+}
+
+// verifyDetachedPartition verifies parts for the detached partition with the given tr at srcPath
+// and returns index entries, which must be registered in indexdb before attaching the partition.
+func (tb *table) verifyDetachedPartition(srcPath string, tr TimeRange) (*detachedPartitionIndex, error) {
+	ps, err := tb.readDetachedPartitionSeries(srcPath, tr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify that all the series from the partition are registered in indexdb or they can be registered from detachedSeriesFilename.
+	// Otherwise they cannot be found during querying.
+	// The series may be registered either in the current or in the previous indexdb.
+	var dpi *detachedPartitionIndex
+	seriesPath := filepath.Join(srcPath, detachedSeriesFilename)
+	tb.doIndexDBs(func(idbs []*indexDB) {
+		dpi, err = ps.prepareIndexDB(idbs, !tb.s.disablePerDayIndex, seriesPath)
+	})
+	return dpi, err
+}
+
+// readDetachedPartitionSeries verifies parts for the detached partition with the given tr at partitionPath
+// and returns the series from these parts.
+func (tb *table) readDetachedPartitionSeries(partitionPath string, tr TimeRange) (*detachedPartitionSeries, error) {
+	smallPartsPath := filepath.Join(partitionPath, smallDirname)
+	bigPartsPath := filepath.Join(partitionPath, bigDirname)
+	partNamesSmall, partNamesBig, err := readDetachedPartNames(smallPartsPath, bigPartsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ps := &detachedPartitionSeries{}
+	for _, partName := range partNamesSmall {
+		if err := tb.verifyDetachedPart(filepath.Join(smallPartsPath, partName), tr, ps); err != nil {
+			return nil, err
+		}
+	}
+	for _, partName := range partNamesBig {
+		if err := tb.verifyDetachedPart(filepath.Join(bigPartsPath, partName), tr, ps); err != nil {
+			return nil, err
+		}
+	}
+	return ps, nil
+}
+
+// writeDetachedSeries writes series for the detached partition with the given tr at partitionPath to detachedSeriesFilename.
+//
+// This allows attaching the partition to the storage, which doesn't contain these series in indexdb.
+func (tb *table) writeDetachedSeries(partitionPath string, tr TimeRange) error {
+	ps, err := tb.readDetachedPartitionSeries(partitionPath, tr)
+	if err != nil {
+		return err
+	}
+	seriesPath := filepath.Join(partitionPath, detachedSeriesFilename)
+	dmis := tb.s.getDeletedMetricIDs()
+	tb.doIndexDBs(func(idbs []*indexDB) {
+		err = ps.writeSeries(seriesPath, idbs, dmis)
+	})
+	return err
+}
+
+// doIndexDBs calls f for the current and the previous indexdb, which may contain series for tb.
+func (tb *table) doIndexDBs(f func(idbs []*indexDB)) {
+	idb, putIndexDB := tb.s.getCurrIndexDB()
+	defer putIndexDB()
+
+	hasExtDB := false
+	idb.doExtDB(func(extDB *indexDB) {
+		hasExtDB = true
+		f([]*indexDB{idb, extDB})
+	})
+	if !hasExtDB {
+		f([]*indexDB{idb})
+	}
+}
+
+// detachedPartitionSeries holds series found in the detached partition.
+type detachedPartitionSeries struct {
+	// metricIDs contains metricIDs for all the series in the partition.
+	metricIDs uint64set.Set
+
+	// dateMetricIDs contains metricIDs per every date with samples in the partition.
+	dateMetricIDs map[uint64]*uint64set.Set
+}
+
+// addBlock registers the series from the block with the given bh in ps.
+//
+// Dates are registered only for the first and the last sample of the block,
+// since the block may have no samples for the days between them.
+func (ps *detachedPartitionSeries) addBlock(bh *blockHeader) {
+	metricID := bh.TSID.MetricID
+	ps.metricIDs.Add(metricID)
+	ps.addDate(uint64(bh.MinTimestamp)/msecPerDay, metricID)
+	ps.addDate(uint64(bh.MaxTimestamp)/msecPerDay, metricID)
+}
+
+func (ps *detachedPartitionSeries) addDate(date, metricID uint64) {
+	if ps.dateMetricIDs == nil {
+		ps.dateMetricIDs = make(map[uint64]*uint64set.Set)
+	}
+	metricIDs := ps.dateMetricIDs[date]
+	if metricIDs == nil {
+		metricIDs = &uint64set.Set{}
+		ps.dateMetricIDs[date] = metricIDs
+	}
+	metricIDs.Add(metricID)
+}
+
+// writeSeries writes TSIDs and metric names for the series from ps to the file at path.
+//
+// The series are searched in idbs. Series from deletedMetricIDs are skipped.
+func (ps *detachedPartitionSeries) writeSeries(path string, idbs []*indexDB, deletedMetricIDs *uint64set.Set) error {
+	iss := getIndexSearches(idbs)
+	defer putIndexSearches(idbs, iss)
+
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("cannot create %q: %w", tmpPath, err)
+	}
+	bw := bufio.NewWriter(f)
+	var tsid TSID
+	var buf, metricName []byte
+	ps.metricIDs.ForEach(func(part []uint64) bool {
+		for _, metricID := range part {
+			if deletedMetricIDs.Has(metricID) {
+				continue
+			}
+			for _, is := range iss {
+				if !is.getTSIDByMetricID(&tsid, metricID) {
+					continue
+				}
+				var ok bool
+				metricName, ok = is.searchMetricName(metricName[:0], metricID)
+				if !ok {
+					continue
+				}
+				buf = marshalDetachedSeries(buf[:0], &tsid, metricName)
+				if _, err = bw.Write(buf); err != nil {
+					return false
+				}
+				break
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("cannot write series to %q: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("cannot move %q to %q: %w", tmpPath, path, err)
+	}
+	return nil
+}
+
+// marshalDetachedSeries appends the series with the given tsid and marshaled metricName to dst and returns the result.
+func marshalDetachedSeries(dst []byte, tsid *TSID, metricName []byte) []byte {
+	dst = tsid.Marshal(dst)
+	dst = encoding.MarshalUint32(dst, uint32(len(metricName)))
+	return append(dst, metricName...)
+}
+
+// readDetachedSeries calls f for every series from the file at path written by writeSeries.
+//
+// metricName passed to f is valid only during the call.
+func readDetachedSeries(path string, f func(tsid *TSID, metricName []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot open %q: %w", path, err)
+	}
+	defer fs.MustClose(file)
+
+	br := bufio.NewReader(file)
+	var tsid TSID
+	var buf []byte
+	for {
+		buf = slices.Grow(buf[:0], marshaledTSIDSize+4)[:marshaledTSIDSize+4]
+		if _, err := io.ReadFull(br, buf); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("cannot read series header from %q: %w", path, err)
+		}
+		if _, err := tsid.Unmarshal(buf[:marshaledTSIDSize]); err != nil {
+			return fmt.Errorf("cannot unmarshal TSID from %q: %w", path, err)
+		}
+		n := encoding.UnmarshalUint32(buf[marshaledTSIDSize:])
+		buf = slices.Grow(buf[:0], int(n))[:n]
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return fmt.Errorf("cannot read metric name for metricID=%d from %q: %w", tsid.MetricID, path, err)
+		}
+		if err := f(&tsid, buf); err != nil {
+			return err
+		}
+	}
+}
+
+func getIndexSearches(idbs []*indexDB) []*indexSearch {
+	iss := make([]*indexSearch, len(idbs))
+	for i, db := range idbs {
+		iss[i] = db.getIndexSearchInternal(noDeadline, true)
+	}
+	return iss
+}
+
+func putIndexSearches(idbs []*indexDB, iss []*indexSearch) {
+	for i, db := range idbs {
+		db.putIndexSearch(iss[i])
+	}
+}
+
+// detachedPartitionIndex contains index entries, which must be registered in indexdb before attaching the detached partition.
+type detachedPartitionIndex struct {
+	// series contains series, which need index entries, by metricID.
+	series map[uint64]*detachedSeries
+
+	// dateMetricIDs contains metricIDs with missing per-day index entries by date.
+	dateMetricIDs map[uint64][]uint64
+}
+
+// detachedSeries is a series from the detached partition.
+type detachedSeries struct {
+	tsid TSID
+
+	// metricName is marshaled MetricName for the series.
+	metricName []byte
+
+	// isRegistered is set if the series is already registered in indexdb, so only per-day index entries must be registered for it.
+	isRegistered bool
+}
+
+// prepareIndexDB returns index entries for the series from ps, which are missing in idbs.
+//
+// The missing series are read from the file at seriesPath written by writeSeries. The partition cannot be attached if some series are missing
+// both in idbs and in seriesPath, or if the series from seriesPath have distinct metric names in idbs.
+// Per-day index entries are prepared if checkPerDayIndex is set.
+func (ps *detachedPartitionSeries) prepareIndexDB(idbs []*indexDB, checkPerDayIndex bool, seriesPath string) (*detachedPartitionIndex, error) {
+	iss := getIndexSearches(idbs)
+	defer putIndexSearches(idbs, iss)
+
+	// searchSeries searches for the series with the given metricID in iss and returns it on success.
+	searchSeries := func(metricID uint64) (*detachedSeries, bool) {
+		for _, is := range iss {
+			metricName, ok := is.searchMetricName(nil, metricID)
+			if !ok {
+				continue
+			}
+			ds := &detachedSeries{
+				metricName:   metricName,
+				isRegistered: true,
+			}
+			if !is.getTSIDByMetricID(&ds.tsid, metricID) {
+				continue
+			}
+			return ds, true
+		}
+		return nil, false
+	}
+
+	var missingMetricIDs uint64set.Set
+	var metricName []byte
+	ps.metricIDs.ForEach(func(part []uint64) bool {
+		for _, metricID := range part {
+			ok := false
+			for _, is := range iss {
+				metricName, ok = is.searchMetricName(metricName[:0], metricID)
+				if ok {
+					break
+				}
+			}
+			if !ok {
+				missingMetricIDs.Add(metricID)
+			}
+		}
+		return true
+	})
+
+	dpi := &detachedPartitionIndex{
+		series: make(map[uint64]*detachedSeries),
+	}
+	if fs.IsPathExist(seriesPath) {
+		err := readDetachedSeries(seriesPath, func(tsid *TSID, metricName []byte) error {
+			metricID := tsid.MetricID
+			if missingMetricIDs.Has(metricID) {
+				dpi.series[metricID] = &detachedSeries{
+					tsid:       *tsid,
+					metricName: append([]byte{}, metricName...),
+				}
+				return nil
+			}
+			if !ps.metricIDs.Has(metricID) {
+				return nil
+			}
+			ds, ok := searchSeries(metricID)
+			if !ok {
+				return nil
+			}
+			if !bytes.Equal(ds.metricName, metricName) || ds.tsid != *tsid {
+				var mn, mnLocal MetricName
+				if err := mn.Unmarshal(metricName); err != nil {
+					return fmt.Errorf("cannot unmarshal metric name for metricID=%d from %q: %w", metricID, seriesPath, err)
+				}
+				if err := mnLocal.Unmarshal(ds.metricName); err != nil {
+					return fmt.Errorf("cannot unmarshal metric name for metricID=%d from indexdb: %w", metricID, err)
+				}
+				return fmt.Errorf("metricID=%d for the series %s from the partition is already used by the series %s in indexdb; "+
+					"the partition cannot be attached to this storage", metricID, &mn, &mnLocal)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		// The series, which are missing both in indexdb and in seriesPath, were deleted before detaching the partition.
+		// They are left unregistered, so they remain invisible for queries.
+	} else if missingMetricIDs.Len() > 0 {
+		return nil, fmt.Errorf("%d out of %d series aren't registered in indexdb (for example, metricID=%d), while %q is missing; "+
+			"the partition can be attached only to the storage with indexdb containing all its series", missingMetricIDs.Len(), ps.metricIDs.Len(), missingMetricIDs.AppendTo(nil)[0], seriesPath)
+	}
+	if !checkPerDayIndex {
+		return dpi, nil
+	}
+
+	dpi.dateMetricIDs = make(map[uint64][]uint64)
+	for date, metricIDs := range ps.dateMetricIDs {
+		metricIDs.ForEach(func(part []uint64) bool {
+			for _, metricID := range part {
+				if missingMetricIDs.Has(metricID) {
+					if _, ok := dpi.series[metricID]; ok {
+						dpi.dateMetricIDs[date] = append(dpi.dateMetricIDs[date], metricID)
+					}
+					continue
+				}
+				ok := false
+				for _, is := range iss {
+					if is.hasDateMetricIDNoExtDB(date, metricID) {
+						ok = true
+						break
+					}
+				}
+				if ok {
+					continue
+				}
+				if _, ok := dpi.series[metricID]; !ok {
+					ds, ok := searchSeries(metricID)
+					if !ok {
+						continue
+					}
+					dpi.series[metricID] = ds
+				}
+				dpi.dateMetricIDs[date] = append(dpi.dateMetricIDs[date], metricID)
+			}
+			return true
+		})
+	}
+	return dpi, nil
+}
+
+// register registers index entries from dpi in idb.
+func (dpi *detachedPartitionIndex) register(idb *indexDB) {
+	if len(dpi.series) == 0 {
+		return
+	}
+
+	is := idb.getIndexSearch(noDeadline)
+	defer idb.putIndexSearch(is)
+
+	var mn MetricName
+	unmarshalMetricName := func(ds *detachedSeries) {
+		if err := mn.Unmarshal(ds.metricName); err != nil {
+			logger.Panicf("BUG: cannot unmarshal metric name for metricID=%d: %s", ds.tsid.MetricID, err)
+		}
+	}
+	for _, ds := range dpi.series {
+		if ds.isRegistered {
+			continue
+		}
+		unmarshalMetricName(ds)
+		is.createGlobalIndexes(&ds.tsid, &mn)
+		updateNextUniqueMetricID(ds.tsid.MetricID)
+	}
+	for date, metricIDs := range dpi.dateMetricIDs {
+		for _, metricID := range metricIDs {
+			ds := dpi.series[metricID]
+			unmarshalMetricName(ds)
+			is.createPerDayIndexes(date, &ds.tsid, &mn)
+		}
+	}
+
+	// Make the registered entries visible for search before attaching the partition.
+	idb.tb.DebugFlush()
+	invalidateTagFiltersCache()
+}
+
+// updateNextUniqueMetricID makes sure generateUniqueMetricID returns values bigger than the metricID registered from the detached partition.
+//
+// Otherwise new series may get the same metricID, since the partition may be detached from another storage with bigger metricIDs.
+func updateNextUniqueMetricID(metricID uint64) {
+	for {
+		n := nextUniqueMetricID.Load()
+		if n >= metricID || nextUniqueMetricID.CompareAndSwap(n, metricID) {
+			return
+		}
+	}
+}
+
+func readDetachedPartNames(smallPartsPath, bigPartsPath string) ([]string, []string, error) {
+	partsFile := filepath.Join(smallPartsPath, partsFilename)
+	if !fs.IsPathExist(partsFile) {
+		// The partsFile may be missing for partitions created before v1.90.0.
+		return mustReadPartNamesFromDir(smallPartsPath), mustReadPartNamesFromDir(bigPartsPath), nil
+	}
+	data, err := os.ReadFile(partsFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read %q: %w", partsFile, err)
+	}
+	var partNames partNamesJSON
+	if err := json.Unmarshal(data, &partNames); err != nil {
+		return nil, nil, fmt.Errorf("cannot parse %q: %w", partsFile, err)
+	}
+	return partNames.Small, partNames.Big, nil
+}
+
+// verifyDetachedPart verifies the part at partPath for the partition with the given tr
+// and adds the series from the part to ps.
+func (tb *table) verifyDetachedPart(partPath string, tr TimeRange, ps *detachedPartitionSeries) error {
+	var ph partHeader
+	if err := ph.readMetadata(partPath); err != nil {
+		return err
+	}
+	if ph.MinTimestamp < tr.MinTimestamp || ph.MaxTimestamp > tr.MaxTimestamp {
+		return fmt.Errorf("time range [%d..%d] for the part %q is outside the partition time range %s", ph.MinTimestamp, ph.MaxTimestamp, partPath, &tr)
+	}
+
+	var timestampsSize, valuesSize uint64
+	if isOffloadedPart(partPath) {
+		if tb.s.offloader == nil {
+			return fmt.Errorf("the part %q is offloaded to remote storage, while remote storage isn't configured", partPath)
+		}
+		opi := mustReadOffloadedPartInfo(partPath)
+		timestampsSize = opi.TimestampsSize
+		valuesSize = opi.ValuesSize
+	} else {
+		var err error
+		timestampsSize, err = getFileSize(filepath.Join(partPath, timestampsFilename))
+		if err != nil {
+			return err
+		}
+		valuesSize, err = getFileSize(filepath.Join(partPath, valuesFilename))
+		if err != nil {
+			return err
+		}
+	}
+
+	metaindexPath := filepath.Join(partPath, metaindexFilename)
+	metaindexData, err := os.ReadFile(metaindexPath)
+	if err != nil {
+		return fmt.Errorf("cannot read %q: %w", metaindexPath, err)
+	}
+	mrs, err := unmarshalMetaindexRows(nil, bytes.NewReader(metaindexData))
+	if err != nil {
+		return fmt.Errorf("cannot parse %q: %w", metaindexPath, err)
+	}
+
+	indexPath := filepath.Join(partPath, indexFilename)
+	indexFile, err := os.Open(indexPath)
+	if err != nil {
+		return fmt.Errorf("cannot open %q: %w", indexPath, err)
+	}
+	defer fs.MustClose(indexFile)
+
+	var compressedData, data []byte
+	var bhs []blockHeader
+	blocksCount := uint64(0)
+	rowsCount := uint64(0)
+	for i := range mrs {
+		mr := &mrs[i]
+		compressedData = slices.Grow(compressedData[:0], int(mr.IndexBlockSize))[:mr.IndexBlockSize]
+		if _, err := indexFile.ReadAt(compressedData, int64(mr.IndexBlockOffset)); err != nil {
+			return fmt.Errorf("cannot read index block at offset %d from %q: %w", mr.IndexBlockOffset, indexPath, err)
+		}
+		data, err = encoding.DecompressZSTD(data[:0], compressedData)
+		if err != nil {
+			return fmt.Errorf("cannot decompress index block at offset %d from %q: %w", mr.IndexBlockOffset, indexPath, err)
+		}
+		bhs, err = unmarshalBlockHeaders(bhs[:0], data, int(mr.BlockHeadersCount))
+		if err != nil {
+			return fmt.Errorf("cannot parse index block at offset %d from %q: %w", mr.IndexBlockOffset, indexPath, err)
+		}
+		for j := range bhs {
+			bh := &bhs[j]
+			if bh.MinTimestamp < ph.MinTimestamp || bh.MaxTimestamp > ph.MaxTimestamp {
+				return fmt.Errorf("time range [%d..%d] for the block in %q is outside the part time range [%d..%d]", bh.MinTimestamp, bh.MaxTimestamp, indexPath, ph.MinTimestamp, ph.MaxTimestamp)
+			}
+			if bh.TimestampsBlockOffset+uint64(bh.TimestampsBlockSize) > timestampsSize {
+				return fmt.Errorf("the block in %q refers to data outside %s of %d bytes", indexPath, timestampsFilename, timestampsSize)
+			}
+			if bh.ValuesBlockOffset+uint64(bh.ValuesBlockSize) > valuesSize {
+				return fmt.Errorf("the block in %q refers to data outside %s of %d bytes", indexPath, valuesFilename, valuesSize)
+			}
+			rowsCount += uint64(bh.RowsCount)
+			ps.addBlock(bh)
+		}
+		blocksCount += uint64(len(bhs))
+	}
+	if blocksCount != ph.BlocksCount {
+		return fmt.Errorf("unexpected number of blocks in %q; got %d; want %d", partPath, blocksCount, ph.BlocksCount)
+	}
+	if rowsCount != ph.RowsCount {
+		return fmt.Errorf("unexpected number of rows in %q; got %d; want %d", partPath, rowsCount, ph.RowsCount)
+	}
+	return nil
+}
+
+func getFileSize(path string) (uint64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, fmt.Errorf("missing %q", path)
+		}
+		return 0, fmt.Errorf("cannot stat %q: %w", path, err)
+	}
+	return uint64(fi.Size()), nil
+}
@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+func TestStorageDetachAttachPartition(t *testing.T) {
+	defer testRemoveAll(t)
+
+	now := time.Now().UnixMilli()
+	oldTimestamp := now - 60*24*3600*1000
+	oldPartitionName := timestampToPartitionName(oldTimestamp)
+
+	newRows := func(metricName string, timestamp int64) []MetricRow {
+		var mrs []MetricRow
+		for i := 0; i < 100; i++ {
+			mn := MetricName{
+				MetricGroup: []byte(metricName),
+				Tags: []Tag{
+					{
+						Key:   []byte("series"),
+						Value: []byte(fmt.Sprintf("%d", i%10)),
+					},
+				},
+			}
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: mn.marshalRaw(nil),
+				Timestamp:     timestamp + int64(i)*1000,
+				Value:         float64(i),
+			})
+		}
+		return mrs
+	}
+	oldRows := newRows("metric", oldTimestamp)
+	recentRows := newRows("metric", now-3600*1000)
+	allRows := append(append([]MetricRow{}, oldRows...), recentRows...)
+
+	path := filepath.Join(t.Name(), "storage")
+	s := MustOpenStorage(path, OpenOptions{})
+	s.AddRows(oldRows, 64)
+	s.AddRows(recentRows, 64)
+	s.DebugFlush()
+
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("metric"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	tr := TimeRange{
+		MinTimestamp: oldTimestamp,
+		MaxTimestamp: now,
+	}
+	assertSearch := func(s *Storage, want []MetricRow) {
+		t.Helper()
+		if err := testAssertSearchResult(s, tr, tfs, want); err != nil {
+			t.Fatalf("unexpected search result: %s", err)
+		}
+	}
+	assertSearch(s, allRows)
+
+	// Invalid partition names
+	if err := s.DetachPartition("foobar"); err == nil {
+		t.Fatalf("expecting non-nil error when detaching partition with invalid name")
+	}
+	if err := s.AttachPartition("foobar"); err == nil {
+		t.Fatalf("expecting non-nil error when attaching partition with invalid name")
+	}
+
+	// Missing partitions
+	if err := s.DetachPartition("2001_01"); err == nil {
+		t.Fatalf("expecting non-nil error when detaching missing partition")
+	}
+	if err := s.AttachPartition(oldPartitionName); err == nil {
+		t.Fatalf("expecting non-nil error when attaching missing detached partition")
+	}
+
+	// Detach the old partition
+	if err := s.DetachPartition(oldPartitionName); err != nil {
+		t.Fatalf("cannot detach partition: %s", err)
+	}
+	assertSearch(s, recentRows)
+	detachedPath := filepath.Join(path, dataDirname, detachedDirname, oldPartitionName)
+	if !fs.IsPathExist(filepath.Join(detachedPath, smallDirname, partsFilename)) {
+		t.Fatalf("missing detached partition at %q", detachedPath)
+	}
+	if fs.IsPathExist(filepath.Join(path, dataDirname, smallDirname, oldPartitionName)) {
+		t.Fatalf("unexpected partition directory after detaching")
+	}
+
+	// The detached partition must remain detached after the restart.
+	s.MustClose()
+	s = MustOpenStorage(path, OpenOptions{})
+	assertSearch(s, recentRows)
+
+	// Attach the old partition back
+	if err := s.AttachPartition(oldPartitionName); err != nil {
+		t.Fatalf("cannot attach partition: %s", err)
+	}
+	assertSearch(s, allRows)
+	if fs.IsPathExist(detachedPath) {
+		t.Fatalf("unexpected detached partition at %q after attaching it", detachedPath)
+	}
+	if err := s.AttachPartition(oldPartitionName); err == nil {
+		t.Fatalf("expecting non-nil error when attaching already attached partition")
+	}
+
+	s.MustClose()
+	s = MustOpenStorage(path, OpenOptions{})
+	assertSearch(s, allRows)
+
+	// Partition with series registered in the previous indexdb can be attached.
+	s.mustRotateIndexDB(time.Now())
+	if err := s.DetachPartition(oldPartitionName); err != nil {
+		t.Fatalf("cannot detach partition: %s", err)
+	}
+	if err := s.AttachPartition(oldPartitionName); err != nil {
+		t.Fatalf("cannot attach partition with series from the previous indexdb: %s", err)
+	}
+	assertSearch(s, allRows)
+
+	// Partition detached from another storage can be attached, since its series are registered from the detached series file.
+	otherPath := filepath.Join(t.Name(), "other")
+	sOther := MustOpenStorage(otherPath, OpenOptions{})
+	otherTimestamp := oldTimestamp - 31*24*3600*1000
+	otherPartitionName := timestampToPartitionName(otherTimestamp)
+	detachOtherPartition := func(metricName string) string {
+		t.Helper()
+		sOther.AddRows(newRows(metricName, otherTimestamp), 64)
+		sOther.DebugFlush()
+		if err := sOther.DetachPartition(otherPartitionName); err != nil {
+			t.Fatalf("cannot detach partition: %s", err)
+		}
+		srcPath := filepath.Join(otherPath, dataDirname, detachedDirname, otherPartitionName)
+		dstPath := filepath.Join(path, dataDirname, detachedDirname, otherPartitionName)
+		if err := os.Rename(srcPath, dstPath); err != nil {
+			t.Fatalf("cannot move detached partition: %s", err)
+		}
+		return dstPath
+	}
+	fs.MustMkdirIfNotExist(filepath.Join(path, dataDirname, detachedDirname))
+
+	// The series file is missing, so the partition cannot be attached.
+	dstPath := detachOtherPartition("unknown_metric")
+	if err := os.Remove(filepath.Join(dstPath, detachedSeriesFilename)); err != nil {
+		t.Fatalf("cannot remove series file: %s", err)
+	}
+	err := s.AttachPartition(otherPartitionName)
+	if err == nil {
+		t.Fatalf("expecting non-nil error when attaching partition with unknown series")
+	}
+	if !strings.Contains(err.Error(), "aren't registered in indexdb") {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !fs.IsPathExist(dstPath) {
+		t.Fatalf("the detached partition must remain at %q after unsuccessful attach", dstPath)
+	}
+	assertSearch(s, allRows)
+	fs.MustRemoveAll(dstPath)
+
+	// Both series known to the storage and unknown series are attached.
+	dstPath = detachOtherPartition("metric")
+	if err := s.AttachPartition(otherPartitionName); err != nil {
+		t.Fatalf("cannot attach partition from another storage: %s", err)
+	}
+	if fs.IsPathExist(dstPath) {
+		t.Fatalf("unexpected detached partition at %q after attaching it", dstPath)
+	}
+	sOther.MustClose()
+
+	trOther := TimeRange{
+		MinTimestamp: otherTimestamp,
+		MaxTimestamp: now,
+	}
+	assertSearchOther := func(s *Storage) {
+		t.Helper()
+		if err := testAssertSearchResult(s, trOther, tfs, append(newRows("metric", otherTimestamp), allRows...)); err != nil {
+			t.Fatalf("unexpected search result: %s", err)
+		}
+	}
+	assertSearchOther(s)
+
+	s.MustClose()
+	s = MustOpenStorage(path, OpenOptions{})
+	assertSearchOther(s)
+
+	s.MustClose()
+}
+
+func TestDetachedPartitionSeriesPrepareIndexDB(t *testing.T) {
+	defer testRemoveAll(t)
+
+	timestamp := time.Now().UnixMilli() - 10*24*3600*1000
+	mn := MetricName{
+		MetricGroup: []byte("metric"),
+	}
+	mrs := []MetricRow{
+		{
+			MetricNameRaw: mn.marshalRaw(nil),
+			Timestamp:     timestamp,
+			Value:         1,
+		},
+	}
+	s := MustOpenStorage(t.Name(), OpenOptions{})
+	defer s.MustClose()
+	s.AddRows(mrs, 64)
+	s.DebugFlush()
+
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("metric"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	tr := TimeRange{
+		MinTimestamp: timestamp,
+		MaxTimestamp: timestamp,
+	}
+	idb, putIndexDB := s.getCurrIndexDB()
+	defer putIndexDB()
+	metricIDs, err := idb.searchMetricIDs(nil, []*TagFilters{tfs}, tr, 1e3, noDeadline)
+	if err != nil {
+		t.Fatalf("cannot search metricIDs: %s", err)
+	}
+	if len(metricIDs) != 1 {
+		t.Fatalf("unexpected number of metricIDs; got %d; want 1", len(metricIDs))
+	}
+	metricID := metricIDs[0]
+	date := uint64(timestamp) / msecPerDay
+
+	seriesPath := filepath.Join(t.Name(), detachedSeriesFilename)
+	writeSeries := func(metricID uint64, metricGroup string) {
+		t.Helper()
+		tsid := TSID{
+			MetricID: metricID,
+		}
+		mn := MetricName{
+			MetricGroup: []byte(metricGroup),
+		}
+		data := marshalDetachedSeries(nil, &tsid, mn.Marshal(nil))
+		fs.MustWriteSync(seriesPath, data)
+	}
+
+	f := func(metricID, date uint64, checkPerDayIndex bool, errExpected string, seriesExpected, dateSeriesExpected int) {
+		t.Helper()
+
+		var ps detachedPartitionSeries
+		ps.metricIDs.Add(metricID)
+		ps.addDate(date, metricID)
+		dpi, err := ps.prepareIndexDB([]*indexDB{idb}, checkPerDayIndex, seriesPath)
+		if errExpected != "" {
+			if err == nil {
+				t.Fatalf("expecting non-nil error")
+			}
+			if !strings.Contains(err.Error(), errExpected) {
+				t.Fatalf("unexpected error; got %q; want %q", err, errExpected)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n := len(dpi.series); n != seriesExpected {
+			t.Fatalf("unexpected number of series to register; got %d; want %d", n, seriesExpected)
+		}
+		if n := len(dpi.dateMetricIDs[date]); n != dateSeriesExpected {
+			t.Fatalf("unexpected number of per-day index entries to register; got %d; want %d", n, dateSeriesExpected)
+		}
+	}
+
+	// All the entries are registered
+	f(metricID, date, true, "", 0, 0)
+
+	// Unknown series without series file
+	f(metricID+1, date, true, "aren't registered in indexdb", 0, 0)
+
+	// Missing per-day index entry is registered for the known series
+	f(metricID, date+1, true, "", 1, 1)
+
+	// Per-day index entries aren't verified
+	f(metricID, date+1, false, "", 0, 0)
+
+	// Unknown series from series file is registered
+	writeSeries(metricID+1, "metric")
+	f(metricID+1, date, true, "", 1, 1)
+	f(metricID+1, date, false, "", 1, 0)
+
+	// New series mustn't get metricIDs registered from the detached partition
+	var ps detachedPartitionSeries
+	bigMetricID := nextUniqueMetricID.Load() + 1e9
+	ps.metricIDs.Add(bigMetricID)
+	writeSeries(bigMetricID, "big_metric")
+	dpi, err := ps.prepareIndexDB([]*indexDB{idb}, true, seriesPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	dpi.register(idb)
+	if n := generateUniqueMetricID(); n <= bigMetricID {
+		t.Fatalf("unexpected metricID generated after registering metricID=%d; got %d", bigMetricID, n)
+	}
+
+	// Unknown series missing in series file is skipped, since it has been deleted
+	f(metricID+2, date, true, "", 0, 0)
+
+	// Series file with the same metricID for distinct series
+	writeSeries(metricID, "other_metric")
+	f(metricID, date, true, "is already used by the series", 0, 0)
+}
@@ -0,0 +1,276 @@
+package storage
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
+)
+
+// tombstone marks samples on the given time range as deleted for the given series.
+type tombstone struct {
+	// ID is a unique id of the tombstone. Tombstones created later have bigger ids.
+	//
+	// The tombstone must be applied to parts with partHeader.TombstoneID smaller than ID.
+	ID uint64
+
+	// MinTimestamp is the minimum timestamp in milliseconds for the deleted samples.
+	MinTimestamp int64
+
+	// MaxTimestamp is the maximum timestamp in milliseconds for the deleted samples.
+	MaxTimestamp int64
+
+	// MetricIDs contains sorted ids for series with deleted samples.
+	MetricIDs []uint64
+
+	// metricIDs is used for fast lookup of MetricIDs.
+	metricIDs *uint64set.Set
+}
+
+func (ts *tombstone) init() {
+	ts.metricIDs = &uint64set.Set{}
+	ts.metricIDs.AddMulti(ts.MetricIDs)
+}
+
+// String returns string representation of ts.
+func (ts *tombstone) String() string {
+	tr := TimeRange{
+		MinTimestamp: ts.MinTimestamp,
+		MaxTimestamp: ts.MaxTimestamp,
+	}
+	return fmt.Sprintf("tombstone{id=%d, series=%d, timeRange=%s}", ts.ID, len(ts.MetricIDs), &tr)
+}
+
+// mustBeAppliedTo returns true if ts must be applied to samples on the given time range from the part with the given tombstoneID.
+func (ts *tombstone) mustBeAppliedTo(tombstoneID uint64, minTimestamp, maxTimestamp int64) bool {
+	return ts.ID > tombstoneID && ts.MinTimestamp <= maxTimestamp && minTimestamp <= ts.MaxTimestamp
+}
+
+// tombstones is an immutable list of tombstones sorted by ID.
+type tombstones struct {
+	items []*tombstone
+}
+
+// lastID returns the id of the last tombstone in tss.
+//
+// It returns 0 if tss is empty.
+func (tss *tombstones) lastID() uint64 {
+	if tss == nil || len(tss.items) == 0 {
+		return 0
+	}
+	return tss.items[len(tss.items)-1].ID
+}
+
+// appendDeletedTimeRanges appends time ranges with deleted samples for the block with the given bh to dst and returns the result.
+//
+// tombstoneID must contain partHeader.TombstoneID for the part containing the block.
+func (tss *tombstones) appendDeletedTimeRanges(dst []TimeRange, bh *blockHeader, tombstoneID uint64) []TimeRange {
+	if tss == nil {
+		return dst
+	}
+	for _, ts := range tss.items {
+		if !ts.mustBeAppliedTo(tombstoneID, bh.MinTimestamp, bh.MaxTimestamp) || !ts.metricIDs.Has(bh.TSID.MetricID) {
+			continue
+		}
+		dst = append(dst, TimeRange{
+			MinTimestamp: ts.MinTimestamp,
+			MaxTimestamp: ts.MaxTimestamp,
+		})
+	}
+	return dst
+}
+
+// isBlockDeleted returns true if all the samples in the block with the given bh are deleted by tss.
+//
+// tombstoneID must contain partHeader.TombstoneID for the part containing the block.
+func (tss *tombstones) isBlockDeleted(bh *blockHeader, tombstoneID uint64) bool {
+	if tss == nil {
+		return false
+	}
+	for _, ts := range tss.items {
+		if ts.MinTimestamp <= bh.MinTimestamp && bh.MaxTimestamp <= ts.MaxTimestamp &&
+			ts.mustBeAppliedTo(tombstoneID, bh.MinTimestamp, bh.MaxTimestamp) && ts.metricIDs.Has(bh.TSID.MetricID) {
+			return true
+		}
+	}
+	return false
+}
+
+// mustBeAppliedToPart returns true if at least a single tombstone from tss must be applied to the part with the given ph.
+func (tss *tombstones) mustBeAppliedToPart(ph *partHeader) bool {
+	if tss == nil {
+		return false
+	}
+	for _, ts := range tss.items {
+		if ts.mustBeAppliedTo(ph.TombstoneID, ph.MinTimestamp, ph.MaxTimestamp) {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteSamplesOnTimeRanges deletes samples on the given trs from b starting from b.nextIdx.
+//
+// b must be already unmarshaled. The number of deleted samples is returned.
+func deleteSamplesOnTimeRanges(b *Block, trs []TimeRange) int {
+	timestamps := b.timestamps
+	values := b.values
+	n := b.nextIdx
+	for i := b.nextIdx; i < len(timestamps); i++ {
+		if isTimestampInTimeRanges(timestamps[i], trs) {
+			continue
+		}
+		timestamps[n] = timestamps[i]
+		values[n] = values[i]
+		n++
+	}
+	deletedSamples := len(timestamps) - n
+	b.timestamps = timestamps[:n]
+	b.values = values[:n]
+	return deletedSamples
+}
+
+func isTimestampInTimeRanges(timestamp int64, trs []TimeRange) bool {
+	for i := range trs {
+		tr := &trs[i]
+		if timestamp >= tr.MinTimestamp && timestamp <= tr.MaxTimestamp {
+			return true
+		}
+	}
+	return false
+}
+
+func mustLoadTombstones(path string) *tombstones {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &tombstones{}
+		}
+		logger.Panicf("FATAL: cannot read tombstones: %s", err)
+	}
+	var items []*tombstone
+	if err := json.Unmarshal(data, &items); err != nil {
+		logger.Panicf("FATAL: cannot parse tombstones from %q: %s", path, err)
+	}
+	for _, ts := range items {
+		ts.init()
+	}
+	slices.SortFunc(items, func(a, b *tombstone) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return &tombstones{
+		items: items,
+	}
+}
+
+func (tss *tombstones) mustSave(path string) {
+	items := tss.items
+	if items == nil {
+		items = []*tombstone{}
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		logger.Panicf("BUG: cannot marshal tombstones: %s", err)
+	}
+	fs.MustWriteAtomic(path, data, true)
+}
+
+func (s *Storage) getTombstones() *tombstones {
+	return s.tombstones.Load()
+}
+
+func (s *Storage) getTombstonesPath() string {
+	return filepath.Join(s.path, metadataDirname, tombstonesFilename)
+}
+
+// DeleteSeriesOnTimeRange deletes samples on the given tr for series matching tfss.
+//
+// Unlike DeleteSeries, the matching series remain available outside tr.
+// The deleted samples are hidden from search results immediately
+// and are removed from the storage by background merges.
+// Samples, which are added on tr after the call, aren't deleted.
+//
+// Returns the number of series with deleted samples.
+func (s *Storage) DeleteSeriesOnTimeRange(qt *querytracer.Tracer, tfss []*TagFilters, tr TimeRange, maxMetrics int) (int, error) {
+	qt = qt.NewChild("deleting samples on the time range %s for series matching %s", &tr, tfss)
+	defer qt.Done()
+
+	if tr.MinTimestamp > tr.MaxTimestamp {
+		return 0, fmt.Errorf("invalid time range %s: the start time cannot exceed the end time", &tr)
+	}
+
+	idb, putIndexDB := s.getCurrIndexDB()
+	metricIDs, err := idb.searchMetricIDs(qt, tfss, tr, maxMetrics, noDeadline)
+	putIndexDB()
+	if err != nil {
+		return 0, fmt.Errorf("cannot search for series to delete: %w", err)
+	}
+	if len(metricIDs) == 0 {
+		return 0, nil
+	}
+
+	// Convert pending rows to parts, so the created tombstone could be applied to them.
+	s.tb.flushPendingRows()
+
+	ts := &tombstone{
+		MinTimestamp: tr.MinTimestamp,
+		MaxTimestamp: tr.MaxTimestamp,
+		MetricIDs:    metricIDs,
+	}
+	ts.init()
+
+	s.tombstonesLock.Lock()
+	tss := s.getTombstones()
+
+	// Use the current time for tombstone ids, so they remain unique after the last tombstone is dropped.
+	ts.ID = max(uint64(time.Now().UnixNano()), tss.lastID()+1)
+
+	items := append([]*tombstone{}, tss.items...)
+	items = append(items, ts)
+	tssNew := &tombstones{
+		items: items,
+	}
+	tssNew.mustSave(s.getTombstonesPath())
+	s.tombstones.Store(tssNew)
+	s.tombstonesLock.Unlock()
+
+	qt.Printf("created %s", ts)
+	logger.Infof("created %s", ts)
+
+	return len(metricIDs), nil
+}
+
+// dropAppliedTombstones drops tombstones, which are applied to all the parts.
+//
+// isApplied must return true if the given ts is applied to all the parts.
+func (s *Storage) dropAppliedTombstones(isApplied func(ts *tombstone) bool) {
+	s.tombstonesLock.Lock()
+	defer s.tombstonesLock.Unlock()
+
+	tss := s.getTombstones()
+	var items []*tombstone
+	for _, ts := range tss.items {
+		if isApplied(ts) {
+			logger.Infof("dropping %s, since it has been applied to all the parts", ts)
+			continue
+		}
+		items = append(items, ts)
+	}
+	if len(items) == len(tss.items) {
+		return
+	}
+	tssNew := &tombstones{
+		items: items,
+	}
+	tssNew.mustSave(s.getTombstonesPath())
+	s.tombstones.Store(tssNew)
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+func TestDeleteSamplesOnTimeRanges(t *testing.T) {
+	f := func(timestamps []int64, nextIdx int, trs []TimeRange, timestampsExpected []int64) {
+		t.Helper()
+
+		var b Block
+		b.timestamps = append(b.timestamps, timestamps...)
+		for _, ts := range timestamps {
+			b.values = append(b.values, ts*10)
+		}
+		b.nextIdx = nextIdx
+		n := deleteSamplesOnTimeRanges(&b, trs)
+		if n != len(timestamps)-len(timestampsExpected) {
+			t.Fatalf("unexpected number of deleted samples; got %d; want %d", n, len(timestamps)-len(timestampsExpected))
+		}
+		if !reflect.DeepEqual(b.timestamps, timestampsExpected) {
+			t.Fatalf("unexpected timestamps; got %d; want %d", b.timestamps, timestampsExpected)
+		}
+		for i, ts := range b.timestamps {
+			if b.values[i] != ts*10 {
+				t.Fatalf("unexpected value at position %d; got %d; want %d", i, b.values[i], ts*10)
+			}
+		}
+	}
+
+	// No time ranges
+	f([]int64{1, 2, 3}, 0, nil, []int64{1, 2, 3})
+
+	// Time range outside the samples
+	f([]int64{1, 2, 3}, 0, []TimeRange{{MinTimestamp: 5, MaxTimestamp: 10}}, []int64{1, 2, 3})
+
+	// Time range in the middle
+	f([]int64{1, 2, 3, 4, 5}, 0, []TimeRange{{MinTimestamp: 2, MaxTimestamp: 3}}, []int64{1, 4, 5})
+
+	// Multiple time ranges
+	f([]int64{1, 2, 3, 4, 5}, 0, []TimeRange{{MinTimestamp: 1, MaxTimestamp: 1}, {MinTimestamp: 4, MaxTimestamp: 10}}, []int64{2, 3})
+
+	// All the samples are deleted
+	f([]int64{1, 2, 3}, 0, []TimeRange{{MinTimestamp: 0, MaxTimestamp: 3}}, []int64{})
+
+	// Samples before nextIdx are left untouched
+	f([]int64{1, 2, 3, 4}, 2, []TimeRange{{MinTimestamp: 0, MaxTimestamp: 3}}, []int64{1, 2, 4})
+}
+
+func TestStorageDeleteSeriesOnTimeRange(t *testing.T) {
+	defer testRemoveAll(t)
+
+	const seriesCount = 10
+	const samplesPerSeries = 100
+	startTimestamp := time.Now().UnixMilli() - 10*24*3600*1000
+	newRows := func(timestamp int64, samples int) []MetricRow {
+		var mrs []MetricRow
+		for i := 0; i < samples; i++ {
+			for j := 0; j < seriesCount; j++ {
+				mn := MetricName{
+					MetricGroup: []byte("metric"),
+					Tags: []Tag{
+						{
+							Key:   []byte("series"),
+							Value: []byte(fmt.Sprintf("%d", j)),
+						},
+					},
+				}
+				mrs = append(mrs, MetricRow{
+					MetricNameRaw: mn.marshalRaw(nil),
+					Timestamp:     timestamp + int64(i)*1000,
+					Value:         float64(i),
+				})
+			}
+		}
+		return mrs
+	}
+	filterRows := func(mrs []MetricRow, seriesValue string, tr TimeRange) []MetricRow {
+		var result []MetricRow
+		var mn MetricName
+		for _, mr := range mrs {
+			if err := mn.UnmarshalRaw(mr.MetricNameRaw); err != nil {
+				t.Fatalf("cannot unmarshal metric name: %s", err)
+			}
+			if string(mn.GetTagValue("series")) == seriesValue && mr.Timestamp >= tr.MinTimestamp && mr.Timestamp <= tr.MaxTimestamp {
+				continue
+			}
+			result = append(result, mr)
+		}
+		return result
+	}
+	newTagFilters := func(seriesValue string) *TagFilters {
+		tfs := NewTagFilters()
+		if err := tfs.Add(nil, []byte("metric"), false, false); err != nil {
+			t.Fatalf("cannot add tag filter: %s", err)
+		}
+		if seriesValue != "" {
+			if err := tfs.Add([]byte("series"), []byte(seriesValue), false, false); err != nil {
+				t.Fatalf("cannot add tag filter: %s", err)
+			}
+		}
+		return tfs
+	}
+
+	s := MustOpenStorage(t.Name(), OpenOptions{})
+	mrs := newRows(startTimestamp, samplesPerSeries)
+	s.AddRows(mrs, 64)
+	s.DebugFlush()
+
+	searchTR := TimeRange{
+		MinTimestamp: startTimestamp,
+		MaxTimestamp: startTimestamp + samplesPerSeries*1000,
+	}
+	assertSearch := func(s *Storage, want []MetricRow) {
+		t.Helper()
+		want = append([]MetricRow{}, want...)
+		if err := testAssertSearchResult(s, searchTR, newTagFilters(""), want); err != nil {
+			t.Fatalf("unexpected search result: %s", err)
+		}
+	}
+	assertPendingTombstones := func(s *Storage, want uint64) {
+		t.Helper()
+		var m Metrics
+		s.UpdateMetrics(&m)
+		if m.PendingTombstones != want {
+			t.Fatalf("unexpected number of pending tombstones; got %d; want %d", m.PendingTombstones, want)
+		}
+	}
+	deleteSeries := func(seriesValue string, tr TimeRange) {
+		t.Helper()
+		n, err := s.DeleteSeriesOnTimeRange(nil, []*TagFilters{newTagFilters(seriesValue)}, tr, 1e5)
+		if err != nil {
+			t.Fatalf("cannot delete series: %s", err)
+		}
+		if n != 1 {
+			t.Fatalf("unexpected number of series with deleted samples; got %d; want 1", n)
+		}
+		mrs = filterRows(mrs, seriesValue, tr)
+	}
+
+	// Invalid time range
+	if _, err := s.DeleteSeriesOnTimeRange(nil, []*TagFilters{newTagFilters("")}, TimeRange{MinTimestamp: 10, MaxTimestamp: 1}, 1e5); err == nil {
+		t.Fatalf("expecting non-nil error for invalid time range")
+	}
+
+	// Delete samples in the middle of the series.
+	deleteSeries("1", TimeRange{
+		MinTimestamp: startTimestamp + 20*1000,
+		MaxTimestamp: startTimestamp + 40*1000,
+	})
+	assertSearch(s, mrs)
+
+	// Delete all the samples for the series.
+	deleteSeries("2", searchTR)
+	assertSearch(s, mrs)
+	assertPendingTombstones(s, 2)
+
+	// Samples added on the deleted time range after the deletion must be visible.
+	mrsNew := newRows(startTimestamp+20*1000+500, 1)
+	s.AddRows(mrsNew, 64)
+	s.DebugFlush()
+	mrs = append(mrs, mrsNew...)
+	assertSearch(s, mrs)
+
+	// Tombstones must be preserved after the restart.
+	s.MustClose()
+	tombstonesPath := filepath.Join(t.Name(), metadataDirname, tombstonesFilename)
+	if !fs.IsPathExist(tombstonesPath) {
+		t.Fatalf("missing tombstones file at %q", tombstonesPath)
+	}
+	s = MustOpenStorage(t.Name(), OpenOptions{})
+	assertSearch(s, mrs)
+	assertPendingTombstones(s, 2)
+
+	// Tombstones must be dropped after being applied to all the parts.
+	s.tb.applyTombstones()
+	assertSearch(s, mrs)
+	assertPendingTombstones(s, 0)
+
+	s.MustClose()
+	s = MustOpenStorage(t.Name(), OpenOptions{})
+	assertSearch(s, mrs)
+	assertPendingTombstones(s, 0)
+	s.MustClose()
+}